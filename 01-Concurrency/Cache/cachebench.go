@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+)
+
+// cachebench drives a Memory with a synthetic workload and reports what a real program
+// would see: throughput, latency percentiles and hit ratio. Unlike the benchmarks of
+// main_test.go, which compare implementations on one fixed loop, every parameter of the
+// workload is a flag, to try a configuration before using it.
+//
+// Real key popularity is rarely uniform: a few keys get most of the reads. The zipf
+// distribution models that, and is where a small cache and a good policy pay off.
+
+// Flags of --cachebench
+var (
+	cachebench = flag.Bool("cachebench", false, "run a workload against the cache instead of the demo, see cachebench.go")
+	benchKeys  = flag.Int("keys", 100_000, "cachebench: number of distinct keys")
+	benchDist  = flag.String("dist", "zipf", "cachebench: key distribution, zipf or uniform")
+	benchZipfS = flag.Float64("zipf-s", 1.1, "cachebench: skew of the zipf distribution, above 1")
+	benchReads = flag.Float64("reads", 0.9, "cachebench: share of reads, the rest are Sets")
+	benchProcs = flag.Int("goroutines", 8, "cachebench: goroutines calling the cache")
+	benchTime  = flag.Duration("duration", 3*time.Second, "cachebench: length of the run")
+	benchLoad  = flag.Duration("load", 0, "cachebench: time spent by the function on a miss, like a backend")
+	benchMax   = flag.Int("max-entries", 10_000, "cachebench: WithMaxEntries, 0 means unbounded")
+	benchPol   = flag.String("policy", "LRU", "cachebench: eviction policy, LRU, LFU, FIFO or ARC")
+)
+
+// Workload describes a cachebench run
+type Workload struct {
+	Keys       int
+	Zipf       bool    // Zipf distribution of the keys, uniform otherwise
+	ZipfS      float64 // Skew of the zipf distribution
+	Reads      float64 // Share of Gets, between 0 and 1
+	Goroutines int
+	Duration   time.Duration
+	Load       time.Duration  // Duration of a call to the function
+	Options    []cache.Option // Options of the cache
+}
+
+// BenchReport is the result of a cachebench run
+type BenchReport struct {
+	Ops        int64
+	Throughput float64 // Operations per second
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	Stats      cache.Stats
+}
+
+// latencySamples is the size of the reservoir of latencies of each goroutine:
+// a run makes millions of operations, a uniform sample of them is enough
+const latencySamples = 10_000
+
+// RunWorkload runs workload against a new cache and measures it
+func RunWorkload(workload Workload) BenchReport {
+	m := cache.NewCache(func(key int, m *cache.Memory[int, int]) (int, error) {
+		if workload.Load > 0 {
+			time.Sleep(workload.Load)
+		}
+		return key, nil
+	}, workload.Options...)
+	defer m.Close()
+
+	var (
+		mux     sync.Mutex
+		ops     int64
+		samples []time.Duration
+		slowest time.Duration
+		wg      sync.WaitGroup
+	)
+	deadline := time.Now().Add(workload.Duration)
+	for g := range workload.Goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), uint64(g)))
+			next := func() int { return r.IntN(workload.Keys) }
+			if workload.Zipf {
+				zipf := rand.NewZipf(r, workload.ZipfS, 1, uint64(workload.Keys-1))
+				next = func() int { return int(zipf.Uint64()) }
+			}
+
+			var n int64
+			var local []time.Duration
+			var longest time.Duration
+			for ; time.Now().Before(deadline); n++ {
+				key := next()
+				start := time.Now()
+				if r.Float64() < workload.Reads {
+					m.Get(key)
+				} else {
+					m.Set(key, key)
+				}
+				elapsed := time.Since(start)
+				longest = max(longest, elapsed)
+				// Reservoir sampling: every operation has the same chance to be kept
+				if len(local) < latencySamples {
+					local = append(local, elapsed)
+				} else if i := r.Int64N(n + 1); i < latencySamples {
+					local[i] = elapsed
+				}
+			}
+
+			mux.Lock()
+			ops += n
+			samples = append(samples, local...)
+			slowest = max(slowest, longest)
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(samples)
+	percentile := func(p float64) time.Duration {
+		if len(samples) == 0 {
+			return 0
+		}
+		return samples[min(int(p*float64(len(samples))), len(samples)-1)]
+	}
+	return BenchReport{
+		Ops:        ops,
+		Throughput: float64(ops) / workload.Duration.Seconds(),
+		P50:        percentile(0.50),
+		P90:        percentile(0.90),
+		P99:        percentile(0.99),
+		Max:        slowest,
+		Stats:      m.Stats(),
+	}
+}
+
+// runCachebench builds the workload from the flags, runs it and prints the report
+func runCachebench() {
+	workload := Workload{
+		Keys:       max(*benchKeys, 1),
+		Zipf:       *benchDist == "zipf",
+		ZipfS:      *benchZipfS,
+		Reads:      *benchReads,
+		Goroutines: max(*benchProcs, 1),
+		Duration:   *benchTime,
+		Load:       *benchLoad,
+	}
+	if *benchDist != "zipf" && *benchDist != "uniform" {
+		fmt.Fprintf(os.Stderr, "unknown distribution %q, want zipf or uniform\n", *benchDist)
+		os.Exit(2)
+	}
+	if workload.Zipf && workload.ZipfS <= 1 {
+		fmt.Fprintln(os.Stderr, "the zipf skew must be above 1")
+		os.Exit(2)
+	}
+	if *benchMax > 0 {
+		policies := []cache.Policy{cache.LRU, cache.LFU, cache.FIFO, cache.ARC}
+		index := slices.IndexFunc(policies, func(kind cache.Policy) bool {
+			return strings.EqualFold(kind.String(), *benchPol)
+		})
+		if index < 0 {
+			fmt.Fprintf(os.Stderr, "unknown policy %q, want LRU, LFU, FIFO or ARC\n", *benchPol)
+			os.Exit(2)
+		}
+		workload.Options = append(workload.Options, cache.WithMaxEntries(*benchMax), cache.WithPolicy(policies[index]))
+	}
+
+	fmt.Printf("%d %s keys, %.0f%% reads, %d goroutines, %s, load %s, max entries %d (%s)\n",
+		workload.Keys, *benchDist, 100*workload.Reads, workload.Goroutines, workload.Duration,
+		workload.Load, *benchMax, strings.ToUpper(*benchPol))
+	report := RunWorkload(workload)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ops\t%d\n", report.Ops)
+	fmt.Fprintf(w, "throughput\t%.0f ops/s\n", report.Throughput)
+	fmt.Fprintf(w, "latency\tp50 %s\tp90 %s\tp99 %s\tmax %s\n", report.P50, report.P90, report.P99, report.Max)
+	fmt.Fprintf(w, "hit ratio\t%.3f\n", report.Stats.HitRatio())
+	fmt.Fprintf(w, "cache\t%d entries\t%d evictions\n", report.Stats.Entries, report.Stats.Evictions)
+	w.Flush()
+}
@@ -1,9 +1,33 @@
+// RUN PROGRAM WITH FLAGS
+// go run . --persist=fibonacci.gob
+// A second run restores the Fibonacci results calculated by the first one
+// go run . --redis=localhost:6379
+// Shares the Fibonacci results through Redis (or 03-Net/MiniRedis): run it twice,
+// the second run reads them from the server instead of calculating them
+// go run . --debug=localhost:8080
+// After the demo, serves the Fibonacci cache in JSON on http://localhost:8080/debug/cache
+// go run . --cachebench --dist=zipf --keys=100000 --max-entries=10000 --reads=0.9 --goroutines=8
+// Runs a synthetic workload and reports throughput, latency percentiles and hit ratio
+// go test -race . ../../pkg/cache
+// Checks the demo, then the cache of pkg/cache with a clock.Fake for the TTLs and fake
+// Redis and memcached servers
+// go test -run x -bench . -cpu 1,8
+// Compares the mutex, sharded and sync.Map caches under reads and writes from every core
+
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"sync"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
 )
 
 // FibonacciCached calculates the Fibonacci number for a given value 'n'
@@ -13,78 +37,203 @@ import (
 //   - n: The position in the Fibonacci sequence to calculate
 //   - m: Pointer to the Memory cache system
 //
-// Returns: The Fibonacci number at position n
-func FibonacciCached(n int, m *Memory) int {
+// Returns: The Fibonacci number at position n, or an error for negative positions
+func FibonacciCached(n int, m *cache.Memory[int, int]) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("fibonacci of negative position %d", n)
+	}
 	if n <= 1 {
-		return n
+		return n, nil
 	}
 	// Gets the previous values from cache and adds them
-	return m.Get(n-1) + m.Get(n-2)
+	previous, err := m.Get(n - 1)
+	if err != nil {
+		return 0, err
+	}
+	beforePrevious, err := m.Get(n - 2)
+	if err != nil {
+		return 0, err
+	}
+	return previous + beforePrevious, nil
 }
 
-// Function is a type that defines the signature of functions that can be cached
-// It takes a key and a pointer to the cache memory system
-type Function func(key int, m *Memory) int
+// FibonacciBig is FibonacciCached with arbitrary precision: the int version overflows
+// after position 92, while *big.Int grows as needed. The cached values are shared by
+// every caller, so they are never modified: each sum is a new big.Int.
+func FibonacciBig(n int, m *cache.Memory[int, *big.Int]) (*big.Int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("fibonacci of negative position %d", n)
+	}
+	if n <= 1 {
+		return big.NewInt(int64(n)), nil
+	}
+	previous, err := m.Get(n - 1)
+	if err != nil {
+		return nil, err
+	}
+	beforePrevious, err := m.Get(n - 2)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Add(previous, beforePrevious), nil
+}
 
-// Memory implements a thread-safe caching system
-// This structure ensures safe concurrent access to cached values
-type Memory struct {
-	f     Function    // The function to be cached
-	cache map[int]int // Map that stores cached results
-	mux   sync.Mutex  // Mutex to ensure thread-safe access to the cache
+// WordStats is a struct result cached by a string key
+type WordStats struct {
+	Length int
+	Vowels int
 }
 
-// NewCache creates a new instance of the caching system
-// Parameters:
-//   - f: The function to be cached
-//
-// Returns: A pointer to a new Memory instance
-func NewCache(f Function) *Memory {
-	return &Memory{
-		f:     f,
-		cache: make(map[int]int),
+// AnalyzeWord is a string-keyed function cached with the same Memory type
+func AnalyzeWord(word string, m *cache.Memory[string, WordStats]) (WordStats, error) {
+	if word == "" {
+		return WordStats{}, errors.New("empty word")
+	}
+	stats := WordStats{Length: len(word)}
+	for _, r := range strings.ToLower(word) {
+		if strings.ContainsRune("aeiou", r) {
+			stats.Vowels++
+		}
 	}
+	return stats, nil
 }
 
-// Get retrieves a value from the cache. If it doesn't exist, calculates and stores it
-// This method is thread-safe thanks to mutex implementation
-// Parameters:
-//   - key: The input value for which we want to cache the result
-//
-// Returns: The cached or newly calculated result
-func (m *Memory) Get(key int) int {
-	// First attempt to read from cache, protected by mutex
-	m.mux.Lock()
-	result, exists := m.cache[key]
-	m.mux.Unlock()
-
-	// If the value doesn't exist in cache, we calculate it
-	if !exists {
-		m.mux.Lock()
-		// Calculate the result using the stored function
-		result = m.f(key, m)
-		// Store the result in cache
-		m.cache[key] = result
-		m.mux.Unlock()
-	}
-	return result
+// caches is the registry of the program, like http.DefaultServeMux is its default mux
+var caches = cache.NewRegistry()
+
+// File where the Fibonacci results are saved on exit and restored on start, empty to disable
+var persist = flag.String("persist", "", "file to save the Fibonacci cache to on exit and restore it from on start")
+
+// Redis server used as the second layer of a Layered cache, empty to skip that demo
+var redisAddr = flag.String("redis", "", "address of a Redis server shared by the runs of the program")
+
+// eventLogger is an observer printing the events of a cache
+type eventLogger[K comparable, V any] struct {
+	id string
+}
+
+func (l eventLogger[K, V]) GetId() string {
+	return l.id
 }
 
+func (l eventLogger[K, V]) UpdateValue(_ context.Context, event cache.Event[K, V]) error {
+	fmt.Printf("   [%s] %s %v\n", l.id, event.Kind, event.Key)
+	return nil
+}
+
+// redisDemo calculates Fibonacci numbers in a Layered cache over Redis and counts
+// how many were calculated here and how many came from the server
+func redisDemo(addr string) {
+	store := cache.NewRedisStore[int, *big.Int](addr, "fibonacci:", time.Hour, 4, nil)
+	defer store.Close()
+	calculated := 0
+	layered := cache.NewLayered(func(n int, m *cache.Memory[int, *big.Int]) (*big.Int, error) {
+		calculated++
+		return FibonacciBig(n, m)
+	}, store)
+	start := time.Now()
+	value, err := layered.Get(300)
+	if err != nil {
+		fmt.Println(" redis:", err)
+		return
+	}
+	fmt.Printf(" redis: fibonacci(300) = %s in %s, %d of 301 results calculated here\n",
+		value, time.Since(start).Round(time.Microsecond), calculated)
+}
+
+// Address serving the Handler of the Fibonacci cache after the demo, empty to exit instead
+var debugAddr = flag.String("debug", "", "address to serve the Fibonacci cache on after the demo, see pkg/cache/debug.go")
+
 func main() {
-	// Create a new cache instance for the Fibonacci function
-	cache := NewCache(FibonacciCached)
+	flag.Parse()
+	if *cachebench {
+		runCachebench()
+		return
+	}
+
+	// Create a new cache instance for the Fibonacci function; *big.Int values
+	// keep the result of 1000 exact, int would overflow after position 92
+	fib := cache.NewCache(FibonacciBig)
+	if *persist != "" {
+		restored, err := fib.LoadFile(*persist)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf(" no snapshot in %s yet, starting cold\n", *persist)
+		case err != nil:
+			fmt.Println(" restoring the cache:", err)
+		default:
+			fmt.Printf(" restored %d results from %s\n", restored, *persist)
+		}
+		defer func() {
+			if err := fib.SaveFile(*persist); err != nil {
+				fmt.Println(" saving the cache:", err)
+				return
+			}
+			fmt.Printf(" saved %d results to %s\n", len(fib.Keys()), *persist)
+		}()
+	}
 
 	// List of Fibonacci numbers we want to calculate
 	// Note that some numbers are repeated to demonstrate cache effectiveness
-	tasks := []int{42, 40, 41, 42, 38, 1000}
+	tasks := []int{42, 40, 41, 42, 38, 1000, -1}
 
 	// Calculate each number and measure the time taken
 	// This demonstrates how subsequent calculations of the same number
 	// are much faster due to caching
 	for _, n := range tasks {
 		start := time.Now()
-		value := cache.Get(n)
+		value, err := fib.Get(n)
+		if err != nil {
+			fmt.Printf(" %d, %s, error: %v\n", n, time.Since(start), err)
+			continue
+		}
 		// Print: calculated number, elapsed time, and result
 		fmt.Printf(" %d, %s, %d\n", n, time.Since(start), value)
 	}
+
+	// The same cache works with any comparable key and any result type
+	// The events of the cache are printed by an observer, see pkg/cache/observer.go
+	words := cache.NewCache(AnalyzeWord)
+	words.Subscribe(eventLogger[string, WordStats]{id: "logger"})
+	for _, word := range []string{"concurrency", "cache", "concurrency", ""} {
+		stats, err := words.Get(word)
+		fmt.Printf(" %q, %+v, %v\n", word, stats, err)
+	}
+
+	// The registry of pkg/cache finds both caches by name, whatever their types
+	caches.Register("fibonacci", fib)
+	caches.Register("words", words)
+	byName, total := caches.Stats()
+	for _, name := range caches.Names() {
+		fmt.Printf(" registry: %s has %d results\n", name, byName[name].Entries)
+	}
+	fmt.Printf(" registry: %d results in total, hit ratio %.2f\n", total.Entries, total.HitRatio())
+
+	// With a TTL the results are calculated again once they expire
+	clock := cache.NewCacheWithTTL(func(zone string, m *cache.Memory[string, string]) (string, error) {
+		return time.Now().Format("15:04:05.000"), nil
+	}, 100*time.Millisecond)
+	for _, wait := range []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond} {
+		time.Sleep(wait)
+		now, _ := clock.Get("UTC")
+		fmt.Printf(" time cached for 100ms after waiting %s: %s\n", wait, now)
+	}
+
+	if *redisAddr != "" {
+		redisDemo(*redisAddr)
+	}
+
+	// The duplicated jobs of the massive operations demo, counted by Stats,
+	// first with every calculation at once, then at most 2 at a time
+	massiveOperationsCached(20*time.Millisecond, 10, 0)
+	stats := massiveOperationsCached(20*time.Millisecond, 10, 2)
+	fmt.Printf(" stats: %+v\n", stats)
+
+	if *debugAddr != "" {
+		http.Handle("/debug/cache", fib.Handler())
+		fmt.Printf(" serving the Fibonacci cache on http://%s/debug/cache\n", *debugAddr)
+		if err := http.ListenAndServe(*debugAddr, nil); err != nil {
+			fmt.Println(" debug server:", err)
+		}
+	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+)
+
+// TestFibonacci checks the memoized functions of the demo: FibonacciBig past the
+// overflow of int, and the errors of a negative position and an empty word
+func TestFibonacci(t *testing.T) {
+	want, _ := new(big.Int).SetString("354224848179261915075", 10)
+	if got, err := cache.NewCache(FibonacciBig).Get(100); err != nil || got.Cmp(want) != 0 {
+		t.Errorf("got fibonacci(100) = %v, %v, want %s", got, err, want)
+	}
+	if got, err := cache.NewCache(FibonacciCached).Get(90); err != nil || got != 2880067194370816120 {
+		t.Errorf("got fibonacci(90) = %d, %v", got, err)
+	}
+	if _, err := cache.NewCache(FibonacciCached).Get(-1); err == nil {
+		t.Error("got no error for a negative position")
+	}
+	words := cache.NewCache(AnalyzeWord)
+	if stats, err := words.Get("Cache"); err != nil || stats != (WordStats{Length: 5, Vowels: 2}) {
+		t.Errorf("got %+v, %v for Cache", stats, err)
+	}
+	if _, err := words.Get(""); err == nil {
+		t.Error("got no error for the empty word")
+	}
+}
+
+// TestWorkload runs two short cachebench workloads with the same small cache: the zipf
+// keys, where a few are read most of the time, must hit more often than the uniform ones
+func TestWorkload(t *testing.T) {
+	workload := Workload{
+		Keys:       10_000,
+		ZipfS:      1.2,
+		Reads:      1,
+		Goroutines: 2,
+		Duration:   50 * time.Millisecond,
+		Options:    []cache.Option{cache.WithMaxEntries(500)},
+	}
+	uniform := RunWorkload(workload)
+	workload.Zipf = true
+	zipf := RunWorkload(workload)
+	for _, report := range []BenchReport{uniform, zipf} {
+		if report.Ops == 0 || report.P50 > report.P99 || report.P99 > report.Max || report.Stats.Entries > 500 {
+			t.Fatalf("inconsistent report %+v", report)
+		}
+	}
+	if zipf.Stats.HitRatio() <= uniform.Stats.HitRatio() {
+		t.Errorf("zipf hit ratio %.2f, not above uniform %.2f", zipf.Stats.HitRatio(), uniform.Stats.HitRatio())
+	}
+}
+
+// benchCache is what the benchmarks need from the three implementations
+type benchCache interface {
+	Get(key int) (int, error)
+	Set(key, value int)
+}
+
+// BenchmarkCache compares Memory (one mutex), Sharded (a mutex per shard) and SyncMemory
+// (sync.Map) under three workloads, with GOMAXPROCS goroutines calling the cache at once.
+// With reads only, SyncMemory takes no lock while every Get of Memory goes through its
+// single mutex. As writes grow, sync.Map pays for the promotion of its dirty map, and
+// Sharded, which only splits the contention over its shards, usually catches up.
+// With -cpu 1 the locks are never disputed and the three are close.
+func BenchmarkCache(b *testing.B) {
+	const keys = 1024
+	identity := func(key int, m *cache.Memory[int, int]) (int, error) { return key, nil }
+	implementations := []struct {
+		name string
+		new  func() benchCache
+	}{
+		{"Mutex", func() benchCache { return cache.NewCache(identity) }},
+		{"Sharded", func() benchCache { return cache.NewSharded(identity, 16) }},
+		{"SyncMap", func() benchCache {
+			return cache.NewSyncCache(func(key int, m *cache.SyncMemory[int, int]) (int, error) { return key, nil })
+		}},
+	}
+	workloads := []struct {
+		name   string
+		writes int // Percentage of Set among the calls
+	}{
+		{"ReadHeavy", 0},
+		{"Mixed", 10},
+		{"WriteHeavy", 90},
+	}
+
+	for _, workload := range workloads {
+		for _, implementation := range implementations {
+			b.Run(workload.name+"/"+implementation.name, func(b *testing.B) {
+				c := implementation.new()
+				for key := range keys {
+					c.Get(key)
+				}
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						key := rand.N(keys)
+						if rand.N(100) < workload.writes {
+							c.Set(key, key)
+						} else {
+							c.Get(key)
+						}
+					}
+				})
+			})
+		}
+	}
+}
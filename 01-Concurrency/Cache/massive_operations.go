@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
 )
 
 // ExpensiveFibonacci simulates an expensive calculation by adding a delay
@@ -119,3 +121,48 @@ func massiveOperations() {
 	// Wait for all goroutines to complete
 	wg.Wait()
 }
+
+// massiveOperationsCached runs the same kind of duplicated jobs through a Memory
+// and uses its Stats to show how many calculations the cache avoided
+// Parameters:
+//   - delay: The simulated cost of each calculation
+//   - rounds: How many times every job is requested
+//   - maxLoads: How many calculations may run at once, 0 for no limit
+func massiveOperationsCached(delay time.Duration, rounds, maxLoads int) cache.Stats {
+	// Calculations running right now, and the most seen at once
+	running, peak := 0, 0
+	var runningMux sync.Mutex
+	memo := cache.NewCache(func(job int, m *cache.Memory[int, int]) (int, error) {
+		runningMux.Lock()
+		running++
+		peak = max(peak, running)
+		runningMux.Unlock()
+		defer func() {
+			runningMux.Lock()
+			running--
+			runningMux.Unlock()
+		}()
+		time.Sleep(delay)
+		return job, nil
+	}, cache.WithMaxConcurrentLoads(maxLoads))
+	jobs := []int{3, 4, 5, 5, 4, 3, 2, 1, 0}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range rounds {
+		for _, job := range jobs {
+			wg.Add(1)
+			go func(job int) {
+				defer wg.Done()
+				memo.Get(job)
+			}(job)
+		}
+	}
+	wg.Wait()
+
+	stats := memo.Stats()
+	fmt.Printf(" %d jobs in %s: %d calculated, at most %d at once, %d from the cache (%.0f%% hits), about %s saved\n",
+		rounds*len(jobs), time.Since(start).Round(time.Millisecond), stats.Misses, peak, stats.Hits,
+		100*stats.HitRatio(), stats.Saved().Round(time.Millisecond))
+	return stats
+}
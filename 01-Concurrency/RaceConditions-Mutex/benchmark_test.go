@@ -0,0 +1,190 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/account"
+)
+
+// bankAccount is what the benchmarks need from the implementations
+type bankAccount interface {
+	Deposit(amount account.Money) error
+	Withdraw(amount account.Money) error
+	Balance() account.Money
+}
+
+// mutexAccount keeps a balance behind a plain Mutex, so reads wait for each other too
+type mutexAccount struct {
+	balance account.Money
+	mux     sync.Mutex
+}
+
+func (a *mutexAccount) Deposit(amount account.Money) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	balance, err := a.balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	a.balance = balance
+	return nil
+}
+
+func (a *mutexAccount) Withdraw(amount account.Money) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	balance, err := a.balance.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if balance.IsNegative() {
+		return account.ErrInsufficientFunds
+	}
+	a.balance = balance
+	return nil
+}
+
+func (a *mutexAccount) Balance() account.Money {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.balance
+}
+
+// rwmutexAccount is mutexAccount with a RWMutex, so reads run together. Unlike
+// account.Account it keeps no history, the comparison only measures the lock.
+type rwmutexAccount struct {
+	balance account.Money
+	mux     sync.RWMutex
+}
+
+func (a *rwmutexAccount) Deposit(amount account.Money) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	balance, err := a.balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	a.balance = balance
+	return nil
+}
+
+func (a *rwmutexAccount) Withdraw(amount account.Money) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	balance, err := a.balance.Sub(amount)
+	if err != nil {
+		return err
+	}
+	if balance.IsNegative() {
+		return account.ErrInsufficientFunds
+	}
+	a.balance = balance
+	return nil
+}
+
+func (a *rwmutexAccount) Balance() account.Money {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return a.balance
+}
+
+// BenchmarkAccount compares the Mutex, RWMutex and atomic versions under several mixes of
+// reads and writes, with GOMAXPROCS goroutines calling the same account. RWMutex lets the
+// reads run together and wins when they dominate, but its bookkeeping costs more than a
+// Mutex when writes are frequent; atomic operations avoid locking altogether.
+func BenchmarkAccount(b *testing.B) {
+	initial, cent := account.NewMoney(1_000_000, "USD"), account.NewMoney(1, "USD")
+	implementations := []struct {
+		name string
+		new  func() bankAccount
+	}{
+		{"Mutex", func() bankAccount { return &mutexAccount{balance: initial} }},
+		{"RWMutex", func() bankAccount { return &rwmutexAccount{balance: initial} }},
+		{"Atomic", func() bankAccount { return account.NewAtomicAccount(initial) }},
+	}
+	workloads := []struct {
+		name  string
+		reads int // Percentage of Balance among the calls, the rest are Deposit and Withdraw
+	}{
+		{"Reads100", 100},
+		{"Reads90", 90},
+		{"Reads50", 50},
+		{"Reads10", 10},
+	}
+
+	for _, workload := range workloads {
+		for _, implementation := range implementations {
+			b.Run(workload.name+"/"+implementation.name, func(b *testing.B) {
+				shared := implementation.new()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						switch n := rand.N(100); {
+						case n < workload.reads:
+							shared.Balance()
+						case n%2 == 0:
+							shared.Deposit(cent)
+						default:
+							shared.Withdraw(cent)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkContention compares locking (mutexAccount) with optimistic updates
+// (account.OptimisticAccount) on deposits and withdrawals only. With 1024 accounts two
+// goroutines rarely update the same one, with a single account they always do: that is
+// where the optimistic updates start failing their commits and redoing their work. The
+// conflicts are reported per operation.
+func BenchmarkContention(b *testing.B) {
+	initial, cent := account.NewMoney(1_000_000, "USD"), account.NewMoney(1, "USD")
+	contentions := []struct {
+		name     string
+		accounts int
+	}{
+		{"Low", 1024},
+		{"High", 1},
+	}
+	for _, contention := range contentions {
+		b.Run(contention.name+"/Pessimistic", func(b *testing.B) {
+			accounts := make([]*mutexAccount, contention.accounts)
+			for i := range accounts {
+				accounts[i] = &mutexAccount{balance: initial}
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					transact(accounts[rand.N(contention.accounts)], cent)
+				}
+			})
+		})
+		b.Run(contention.name+"/Optimistic", func(b *testing.B) {
+			accounts := make([]*account.OptimisticAccount, contention.accounts)
+			for i := range accounts {
+				accounts[i] = account.NewOptimisticAccount(initial)
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					transact(accounts[rand.N(contention.accounts)], cent)
+				}
+			})
+			var conflicts int64
+			for _, a := range accounts {
+				conflicts += a.Conflicts()
+			}
+			b.ReportMetric(float64(conflicts)/float64(b.N), "conflicts/op")
+		})
+	}
+}
+
+// transact deposits or withdraws amount, at random
+func transact(a bankAccount, amount account.Money) {
+	if rand.N(2) == 0 {
+		a.Deposit(amount)
+	} else {
+		a.Withdraw(amount)
+	}
+}
@@ -1,91 +1,184 @@
 // RACE CONDITIONS
 // A race condition occurs when multiple goroutines access shared resources concurrently
 // To detect race conditions, we can use the -race flag when running the program:
-// go run -race main.go
+// go run -race .
+// The account lives in its own package, pkg/account, whose tests hammer it from many
+// goroutines the same way:
+// go test -race . ../../pkg/account
+// To compare the Mutex, RWMutex, atomic and optimistic versions of the account,
+// see benchmark_test.go:
+// go test -run x -bench . -cpu 1,8
+// To run a stress test checking the final balance, see stress.go:
+// go run -race . --stress --goroutines=16 --ops=10000 --read-ratio=0.9
+// To serve the HTTP API of a bank, see server.go:
+// go run . --serve=localhost:8080
+// curl -d '{"initial":"100.00 USD"}' localhost:8080/accounts
+// To keep the bank of the demo between runs, see pkg/account/snapshot.go:
+// go run . --restore=bank.json
 
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
 	"sync"
-)
-
-// balance represents our shared resource that multiple goroutines will access
-var balance int = 100
-
-// Deposit adds the given amount to the balance
-// It takes a WaitGroup to coordinate goroutines and a RWMutex to prevent race conditions
-// Only one goroutine can modify the balance at a time using Lock() for write operations
-func Deposit(amount int, wg *sync.WaitGroup, mux *sync.RWMutex) {
-	// Notify the WaitGroup that this goroutine is done when the function returns
-	defer wg.Done()
-	// Lock the mutex to ensure exclusive access to the balance
-	// Lock() is used for write operations, blocking all other read and write operations
-	mux.Lock()
-	balance += amount
-	// Unlock the mutex to allow other goroutines to access the balance
-	mux.Unlock()
-}
+	"time"
 
-// Withdraw subtracts the given amount from the balance
-// It takes a WaitGroup to coordinate goroutines and a RWMutex to prevent race conditions
-// Similar to Deposit, it requires exclusive write access using Lock()
-func Withdraw(amount int, wg *sync.WaitGroup, mux *sync.RWMutex) {
-	defer wg.Done()
-	// Lock() acquires exclusive write access, preventing any concurrent reads or writes
-	mux.Lock()
-	balance -= amount
-	mux.Unlock()
-}
+	"github.com/Arcanm/go_advanced_course/pkg/account"
+)
 
-// Balance returns the current balance
-// It uses RLock() (Read Lock) since it only needs read access
-// Multiple goroutines can read the balance simultaneously using RLock()
-// However, if any goroutine has a write lock, read operations will be blocked
-func Balance(mux *sync.RWMutex) int {
-	// RLock() allows multiple readers to access the balance concurrently
-	mux.RLock()
-	b := balance
-	// RUnlock() releases the read lock
-	mux.RUnlock()
-	return b
-}
+var serve = flag.String("serve", "", "address to serve the HTTP API of a bank on instead of the demo")
+var restore = flag.String("restore", "", "file the bank of the demo is restored from on start and saved to on exit")
 
 func main() {
+	flag.Parse()
+	if *stress {
+		runStress()
+		return
+	}
+	if *serve != "" {
+		log.Printf("Serving the bank API on %s", *serve)
+		log.Fatal(http.ListenAndServe(*serve, NewBankServer(account.NewBank(16))))
+	}
+
+	start := time.Now()
 	// Create a WaitGroup to wait for all goroutines to complete
 	var wg sync.WaitGroup
-	// Create a RWMutex (Read-Write Mutex) to prevent race conditions
-	// RWMutex allows multiple readers but only one writer at a time
-	// This is more efficient than a regular Mutex when we have more read operations than write operations
-	var mux sync.RWMutex
+	// Amounts are Money, an exact number of cents, never a float64, see pkg/account/money.go
+	dollars := func(amount int) account.Money { return account.NewMoney(int64(amount)*100, "USD") }
+	// The account is our shared resource that multiple goroutines will access
+	// It carries its own RWMutex, see pkg/account/account.go, so the goroutines only need the account
+	checking := account.NewAccount(dollars(100))
 
-	// Print initial balance using the read-only Balance function
-	fmt.Printf("Initial balance: %d\n", Balance(&mux))
+	// Print initial balance using the read-only Balance method
+	fmt.Printf("Initial balance: %s\n", checking.Balance())
 
 	// Launch 5 goroutines that deposit increasing amounts
 	// Each goroutine will need exclusive write access using Lock()
 	for index := 1; index <= 5; index++ {
 		wg.Add(1) // Increment WaitGroup counter
-		go Deposit(index*100, &wg, &mux)
+		go func() {
+			// Notify the WaitGroup that this goroutine is done when the function returns
+			defer wg.Done()
+			checking.Deposit(dollars(index * 100))
+		}()
 	}
 
 	// Launch 2 more goroutines with fixed deposit amounts
 	// These also require exclusive write access
-	wg.Add(2)
-	go Deposit(100, &wg, &mux)
-	go Deposit(200, &wg, &mux)
+	for _, amount := range []int{100, 200} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checking.Deposit(dollars(amount))
+		}()
+	}
 
 	// Launch 3 withdrawal goroutines
 	// Each withdrawal needs exclusive write access to modify the balance
-	wg.Add(3)
-	go Withdraw(300, &wg, &mux)
-	go Withdraw(200, &wg, &mux)
-	go Withdraw(100, &wg, &mux)
+	for _, amount := range []int{300, 200, 100} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// A withdrawal scheduled before the deposits may find the balance too low
+			if err := checking.Withdraw(dollars(amount)); err != nil {
+				fmt.Printf("Withdraw %d: %v\n", amount, err)
+			}
+		}()
+	}
 
-	// Add a small delay to see intermediate balance
-	// This demonstrates that we can read the balance while operations are in progress
 	// Wait for all goroutines to complete their operations
 	wg.Wait()
-	// Print the final balance using the read-only Balance function
-	fmt.Printf("Final balance: %d\n", Balance(&mux))
+	// Print the final balance using the read-only Balance method
+	fmt.Printf("Final balance: %s\n", checking.Balance())
+
+	// Money of another currency is refused instead of silently added as dollars
+	euros, _ := account.ParseMoney("10.50 EUR")
+	fmt.Printf("Deposit %s: %v\n", euros, checking.Deposit(euros))
+
+	// Transfer locks both accounts, always in the same order, see pkg/account/account.go
+	savings := account.NewAccount(dollars(0))
+	// The owner of the savings gets an SMS for each operation, see pkg/account/events.go
+	savings.Register(account.NewSmsClient("+15550001111", "+15550000000", nil))
+	if err := account.Transfer(checking, savings, dollars(500)); err != nil {
+		fmt.Println("Transfer:", err)
+	}
+	fmt.Printf("After a transfer of 500: balance %s, savings %s\n", checking.Balance(), savings.Balance())
+
+	// Every change of the balance is in the history, in the order the lock was taken
+	fmt.Println("History:")
+	for _, operation := range checking.History() {
+		fmt.Println(" ", operation)
+	}
+
+	// Series replays the balance from the history, see pkg/account/series.go: one bar per 100 dollars
+	fmt.Println("Balance over time:")
+	for _, point := range checking.Series(start, time.Now()) {
+		fmt.Printf("  %s %-20s %s\n", point.Time.Format("15:04:05.000000"),
+			strings.Repeat("#", int(point.Balance.Units/10_000)), point.Balance)
+	}
+
+	// A bank of many accounts: random transfers between them move money around,
+	// but the total of the bank never changes
+	// With --restore, the accounts and their histories come from the previous run
+	bank := account.NewBank(16)
+	if *restore != "" {
+		restored, err := bank.Load(*restore)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf("No snapshot in %s yet, opening new accounts\n", *restore)
+		case err != nil:
+			fmt.Println("Restoring the bank:", err)
+		default:
+			fmt.Printf("Restored %d accounts from %s\n", restored, *restore)
+		}
+	}
+	var ids []uint64
+	for _, opened := range bank.Accounts() {
+		ids = append(ids, opened.ID())
+	}
+	for len(ids) < 10 {
+		ids = append(ids, bank.CreateAccount(dollars(1000)).ID())
+	}
+	for index := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bank.Transfer(ids[index%len(ids)], ids[(index*7+3)%len(ids)], dollars(index))
+		}()
+	}
+	wg.Wait()
+	totals, _ := bank.Totals()
+	fmt.Printf("Bank of %d accounts after 100 transfers: total %s\n", len(ids), totals["USD"])
+	if *restore != "" {
+		if err := bank.Save(*restore); err != nil {
+			fmt.Println("Saving the bank:", err)
+		} else {
+			fmt.Printf("Saved %d accounts to %s\n", len(ids), *restore)
+		}
+	}
+
+	// WithdrawWait blocks on a sync.Cond until a deposit covers the amount, see pkg/account/wait.go
+	wallet := account.NewAccount(dollars(0))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		wallet.Deposit(dollars(300))
+	}()
+	start = time.Now()
+	err := wallet.WithdrawWait(dollars(250), time.Second)
+	fmt.Printf("WithdrawWait of 250 on an empty wallet: %v after %s, balance %s\n",
+		err, time.Since(start).Round(10*time.Millisecond), wallet.Balance())
+
+	// A transaction applies all its steps or none, see pkg/account/tx.go: the second withdrawal
+	// can't be covered, so the transfer before it doesn't happen either
+	tx := wallet.Begin()
+	tx.Transfer(savings, dollars(30))
+	tx.Withdraw(dollars(30))
+	fmt.Printf("Transaction of 60 from a wallet of 50: %v, wallet %s, savings %s\n",
+		tx.Commit(), wallet.Balance(), savings.Balance())
 }
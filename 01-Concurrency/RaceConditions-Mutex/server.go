@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Arcanm/go_advanced_course/pkg/account"
+	"github.com/Arcanm/go_advanced_course/pkg/httpjson"
+)
+
+// The HTTP API of a Bank. net/http serves every request in its own goroutine, so the
+// handlers run concurrently like the goroutines of main: the accounts need no extra
+// locking here, their own mutexes already serialize the changes of each balance
+// Routes, amounts are strings like "12.34 USD":
+//   POST /accounts                {"initial": "100.00 USD"} opens an account
+//   GET  /accounts/{id}           returns the balance
+//   POST /accounts/{id}/deposit   {"amount": "10.00 USD"}
+//   POST /accounts/{id}/withdraw  {"amount": "10.00 USD"}
+//   POST /transfers               {"from": 1, "to": 2, "amount": "10.00 USD"}
+// The routes use the method and wildcard patterns of ServeMux, available since Go 1.22
+
+// accountResponse is an account as returned by the API
+type accountResponse struct {
+	ID      uint64        `json:"id"`
+	Balance account.Money `json:"balance"`
+}
+
+// amountRequest is the body of the requests changing the balance of an account
+type amountRequest struct {
+	Initial account.Money `json:"initial"`
+	Amount  account.Money `json:"amount"`
+}
+
+// transferRequest is the body of POST /transfers
+type transferRequest struct {
+	From   uint64        `json:"from"`
+	To     uint64        `json:"to"`
+	Amount account.Money `json:"amount"`
+}
+
+// NewBankServer returns the API of bank with its routes
+func NewBankServer(bank *account.Bank) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /accounts", func(w http.ResponseWriter, r *http.Request) {
+		var req amountRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		if req.Initial.IsNegative() || req.Initial.Currency == "" {
+			httpjson.Error(w, http.StatusBadRequest, fmt.Errorf("%w: the initial balance needs a currency and can't be negative", account.ErrInvalidMoney))
+			return
+		}
+		account := bank.CreateAccount(req.Initial)
+		w.Header().Set("Location", fmt.Sprintf("/accounts/%d", account.ID()))
+		httpjson.Write(w, http.StatusCreated, accountResponse{account.ID(), account.Balance()})
+	})
+	mux.HandleFunc("GET /accounts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		account, err := pathAccount(bank, r)
+		if err != nil {
+			httpjson.Error(w, errorStatuses.Of(err), err)
+			return
+		}
+		httpjson.Write(w, http.StatusOK, accountResponse{account.ID(), account.Balance()})
+	})
+	operations := map[string]func(*account.Account, account.Money) error{
+		"deposit":  (*account.Account).Deposit,
+		"withdraw": (*account.Account).Withdraw,
+	}
+	for name, operation := range operations {
+		mux.HandleFunc("POST /accounts/{id}/"+name, func(w http.ResponseWriter, r *http.Request) {
+			account, err := pathAccount(bank, r)
+			if err != nil {
+				httpjson.Error(w, errorStatuses.Of(err), err)
+				return
+			}
+			var req amountRequest
+			if !decode(w, r, &req) {
+				return
+			}
+			if err := operation(account, req.Amount); err != nil {
+				httpjson.Error(w, errorStatuses.Of(err), err)
+				return
+			}
+			httpjson.Write(w, http.StatusOK, accountResponse{account.ID(), account.Balance()})
+		})
+	}
+	mux.HandleFunc("POST /transfers", func(w http.ResponseWriter, r *http.Request) {
+		var req transferRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		if err := bank.Transfer(req.From, req.To, req.Amount); err != nil {
+			httpjson.Error(w, errorStatuses.Of(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// pathAccount returns the account of the {id} of the route
+func pathAccount(bank *account.Bank, r *http.Request) (*account.Account, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, account.ErrUnknownAccount
+	}
+	return bank.Get(id)
+}
+
+// decode reads the JSON body of the request into v, or answers 400 and returns false
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(v); err != nil {
+		httpjson.Error(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+// errorStatuses maps the errors of the accounts to HTTP status codes
+var errorStatuses = httpjson.Statuses{
+	{Err: account.ErrUnknownAccount, Status: http.StatusNotFound},
+	{Err: account.ErrInsufficientFunds, Status: http.StatusConflict},
+	{Err: account.ErrCurrencyMismatch, Status: http.StatusBadRequest},
+	{Err: account.ErrInvalidMoney, Status: http.StatusBadRequest},
+	{Err: account.ErrMoneyOverflow, Status: http.StatusBadRequest},
+	{Err: account.ErrSameAccount, Status: http.StatusBadRequest},
+}
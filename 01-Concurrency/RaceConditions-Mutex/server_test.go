@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/account"
+)
+
+// usd returns an amount of cents, for short checks
+func usd(cents int) account.Money {
+	return account.NewMoney(int64(cents), "USD")
+}
+
+// TestBankServer opens two accounts through the API, then sends deposits, withdrawals and
+// transfers concurrently, one request per goroutine like real clients: the balances must
+// add up as if they had been sent one at a time
+func TestBankServer(t *testing.T) {
+	server := httptest.NewServer(NewBankServer(account.NewBank(16)))
+	defer server.Close()
+	call := func(path, body string, response any) (int, error) {
+		method := http.MethodPost
+		if body == "" {
+			method = http.MethodGet
+		}
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if response != nil && resp.StatusCode < 300 {
+			err = json.NewDecoder(resp.Body).Decode(response)
+		}
+		return resp.StatusCode, err
+	}
+
+	var alice, bob accountResponse
+	if status, err := call("/accounts", `{"initial": "100.00 USD"}`, &alice); status != http.StatusCreated || err != nil {
+		t.Fatalf("create returned %d, %v", status, err)
+	}
+	if status, err := call("/accounts", `{"initial": "0 USD"}`, &bob); status != http.StatusCreated || err != nil {
+		t.Fatalf("create returned %d, %v", status, err)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			call(fmt.Sprintf("/accounts/%d/deposit", alice.ID), `{"amount": "2.00 USD"}`, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			call(fmt.Sprintf("/accounts/%d/withdraw", alice.ID), `{"amount": "1.00 USD"}`, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			call("/transfers", fmt.Sprintf(`{"from": %d, "to": %d, "amount": "0.50 USD"}`, alice.ID, bob.ID), nil)
+		}()
+	}
+	wg.Wait()
+	if _, err := call(fmt.Sprintf("/accounts/%d", alice.ID), "", &alice); err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if _, err := call(fmt.Sprintf("/accounts/%d", bob.ID), "", &bob); err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if alice.Balance != usd(100_00+50*(200-100-50)) || bob.Balance != usd(50*50) {
+		t.Fatalf("balances %s and %s, want 125.00 USD and 25.00 USD", alice.Balance, bob.Balance)
+	}
+
+	errorCases := []struct {
+		path, body string
+		want       int
+	}{
+		{fmt.Sprintf("/accounts/%d/withdraw", bob.ID), `{"amount": "1000.00 USD"}`, http.StatusConflict},
+		{fmt.Sprintf("/accounts/%d/deposit", bob.ID), `{"amount": "-5.00 USD"}`, http.StatusBadRequest},
+		{fmt.Sprintf("/accounts/%d/deposit", bob.ID), `{"amount": "5.00 EUR"}`, http.StatusBadRequest},
+		{fmt.Sprintf("/accounts/%d/deposit", bob.ID), `{"amount": "5 dollars"}`, http.StatusBadRequest},
+		{"/accounts/999999/deposit", `{"amount": "5.00 USD"}`, http.StatusNotFound},
+		{"/accounts/abc", "", http.StatusNotFound},
+	}
+	for _, c := range errorCases {
+		if status, err := call(c.path, c.body, nil); status != c.want || err != nil {
+			t.Errorf("%s %s returned %d, %v, want %d", c.path, c.body, status, err, c.want)
+		}
+	}
+}
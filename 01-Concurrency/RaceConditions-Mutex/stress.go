@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/account"
+)
+
+// The stress mode turns the demo into a check of the locking: many goroutines run a mix
+// of reads, deposits and withdrawals on one account, and the final balance must be exactly
+// the one computed without any concurrency. Every goroutine draws its operations from its
+// own seeded generator, so the expected balance is known before the run; a lost update
+// shows as a difference. Run it with -race to also catch the unprotected accesses
+
+// Flags of --stress
+var (
+	stress          = flag.Bool("stress", false, "run a stress test of the account instead of the demo, see stress.go")
+	stressProcs     = flag.Int("goroutines", 8, "stress: goroutines sharing the account")
+	stressOps       = flag.Int("ops", 100_000, "stress: operations of each goroutine")
+	stressReadRatio = flag.Float64("read-ratio", 0.5, "stress: share of Balance among the operations, the rest are deposits and withdrawals")
+)
+
+// stressOperations draws the operations of goroutine g, the same ones for the same g, as
+// amounts of cents: zero for a read, positive for a deposit, negative for a withdrawal
+func stressOperations(g, ops int, readRatio float64) []int64 {
+	r := rand.New(rand.NewPCG(uint64(g), 1805))
+	operations := make([]int64, ops)
+	for i := range operations {
+		if r.Float64() < readRatio {
+			continue
+		}
+		amount := 1 + r.Int64N(100)
+		if r.IntN(2) == 0 {
+			amount = -amount
+		}
+		operations[i] = amount
+	}
+	return operations
+}
+
+// runStress runs the stress test of the flags and prints the result; it exits with
+// status 1 if the final balance is wrong
+func runStress() {
+	goroutines, ops := max(*stressProcs, 1), max(*stressOps, 0)
+	readRatio := min(max(*stressReadRatio, 0), 1)
+
+	// The expected balance, and an initial balance covering all the withdrawals so none
+	// of them can fail whatever the order: the result doesn't depend on the scheduling
+	plans := make([][]int64, goroutines)
+	var withdrawn, net, writes int64
+	for g := range plans {
+		plans[g] = stressOperations(g, ops, readRatio)
+		for _, amount := range plans[g] {
+			if amount < 0 {
+				withdrawn -= amount
+			}
+			if amount != 0 {
+				writes++
+			}
+			net += amount
+		}
+	}
+	shared := account.NewAccount(account.NewMoney(withdrawn, "USD"))
+	want := account.NewMoney(withdrawn+net, "USD")
+
+	fmt.Printf("%d goroutines, %d operations each, %.0f%% reads\n", goroutines, ops, 100*readRatio)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, plan := range plans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, amount := range plan {
+				switch {
+				case amount == 0:
+					shared.Balance()
+				case amount > 0:
+					shared.Deposit(account.NewMoney(amount, "USD"))
+				default:
+					if err := shared.Withdraw(account.NewMoney(-amount, "USD")); err != nil {
+						fmt.Println("Withdraw:", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := goroutines * ops
+	fmt.Printf("%d operations in %s: %.0f ops/s\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	balance, history := shared.Balance(), len(shared.History())
+	if balance != want || int64(history) != writes {
+		fmt.Printf("Stress check failed: balance %s with %d operations in the history, want %s and %d\n",
+			balance, history, want, writes)
+		os.Exit(1)
+	}
+	fmt.Printf("Stress check passed: final balance %s\n", balance)
+}
@@ -19,26 +19,43 @@
 // 3. Has a BankPayment implementation with a different interface
 // 4. Uses an Adapter to make BankPayment compatible with the Payment interface
 // 5. Demonstrates how both payment types can be processed uniformly
+// 6. Adapts a third-party SDK (see sdk.go) translating both directions: our calls into
+//    SDK requests, and SDK responses and error codes into our own errors
 
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // Payment defines the standard interface for all payment methods
 type Payment interface {
-	Pay()
+	Pay() error
 }
 
+// Errors of our payment domain; adapters translate foreign errors into these
+var (
+	ErrPaymentDeclined   = errors.New("payment declined")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidPayment    = errors.New("invalid payment")
+	ErrTemporary         = errors.New("temporary payment failure, try again later")
+)
+
 // CashPayment implements the Payment interface directly
 type CashPayment struct{}
 
-func (c *CashPayment) Pay() {
+func (c *CashPayment) Pay() error {
 	fmt.Println("Paying with cash")
+	return nil
 }
 
 // ProcessPayment handles any payment method that implements the Payment interface
 func ProcessPayment(p Payment) {
-	p.Pay()
+	if err := p.Pay(); err != nil {
+		fmt.Println("Payment failed:", err)
+	}
 }
 
 // BankPayment represents a payment system with an incompatible interface
@@ -55,8 +72,68 @@ type BankPaymentAdapter struct {
 }
 
 // Pay implements the Payment interface for BankPaymentAdapter
-func (b *BankPaymentAdapter) Pay() {
+func (b *BankPaymentAdapter) Pay() error {
 	b.bankPayment.Pay(b.bankAccount)
+	return nil
+}
+
+// SDKPaymentAdapter adapts the third-party SDKClient to the Payment interface
+type SDKPaymentAdapter struct {
+	client   SDKClient
+	cents    int64  // Amount in cents, an exact integer: a float64 can't hold 0.1 exactly
+	currency string // Currency as we store it, e.g. "usd"
+	card     string // Card token
+	orderID  string // Used as idempotency key
+}
+
+// NewSDKPaymentAdapter creates an adapter for a single order payment
+func NewSDKPaymentAdapter(client SDKClient, orderID string, cents int64, currency, card string) *SDKPaymentAdapter {
+	return &SDKPaymentAdapter{client: client, cents: cents, currency: currency, card: card, orderID: orderID}
+}
+
+// Pay implements the Payment interface for SDKPaymentAdapter
+func (a *SDKPaymentAdapter) Pay() error {
+	if a.cents <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+
+	// Translate our call into the SDK request format
+	charge, err := a.client.CreateCharge(SDKChargeRequest{
+		AmountMinor:    a.cents,
+		CurrencyISO:    strings.ToUpper(a.currency),
+		SourceToken:    a.card,
+		IdempotencyKey: a.orderID,
+	})
+	if err != nil {
+		return translateSDKError(err)
+	}
+
+	// Translate the SDK response back into our semantics
+	if charge.Status != "succeeded" {
+		return fmt.Errorf("%w: charge %s is %s", ErrTemporary, charge.ChargeID, charge.Status)
+	}
+	fmt.Printf("Paying %d.%02d %s with card, charge %s\n", a.cents/100, a.cents%100, a.currency, charge.ChargeID)
+	return nil
+}
+
+// translateSDKError maps the provider error codes to our domain errors,
+// keeping the original error in the chain for logging
+func translateSDKError(err error) error {
+	var sdkErr *SDKError
+	if !errors.As(err, &sdkErr) {
+		return fmt.Errorf("%w: %w", ErrTemporary, err)
+	}
+
+	switch sdkErr.Code {
+	case SDKCodeCardDeclined:
+		return fmt.Errorf("%w: %w", ErrPaymentDeclined, err)
+	case SDKCodeInsufficientFunds:
+		return fmt.Errorf("%w: %w", ErrInsufficientFunds, err)
+	case SDKCodeUnsupportedCurr:
+		return fmt.Errorf("%w: %w", ErrInvalidPayment, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrTemporary, err)
+	}
 }
 
 func main() {
@@ -70,4 +147,14 @@ func main() {
 		bankAccount: 5,
 	}
 	ProcessPayment(bankAdapter)
+
+	// Example of the third-party SDK used through the same interface
+	sdk := &FakeSDKClient{}
+	ProcessPayment(NewSDKPaymentAdapter(sdk, "order-10", 4999, "usd", "tok_visa"))
+	ProcessPayment(NewSDKPaymentAdapter(sdk, "order-11", 4999, "usd", "tok_declined"))
+	ProcessPayment(NewSDKPaymentAdapter(sdk, "order-12", 4999, "jpy", "tok_visa"))
+
+	// The SDK errors can be handled with our own error values
+	err := NewSDKPaymentAdapter(sdk, "order-13", 500, "eur", "tok_no_funds").Pay()
+	fmt.Println("Insufficient funds detected:", errors.Is(err, ErrInsufficientFunds))
 }
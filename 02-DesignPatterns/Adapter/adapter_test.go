@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestSDKPaymentAdapter checks the translation in both directions using the SDK mock
+func TestSDKPaymentAdapter(t *testing.T) {
+	// Outgoing call: 1234 cents in "usd" must become 1234 minor units in "USD"
+	t.Run("request", func(t *testing.T) {
+		mock := &MockSDKClient{Charge: &SDKCharge{ChargeID: "ch_mock", Status: "succeeded"}}
+		if err := NewSDKPaymentAdapter(mock, "order-1", 1234, "usd", "tok_visa").Pay(); err != nil {
+			t.Fatal(err)
+		}
+		req := mock.Requests[0]
+		if req.AmountMinor != 1234 || req.CurrencyISO != "USD" || req.IdempotencyKey != "order-1" {
+			t.Errorf("unexpected SDK request %+v", req)
+		}
+	})
+
+	// A negative amount is refused before reaching the SDK
+	t.Run("negative amount", func(t *testing.T) {
+		mock := &MockSDKClient{Charge: &SDKCharge{ChargeID: "ch_mock", Status: "succeeded"}}
+		if err := NewSDKPaymentAdapter(mock, "order-4", -1234, "usd", "tok_visa").Pay(); !errors.Is(err, ErrInvalidPayment) || len(mock.Requests) != 0 {
+			t.Errorf("negative amount returned %v after %d SDK requests", err, len(mock.Requests))
+		}
+	})
+
+	// Incoming errors: every SDK code must map to one of our errors
+	errorCases := []struct {
+		name   string
+		sdkErr error
+		want   error
+	}{
+		{"card declined", &SDKError{Code: SDKCodeCardDeclined}, ErrPaymentDeclined},
+		{"insufficient funds", &SDKError{Code: SDKCodeInsufficientFunds}, ErrInsufficientFunds},
+		{"unsupported currency", &SDKError{Code: SDKCodeUnsupportedCurr}, ErrInvalidPayment},
+		{"rate limited", &SDKError{Code: SDKCodeRateLimited}, ErrTemporary},
+		{"network error", errors.New("connection reset"), ErrTemporary},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &MockSDKClient{Err: c.sdkErr}
+			err := NewSDKPaymentAdapter(mock, "order-2", 1000, "usd", "tok_visa").Pay()
+			if !errors.Is(err, c.want) {
+				t.Errorf("SDK error %v translated to %v, want %v", c.sdkErr, err, c.want)
+			}
+		})
+	}
+
+	// Incoming response: a pending charge is not a completed payment
+	t.Run("pending charge", func(t *testing.T) {
+		mock := &MockSDKClient{Charge: &SDKCharge{ChargeID: "ch_mock", Status: "pending"}}
+		if err := NewSDKPaymentAdapter(mock, "order-3", 1000, "usd", "tok_visa").Pay(); !errors.Is(err, ErrTemporary) {
+			t.Errorf("pending charge translated to %v, want ErrTemporary", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// This file simulates a third-party payment SDK we can't modify.
+// Its API is intentionally different from ours: other method names,
+// amounts in minor units (cents), ISO currency codes and string error codes.
+
+// SDK error codes returned by the provider
+const (
+	SDKCodeCardDeclined      = "card_declined"
+	SDKCodeInsufficientFunds = "insufficient_funds"
+	SDKCodeUnsupportedCurr   = "currency_not_supported"
+	SDKCodeRateLimited       = "rate_limited"
+)
+
+// SDKChargeRequest is the request format expected by the provider
+type SDKChargeRequest struct {
+	AmountMinor    int64  // Amount in cents
+	CurrencyISO    string // Upper case ISO 4217 code, e.g. "USD"
+	SourceToken    string // Tokenized card
+	IdempotencyKey string // Retries with the same key are not charged twice
+}
+
+// SDKCharge is the provider's answer to a successful charge
+type SDKCharge struct {
+	ChargeID string
+	Status   string // "succeeded" or "pending"
+}
+
+// SDKError is the provider's error type
+type SDKError struct {
+	Code    string
+	Message string
+}
+
+func (e *SDKError) Error() string {
+	return fmt.Sprintf("paysdk: %s: %s", e.Code, e.Message)
+}
+
+// SDKClient is the interface of the provider client; the adapter depends on it
+// so the real client can be replaced by MockSDKClient
+type SDKClient interface {
+	CreateCharge(req SDKChargeRequest) (*SDKCharge, error)
+}
+
+// FakeSDKClient behaves like the real provider: it supports a few currencies
+// and declines some test tokens
+type FakeSDKClient struct {
+	nextID int
+	mux    sync.Mutex
+}
+
+func (c *FakeSDKClient) CreateCharge(req SDKChargeRequest) (*SDKCharge, error) {
+	switch {
+	case req.CurrencyISO != "USD" && req.CurrencyISO != "EUR" && req.CurrencyISO != "MXN":
+		return nil, &SDKError{Code: SDKCodeUnsupportedCurr, Message: req.CurrencyISO + " is not supported"}
+	case req.SourceToken == "tok_declined":
+		return nil, &SDKError{Code: SDKCodeCardDeclined, Message: "the card was declined"}
+	case req.SourceToken == "tok_no_funds":
+		return nil, &SDKError{Code: SDKCodeInsufficientFunds, Message: "the card has insufficient funds"}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.nextID++
+	return &SDKCharge{ChargeID: fmt.Sprintf("ch_%04d", c.nextID), Status: "succeeded"}, nil
+}
+
+// MockSDKClient returns scripted answers and records the requests it receives,
+// so the adapter translation can be checked without the provider
+type MockSDKClient struct {
+	Charge   *SDKCharge
+	Err      error
+	Requests []SDKChargeRequest
+}
+
+func (m *MockSDKClient) CreateCharge(req SDKChargeRequest) (*SDKCharge, error) {
+	m.Requests = append(m.Requests, req)
+	return m.Charge, m.Err
+}
@@ -0,0 +1,383 @@
+// CQRS (Command Query Responsibility Segregation) is an architectural pattern that separates
+// the operations that change state (commands) from the operations that read state (queries).
+// Each side gets its own model: the write model enforces the business rules, while the read
+// models are denormalized views shaped exactly like the screens or reports that need them.
+//
+// Key benefits of CQRS:
+// - Business rules live in one place (the command side) and are not mixed with reporting code
+// - Read models can be optimized for each query without affecting the write model
+// - Both sides can scale and evolve independently
+// - Events connecting both sides document everything that happened in the system
+//
+// Common use cases:
+// - Systems with many more reads than writes
+// - Screens that need data aggregated from several entities
+// - Combined with Event Sourcing, where events are the source of truth
+//
+// In this example, we implement a small order service where:
+// 1. Commands (PlaceOrder, ShipOrder, CancelOrder) are validated by a CommandHandler
+// 2. The CommandHandler mutates the write model and publishes events
+// 3. An in-memory EventBus delivers the events asynchronously to the query side
+// 4. Projections keep two denormalized read models: order summaries and per-customer totals
+// 5. The QueryService only reads from the read models, never from the write model
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Errors returned by the command side when validation fails
+var (
+	ErrInvalidCommand = errors.New("invalid command")
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrInvalidState   = errors.New("operation not allowed in the current order state")
+)
+
+// OrderStatus describes the lifecycle of an order
+type OrderStatus string
+
+const (
+	StatusPlaced    OrderStatus = "placed"
+	StatusShipped   OrderStatus = "shipped"
+	StatusCancelled OrderStatus = "cancelled"
+)
+
+// ---------------------------------------------------------------------------
+// Events: the contract between the command side and the query side
+// ---------------------------------------------------------------------------
+
+// Event is anything that happened in the write model
+type Event interface {
+	OrderID() string
+}
+
+// OrderLine is a product and quantity inside an order
+type OrderLine struct {
+	Product  string
+	Quantity int
+	Price    int
+}
+
+// OrderPlaced is published when a new order is accepted
+type OrderPlaced struct {
+	ID       string
+	Customer string
+	Lines    []OrderLine
+	Total    int
+}
+
+// OrderShipped is published when an order leaves the warehouse
+type OrderShipped struct {
+	ID string
+}
+
+// OrderCancelled is published when an order is cancelled
+type OrderCancelled struct {
+	ID     string
+	Reason string
+}
+
+func (e OrderPlaced) OrderID() string    { return e.ID }
+func (e OrderShipped) OrderID() string   { return e.ID }
+func (e OrderCancelled) OrderID() string { return e.ID }
+
+// ---------------------------------------------------------------------------
+// Event bus: connects both sides
+// ---------------------------------------------------------------------------
+
+// EventHandler processes an event published on the bus
+type EventHandler func(Event)
+
+// EventBus delivers events asynchronously, in publication order, to every subscriber
+type EventBus struct {
+	events   chan Event
+	handlers []EventHandler
+	pending  sync.WaitGroup
+	mux      sync.RWMutex
+}
+
+// NewEventBus creates a bus and starts its dispatcher goroutine
+func NewEventBus() *EventBus {
+	bus := &EventBus{events: make(chan Event, 100)}
+	go bus.dispatch()
+	return bus
+}
+
+// Subscribe registers a handler that will receive every published event
+func (b *EventBus) Subscribe(handler EventHandler) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish queues an event; it returns before the handlers run
+func (b *EventBus) Publish(event Event) {
+	b.pending.Add(1)
+	b.events <- event
+}
+
+// Flush blocks until every published event has been handled.
+// The read models are eventually consistent, Flush lets the demo wait for them.
+func (b *EventBus) Flush() {
+	b.pending.Wait()
+}
+
+// Close stops the dispatcher after the queued events have been delivered
+func (b *EventBus) Close() {
+	b.Flush()
+	close(b.events)
+}
+
+// dispatch delivers the queued events to all subscribers
+func (b *EventBus) dispatch() {
+	for event := range b.events {
+		b.mux.RLock()
+		for _, handler := range b.handlers {
+			handler(event)
+		}
+		b.mux.RUnlock()
+		b.pending.Done()
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Command side: validated mutations over the write model
+// ---------------------------------------------------------------------------
+
+// PlaceOrder asks to create a new order
+type PlaceOrder struct {
+	Customer string
+	Lines    []OrderLine
+}
+
+// ShipOrder asks to ship an existing order
+type ShipOrder struct {
+	ID string
+}
+
+// CancelOrder asks to cancel an order that was not shipped yet
+type CancelOrder struct {
+	ID     string
+	Reason string
+}
+
+// order is the write model; it is never exposed to the query side
+type order struct {
+	id     string
+	status OrderStatus
+}
+
+// CommandHandler validates commands, mutates the write model and publishes events
+type CommandHandler struct {
+	orders map[string]*order
+	nextID int
+	bus    *EventBus
+	mux    sync.Mutex
+}
+
+// NewCommandHandler creates the command side publishing on the given bus
+func NewCommandHandler(bus *EventBus) *CommandHandler {
+	return &CommandHandler{
+		orders: make(map[string]*order),
+		nextID: 1,
+		bus:    bus,
+	}
+}
+
+// PlaceOrder validates and creates an order, returning its ID
+func (h *CommandHandler) PlaceOrder(cmd PlaceOrder) (string, error) {
+	if cmd.Customer == "" {
+		return "", fmt.Errorf("%w: customer is required", ErrInvalidCommand)
+	}
+	if len(cmd.Lines) == 0 {
+		return "", fmt.Errorf("%w: an order needs at least one line", ErrInvalidCommand)
+	}
+
+	total := 0
+	for _, line := range cmd.Lines {
+		if line.Quantity <= 0 || line.Price < 0 {
+			return "", fmt.Errorf("%w: invalid line for %q", ErrInvalidCommand, line.Product)
+		}
+		total += line.Quantity * line.Price
+	}
+
+	h.mux.Lock()
+	id := fmt.Sprintf("order-%d", h.nextID)
+	h.nextID++
+	h.orders[id] = &order{id: id, status: StatusPlaced}
+	h.mux.Unlock()
+
+	h.bus.Publish(OrderPlaced{ID: id, Customer: cmd.Customer, Lines: cmd.Lines, Total: total})
+	return id, nil
+}
+
+// ShipOrder marks a placed order as shipped
+func (h *CommandHandler) ShipOrder(cmd ShipOrder) error {
+	if err := h.transition(cmd.ID, StatusShipped); err != nil {
+		return err
+	}
+	h.bus.Publish(OrderShipped{ID: cmd.ID})
+	return nil
+}
+
+// CancelOrder cancels a placed order
+func (h *CommandHandler) CancelOrder(cmd CancelOrder) error {
+	if cmd.Reason == "" {
+		return fmt.Errorf("%w: a cancellation reason is required", ErrInvalidCommand)
+	}
+	if err := h.transition(cmd.ID, StatusCancelled); err != nil {
+		return err
+	}
+	h.bus.Publish(OrderCancelled{ID: cmd.ID, Reason: cmd.Reason})
+	return nil
+}
+
+// transition moves a placed order to a new status, enforcing the business rules
+func (h *CommandHandler) transition(id string, to OrderStatus) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	o, exists := h.orders[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+	}
+	if o.status != StatusPlaced {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, id, o.status)
+	}
+	o.status = to
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Query side: denormalized read models updated from events
+// ---------------------------------------------------------------------------
+
+// OrderSummary is a read model row shaped for an "order list" screen
+type OrderSummary struct {
+	ID        string
+	Customer  string
+	ItemCount int
+	Total     int
+	Status    OrderStatus
+}
+
+// CustomerStats is a read model row shaped for a "customer report" screen
+type CustomerStats struct {
+	Customer   string
+	Orders     int
+	TotalSpent int
+}
+
+// QueryService owns the read models and answers queries from them
+type QueryService struct {
+	summaries map[string]OrderSummary
+	customers map[string]CustomerStats
+	mux       sync.RWMutex
+}
+
+// NewQueryService creates the query side and subscribes its projection to the bus
+func NewQueryService(bus *EventBus) *QueryService {
+	q := &QueryService{
+		summaries: make(map[string]OrderSummary),
+		customers: make(map[string]CustomerStats),
+	}
+	bus.Subscribe(q.project)
+	return q
+}
+
+// project updates the read models from an event
+func (q *QueryService) project(event Event) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	switch e := event.(type) {
+	case OrderPlaced:
+		items := 0
+		for _, line := range e.Lines {
+			items += line.Quantity
+		}
+		q.summaries[e.ID] = OrderSummary{
+			ID:        e.ID,
+			Customer:  e.Customer,
+			ItemCount: items,
+			Total:     e.Total,
+			Status:    StatusPlaced,
+		}
+		stats := q.customers[e.Customer]
+		stats.Customer = e.Customer
+		stats.Orders++
+		stats.TotalSpent += e.Total
+		q.customers[e.Customer] = stats
+	case OrderShipped:
+		summary := q.summaries[e.ID]
+		summary.Status = StatusShipped
+		q.summaries[e.ID] = summary
+	case OrderCancelled:
+		summary := q.summaries[e.ID]
+		summary.Status = StatusCancelled
+		q.summaries[e.ID] = summary
+		// Cancelled orders no longer count towards the customer totals
+		stats := q.customers[summary.Customer]
+		stats.Orders--
+		stats.TotalSpent -= summary.Total
+		q.customers[summary.Customer] = stats
+	}
+}
+
+// GetOrderSummary returns the summary of an order
+func (q *QueryService) GetOrderSummary(id string) (OrderSummary, bool) {
+	q.mux.RLock()
+	defer q.mux.RUnlock()
+	summary, exists := q.summaries[id]
+	return summary, exists
+}
+
+// GetCustomerStats returns the aggregated stats of a customer
+func (q *QueryService) GetCustomerStats(customer string) CustomerStats {
+	q.mux.RLock()
+	defer q.mux.RUnlock()
+	return q.customers[customer]
+}
+
+func main() {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	commands := NewCommandHandler(bus)
+	queries := NewQueryService(bus)
+
+	// Command side: every mutation goes through validation
+	first, _ := commands.PlaceOrder(PlaceOrder{
+		Customer: "test@test.com",
+		Lines:    []OrderLine{{Product: "Laptop", Quantity: 1, Price: 1200}, {Product: "Mouse", Quantity: 2, Price: 25}},
+	})
+	second, _ := commands.PlaceOrder(PlaceOrder{
+		Customer: "test@test.com",
+		Lines:    []OrderLine{{Product: "Desktop", Quantity: 1, Price: 900}},
+	})
+	commands.ShipOrder(ShipOrder{ID: first})
+	commands.CancelOrder(CancelOrder{ID: second, Reason: "changed my mind"})
+
+	// Invalid commands are rejected and never reach the read models
+	if _, err := commands.PlaceOrder(PlaceOrder{Customer: "test@test.com"}); err != nil {
+		fmt.Println("Rejected:", err)
+	}
+	if err := commands.CancelOrder(CancelOrder{ID: first, Reason: "too late"}); err != nil {
+		fmt.Println("Rejected:", err)
+	}
+
+	// Read models are eventually consistent: wait for the projection to catch up
+	bus.Flush()
+
+	// Query side: reads come from the denormalized views only
+	for _, id := range []string{first, second} {
+		summary, _ := queries.GetOrderSummary(id)
+		fmt.Printf("%s: customer=%s items=%d total=%d status=%s\n",
+			summary.ID, summary.Customer, summary.ItemCount, summary.Total, summary.Status)
+	}
+	stats := queries.GetCustomerStats("test@test.com")
+	fmt.Printf("Customer %s: %d active orders, %d spent\n", stats.Customer, stats.Orders, stats.TotalSpent)
+}
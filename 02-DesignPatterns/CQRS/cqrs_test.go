@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCommands checks the validation of the command side
+func TestCommands(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+	commands := NewCommandHandler(bus)
+	placed, err := commands.PlaceOrder(PlaceOrder{Customer: "ana@test.com", Lines: []OrderLine{{Product: "Mouse", Quantity: 1, Price: 25}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipped, _ := commands.PlaceOrder(PlaceOrder{Customer: "ana@test.com", Lines: []OrderLine{{Product: "Laptop", Quantity: 1, Price: 1200}}})
+	if err := commands.ShipOrder(ShipOrder{ID: shipped}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		run     func() error
+		wantErr error
+	}{
+		{
+			name: "order without customer",
+			run: func() error {
+				_, err := commands.PlaceOrder(PlaceOrder{Lines: []OrderLine{{Product: "Mouse", Quantity: 1}}})
+				return err
+			},
+			wantErr: ErrInvalidCommand,
+		},
+		{
+			name: "order without lines",
+			run: func() error {
+				_, err := commands.PlaceOrder(PlaceOrder{Customer: "ana@test.com"})
+				return err
+			},
+			wantErr: ErrInvalidCommand,
+		},
+		{
+			name: "line with zero quantity",
+			run: func() error {
+				_, err := commands.PlaceOrder(PlaceOrder{Customer: "ana@test.com", Lines: []OrderLine{{Product: "Mouse"}}})
+				return err
+			},
+			wantErr: ErrInvalidCommand,
+		},
+		{
+			name:    "ship an unknown order",
+			run:     func() error { return commands.ShipOrder(ShipOrder{ID: "order-99"}) },
+			wantErr: ErrOrderNotFound,
+		},
+		{
+			name:    "cancel without reason",
+			run:     func() error { return commands.CancelOrder(CancelOrder{ID: placed}) },
+			wantErr: ErrInvalidCommand,
+		},
+		{
+			name:    "cancel a shipped order",
+			run:     func() error { return commands.CancelOrder(CancelOrder{ID: shipped, Reason: "too late"}) },
+			wantErr: ErrInvalidState,
+		},
+		{
+			name:    "ship a shipped order",
+			run:     func() error { return commands.ShipOrder(ShipOrder{ID: shipped}) },
+			wantErr: ErrInvalidState,
+		},
+		{
+			name: "cancel a placed order",
+			run:  func() error { return commands.CancelOrder(CancelOrder{ID: placed, Reason: "changed my mind"}) },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.run()
+			if !errors.Is(err, c.wantErr) || (c.wantErr == nil && err != nil) {
+				t.Errorf("got error %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+// TestProjections checks the read models built from the events of the command side
+func TestProjections(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+	commands := NewCommandHandler(bus)
+	queries := NewQueryService(bus)
+
+	first, _ := commands.PlaceOrder(PlaceOrder{
+		Customer: "ana@test.com",
+		Lines:    []OrderLine{{Product: "Laptop", Quantity: 1, Price: 1200}, {Product: "Mouse", Quantity: 2, Price: 25}},
+	})
+	second, _ := commands.PlaceOrder(PlaceOrder{
+		Customer: "ana@test.com",
+		Lines:    []OrderLine{{Product: "Desktop", Quantity: 1, Price: 900}},
+	})
+	commands.ShipOrder(ShipOrder{ID: first})
+	commands.CancelOrder(CancelOrder{ID: second, Reason: "changed my mind"})
+	// A rejected command publishes nothing
+	commands.PlaceOrder(PlaceOrder{Customer: "ana@test.com"})
+	bus.Flush()
+
+	cases := []struct {
+		id   string
+		want OrderSummary
+	}{
+		{id: first, want: OrderSummary{ID: first, Customer: "ana@test.com", ItemCount: 3, Total: 1250, Status: StatusShipped}},
+		{id: second, want: OrderSummary{ID: second, Customer: "ana@test.com", ItemCount: 1, Total: 900, Status: StatusCancelled}},
+	}
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			got, exists := queries.GetOrderSummary(c.id)
+			if !exists || got != c.want {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+
+	// The cancelled order no longer counts towards the customer totals
+	want := CustomerStats{Customer: "ana@test.com", Orders: 1, TotalSpent: 1250}
+	if got := queries.GetCustomerStats("ana@test.com"); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, exists := queries.GetOrderSummary("order-3"); exists {
+		t.Error("the rejected order reached the read model")
+	}
+}
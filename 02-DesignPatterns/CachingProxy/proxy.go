@@ -0,0 +1,138 @@
+// This example combines two patterns seen separately in the course:
+//
+// The Proxy Pattern provides a substitute for another object that controls access to it.
+// The proxy implements the same interface as the real object, so clients can't tell them
+// apart; here the proxy adds caching in front of an expensive service.
+//
+// The Singleton Pattern (see ../Singleton) provides a single, lazily created registry of
+// proxies, so every part of the program shares the same caches.
+//
+// Key benefits of the combination:
+// - Expensive services are called once per key, no matter how many clients ask
+// - Clients keep depending on the service interface, caching is invisible to them
+// - The registry is created on first use and is safe for concurrent access
+//
+// Common use cases:
+// - Caching remote lookups (prices, exchange rates, user profiles)
+// - Sharing expensive clients (database, HTTP, gRPC) across a program
+//
+// In this example, we implement:
+// 1. A PriceService interface with a slow real implementation
+// 2. A CachingPriceProxy implementing the same interface on top of the cache of pkg/cache,
+//    the one of 01-Concurrency/Cache
+// 3. A Registry singleton created with sync.Once that lazily builds one proxy per service
+// 4. Concurrent clients showing that the real service is called once per key
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+)
+
+// PriceService returns the price of a product
+type PriceService interface {
+	Price(sku string) (int, error)
+}
+
+// RemotePriceService is the real, expensive implementation
+type RemotePriceService struct {
+	calls atomic.Int64
+}
+
+// Price simulates a slow remote call
+func (s *RemotePriceService) Price(sku string) (int, error) {
+	s.calls.Add(1)
+	time.Sleep(300 * time.Millisecond)
+	prices := map[string]int{"laptop": 1200, "desktop": 900, "tablet": 400}
+	price, exists := prices[sku]
+	if !exists {
+		return 0, fmt.Errorf("unknown sku %q", sku)
+	}
+	return price, nil
+}
+
+// CachingPriceProxy controls access to the real service by caching its answers
+// It implements PriceService, so clients use it exactly like the real one
+type CachingPriceProxy struct {
+	prices *cache.Memory[string, int]
+}
+
+// NewCachingPriceProxy wraps a PriceService with a cache.
+// Concurrent misses for the same sku wait for a single call, and failed calls are not cached.
+func NewCachingPriceProxy(service PriceService) *CachingPriceProxy {
+	load := func(sku string, _ *cache.Memory[string, int]) (int, error) {
+		return service.Price(sku)
+	}
+	return &CachingPriceProxy{prices: cache.NewCache(load)}
+}
+
+// Price returns the cached price, calling the real service only on a miss
+func (p *CachingPriceProxy) Price(sku string) (int, error) {
+	return p.prices.Get(sku)
+}
+
+// Registry holds the shared proxies; it is a singleton
+type Registry struct {
+	remote  *RemotePriceService
+	prices  PriceService
+	initOne sync.Once
+}
+
+// The only Registry instance and the Once guarding its creation
+var (
+	registry     *Registry
+	registryOnce sync.Once
+)
+
+// GetRegistry returns the single Registry, creating it on the first call
+func GetRegistry() *Registry {
+	registryOnce.Do(func() {
+		fmt.Println("Creating service registry")
+		registry = &Registry{}
+	})
+	return registry
+}
+
+// Prices returns the shared price service; the proxy itself is also created lazily
+func (r *Registry) Prices() PriceService {
+	r.initOne.Do(func() {
+		fmt.Println("Creating caching proxy for the price service")
+		r.remote = &RemotePriceService{}
+		r.prices = NewCachingPriceProxy(r.remote)
+	})
+	return r.prices
+}
+
+// RemoteCalls returns how many times the real service was called
+func (r *Registry) RemoteCalls() int64 {
+	r.Prices()
+	return r.remote.calls.Load()
+}
+
+func main() {
+	skus := []string{"laptop", "desktop", "laptop", "tablet", "laptop", "desktop", "phone", "tablet"}
+
+	// Every goroutine gets the registry and the service on its own; all of them share the same cache
+	start := time.Now()
+	var wg sync.WaitGroup
+	for round := range 3 {
+		for _, sku := range skus {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := GetRegistry().Prices().Price(sku); err != nil {
+					fmt.Printf("round %d: %v\n", round, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	fmt.Printf("%d lookups in %s, real service called %d times\n",
+		3*len(skus), time.Since(start).Round(10*time.Millisecond), GetRegistry().RemoteCalls())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingService is a PriceService counting the calls per sku
+type countingService struct {
+	calls map[string]int
+	mux   sync.Mutex
+}
+
+func (s *countingService) Price(sku string) (int, error) {
+	s.mux.Lock()
+	s.calls[sku]++
+	s.mux.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	if sku == "phone" {
+		return 0, fmt.Errorf("unknown sku %q", sku)
+	}
+	return len(sku) * 100, nil
+}
+
+func (s *countingService) count(sku string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.calls[sku]
+}
+
+// TestProxy checks that concurrent clients share one call per sku
+func TestProxy(t *testing.T) {
+	service := &countingService{calls: make(map[string]int)}
+	var prices PriceService = NewCachingPriceProxy(service)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		for _, sku := range []string{"laptop", "desktop", "tablet"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if price, err := prices.Price(sku); err != nil || price != len(sku)*100 {
+					t.Errorf("got %d, %v for %s", price, err, sku)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, sku := range []string{"laptop", "desktop", "tablet"} {
+		if got := service.count(sku); got != 1 {
+			t.Errorf("the service was called %d times for %s, want 1", got, sku)
+		}
+	}
+}
+
+// TestProxyErrors checks that failed calls reach the client and are not cached
+func TestProxyErrors(t *testing.T) {
+	service := &countingService{calls: make(map[string]int)}
+	prices := NewCachingPriceProxy(service)
+	for range 2 {
+		if _, err := prices.Price("phone"); err == nil {
+			t.Fatal("the unknown sku returned no error")
+		}
+	}
+	if got := service.count("phone"); got != 2 {
+		t.Errorf("the service was called %d times, want 2", got)
+	}
+}
+
+// TestRegistry checks that every caller gets the same registry and the same proxy
+func TestRegistry(t *testing.T) {
+	var wg sync.WaitGroup
+	registries := make([]*Registry, 10)
+	services := make([]PriceService, 10)
+	for i := range registries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registries[i] = GetRegistry()
+			services[i] = registries[i].Prices()
+		}()
+	}
+	wg.Wait()
+
+	for i := range registries {
+		if registries[i] != registries[0] || services[i] != services[0] {
+			t.Fatal("the registry or its proxy was created more than once")
+		}
+	}
+	if _, ok := services[0].(*CachingPriceProxy); !ok {
+		t.Errorf("got %T, want the caching proxy", services[0])
+	}
+}
@@ -0,0 +1,172 @@
+// This is a second Strategy Pattern example (see ../Strategy for the first one).
+// Compression algorithms are a natural family of interchangeable strategies: all of them
+// turn a stream of bytes into a smaller stream, but each one makes a different trade-off
+// between speed and compression ratio.
+//
+// In this example, we implement a file archiver that:
+// 1. Defines a CompressionStrategy interface working on io.Reader and io.Writer
+// 2. Has concrete implementations using gzip, zlib and zstd (see zstd.go)
+// 3. Uses a Compressor that delegates to the selected strategy
+// 4. Selects the strategy at runtime from a command line flag
+// 5. Benchmarks every strategy to compare output size and speed (see compression_test.go)
+// RUN PROGRAM WITH FLAGS
+// go run . --algorithm=zstd
+// go test .
+// go test -run x -bench . -benchmem
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CompressionStrategy defines the interface that all compression strategies must implement
+type CompressionStrategy interface {
+	// Compress reads everything from r and writes the compressed data to w
+	Compress(r io.Reader, w io.Writer) error
+	// Decompress reads compressed data from r and writes the original data to w
+	Decompress(r io.Reader, w io.Writer) error
+}
+
+// Compressor holds the selected compression strategy
+type Compressor struct {
+	strategy CompressionStrategy
+}
+
+// NewCompressor creates a new Compressor with the specified strategy
+func NewCompressor(strategy CompressionStrategy) *Compressor {
+	return &Compressor{strategy: strategy}
+}
+
+// SetStrategy allows changing the compression strategy at runtime
+func (c *Compressor) SetStrategy(strategy CompressionStrategy) {
+	c.strategy = strategy
+}
+
+// Compress executes the selected strategy
+func (c *Compressor) Compress(r io.Reader, w io.Writer) error {
+	return c.strategy.Compress(r, w)
+}
+
+// Decompress executes the selected strategy in reverse
+func (c *Compressor) Decompress(r io.Reader, w io.Writer) error {
+	return c.strategy.Decompress(r, w)
+}
+
+// Gzip implements the CompressionStrategy interface using compress/gzip
+type Gzip struct {
+	Level int
+}
+
+func (g *Gzip) Compress(r io.Reader, w io.Writer) error {
+	zw, err := gzip.NewWriterLevel(w, g.Level)
+	if err != nil {
+		return err
+	}
+	return copyAndClose(zw, r)
+}
+
+func (g *Gzip) Decompress(r io.Reader, w io.Writer) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(w, zr)
+	return err
+}
+
+// Zlib implements the CompressionStrategy interface using compress/zlib
+type Zlib struct {
+	Level int
+}
+
+func (z *Zlib) Compress(r io.Reader, w io.Writer) error {
+	zw, err := zlib.NewWriterLevel(w, z.Level)
+	if err != nil {
+		return err
+	}
+	return copyAndClose(zw, r)
+}
+
+func (z *Zlib) Decompress(r io.Reader, w io.Writer) error {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(w, zr)
+	return err
+}
+
+// copyAndClose compresses r into zw; Close flushes the last block so its error matters
+func copyAndClose(zw io.WriteCloser, r io.Reader) error {
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// strategies lists the strategies that can be selected by name
+var strategies = map[string]CompressionStrategy{
+	"gzip": &Gzip{Level: gzip.DefaultCompression},
+	"zlib": &Zlib{Level: zlib.DefaultCompression},
+	"zstd": &Zstd{Level: ZstdDefault},
+}
+
+// Command line flags
+var algorithm = flag.String("algorithm", "gzip", "compression algorithm: gzip, zlib or zstd")
+
+// sampleData returns repetitive text, similar to logs, that compresses well
+func sampleData() []byte {
+	var b strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&b, "2025-01-01T10:%02d:%02d INFO request served path=/products/%d status=200\n", i/60%60, i%60, i%97)
+	}
+	return []byte(b.String())
+}
+
+func main() {
+	flag.Parse()
+
+	strategy, exists := strategies[*algorithm]
+	if !exists {
+		names := make([]string, 0, len(strategies))
+		for name := range strategies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("Unknown algorithm %q, available: %s\n", *algorithm, strings.Join(names, ", "))
+		os.Exit(1)
+	}
+
+	// The strategy is chosen at runtime, the rest of the code doesn't change
+	compressor := NewCompressor(strategy)
+	data := sampleData()
+
+	var compressed bytes.Buffer
+	if err := compressor.Compress(bytes.NewReader(data), &compressed); err != nil {
+		fmt.Println("Compress error:", err)
+		os.Exit(1)
+	}
+	compressedSize := compressed.Len()
+
+	var restored bytes.Buffer
+	if err := compressor.Decompress(&compressed, &restored); err != nil {
+		fmt.Println("Decompress error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s: %d bytes -> %d bytes (%.1f%%), round trip ok: %t\n",
+		*algorithm, len(data), compressedSize, 100*float64(compressedSize)/float64(len(data)),
+		bytes.Equal(data, restored.Bytes()))
+}
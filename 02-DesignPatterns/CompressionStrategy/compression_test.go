@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+)
+
+// levels are the strategies and levels compared by the benchmark
+var levels = []struct {
+	name     string
+	strategy CompressionStrategy
+}{
+	{"Gzip/fastest", &Gzip{Level: gzip.BestSpeed}},
+	{"Gzip/default", &Gzip{Level: gzip.DefaultCompression}},
+	{"Gzip/best", &Gzip{Level: gzip.BestCompression}},
+	{"Zlib/default", &Zlib{Level: zlib.DefaultCompression}},
+	{"Zstd/fastest", &Zstd{Level: ZstdFastest}},
+	{"Zstd/default", &Zstd{Level: ZstdDefault}},
+	{"Zstd/best", &Zstd{Level: ZstdBest}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := sampleData()
+	for _, l := range levels {
+		t.Run(l.name, func(t *testing.T) {
+			compressor := NewCompressor(l.strategy)
+			var compressed, restored bytes.Buffer
+			if err := compressor.Compress(bytes.NewReader(data), &compressed); err != nil {
+				t.Fatal(err)
+			}
+			if compressed.Len() >= len(data) {
+				t.Errorf("compressed %d bytes into %d", len(data), compressed.Len())
+			}
+			if err := compressor.Decompress(&compressed, &restored); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(restored.Bytes(), data) {
+				t.Error("the decompressed data differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecompressCorrupted(t *testing.T) {
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			err := strategy.Decompress(bytes.NewReader([]byte("not compressed")), io.Discard)
+			if err == nil {
+				t.Error("got no error for data that is not compressed")
+			}
+		})
+	}
+}
+
+// BenchmarkCompress compresses the sample data with every strategy and level. Next to the
+// throughput it reports the size of the output as a percentage of the input.
+func BenchmarkCompress(b *testing.B) {
+	data := sampleData()
+	for _, l := range levels {
+		b.Run(l.name, func(b *testing.B) {
+			var out bytes.Buffer
+			if err := l.strategy.Compress(bytes.NewReader(data), &out); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := l.strategy.Compress(bytes.NewReader(data), io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(100*float64(out.Len())/float64(len(data)), "%size")
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Zstd levels exposed by the strategy
+const (
+	ZstdFastest = zstd.SpeedFastest
+	ZstdDefault = zstd.SpeedDefault
+	ZstdBest    = zstd.SpeedBestCompression
+)
+
+// Zstd implements the CompressionStrategy interface using Zstandard
+type Zstd struct {
+	Level zstd.EncoderLevel
+}
+
+func (z *Zstd) Compress(r io.Reader, w io.Writer) error {
+	zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(z.Level))
+	if err != nil {
+		return err
+	}
+	return copyAndClose(zw, r)
+}
+
+func (z *Zstd) Decompress(r io.Reader, w io.Writer) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(w, zr)
+	return err
+}
@@ -0,0 +1,284 @@
+// Dependency Injection (DI) is a technique where an object receives the objects it depends on
+// instead of creating them itself. A DI container automates this wiring: constructors are
+// registered once, and the container builds the whole object graph by matching parameter types.
+//
+// Key benefits of Dependency Injection:
+// - Removes hidden global state (compare with the Singleton example)
+// - Makes dependencies explicit in constructor signatures
+// - Allows swapping implementations without touching the code that uses them
+// - Centralizes the decision of which objects are shared and which are recreated
+//
+// Common use cases:
+// - Wiring services, repositories and clients in larger applications
+// - Replacing real implementations with fakes in tests
+// - Controlling the lifetime of expensive resources (connections, pools)
+//
+// In this example, we implement a minimal container that:
+// 1. Registers constructors (plain functions) keyed by the type they return
+// 2. Resolves a type by recursively resolving the constructor parameters
+// 3. Supports Singleton (built once, shared) and Transient (built on every resolve) lifetimes
+// 4. Detects dependency cycles and reports the full resolution path
+// 5. Wires the notification (Observer) and payment (Adapter) examples without globals
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Lifetime defines how long an instance built by the container lives
+type Lifetime int
+
+const (
+	// Transient instances are built again on every resolution
+	Transient Lifetime = iota
+	// Singleton instances are built once per container and then shared
+	Singleton
+)
+
+// errorType is used to detect constructors that return (T, error)
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// provider holds a registered constructor and its lifetime
+type provider struct {
+	constructor reflect.Value
+	lifetime    Lifetime
+}
+
+// Container keeps the registered constructors and the singleton instances
+// Every container is independent, so there is no package level state
+type Container struct {
+	providers  map[reflect.Type]*provider
+	singletons map[reflect.Type]reflect.Value
+}
+
+// NewContainer creates an empty container
+func NewContainer() *Container {
+	return &Container{
+		providers:  make(map[reflect.Type]*provider),
+		singletons: make(map[reflect.Type]reflect.Value),
+	}
+}
+
+// Register adds a constructor to the container.
+// The constructor must be a function returning T or (T, error); its parameters
+// are resolved from the container when T is requested.
+func (c *Container) Register(constructor any, lifetime Lifetime) error {
+	fn := reflect.ValueOf(constructor)
+	if fn.Kind() != reflect.Func {
+		return fmt.Errorf("constructor must be a function, got %T", constructor)
+	}
+
+	fnType := fn.Type()
+	switch {
+	case fnType.NumOut() == 1:
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	default:
+		return fmt.Errorf("constructor %s must return T or (T, error)", fnType)
+	}
+
+	out := fnType.Out(0)
+	if _, exists := c.providers[out]; exists {
+		return fmt.Errorf("a constructor for %s is already registered", out)
+	}
+	c.providers[out] = &provider{constructor: fn, lifetime: lifetime}
+	return nil
+}
+
+// Resolve builds (or returns the shared instance of) the type pointed to by target.
+// Usage: var p Payment; err := c.Resolve(&p)
+func (c *Container) Resolve(target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return errors.New("resolve target must be a non-nil pointer")
+	}
+
+	value, err := c.resolve(ptr.Elem().Type(), nil)
+	if err != nil {
+		return err
+	}
+	ptr.Elem().Set(value)
+	return nil
+}
+
+// resolve builds a value of type t. The path holds the types currently being
+// built, so finding t in it again means the graph contains a cycle.
+func (c *Container) resolve(t reflect.Type, path []reflect.Type) (reflect.Value, error) {
+	for _, inProgress := range path {
+		if inProgress == t {
+			return reflect.Value{}, fmt.Errorf("dependency cycle detected: %s", formatPath(append(path, t)))
+		}
+	}
+
+	if instance, exists := c.singletons[t]; exists {
+		return instance, nil
+	}
+
+	p, exists := c.providers[t]
+	if !exists {
+		if len(path) == 0 {
+			return reflect.Value{}, fmt.Errorf("no constructor registered for %s", t)
+		}
+		return reflect.Value{}, fmt.Errorf("no constructor registered for %s (required by %s)", t, formatPath(path))
+	}
+
+	// Resolve every parameter of the constructor before calling it
+	path = append(path, t)
+	fnType := p.constructor.Type()
+	args := make([]reflect.Value, fnType.NumIn())
+	for i := range args {
+		arg, err := c.resolve(fnType.In(i), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		args[i] = arg
+	}
+
+	results := p.constructor.Call(args)
+	if len(results) == 2 && !results[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("building %s: %w", t, results[1].Interface().(error))
+	}
+
+	if p.lifetime == Singleton {
+		c.singletons[t] = results[0]
+	}
+	return results[0], nil
+}
+
+// formatPath renders a resolution path as "A -> B -> C"
+func formatPath(path []reflect.Type) string {
+	names := make([]string, len(path))
+	for i, t := range path {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Config holds the settings shared by the services below
+type Config struct {
+	SenderEmail string
+	BankAccount int
+}
+
+// Notifier is the notification side (see the Observer example)
+type Notifier interface {
+	Notify(message string)
+}
+
+// EmailNotifier sends notifications by email
+type EmailNotifier struct {
+	from string
+}
+
+// NewEmailNotifier depends only on the configuration
+func NewEmailNotifier(cfg *Config) Notifier {
+	return &EmailNotifier{from: cfg.SenderEmail}
+}
+
+func (e *EmailNotifier) Notify(message string) {
+	fmt.Printf("Sending email from %s: %s\n", e.from, message)
+}
+
+// Payment is the payment side (see the Adapter example)
+type Payment interface {
+	Pay()
+}
+
+// BankPayment represents a payment system with an incompatible interface
+type BankPayment struct{}
+
+func (b *BankPayment) Pay(amount int) {
+	fmt.Printf("Paying %d with bank transfer\n", amount)
+}
+
+// BankPaymentAdapter adapts BankPayment to match the Payment interface
+type BankPaymentAdapter struct {
+	bankPayment *BankPayment
+	bankAccount int
+}
+
+// NewBankPaymentAdapter receives the adaptee and the configuration from the container
+func NewBankPaymentAdapter(bank *BankPayment, cfg *Config) Payment {
+	return &BankPaymentAdapter{bankPayment: bank, bankAccount: cfg.BankAccount}
+}
+
+func (b *BankPaymentAdapter) Pay() {
+	b.bankPayment.Pay(b.bankAccount)
+}
+
+// CheckoutService depends on both a Payment and a Notifier
+type CheckoutService struct {
+	payment  Payment
+	notifier Notifier
+}
+
+// NewCheckoutService declares its dependencies as parameters instead of creating them
+func NewCheckoutService(payment Payment, notifier Notifier) *CheckoutService {
+	return &CheckoutService{payment: payment, notifier: notifier}
+}
+
+// Checkout pays and notifies the customer
+func (s *CheckoutService) Checkout(item string) {
+	s.payment.Pay()
+	s.notifier.Notify(fmt.Sprintf("your order for %s was paid", item))
+}
+
+// Two types that depend on each other, used to demonstrate cycle detection
+type ServiceA struct{ b *ServiceB }
+type ServiceB struct{ a *ServiceA }
+
+func NewServiceA(b *ServiceB) *ServiceA { return &ServiceA{b: b} }
+func NewServiceB(a *ServiceA) *ServiceB { return &ServiceB{a: a} }
+
+func main() {
+	container := NewContainer()
+
+	// Register every constructor once; the order does not matter
+	registrations := []struct {
+		constructor any
+		lifetime    Lifetime
+	}{
+		{func() *Config { return &Config{SenderEmail: "shop@test.com", BankAccount: 5} }, Singleton},
+		{func() *BankPayment { return &BankPayment{} }, Singleton},
+		{NewEmailNotifier, Singleton},
+		{NewBankPaymentAdapter, Transient},
+		{NewCheckoutService, Transient},
+	}
+	for _, r := range registrations {
+		if err := container.Register(r.constructor, r.lifetime); err != nil {
+			fmt.Println("Register error:", err)
+			return
+		}
+	}
+
+	// The container builds Config, BankPayment, the adapter and the notifier for us
+	var checkout *CheckoutService
+	if err := container.Resolve(&checkout); err != nil {
+		fmt.Println("Resolve error:", err)
+		return
+	}
+	checkout.Checkout("RTX 5090")
+
+	// Singletons are shared, transients are rebuilt on every resolution
+	var n1, n2 Notifier
+	container.Resolve(&n1)
+	container.Resolve(&n2)
+	fmt.Printf("Notifier is shared (singleton): %t\n", n1 == n2)
+
+	var p1, p2 Payment
+	container.Resolve(&p1)
+	container.Resolve(&p2)
+	fmt.Printf("Payment is shared (transient): %t\n", p1 == p2)
+
+	// A cycle is reported instead of overflowing the stack
+	cyclic := NewContainer()
+	cyclic.Register(NewServiceA, Singleton)
+	cyclic.Register(NewServiceB, Singleton)
+	var a *ServiceA
+	if err := cyclic.Resolve(&a); err != nil {
+		fmt.Println("Resolve error:", err)
+	}
+}
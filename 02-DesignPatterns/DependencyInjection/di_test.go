@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestContainer registers the services of main
+func newTestContainer(t *testing.T) *Container {
+	t.Helper()
+	container := NewContainer()
+	registrations := []struct {
+		constructor any
+		lifetime    Lifetime
+	}{
+		{func() *Config { return &Config{SenderEmail: "shop@test.com", BankAccount: 5} }, Singleton},
+		{func() *BankPayment { return &BankPayment{} }, Singleton},
+		{NewEmailNotifier, Singleton},
+		{NewBankPaymentAdapter, Transient},
+		{NewCheckoutService, Transient},
+	}
+	for _, r := range registrations {
+		if err := container.Register(r.constructor, r.lifetime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return container
+}
+
+// TestRegister checks which constructors are accepted
+func TestRegister(t *testing.T) {
+	cases := []struct {
+		name        string
+		constructor any
+		wantErr     bool
+	}{
+		{name: "returns T", constructor: func() *Config { return &Config{} }},
+		{name: "returns T and error", constructor: func() (*BankPayment, error) { return &BankPayment{}, nil }},
+		{name: "not a function", constructor: &Config{}, wantErr: true},
+		{name: "returns nothing", constructor: func() {}, wantErr: true},
+		{name: "second value is not an error", constructor: func() (*Config, int) { return nil, 0 }, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := NewContainer().Register(c.constructor, Transient)
+			if (err != nil) != c.wantErr {
+				t.Errorf("got %v, want error %t", err, c.wantErr)
+			}
+		})
+	}
+
+	t.Run("duplicate type", func(t *testing.T) {
+		container := NewContainer()
+		container.Register(func() *Config { return &Config{} }, Singleton)
+		if err := container.Register(func() *Config { return &Config{} }, Transient); err == nil {
+			t.Error("a second constructor for *Config was accepted")
+		}
+	})
+}
+
+// TestResolve checks the wiring of the graph and the two lifetimes
+func TestResolve(t *testing.T) {
+	container := newTestContainer(t)
+
+	var checkout *CheckoutService
+	if err := container.Resolve(&checkout); err != nil {
+		t.Fatal(err)
+	}
+	adapter, ok := checkout.payment.(*BankPaymentAdapter)
+	if !ok || adapter.bankAccount != 5 {
+		t.Errorf("got payment %#v, want a BankPaymentAdapter for account 5", checkout.payment)
+	}
+	if notifier, ok := checkout.notifier.(*EmailNotifier); !ok || notifier.from != "shop@test.com" {
+		t.Errorf("got notifier %#v, want an EmailNotifier from shop@test.com", checkout.notifier)
+	}
+
+	var n1, n2 Notifier
+	container.Resolve(&n1)
+	container.Resolve(&n2)
+	if n1 != n2 {
+		t.Error("the singleton Notifier was built twice")
+	}
+	var p1, p2 Payment
+	container.Resolve(&p1)
+	container.Resolve(&p2)
+	if p1 == p2 {
+		t.Error("the transient Payment was shared")
+	}
+	if p1.(*BankPaymentAdapter).bankPayment != p2.(*BankPaymentAdapter).bankPayment {
+		t.Error("the transient adapters don't share the singleton BankPayment")
+	}
+}
+
+// TestResolveErrors checks the errors reported while resolving
+func TestResolveErrors(t *testing.T) {
+	t.Run("target is not a pointer", func(t *testing.T) {
+		var checkout *CheckoutService
+		if err := newTestContainer(t).Resolve(checkout); err == nil {
+			t.Error("a nil pointer was accepted as target")
+		}
+	})
+
+	t.Run("missing dependency names the path", func(t *testing.T) {
+		container := NewContainer()
+		container.Register(NewEmailNotifier, Singleton)
+		var n Notifier
+		err := container.Resolve(&n)
+		if err == nil || !strings.Contains(err.Error(), "*main.Config (required by main.Notifier)") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		container := NewContainer()
+		container.Register(NewServiceA, Singleton)
+		container.Register(NewServiceB, Singleton)
+		var a *ServiceA
+		err := container.Resolve(&a)
+		if err == nil || !strings.Contains(err.Error(), "*main.ServiceA -> *main.ServiceB -> *main.ServiceA") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("constructor error is wrapped", func(t *testing.T) {
+		failure := errors.New("no config file")
+		container := NewContainer()
+		container.Register(func() (*Config, error) { return nil, failure }, Singleton)
+		var cfg *Config
+		if err := container.Resolve(&cfg); !errors.Is(err, failure) {
+			t.Errorf("got %v, want %v", err, failure)
+		}
+	})
+}
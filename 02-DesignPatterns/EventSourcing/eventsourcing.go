@@ -0,0 +1,299 @@
+// Event Sourcing is an architectural pattern where the state of an object is not stored directly.
+// Instead, every change is recorded as an immutable event in an append-only stream, and the
+// current state is rebuilt by replaying those events from the beginning.
+//
+// Key benefits of Event Sourcing:
+// - Complete audit log: the history of every change is kept forever
+// - State at any point in time can be rebuilt by replaying a prefix of the stream
+// - Events can feed other models (see the CQRS example)
+// - Appending is simple and fast, there are no in-place updates
+//
+// Common use cases:
+// - Banking and accounting, where the history matters as much as the balance
+// - Systems that need auditing or temporal queries
+// - Debugging production issues by replaying the exact sequence of events
+//
+// In this example, we implement a bank account where:
+// 1. The BankAccount aggregate only changes state by applying events
+// 2. Commands (Deposit, Withdraw) validate rules and record new events
+// 3. An EventStore interface has in-memory and file implementations (see store.go)
+// 4. The AccountRepository takes a snapshot every N events
+// 5. Loading an account starts from the latest snapshot and replays only the newer events
+// RUN PROGRAM WITH FLAGS
+// go run . --dir=./events --snapshot-every=3
+// go test .
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrInsufficientFunds is returned when a withdrawal exceeds the balance
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Event is a fact that happened to an account
+type Event interface {
+	EventType() string
+}
+
+// AccountOpened is the first event of every account stream
+type AccountOpened struct {
+	Owner string `json:"owner"`
+}
+
+// MoneyDeposited records a deposit
+type MoneyDeposited struct {
+	Amount int `json:"amount"`
+}
+
+// MoneyWithdrawn records a withdrawal
+type MoneyWithdrawn struct {
+	Amount int `json:"amount"`
+}
+
+func (AccountOpened) EventType() string  { return "AccountOpened" }
+func (MoneyDeposited) EventType() string { return "MoneyDeposited" }
+func (MoneyWithdrawn) EventType() string { return "MoneyWithdrawn" }
+
+// eventTypes maps the stored type name back to a concrete event for decoding
+var eventTypes = map[string]func() Event{
+	"AccountOpened":  func() Event { return &AccountOpened{} },
+	"MoneyDeposited": func() Event { return &MoneyDeposited{} },
+	"MoneyWithdrawn": func() Event { return &MoneyWithdrawn{} },
+}
+
+// BankAccount is the aggregate; its fields are only modified by apply
+type BankAccount struct {
+	id      string
+	owner   string
+	balance int
+	// version is the number of events applied, including the uncommitted ones
+	version int
+	// changes holds the events recorded since the account was loaded
+	changes []Event
+}
+
+// accountState is the serializable form of the aggregate used by snapshots
+type accountState struct {
+	Owner   string `json:"owner"`
+	Balance int    `json:"balance"`
+}
+
+// OpenAccount creates a new aggregate by recording its first event
+func OpenAccount(id, owner string) *BankAccount {
+	account := &BankAccount{id: id}
+	account.record(AccountOpened{Owner: owner})
+	return account
+}
+
+// Deposit validates and records a deposit
+func (a *BankAccount) Deposit(amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("deposit amount must be positive, got %d", amount)
+	}
+	a.record(MoneyDeposited{Amount: amount})
+	return nil
+}
+
+// Withdraw validates and records a withdrawal
+func (a *BankAccount) Withdraw(amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("withdraw amount must be positive, got %d", amount)
+	}
+	if amount > a.balance {
+		return fmt.Errorf("%w: balance %d, requested %d", ErrInsufficientFunds, a.balance, amount)
+	}
+	a.record(MoneyWithdrawn{Amount: amount})
+	return nil
+}
+
+// Balance returns the current balance
+func (a *BankAccount) Balance() int {
+	return a.balance
+}
+
+// record applies a new event and keeps it to be saved later
+func (a *BankAccount) record(event Event) {
+	a.apply(event)
+	a.changes = append(a.changes, event)
+}
+
+// apply is the only place where the state changes; it must never fail
+// because it is also used to replay events that already happened.
+// Recorded events are values while decoded events are pointers, so both are handled.
+func (a *BankAccount) apply(event Event) {
+	switch e := event.(type) {
+	case *AccountOpened:
+		a.owner = e.Owner
+	case AccountOpened:
+		a.owner = e.Owner
+	case *MoneyDeposited:
+		a.balance += e.Amount
+	case MoneyDeposited:
+		a.balance += e.Amount
+	case *MoneyWithdrawn:
+		a.balance -= e.Amount
+	case MoneyWithdrawn:
+		a.balance -= e.Amount
+	}
+	a.version++
+}
+
+// AccountRepository loads and saves accounts through an EventStore
+type AccountRepository struct {
+	store         EventStore
+	snapshotEvery int
+}
+
+// NewAccountRepository creates a repository that snapshots every snapshotEvery events
+func NewAccountRepository(store EventStore, snapshotEvery int) *AccountRepository {
+	return &AccountRepository{store: store, snapshotEvery: snapshotEvery}
+}
+
+// Save appends the uncommitted events and takes a snapshot when a boundary is crossed
+func (r *AccountRepository) Save(account *BankAccount) error {
+	if len(account.changes) == 0 {
+		return nil
+	}
+
+	committed := account.version - len(account.changes)
+	records := make([]Record, len(account.changes))
+	for i, event := range account.changes {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		records[i] = Record{
+			StreamID: account.id,
+			Version:  committed + i + 1,
+			Type:     event.EventType(),
+			Data:     data,
+			Time:     time.Now(),
+		}
+	}
+
+	if err := r.store.Append(account.id, committed, records); err != nil {
+		return err
+	}
+	account.changes = nil
+
+	// Snapshot when the new events crossed a multiple of snapshotEvery
+	if r.snapshotEvery > 0 && account.version/r.snapshotEvery > committed/r.snapshotEvery {
+		state, err := json.Marshal(accountState{Owner: account.owner, Balance: account.balance})
+		if err != nil {
+			return err
+		}
+		return r.store.SaveSnapshot(Snapshot{StreamID: account.id, Version: account.version, State: state})
+	}
+	return nil
+}
+
+// Load rebuilds an account from its latest snapshot plus the events after it.
+// It also reports how many events had to be replayed.
+func (r *AccountRepository) Load(id string) (*BankAccount, int, error) {
+	account := &BankAccount{id: id}
+
+	snapshot, found, err := r.store.LoadSnapshot(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if found {
+		var state accountState
+		if err := json.Unmarshal(snapshot.State, &state); err != nil {
+			return nil, 0, fmt.Errorf("decoding snapshot: %w", err)
+		}
+		account.owner = state.Owner
+		account.balance = state.Balance
+		account.version = snapshot.Version
+	}
+
+	records, err := r.store.Load(id, account.version)
+	if err != nil {
+		return nil, 0, err
+	}
+	if account.version == 0 && len(records) == 0 {
+		return nil, 0, fmt.Errorf("account %s: %w", id, ErrStreamNotFound)
+	}
+
+	for _, record := range records {
+		newEvent, known := eventTypes[record.Type]
+		if !known {
+			return nil, 0, fmt.Errorf("unknown event type %q", record.Type)
+		}
+		event := newEvent()
+		if err := json.Unmarshal(record.Data, event); err != nil {
+			return nil, 0, fmt.Errorf("decoding %s: %w", record.Type, err)
+		}
+		account.apply(event)
+	}
+	return account, len(records), nil
+}
+
+// Command line flags for the demo
+var (
+	dir           = flag.String("dir", "", "directory for the file event store (empty uses memory)")
+	snapshotEvery = flag.Int("snapshot-every", 3, "take a snapshot every N events")
+)
+
+func main() {
+	flag.Parse()
+
+	var store EventStore = NewInMemoryEventStore()
+	if *dir != "" {
+		fileStore, err := NewFileEventStore(*dir)
+		if err != nil {
+			fmt.Println("Event store error:", err)
+			os.Exit(1)
+		}
+		store = fileStore
+	}
+	repo := NewAccountRepository(store, *snapshotEvery)
+
+	// Reuse the account if it already exists in the file store
+	account, replayed, err := repo.Load("account-1")
+	if errors.Is(err, ErrStreamNotFound) {
+		account = OpenAccount("account-1", "Andres")
+		err = nil
+	}
+	if err != nil {
+		fmt.Println("Load error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Starting at version %d with balance %d (%d events replayed)\n",
+		account.version, account.Balance(), replayed)
+
+	// Each operation is saved separately, so snapshots are taken along the way
+	operations := []func() error{
+		func() error { return account.Deposit(100) },
+		func() error { return account.Deposit(50) },
+		func() error { return account.Withdraw(30) },
+		func() error { return account.Withdraw(1000) },
+		func() error { return account.Deposit(200) },
+		func() error { return account.Withdraw(20) },
+	}
+	for _, operation := range operations {
+		if err := operation(); err != nil {
+			fmt.Println("Rejected:", err)
+			continue
+		}
+		if err := repo.Save(account); err != nil {
+			fmt.Println("Save error:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Rebuild the account: snapshot + replay of the newer events
+	restored, replayed, err := repo.Load("account-1")
+	if err != nil {
+		fmt.Println("Load error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %s (%s) at version %d with balance %d, replayed %d events after the snapshot\n",
+		restored.id, restored.owner, restored.version, restored.Balance(), replayed)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestAccountRepository saves an account one operation at a time with a snapshot every
+// 3 events: loading it replays only the events after the last snapshot
+func TestAccountRepository(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			repo := NewAccountRepository(s.newStore(t), 3)
+			if _, _, err := repo.Load("account-1"); !errors.Is(err, ErrStreamNotFound) {
+				t.Errorf("got %v for a missing account, want ErrStreamNotFound", err)
+			}
+
+			account := OpenAccount("account-1", "Andres")
+			operations := []func() error{
+				func() error { return account.Deposit(100) },
+				func() error { return account.Withdraw(30) },
+				func() error { return account.Deposit(50) },
+				func() error { return account.Withdraw(20) },
+			}
+			for _, operation := range operations {
+				if err := operation(); err != nil {
+					t.Fatal(err)
+				}
+				if err := repo.Save(account); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := account.Withdraw(1000); !errors.Is(err, ErrInsufficientFunds) {
+				t.Errorf("got %v for a withdrawal above the balance, want ErrInsufficientFunds", err)
+			}
+
+			// 5 events: the snapshot of version 3 covers the first 3
+			restored, replayed, err := repo.Load("account-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored.owner != "Andres" || restored.Balance() != 100 || restored.version != 5 || replayed != 2 {
+				t.Errorf("got %s with %d at version %d after %d events, want Andres with 100 at version 5 after 2",
+					restored.owner, restored.Balance(), restored.version, replayed)
+			}
+
+			// A copy loaded before the save of another one is out of date
+			stale, _, _ := repo.Load("account-1")
+			restored.Deposit(10)
+			if err := repo.Save(restored); err != nil {
+				t.Fatal(err)
+			}
+			stale.Deposit(10)
+			if err := repo.Save(stale); !errors.Is(err, ErrConcurrentUpdate) {
+				t.Errorf("got %v saving a stale copy, want ErrConcurrentUpdate", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Errors returned by the event stores
+var (
+	ErrStreamNotFound   = errors.New("stream not found")
+	ErrConcurrentUpdate = errors.New("stream was modified concurrently")
+	ErrInvalidStreamID  = errors.New("invalid stream ID")
+)
+
+// Record is the stored form of an event
+type Record struct {
+	StreamID string          `json:"stream_id"`
+	Version  int             `json:"version"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	Time     time.Time       `json:"time"`
+}
+
+// Snapshot is the serialized state of an aggregate at a given version
+type Snapshot struct {
+	StreamID string          `json:"stream_id"`
+	Version  int             `json:"version"`
+	State    json.RawMessage `json:"state"`
+}
+
+// EventStore is an append-only log of events grouped in streams
+type EventStore interface {
+	// Append adds records to a stream. expectedVersion must match the current
+	// length of the stream, otherwise ErrConcurrentUpdate is returned.
+	Append(streamID string, expectedVersion int, records []Record) error
+	// Load returns the records of a stream with a version greater than afterVersion
+	Load(streamID string, afterVersion int) ([]Record, error)
+	// SaveSnapshot replaces the latest snapshot of a stream
+	SaveSnapshot(snapshot Snapshot) error
+	// LoadSnapshot returns the latest snapshot of a stream, if any
+	LoadSnapshot(streamID string) (Snapshot, bool, error)
+}
+
+// InMemoryEventStore keeps the streams in maps
+type InMemoryEventStore struct {
+	streams   map[string][]Record
+	snapshots map[string]Snapshot
+	mux       sync.RWMutex
+}
+
+// NewInMemoryEventStore creates an empty in-memory store
+func NewInMemoryEventStore() *InMemoryEventStore {
+	return &InMemoryEventStore{
+		streams:   make(map[string][]Record),
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+func (s *InMemoryEventStore) Append(streamID string, expectedVersion int, records []Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if current := len(s.streams[streamID]); current != expectedVersion {
+		return fmt.Errorf("%w: expected version %d, stream is at %d", ErrConcurrentUpdate, expectedVersion, current)
+	}
+	s.streams[streamID] = append(s.streams[streamID], records...)
+	return nil
+}
+
+func (s *InMemoryEventStore) Load(streamID string, afterVersion int) ([]Record, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	stream := s.streams[streamID]
+	if afterVersion >= len(stream) {
+		return nil, nil
+	}
+	// Return a copy so callers can't modify the stored stream
+	return append([]Record(nil), stream[afterVersion:]...), nil
+}
+
+func (s *InMemoryEventStore) SaveSnapshot(snapshot Snapshot) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.snapshots[snapshot.StreamID] = snapshot
+	return nil
+}
+
+func (s *InMemoryEventStore) LoadSnapshot(streamID string) (Snapshot, bool, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	snapshot, exists := s.snapshots[streamID]
+	return snapshot, exists, nil
+}
+
+// FileEventStore keeps every stream in a JSON lines file opened in append-only mode
+// and its latest snapshot in a separate JSON file.
+// The first access to a stream scans its file once to learn its version and the offset
+// of every record; after that, Append only writes the new records at the end of the file
+// and Load seeks to the first requested record, so loading after a snapshot doesn't decode
+// the events it covers. The store must be the only writer of its directory.
+type FileEventStore struct {
+	dir     string
+	streams map[string]*fileStream
+	mux     sync.Mutex
+}
+
+// fileStream is the index of a stream file: offsets[v] is where the record with
+// version v+1 starts, and the last offset is the size of the file
+type fileStream struct {
+	offsets []int64
+}
+
+func (f *fileStream) version() int {
+	return len(f.offsets) - 1
+}
+
+// NewFileEventStore creates the directory if needed
+func NewFileEventStore(dir string) (*FileEventStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileEventStore{dir: dir, streams: make(map[string]*fileStream)}, nil
+}
+
+// validStreamID accepts letters, digits, '-' and '_', so an ID can't name a file
+// outside the directory of the store
+func validStreamID(streamID string) error {
+	if streamID == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidStreamID)
+	}
+	for _, r := range streamID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("%w: %q", ErrInvalidStreamID, streamID)
+		}
+	}
+	return nil
+}
+
+func (s *FileEventStore) eventsPath(streamID string) string {
+	return filepath.Join(s.dir, streamID+".events")
+}
+
+func (s *FileEventStore) snapshotPath(streamID string) string {
+	return filepath.Join(s.dir, streamID+".snapshot")
+}
+
+func (s *FileEventStore) Append(streamID string, expectedVersion int, records []Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stream, err := s.stream(streamID)
+	if err != nil {
+		return err
+	}
+	if stream.version() != expectedVersion {
+		return fmt.Errorf("%w: expected version %d, stream is at %d", ErrConcurrentUpdate, expectedVersion, stream.version())
+	}
+
+	// Encode the whole batch first, it is written with a single call
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	offsets := make([]int64, len(records))
+	end := stream.offsets[stream.version()]
+	for i, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+		offsets[i] = end + int64(buf.Len())
+	}
+
+	// O_APPEND guarantees we never rewrite events that are already stored
+	file, err := os.OpenFile(s.eventsPath(streamID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		// Part of the batch may be in the file: scan it again on the next access
+		delete(s.streams, streamID)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		delete(s.streams, streamID)
+		return err
+	}
+	stream.offsets = append(stream.offsets, offsets...)
+	return nil
+}
+
+func (s *FileEventStore) Load(streamID string, afterVersion int) ([]Record, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stream, err := s.stream(streamID)
+	afterVersion = max(afterVersion, 0)
+	if err != nil || afterVersion >= stream.version() {
+		return nil, err
+	}
+	file, err := os.Open(s.eventsPath(streamID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(stream.offsets[afterVersion], io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, stream.version()-afterVersion)
+	decoder := json.NewDecoder(file)
+	for range cap(records) {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("corrupted event in %s: %w", streamID, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// stream returns the index of a stream, scanning its file on the first access;
+// a missing file is an empty stream
+func (s *FileEventStore) stream(streamID string) (*fileStream, error) {
+	if err := validStreamID(streamID); err != nil {
+		return nil, err
+	}
+	if stream, exists := s.streams[streamID]; exists {
+		return stream, nil
+	}
+
+	stream := &fileStream{offsets: []int64{0}}
+	file, err := os.Open(s.eventsPath(streamID))
+	if errors.Is(err, os.ErrNotExist) {
+		s.streams[streamID] = stream
+		return stream, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("corrupted event in %s: %w", streamID, err)
+		}
+		offset += int64(len(line))
+		stream.offsets = append(stream.offsets, offset)
+	}
+	s.streams[streamID] = stream
+	return stream, nil
+}
+
+func (s *FileEventStore) SaveSnapshot(snapshot Snapshot) error {
+	if err := validStreamID(snapshot.StreamID); err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it so a crash never leaves a torn snapshot
+	tmp := s.snapshotPath(snapshot.StreamID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.snapshotPath(snapshot.StreamID))
+}
+
+func (s *FileEventStore) LoadSnapshot(streamID string) (Snapshot, bool, error) {
+	if err := validStreamID(streamID); err != nil {
+		return Snapshot{}, false, err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	data, err := os.ReadFile(s.snapshotPath(streamID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, false, fmt.Errorf("corrupted snapshot for %s: %w", streamID, err)
+	}
+	return snapshot, true, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stores builds a fresh, empty store of every implementation
+var stores = []struct {
+	name     string
+	newStore func(t *testing.T) EventStore
+}{
+	{"Memory", func(t *testing.T) EventStore {
+		return NewInMemoryEventStore()
+	}},
+	{"File", func(t *testing.T) EventStore {
+		store, err := NewFileEventStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+// records returns the records of versions from+1 to to of a stream
+func records(streamID string, from, to int) []Record {
+	var result []Record
+	for version := from + 1; version <= to; version++ {
+		data := fmt.Appendf(nil, `{"amount":%d}`, version)
+		result = append(result, Record{StreamID: streamID, Version: version, Type: "MoneyDeposited", Data: data})
+	}
+	return result
+}
+
+// checkVersions compares the versions of loaded records with want
+func checkVersions(t *testing.T, got []Record, err error, want ...int) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want versions %v", len(got), want)
+	}
+	for i, record := range got {
+		if record.Version != want[i] || string(record.Data) != fmt.Sprintf(`{"amount":%d}`, want[i]) {
+			t.Errorf("record %d is version %d with %s, want version %d", i, record.Version, record.Data, want[i])
+		}
+	}
+}
+
+func TestEventStore(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.newStore(t)
+			if got, err := store.Load("account-1", 0); err != nil || len(got) != 0 {
+				t.Fatalf("got %v, %v from a missing stream", got, err)
+			}
+			if err := store.Append("account-1", 0, records("account-1", 0, 3)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append("account-1", 3, records("account-1", 3, 5)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append("account-1", 3, records("account-1", 3, 4)); !errors.Is(err, ErrConcurrentUpdate) {
+				t.Errorf("got %v for an old expected version, want ErrConcurrentUpdate", err)
+			}
+
+			got, err := store.Load("account-1", 0)
+			checkVersions(t, got, err, 1, 2, 3, 4, 5)
+			got, err = store.Load("account-1", 3)
+			checkVersions(t, got, err, 4, 5)
+			if got, err := store.Load("account-1", 5); err != nil || len(got) != 0 {
+				t.Errorf("got %v, %v after the last version", got, err)
+			}
+			got, err = store.Load("account-2", 0)
+			checkVersions(t, got, err)
+
+			if _, found, err := store.LoadSnapshot("account-1"); found || err != nil {
+				t.Errorf("got a snapshot before saving one, error %v", err)
+			}
+			for _, version := range []int{3, 5} {
+				state := fmt.Appendf(nil, `{"balance":%d}`, version)
+				if err := store.SaveSnapshot(Snapshot{StreamID: "account-1", Version: version, State: state}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if snapshot, found, err := store.LoadSnapshot("account-1"); !found || err != nil || snapshot.Version != 5 {
+				t.Errorf("got %+v, %t, %v, want the snapshot of version 5", snapshot, found, err)
+			}
+		})
+	}
+}
+
+// TestFileEventStoreReopen checks that a new store over the same directory finds the
+// versions and offsets of the streams written by the previous one
+func TestFileEventStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Append("account-1", 0, records("account-1", 0, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Append("account-1", 0, records("account-1", 0, 1)); !errors.Is(err, ErrConcurrentUpdate) {
+		t.Errorf("got %v for version 0 of an existing stream, want ErrConcurrentUpdate", err)
+	}
+	if err := second.Append("account-1", 4, records("account-1", 4, 6)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := second.Load("account-1", 2)
+	checkVersions(t, got, err, 3, 4, 5, 6)
+}
+
+// TestFileEventStoreLoadSeeks corrupts the records covered by a snapshot: Load after
+// them must not read them again
+func TestFileEventStoreLoadSeeks(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append("account-1", 0, records("account-1", 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	first := store.streams["account-1"].offsets[1]
+	file, err := os.OpenFile(filepath.Join(dir, "account-1.events"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte("#"), first-2); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	got, err := store.Load("account-1", 1)
+	checkVersions(t, got, err, 2, 3)
+	if _, err := store.Load("account-1", 0); err == nil {
+		t.Error("got no error loading a corrupted record")
+	}
+}
+
+func TestFileEventStoreStreamID(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(filepath.Join(dir, "events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"", "../outside", "a/b", `a\b`, "..", "account 1"} {
+		if err := store.Append(id, 0, records(id, 0, 1)); !errors.Is(err, ErrInvalidStreamID) {
+			t.Errorf("Append(%q): got %v, want ErrInvalidStreamID", id, err)
+		}
+		if _, err := store.Load(id, 0); !errors.Is(err, ErrInvalidStreamID) {
+			t.Errorf("Load(%q): got %v, want ErrInvalidStreamID", id, err)
+		}
+		if err := store.SaveSnapshot(Snapshot{StreamID: id}); !errors.Is(err, ErrInvalidStreamID) {
+			t.Errorf("SaveSnapshot(%q): got %v, want ErrInvalidStreamID", id, err)
+		}
+		if _, _, err := store.LoadSnapshot(id); !errors.Is(err, ErrInvalidStreamID) {
+			t.Errorf("LoadSnapshot(%q): got %v, want ErrInvalidStreamID", id, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d entries next to the directory of the store, want none", len(entries)-1)
+	}
+}
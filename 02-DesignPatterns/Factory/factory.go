@@ -19,11 +19,15 @@
 // 3. Creates s pecific types (Laptop, Desktop) that inherit from Computer
 // 4. Uses a Factory function to centralize and encapsulate creation logic
 // 5. Allows clients to create objects without knowing implementation details
+// 6. Lets constructors register themselves by name, so new products (like Tablet)
+//    can be added without modifying the factory
 
 package main
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 )
 
 // IProduct defines the interface that all products must implement
@@ -87,18 +91,77 @@ func newDesktop() IProduct {
 	}
 }
 
+// Tablet is a product added later; it only needs to register its constructor
+type Tablet struct {
+	Computer
+}
+
+// newTablet is a constructor function that creates a new Tablet instance
+func newTablet() IProduct {
+	return &Tablet{
+		Computer: Computer{
+			stock: 23,
+			name:  "Tablet",
+		},
+	}
+}
+
+// Constructor is the signature every product constructor must have
+type Constructor func() IProduct
+
+// Registry of product constructors indexed by name
+var (
+	constructors   = make(map[string]Constructor)
+	constructorsMu sync.RWMutex
+)
+
+// RegisterProduct makes a product constructor available to the factory under the given name.
+// Like database/sql drivers, registering the same name twice is a programming error and panics.
+func RegisterProduct(name string, constructor Constructor) {
+	constructorsMu.Lock()
+	defer constructorsMu.Unlock()
+
+	if constructor == nil {
+		panic("factory: RegisterProduct constructor is nil")
+	}
+	if _, exists := constructors[name]; exists {
+		panic("factory: RegisterProduct called twice for " + name)
+	}
+	constructors[name] = constructor
+}
+
+// Products returns the sorted names of the registered products
+func Products() []string {
+	constructorsMu.RLock()
+	defer constructorsMu.RUnlock()
+
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// init registers the products known by this file; other files can do the same
+func init() {
+	RegisterProduct("laptop", newLaptop)
+	RegisterProduct("desktop", newDesktop)
+	RegisterProduct("tablet", newTablet)
+}
+
 // ComputerFactory is the factory function that centralizes the creation of different computer types.
-// It receives the type of computer to create and returns a new instance of the corresponding type.
-// This encapsulates all object creation logic in one place, making it easier to modify or extend.
-func ComputerFactory(computerType IProduct) (IProduct, error) {
-	switch computerType.(type) {
-	case *Desktop:
-		return newDesktop(), nil
-	case *Laptop:
-		return newLaptop(), nil
-	default:
-		return nil, fmt.Errorf("Unknown computer type: %T", computerType)
+// It receives the name of the computer to create and returns a new instance of the corresponding type.
+// Constructors are looked up in the registry, so adding a type doesn't require modifying this function.
+func ComputerFactory(name string) (IProduct, error) {
+	constructorsMu.RLock()
+	constructor, exists := constructors[name]
+	constructorsMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("Unknown computer type: %s", name)
 	}
+	return constructor(), nil
 }
 
 // String implements the Stringer interface to display product information
@@ -111,8 +174,14 @@ func main() {
 	// Example of Factory Pattern usage
 	// The client only needs to know about the IProduct interface and the Factory function
 	// It doesn't need to know the implementation details of each computer type
-	laptop, _ := ComputerFactory(&Laptop{})
-	desktop, _ := ComputerFactory(&Desktop{})
-	fmt.Println(laptop)
-	fmt.Println(desktop)
+	// Products are created by name, without needing an instance of the type first
+	fmt.Println("Registered products:", Products())
+	for _, name := range []string{"laptop", "desktop", "tablet", "phone"} {
+		product, err := ComputerFactory(name)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println(product)
+	}
 }
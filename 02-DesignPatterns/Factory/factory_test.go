@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestComputerFactory checks the products created for every registered name,
+// and that unknown names are rejected
+func TestComputerFactory(t *testing.T) {
+	cases := []struct {
+		name      string
+		wantType  string
+		wantName  string
+		wantStock int
+		wantErr   bool
+	}{
+		{name: "laptop", wantType: "*main.Laptop", wantName: "Laptop", wantStock: 11},
+		{name: "desktop", wantType: "*main.Desktop", wantName: "Desktop", wantStock: 66},
+		{name: "tablet", wantType: "*main.Tablet", wantName: "Tablet", wantStock: 23},
+		{name: "phone", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			product, err := ComputerFactory(c.name)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", product)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", product); got != c.wantType {
+				t.Errorf("got type %s, want %s", got, c.wantType)
+			}
+			if product.getName() != c.wantName || product.getStock() != c.wantStock {
+				t.Errorf("got %s with stock %d, want %s with stock %d",
+					product.getName(), product.getStock(), c.wantName, c.wantStock)
+			}
+		})
+	}
+}
+
+// TestComputerFactoryNewInstance checks that every call returns a new instance
+func TestComputerFactoryNewInstance(t *testing.T) {
+	first, _ := ComputerFactory("laptop")
+	second, _ := ComputerFactory("laptop")
+	first.setStock(0)
+	if second.getStock() == 0 {
+		t.Fatal("factory returned a shared instance")
+	}
+}
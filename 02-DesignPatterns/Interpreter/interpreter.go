@@ -0,0 +1,149 @@
+// The Interpreter Pattern defines a representation for the grammar of a small language along
+// with an interpreter that uses the representation to evaluate sentences of the language.
+// Every grammar rule becomes a node type of an Abstract Syntax Tree (AST), and evaluating a
+// sentence means walking the tree.
+//
+// Key benefits of the Interpreter Pattern:
+// - The grammar is explicit and easy to extend with new node types
+// - Parsing (structure) and evaluation (meaning) are separated
+// - The same AST can be evaluated, printed, optimized or type checked
+//
+// Common use cases:
+// - Calculators, formula fields in spreadsheets and pricing rules
+// - Configuration or query languages (filters, templates, feature flags)
+// - Domain specific languages inside an application
+//
+// In this example, we implement a tiny arithmetic language with variables where:
+// 1. The lexer (lexer.go) turns text into tokens with their positions
+// 2. The parser (parser.go) builds an AST using recursive descent
+// 3. The Interpreter evaluates the AST with an environment of variables
+// 4. Every error points to the position where it happened
+// 5. A table of expressions checks results and error positions (see interpreter_test.go)
+// RUN PROGRAM WITH FLAGS
+// go run .
+// go test .
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Interpreter evaluates ASTs and keeps the variables between statements
+type Interpreter struct {
+	vars map[string]float64
+}
+
+// NewInterpreter creates an interpreter with the predefined constants
+func NewInterpreter() *Interpreter {
+	return &Interpreter{vars: map[string]float64{"pi": math.Pi, "e": math.E}}
+}
+
+// Run parses and evaluates a statement
+func (in *Interpreter) Run(src string) (float64, error) {
+	node, err := Parse(src)
+	if err != nil {
+		return 0, err
+	}
+	return in.Eval(node)
+}
+
+// Eval walks the tree; every node type knows how to compute its value
+func (in *Interpreter) Eval(node Node) (float64, error) {
+	switch n := node.(type) {
+	case *NumberNode:
+		return n.Value, nil
+	case *VariableNode:
+		value, exists := in.vars[n.Name]
+		if !exists {
+			return 0, errorf(n.At, "undefined variable %q", n.Name)
+		}
+		return value, nil
+	case *UnaryNode:
+		value, err := in.Eval(n.Operand)
+		return -value, err
+	case *AssignNode:
+		value, err := in.Eval(n.Value)
+		if err != nil {
+			return 0, err
+		}
+		in.vars[n.Name] = value
+		return value, nil
+	case *BinaryNode:
+		left, err := in.Eval(n.Left)
+		if err != nil {
+			return 0, err
+		}
+		right, err := in.Eval(n.Right)
+		if err != nil {
+			return 0, err
+		}
+		return applyOperator(n, left, right)
+	default:
+		return 0, errorf(node.Pos(), "unknown node %T", node)
+	}
+}
+
+// applyOperator computes a binary operation, reporting errors at the operator position
+func applyOperator(n *BinaryNode, left, right float64) (float64, error) {
+	switch n.Op {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/":
+		if right == 0 {
+			return 0, errorf(n.At, "division by zero")
+		}
+		return left / right, nil
+	case "%":
+		if right == 0 {
+			return 0, errorf(n.At, "modulo by zero")
+		}
+		return math.Mod(left, right), nil
+	case "^":
+		return math.Pow(left, right), nil
+	default:
+		return 0, errorf(n.At, "unknown operator %q", n.Op)
+	}
+}
+
+// showError prints the source with a caret under the position of the error
+func showError(src string, err error) {
+	fmt.Printf("  %s\n", src)
+	if e, ok := err.(*Error); ok {
+		fmt.Printf("  %s^ %s\n", strings.Repeat(" ", e.Pos), e.Msg)
+		return
+	}
+	fmt.Printf("  %v\n", err)
+}
+
+// statements of the demo, run in order with a single interpreter so variables carry over
+var statements = []string{
+	"1 + 2 * 3",
+	"2 ^ 3 ^ 2",
+	"price = 1200",
+	"total = price * 3 - 100",
+	"2 * pi",
+	"(1 + 2",
+	"3 $ 4",
+	"discount * 2",
+	"10 / (5 - 5)",
+}
+
+func main() {
+	interpreter := NewInterpreter()
+	for _, src := range statements {
+		got, err := interpreter.Run(src)
+		if err != nil {
+			fmt.Printf("%-25s error\n", src)
+			showError(src, err)
+			continue
+		}
+		fmt.Printf("%-25s = %g\n", src, got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		src     string
+		vars    []string // Statements run first, on the same interpreter
+		want    float64
+		wantPos int // Position of the expected error, -1 when no error is expected
+	}{
+		{"1 + 2 * 3", nil, 7, -1},
+		{"(1 + 2) * 3", nil, 9, -1},
+		{"2 ^ 3 ^ 2", nil, 512, -1},
+		{"-2 ^ 2", nil, -4, -1},
+		{"10 % 4 - 8 / 4", nil, 0, -1},
+		{"price = 1200", nil, 1200, -1},
+		{"total = price * 3 - 100", []string{"price = 1200"}, 3500, -1},
+		{"total / 2", []string{"price = 1200", "total = price * 3 - 100"}, 1750, -1},
+		{"2 * pi", nil, 2 * math.Pi, -1},
+		{"1 + ", nil, 0, 4},
+		{"(1 + 2", nil, 0, 6},
+		{"3 $ 4", nil, 0, 2},
+		{"discount * 2", nil, 0, 0},
+		{"10 / (5 - 5)", nil, 0, 3},
+		{"10 % 0", nil, 0, 3},
+		{"1 2", nil, 0, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.src, func(t *testing.T) {
+			interpreter := NewInterpreter()
+			for _, src := range c.vars {
+				if _, err := interpreter.Run(src); err != nil {
+					t.Fatalf("%s: %v", src, err)
+				}
+			}
+			got, err := interpreter.Run(c.src)
+			if c.wantPos >= 0 {
+				var e *Error
+				if !errors.As(err, &e) || e.Pos != c.wantPos {
+					t.Errorf("got %g, %v, want an error at position %d", got, err, c.wantPos)
+				}
+				return
+			}
+			if err != nil || math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("got %g, %v, want %g", got, err, c.want)
+			}
+		})
+	}
+}
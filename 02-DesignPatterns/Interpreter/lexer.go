@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// TokenKind classifies the tokens of the language
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenNumber
+	TokenIdent
+	TokenOperator // + - * / % ^
+	TokenAssign   // =
+	TokenLParen
+	TokenRParen
+)
+
+func (k TokenKind) String() string {
+	return [...]string{"end of input", "number", "identifier", "operator", "'='", "'('", "')'"}[k]
+}
+
+// Token is a lexeme with its position (character offset) in the source
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Value float64 // Only for numbers
+	Pos   int
+}
+
+// Error is a lexing, parsing or evaluation error pointing to a position in the source
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// errorf creates an Error at the given position
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Tokenize splits the source into tokens, ending with a TokenEOF
+func Tokenize(src string) ([]Token, error) {
+	var tokens []Token
+	runes := []rune(src)
+
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case unicode.IsDigit(r) || r == '.':
+			start := pos
+			for pos < len(runes) && (unicode.IsDigit(runes[pos]) || runes[pos] == '.') {
+				pos++
+			}
+			text := string(runes[start:pos])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, errorf(start, "invalid number %q", text)
+			}
+			tokens = append(tokens, Token{Kind: TokenNumber, Text: text, Value: value, Pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := pos
+			for pos < len(runes) && (unicode.IsLetter(runes[pos]) || unicode.IsDigit(runes[pos]) || runes[pos] == '_') {
+				pos++
+			}
+			tokens = append(tokens, Token{Kind: TokenIdent, Text: string(runes[start:pos]), Pos: start})
+		default:
+			kind, known := map[rune]TokenKind{
+				'+': TokenOperator, '-': TokenOperator, '*': TokenOperator, '/': TokenOperator,
+				'%': TokenOperator, '^': TokenOperator, '=': TokenAssign, '(': TokenLParen, ')': TokenRParen,
+			}[r]
+			if !known {
+				return nil, errorf(pos, "unexpected character %q", r)
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: string(r), Pos: pos})
+			pos++
+		}
+	}
+	return append(tokens, Token{Kind: TokenEOF, Pos: len(runes)}), nil
+}
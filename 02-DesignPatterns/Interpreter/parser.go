@@ -0,0 +1,193 @@
+package main
+
+// Node is an element of the abstract syntax tree
+type Node interface {
+	// Pos returns the position of the node in the source, used in error messages
+	Pos() int
+}
+
+// NumberNode is a numeric literal
+type NumberNode struct {
+	Value float64
+	At    int
+}
+
+// VariableNode is a reference to a variable
+type VariableNode struct {
+	Name string
+	At   int
+}
+
+// UnaryNode is a negation: -x
+type UnaryNode struct {
+	Operand Node
+	At      int
+}
+
+// BinaryNode is an operation with two operands: x + y
+type BinaryNode struct {
+	Op          string
+	Left, Right Node
+	At          int // Position of the operator
+}
+
+// AssignNode stores the value of an expression in a variable: x = y + 1
+type AssignNode struct {
+	Name  string
+	Value Node
+	At    int
+}
+
+func (n *NumberNode) Pos() int   { return n.At }
+func (n *VariableNode) Pos() int { return n.At }
+func (n *UnaryNode) Pos() int    { return n.At }
+func (n *BinaryNode) Pos() int   { return n.At }
+func (n *AssignNode) Pos() int   { return n.At }
+
+// Grammar, from lowest to highest precedence:
+//
+//	statement  = IDENT "=" expression | expression
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = "-" unary | power
+//	power      = primary [ "^" unary ]          (right associative)
+//	primary    = NUMBER | IDENT | "(" expression ")"
+//
+// Each rule is a method of the recursive descent parser below.
+
+// parser consumes the tokens produced by Tokenize
+type parser struct {
+	tokens  []Token
+	current int
+}
+
+// Parse turns the source into an AST
+func Parse(src string) (Node, error) {
+	tokens, err := Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		return nil, errorf(tok.Pos, "unexpected %s %q", tok.Kind, tok.Text)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.current]
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.current]
+	if tok.Kind != TokenEOF {
+		p.current++
+	}
+	return tok
+}
+
+// isOperator reports whether the next token is one of the given operators
+func (p *parser) isOperator(ops ...string) bool {
+	tok := p.peek()
+	if tok.Kind != TokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if tok.Text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) statement() (Node, error) {
+	if p.peek().Kind == TokenIdent && p.tokens[p.current+1].Kind == TokenAssign {
+		name := p.next()
+		assign := p.next()
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &AssignNode{Name: name.Text, Value: value, At: assign.Pos}, nil
+	}
+	return p.expression()
+}
+
+func (p *parser) expression() (Node, error) {
+	return p.binary(p.term, "+", "-")
+}
+
+func (p *parser) term() (Node, error) {
+	return p.binary(p.unary, "*", "/", "%")
+}
+
+// binary parses a left associative chain of operators with the same precedence
+func (p *parser) binary(operand func() (Node, error), ops ...string) (Node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator(ops...) {
+		op := p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{Op: op.Text, Left: left, Right: right, At: op.Pos}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Node, error) {
+	if p.isOperator("-") {
+		op := p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryNode{Operand: operand, At: op.Pos}, nil
+	}
+	return p.power()
+}
+
+func (p *parser) power() (Node, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator("^") {
+		op := p.next()
+		// Recursing into unary makes 2^3^2 = 2^(3^2) and allows 2^-1
+		exponent, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryNode{Op: "^", Left: base, Right: exponent, At: op.Pos}, nil
+	}
+	return base, nil
+}
+
+func (p *parser) primary() (Node, error) {
+	tok := p.next()
+	switch tok.Kind {
+	case TokenNumber:
+		return &NumberNode{Value: tok.Value, At: tok.Pos}, nil
+	case TokenIdent:
+		return &VariableNode{Name: tok.Text, At: tok.Pos}, nil
+	case TokenLParen:
+		inner, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.Kind != TokenRParen {
+			return nil, errorf(closing.Pos, "expected ')' to close '(' at position %d, found %s", tok.Pos, closing.Kind)
+		}
+		return inner, nil
+	default:
+		return nil, errorf(tok.Pos, "expected a number, a variable or '(', found %s", tok.Kind)
+	}
+}
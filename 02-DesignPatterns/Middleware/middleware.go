@@ -0,0 +1,67 @@
+// The Middleware (or Decorator Chain) Pattern wraps a handler with layers that add behavior
+// before and after it, without modifying the handler itself. Each middleware receives the
+// next handler and returns a new one, so they can be stacked in any order.
+//
+// Key benefits of the Middleware Pattern:
+// - Cross-cutting concerns (logging, auth, recovery) are written once and reused
+// - Handlers stay focused on business logic
+// - The order of the layers is explicit and easy to change
+// - Every middleware can be tested in isolation
+//
+// Common use cases:
+// - HTTP servers: logging, authentication, panic recovery, request IDs, CORS
+// - gRPC interceptors and database drivers with hooks
+//
+// In this example, we implement middlewares for net/http where:
+// 1. A Middleware is a func(http.Handler) http.Handler
+// 2. Chain(middlewares...).Then(handler) applies them so the first one is the outermost
+// 3. RequestID, Logging, Recovery and Auth show the typical layers of an HTTP service
+// 4. The request ID travels in the request context so other layers can read it
+//
+// The chain lives in pkg/middleware, and the HTTP services in 03-Net build their handlers
+// with it too.
+// RUN PROGRAM WITH FLAGS
+// go run . --addr=localhost:8080
+// curl -H "Authorization: Bearer secret-token" localhost:8080/hello
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+)
+
+// Address the demo server listens on
+var addr = flag.String("addr", "localhost:8080", "address to listen on")
+
+// routes builds the handlers of the demo, every one wrapped by its chain
+func routes(logger *log.Logger) http.Handler {
+	// Common layers for every route
+	base := middleware.NewChain(middleware.RequestID, middleware.Logging(logger), middleware.Recovery(logger))
+	// Protected routes add authentication on top of the common layers
+	protected := base.Append(middleware.Auth("secret-token"))
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", base.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}))
+	mux.Handle("/hello", protected.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello! Your request ID is %s\n", middleware.GetRequestID(r.Context()))
+	}))
+	mux.Handle("/panic", protected.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	}))
+	return mux
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	logger.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, routes(logger)))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+)
+
+// TestRoutes sends requests through the chains of the demo
+func TestRoutes(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		token      string
+		requestID  string
+		wantStatus int
+		wantBody   string
+		wantLog    string
+	}{
+		{name: "public route", path: "/health", wantStatus: http.StatusOK, wantBody: "ok"},
+		{name: "missing token", path: "/hello", wantStatus: http.StatusUnauthorized, wantLog: " 401 "},
+		{name: "wrong token", path: "/hello", token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "request ID is propagated", path: "/hello", token: "secret-token", requestID: "abc123", wantStatus: http.StatusOK, wantBody: "abc123", wantLog: "abc123 GET /hello 200"},
+		{name: "panic is recovered", path: "/panic", token: "secret-token", wantStatus: http.StatusInternalServerError, wantLog: "panic: something went wrong"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			handler := routes(log.New(&logs, "", 0))
+
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			if c.requestID != "" {
+				req.Header.Set(middleware.RequestIDHeader, c.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != c.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, c.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), c.wantBody) {
+				t.Errorf("got body %q, want it to contain %q", rec.Body.String(), c.wantBody)
+			}
+			if rec.Header().Get(middleware.RequestIDHeader) == "" {
+				t.Error("the response has no request ID")
+			}
+			if !strings.Contains(logs.String(), c.wantLog) {
+				t.Errorf("got logs %q, want them to contain %q", logs.String(), c.wantLog)
+			}
+		})
+	}
+}
+
+// TestChainOrder checks that the first middleware of a chain receives the request first
+// and that Append doesn't modify the chain it extends
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	layer := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+" in")
+				next.ServeHTTP(w, r)
+				calls = append(calls, name+" out")
+			})
+		}
+	}
+
+	base := middleware.NewChain(layer("a"), layer("b"))
+	extended := base.Append(layer("c"))
+	base.ThenFunc(func(http.ResponseWriter, *http.Request) { calls = append(calls, "handler") }).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := []string{"a in", "b in", "handler", "b out", "a out"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %v, want %v", calls, want)
+	}
+
+	calls = nil
+	extended.ThenFunc(func(http.ResponseWriter, *http.Request) {}).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(calls) != 6 || calls[2] != "c in" {
+		t.Errorf("got %v, want c as the innermost layer", calls)
+	}
+}
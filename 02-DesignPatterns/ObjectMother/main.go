@@ -0,0 +1,95 @@
+// The Object Mother and Test Data Builder patterns centralize the creation of the objects used
+// as fixtures. A builder starts from valid defaults and exposes fluent With... methods, so a
+// check only spells out the fields it cares about. An Object Mother names the common cases
+// ("a product out of stock", "a declined payment") on top of the builders.
+//
+// Key benefits of Test Data Builders:
+// - Fixtures are short and show only what matters for the case under test
+// - Adding a field to a domain type means updating one builder, not every fixture
+// - Defaults are always valid, so invalid objects are created on purpose only
+// - The same vocabulary is shared by every test in the course
+//
+// Common use cases:
+// - Table-driven tests that need many slightly different objects
+// - Seeding in-memory repositories and fakes
+// - Demos that need realistic data without repeating literals
+//
+// In this example, we implement builders for the domain objects used across the pattern modules:
+// 1. ProductBuilder for the products of Repository, Specification and UnitOfWork
+// 2. PaymentBuilder for the charges of the Adapter example
+// 3. ObserverBuilder for recording observers like the observers of pkg/observer
+// 4. An Object Mother with named, ready to use fixtures
+// The builders and the mother live in pkg/objectmother, so the tests of the other modules
+// import them instead of repeating literals; this program shows how they read.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/Arcanm/go_advanced_course/pkg/objectmother"
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// inStock is a tiny piece of logic to exercise with the fixtures
+func inStock(products []repository.Product) []repository.Product {
+	var result []repository.Product
+	for _, p := range products {
+		if p.Stock > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// validatePayment rejects the payments the providers would reject
+func validatePayment(p objectmother.Payment) error {
+	switch {
+	case p.Amount <= 0:
+		return fmt.Errorf("invalid amount %d", p.Amount)
+	case len(p.Currency) != 3:
+		return fmt.Errorf("invalid currency %q", p.Currency)
+	}
+	return nil
+}
+
+func main() {
+	// Each case only mentions what makes it different
+	products := []repository.Product{
+		objectmother.AProduct().Build(),
+		objectmother.AnOutOfStockProduct().WithID(2).Build(),
+		objectmother.ACheapProduct().WithID(3).Build(),
+	}
+	fmt.Println("In stock:")
+	for _, p := range inStock(products) {
+		fmt.Printf("  #%d %s $%d (stock %d)\n", p.ID, p.Name, p.Price, p.Stock)
+	}
+
+	cases := []struct {
+		name    string
+		payment objectmother.Payment
+		valid   bool
+	}{
+		{"valid payment", objectmother.AValidPayment().Build(), true},
+		{"declined card is still well formed", objectmother.ADeclinedPayment().Build(), true},
+		{"zero amount", objectmother.AnInvalidPayment().Build(), false},
+		{"bad currency", objectmother.AValidPayment().WithCurrency("DOLLARS").Build(), false},
+	}
+	for _, c := range cases {
+		err := validatePayment(c.payment)
+		status := "OK"
+		if (err == nil) != c.valid {
+			status = "FAIL"
+		}
+		fmt.Printf("%-4s %s (err: %v)\n", status, c.name, err)
+	}
+
+	// Builders return new observers every time
+	ok, broken := objectmother.AnEmailObserver().Build(), objectmother.ABrokenObserver().Build()
+	for _, o := range []*objectmother.RecordingObserver{ok, broken} {
+		err := o.Update("price changed")
+		fmt.Printf("Observer %s received %d events (err: %v)\n", o.ID(), len(o.Events()), err)
+	}
+
+	fmt.Printf("Catalog fixture: %+v\n", objectmother.Catalog(3))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/objectmother"
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// TestInStock filters fixtures built by the mother
+func TestInStock(t *testing.T) {
+	products := []repository.Product{
+		objectmother.AProduct().Build(),
+		objectmother.AnOutOfStockProduct().WithID(2).Build(),
+		objectmother.ACheapProduct().WithID(3).Build(),
+	}
+	var got []int64
+	for _, p := range inStock(products) {
+		got = append(got, p.ID)
+	}
+	if want := []int64{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestValidatePayment runs the payment cases of main as a table
+func TestValidatePayment(t *testing.T) {
+	cases := []struct {
+		name    string
+		payment objectmother.Payment
+		valid   bool
+	}{
+		{name: "valid payment", payment: objectmother.AValidPayment().Build(), valid: true},
+		{name: "declined card is still well formed", payment: objectmother.ADeclinedPayment().Build(), valid: true},
+		{name: "zero amount", payment: objectmother.AnInvalidPayment().Build()},
+		{name: "negative amount", payment: objectmother.AValidPayment().WithAmount(-1).Build()},
+		{name: "bad currency", payment: objectmother.AValidPayment().WithCurrency("DOLLARS").Build()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := validatePayment(c.payment); (err == nil) != c.valid {
+				t.Errorf("got %v, want valid %t", err, c.valid)
+			}
+		})
+	}
+}
+
+// TestBuilders checks the defaults of the builders and that the built objects are not shared
+func TestBuilders(t *testing.T) {
+	builder := objectmother.AProduct()
+	first := builder.Build()
+	second := builder.WithStock(0).Build()
+	if first.Stock != 10 || second.Stock != 0 {
+		t.Errorf("a built product changed with its builder: %+v %+v", first, second)
+	}
+
+	ok, broken := objectmother.AnEmailObserver().Build(), objectmother.ABrokenObserver().Build()
+	if err := ok.Update("price changed"); err != nil || len(ok.Events()) != 1 {
+		t.Errorf("got %v with %d events", err, len(ok.Events()))
+	}
+	if err := broken.Update("price changed"); err == nil {
+		t.Error("the broken observer accepted the event")
+	}
+	if objectmother.AnEmailObserver().Build() == ok {
+		t.Error("the builder returned a shared observer")
+	}
+
+	catalog := objectmother.Catalog(3)
+	for i, p := range catalog {
+		if p.ID != int64(i+1) || p.Price != 100*(i+1) {
+			t.Errorf("product %d is %+v", i, p)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// EmailTemplate renders the subject and body of the email for an event
+type EmailTemplate struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// NewEmailTemplate parses a subject and a body template; both receive the ItemEvent
+func NewEmailTemplate(subject, body string) EmailTemplate {
+	return EmailTemplate{
+		Subject: template.Must(template.New("subject").Parse(subject)),
+		Body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// Render executes both templates with the event
+func (t EmailTemplate) Render(event ItemEvent) (subject, body string, err error) {
+	var s, b strings.Builder
+	if err := t.Subject.Execute(&s, event); err != nil {
+		return "", "", err
+	}
+	if err := t.Body.Execute(&b, event); err != nil {
+		return "", "", err
+	}
+	return s.String(), b.String(), nil
+}
+
+// DefaultTemplates has one template per kind of event
+var DefaultTemplates = map[EventKind]EmailTemplate{
+	EventAvailability: NewEmailTemplate(
+		"{{.Name}} is back in stock",
+		"Good news!\n\n{{.Name}} is available again for ${{.Price}}.\n",
+	),
+	EventPriceChange: NewEmailTemplate(
+		"New price for {{.Name}}",
+		"The price of {{.Name}} changed from ${{.OldPrice}} to ${{.Price}}.\n",
+	),
+	EventDiscount: NewEmailTemplate(
+		"{{.Discount}}% off {{.Name}}",
+		"{{.Name}} has a {{.Discount}}% discount: ${{.OldPrice}} -> ${{.Price}}.\n",
+	),
+}
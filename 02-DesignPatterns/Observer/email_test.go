@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/notify"
+)
+
+// TestEmail checks the rendered emails with a MockSender
+func TestEmail(t *testing.T) {
+	mock := &notify.MockSender{}
+	item := NewItem("Email item")
+	item.Register(NewEmailClient("buyer@test.com", "shop@test.com", mock))
+	item.UpdateAvailable()
+	item.ApplyDiscount(50)
+
+	messages := mock.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("got %d emails, want 2", len(messages))
+	}
+	if got := messages[1].Subject; got != "50% off Email item" {
+		t.Errorf("got subject %q", got)
+	}
+	if got := messages[1].Body; !strings.Contains(got, "$100 -> $50") {
+		t.Errorf("got body %q", got)
+	}
+
+	// A failing sender makes the notification fail, so the dispatcher retries it
+	failing := &notify.MockSender{Err: errors.New("smtp down")}
+	item = NewItem("Failing item")
+	item.Register(NewEmailClient("buyer@test.com", "shop@test.com", failing))
+	if err := item.UpdateAvailable(); err == nil || len(failing.Messages()) != 3 {
+		t.Errorf("got %v after %d attempts, want an error after 3", err, len(failing.Messages()))
+	}
+
+	// Header injection is rejected before connecting
+	if _, err := (notify.Message{From: "a@test.com", To: []string{"b@test.com\r\nBcc: c@test.com"}}).Bytes(); err == nil {
+		t.Error("a newline in a header was accepted")
+	}
+}
+
+// TestSMTPSender runs a fake server speaking just enough SMTP and checks what it received
+func TestSMTPSender(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+		reply("220 fake ESMTP")
+		var transcript strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 fake")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				transcript.WriteString(line)
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := reader.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					transcript.WriteString(data)
+				}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	sender := &notify.SMTPSender{Host: "127.0.0.1", Port: port}
+	err = sender.Send(context.Background(), notify.Message{From: "shop@test.com", To: []string{"buyer@test.com"}, Subject: "Precio rebajado ¡50%!", Body: "Hola\nAdiós\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript := <-received
+	for _, want := range []string{
+		"MAIL FROM:<shop@test.com>",
+		"RCPT TO:<buyer@test.com>",
+		"Subject: =?utf-8?q?Precio_rebajado_=C2=A150%!?=",
+		"Adi=C3=B3s",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("the SMTP server did not receive %q in:\n%s", want, transcript)
+		}
+	}
+
+	// Without STARTTLS the sender refuses to continue when TLS is required
+	sender.RequireTLS = true
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "220 fake\r\n")
+		bufio.NewReader(conn).ReadString('\n')
+		fmt.Fprint(conn, "250 fake\r\n")
+		bufio.NewReader(conn).ReadString('\n')
+	}()
+	if err := sender.Send(context.Background(), notify.Message{From: "shop@test.com", To: []string{"buyer@test.com"}}); !errors.Is(err, notify.ErrTLSRequired) {
+		t.Errorf("got %v, want ErrTLSRequired", err)
+	}
+}
+
+// TestSMTPSenderContext checks that a server that never answers is abandoned when the
+// context of the dispatcher expires, instead of after the timeout of the sender
+func TestSMTPSenderContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Accepts the connection but never sends the greeting
+		bufio.NewReader(conn).ReadString('\n')
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	sender := &notify.SMTPSender{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, Timeout: time.Minute}
+	start := time.Now()
+	if err := sender.Send(ctx, notify.Message{From: "shop@test.com", To: []string{"buyer@test.com"}}); err == nil {
+		t.Fatal("the send succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the send took %v, want it to stop at the 50ms deadline", elapsed)
+	}
+}
@@ -3,107 +3,368 @@
 //
 // In this example, we implement a product availability notification system where:
 // 1. We have a Topic (Item) that can be observed
-// 2. We have Observers (EmailClient) that subscribe to receive updates
+// 2. We have Observers (EmailClient, SmsClient) that subscribe to receive updates
 // 3. When the Item becomes available, it notifies all its observers
 // 4. The observers receive the notification and execute their logic (send email)
+//
+// Topic and Observer are generic over the event type E, so observers receive a
+// structured payload (ItemEvent) instead of just the item name.
+//
+// Observers are notified concurrently by a Dispatcher (see pkg/observer) that uses a
+// worker pool, a timeout per observer, retries, and keeps failed notifications as dead letters.
+//
+// The Item emits several kinds of events (availability, price change, discount) and observers
+// can subscribe only to the ones they care about with filters (see pkg/observer).
+//
+// EmailClient renders a template per kind of event (see email.go) and delivers it through a
+// Sender of pkg/notify: printed to the console by default, or sent by an SMTP server with
+// --smtp-host. SmsClient does the same with an SMSProvider and an HTTP gateway with --sms-url.
+// WebhookClient posts the events as JSON with any httpclient.Doer; --webhook-url uses the
+// client of pkg/httpclient, with its timeouts and circuit breaker.
+// RUN PROGRAM WITH FLAGS
+// SMTP_PASSWORD=secret go run . --smtp-host=smtp.example.com --smtp-port=587 --smtp-user=me@example.com --smtp-from=shop@example.com
+// SMS_TOKEN=secret go run . --sms-url=https://api.example.com --sms-account=AC123 --sms-from=+15550001111
+// go run . --webhook-url=https://example.com/hooks/stock
 
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
-// Topic defines the interface for objects that can be observed
-type Topic interface {
-	// Register adds a new observer to receive updates
-	Register(observer Observer)
-	// Broadcast notifies all registered observers
-	Broadcast()
+	"github.com/Arcanm/go_advanced_course/pkg/httpclient"
+	"github.com/Arcanm/go_advanced_course/pkg/notify"
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// EventKind identifies what changed in an Item
+type EventKind string
+
+const (
+	EventAvailability EventKind = "availability"
+	EventPriceChange  EventKind = "price-change"
+	EventDiscount     EventKind = "discount"
+)
+
+// ItemEvent is the payload sent to the observers of an Item
+type ItemEvent struct {
+	Kind      EventKind // What changed
+	Name      string    // Product name
+	Price     int       // Product price
+	OldPrice  int       // Price before a price change or discount
+	Discount  int       // Discount percentage, only for EventDiscount
+	Available bool      // Whether the product can be bought
 }
 
-// Observer defines the interface for objects that want to receive updates
-type Observer interface {
-	// getId returns the unique identifier of the observer
-	getId() string
-	// updateValue receives updates from the Topic
-	updateValue(string)
+// String describes the event for the notification messages
+func (e ItemEvent) String() string {
+	switch e.Kind {
+	case EventPriceChange:
+		return fmt.Sprintf("%s price changed from $%d to $%d", e.Name, e.OldPrice, e.Price)
+	case EventDiscount:
+		return fmt.Sprintf("%s has a %d%% discount, now $%d", e.Name, e.Discount, e.Price)
+	default:
+		return fmt.Sprintf("%s is now available for $%d", e.Name, e.Price)
+	}
+}
+
+// ForKinds subscribes an observer only to the given kinds of item events
+func ForKinds(kinds ...EventKind) observer.SubscribeOption[ItemEvent] {
+	return observer.WithFilter(func(event ItemEvent) bool {
+		for _, kind := range kinds {
+			if event.Kind == kind {
+				return true
+			}
+		}
+		return false
+	})
 }
 
 // Item represents a product that can be available or not
-// Implements the Topic interface to be observable
+// Implements the Topic[ItemEvent] interface to be observable
 type Item struct {
-	observers []Observer // List of subscribed observers
-	name      string     // Product name
-	price     int        // Product price
+	subscriptions []observer.Subscription[ItemEvent] // List of subscribed observers and their filters
+	dispatcher    *observer.Dispatcher[ItemEvent]    // Delivers the events to the observers
+	name          string                             // Product name
+	price         int                                // Product price
+	available     bool                               // Product availability
 }
 
 // NewItem creates a new Item instance with the specified name
+// Observers are notified by 4 workers, with a 500ms timeout and 2 retries
 func NewItem(name string) *Item {
 	return &Item{
-		name: name,
+		name:       name,
+		dispatcher: observer.NewDispatcher[ItemEvent](4, 500*time.Millisecond, 2),
 	}
 }
 
 // UpdateAvailable marks the item as available, updates its price and notifies observers
-func (i *Item) UpdateAvailable() {
+func (i *Item) UpdateAvailable() error {
 	fmt.Printf("The item %s is now available\n", i.name)
 	i.price = 100
-	i.Broadcast()
+	i.available = true
+	return i.Broadcast(i.event(EventAvailability))
+}
+
+// UpdatePrice changes the price and notifies the observers interested in price changes
+func (i *Item) UpdatePrice(price int) error {
+	event := i.event(EventPriceChange)
+	i.price = price
+	event.Price = price
+	return i.Broadcast(event)
+}
+
+// ApplyDiscount lowers the price by a percentage and notifies the observers interested in discounts
+func (i *Item) ApplyDiscount(percent int) error {
+	event := i.event(EventDiscount)
+	i.price -= i.price * percent / 100
+	event.Price = i.price
+	event.Discount = percent
+	return i.Broadcast(event)
+}
+
+// event builds the payload describing the current state of the item
+func (i *Item) event(kind EventKind) ItemEvent {
+	return ItemEvent{Kind: kind, Name: i.name, Price: i.price, OldPrice: i.price, Available: i.available}
 }
 
 // Register adds a new observer to the item's list of observers
-func (i *Item) Register(observer Observer) {
-	i.observers = append(i.observers, observer)
+func (i *Item) Register(o observer.Observer[ItemEvent], options ...observer.SubscribeOption[ItemEvent]) {
+	i.subscriptions = append(i.subscriptions, observer.NewSubscription(o, options))
 }
 
-// Broadcast notifies all registered observers about changes in the item
-func (i *Item) Broadcast() {
-	for _, observer := range i.observers {
-		observer.updateValue(i.name)
-	}
+// Broadcast notifies the registered observers whose filters accept the event
+func (i *Item) Broadcast(event ItemEvent) error {
+	return i.dispatcher.Dispatch(observer.Matching(i.subscriptions, event), event)
+}
+
+// DeadLetters returns the notifications that failed after every retry
+func (i *Item) DeadLetters() []observer.DeadLetter[ItemEvent] {
+	return i.dispatcher.DeadLetters()
 }
 
 // EmailClient represents a client that will receive email notifications
-// Implements the Observer interface
+// Implements the Observer[ItemEvent] interface
 type EmailClient struct {
-	id string // Client's email
+	id        string                      // Client's email
+	sender    notify.Sender               // How the email is delivered, ConsoleSender when nil
+	from      string                      // Sender address
+	templates map[EventKind]EmailTemplate // DefaultTemplates when nil
 }
 
-// updateValue implements the email notification logic when an item becomes available
-func (e *EmailClient) updateValue(itemName string) {
-	fmt.Printf("Sending email - %s is now available for client %s\n", itemName, e.id)
+// NewEmailClient creates a client whose notifications are delivered by sender
+func NewEmailClient(address, from string, sender notify.Sender) *EmailClient {
+	return &EmailClient{id: address, sender: sender, from: from}
+}
+
+// UpdateValue renders the template of the event and sends the email
+func (e *EmailClient) UpdateValue(ctx context.Context, event ItemEvent) error {
+	if !strings.Contains(e.id, "@") {
+		return fmt.Errorf("invalid email address %q", e.id)
+	}
+	templates := e.templates
+	if templates == nil {
+		templates = DefaultTemplates
+	}
+	tmpl, exists := templates[event.Kind]
+	if !exists {
+		return fmt.Errorf("no email template for %s events", event.Kind)
+	}
+	subject, body, err := tmpl.Render(event)
+	if err != nil {
+		return err
+	}
+
+	var sender notify.Sender = notify.ConsoleSender{}
+	if e.sender != nil {
+		sender = e.sender
+	}
+	return sender.Send(ctx, notify.Message{From: e.from, To: []string{e.id}, Subject: subject, Body: body})
 }
 
-// getId returns the client's email
-func (e EmailClient) getId() string {
+// GetId returns the client's email
+func (e EmailClient) GetId() string {
 	return e.id
 }
 
 // SmsClient represents a client that will receive SMS notifications
-// Implements the Observer interface
+// Implements the Observer[ItemEvent] interface
 type SmsClient struct {
-	id string // Client's phone number
+	id       string             // Client's phone number
+	from     string             // Sender number
+	provider notify.SMSProvider // How the SMS is delivered, ConsoleSMS when nil
 }
 
-// updateValue implements the SMS notification logic when an item becomes available
-func (s *SmsClient) updateValue(itemName string) {
-	fmt.Printf("Sending SMS - %s is now available for client %s\n", itemName, s.id)
+// NewSmsClient creates a client whose notifications are delivered by provider
+func NewSmsClient(number, from string, provider notify.SMSProvider) *SmsClient {
+	return &SmsClient{id: number, from: from, provider: provider}
 }
 
-// getId returns the client's phone number
-func (s SmsClient) getId() string {
+// UpdateValue implements the SMS notification logic when an item changes
+func (s *SmsClient) UpdateValue(ctx context.Context, event ItemEvent) error {
+	if !strings.HasPrefix(s.id, "+") {
+		return fmt.Errorf("phone number %q must include the country code", s.id)
+	}
+	var provider notify.SMSProvider = notify.ConsoleSMS{}
+	if s.provider != nil {
+		provider = s.provider
+	}
+	return provider.SendSMS(ctx, s.from, s.id, event.String())
+}
+
+// WebhookClient represents a client notified through a slow HTTP callback
+// Implements the Observer[ItemEvent] interface
+type WebhookClient struct {
+	id      string          // Callback URL
+	latency time.Duration   // Simulated response time of the remote server
+	client  httpclient.Doer // Posts the event to the URL, nil only simulates the call
+}
+
+// NewWebhookClient creates a client that posts every event as JSON to url
+func NewWebhookClient(url string, client httpclient.Doer) *WebhookClient {
+	return &WebhookClient{id: url, client: client}
+}
+
+// UpdateValue calls the webhook, which may exceed the dispatcher timeout: the request
+// is canceled with ctx
+func (w *WebhookClient) UpdateValue(ctx context.Context, event ItemEvent) error {
+	if w.client == nil {
+		select {
+		case <-time.After(w.latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		fmt.Printf("Calling webhook - %s for client %s\n", event, w.id)
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.id, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The dispatcher retries failed notifications, the key lets the receiver drop duplicates
+	key := sha256.Sum256(append([]byte(w.id), body...))
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(key[:16]))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", w.id, resp.Status)
+	}
+	return nil
+}
+
+// GetId returns the callback URL
+func (w WebhookClient) GetId() string {
+	return w.id
+}
+
+// GetId returns the client's phone number
+func (s SmsClient) GetId() string {
 	return s.id
 }
 
+// SMTP configuration, the password is read from the SMTP_PASSWORD environment variable
+// so it doesn't end up in the shell history
+var (
+	smtpHost = flag.String("smtp-host", "", "SMTP server, empty prints the emails instead of sending them")
+	smtpPort = flag.Int("smtp-port", 587, "SMTP port")
+	smtpUser = flag.String("smtp-user", "", "SMTP user, empty disables authentication")
+	smtpFrom = flag.String("smtp-from", "shop@test.com", "sender address of the notifications")
+)
+
+// SMS gateway configuration, the token is read from the SMS_TOKEN environment variable
+var (
+	smsURL     = flag.String("sms-url", "", "base URL of the SMS gateway, empty prints the messages")
+	smsAccount = flag.String("sms-account", "", "account SID of the SMS gateway")
+	smsFrom    = flag.String("sms-from", "+15550001111", "sender number of the SMS notifications")
+)
+
+// Callback URL posted every event, empty skips the webhook
+var webhookURL = flag.String("webhook-url", "", "URL the events are posted to as JSON, empty skips the webhook")
+
 func main() {
+	flag.Parse()
+
+	var emailSender notify.Sender = notify.ConsoleSender{}
+	if *smtpHost != "" {
+		emailSender = &notify.SMTPSender{
+			Host:       *smtpHost,
+			Port:       *smtpPort,
+			Username:   *smtpUser,
+			Password:   os.Getenv("SMTP_PASSWORD"),
+			RequireTLS: true,
+		}
+	}
+
+	var smsProvider notify.SMSProvider = notify.ConsoleSMS{}
+	if *smsURL != "" {
+		smsProvider = notify.NewHTTPSMSProvider(*smsURL, *smsAccount, os.Getenv("SMS_TOKEN"))
+	}
+
 	// Example of Observer pattern usage
 	item := NewItem("RTX 5090")
-	emailClient := &EmailClient{id: "test@test.com"}
-	secondEmailClient := &EmailClient{id: "test2@test.com"}
-	smsClient := &SmsClient{id: "+525555555555"}
-	// Register two clients to receive notifications
+	emailClient := NewEmailClient("test@test.com", *smtpFrom, emailSender)
+	secondEmailClient := NewEmailClient("test2@test.com", *smtpFrom, emailSender)
+	smsClient := NewSmsClient("+525555555555", *smsFrom, smsProvider)
+	// Register two clients to receive every notification
 	item.Register(emailClient)
 	item.Register(secondEmailClient)
-	// Register a client to receive SMS notifications
-	item.Register(smsClient)
-	// Update item availability, which will notify both clients
-	item.UpdateAvailable()
+	// Register a client to receive SMS notifications only about availability and discounts
+	item.Register(smsClient, ForKinds(EventAvailability, EventDiscount))
+	// Register clients that will fail: a bad phone number and a webhook slower than the timeout
+	item.Register(NewSmsClient("5555555555", *smsFrom, smsProvider), ForKinds(EventAvailability))
+	item.Register(&WebhookClient{id: "https://example.com/hook", latency: time.Second}, ForKinds(EventAvailability))
+	// Register a client that only wants to know about big discounts
+	item.Register(NewEmailClient("bargains@test.com", *smtpFrom, emailSender), ForKinds(EventDiscount), observer.WithFilter(func(event ItemEvent) bool {
+		return event.Discount >= 20
+	}))
+	// A real webhook goes through the hardened client, which gives up before the timeout of
+	// the dispatcher; the dispatcher retries the notification, so the client doesn't
+	if *webhookURL != "" {
+		client := httpclient.New(httpclient.Options{
+			Timeout: 400 * time.Millisecond,
+			Retries: -1,
+			Breaker: &httpclient.BreakerOptions{Threshold: 3, Cooldown: 5 * time.Second},
+		})
+		item.Register(NewWebhookClient(*webhookURL, client))
+	}
+	// Update item availability, which will notify all clients concurrently
+	if err := item.UpdateAvailable(); err != nil {
+		fmt.Printf("Some notifications failed:\n%v\n", err)
+	}
+	// Failed notifications are kept after all retries for later inspection
+	for _, letter := range item.DeadLetters() {
+		timedOut := errors.Is(letter.Err, observer.ErrObserverTimeout)
+		fmt.Printf("Dead letter for %s (timeout: %t)\n", letter.ObserverID, timedOut)
+	}
+
+	// Price changes only reach the email clients subscribed to every event
+	fmt.Println("Changing the price")
+	item.UpdatePrice(120)
+	// Small discounts don't reach the bargain hunter, big ones do
+	fmt.Println("Applying a 10% discount")
+	item.ApplyDiscount(10)
+	fmt.Println("Applying a 25% discount")
+	item.ApplyDiscount(25)
 }
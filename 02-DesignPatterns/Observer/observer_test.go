@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// countingObserver records the events it receives so the notifications can be checked
+type countingObserver struct {
+	id     string
+	fail   bool
+	events []ItemEvent
+	mux    sync.Mutex
+}
+
+func (c *countingObserver) UpdateValue(_ context.Context, event ItemEvent) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.events = append(c.events, event)
+	if c.fail {
+		return errors.New("delivery failed")
+	}
+	return nil
+}
+
+func (c *countingObserver) GetId() string {
+	return c.id
+}
+
+func (c *countingObserver) count() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.events)
+}
+
+// TestNotifications checks how many notifications every kind of subscription receives.
+// The dispatcher retries failing observers twice, so they are called three times per event.
+func TestNotifications(t *testing.T) {
+	item := NewItem("Verification item")
+	all := &countingObserver{id: "all"}
+	prices := &countingObserver{id: "prices"}
+	bigDiscounts := &countingObserver{id: "big-discounts"}
+	failing := &countingObserver{id: "failing", fail: true}
+
+	item.Register(all)
+	item.Register(prices, ForKinds(EventPriceChange))
+	item.Register(bigDiscounts, ForKinds(EventDiscount), observer.WithFilter(func(e ItemEvent) bool { return e.Discount >= 20 }))
+	item.Register(failing, ForKinds(EventAvailability))
+
+	if err := item.UpdateAvailable(); err == nil {
+		t.Fatal("expected UpdateAvailable to report the failing observer")
+	}
+	item.UpdatePrice(200)
+	item.ApplyDiscount(10)
+	item.ApplyDiscount(50)
+
+	cases := []struct {
+		observer *countingObserver
+		want     int
+	}{
+		{all, 4},
+		{prices, 1},
+		{bigDiscounts, 1},
+		{failing, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.observer.id, func(t *testing.T) {
+			if got := c.observer.count(); got != c.want {
+				t.Errorf("received %d notifications, want %d", got, c.want)
+			}
+		})
+	}
+
+	if letters := item.DeadLetters(); len(letters) != 1 || letters[0].ObserverID != "failing" {
+		t.Errorf("got dead letters %+v, want one for the failing observer", letters)
+	}
+	if event := prices.events[0]; event.OldPrice != 100 || event.Price != 200 {
+		t.Errorf("price change event %+v, want 100 -> 200", event)
+	}
+}
+
+// TestWebhook posts the events to a local server that fails the first call;
+// the retry of the dispatcher must carry the same Idempotency-Key
+func TestWebhook(t *testing.T) {
+	var keys, bodies []string
+	var mux sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mux.Lock()
+		defer mux.Unlock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(body))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	item := NewItem("Webhook item")
+	item.Register(NewWebhookClient(server.URL, server.Client()))
+	if err := item.UpdateAvailable(); err != nil {
+		t.Fatalf("webhook failed after retries: %v", err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("got idempotency keys %q, want the same key twice", keys)
+	}
+	if !strings.Contains(bodies[1], `"Name":"Webhook item"`) {
+		t.Errorf("got body %s", bodies[1])
+	}
+}
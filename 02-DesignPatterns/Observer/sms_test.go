@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/notify"
+)
+
+// TestSMS checks SmsClient with a FakeSMSProvider
+func TestSMS(t *testing.T) {
+	fake := &notify.FakeSMSProvider{}
+	item := NewItem("SMS item")
+	item.Register(NewSmsClient("+525555555555", "+15550001111", fake))
+	item.UpdateAvailable()
+
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].To != "+525555555555" || !strings.Contains(sent[0].Body, "SMS item") {
+		t.Errorf("got %+v", sent)
+	}
+}
+
+// mockGateway is a Twilio-style gateway checking auth and signatures;
+// it answers the queued statuses first, then 201
+type mockGateway struct {
+	sid, token string
+	statuses   []int
+	requests   []string // Body of every accepted message
+	mux        sync.Mutex
+}
+
+func (g *mockGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sid, token, ok := r.BasicAuth()
+	if !ok || sid != g.sid || token != g.token {
+		writeGatewayError(w, http.StatusUnauthorized, 20003, "authentication failed")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeGatewayError(w, http.StatusBadRequest, 21100, "invalid form")
+		return
+	}
+	endpoint := "http://" + r.Host + r.URL.Path
+	if !notify.VerifySignature(g.token, endpoint, r.Header.Get("X-Timestamp"), r.Header.Get("X-Signature"), r.PostForm, time.Minute) {
+		writeGatewayError(w, http.StatusForbidden, 20004, "invalid signature")
+		return
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if len(g.statuses) > 0 {
+		status := g.statuses[0]
+		g.statuses = g.statuses[1:]
+		writeGatewayError(w, status, 0, http.StatusText(status))
+		return
+	}
+	g.requests = append(g.requests, r.PostForm.Get("Body"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"sid":"SM%d","status":"queued"}`, len(g.requests))
+}
+
+func writeGatewayError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"status":%d,"code":%d,"message":%q}`, status, code, message)
+}
+
+// TestHTTPSMSProvider sends through HTTPSMSProvider to a mock gateway running in-process
+func TestHTTPSMSProvider(t *testing.T) {
+	gateway := &mockGateway{sid: "AC123", token: "secret"}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	// Two unavailable answers are retried by the provider, the third attempt succeeds
+	gateway.statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	provider := notify.NewHTTPSMSProvider(server.URL, "AC123", "secret")
+	item := NewItem("Gateway item")
+	item.Register(NewSmsClient("+525555555555", "+15550001111", provider))
+	if err := item.UpdateAvailable(); err != nil {
+		t.Fatalf("retried send failed: %v", err)
+	}
+	if len(gateway.requests) != 1 || !strings.Contains(gateway.requests[0], "Gateway item") {
+		t.Fatalf("gateway received %q", gateway.requests)
+	}
+
+	// Client errors are not retried
+	gateway.statuses = []int{http.StatusBadRequest}
+	var gatewayErr *notify.GatewayError
+	err := provider.SendSMS(context.Background(), "+15550001111", "+525555555555", "hello")
+	if !errors.As(err, &gatewayErr) || gatewayErr.Status != http.StatusBadRequest || errors.Is(err, notify.ErrTemporary) {
+		t.Errorf("got %v, want a permanent 400", err)
+	}
+	if len(gateway.statuses) != 0 || len(gateway.requests) != 1 {
+		t.Error("the 400 answer was retried")
+	}
+
+	// A wrong token breaks both the basic auth and the signature
+	wrong := notify.NewHTTPSMSProvider(server.URL, "AC123", "wrong")
+	if err := wrong.SendSMS(context.Background(), "+15550001111", "+525555555555", "hello"); !errors.As(err, &gatewayErr) || gatewayErr.Status != http.StatusUnauthorized {
+		t.Errorf("got %v with a wrong token, want 401", err)
+	}
+
+	// A done context stops the retries instead of waiting out the backoff
+	gateway.statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	slow := notify.NewHTTPSMSProvider(server.URL, "AC123", "secret")
+	slow.Retry.Backoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := slow.SendSMS(ctx, "+15550001111", "+525555555555", "hello"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline of the context", err)
+	}
+	if len(gateway.statuses) != 1 {
+		t.Errorf("%d attempts left unanswered, want 1 after the context expired", len(gateway.statuses))
+	}
+	gateway.statuses = nil
+
+	// A tampered body doesn't match the signature
+	form := map[string][]string{"Body": {"hello"}}
+	signature := notify.Sign("secret", server.URL, "1", form)
+	form["Body"] = []string{"tampered"}
+	if notify.VerifySignature("secret", server.URL, "1", signature, form, 100*365*24*time.Hour) {
+		t.Error("a tampered message kept a valid signature")
+	}
+}
@@ -0,0 +1,306 @@
+// The Pipeline Pattern processes data through a sequence of independent stages, where the
+// output of one stage is the input of the next. Each stage does one thing (validate, enrich,
+// persist...) and knows nothing about the others, so stages can be reordered, replaced or
+// reused in other pipelines.
+//
+// Key benefits of the Pipeline Pattern:
+// - Each stage is small, focused and easy to test on its own
+// - The order of the stages is configuration, not code
+// - Error handling can be decided per stage instead of with one global rule
+// - New stages (auditing, metrics, deduplication) are added without touching the others
+//
+// Common use cases:
+// - Import jobs: parse -> validate -> transform -> store
+// - Request processing in servers and message consumers
+// - Data enrichment and ETL processes
+//
+// Error policies: not every failure means the same thing. An invalid order should be
+// skipped, a flaky remote lookup deserves a retry, a failing database should stop the
+// whole run, and an optional stage (like sending a notification) can fail without
+// stopping the item. Each stage is added with the policy that fits it:
+// - Abort: stop the pipeline and return the error
+// - Skip: drop the current item and continue with the next one
+// - Retry(n, then): retry the stage n times, then apply the fallback policy
+// - Continue: record the error and pass the item to the next stage anyway
+//
+// In this example, we implement an order import where:
+// 1. Every stage implements the Stage interface
+// 2. The Pipeline runs each order through the stages in the configured order
+// 3. The stages are selected and ordered with the --stages flag
+// 4. The final Report lists processed, skipped and failed items with the stage that failed
+// RUN PROGRAM WITH FLAGS
+// go run . --stages=validate,enrich,notify,persist
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Order is the item flowing through the pipeline
+type Order struct {
+	ID       string
+	Customer string
+	SKU      string
+	Quantity int
+	Price    int // Filled by the enrich stage
+	Total    int // Filled by the enrich stage
+}
+
+// Stage is a single step of the pipeline
+type Stage interface {
+	// Name identifies the stage in the configuration and in the report
+	Name() string
+	// Process works on the item in place and returns an error if it can't
+	Process(ctx context.Context, order *Order) error
+}
+
+// PolicyKind is the action taken when a stage fails
+type PolicyKind int
+
+const (
+	Abort PolicyKind = iota
+	Skip
+	Continue
+)
+
+// ErrorPolicy decides what happens when a stage returns an error
+type ErrorPolicy struct {
+	Kind    PolicyKind
+	Retries int // Extra attempts before applying Kind
+}
+
+// Policies used when building the pipeline
+var (
+	AbortOnError    = ErrorPolicy{Kind: Abort}
+	SkipOnError     = ErrorPolicy{Kind: Skip}
+	ContinueOnError = ErrorPolicy{Kind: Continue}
+)
+
+// Retry retries the stage n times and then applies the fallback policy
+func Retry(n int, then PolicyKind) ErrorPolicy {
+	return ErrorPolicy{Kind: then, Retries: n}
+}
+
+// StageError records which stage failed for which item
+type StageError struct {
+	Stage   string
+	OrderID string
+	Err     error
+}
+
+func (e StageError) Error() string {
+	return fmt.Sprintf("stage %s, order %s: %v", e.Stage, e.OrderID, e.Err)
+}
+
+func (e StageError) Unwrap() error {
+	return e.Err
+}
+
+// Report summarizes a pipeline run
+type Report struct {
+	Processed []string     // Orders that went through every stage
+	Skipped   []string     // Orders dropped by a Skip policy
+	Errors    []StageError // Every error, including the ones tolerated by Continue
+}
+
+// step is a stage with its error policy
+type step struct {
+	stage  Stage
+	policy ErrorPolicy
+}
+
+// Pipeline runs items through an ordered list of stages
+type Pipeline struct {
+	steps []step
+}
+
+// NewPipeline creates an empty pipeline
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Add appends a stage with its error policy and returns the pipeline for chaining
+func (p *Pipeline) Add(stage Stage, policy ErrorPolicy) *Pipeline {
+	p.steps = append(p.steps, step{stage: stage, policy: policy})
+	return p
+}
+
+// Stages returns the names of the stages in execution order
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.steps))
+	for i, s := range p.steps {
+		names[i] = s.stage.Name()
+	}
+	return names
+}
+
+// Run processes every order. It stops early only when an Abort policy is triggered
+// or the context is cancelled; the report contains everything done until then.
+func (p *Pipeline) Run(ctx context.Context, orders []*Order) (Report, error) {
+	var report Report
+
+items:
+	for _, order := range orders {
+		for _, s := range p.steps {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			err := runWithRetries(ctx, s, order)
+			if err == nil {
+				continue
+			}
+
+			stageErr := StageError{Stage: s.stage.Name(), OrderID: order.ID, Err: err}
+			report.Errors = append(report.Errors, stageErr)
+			switch s.policy.Kind {
+			case Abort:
+				return report, stageErr
+			case Skip:
+				report.Skipped = append(report.Skipped, order.ID)
+				continue items
+			case Continue:
+				// The error is recorded, the item goes on to the next stage
+			}
+		}
+		report.Processed = append(report.Processed, order.ID)
+	}
+	return report, nil
+}
+
+// runWithRetries calls the stage once plus the retries allowed by its policy
+func runWithRetries(ctx context.Context, s step, order *Order) error {
+	var err error
+	for attempt := 0; attempt <= s.policy.Retries; attempt++ {
+		if err = s.stage.Process(ctx, order); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// ValidateStage rejects orders with missing or invalid data
+type ValidateStage struct{}
+
+func (ValidateStage) Name() string { return "validate" }
+
+func (ValidateStage) Process(_ context.Context, order *Order) error {
+	switch {
+	case order.Customer == "":
+		return errors.New("customer is required")
+	case order.Quantity <= 0:
+		return fmt.Errorf("invalid quantity %d", order.Quantity)
+	}
+	return nil
+}
+
+// EnrichStage adds the price from a catalog service that fails from time to time
+type EnrichStage struct {
+	catalog  map[string]int
+	failures map[string]int // Remaining simulated failures per SKU
+}
+
+func (EnrichStage) Name() string { return "enrich" }
+
+func (s *EnrichStage) Process(_ context.Context, order *Order) error {
+	if s.failures[order.SKU] > 0 {
+		s.failures[order.SKU]--
+		return errors.New("catalog service unavailable")
+	}
+	price, exists := s.catalog[order.SKU]
+	if !exists {
+		return fmt.Errorf("unknown sku %q", order.SKU)
+	}
+	order.Price = price
+	order.Total = price * order.Quantity
+	return nil
+}
+
+// NotifyStage sends a confirmation; failing to notify must not lose the order
+type NotifyStage struct{}
+
+func (NotifyStage) Name() string { return "notify" }
+
+func (NotifyStage) Process(_ context.Context, order *Order) error {
+	if !strings.Contains(order.Customer, "@") {
+		return fmt.Errorf("can't email %q", order.Customer)
+	}
+	fmt.Printf("  email to %s: order %s total %d\n", order.Customer, order.ID, order.Total)
+	return nil
+}
+
+// PersistStage stores the orders; a duplicate ID means corrupted input, so it aborts
+type PersistStage struct {
+	stored map[string]Order
+}
+
+func (PersistStage) Name() string { return "persist" }
+
+func (s *PersistStage) Process(_ context.Context, order *Order) error {
+	if _, exists := s.stored[order.ID]; exists {
+		return fmt.Errorf("order %s already stored", order.ID)
+	}
+	s.stored[order.ID] = *order
+	return nil
+}
+
+// Order of the stages, configurable from the command line
+var stagesFlag = flag.String("stages", "validate,enrich,notify,persist", "comma separated list of stages to run")
+
+func main() {
+	flag.Parse()
+
+	store := &PersistStage{stored: make(map[string]Order)}
+
+	// Every available stage with the error policy that fits it
+	available := map[string]step{
+		"validate": {ValidateStage{}, SkipOnError},
+		"enrich": {&EnrichStage{
+			catalog:  map[string]int{"laptop": 1200, "desktop": 900},
+			failures: map[string]int{"desktop": 2},
+		}, Retry(2, Skip)},
+		"notify":  {NotifyStage{}, ContinueOnError},
+		"persist": {store, AbortOnError},
+	}
+
+	pipeline := NewPipeline()
+	for _, name := range strings.Split(*stagesFlag, ",") {
+		s, exists := available[strings.TrimSpace(name)]
+		if !exists {
+			fmt.Printf("Unknown stage %q\n", name)
+			os.Exit(1)
+		}
+		pipeline.Add(s.stage, s.policy)
+	}
+	fmt.Println("Stages:", strings.Join(pipeline.Stages(), " -> "))
+
+	orders := []*Order{
+		{ID: "1", Customer: "ana@test.com", SKU: "laptop", Quantity: 1},
+		{ID: "2", Customer: "luis@test.com", SKU: "desktop", Quantity: 2},
+		{ID: "3", Customer: "", SKU: "laptop", Quantity: 1},
+		{ID: "4", Customer: "maria", SKU: "laptop", Quantity: 3},
+		{ID: "5", Customer: "ana@test.com", SKU: "phone", Quantity: 1},
+		{ID: "1", Customer: "ana@test.com", SKU: "laptop", Quantity: 1},
+		{ID: "6", Customer: "luis@test.com", SKU: "laptop", Quantity: 1},
+	}
+
+	report, err := pipeline.Run(context.Background(), orders)
+	fmt.Println("Processed:", report.Processed)
+	fmt.Println("Skipped:", report.Skipped)
+	for _, e := range report.Errors {
+		fmt.Println("Error:", e)
+	}
+	if err != nil {
+		fmt.Println("Pipeline aborted:", err)
+	}
+	fmt.Printf("Stored orders: %d\n", len(store.stored))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// flakyStage fails the first failures calls and counts every call
+type flakyStage struct {
+	failures int
+	calls    int
+}
+
+func (*flakyStage) Name() string { return "flaky" }
+
+func (s *flakyStage) Process(context.Context, *Order) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+// countingStage counts the orders that reached it
+type countingStage struct {
+	seen []string
+}
+
+func (*countingStage) Name() string { return "count" }
+
+func (s *countingStage) Process(_ context.Context, order *Order) error {
+	s.seen = append(s.seen, order.ID)
+	return nil
+}
+
+// TestErrorPolicies runs two orders through a failing stage followed by a counting stage
+func TestErrorPolicies(t *testing.T) {
+	cases := []struct {
+		name          string
+		failures      int
+		policy        ErrorPolicy
+		wantErr       bool
+		wantCalls     int
+		wantProcessed []string
+		wantSkipped   []string
+		wantSeen      []string
+	}{
+		{name: "abort", failures: 1, policy: AbortOnError, wantErr: true, wantCalls: 1},
+		{name: "skip", failures: 1, policy: SkipOnError, wantCalls: 2, wantProcessed: []string{"b"}, wantSkipped: []string{"a"}, wantSeen: []string{"b"}},
+		{name: "continue", failures: 1, policy: ContinueOnError, wantCalls: 2, wantProcessed: []string{"a", "b"}, wantSeen: []string{"a", "b"}},
+		{name: "retry succeeds", failures: 2, policy: Retry(2, Abort), wantCalls: 4, wantProcessed: []string{"a", "b"}, wantSeen: []string{"a", "b"}},
+		{name: "retry then skip", failures: 3, policy: Retry(2, Skip), wantCalls: 4, wantProcessed: []string{"b"}, wantSkipped: []string{"a"}, wantSeen: []string{"b"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			flaky := &flakyStage{failures: c.failures}
+			counter := &countingStage{}
+			pipeline := NewPipeline().Add(flaky, c.policy).Add(counter, AbortOnError)
+
+			report, err := pipeline.Run(context.Background(), []*Order{{ID: "a"}, {ID: "b"}})
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %t", err, c.wantErr)
+			}
+			if flaky.calls != c.wantCalls {
+				t.Errorf("got %d calls, want %d", flaky.calls, c.wantCalls)
+			}
+			if !reflect.DeepEqual(report.Processed, c.wantProcessed) || !reflect.DeepEqual(report.Skipped, c.wantSkipped) {
+				t.Errorf("got processed %v and skipped %v, want %v and %v", report.Processed, report.Skipped, c.wantProcessed, c.wantSkipped)
+			}
+			if !reflect.DeepEqual(counter.seen, c.wantSeen) {
+				t.Errorf("the next stage saw %v, want %v", counter.seen, c.wantSeen)
+			}
+		})
+	}
+}
+
+// TestOrderImport runs the orders of main through the stages of main
+func TestOrderImport(t *testing.T) {
+	store := &PersistStage{stored: make(map[string]Order)}
+	pipeline := NewPipeline().
+		Add(ValidateStage{}, SkipOnError).
+		Add(&EnrichStage{catalog: map[string]int{"laptop": 1200, "desktop": 900}, failures: map[string]int{"desktop": 2}}, Retry(2, Skip)).
+		Add(NotifyStage{}, ContinueOnError).
+		Add(store, AbortOnError)
+
+	orders := []*Order{
+		{ID: "1", Customer: "ana@test.com", SKU: "laptop", Quantity: 1},
+		{ID: "2", Customer: "luis@test.com", SKU: "desktop", Quantity: 2},
+		{ID: "3", Customer: "", SKU: "laptop", Quantity: 1},
+		{ID: "4", Customer: "maria", SKU: "laptop", Quantity: 3},
+		{ID: "5", Customer: "ana@test.com", SKU: "phone", Quantity: 1},
+		{ID: "1", Customer: "ana@test.com", SKU: "laptop", Quantity: 1},
+		{ID: "6", Customer: "luis@test.com", SKU: "laptop", Quantity: 1},
+	}
+	report, err := pipeline.Run(context.Background(), orders)
+
+	var stageErr StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != "persist" || stageErr.OrderID != "1" {
+		t.Fatalf("got %v, want the duplicate order 1 to abort in persist", err)
+	}
+	if want := []string{"1", "2", "4"}; !reflect.DeepEqual(report.Processed, want) {
+		t.Errorf("got processed %v, want %v", report.Processed, want)
+	}
+	if want := []string{"3", "5"}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("got skipped %v, want %v", report.Skipped, want)
+	}
+	var failed []string
+	for _, e := range report.Errors {
+		failed = append(failed, e.Stage+":"+e.OrderID)
+	}
+	if want := []string{"validate:3", "notify:4", "enrich:5", "persist:1"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("got errors %v, want %v", failed, want)
+	}
+	if got := store.stored["2"].Total; got != 1800 {
+		t.Errorf("got total %d for the retried order, want 1800", got)
+	}
+}
+
+// TestRunCancelled checks that a cancelled context stops the run before any stage
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	counter := &countingStage{}
+	if _, err := NewPipeline().Add(counter, AbortOnError).Run(ctx, []*Order{{ID: "a"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(counter.seen) != 0 {
+		t.Errorf("the stage ran for %v", counter.seen)
+	}
+}
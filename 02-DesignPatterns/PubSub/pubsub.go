@@ -0,0 +1,337 @@
+// The Publish/Subscribe Pattern is a messaging pattern where publishers send messages to named
+// topics and subscribers receive the messages of the topics they are interested in. A broker
+// sits in the middle, so publishers and subscribers never know about each other.
+//
+// Pub/Sub vs Observer (see ../Observer):
+// - In the Observer pattern the subject keeps the list of observers and calls them directly;
+//   in Pub/Sub the publisher only knows a topic name and the broker does the routing
+// - Observers must be registered when the event happens or they miss it; a durable
+//   subscription keeps its position in the topic and receives what it missed while offline
+// - An observer call is fire-and-forget; here every message must be acknowledged and is
+//   redelivered until it is (at-least-once delivery)
+//
+// Key benefits of Pub/Sub:
+// - Publishers and subscribers are fully decoupled, they can be deployed and scaled separately
+// - New subscribers can be added without changing the publishers
+// - A journal allows replay and recovery after failures
+//
+// Common use cases:
+// - Integrating services through events (orders, payments, notifications)
+// - Fan-out of the same message to several independent consumers
+// - Buffering work between fast producers and slow consumers
+//
+// In this example, we implement a broker that:
+// 1. Appends every published message to an in-memory journal per topic
+// 2. Supports durable subscriptions identified by name that resume where they left off
+// 3. Pushes messages to subscribers through a channel of deliveries
+// 4. Redelivers messages that are not acknowledged within the ack timeout
+// 5. Shows a subscriber that "crashes" and one that goes offline for a while
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Message is an entry of a topic journal
+type Message struct {
+	Topic   string
+	Offset  int // Position in the topic journal
+	Payload string
+}
+
+// Delivery is a message sent to a subscriber; it must be acknowledged with Ack
+type Delivery struct {
+	Message
+	Attempt int // 1 for the first delivery, more for redeliveries
+	sub     *Subscription
+}
+
+// Ack confirms the message was processed so it is never delivered again
+func (d Delivery) Ack() {
+	d.sub.broker.ack(d.sub.name, d.Offset)
+}
+
+// inflight tracks a delivered but not yet acknowledged message
+type inflight struct {
+	attempts int
+	deadline time.Time
+}
+
+// subscriptionState survives disconnections; this is what makes a subscription durable
+type subscriptionState struct {
+	topic    string
+	next     int               // Next journal offset never delivered
+	inflight map[int]*inflight // Delivered, waiting for ack
+	acked    map[int]bool      // Acknowledged above the committed offset
+	active   *Subscription     // Connected consumer, nil while offline
+}
+
+// committed returns the offset below which every message was acknowledged
+func (s *subscriptionState) committed() int {
+	offset := 0
+	for s.acked[offset] {
+		offset++
+	}
+	return offset
+}
+
+// Broker routes messages from topics to durable subscriptions
+type Broker struct {
+	journal       map[string][]Message
+	subscriptions map[string]*subscriptionState
+	ackTimeout    time.Duration
+	mux           sync.Mutex
+}
+
+// NewBroker creates a broker that redelivers messages not acknowledged within ackTimeout
+func NewBroker(ackTimeout time.Duration) *Broker {
+	return &Broker{
+		journal:       make(map[string][]Message),
+		subscriptions: make(map[string]*subscriptionState),
+		ackTimeout:    ackTimeout,
+	}
+}
+
+// Publish appends a message to the topic journal and wakes up its subscribers
+func (b *Broker) Publish(topic, payload string) int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	offset := len(b.journal[topic])
+	b.journal[topic] = append(b.journal[topic], Message{Topic: topic, Offset: offset, Payload: payload})
+
+	for _, state := range b.subscriptions {
+		if state.topic == topic && state.active != nil {
+			state.active.wake()
+		}
+	}
+	return offset
+}
+
+// Subscription is an active connection of a durable subscription
+type Subscription struct {
+	name       string
+	broker     *Broker
+	deliveries chan Delivery
+	notify     chan struct{}
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// Subscribe connects to the durable subscription with the given name, creating it on first use.
+// A new subscription starts at the beginning of the journal; an existing one resumes
+// and receives again the messages it didn't acknowledge.
+func (b *Broker) Subscribe(topic, name string) (*Subscription, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	state, exists := b.subscriptions[name]
+	if !exists {
+		state = &subscriptionState{topic: topic, inflight: make(map[int]*inflight), acked: make(map[int]bool)}
+		b.subscriptions[name] = state
+	}
+	if state.topic != topic {
+		return nil, fmt.Errorf("subscription %s belongs to topic %s", name, state.topic)
+	}
+	if state.active != nil {
+		return nil, fmt.Errorf("subscription %s is already connected", name)
+	}
+
+	// Messages delivered to the previous connection are redelivered right away
+	for _, pending := range state.inflight {
+		pending.deadline = time.Time{}
+	}
+
+	sub := &Subscription{
+		name:       name,
+		broker:     b,
+		deliveries: make(chan Delivery),
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	state.active = sub
+	sub.wg.Add(1)
+	go sub.run()
+	sub.wake()
+	return sub, nil
+}
+
+// Deliveries returns the channel where messages are pushed
+func (s *Subscription) Deliveries() <-chan Delivery {
+	return s.deliveries
+}
+
+// Close disconnects the consumer; the subscription keeps its position for the next Subscribe
+func (s *Subscription) Close() {
+	s.broker.mux.Lock()
+	if state := s.broker.subscriptions[s.name]; state.active == s {
+		state.active = nil
+	}
+	s.broker.mux.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+}
+
+// wake signals the delivery loop without blocking
+func (s *Subscription) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run pushes new and expired messages until the subscription is closed.
+// The ticker makes sure unacknowledged messages are redelivered even if nothing is published.
+func (s *Subscription) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.broker.ackTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivery, found := s.broker.nextDue(s)
+			if !found {
+				break
+			}
+			select {
+			case s.deliveries <- delivery:
+			case <-s.done:
+				// The consumer never received it, so this attempt doesn't count
+				s.broker.undeliver(s.name, delivery.Offset)
+				return
+			}
+		}
+
+		select {
+		case <-s.notify:
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// nextDue picks the next message to push: the oldest expired in-flight one first,
+// then the next message never delivered. The message is marked as in flight.
+func (b *Broker) nextDue(sub *Subscription) (Delivery, bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	state := b.subscriptions[sub.name]
+	journal := b.journal[state.topic]
+	now := time.Now()
+
+	offset := -1
+	for candidate, pending := range state.inflight {
+		if now.After(pending.deadline) && (offset == -1 || candidate < offset) {
+			offset = candidate
+		}
+	}
+	if offset == -1 {
+		if state.next == len(journal) {
+			return Delivery{}, false
+		}
+		offset = state.next
+		state.next++
+		state.inflight[offset] = &inflight{}
+	}
+
+	pending := state.inflight[offset]
+	pending.attempts++
+	pending.deadline = now.Add(b.ackTimeout)
+	return Delivery{Message: journal[offset], Attempt: pending.attempts, sub: sub}, true
+}
+
+// undeliver reverts a delivery that never reached the consumer
+func (b *Broker) undeliver(name string, offset int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if pending, exists := b.subscriptions[name].inflight[offset]; exists {
+		pending.attempts--
+		pending.deadline = time.Time{}
+	}
+}
+
+// ack marks a message as processed
+func (b *Broker) ack(name string, offset int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	state := b.subscriptions[name]
+	if _, pending := state.inflight[offset]; !pending {
+		return
+	}
+	delete(state.inflight, offset)
+	state.acked[offset] = true
+}
+
+// Committed returns the committed offset of a subscription
+func (b *Broker) Committed(name string) int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if state, exists := b.subscriptions[name]; exists {
+		return state.committed()
+	}
+	return 0
+}
+
+// consume processes deliveries until n distinct messages were acknowledged.
+// handle returns false to simulate a consumer that crashes before acknowledging.
+func consume(sub *Subscription, name string, n int, handle func(d Delivery) bool) {
+	processed := make(map[int]bool)
+	for delivery := range sub.Deliveries() {
+		if !handle(delivery) {
+			fmt.Printf("[%s] failed on #%d %q (attempt %d), not acknowledged\n", name, delivery.Offset, delivery.Payload, delivery.Attempt)
+			continue
+		}
+		fmt.Printf("[%s] processed #%d %q (attempt %d)\n", name, delivery.Offset, delivery.Payload, delivery.Attempt)
+		delivery.Ack()
+		processed[delivery.Offset] = true
+		if len(processed) == n {
+			return
+		}
+	}
+}
+
+func main() {
+	broker := NewBroker(200 * time.Millisecond)
+
+	// The publisher only knows the topic name
+	for _, order := range []string{"order-1", "order-2", "order-3"} {
+		broker.Publish("orders", order)
+	}
+
+	// A subscriber that fails to process order-2 the first time: it is redelivered later
+	shipping, _ := broker.Subscribe("orders", "shipping")
+	failedOnce := false
+	consume(shipping, "shipping", 3, func(d Delivery) bool {
+		if d.Payload == "order-2" && !failedOnce {
+			failedOnce = true
+			return false
+		}
+		return true
+	})
+	shipping.Close()
+
+	// A subscriber that goes offline after the first message
+	audit, _ := broker.Subscribe("orders", "audit")
+	consume(audit, "audit", 1, func(Delivery) bool { return true })
+	audit.Close()
+	fmt.Printf("audit went offline at committed offset %d\n", broker.Committed("audit"))
+
+	// Messages published while audit is offline are kept in the journal
+	broker.Publish("orders", "order-4")
+	broker.Publish("orders", "order-5")
+
+	// The durable subscription resumes where it left off
+	audit, _ = broker.Subscribe("orders", "audit")
+	consume(audit, "audit", 4, func(Delivery) bool { return true })
+	audit.Close()
+	fmt.Printf("audit committed offset %d, shipping committed offset %d\n",
+		broker.Committed("audit"), broker.Committed("shipping"))
+}
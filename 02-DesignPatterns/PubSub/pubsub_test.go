@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// receive waits for the next delivery of the subscription
+func receive(t *testing.T, sub *Subscription) Delivery {
+	t.Helper()
+	select {
+	case delivery := <-sub.Deliveries():
+		return delivery
+	case <-time.After(time.Second):
+		t.Fatal("no delivery within a second")
+		return Delivery{}
+	}
+}
+
+// TestFanOut checks that every subscription receives every message of its topic, in order
+func TestFanOut(t *testing.T) {
+	broker := NewBroker(time.Second)
+	broker.Publish("orders", "order-1")
+	broker.Publish("payments", "payment-1")
+	broker.Publish("orders", "order-2")
+
+	for _, name := range []string{"shipping", "audit"} {
+		t.Run(name, func(t *testing.T) {
+			sub, err := broker.Subscribe("orders", name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+			for i, want := range []string{"order-1", "order-2"} {
+				delivery := receive(t, sub)
+				if delivery.Payload != want || delivery.Offset != i || delivery.Attempt != 1 {
+					t.Errorf("got %+v, want %q at offset %d", delivery.Message, want, i)
+				}
+				delivery.Ack()
+			}
+			if got := broker.Committed(name); got != 2 {
+				t.Errorf("got committed offset %d, want 2", got)
+			}
+		})
+	}
+}
+
+// TestRedelivery checks that a message not acknowledged within the ack timeout is delivered again
+func TestRedelivery(t *testing.T) {
+	broker := NewBroker(50 * time.Millisecond)
+	broker.Publish("orders", "order-1")
+	sub, _ := broker.Subscribe("orders", "shipping")
+	defer sub.Close()
+
+	if first := receive(t, sub); first.Attempt != 1 {
+		t.Fatalf("got attempt %d, want 1", first.Attempt)
+	}
+	second := receive(t, sub)
+	if second.Offset != 0 || second.Attempt != 2 {
+		t.Fatalf("got offset %d attempt %d, want offset 0 attempt 2", second.Offset, second.Attempt)
+	}
+	second.Ack()
+	if got := broker.Committed("shipping"); got != 1 {
+		t.Errorf("got committed offset %d, want 1", got)
+	}
+}
+
+// TestDurableSubscription checks that a subscription resumes where it left off,
+// receiving the messages published while it was offline
+func TestDurableSubscription(t *testing.T) {
+	broker := NewBroker(time.Second)
+	broker.Publish("orders", "order-1")
+	broker.Publish("orders", "order-2")
+
+	sub, _ := broker.Subscribe("orders", "audit")
+	receive(t, sub).Ack()
+	sub.Close()
+
+	broker.Publish("orders", "order-3")
+	sub, err := broker.Subscribe("orders", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	for _, want := range []string{"order-2", "order-3"} {
+		delivery := receive(t, sub)
+		if delivery.Payload != want {
+			t.Errorf("got %q, want %q", delivery.Payload, want)
+		}
+		delivery.Ack()
+	}
+	if got := broker.Committed("audit"); got != 3 {
+		t.Errorf("got committed offset %d, want 3", got)
+	}
+}
+
+// TestSubscribeErrors checks the subscriptions the broker refuses
+func TestSubscribeErrors(t *testing.T) {
+	broker := NewBroker(time.Second)
+	sub, _ := broker.Subscribe("orders", "audit")
+	defer sub.Close()
+
+	cases := []struct {
+		name, topic string
+	}{
+		{name: "already connected", topic: "orders"},
+		{name: "other topic", topic: "payments"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := broker.Subscribe(c.topic, "audit"); err == nil {
+				t.Error("the subscription was accepted")
+			}
+		})
+	}
+}
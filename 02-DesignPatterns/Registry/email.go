@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// init registers the backend; including this file is enough to make "email" available
+func init() {
+	MustRegister("email", newEmailNotifier)
+}
+
+// emailNotifier sends notifications by email
+type emailNotifier struct {
+	from string
+}
+
+func newEmailNotifier(config map[string]string) (Notifier, error) {
+	if config["from"] == "" {
+		return nil, errors.New("email notifier: \"from\" is required")
+	}
+	return &emailNotifier{from: config["from"]}, nil
+}
+
+func (e *emailNotifier) Notify(to, message string) error {
+	fmt.Printf("Sending email from %s to %s: %s\n", e.from, to, message)
+	return nil
+}
@@ -0,0 +1,133 @@
+// The Registry Pattern keeps a well known place where implementations can be found by name.
+// Combined with self-registration in init(), it lets new implementations be added just by
+// including their file (or importing their package), without editing a central switch.
+// This is how database/sql finds its drivers and image.Decode finds its formats.
+//
+// Key benefits of the Registry Pattern:
+// - New implementations plug in without modifying existing code
+// - The implementation to use can be chosen at runtime, by name, from configuration
+// - The registry is global but encapsulated: the map is private and guarded by a mutex,
+//   the only way to touch it is through Register, MustRegister, Open and Names
+//
+// Common use cases:
+// - Database drivers, codecs, compression formats
+// - Notification backends, storage backends, authentication providers
+//
+// In this example, we implement a notifier registry where:
+// 1. Every backend (email.go, sms.go, slack.go) registers itself from its init() function
+// 2. Open creates a notifier by name, passing it its configuration
+// 3. Registering the same name twice is detected and reported
+// 4. The demo opens every backend listed in the --notifiers flag
+// RUN PROGRAM WITH FLAGS
+// go run . --notifiers=email,slack
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Errors returned by the registry
+var (
+	ErrDuplicateNotifier = errors.New("notifier already registered")
+	ErrUnknownNotifier   = errors.New("unknown notifier")
+)
+
+// Notifier sends a message to a recipient
+type Notifier interface {
+	Notify(to, message string) error
+}
+
+// Factory creates a configured notifier, like a database/sql driver opening a connection
+type Factory func(config map[string]string) (Notifier, error)
+
+// registry is private: the rest of the program can only use the functions below
+var registry = struct {
+	factories map[string]Factory
+	mux       sync.RWMutex
+}{factories: make(map[string]Factory)}
+
+// Register makes a notifier backend available under the given name
+func Register(name string, factory Factory) error {
+	if factory == nil {
+		return fmt.Errorf("notifier %q: factory is nil", name)
+	}
+
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	if _, exists := registry.factories[name]; exists {
+		return fmt.Errorf("%w: %q", ErrDuplicateNotifier, name)
+	}
+	registry.factories[name] = factory
+	return nil
+}
+
+// MustRegister is Register for init() functions, where an error can only be a programming
+// mistake (two backends with the same name) and the program must not start
+func MustRegister(name string, factory Factory) {
+	if err := Register(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Open creates the notifier registered under name
+func Open(name string, config map[string]string) (Notifier, error) {
+	registry.mux.RLock()
+	factory, exists := registry.factories[name]
+	registry.mux.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %q (available: %s)", ErrUnknownNotifier, name, strings.Join(Names(), ", "))
+	}
+	return factory(config)
+}
+
+// Names returns the sorted names of the registered backends
+func Names() []string {
+	registry.mux.RLock()
+	defer registry.mux.RUnlock()
+
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Notifiers to open, by name
+var notifiers = flag.String("notifiers", "email,sms,slack", "comma separated list of notifier backends")
+
+func main() {
+	flag.Parse()
+
+	// The backends registered themselves before main started
+	fmt.Println("Registered notifiers:", Names())
+
+	// Configuration per backend, usually read from a file or the environment
+	configs := map[string]map[string]string{
+		"email": {"from": "shop@test.com"},
+		"sms":   {"sender": "+525555555555"},
+		"slack": {"channel": "#orders"},
+	}
+
+	for _, name := range strings.Split(*notifiers, ",") {
+		notifier, err := Open(name, configs[name])
+		if err != nil {
+			fmt.Println("Open error:", err)
+			continue
+		}
+		if err := notifier.Notify("ana", "RTX 5090 is now available"); err != nil {
+			fmt.Println("Notify error:", err)
+		}
+	}
+
+	// A second backend trying to use an existing name is rejected
+	err := Register("email", func(map[string]string) (Notifier, error) { return nil, nil })
+	fmt.Println("Duplicate registration:", err, errors.Is(err, ErrDuplicateNotifier))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestSelfRegistration checks that the backends registered themselves from init
+func TestSelfRegistration(t *testing.T) {
+	if got, want := Names(), []string{"email", "slack", "sms"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestOpen checks the notifier built for every name and configuration
+func TestOpen(t *testing.T) {
+	cases := []struct {
+		name     string
+		backend  string
+		config   map[string]string
+		wantType string
+		wantErr  error
+	}{
+		{name: "email", backend: "email", config: map[string]string{"from": "shop@test.com"}, wantType: "*main.emailNotifier"},
+		{name: "email without from", backend: "email", wantErr: errAny},
+		{name: "sms", backend: "sms", config: map[string]string{"sender": "+525555555555"}, wantType: "*main.smsNotifier"},
+		{name: "sms without sender", backend: "sms", wantErr: errAny},
+		{name: "slack uses the default channel", backend: "slack", wantType: "*main.slackNotifier"},
+		{name: "unknown", backend: "pigeon", wantErr: ErrUnknownNotifier},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			notifier, err := Open(c.backend, c.config)
+			if c.wantErr != nil {
+				if err == nil || (c.wantErr != errAny && !errors.Is(err, c.wantErr)) {
+					t.Fatalf("got %v, want %v", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", notifier); got != c.wantType {
+				t.Errorf("got %s, want %s", got, c.wantType)
+			}
+		})
+	}
+
+	if notifier, _ := Open("slack", nil); notifier.(*slackNotifier).channel != "#general" {
+		t.Errorf("got channel %q, want #general", notifier.(*slackNotifier).channel)
+	}
+}
+
+// errAny marks the cases where any error is expected
+var errAny = errors.New("any error")
+
+// TestRegister checks the registrations the registry refuses
+func TestRegister(t *testing.T) {
+	factory := func(map[string]string) (Notifier, error) { return &slackNotifier{}, nil }
+
+	if err := Register("email", factory); !errors.Is(err, ErrDuplicateNotifier) {
+		t.Errorf("got %v, want ErrDuplicateNotifier", err)
+	}
+	if err := Register("webhook", nil); err == nil {
+		t.Error("a nil factory was accepted")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("MustRegister did not panic on a duplicate name")
+			}
+		}()
+		MustRegister("sms", factory)
+	}()
+
+	if err := Register("webhook", factory); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("webhook", nil); err != nil {
+		t.Errorf("the new backend can't be opened: %v", err)
+	}
+}
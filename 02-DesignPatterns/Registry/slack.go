@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+// init registers the backend; including this file is enough to make "slack" available
+func init() {
+	MustRegister("slack", newSlackNotifier)
+}
+
+// slackNotifier posts notifications to a channel
+type slackNotifier struct {
+	channel string
+}
+
+func newSlackNotifier(config map[string]string) (Notifier, error) {
+	channel := config["channel"]
+	if channel == "" {
+		channel = "#general"
+	}
+	return &slackNotifier{channel: channel}, nil
+}
+
+func (s *slackNotifier) Notify(to, message string) error {
+	fmt.Printf("Posting to %s for @%s: %s\n", s.channel, to, message)
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// init registers the backend; including this file is enough to make "sms" available
+func init() {
+	MustRegister("sms", newSmsNotifier)
+}
+
+// smsNotifier sends notifications by SMS
+type smsNotifier struct {
+	sender string
+}
+
+func newSmsNotifier(config map[string]string) (Notifier, error) {
+	if config["sender"] == "" {
+		return nil, errors.New("sms notifier: \"sender\" is required")
+	}
+	return &smsNotifier{sender: config["sender"]}, nil
+}
+
+func (s *smsNotifier) Notify(to, message string) error {
+	fmt.Printf("Sending SMS from %s to %s: %s\n", s.sender, to, message)
+	return nil
+}
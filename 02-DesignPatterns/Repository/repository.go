@@ -0,0 +1,86 @@
+// The Repository Pattern mediates between the domain and the data mapping layers, acting like
+// an in-memory collection of domain objects. Client code talks to a repository interface and
+// never sees SQL, maps, files or any other storage detail.
+//
+// Key benefits of the Repository Pattern:
+// - Decouples business logic from the storage technology
+// - Allows swapping backends (memory, SQL, remote) without touching client code
+// - Centralizes queries in one place
+// - Makes it possible to verify every backend against the same expectations
+//
+// Common use cases:
+// - Applications that start with an in-memory store and later move to a database
+// - Tests that replace a real database with a fast in-memory implementation
+// - Hiding an ORM or a query builder behind a domain focused interface
+//
+// In this example, we implement a product catalog that:
+// 1. Defines a ProductRepository interface with CRUD operations and queries
+// 2. Has an InMemoryProductRepository backed by a map and a mutex
+// 3. Has a SQLiteProductRepository backed by database/sql
+// 4. Verifies both backends with the same conformance suite
+// The repositories live in pkg/repository, so the HTTP services of 03-Net and the other
+// patterns can store their products in them too; this program is the client code.
+// RUN PROGRAM WITH FLAGS
+// go run . --db=products.db
+// go test ../../pkg/repository
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// Path of the SQLite database used by the demo, ":memory:" keeps it in RAM
+var dbPath = flag.String("db", ":memory:", "path of the SQLite database")
+
+// printCatalog only depends on the interface, so it works with any backend
+func printCatalog(repo repository.ProductRepository) {
+	products, err := repo.List()
+	if err != nil {
+		fmt.Println("List error:", err)
+		return
+	}
+	for _, p := range products {
+		fmt.Printf("  #%d %s price=%d stock=%d\n", p.ID, p.Name, p.Price, p.Stock)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	sqliteRepo, err := repository.NewSQLiteProductRepository(*dbPath)
+	if err != nil {
+		fmt.Println("SQLite error:", err)
+		os.Exit(1)
+	}
+	defer sqliteRepo.Close()
+
+	backends := []struct {
+		name string
+		repo repository.ProductRepository
+	}{
+		{"memory", repository.NewInMemoryProductRepository()},
+		{"sqlite", sqliteRepo},
+	}
+
+	for _, backend := range backends {
+		fmt.Printf("Backend %s\n", backend.name)
+
+		// Same client code for every backend
+		for _, p := range []*repository.Product{
+			{Name: "Laptop", Price: 1200, Stock: 11},
+			{Name: "Desktop", Price: 900, Stock: 66},
+			{Name: "Gaming Laptop", Price: 2500, Stock: 0},
+		} {
+			if err := backend.repo.Create(p); err != nil {
+				fmt.Println("Create error:", err)
+				os.Exit(1)
+			}
+		}
+		printCatalog(backend.repo)
+	}
+}
@@ -0,0 +1,226 @@
+// The Result (or Either) type carries either a value or an error in a single object.
+// It comes from functional languages (Rust's Result, Haskell's Either) and is useful in Go
+// when values travel through channels: instead of one channel for values and a parallel
+// channel for errors, which can get out of sync or deadlock when one of them isn't drained,
+// every message says by itself whether it succeeded.
+//
+// Key benefits of a Result type:
+// - A value and its error can't be separated or reordered
+// - Pipelines need a single channel per stage
+// - Combinators (Map, AndThen) skip the remaining steps after the first error
+//
+// Common use cases:
+// - Concurrent pipelines and fan-out/fan-in with goroutines
+// - Collecting the outcome of many independent jobs
+//
+// In this example, we implement:
+// 1. A generic Result[T] with Ok and Err constructors
+// 2. Map and AndThen combinators, and Unwrap/Get to extract the value
+// 3. Channel helpers: Stage to build pipeline stages and Collect to drain them
+// 4. A pipeline (parse -> validate -> price) that reports the line of every failed record
+//
+// Go doesn't allow type parameters on methods, so Map and AndThen are functions.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Result holds either a value of type T or an error
+type Result[T any] struct {
+	value T
+	err   error
+}
+
+// Ok creates a successful result
+func Ok[T any](value T) Result[T] {
+	return Result[T]{value: value}
+}
+
+// Err creates a failed result
+func Err[T any](err error) Result[T] {
+	return Result[T]{err: err}
+}
+
+// Try converts the usual (value, error) pair into a Result
+func Try[T any](value T, err error) Result[T] {
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(value)
+}
+
+// IsOk reports whether the result holds a value
+func (r Result[T]) IsOk() bool {
+	return r.err == nil
+}
+
+// Err returns the error, nil for successful results
+func (r Result[T]) Err() error {
+	return r.err
+}
+
+// Get returns the value and the error, the idiomatic Go way
+func (r Result[T]) Get() (T, error) {
+	return r.value, r.err
+}
+
+// Unwrap returns the value and panics if the result is an error.
+// Use it only when an error is a programming mistake.
+func (r Result[T]) Unwrap() T {
+	if r.err != nil {
+		panic(fmt.Sprintf("Unwrap called on an error result: %v", r.err))
+	}
+	return r.value
+}
+
+// UnwrapOr returns the value or the fallback if the result is an error
+func (r Result[T]) UnwrapOr(fallback T) T {
+	if r.err != nil {
+		return fallback
+	}
+	return r.value
+}
+
+// Map transforms the value of a successful result; errors pass through untouched
+func Map[T, U any](r Result[T], f func(T) U) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return Ok(f(r.value))
+}
+
+// AndThen chains an operation that can fail; errors pass through untouched
+func AndThen[T, U any](r Result[T], f func(T) Result[U]) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return f(r.value)
+}
+
+// Stage runs f on every result received from in using the given number of workers.
+// Errors are forwarded without calling f. The output channel is closed when in is drained.
+// With more than one worker the output order is not preserved.
+func Stage[T, U any](in <-chan Result[T], workers int, f func(T) Result[U]) <-chan Result[U] {
+	out := make(chan Result[U])
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range in {
+				out <- AndThen(r, f)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FromSlice sends every value as a successful result and closes the channel
+func FromSlice[T any](values []T) <-chan Result[T] {
+	out := make(chan Result[T])
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			out <- Ok(v)
+		}
+	}()
+	return out
+}
+
+// Collect drains the channel, returning the values and every error joined together
+func Collect[T any](in <-chan Result[T]) ([]T, error) {
+	var values []T
+	var errs []error
+	for r := range in {
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		values = append(values, r.value)
+	}
+	return values, errors.Join(errs...)
+}
+
+// record is a raw input line with its position, so errors can point to it
+type record struct {
+	line int
+	text string
+}
+
+// Order is a parsed and priced record
+type Order struct {
+	Line     int
+	Product  string
+	Quantity int
+	Total    int
+}
+
+// prices is the catalog used by the pricing stage
+var prices = map[string]int{"laptop": 1200, "desktop": 900, "tablet": 400}
+
+// parse turns "product,quantity" into an Order
+func parse(r record) Result[Order] {
+	product, quantity, found := strings.Cut(r.text, ",")
+	if !found {
+		return Err[Order](fmt.Errorf("line %d: expected product,quantity", r.line))
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(quantity))
+	if err != nil {
+		return Err[Order](fmt.Errorf("line %d: invalid quantity: %w", r.line, err))
+	}
+	return Ok(Order{Line: r.line, Product: strings.TrimSpace(product), Quantity: n})
+}
+
+// validate rejects orders that can't be priced
+func validate(o Order) Result[Order] {
+	if o.Quantity <= 0 {
+		return Err[Order](fmt.Errorf("line %d: quantity must be positive", o.Line))
+	}
+	if _, exists := prices[o.Product]; !exists {
+		return Err[Order](fmt.Errorf("line %d: unknown product %q", o.Line, o.Product))
+	}
+	return Ok(o)
+}
+
+// price computes the total of a valid order; it can't fail so it is used with Map
+func price(o Order) Order {
+	o.Total = prices[o.Product] * o.Quantity
+	return o
+}
+
+func main() {
+	lines := []string{"laptop,1", "desktop,2", "phone,1", "tablet,x", "tablet,3", "laptop,-1", "desktop"}
+	records := make([]record, len(lines))
+	for i, text := range lines {
+		records[i] = record{line: i + 1, text: text}
+	}
+
+	// Each stage has a single channel: values and errors travel together
+	parsed := Stage(FromSlice(records), 2, parse)
+	valid := Stage(parsed, 2, validate)
+	priced := Stage(valid, 2, func(o Order) Result[Order] { return Map(Ok(o), price) })
+
+	orders, err := Collect(priced)
+	for _, o := range orders {
+		fmt.Printf("line %d: %d x %s = %d\n", o.Line, o.Quantity, o.Product, o.Total)
+	}
+	if err != nil {
+		fmt.Printf("Rejected records:\n%v\n", err)
+	}
+
+	// Combinators outside of channels
+	doubled := Map(Try(strconv.Atoi("21")), func(n int) int { return n * 2 })
+	fmt.Println("Doubled:", doubled.Unwrap())
+	invalid := Map(Try(strconv.Atoi("abc")), func(n int) int { return n * 2 })
+	fmt.Println("Invalid with fallback:", invalid.UnwrapOr(-1), "error:", invalid.Err())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCombinators checks that Map and AndThen run on values and let errors through
+func TestCombinators(t *testing.T) {
+	failure := errors.New("boom")
+	double := func(n int) int { return n * 2 }
+	half := func(n int) Result[int] {
+		if n%2 != 0 {
+			return Err[int](failure)
+		}
+		return Ok(n / 2)
+	}
+
+	cases := []struct {
+		name    string
+		result  Result[int]
+		want    int
+		wantErr error
+	}{
+		{name: "map value", result: Map(Ok(21), double), want: 42},
+		{name: "map error", result: Map(Err[int](failure), double), wantErr: failure},
+		{name: "and then value", result: AndThen(Ok(42), half), want: 21},
+		{name: "and then failing", result: AndThen(Ok(21), half), wantErr: failure},
+		{name: "and then skipped after error", result: AndThen(Map(Try(strconv.Atoi("x")), double), half), wantErr: strconv.ErrSyntax},
+		{name: "try value", result: Try(strconv.Atoi("7")), want: 7},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.result.Get()
+			if !errors.Is(err, c.wantErr) || (c.wantErr == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, c.wantErr)
+			}
+			if c.result.IsOk() != (c.wantErr == nil) {
+				t.Errorf("IsOk is %t with error %v", c.result.IsOk(), err)
+			}
+			if err == nil && got != c.want {
+				t.Errorf("got %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+// TestUnwrap checks the two ways of extracting a value without an error check
+func TestUnwrap(t *testing.T) {
+	if got := Ok(1).Unwrap(); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	if got := Err[int](errors.New("boom")).UnwrapOr(-1); got != -1 {
+		t.Errorf("got %d, want the fallback -1", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Unwrap of an error did not panic")
+		}
+	}()
+	Err[int](errors.New("boom")).Unwrap()
+}
+
+// TestPipeline runs the records of main through the concurrent stages
+func TestPipeline(t *testing.T) {
+	lines := []string{"laptop,1", "desktop,2", "phone,1", "tablet,x", "tablet,3", "laptop,-1", "desktop"}
+	records := make([]record, len(lines))
+	for i, text := range lines {
+		records[i] = record{line: i + 1, text: text}
+	}
+
+	parsed := Stage(FromSlice(records), 2, parse)
+	valid := Stage(parsed, 2, validate)
+	priced := Stage(valid, 2, func(o Order) Result[Order] { return Map(Ok(o), price) })
+	orders, err := Collect(priced)
+
+	// The workers don't keep the order
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Line < orders[j].Line })
+	want := []Order{
+		{Line: 1, Product: "laptop", Quantity: 1, Total: 1200},
+		{Line: 2, Product: "desktop", Quantity: 2, Total: 1800},
+		{Line: 5, Product: "tablet", Quantity: 3, Total: 1200},
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("got %+v, want %+v", orders, want)
+	}
+	for i := range want {
+		if orders[i] != want[i] {
+			t.Errorf("got %+v, want %+v", orders[i], want[i])
+		}
+	}
+
+	for _, line := range []string{"line 3:", "line 4:", "line 6:", "line 7:"} {
+		if err == nil || !strings.Contains(err.Error(), line) {
+			t.Errorf("the errors don't report %q: %v", line, err)
+		}
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("the joined errors lost the parse error of line 4")
+	}
+}
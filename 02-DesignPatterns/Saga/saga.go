@@ -0,0 +1,330 @@
+// The Saga Pattern manages a transaction that spans several services without a distributed lock
+// or a two-phase commit. The transaction is split into local steps; every step has a compensating
+// action that semantically undoes it. When a step fails, the compensations of the steps that
+// already succeeded run in reverse order, leaving the system consistent again.
+//
+// Key benefits of the Saga Pattern:
+// - Keeps each service autonomous, every step is a local transaction
+// - Failures are handled explicitly with compensations instead of locks
+// - The orchestrator (process manager) makes the whole flow visible in one place
+// - Services only communicate through messages, so they stay decoupled
+//
+// Common use cases:
+// - Order processing involving stock, payments and shipping
+// - Travel bookings (flight + hotel + car) where any booking can fail
+// - Any workflow crossing service or database boundaries
+//
+// In this example, we implement an orchestrated saga where:
+// 1. An in-memory pub/sub Bus carries commands and replies between components
+// 2. Inventory, Payment and Shipping services subscribe to their command topics
+// 3. The Orchestrator runs the steps reserve stock -> charge payment -> ship
+// 4. When a step fails, the Orchestrator publishes the compensations in reverse order
+// 5. Every saga reports its final outcome and the log of executed actions
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Pub/Sub bus
+// ---------------------------------------------------------------------------
+
+// Message is what travels on the bus
+type Message struct {
+	SagaID  string
+	Order   Order
+	Step    string
+	Success bool
+	Reason  string
+}
+
+// Handler processes a message received from a topic
+type Handler func(Message)
+
+// Bus is a minimal topic based publish/subscribe broker.
+// Handlers run in their own goroutine, like messages consumed by remote services.
+type Bus struct {
+	subscribers map[string][]Handler
+	mux         sync.RWMutex
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]Handler)}
+}
+
+// Subscribe registers a handler for a topic
+func (b *Bus) Subscribe(topic string, handler Handler) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.subscribers[topic] = append(b.subscribers[topic], handler)
+}
+
+// Publish delivers the message to every subscriber of the topic asynchronously
+func (b *Bus) Publish(topic string, msg Message) {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for _, handler := range b.subscribers[topic] {
+		go handler(msg)
+	}
+}
+
+// Topics used by the saga
+const (
+	TopicReserveStock  = "inventory.reserve"
+	TopicReleaseStock  = "inventory.release"
+	TopicChargePayment = "payment.charge"
+	TopicRefundPayment = "payment.refund"
+	TopicShipOrder     = "shipping.ship"
+	TopicReplies       = "saga.replies"
+)
+
+// ---------------------------------------------------------------------------
+// Services: each one owns its data and replies through the bus
+// ---------------------------------------------------------------------------
+
+// Order is the business transaction coordinated by the saga
+type Order struct {
+	ID       string
+	Product  string
+	Quantity int
+	Amount   int
+	Address  string
+}
+
+// InventoryService reserves and releases stock
+type InventoryService struct {
+	stock map[string]int
+	mux   sync.Mutex
+}
+
+// NewInventoryService subscribes the service to its topics
+func NewInventoryService(bus *Bus, stock map[string]int) *InventoryService {
+	s := &InventoryService{stock: stock}
+	bus.Subscribe(TopicReserveStock, func(msg Message) {
+		s.mux.Lock()
+		available := s.stock[msg.Order.Product]
+		if available < msg.Order.Quantity {
+			s.mux.Unlock()
+			bus.Publish(TopicReplies, reply(msg, false, fmt.Sprintf("only %d %s left", available, msg.Order.Product)))
+			return
+		}
+		s.stock[msg.Order.Product] = available - msg.Order.Quantity
+		s.mux.Unlock()
+		bus.Publish(TopicReplies, reply(msg, true, ""))
+	})
+	bus.Subscribe(TopicReleaseStock, func(msg Message) {
+		s.mux.Lock()
+		s.stock[msg.Order.Product] += msg.Order.Quantity
+		s.mux.Unlock()
+		bus.Publish(TopicReplies, reply(msg, true, ""))
+	})
+	return s
+}
+
+// Stock returns the units left for a product
+func (s *InventoryService) Stock(product string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.stock[product]
+}
+
+// PaymentService charges and refunds customers, rejecting amounts above a limit
+type PaymentService struct {
+	limit   int
+	charged int
+	mux     sync.Mutex
+}
+
+// NewPaymentService subscribes the service to its topics
+func NewPaymentService(bus *Bus, limit int) *PaymentService {
+	s := &PaymentService{limit: limit}
+	bus.Subscribe(TopicChargePayment, func(msg Message) {
+		if msg.Order.Amount > s.limit {
+			bus.Publish(TopicReplies, reply(msg, false, fmt.Sprintf("amount %d exceeds the card limit", msg.Order.Amount)))
+			return
+		}
+		s.mux.Lock()
+		s.charged += msg.Order.Amount
+		s.mux.Unlock()
+		bus.Publish(TopicReplies, reply(msg, true, ""))
+	})
+	bus.Subscribe(TopicRefundPayment, func(msg Message) {
+		s.mux.Lock()
+		s.charged -= msg.Order.Amount
+		s.mux.Unlock()
+		bus.Publish(TopicReplies, reply(msg, true, ""))
+	})
+	return s
+}
+
+// Charged returns the total amount currently charged
+func (s *PaymentService) Charged() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.charged
+}
+
+// NewShippingService subscribes a shipping service that can't deliver without an address
+func NewShippingService(bus *Bus) {
+	bus.Subscribe(TopicShipOrder, func(msg Message) {
+		if msg.Order.Address == "" {
+			bus.Publish(TopicReplies, reply(msg, false, "missing shipping address"))
+			return
+		}
+		bus.Publish(TopicReplies, reply(msg, true, ""))
+	})
+}
+
+// reply builds the answer to a command
+func reply(cmd Message, success bool, reason string) Message {
+	cmd.Success = success
+	cmd.Reason = reason
+	return cmd
+}
+
+// ---------------------------------------------------------------------------
+// Orchestrator (process manager)
+// ---------------------------------------------------------------------------
+
+// Step is one local transaction of the saga and the topic that undoes it
+type Step struct {
+	Name         string
+	Action       string
+	Compensation string // empty when the step doesn't need to be undone
+}
+
+// orderSteps defines the saga; the last step has no compensation because nothing follows it
+var orderSteps = []Step{
+	{Name: "reserve-stock", Action: TopicReserveStock, Compensation: TopicReleaseStock},
+	{Name: "charge-payment", Action: TopicChargePayment, Compensation: TopicRefundPayment},
+	{Name: "ship-order", Action: TopicShipOrder},
+}
+
+// Result is the final outcome of a saga
+type Result struct {
+	SagaID    string
+	Completed bool
+	Reason    string
+	Log       []string
+}
+
+// sagaState tracks where a running saga is
+type sagaState struct {
+	order        Order
+	current      int  // index of the step waiting for a reply
+	compensating bool // true once a step failed
+	reason       string
+	log          []string
+	done         chan Result
+}
+
+// Orchestrator drives every saga by reacting to the replies published by the services
+type Orchestrator struct {
+	bus    *Bus
+	steps  []Step
+	sagas  map[string]*sagaState
+	nextID int
+	mux    sync.Mutex
+}
+
+// NewOrchestrator subscribes the orchestrator to the replies topic
+func NewOrchestrator(bus *Bus, steps []Step) *Orchestrator {
+	o := &Orchestrator{bus: bus, steps: steps, sagas: make(map[string]*sagaState)}
+	bus.Subscribe(TopicReplies, o.handleReply)
+	return o
+}
+
+// Start begins a new saga for the order; the result is delivered on the returned channel
+func (o *Orchestrator) Start(order Order) <-chan Result {
+	o.mux.Lock()
+	o.nextID++
+	id := fmt.Sprintf("saga-%d", o.nextID)
+	state := &sagaState{order: order, done: make(chan Result, 1)}
+	o.sagas[id] = state
+	o.mux.Unlock()
+
+	o.send(id, state, o.steps[0].Name, o.steps[0].Action)
+	return state.done
+}
+
+// send publishes a command for the saga and records it in the log
+func (o *Orchestrator) send(id string, state *sagaState, step, topic string) {
+	state.log = append(state.log, topic)
+	o.bus.Publish(topic, Message{SagaID: id, Order: state.order, Step: step})
+}
+
+// handleReply advances the saga: next step on success, compensation on failure
+func (o *Orchestrator) handleReply(msg Message) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	state, exists := o.sagas[msg.SagaID]
+	if !exists {
+		return
+	}
+
+	if !state.compensating {
+		if msg.Success {
+			state.current++
+			if state.current == len(o.steps) {
+				o.finish(msg.SagaID, state, true)
+				return
+			}
+			next := o.steps[state.current]
+			o.send(msg.SagaID, state, next.Name, next.Action)
+			return
+		}
+		// The current step failed: nothing to undo for it, start compensating the previous ones
+		state.compensating = true
+		state.reason = fmt.Sprintf("%s failed: %s", msg.Step, msg.Reason)
+	}
+
+	// Compensate the completed steps one at a time, in reverse order
+	for state.current--; state.current >= 0; state.current-- {
+		step := o.steps[state.current]
+		if step.Compensation != "" {
+			o.send(msg.SagaID, state, step.Name, step.Compensation)
+			return
+		}
+	}
+	o.finish(msg.SagaID, state, false)
+}
+
+// finish reports the outcome and forgets the saga
+func (o *Orchestrator) finish(id string, state *sagaState, completed bool) {
+	delete(o.sagas, id)
+	state.done <- Result{SagaID: id, Completed: completed, Reason: state.reason, Log: state.log}
+}
+
+func main() {
+	bus := NewBus()
+	inventory := NewInventoryService(bus, map[string]int{"RTX 5090": 5})
+	payments := NewPaymentService(bus, 3000)
+	NewShippingService(bus)
+	orchestrator := NewOrchestrator(bus, orderSteps)
+
+	orders := []Order{
+		{ID: "A", Product: "RTX 5090", Quantity: 1, Amount: 2000, Address: "Main St 1"},
+		{ID: "B", Product: "RTX 5090", Quantity: 2, Amount: 4000, Address: "Main St 2"},
+		{ID: "C", Product: "RTX 5090", Quantity: 1, Amount: 2000},
+		{ID: "D", Product: "RTX 5090", Quantity: 10, Amount: 500, Address: "Main St 4"},
+	}
+
+	// Run one saga at a time so the output is easy to follow
+	for _, order := range orders {
+		result := <-orchestrator.Start(order)
+		if result.Completed {
+			fmt.Printf("Order %s completed\n", order.ID)
+		} else {
+			fmt.Printf("Order %s rolled back (%s)\n", order.ID, result.Reason)
+		}
+		fmt.Printf("  actions: %v\n", result.Log)
+	}
+
+	// Only order A kept its stock and payment
+	fmt.Printf("Stock left: %d, total charged: %d\n", inventory.Stock("RTX 5090"), payments.Charged())
+}
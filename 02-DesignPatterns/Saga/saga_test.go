@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSaga runs one saga per case and checks the outcome, the executed actions and
+// that the compensations left the services as they were before the failed saga
+func TestSaga(t *testing.T) {
+	cases := []struct {
+		name        string
+		order       Order
+		wantDone    bool
+		wantReason  string
+		wantLog     []string
+		wantStock   int
+		wantCharged int
+	}{
+		{
+			name:        "completes",
+			order:       Order{ID: "A", Product: "gpu", Quantity: 1, Amount: 2000, Address: "Main St 1"},
+			wantDone:    true,
+			wantLog:     []string{TopicReserveStock, TopicChargePayment, TopicShipOrder},
+			wantStock:   4,
+			wantCharged: 2000,
+		},
+		{
+			name:       "first step fails, nothing to compensate",
+			order:      Order{ID: "B", Product: "gpu", Quantity: 10, Amount: 500, Address: "Main St 2"},
+			wantReason: "reserve-stock failed",
+			wantLog:    []string{TopicReserveStock},
+			wantStock:  5,
+		},
+		{
+			name:       "payment fails, stock is released",
+			order:      Order{ID: "C", Product: "gpu", Quantity: 2, Amount: 4000, Address: "Main St 3"},
+			wantReason: "charge-payment failed",
+			wantLog:    []string{TopicReserveStock, TopicChargePayment, TopicReleaseStock},
+			wantStock:  5,
+		},
+		{
+			name:       "shipping fails, payment and stock are undone in reverse order",
+			order:      Order{ID: "D", Product: "gpu", Quantity: 1, Amount: 2000},
+			wantReason: "ship-order failed",
+			wantLog:    []string{TopicReserveStock, TopicChargePayment, TopicShipOrder, TopicRefundPayment, TopicReleaseStock},
+			wantStock:  5,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bus := NewBus()
+			inventory := NewInventoryService(bus, map[string]int{"gpu": 5})
+			payments := NewPaymentService(bus, 3000)
+			NewShippingService(bus)
+			orchestrator := NewOrchestrator(bus, orderSteps)
+
+			var result Result
+			select {
+			case result = <-orchestrator.Start(c.order):
+			case <-time.After(time.Second):
+				t.Fatal("the saga did not finish")
+			}
+			if result.Completed != c.wantDone || !strings.HasPrefix(result.Reason, c.wantReason) {
+				t.Errorf("got completed %t (%q), want %t (%q)", result.Completed, result.Reason, c.wantDone, c.wantReason)
+			}
+			if !reflect.DeepEqual(result.Log, c.wantLog) {
+				t.Errorf("got actions %v, want %v", result.Log, c.wantLog)
+			}
+			if inventory.Stock("gpu") != c.wantStock || payments.Charged() != c.wantCharged {
+				t.Errorf("got stock %d and %d charged, want %d and %d",
+					inventory.Stock("gpu"), payments.Charged(), c.wantStock, c.wantCharged)
+			}
+		})
+	}
+}
+
+// TestSagaConcurrent runs several sagas at once; the replies are routed by saga ID
+func TestSagaConcurrent(t *testing.T) {
+	bus := NewBus()
+	inventory := NewInventoryService(bus, map[string]int{"gpu": 3})
+	payments := NewPaymentService(bus, 3000)
+	NewShippingService(bus)
+	orchestrator := NewOrchestrator(bus, orderSteps)
+
+	var results []<-chan Result
+	for range 5 {
+		results = append(results, orchestrator.Start(Order{Product: "gpu", Quantity: 1, Amount: 100, Address: "Main St"}))
+	}
+	completed := 0
+	for _, done := range results {
+		if (<-done).Completed {
+			completed++
+		}
+	}
+	if completed != 3 || inventory.Stock("gpu") != 0 || payments.Charged() != 300 {
+		t.Errorf("got %d completed, stock %d and %d charged, want 3, 0 and 300",
+			completed, inventory.Stock("gpu"), payments.Charged())
+	}
+}
@@ -5,62 +5,234 @@
 // - Global configurations
 // - Shared caches
 //
-// In this example, we implement the Singleton for a database connection:
-// 1. We have a global 'db' variable that holds the single instance
-// 2. We use a mutex to ensure thread-safe access to instance creation
-// 3. The getDatabaseInstance() method implements "lazy initialization" logic
-// 4. The main() demo shows how multiple goroutines try to access the same instance
+// In this example, we implement the Singleton for a database connection pool:
+// 1. We have a global 'pool' variable that holds the single instance
+// 2. We use sync.Once to run the "lazy initialization" exactly once, even with many goroutines
+// 3. The Pool is a real resource: bounded connections, Acquire/Release and health checks
+// 4. Reset closes the instance and allows a new one to be created, which tests need
+// 5. The mutex based version is kept to benchmark the contention difference (see singleton_test.go)
+//
+// After the first call, sync.Once only performs an atomic load, while the mutex version
+// makes every goroutine take the same lock just to read the pointer.
+// RUN PROGRAM WITH FLAGS
+// go run .
+// go test -race .
+// go test -bench . -cpu 1,4
 
 package main
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Database represents our "connection" to the database
-type Database struct{}
+// ErrPoolClosed is returned when acquiring a connection from a closed pool
+var ErrPoolClosed = errors.New("connection pool is closed")
+
+// Conn represents a single "connection" to the database
+type Conn struct {
+	id       int
+	healthy  bool
+	lastUsed time.Time
+}
+
+// Ping simulates a round trip to the database
+func (c *Conn) Ping() error {
+	if !c.healthy {
+		return fmt.Errorf("connection %d is broken", c.id)
+	}
+	return nil
+}
+
+// Pool is a bounded pool of connections shared by the whole program
+type Pool struct {
+	idle   chan *Conn    // Connections ready to be used
+	size   int           // Maximum number of connections
+	nextID atomic.Int64  // Last connection id
+	closed bool          // Set by Close, protected by mux
+	done   chan struct{} // Closed by Close, wakes the Acquire calls waiting for a connection
+	mux    sync.RWMutex  // Prevents sending to idle while Close is draining it
+}
+
+// NewPool opens size connections; opening is slow, which is why we want a single pool
+func NewPool(size int, connectDelay time.Duration) *Pool {
+	p := &Pool{idle: make(chan *Conn, size), size: size, done: make(chan struct{})}
+	for range size {
+		p.idle <- p.connect(connectDelay)
+	}
+	return p
+}
+
+// connect simulates opening a new connection
+func (p *Pool) connect(delay time.Duration) *Conn {
+	time.Sleep(delay)
+	return &Conn{id: int(p.nextID.Add(1)), healthy: true, lastUsed: time.Now()}
+}
+
+// Acquire waits for an idle connection, and fails once the pool is closed.
+// The lock isn't held while waiting, or a Release could never take it to hand a
+// connection back; a connection received during Close is dropped instead.
+func (p *Pool) Acquire() (*Conn, error) {
+	if p.isClosed() {
+		return nil, ErrPoolClosed
+	}
+	select {
+	case conn := <-p.idle:
+		if p.isClosed() {
+			return nil, ErrPoolClosed
+		}
+		return conn, nil
+	case <-p.done:
+		return nil, ErrPoolClosed
+	}
+}
+
+func (p *Pool) isClosed() bool {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.closed
+}
+
+// Release returns a connection to the pool
+func (p *Pool) Release(conn *Conn) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	if p.closed {
+		return
+	}
+	conn.lastUsed = time.Now()
+	p.idle <- conn
+}
+
+// HealthCheck pings the idle connections and replaces the broken ones.
+// It returns how many connections were replaced.
+// An Acquire can take a connection between len and the receive, so the receive doesn't
+// wait: a blocked HealthCheck would hold the read lock, and Close would wait forever.
+func (p *Pool) HealthCheck() int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	if p.closed {
+		return 0
+	}
 
-// mutex to ensure thread-safety in instance creation
-var mutex = sync.Mutex{}
+	replaced := 0
+	for range len(p.idle) {
+		var conn *Conn
+		select {
+		case conn = <-p.idle:
+		default:
+			return replaced
+		}
+		if err := conn.Ping(); err != nil {
+			conn = p.connect(0)
+			replaced++
+		}
+		p.idle <- conn
+	}
+	return replaced
+}
 
-// CreateSingleConnection simulates creating a database connection
-func (Database) CreateSingleConnection() {
-	fmt.Println("Connection singleton for database")
-	time.Sleep(3 * time.Second) // Simulate connection work
-	fmt.Println("Connection created")
+// Close drains the pool; further Acquire calls fail, and so do the ones waiting.
+// The connections in use are dropped when they are released.
+func (p *Pool) Close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for {
+		select {
+		case <-p.idle:
+		default:
+			return
+		}
+	}
 }
 
-// The only Database instance that will exist
-var db *Database
+// Pool configuration used by the singleton
+const poolSize = 4
+
+// connectDelay is how long a connection of the singleton takes to open; the tests shorten it
+var connectDelay = 500 * time.Millisecond
 
-// getDatabaseInstance implements the Singleton pattern
-// Returns the single instance, creating it if it doesn't exist
-func getDatabaseInstance() *Database {
+// The only Pool instance that will exist and the Once guarding its creation
+var (
+	pool     *Pool
+	poolOnce sync.Once
+)
+
+// GetPool implements the Singleton pattern with sync.Once
+// Returns the single instance, creating it on the first call
+func GetPool() *Pool {
+	poolOnce.Do(func() {
+		fmt.Println("Creating new connection pool")
+		pool = NewPool(poolSize, connectDelay)
+		fmt.Println("Connection pool created")
+	})
+	return pool
+}
+
+// Reset closes the current instance so the next GetPool creates a new one.
+// It is meant for tests and must not run concurrently with GetPool.
+func Reset() {
+	if pool != nil {
+		pool.Close()
+	}
+	pool = nil
+	poolOnce = sync.Once{}
+}
+
+// Mutex based version kept for comparison
+var (
+	mutexPool *Pool
+	mutex     = sync.Mutex{}
+)
+
+// getPoolWithMutex is the previous implementation: every call takes the lock
+func getPoolWithMutex() *Pool {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	if db == nil {
-		fmt.Println("Creating new database instance")
-		db = &Database{}
-		db.CreateSingleConnection()
-	} else {
-		fmt.Println("Database instance already created")
+	if mutexPool == nil {
+		mutexPool = NewPool(poolSize, connectDelay)
 	}
-	return db
+	return mutexPool
 }
 
 func main() {
 	// Demonstrate the Singleton with multiple goroutines
 	var wg sync.WaitGroup
-	for range 10 {
+	pools := make([]*Pool, 10)
+	for i := range 10 {
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
-			getDatabaseInstance()
+			pools[i] = GetPool()
 		}()
 	}
 	wg.Wait()
+
+	same := true
+	for _, p := range pools {
+		same = same && p == pools[0]
+	}
+	fmt.Printf("All goroutines got the same pool: %t\n", same)
+
+	// Use the shared resource
+	db := GetPool()
+	conn, _ := db.Acquire()
+	fmt.Printf("Using connection %d\n", conn.id)
+	conn.healthy = false // simulate a connection dropped by the server
+	db.Release(conn)
+	fmt.Printf("Health check replaced %d broken connections\n", db.HealthCheck())
+
+	// Reset lets a test start again from a fresh instance
+	Reset()
+	fmt.Printf("New pool after Reset: %t\n", GetPool() != db)
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	// The tests create several singletons, each would take poolSize delays to open
+	connectDelay = time.Millisecond
+}
+
+func TestGetPoolOnce(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	var wg sync.WaitGroup
+	pools := make([]*Pool, 20)
+	for i := range pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pools[i] = GetPool()
+		}()
+	}
+	wg.Wait()
+	for i, p := range pools {
+		if p == nil || p != pools[0] {
+			t.Fatalf("goroutine %d got pool %p, goroutine 0 got %p", i, p, pools[0])
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	first := GetPool()
+	Reset()
+	if _, err := first.Acquire(); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("got %v from the pool before Reset, want ErrPoolClosed", err)
+	}
+	second := GetPool()
+	if second == first {
+		t.Fatal("GetPool returned the same pool after Reset")
+	}
+	if _, err := second.Acquire(); err != nil {
+		t.Errorf("got %v from the new pool", err)
+	}
+}
+
+func TestPoolAcquireRelease(t *testing.T) {
+	p := NewPool(2, 0)
+	defer p.Close()
+	a, errA := p.Acquire()
+	b, errB := p.Acquire()
+	if errA != nil || errB != nil || a == b {
+		t.Fatalf("got %v, %v and %v, %v", a, errA, b, errB)
+	}
+
+	// The pool is empty: the next Acquire waits for a Release
+	acquired := make(chan *Conn)
+	go func() {
+		conn, _ := p.Acquire()
+		acquired <- conn
+	}()
+	select {
+	case conn := <-acquired:
+		t.Fatalf("Acquire returned %v from an empty pool", conn)
+	case <-time.After(20 * time.Millisecond):
+	}
+	p.Release(a)
+	if conn := <-acquired; conn != a {
+		t.Errorf("got connection %v, want the released %v", conn, a)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := NewPool(2, 0)
+	conn, err := p.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	// One connection is still idle, it must not be handed out after Close
+	if got, err := p.Acquire(); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("got %v, %v after Close, want ErrPoolClosed", got, err)
+	}
+	if len(p.idle) != 0 {
+		t.Errorf("%d connections left idle after Close", len(p.idle))
+	}
+	// Releasing to a closed pool drops the connection, and a second Close does nothing
+	p.Release(conn)
+	p.Close()
+	if len(p.idle) != 0 {
+		t.Errorf("Release after Close put the connection back")
+	}
+}
+
+func TestPoolCloseWakesAcquire(t *testing.T) {
+	p := NewPool(1, 0)
+	if _, err := p.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error)
+	for range 3 {
+		go func() {
+			_, err := p.Acquire()
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	for range 3 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrPoolClosed) {
+				t.Errorf("got %v, want ErrPoolClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Acquire still waiting after Close")
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	p := NewPool(3, 0)
+	defer p.Close()
+	conn, _ := p.Acquire()
+	conn.healthy = false
+	p.Release(conn)
+	if replaced := p.HealthCheck(); replaced != 1 {
+		t.Errorf("HealthCheck replaced %d connections, want 1", replaced)
+	}
+	if replaced := p.HealthCheck(); replaced != 0 {
+		t.Errorf("second HealthCheck replaced %d connections, want 0", replaced)
+	}
+	for range 3 {
+		conn, err := p.Acquire()
+		if err != nil || conn.Ping() != nil {
+			t.Errorf("got %v, %v after HealthCheck", conn, err)
+		}
+	}
+	p.Close()
+	if replaced := p.HealthCheck(); replaced != 0 {
+		t.Errorf("HealthCheck of a closed pool replaced %d connections", replaced)
+	}
+}
+
+// BenchmarkGetPool compares the cost of getting the instance once it already exists.
+// The parallel runs call it from GOMAXPROCS goroutines, which is where the mutex
+// version suffers: every reader waits for the same lock. Compare with -cpu 1,4.
+func BenchmarkGetPool(b *testing.B) {
+	// Create both instances first so only the access path is measured
+	GetPool()
+	getPoolWithMutex()
+
+	cases := []struct {
+		name string
+		get  func() *Pool
+	}{
+		{"Mutex", getPoolWithMutex},
+		{"Once", GetPool},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			for b.Loop() {
+				c.get()
+			}
+		})
+		b.Run(c.name+"Parallel", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.get()
+				}
+			})
+		})
+	}
+}
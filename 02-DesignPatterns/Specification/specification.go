@@ -0,0 +1,223 @@
+// The Specification Pattern encapsulates a business rule in an object that answers a single
+// question: "does this candidate satisfy the rule?". Specifications can be combined with
+// boolean operators (And, Or, Not) to build complex rules from small, named, reusable pieces.
+//
+// Key benefits of the Specification Pattern:
+// - Business rules get a name and live in one place
+// - Complex rules are built by composing simple ones
+// - The same rule can be evaluated in memory or translated into a database query
+// - Repositories don't need one method per combination of filters
+//
+// Common use cases:
+// - Filtering products, orders or users with combinable criteria
+// - Validation rules shared between the UI and the backend
+// - Building dynamic queries from search forms
+//
+// In this example, we implement product specifications that:
+// 1. Define a Specification interface that evaluates a Product in memory
+// 2. Implement leaf specifications (price range, name contains, in stock)
+// 3. Compose them with And, Or and Not
+// 4. Translate the same specification into a SQL WHERE clause with placeholders,
+//    so the SQLite repository (see ../Repository) can run it in the database
+// 5. Filter products built by a factory and print the equivalent SQL
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Product is the candidate evaluated by the specifications
+// It has the same fields as the Product of the Repository example
+type Product struct {
+	ID    int64
+	Name  string
+	Price int
+	Stock int
+}
+
+// Specification is a business rule over products
+type Specification interface {
+	// IsSatisfiedBy evaluates the rule in memory
+	IsSatisfiedBy(p Product) bool
+	// SQL returns an equivalent WHERE clause with "?" placeholders and its arguments
+	SQL() (string, []any)
+}
+
+// PriceBetween is satisfied by products with min <= price <= max
+type PriceBetween struct {
+	Min, Max int
+}
+
+func (s PriceBetween) IsSatisfiedBy(p Product) bool {
+	return p.Price >= s.Min && p.Price <= s.Max
+}
+
+func (s PriceBetween) SQL() (string, []any) {
+	return "price BETWEEN ? AND ?", []any{s.Min, s.Max}
+}
+
+// NameContains is satisfied by products whose name contains the text (case insensitive)
+type NameContains struct {
+	Text string
+}
+
+func (s NameContains) IsSatisfiedBy(p Product) bool {
+	return strings.Contains(strings.ToLower(p.Name), strings.ToLower(s.Text))
+}
+
+func (s NameContains) SQL() (string, []any) {
+	// Escape the LIKE wildcards so they are matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s.Text)
+	return `name LIKE ? ESCAPE '\'`, []any{"%" + escaped + "%"}
+}
+
+// InStock is satisfied by products with a positive stock
+type InStock struct{}
+
+func (InStock) IsSatisfiedBy(p Product) bool {
+	return p.Stock > 0
+}
+
+func (InStock) SQL() (string, []any) {
+	return "stock > 0", nil
+}
+
+// andSpec is satisfied when every child specification is satisfied
+type andSpec struct {
+	specs []Specification
+}
+
+// And combines specifications that must all be satisfied
+func And(specs ...Specification) Specification {
+	return andSpec{specs: specs}
+}
+
+func (s andSpec) IsSatisfiedBy(p Product) bool {
+	for _, spec := range s.specs {
+		if !spec.IsSatisfiedBy(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s andSpec) SQL() (string, []any) {
+	return joinSQL(s.specs, " AND ", "1 = 1")
+}
+
+// orSpec is satisfied when at least one child specification is satisfied
+type orSpec struct {
+	specs []Specification
+}
+
+// Or combines specifications where at least one must be satisfied
+func Or(specs ...Specification) Specification {
+	return orSpec{specs: specs}
+}
+
+func (s orSpec) IsSatisfiedBy(p Product) bool {
+	for _, spec := range s.specs {
+		if spec.IsSatisfiedBy(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s orSpec) SQL() (string, []any) {
+	return joinSQL(s.specs, " OR ", "1 = 0")
+}
+
+// notSpec negates a specification
+type notSpec struct {
+	spec Specification
+}
+
+// Not is satisfied when the given specification is not
+func Not(spec Specification) Specification {
+	return notSpec{spec: spec}
+}
+
+func (s notSpec) IsSatisfiedBy(p Product) bool {
+	return !s.spec.IsSatisfiedBy(p)
+}
+
+func (s notSpec) SQL() (string, []any) {
+	clause, args := s.spec.SQL()
+	return "NOT (" + clause + ")", args
+}
+
+// joinSQL wraps every child clause in parentheses so operator precedence is preserved.
+// empty is used when there are no children: And() matches everything, Or() nothing.
+func joinSQL(specs []Specification, operator, empty string) (string, []any) {
+	if len(specs) == 0 {
+		return empty, nil
+	}
+	clauses := make([]string, len(specs))
+	var args []any
+	for i, spec := range specs {
+		clause, specArgs := spec.SQL()
+		clauses[i] = "(" + clause + ")"
+		args = append(args, specArgs...)
+	}
+	return strings.Join(clauses, operator), args
+}
+
+// Filter returns the products that satisfy the specification
+func Filter(products []Product, spec Specification) []Product {
+	var result []Product
+	for _, p := range products {
+		if spec.IsSatisfiedBy(p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Query builds the SELECT the SQLite repository would run for the specification
+func Query(spec Specification) (string, []any) {
+	where, args := spec.SQL()
+	return "SELECT id, name, price, stock FROM products WHERE " + where + " ORDER BY id", args
+}
+
+// newProduct is the constructor used by the catalog, like the Factory example constructors
+func newProduct(id int64, name string, price, stock int) Product {
+	return Product{ID: id, Name: name, Price: price, Stock: stock}
+}
+
+func main() {
+	catalog := []Product{
+		newProduct(1, "Laptop", 1200, 11),
+		newProduct(2, "Desktop", 900, 66),
+		newProduct(3, "Gaming Laptop", 2500, 0),
+		newProduct(4, "Tablet", 400, 23),
+		newProduct(5, "100% Cotton Laptop Sleeve", 30, 5),
+	}
+
+	// Small named rules...
+	affordable := PriceBetween{Min: 0, Max: 1000}
+	laptops := NameContains{Text: "laptop"}
+
+	// ...composed into bigger ones
+	specs := []struct {
+		name string
+		spec Specification
+	}{
+		{"affordable and in stock", And(affordable, InStock{})},
+		{"laptops that are not affordable", And(laptops, Not(affordable))},
+		{"laptops or tablets in stock", And(Or(laptops, NameContains{Text: "tablet"}), InStock{})},
+		{"name contains '100%'", NameContains{Text: "100%"}},
+	}
+
+	for _, s := range specs {
+		fmt.Printf("%s:\n", s.name)
+		for _, p := range Filter(catalog, s.spec) {
+			fmt.Printf("  #%d %s price=%d stock=%d\n", p.ID, p.Name, p.Price, p.Stock)
+		}
+		// The same specification, translated for the database
+		query, args := Query(s.spec)
+		fmt.Printf("  SQL: %s %v\n", query, args)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// catalog is the product list of main
+var catalog = []Product{
+	newProduct(1, "Laptop", 1200, 11),
+	newProduct(2, "Desktop", 900, 66),
+	newProduct(3, "Gaming Laptop", 2500, 0),
+	newProduct(4, "Tablet", 400, 23),
+	newProduct(5, "100% Cotton Laptop Sleeve", 30, 5),
+}
+
+// specCases are the specifications checked in memory and in SQLite, with the IDs they match
+var specCases = []struct {
+	name string
+	spec Specification
+	want []int64
+}{
+	{name: "affordable and in stock", spec: And(PriceBetween{Min: 0, Max: 1000}, InStock{}), want: []int64{2, 4, 5}},
+	{name: "laptops that are not affordable", spec: And(NameContains{Text: "laptop"}, Not(PriceBetween{Min: 0, Max: 1000})), want: []int64{1, 3}},
+	{name: "laptops or tablets in stock", spec: And(Or(NameContains{Text: "laptop"}, NameContains{Text: "tablet"}), InStock{}), want: []int64{1, 4, 5}},
+	{name: "percent sign is literal", spec: NameContains{Text: "100%"}, want: []int64{5}},
+	{name: "underscore is literal", spec: NameContains{Text: "_"}},
+	{name: "empty and matches everything", spec: And(), want: []int64{1, 2, 3, 4, 5}},
+	{name: "empty or matches nothing", spec: Or()},
+}
+
+// ids returns the IDs of the products
+func ids(products []Product) []int64 {
+	var result []int64
+	for _, p := range products {
+		result = append(result, p.ID)
+	}
+	return result
+}
+
+// TestFilter evaluates the specifications in memory
+func TestFilter(t *testing.T) {
+	for _, c := range specCases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := ids(Filter(catalog, c.spec)); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestQuery runs the SQL translation of every specification in SQLite and checks it
+// matches the same products as the evaluation in memory
+func TestQuery(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT, price INTEGER, stock INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range catalog {
+		if _, err := db.Exec(`INSERT INTO products VALUES (?, ?, ?, ?)`, p.ID, p.Name, p.Price, p.Stock); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range specCases {
+		t.Run(c.name, func(t *testing.T) {
+			query, args := Query(c.spec)
+			rows, err := db.Query(query, args...)
+			if err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			defer rows.Close()
+			var got []Product
+			for rows.Next() {
+				var p Product
+				if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, p)
+			}
+			if !reflect.DeepEqual(ids(got), c.want) {
+				t.Errorf("%s %v: got %v, want %v", query, args, ids(got), c.want)
+			}
+		})
+	}
+}
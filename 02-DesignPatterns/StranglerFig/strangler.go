@@ -0,0 +1,293 @@
+// The Strangler Fig Pattern replaces a legacy system incrementally: a facade sits in front of
+// the old component and routes a growing share of the calls to the new implementation, until
+// the old one receives no traffic and can be removed. The name comes from the strangler fig
+// tree, which grows around a host tree until it replaces it.
+//
+// The Anti-Corruption Layer (ACL) protects the new code from the legacy model. The legacy
+// component speaks its own language (cents as strings, status codes, cryptic field names);
+// the ACL translates it into the clean domain model, so the legacy concepts don't leak.
+//
+// Key benefits of the combination:
+// - Migration happens in small, reversible steps instead of a risky "big bang" rewrite
+// - The rollout percentage is configuration: it can go up, or back to 0 on problems
+// - Metrics comparing both implementations tell when it is safe to increase the share
+// - The new code is written against the new model only
+//
+// Common use cases:
+// - Migrating a monolith to services one feature at a time
+// - Replacing a third-party provider (payments, email, storage)
+// - Canary releases of a rewritten component
+//
+// In this example, we implement a payment migration where:
+// 1. LegacyPaymentGateway has an old API and data model
+// 2. LegacyAdapter is the anti-corruption layer translating it to the PaymentProcessor interface
+// 3. NewPaymentService implements PaymentProcessor natively
+// 4. RoutingFacade sends a configurable percentage of payments to the new implementation
+// 5. Metrics per implementation compare success rate and latency
+// RUN PROGRAM WITH FLAGS
+// go run . --rollout=30
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// New domain model
+// ---------------------------------------------------------------------------
+
+// Errors of the new payment domain
+var (
+	ErrDeclined       = errors.New("payment declined")
+	ErrInvalidPayment = errors.New("invalid payment")
+)
+
+// Payment is the clean model used by the new code
+type Payment struct {
+	ID       string
+	Customer string
+	Amount   int // In cents
+	Currency string
+}
+
+// Receipt is returned for successful payments
+type Receipt struct {
+	PaymentID   string
+	Reference   string
+	ProcessedBy string
+}
+
+// PaymentProcessor is the interface both implementations satisfy
+type PaymentProcessor interface {
+	Process(p Payment) (Receipt, error)
+}
+
+// ---------------------------------------------------------------------------
+// Legacy component, can't be modified
+// ---------------------------------------------------------------------------
+
+// LegacyTxn is the legacy data model
+type LegacyTxn struct {
+	TxnNo   string
+	CustRef string
+	AmtStr  string // Amount with two decimals, e.g. "12.50"
+	Curr    int    // Numeric currency code, 840 = USD, 484 = MXN
+}
+
+// Legacy result codes
+const (
+	legacyOK       = "00"
+	legacyDeclined = "05"
+	legacyBadData  = "30"
+)
+
+// LegacyPaymentGateway is the old component being replaced; it is slow and fails sometimes
+type LegacyPaymentGateway struct{}
+
+// Submit returns a result code and an authorization number
+func (LegacyPaymentGateway) Submit(txn LegacyTxn) (string, string) {
+	time.Sleep(time.Duration(20+rand.Intn(20)) * time.Millisecond)
+	if txn.Curr != 840 && txn.Curr != 484 {
+		return legacyBadData, ""
+	}
+	if rand.Intn(10) == 0 {
+		return legacyDeclined, ""
+	}
+	return legacyOK, fmt.Sprintf("AUTH%06d", rand.Intn(1000000))
+}
+
+// ---------------------------------------------------------------------------
+// Anti-corruption layer
+// ---------------------------------------------------------------------------
+
+// LegacyAdapter translates between the new model and the legacy gateway
+type LegacyAdapter struct {
+	gateway LegacyPaymentGateway
+}
+
+// currencyCodes maps the ISO codes of the new model to the legacy numeric codes
+var currencyCodes = map[string]int{"USD": 840, "MXN": 484}
+
+// Process translates the payment, calls the legacy gateway and translates the answer back
+func (a *LegacyAdapter) Process(p Payment) (Receipt, error) {
+	code, known := currencyCodes[p.Currency]
+	if !known {
+		return Receipt{}, fmt.Errorf("%w: currency %s", ErrInvalidPayment, p.Currency)
+	}
+	txn := LegacyTxn{
+		TxnNo:   strings.ToUpper(p.ID),
+		CustRef: p.Customer,
+		AmtStr:  strconv.Itoa(p.Amount/100) + "." + fmt.Sprintf("%02d", p.Amount%100),
+		Curr:    code,
+	}
+
+	result, auth := a.gateway.Submit(txn)
+	switch result {
+	case legacyOK:
+		return Receipt{PaymentID: p.ID, Reference: auth, ProcessedBy: "legacy"}, nil
+	case legacyDeclined:
+		return Receipt{}, ErrDeclined
+	default:
+		return Receipt{}, fmt.Errorf("%w: legacy result code %s", ErrInvalidPayment, result)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// New implementation
+// ---------------------------------------------------------------------------
+
+// NewPaymentService is the replacement, written against the new model only
+type NewPaymentService struct{}
+
+func (NewPaymentService) Process(p Payment) (Receipt, error) {
+	if p.Amount <= 0 {
+		return Receipt{}, fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+	if _, known := currencyCodes[p.Currency]; !known {
+		return Receipt{}, fmt.Errorf("%w: currency %s", ErrInvalidPayment, p.Currency)
+	}
+	time.Sleep(time.Duration(5+rand.Intn(10)) * time.Millisecond)
+	if rand.Intn(20) == 0 {
+		return Receipt{}, ErrDeclined
+	}
+	return Receipt{PaymentID: p.ID, Reference: "pay_" + p.ID, ProcessedBy: "new"}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Routing facade and metrics
+// ---------------------------------------------------------------------------
+
+// Metrics accumulates the outcome of the calls to one implementation
+type Metrics struct {
+	Calls     int
+	Failures  int
+	TotalTime time.Duration
+}
+
+// RoutingFacade is the single entry point; it decides which implementation handles each call
+type RoutingFacade struct {
+	legacy  PaymentProcessor
+	next    PaymentProcessor
+	percent int // Share of payments routed to the new implementation, 0-100
+	metrics map[string]*Metrics
+	mux     sync.Mutex
+}
+
+// NewRoutingFacade creates a facade routing percent% of the calls to next
+func NewRoutingFacade(legacy, next PaymentProcessor, percent int) *RoutingFacade {
+	return &RoutingFacade{
+		legacy:  legacy,
+		next:    next,
+		percent: percent,
+		metrics: map[string]*Metrics{"legacy": {}, "new": {}},
+	}
+}
+
+// SetRollout changes the share of calls sent to the new implementation at runtime
+func (f *RoutingFacade) SetRollout(percent int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.percent = max(0, min(100, percent))
+}
+
+// Process routes the payment. The decision is a hash of the customer, so the same customer
+// always hits the same implementation for a given rollout, instead of flip-flopping.
+func (f *RoutingFacade) Process(p Payment) (Receipt, error) {
+	f.mux.Lock()
+	percent := f.percent
+	f.mux.Unlock()
+
+	name, processor := "legacy", f.legacy
+	if bucket(p.Customer) < percent {
+		name, processor = "new", f.next
+	}
+
+	start := time.Now()
+	receipt, err := processor.Process(p)
+	elapsed := time.Since(start)
+
+	f.mux.Lock()
+	m := f.metrics[name]
+	m.Calls++
+	m.TotalTime += elapsed
+	if err != nil {
+		m.Failures++
+	}
+	f.mux.Unlock()
+	return receipt, err
+}
+
+// Report prints the comparison between both implementations
+func (f *RoutingFacade) Report() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	names := make([]string, 0, len(f.metrics))
+	for name := range f.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := f.metrics[name]
+		if m.Calls == 0 {
+			fmt.Printf("  %-6s no calls\n", name)
+			continue
+		}
+		fmt.Printf("  %-6s calls=%3d success=%5.1f%% avg latency=%s\n", name, m.Calls,
+			100*float64(m.Calls-m.Failures)/float64(m.Calls), (m.TotalTime / time.Duration(m.Calls)).Round(time.Millisecond))
+	}
+}
+
+// bucket maps a customer to a stable number between 0 and 99
+func bucket(customer string) int {
+	h := fnv.New32a()
+	h.Write([]byte(customer))
+	return int(h.Sum32() % 100)
+}
+
+// Percentage of payments sent to the new implementation
+var rollout = flag.Int("rollout", 30, "percentage of payments routed to the new implementation")
+
+func main() {
+	flag.Parse()
+
+	facade := NewRoutingFacade(&LegacyAdapter{}, NewPaymentService{}, *rollout)
+
+	// Clients only know the facade and the new model
+	run := func(n int) {
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				facade.Process(Payment{
+					ID:       fmt.Sprintf("p-%d", i),
+					Customer: fmt.Sprintf("customer-%d", i%50),
+					Amount:   1250 + i,
+					Currency: []string{"USD", "MXN"}[i%2],
+				})
+			}()
+		}
+		wg.Wait()
+	}
+
+	fmt.Printf("Rollout %d%%\n", *rollout)
+	run(200)
+	facade.Report()
+
+	// The metrics look good: move everybody to the new implementation
+	fmt.Println("Rollout 100%")
+	facade.SetRollout(100)
+	run(200)
+	facade.Report()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeProcessor records the payments it receives and fails when Err is set
+type fakeProcessor struct {
+	name     string
+	err      error
+	payments []Payment
+}
+
+func (f *fakeProcessor) Process(p Payment) (Receipt, error) {
+	f.payments = append(f.payments, p)
+	if f.err != nil {
+		return Receipt{}, f.err
+	}
+	return Receipt{PaymentID: p.ID, ProcessedBy: f.name}, nil
+}
+
+// TestRouting checks the share of customers sent to the new implementation
+func TestRouting(t *testing.T) {
+	cases := []struct {
+		rollout int
+		wantNew func(n int) bool
+	}{
+		{rollout: 0, wantNew: func(n int) bool { return n == 0 }},
+		{rollout: 30, wantNew: func(n int) bool { return n > 15 && n < 45 }},
+		{rollout: 100, wantNew: func(n int) bool { return n == 100 }},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%d%%", c.rollout), func(t *testing.T) {
+			legacy, next := &fakeProcessor{name: "legacy"}, &fakeProcessor{name: "new"}
+			facade := NewRoutingFacade(legacy, next, c.rollout)
+			for i := range 100 {
+				receipt, err := facade.Process(Payment{ID: fmt.Sprint(i), Customer: fmt.Sprintf("customer-%d", i), Amount: 100, Currency: "USD"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := "legacy"
+				if bucket(fmt.Sprintf("customer-%d", i)) < c.rollout {
+					want = "new"
+				}
+				if receipt.ProcessedBy != want {
+					t.Errorf("payment %d processed by %s, want %s", i, receipt.ProcessedBy, want)
+				}
+			}
+			if !c.wantNew(len(next.payments)) || len(legacy.payments)+len(next.payments) != 100 {
+				t.Errorf("got %d new and %d legacy calls", len(next.payments), len(legacy.payments))
+			}
+			if facade.metrics["new"].Calls != len(next.payments) || facade.metrics["legacy"].Calls != len(legacy.payments) {
+				t.Errorf("the metrics don't match the calls: %+v %+v", *facade.metrics["new"], *facade.metrics["legacy"])
+			}
+		})
+	}
+}
+
+// TestStickyRouting checks that a customer keeps its implementation for a given rollout
+// and that raising the rollout never moves a customer back to legacy
+func TestStickyRouting(t *testing.T) {
+	legacy, next := &fakeProcessor{name: "legacy"}, &fakeProcessor{name: "new"}
+	facade := NewRoutingFacade(legacy, next, 50)
+	routes := make(map[string]string)
+	for i := range 200 {
+		customer := fmt.Sprintf("customer-%d", i%20)
+		receipt, _ := facade.Process(Payment{ID: fmt.Sprint(i), Customer: customer})
+		if previous, seen := routes[customer]; seen && previous != receipt.ProcessedBy {
+			t.Fatalf("%s moved from %s to %s", customer, previous, receipt.ProcessedBy)
+		}
+		routes[customer] = receipt.ProcessedBy
+	}
+
+	facade.SetRollout(80)
+	for customer, route := range routes {
+		if receipt, _ := facade.Process(Payment{Customer: customer}); route == "new" && receipt.ProcessedBy != "new" {
+			t.Errorf("%s went back to legacy at 80%%", customer)
+		}
+	}
+}
+
+// TestMetricsFailures checks that the failures are counted for the implementation that failed
+func TestMetricsFailures(t *testing.T) {
+	facade := NewRoutingFacade(&fakeProcessor{name: "legacy"}, &fakeProcessor{name: "new", err: ErrDeclined}, 100)
+	for range 3 {
+		if _, err := facade.Process(Payment{Customer: "ana"}); !errors.Is(err, ErrDeclined) {
+			t.Fatalf("got %v, want ErrDeclined", err)
+		}
+	}
+	if m := facade.metrics["new"]; m.Calls != 3 || m.Failures != 3 {
+		t.Errorf("got %+v, want 3 failed calls", *m)
+	}
+}
+
+// TestProcessors checks the translation of the legacy answers and the validation of both
+// implementations. The gateways decline at random, so a decline is an accepted outcome.
+func TestProcessors(t *testing.T) {
+	cases := []struct {
+		name      string
+		processor PaymentProcessor
+		payment   Payment
+		wantBy    string
+		wantErr   error
+	}{
+		{name: "legacy", processor: &LegacyAdapter{}, payment: Payment{ID: "p-1", Amount: 1250, Currency: "MXN"}, wantBy: "legacy"},
+		{name: "legacy unknown currency", processor: &LegacyAdapter{}, payment: Payment{ID: "p-1", Amount: 1250, Currency: "EUR"}, wantErr: ErrInvalidPayment},
+		{name: "new", processor: NewPaymentService{}, payment: Payment{ID: "p-1", Amount: 1250, Currency: "USD"}, wantBy: "new"},
+		{name: "new zero amount", processor: NewPaymentService{}, payment: Payment{ID: "p-1", Currency: "USD"}, wantErr: ErrInvalidPayment},
+		{name: "new unknown currency", processor: NewPaymentService{}, payment: Payment{ID: "p-1", Amount: 1250, Currency: "EUR"}, wantErr: ErrInvalidPayment},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			receipt, err := c.processor.Process(c.payment)
+			if c.wantErr != nil {
+				if !errors.Is(err, c.wantErr) {
+					t.Errorf("got %v, want %v", err, c.wantErr)
+				}
+				return
+			}
+			if errors.Is(err, ErrDeclined) {
+				return
+			}
+			if err != nil || receipt.ProcessedBy != c.wantBy || receipt.PaymentID != c.payment.ID || receipt.Reference == "" {
+				t.Errorf("got %+v, %v", receipt, err)
+			}
+		})
+	}
+}
@@ -9,14 +9,36 @@
 //
 // In this example, we implement a password protection system that:
 // 1. Defines a HashAlgorithm interface for different hashing strategies
-// 2. Has concrete implementations (SHA, MD5) of the hashing interface
+// 2. Has concrete implementations (SHA-256, bcrypt, argon2id) of the hashing interface
 // 3. Uses a PasswordProtector that can work with any hash algorithm
 // 4. Allows switching between hash algorithms at runtime
 // 5. Demonstrates how different strategies can be used interchangeably
+// 6. Benchmarks the strategies with different cost parameters (see strategy_test.go)
+//
+// Note: a plain salted SHA-256 is fast, which is exactly what you don't want for passwords.
+// It is included to show the trade-off; bcrypt and argon2id are deliberately slow.
+// RUN PROGRAM WITH FLAGS
+// go run .
+// go test .
+// go test -run x -bench . -benchmem
 
 package main
 
-import "fmt"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMismatchedHash is returned by Verify when the password doesn't match the hash
+var ErrMismatchedHash = errors.New("password does not match the hash")
 
 // PasswordProtector holds user credentials and the selected hash algorithm
 type PasswordProtector struct {
@@ -27,7 +49,10 @@ type PasswordProtector struct {
 
 // HashAlgorithm defines the interface that all hash strategies must implement
 type HashAlgorithm interface {
-	Hash(p *PasswordProtector)
+	// Hash returns an encoded hash that includes everything needed to verify it later
+	Hash(password string) (string, error)
+	// Verify checks a password against a hash created by Hash
+	Verify(password, hash string) error
 }
 
 // NewPasswordProtector creates a new PasswordProtector instance with the specified hash algorithm
@@ -45,34 +70,174 @@ func (p *PasswordProtector) SetHashAlgorithm(hashAlgorithm HashAlgorithm) {
 }
 
 // Hash executes the selected hash algorithm on the password
-func (p *PasswordProtector) Hash() {
-	p.hashAlgorithm.Hash(p)
+func (p *PasswordProtector) Hash() (string, error) {
+	return p.hashAlgorithm.Hash(p.password)
+}
+
+// Verify checks the given password against a hash using the selected algorithm
+func (p *PasswordProtector) Verify(password, hash string) error {
+	return p.hashAlgorithm.Verify(password, hash)
+}
+
+// SHA256 implements the HashAlgorithm interface using a random salt and SHA-256
+// Encoded hash format: sha256$<salt>$<digest>
+type SHA256 struct{}
+
+func (s *SHA256) Hash(password string) (string, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(append(salt, password...))
+	return fmt.Sprintf("sha256$%s$%s", encode(salt), encode(digest[:])), nil
+}
+
+func (s *SHA256) Verify(password, hash string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 3 || parts[0] != "sha256" {
+		return fmt.Errorf("invalid sha256 hash format")
+	}
+	salt, err := decode(parts[1])
+	if err != nil {
+		return err
+	}
+	want, err := decode(parts[2])
+	if err != nil {
+		return err
+	}
+	got := sha256.Sum256(append(salt, password...))
+	// Constant time comparison avoids leaking information through timing
+	if subtle.ConstantTimeCompare(got[:], want) != 1 {
+		return ErrMismatchedHash
+	}
+	return nil
+}
+
+// Bcrypt implements the HashAlgorithm interface using bcrypt
+// Cost is the log2 of the number of rounds, every +1 doubles the time
+type Bcrypt struct {
+	Cost int
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b *Bcrypt) Verify(password, hash string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatchedHash
+	}
+	return err
+}
+
+// Argon2id implements the HashAlgorithm interface using argon2id
+// Time is the number of passes and Memory the memory used in KiB
+// Encoded hash format: argon2id$<time>$<memory>$<threads>$<salt>$<key>
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
 }
 
-// SHA implements the HashAlgorithm interface using SHA strategy
-type SHA struct{}
+// argon2KeyLength is the length of the derived key in bytes
+const argon2KeyLength = 32
 
-func (s *SHA) Hash(p *PasswordProtector) {
-	fmt.Printf("Hashing password for %s using SHA\n", p.user)
+// Limits of the parameters accepted by Verify. They come from the hash, which may be
+// crafted: argon2.IDKey panics with zero passes or threads, allocates whatever memory
+// it is given, and an empty key would match any password
+const (
+	argon2MaxTime      = 100
+	argon2MaxMemory    = 1024 * 1024 // KiB, 1 GiB
+	argon2MinKeyLength = 16
+)
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, argon2KeyLength)
+	return fmt.Sprintf("argon2id$%d$%d$%d$%s$%s", a.Time, a.Memory, a.Threads, encode(salt), encode(key)), nil
 }
 
-// MD5 implements the HashAlgorithm interface using MD5 strategy
-type MD5 struct{}
+func (a *Argon2id) Verify(password, hash string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "argon2id" {
+		return fmt.Errorf("invalid argon2id hash format")
+	}
+	// The parameters are read from the hash, so old hashes keep working after a cost change
+	var time, memory uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(strings.Join(parts[1:4], " "), "%d %d %d", &time, &memory, &threads); err != nil {
+		return fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if time < 1 || time > argon2MaxTime || memory > argon2MaxMemory || threads < 1 {
+		return fmt.Errorf("invalid argon2id parameters: t=%d, m=%d, p=%d", time, memory, threads)
+	}
+	salt, err := decode(parts[4])
+	if err != nil {
+		return err
+	}
+	want, err := decode(parts[5])
+	if err != nil {
+		return err
+	}
+	if len(want) < argon2MinKeyLength {
+		return fmt.Errorf("invalid argon2id key of %d bytes", len(want))
+	}
+	got := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrMismatchedHash
+	}
+	return nil
+}
+
+// randomBytes returns n bytes from the cryptographically secure generator
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
 
-func (m *MD5) Hash(p *PasswordProtector) {
-	fmt.Printf("Hashing password for %s using MD5\n", p.user)
+func decode(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
 }
 
 func main() {
 	// Create instances of different hash strategies
-	sha := &SHA{}
-	md5 := &MD5{}
+	strategies := []struct {
+		name      string
+		algorithm HashAlgorithm
+	}{
+		{"SHA-256", &SHA256{}},
+		{"bcrypt", &Bcrypt{Cost: bcrypt.DefaultCost}},
+		{"argon2id", &Argon2id{Time: 1, Memory: 64 * 1024, Threads: 4}},
+	}
+
+	// Create password protector with the initial strategy
+	passwordProtector := NewPasswordProtector("Andres", "password", strategies[0].algorithm)
 
-	// Create password protector with initial SHA strategy
-	passwordProtector := NewPasswordProtector("Andres", "password", sha)
-	passwordProtector.Hash()
+	for _, strategy := range strategies {
+		// Switch strategy at runtime
+		passwordProtector.SetHashAlgorithm(strategy.algorithm)
+
+		hash, err := passwordProtector.Hash()
+		if err != nil {
+			fmt.Printf("%s: hash error: %v\n", strategy.name, err)
+			continue
+		}
+		fmt.Printf("Hashing password for %s using %s: %s\n", passwordProtector.user, strategy.name, hash)
+		fmt.Printf("  correct password: %v\n", passwordProtector.Verify("password", hash))
+		fmt.Printf("  wrong password:   %v\n", passwordProtector.Verify("passw0rd", hash))
+	}
 
-	// Switch to MD5 strategy at runtime
-	passwordProtector.SetHashAlgorithm(md5)
-	passwordProtector.Hash()
+	// A crafted hash with zero passes is refused instead of making argon2 panic
+	crafted := "argon2id$0$65536$4$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	fmt.Printf("Crafted argon2id hash: %v\n", passwordProtector.Verify("password", crafted))
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+)
+
+// cheap parameters of every strategy, so the tests don't spend seconds hashing
+var strategies = []struct {
+	name      string
+	algorithm HashAlgorithm
+}{
+	{"SHA256", &SHA256{}},
+	{"Bcrypt", &Bcrypt{Cost: 4}},
+	{"Argon2id", &Argon2id{Time: 1, Memory: 64, Threads: 1}},
+}
+
+func TestHashVerify(t *testing.T) {
+	for _, s := range strategies {
+		t.Run(s.name, func(t *testing.T) {
+			hash, err := s.algorithm.Hash("password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.algorithm.Verify("password", hash); err != nil {
+				t.Errorf("the right password was refused: %v", err)
+			}
+			if err := s.algorithm.Verify("Password", hash); err == nil {
+				t.Error("a wrong password was accepted")
+			}
+			// Every hash has its own salt
+			if other, _ := s.algorithm.Hash("password"); other == hash {
+				t.Errorf("two hashes of the same password are equal: %s", hash)
+			}
+		})
+	}
+}
+
+func TestSwitchStrategy(t *testing.T) {
+	protector := NewPasswordProtector("alice", "password", &SHA256{})
+	hash, err := protector.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	protector.SetHashAlgorithm(&Bcrypt{Cost: 4})
+	if err := protector.Verify("password", hash); err == nil {
+		t.Error("bcrypt accepted a SHA-256 hash")
+	}
+	if hash, err = protector.Hash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := protector.Verify("password", hash); err != nil {
+		t.Errorf("bcrypt refused its own hash: %v", err)
+	}
+}
+
+// BenchmarkHash measures how the cost parameters change the time needed to hash a password
+func BenchmarkHash(b *testing.B) {
+	cases := []struct {
+		name      string
+		algorithm HashAlgorithm
+	}{
+		{"SHA256", &SHA256{}},
+		{"Bcrypt/cost=8", &Bcrypt{Cost: 8}},
+		{"Bcrypt/cost=10", &Bcrypt{Cost: 10}},
+		{"Bcrypt/cost=12", &Bcrypt{Cost: 12}},
+		{"Argon2id/t=1,m=16MiB", &Argon2id{Time: 1, Memory: 16 * 1024, Threads: 4}},
+		{"Argon2id/t=1,m=64MiB", &Argon2id{Time: 1, Memory: 64 * 1024, Threads: 4}},
+		{"Argon2id/t=3,m=64MiB", &Argon2id{Time: 3, Memory: 64 * 1024, Threads: 4}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := c.algorithm.Hash("password"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
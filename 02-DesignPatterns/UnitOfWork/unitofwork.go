@@ -0,0 +1,368 @@
+// The Unit of Work Pattern keeps track of everything you change during a business transaction
+// and writes all the changes at once when you are done. Either every change is committed or,
+// if anything fails, none of them is, even when the changes span several repositories.
+//
+// Key benefits of the Unit of Work Pattern:
+// - Groups changes to several repositories into a single atomic operation
+// - Business code doesn't need to know the order in which changes must be written
+// - Changes are tracked as new, dirty (modified) or removed until Commit
+// - Rollback simply forgets the pending changes
+//
+// Common use cases:
+// - Placing an order: decrease stock, create the order and empty the cart together
+// - Working with repositories on top of a database transaction
+// - Batching writes to reduce round trips to storage
+//
+// In this example, we implement:
+// 1. A generic in-memory Repository that supports transactional writes
+// 2. A UnitOfWork that tracks new, dirty and removed entities across repositories
+// 3. Commit, which locks every repository, applies all changes, and restores the
+//    previous state of every repository if any change fails
+// 4. Rollback, which discards the pending changes
+// 5. A check that a failed commit leaves every repository untouched (see unitofwork_test.go)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned when a change can't be applied
+var (
+	ErrNotFound      = errors.New("entity not found")
+	ErrAlreadyExists = errors.New("entity already exists")
+	ErrUnknownKind   = errors.New("no repository registered for entity kind")
+)
+
+// Entity is anything stored in a repository
+type Entity interface {
+	// Kind identifies the repository that stores the entity
+	Kind() string
+	// ID identifies the entity inside its repository
+	ID() string
+}
+
+// changeState is the state tracked for an entity inside a unit of work
+type changeState int
+
+const (
+	stateNew changeState = iota
+	stateDirty
+	stateRemoved
+)
+
+func (s changeState) String() string {
+	return [...]string{"new", "dirty", "removed"}[s]
+}
+
+// transactional is what the unit of work needs from a repository
+type transactional interface {
+	kind() string
+	lock()
+	unlock()
+	// snapshot and restore are used to undo a partially applied commit
+	snapshot() any
+	restore(snapshot any)
+	apply(state changeState, entity Entity) error
+}
+
+// Repository stores entities of type T in memory
+type Repository[T Entity] struct {
+	name  string
+	items map[string]T
+	mux   sync.RWMutex
+}
+
+// NewRepository creates an empty repository for entities of the given kind
+func NewRepository[T Entity](kind string) *Repository[T] {
+	return &Repository[T]{name: kind, items: make(map[string]T)}
+}
+
+// Get returns an entity by ID
+func (r *Repository[T]) Get(id string) (T, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	item, exists := r.items[id]
+	if !exists {
+		return item, fmt.Errorf("%s %s: %w", r.name, id, ErrNotFound)
+	}
+	return item, nil
+}
+
+// Len returns the number of stored entities
+func (r *Repository[T]) Len() int {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return len(r.items)
+}
+
+func (r *Repository[T]) kind() string { return r.name }
+func (r *Repository[T]) lock()        { r.mux.Lock() }
+func (r *Repository[T]) unlock()      { r.mux.Unlock() }
+
+func (r *Repository[T]) snapshot() any {
+	copied := make(map[string]T, len(r.items))
+	for id, item := range r.items {
+		copied[id] = item
+	}
+	return copied
+}
+
+func (r *Repository[T]) restore(snapshot any) {
+	r.items = snapshot.(map[string]T)
+}
+
+// apply writes a single change; it is called with the repository locked
+func (r *Repository[T]) apply(state changeState, entity Entity) error {
+	item, ok := entity.(T)
+	if !ok {
+		return fmt.Errorf("%s repository can't store %T", r.name, entity)
+	}
+	_, exists := r.items[item.ID()]
+
+	switch state {
+	case stateNew:
+		if exists {
+			return fmt.Errorf("%s %s: %w", r.name, item.ID(), ErrAlreadyExists)
+		}
+		r.items[item.ID()] = item
+	case stateDirty:
+		if !exists {
+			return fmt.Errorf("%s %s: %w", r.name, item.ID(), ErrNotFound)
+		}
+		r.items[item.ID()] = item
+	case stateRemoved:
+		if !exists {
+			return fmt.Errorf("%s %s: %w", r.name, item.ID(), ErrNotFound)
+		}
+		delete(r.items, item.ID())
+	}
+	return nil
+}
+
+// change is a pending modification tracked by the unit of work
+type change struct {
+	state  changeState
+	entity Entity
+}
+
+// UnitOfWork tracks changes to entities and writes them atomically on Commit
+type UnitOfWork struct {
+	repositories map[string]transactional
+	changes      map[string]*change // Keyed by kind + ID, so every entity is tracked once
+	order        []string           // Keys in registration order, to apply changes deterministically
+	mux          sync.Mutex
+}
+
+// NewUnitOfWork creates a unit of work over the given repositories
+func NewUnitOfWork(repositories ...transactional) *UnitOfWork {
+	uow := &UnitOfWork{
+		repositories: make(map[string]transactional),
+		changes:      make(map[string]*change),
+	}
+	for _, repo := range repositories {
+		uow.repositories[repo.kind()] = repo
+	}
+	return uow
+}
+
+// RegisterNew tracks an entity that must be inserted
+func (u *UnitOfWork) RegisterNew(entity Entity) {
+	u.track(stateNew, entity)
+}
+
+// RegisterDirty tracks an entity that must be updated
+func (u *UnitOfWork) RegisterDirty(entity Entity) {
+	u.track(stateDirty, entity)
+}
+
+// RegisterRemoved tracks an entity that must be deleted
+func (u *UnitOfWork) RegisterRemoved(entity Entity) {
+	u.track(stateRemoved, entity)
+}
+
+// track merges the new state with the one already tracked for the entity:
+// - a new entity modified later is still new (with the latest values)
+// - a new entity removed before commit never reaches the repository
+func (u *UnitOfWork) track(state changeState, entity Entity) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	key := entity.Kind() + "/" + entity.ID()
+	existing, tracked := u.changes[key]
+	if !tracked {
+		u.changes[key] = &change{state: state, entity: entity}
+		u.order = append(u.order, key)
+		return
+	}
+
+	switch {
+	case existing.state == stateNew && state == stateDirty:
+		existing.entity = entity
+	case existing.state == stateNew && state == stateRemoved:
+		delete(u.changes, key)
+	default:
+		existing.state = state
+		existing.entity = entity
+	}
+}
+
+// Pending describes the tracked changes, for logging
+func (u *UnitOfWork) Pending() []string {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	var pending []string
+	for _, key := range u.order {
+		if c, tracked := u.changes[key]; tracked {
+			pending = append(pending, c.state.String()+" "+key)
+		}
+	}
+	return pending
+}
+
+// Commit applies every tracked change atomically.
+// If a change fails, every repository is restored to its state before the commit.
+func (u *UnitOfWork) Commit() error {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	// Lock the repositories in a fixed order so concurrent commits can't deadlock
+	kinds := make([]string, 0, len(u.repositories))
+	for kind := range u.repositories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	snapshots := make(map[string]any, len(kinds))
+	for _, kind := range kinds {
+		repo := u.repositories[kind]
+		repo.lock()
+		defer repo.unlock()
+		snapshots[kind] = repo.snapshot()
+	}
+
+	for _, key := range u.order {
+		c, tracked := u.changes[key]
+		if !tracked {
+			continue
+		}
+		repo, exists := u.repositories[c.entity.Kind()]
+		err := fmt.Errorf("%w: %s", ErrUnknownKind, c.entity.Kind())
+		if exists {
+			err = repo.apply(c.state, c.entity)
+		}
+		if err != nil {
+			for kind, snapshot := range snapshots {
+				u.repositories[kind].restore(snapshot)
+			}
+			return fmt.Errorf("commit rolled back: %w", err)
+		}
+	}
+
+	u.reset()
+	return nil
+}
+
+// Rollback discards every tracked change
+func (u *UnitOfWork) Rollback() {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	u.reset()
+}
+
+func (u *UnitOfWork) reset() {
+	u.changes = make(map[string]*change)
+	u.order = nil
+}
+
+// Product is stored in the products repository
+type Product struct {
+	SKU   string
+	Name  string
+	Stock int
+}
+
+func (p Product) Kind() string { return "product" }
+func (p Product) ID() string   { return p.SKU }
+
+// Order is stored in the orders repository
+type Order struct {
+	Number string
+	SKU    string
+	Units  int
+}
+
+func (o Order) Kind() string { return "order" }
+func (o Order) ID() string   { return o.Number }
+
+// Cart is stored in the carts repository and is emptied when the order is placed
+type Cart struct {
+	Customer string
+	SKU      string
+	Units    int
+}
+
+func (c Cart) Kind() string { return "cart" }
+func (c Cart) ID() string   { return c.Customer }
+
+// placeOrder turns a cart into an order using a unit of work.
+// Nothing is written until Commit, so a failure leaves every repository unchanged.
+func placeOrder(uow *UnitOfWork, products *Repository[Product], carts *Repository[Cart], customer, number string) error {
+	cart, err := carts.Get(customer)
+	if err != nil {
+		return err
+	}
+	product, err := products.Get(cart.SKU)
+	if err != nil {
+		return err
+	}
+	if product.Stock < cart.Units {
+		return fmt.Errorf("not enough stock for %s", product.Name)
+	}
+
+	product.Stock -= cart.Units
+	uow.RegisterDirty(product)
+	uow.RegisterNew(Order{Number: number, SKU: cart.SKU, Units: cart.Units})
+	uow.RegisterRemoved(cart)
+
+	fmt.Printf("Pending changes: %v\n", uow.Pending())
+	return uow.Commit()
+}
+
+func main() {
+	products := NewRepository[Product]("product")
+	orders := NewRepository[Order]("order")
+	carts := NewRepository[Cart]("cart")
+
+	// Seed the repositories through a first unit of work
+	seed := NewUnitOfWork(products, orders, carts)
+	seed.RegisterNew(Product{SKU: "rtx-5090", Name: "RTX 5090", Stock: 5})
+	seed.RegisterNew(Cart{Customer: "ana", SKU: "rtx-5090", Units: 2})
+	seed.RegisterNew(Cart{Customer: "luis", SKU: "rtx-5090", Units: 1})
+	if err := seed.Commit(); err != nil {
+		fmt.Println("Seed error:", err)
+		return
+	}
+
+	// A successful business transaction touching three repositories
+	uow := NewUnitOfWork(products, orders, carts)
+	if err := placeOrder(uow, products, carts, "ana", "order-1"); err != nil {
+		fmt.Println("Order failed:", err)
+	}
+	product, _ := products.Get("rtx-5090")
+	fmt.Printf("Stock: %d, orders: %d, carts: %d\n", product.Stock, orders.Len(), carts.Len())
+
+	// order-1 already exists: the commit fails and the stock update is undone
+	if err := placeOrder(uow, products, carts, "luis", "order-1"); err != nil {
+		fmt.Println("Order failed:", err)
+	}
+	product, _ = products.Get("rtx-5090")
+	fmt.Printf("Stock: %d, orders: %d, carts: %d\n", product.Stock, orders.Len(), carts.Len())
+
+	// Rollback discards the pending changes of the failed attempt
+	uow.Rollback()
+}
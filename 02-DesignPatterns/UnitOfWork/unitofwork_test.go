@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestUnitOfWork checks the commit and rollback semantics. The cases share the
+// repositories and run in order: each one starts from what the previous left
+func TestUnitOfWork(t *testing.T) {
+	products := NewRepository[Product]("product")
+	orders := NewRepository[Order]("order")
+
+	cases := []struct {
+		name         string
+		changes      func(uow *UnitOfWork)
+		wantErr      error
+		wantProducts int
+		wantOrders   int
+	}{
+		{
+			name: "commit writes to every repository",
+			changes: func(uow *UnitOfWork) {
+				uow.RegisterNew(Product{SKU: "a", Stock: 1})
+				uow.RegisterNew(Order{Number: "1", SKU: "a"})
+			},
+			wantProducts: 1,
+			wantOrders:   1,
+		},
+		{
+			name: "failed change undoes the previous ones",
+			changes: func(uow *UnitOfWork) {
+				uow.RegisterNew(Product{SKU: "b"})
+				uow.RegisterRemoved(Order{Number: "missing"})
+			},
+			wantErr:      ErrNotFound,
+			wantProducts: 1,
+			wantOrders:   1,
+		},
+		{
+			name: "new then removed never reaches the repository",
+			changes: func(uow *UnitOfWork) {
+				uow.RegisterNew(Product{SKU: "c"})
+				uow.RegisterRemoved(Product{SKU: "c"})
+			},
+			wantProducts: 1,
+			wantOrders:   1,
+		},
+		{
+			name: "unknown kinds are rejected",
+			changes: func(uow *UnitOfWork) {
+				uow.RegisterNew(Cart{Customer: "ana"})
+			},
+			wantErr:      ErrUnknownKind,
+			wantProducts: 1,
+			wantOrders:   1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			uow := NewUnitOfWork(products, orders)
+			c.changes(uow)
+			err := uow.Commit()
+			if !errors.Is(err, c.wantErr) || (c.wantErr == nil && err != nil) {
+				t.Errorf("got error %v, want %v", err, c.wantErr)
+			}
+			if products.Len() != c.wantProducts || orders.Len() != c.wantOrders {
+				t.Errorf("got %d products and %d orders, want %d and %d",
+					products.Len(), orders.Len(), c.wantProducts, c.wantOrders)
+			}
+		})
+	}
+
+	// Rollback forgets the pending changes
+	t.Run("rollback", func(t *testing.T) {
+		uow := NewUnitOfWork(products, orders)
+		uow.RegisterNew(Product{SKU: "d"})
+		uow.Rollback()
+		if err := uow.Commit(); err != nil || products.Len() != 1 {
+			t.Errorf("got error %v and %d products, want no error and 1", err, products.Len())
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Every test connection starts with one byte telling the server what to do
+const (
+	testThroughput byte = 'T' // The client sends data until it closes its side, the server answers the byte count
+	testLatency    byte = 'L' // The server echoes every ping of pingSize bytes
+)
+
+// bufferSize is the size of every write of the throughput streams
+const bufferSize = 128 * 1024
+
+// pingSize is the size of the latency pings: a sequence number and padding
+const pingSize = 64
+
+// Serve accepts test connections until the listener is closed
+func Serve(listener net.Listener, logger *log.Logger) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := handle(conn); err != nil {
+				logger.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// handle runs the test requested by the first byte
+func handle(conn net.Conn) error {
+	var kind [1]byte
+	if _, err := io.ReadFull(conn, kind[:]); err != nil {
+		return err
+	}
+	switch kind[0] {
+	case testThroughput:
+		// Count what arrives; the count measured here is the real throughput,
+		// the client only knows what its kernel accepted in the send buffer
+		n, err := io.Copy(io.Discard, conn)
+		if err != nil {
+			return err
+		}
+		var answer [8]byte
+		binary.BigEndian.PutUint64(answer[:], uint64(n))
+		_, err = conn.Write(answer[:])
+		return err
+	case testLatency:
+		_, err := io.Copy(conn, conn)
+		return err
+	default:
+		return fmt.Errorf("unknown test %q", kind[0])
+	}
+}
+
+// StreamResult is the outcome of one throughput stream
+type StreamResult struct {
+	Stream   int           `json:"stream"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration_ns"`
+	Mbps     float64       `json:"mbps"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// LatencyResult summarizes the round trips of the latency test
+type LatencyResult struct {
+	Count  int           `json:"count"`
+	Min    time.Duration `json:"min_ns"`
+	Avg    time.Duration `json:"avg_ns"`
+	Max    time.Duration `json:"max_ns"`
+	P99    time.Duration `json:"p99_ns"`
+	StdDev time.Duration `json:"stddev_ns"`
+}
+
+// Report is the complete result of a run
+type Report struct {
+	Server    string         `json:"server"`
+	Streams   []StreamResult `json:"streams"`
+	Bytes     int64          `json:"bytes"`
+	Duration  time.Duration  `json:"duration_ns"`
+	Mbps      float64        `json:"mbps"`
+	Latency   *LatencyResult `json:"latency,omitempty"`
+	LatencyOf string         `json:"latency_error,omitempty"`
+}
+
+// mbps converts bytes in a duration to megabits per second
+func mbps(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / d.Seconds() / 1e6
+}
+
+// Run measures the throughput with parallel streams for duration, then the latency with pings
+// round trips (none when pings is 0). The streams run at the same time so their sum is the
+// aggregate bandwidth; the latency is measured afterwards so the load doesn't inflate it.
+func Run(ctx context.Context, addr string, streams int, duration time.Duration, pings int) Report {
+	report := Report{Server: addr, Streams: make([]StreamResult, streams)}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Streams[i] = runStream(ctx, addr, i+1, duration)
+		}()
+	}
+	wg.Wait()
+	report.Duration = time.Since(start)
+	for _, s := range report.Streams {
+		report.Bytes += s.Bytes
+	}
+	report.Mbps = mbps(report.Bytes, report.Duration)
+
+	if pings > 0 {
+		latency, err := measureLatency(ctx, addr, pings)
+		if err != nil {
+			report.LatencyOf = err.Error()
+		} else {
+			report.Latency = &latency
+		}
+	}
+	return report
+}
+
+// runStream sends data for duration and returns what the server received
+func runStream(ctx context.Context, addr string, stream int, duration time.Duration) StreamResult {
+	result := StreamResult{Stream: stream}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	deadline := start.Add(duration)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	// The deadline interrupts a write blocked on a full send buffer at the end of the test
+	conn.SetWriteDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write([]byte{testThroughput}); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	buf := make([]byte, bufferSize)
+	for time.Now().Before(deadline) {
+		if _, err := conn.Write(buf); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	// Closing our side tells the server the stream ended, it answers the byte count
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	conn.(*net.TCPConn).CloseWrite()
+	var answer [8]byte
+	if _, err := io.ReadFull(conn, answer[:]); err != nil {
+		result.Error = fmt.Sprintf("reading the byte count: %v", err)
+		return result
+	}
+	result.Duration = time.Since(start)
+	result.Bytes = int64(binary.BigEndian.Uint64(answer[:]))
+	result.Mbps = mbps(result.Bytes, result.Duration)
+	return result
+}
+
+// measureLatency sends count pings one after the other over one connection and
+// measures the round trip of each
+func measureLatency(ctx context.Context, addr string, count int) (LatencyResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return LatencyResult{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	// Small pings must leave right away instead of waiting to be coalesced (Nagle)
+	conn.(*net.TCPConn).SetNoDelay(true)
+
+	if _, err := conn.Write([]byte{testLatency}); err != nil {
+		return LatencyResult{}, err
+	}
+	ping := make([]byte, pingSize)
+	pong := make([]byte, pingSize)
+	rtts := make([]time.Duration, 0, count)
+	for seq := range count {
+		binary.BigEndian.PutUint64(ping, uint64(seq))
+		start := time.Now()
+		if _, err := conn.Write(ping); err != nil {
+			return LatencyResult{}, err
+		}
+		if _, err := io.ReadFull(conn, pong); err != nil {
+			return LatencyResult{}, err
+		}
+		if got := binary.BigEndian.Uint64(pong); got != uint64(seq) {
+			return LatencyResult{}, fmt.Errorf("got pong %d for ping %d", got, seq)
+		}
+		rtts = append(rtts, time.Since(start))
+	}
+	return summarize(rtts), nil
+}
+
+// summarize computes the statistics of the round trips
+func summarize(rtts []time.Duration) LatencyResult {
+	slices.Sort(rtts)
+	var sum time.Duration
+	for _, rtt := range rtts {
+		sum += rtt
+	}
+	avg := sum / time.Duration(len(rtts))
+	var variance float64
+	for _, rtt := range rtts {
+		diff := float64(rtt - avg)
+		variance += diff * diff
+	}
+	return LatencyResult{
+		Count:  len(rtts),
+		Min:    rtts[0],
+		Avg:    avg,
+		Max:    rtts[len(rtts)-1],
+		P99:    rtts[min(len(rtts)-1, len(rtts)*99/100)],
+		StdDev: time.Duration(math.Sqrt(variance / float64(len(rtts)))),
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestBench runs short tests against a server on the loopback interface
+func TestBench(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go Serve(listener, log.New(io.Discard, "", 0))
+
+	t.Run("report", func(t *testing.T) {
+		report := Run(context.Background(), listener.Addr().String(), 3, 300*time.Millisecond, 50)
+		if len(report.Streams) != 3 {
+			t.Fatalf("got %d streams, want 3", len(report.Streams))
+		}
+		var sum int64
+		for _, s := range report.Streams {
+			if s.Error != "" || s.Bytes == 0 {
+				t.Fatalf("stream %d: %d bytes, error %q", s.Stream, s.Bytes, s.Error)
+			}
+			sum += s.Bytes
+		}
+		if sum != report.Bytes || report.Mbps <= 0 {
+			t.Errorf("aggregate of %d bytes at %.1f Mbit/s, streams add up to %d", report.Bytes, report.Mbps, sum)
+		}
+		if report.Latency == nil || report.Latency.Count != 50 || report.Latency.Min > report.Latency.Avg || report.Latency.Avg > report.Latency.Max {
+			t.Errorf("bad latency %+v (%s)", report.Latency, report.LatencyOf)
+		}
+	})
+
+	// A canceled run stops the streams instead of waiting for the duration
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		Run(ctx, listener.Addr().String(), 1, time.Minute, 0)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("a canceled run took %s", elapsed)
+		}
+	})
+
+	// Nothing listening: the streams report the error
+	t.Run("closed port", func(t *testing.T) {
+		closed, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := closed.Addr().String()
+		closed.Close()
+		if report := Run(context.Background(), addr, 2, time.Second, 1); report.Streams[0].Error == "" || report.LatencyOf == "" {
+			t.Error("got no error against a closed port")
+		}
+	})
+}
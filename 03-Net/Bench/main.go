@@ -0,0 +1,97 @@
+// This program measures the TCP throughput and latency between two hosts, like iperf
+// - Server mode accepts test connections; client mode runs the tests against it
+// - Throughput: --streams parallel connections send data for --duration; the server counts
+//   what it received, so the numbers don't include data still sitting in the send buffers
+// - Latency: --pings ping-pongs of 64 bytes on one connection, after the throughput test
+// - Results per stream and aggregated, as a table or as JSON (see bench.go)
+// RUN PROGRAM WITH FLAGS
+// go run . --mode=server --listen=:5201
+// go run . --mode=client --server=10.0.0.2:5201 --streams=4 --duration=10s
+// go run . --mode=client --server=10.0.0.2:5201 --format=json --pings=1000
+// go test .
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	mode     = flag.String("mode", "client", "server or client")
+	listen   = flag.String("listen", ":5201", "address the server listens on")
+	server   = flag.String("server", "localhost:5201", "address of the server, for the client")
+	streams  = flag.Int("streams", 1, "number of parallel throughput streams")
+	duration = flag.Duration("duration", 10*time.Second, "duration of the throughput test")
+	pings    = flag.Int("pings", 100, "number of latency round trips, 0 skips the latency test")
+	format   = flag.String("format", "text", "output format: text or json")
+)
+
+// printReport writes the report as a table
+func printReport(report Report) {
+	fmt.Printf("Throughput to %s\n", report.Server)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STREAM\tBYTES\tTIME\tMBIT/S\tERROR")
+	for _, s := range report.Streams {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%.1f\t%s\n", s.Stream, s.Bytes, s.Duration.Round(time.Millisecond), s.Mbps, s.Error)
+	}
+	fmt.Fprintf(w, "SUM\t%d\t%s\t%.1f\t\n", report.Bytes, report.Duration.Round(time.Millisecond), report.Mbps)
+	w.Flush()
+
+	switch {
+	case report.Latency != nil:
+		l := report.Latency
+		fmt.Printf("Latency over %d round trips: min %s, avg %s, max %s, p99 %s, stddev %s\n",
+			l.Count, l.Min, l.Avg, l.Max, l.P99, l.StdDev)
+	case report.LatencyOf != "":
+		fmt.Println("Latency test failed:", report.LatencyOf)
+	}
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, use text or json\n", *format)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch *mode {
+	case "server":
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		context.AfterFunc(ctx, func() { listener.Close() })
+		logger.Printf("Listening on %s", listener.Addr())
+		if err := Serve(listener, logger); err != nil {
+			logger.Fatal(err)
+		}
+	case "client":
+		if *streams < 1 {
+			logger.Fatal("--streams must be at least 1")
+		}
+		report := Run(ctx, *server, *streams, *duration, *pings)
+		if *format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(report)
+			return
+		}
+		printReport(report)
+	default:
+		logger.Fatalf("unknown mode %q, use server or client", *mode)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// Client sends commands over one connection. The commands of concurrent callers
+// are serialized, each one waits for its reply before the next is sent; open a
+// client per goroutine for parallel requests.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	mux    sync.Mutex
+}
+
+// NewClient creates a client over an open connection, a TCP one or an end of net.Pipe
+func NewClient(conn net.Conn) *Client {
+	return &Client{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+}
+
+// Dial connects to the server at addr
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// Do sends a command and returns the reply: nil, string, int64, []any, or a RedisError
+// as error. After an I/O or protocol error the connection is out of sync, and closed.
+func (c *Client) Do(args ...string) (any, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	resp.WriteCommand(c.writer, args)
+	if err := c.writer.Flush(); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	reply, err := resp.ReadReply(c.reader)
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if redisErr, isErr := reply.(resp.RedisError); isErr {
+		return nil, redisErr
+	}
+	return reply, nil
+}
+
+// Get returns the value of key and whether it exists
+func (c *Client) Get(key string) (string, bool, error) {
+	reply, err := c.Do("GET", key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value as the value of key
+func (c *Client) Set(key, value string) error {
+	_, err := c.Do("SET", key, value)
+	return err
+}
+
+// Del removes the keys and returns how many existed
+func (c *Client) Del(keys ...string) (int, error) {
+	reply, err := c.Do(append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errors.New("unexpected reply, want an integer")
+	}
+	return int(n), nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
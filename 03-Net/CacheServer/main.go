@@ -0,0 +1,64 @@
+// This program serves the cache of pkg/cache, the one of 01-Concurrency/Cache, over TCP, tying
+// the Concurrency and Net modules together
+// - The Memory cache does the locking, so every connection runs in its own goroutine
+//   and calls it directly (see server.go)
+// - GET, SET, DEL and PING in the RESP framing of 03-Net/MiniRedis, so redis-cli and nc
+//   work too (see pkg/resp)
+// - A Go client over one connection (see client.go)
+// - The tests run over net.Pipe, the server doesn't need a listener (see server_test.go)
+// RUN PROGRAM WITH FLAGS
+// go run . --listen=localhost:6390 --ttl=1m --max-entries=10000
+// redis-cli -p 6390 SET greeting hello
+// printf 'GET greeting\r\n' | nc localhost 6390
+// go test .
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+)
+
+var (
+	listen     = flag.String("listen", "localhost:6390", "address to listen on")
+	ttl        = flag.Duration("ttl", 0, "how long a value stays in the cache, 0 means forever")
+	maxEntries = flag.Int("max-entries", 0, "maximum number of values, the least recently used leave first; 0 means unbounded")
+)
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	var options []cache.Option
+	if *ttl > 0 {
+		options = append(options, cache.WithTTL(*ttl))
+	}
+	if *maxEntries > 0 {
+		options = append(options, cache.WithMaxEntries(*maxEntries))
+	}
+	server := NewServer(logger, options...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	context.AfterFunc(ctx, func() { listener.Close() })
+	logger.Printf("Listening on %s", listener.Addr())
+
+	start := time.Now()
+	if err := server.Serve(listener); err != nil {
+		logger.Println("Serve error:", err)
+	}
+	stats := server.Stats()
+	logger.Printf("Served for %s: %d values, %d hits, %d misses, hit ratio %.2f",
+		time.Since(start).Round(time.Second), stats.Entries, stats.Hits, stats.Misses, stats.HitRatio())
+}
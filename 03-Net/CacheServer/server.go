@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// errMissing is the result of the loader: the server only holds the values
+// set by the clients, there is nothing to calculate on a miss
+var errMissing = errors.New("missing")
+
+// Server exposes a Memory cache over TCP. The cache does all the locking, so the
+// connections, one goroutine each, call it directly.
+type Server struct {
+	cache  *cache.Memory[string, string]
+	logger *log.Logger
+}
+
+// NewServer creates a server over a new cache configured by options, like cache.WithTTL
+// or cache.WithMaxEntries (see pkg/cache)
+func NewServer(logger *log.Logger, options ...cache.Option) *Server {
+	memo := cache.NewCache(func(key string, m *cache.Memory[string, string]) (string, error) {
+		return "", errMissing
+	}, options...)
+	return &Server{cache: memo, logger: logger}
+}
+
+// Serve accepts connections until the listener is closed
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn executes the commands of one connection until it is closed. It takes any
+// net.Conn, so the checks drive it through net.Pipe without a listener. Replies are
+// buffered and flushed when no more commands are waiting, so pipelined commands
+// share one write.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := resp.ReadCommand(reader)
+		if err != nil {
+			if errors.Is(err, resp.ErrProtocol) {
+				resp.WriteReply(writer, fmt.Errorf("ERR %v", err))
+				writer.Flush()
+			} else if err != io.EOF && !errors.Is(err, io.ErrClosedPipe) {
+				s.logger.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.EqualFold(args[0], "QUIT")
+		if quit {
+			resp.WriteReply(writer, resp.Status("OK"))
+		} else {
+			resp.WriteReply(writer, s.Execute(args))
+		}
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// Execute runs a command on the cache and returns its reply:
+//
+//	PING [message]     PONG, or the message
+//	GET key            the value, or null
+//	SET key value      OK
+//	DEL key [key ...]  the number of keys removed
+func (s *Server) Execute(args []string) any {
+	name := strings.ToUpper(args[0])
+	switch {
+	case name == "PING" && len(args) <= 2:
+		if len(args) == 2 {
+			return args[1]
+		}
+		return resp.Status("PONG")
+	case name == "GET" && len(args) == 2:
+		value, err := s.cache.Get(args[1])
+		if errors.Is(err, errMissing) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ERR %v", err)
+		}
+		return value
+	case name == "SET" && len(args) == 3:
+		s.cache.Set(args[1], args[2])
+		return resp.Status("OK")
+	case name == "DEL" && len(args) >= 2:
+		removed := 0
+		for _, key := range args[1:] {
+			if s.cache.Delete(key) {
+				removed++
+			}
+		}
+		return removed
+	case name == "PING" || name == "GET" || name == "SET" || name == "DEL":
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+	return fmt.Errorf("ERR unknown command '%s'", args[0])
+}
+
+// Stats returns the counters of the cache
+func (s *Server) Stats() cache.Stats {
+	return s.cache.Stats()
+}
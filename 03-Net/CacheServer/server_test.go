@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// TestServer checks the commands, their framing and concurrent connections.
+// The connections are net.Pipe pairs, in memory and synchronous, except the last
+// subtest that goes through a real listener.
+func TestServer(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	t.Run("commands", func(t *testing.T) {
+		client := pipe(t, NewServer(logger))
+
+		if reply, err := client.Do("PING"); err != nil || reply != "PONG" {
+			t.Fatalf("PING = %v, %v, want PONG", reply, err)
+		}
+		if _, found, err := client.Get("missing"); err != nil || found {
+			t.Fatalf("GET of a missing key: found %t, %v", found, err)
+		}
+		// Values are bulk strings: the separators of the protocol are safe inside them
+		values := map[string]string{"greeting": "hello", "binary": "a\r\nb\x00c", "empty": ""}
+		for key, value := range values {
+			if err := client.Set(key, value); err != nil {
+				t.Fatalf("SET %s: %v", key, err)
+			}
+		}
+		for key, want := range values {
+			if value, found, err := client.Get(key); err != nil || !found || value != want {
+				t.Fatalf("GET %s = %q, %t, %v, want %q", key, value, found, err, want)
+			}
+		}
+		if removed, err := client.Del("greeting", "binary", "missing"); err != nil || removed != 2 {
+			t.Fatalf("DEL = %d, %v, want 2", removed, err)
+		}
+		if _, found, _ := client.Get("greeting"); found {
+			t.Fatal("GET found a deleted key")
+		}
+
+		// Errors are replies, the connection stays usable after them
+		var redisErr resp.RedisError
+		if _, err := client.Do("FLUSHALL"); !errors.As(err, &redisErr) {
+			t.Fatalf("unknown command: %v, want an error reply", err)
+		}
+		if _, err := client.Do("SET", "key"); !errors.As(err, &redisErr) {
+			t.Fatalf("SET without value: %v, want an error reply", err)
+		}
+		if value, found, err := client.Get("empty"); err != nil || !found || value != "" {
+			t.Fatalf("GET after the errors = %q, %t, %v", value, found, err)
+		}
+	})
+
+	// Several inline commands in one write, as nc would send them, answered in order
+	t.Run("pipelining", func(t *testing.T) {
+		serverEnd, conn := net.Pipe()
+		go NewServer(logger).ServeConn(serverEnd)
+		defer conn.Close()
+
+		// net.Pipe has no buffer: write in the background while the replies are read
+		go io.WriteString(conn, "SET color blue\r\nGET color\r\nDEL color\r\nQUIT\r\n")
+		reader := bufio.NewReader(conn)
+		for i, want := range []any{"OK", "blue", int64(1), "OK"} {
+			if reply, err := resp.ReadReply(reader); err != nil || reply != want {
+				t.Fatalf("pipelined reply %d = %v, %v, want %v", i, reply, err, want)
+			}
+		}
+		// QUIT closes the connection
+		if _, err := resp.ReadReply(reader); err != io.EOF {
+			t.Fatalf("read after QUIT: %v, want EOF", err)
+		}
+	})
+
+	// Many connections at once on the same cache, each one with its own keys and a key
+	// shared by all, under the race detector when run with -race
+	t.Run("concurrent", func(t *testing.T) {
+		const conns, ops = 8, 100
+		server := NewServer(logger)
+		var wg sync.WaitGroup
+		for c := range conns {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client := pipe(t, server)
+				for i := range ops {
+					key, value := fmt.Sprintf("conn%d:%d", c, i), fmt.Sprint(i)
+					if err := client.Set(key, value); err != nil {
+						t.Error(err)
+						return
+					}
+					if err := client.Set("shared", value); err != nil {
+						t.Error(err)
+						return
+					}
+					if got, found, err := client.Get(key); err != nil || !found || got != value {
+						t.Errorf("GET %s = %q, %t, %v, want %q", key, got, found, err, value)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Every key of every connection is there, and one client can be shared too
+		client := pipe(t, server)
+		var shared sync.WaitGroup
+		for c := range conns {
+			shared.Add(1)
+			go func() {
+				defer shared.Done()
+				client.Get(fmt.Sprintf("conn%d:%d", c, ops-1))
+			}()
+		}
+		shared.Wait()
+		if entries := server.Stats().Entries; entries != conns*ops+1 {
+			t.Fatalf("%d values in the cache, want %d", entries, conns*ops+1)
+		}
+	})
+
+	// The options of the cache apply to the server
+	t.Run("eviction", func(t *testing.T) {
+		client := pipe(t, NewServer(logger, cache.WithMaxEntries(2)))
+		client.Set("a", "1")
+		client.Set("b", "2")
+		client.Get("a") // b is now the least recently used
+		client.Set("c", "3")
+		if _, found, _ := client.Get("b"); found {
+			t.Fatal("b was not evicted")
+		}
+		for _, key := range []string{"a", "c"} {
+			if _, found, _ := client.Get(key); !found {
+				t.Fatalf("%s was evicted", key)
+			}
+		}
+	})
+
+	// Real TCP connections from several clients at once, then closing the listener stops Serve
+	t.Run("listener", func(t *testing.T) {
+		server := NewServer(logger)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(listener) }()
+
+		var wg sync.WaitGroup
+		for c := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client, err := Dial(listener.Addr().String())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer client.Close()
+				key := fmt.Sprint("tcp", c)
+				if err := client.Set(key, "value"); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, found, err := client.Get(key); err != nil || !found {
+					t.Errorf("GET %s over TCP: found %t, %v", key, found, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		listener.Close()
+		if err := <-served; err != nil {
+			t.Fatalf("Serve after Close: %v", err)
+		}
+	})
+}
+
+// pipe connects a client to server through net.Pipe and closes it at the end of the test
+func pipe(t *testing.T, server *Server) *Client {
+	t.Helper()
+	serverEnd, clientEnd := net.Pipe()
+	go server.ServeConn(serverEnd)
+	client := NewClient(clientEnd)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -0,0 +1,201 @@
+// This program queries DNS records for one or more names concurrently
+// Supported record types: A, AAAA, MX, TXT, NS and CNAME
+// The queries go to the system resolver, or to the DNS server given with --server
+// RUN PROGRAM WITH FLAGS
+// go run . --types=A,MX,NS golang.org google.com
+// go run . --server=1.1.1.1:53 --timeout=2s --format=json --types=A,AAAA,TXT example.com
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	server  = flag.String("server", "", "DNS server address (host:port), empty uses the system resolver")
+	timeout = flag.Duration("timeout", 3*time.Second, "timeout of every query")
+	types   = flag.String("types", "A,AAAA,MX,TXT,NS,CNAME", "comma separated record types")
+	workers = flag.Int("workers", 8, "number of concurrent queries")
+	format  = flag.String("format", "table", "output format: table or json")
+)
+
+// Query is one record type to look up for one name
+type Query struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Result holds the answers of a query, or the error that prevented them
+type Result struct {
+	Query
+	Values   []string `json:"values"`
+	Error    string   `json:"error,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// lookups maps every supported record type to the resolver call that answers it
+var lookups = map[string]func(ctx context.Context, r *net.Resolver, name string) ([]string, error){
+	"A": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		return lookupIP(ctx, r, "ip4", name)
+	},
+	"AAAA": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		return lookupIP(ctx, r, "ip6", name)
+	},
+	"MX": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		records, err := r.LookupMX(ctx, name)
+		values := make([]string, len(records))
+		for i, mx := range records {
+			values[i] = fmt.Sprintf("%d %s", mx.Pref, mx.Host)
+		}
+		return values, err
+	},
+	"TXT": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		return r.LookupTXT(ctx, name)
+	},
+	"NS": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		records, err := r.LookupNS(ctx, name)
+		values := make([]string, len(records))
+		for i, ns := range records {
+			values[i] = ns.Host
+		}
+		return values, err
+	},
+	"CNAME": func(ctx context.Context, r *net.Resolver, name string) ([]string, error) {
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{cname}, nil
+	},
+}
+
+func lookupIP(ctx context.Context, r *net.Resolver, network, name string) ([]string, error) {
+	ips, err := r.LookupIP(ctx, network, name)
+	values := make([]string, len(ips))
+	for i, ip := range ips {
+		values[i] = ip.String()
+	}
+	return values, err
+}
+
+// NewResolver returns the system resolver, or one that sends every query to address.
+// PreferGo makes the Go resolver do the work, so the Dial function is always used.
+func NewResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// Lookup runs a single query with its own timeout
+func Lookup(resolver *net.Resolver, q Query, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	values, err := lookups[q.Type](ctx, resolver, q.Name)
+	result := Result{Query: q, Values: values, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.Values == nil {
+		result.Values = []string{}
+	}
+	return result
+}
+
+// parseTypes validates the --types flag
+func parseTypes(list string) ([]string, error) {
+	var result []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, supported := lookups[t]; !supported {
+			return nil, fmt.Errorf("unsupported record type %q", t)
+		}
+		result = append(result, t)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no record types given")
+	}
+	return result, nil
+}
+
+func printTable(results []Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tVALUE\tTIME")
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(w, "%s\t%s\terror: %s\t%s\n", r.Name, r.Type, r.Error, r.Duration)
+		case len(r.Values) == 0:
+			fmt.Fprintf(w, "%s\t%s\t-\t%s\n", r.Name, r.Type, r.Duration)
+		default:
+			// One line per value, the name and type are only written on the first one
+			for i, v := range r.Values {
+				if i == 0 {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Type, v, r.Duration)
+				} else {
+					fmt.Fprintf(w, "\t\t%s\t\n", v)
+				}
+			}
+		}
+	}
+	w.Flush()
+}
+
+func main() {
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go run . [flags] name [name...]")
+		os.Exit(2)
+	}
+	recordTypes, err := parseTypes(*types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, use table or json\n", *format)
+		os.Exit(2)
+	}
+
+	// One query per name and record type
+	var queries []Query
+	for _, name := range names {
+		for _, t := range recordTypes {
+			queries = append(queries, Query{Name: name, Type: t})
+		}
+	}
+
+	resolver := NewResolver(*server)
+	results := RunPool(queries, *workers, func(q Query) Result {
+		return Lookup(resolver, q, *timeout)
+	})
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+		return
+	}
+	printTable(results)
+}
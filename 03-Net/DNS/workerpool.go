@@ -0,0 +1,30 @@
+package main
+
+import "sync"
+
+// RunPool processes every job with a fixed number of workers and returns the results
+// in the same order as the jobs. Unlike the port scanner, which starts one goroutine
+// per port, the pool bounds how many queries hit the resolver at the same time.
+func RunPool[J, R any](jobs []J, workers int, process func(J) R) []R {
+	results := make([]R, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(1, workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker writes only the positions it receives, so no lock is needed
+			for i := range indexes {
+				results[i] = process(jobs[i])
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
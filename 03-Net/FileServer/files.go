@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+)
+
+// FileServer serves the files of a directory. Ranges, If-Modified-Since, If-None-Match and
+// If-Range are handled by http.ServeContent; this handler adds the ETag that ServeContent
+// compares against and renders its own directory listings.
+type FileServer struct {
+	root fs.FS
+}
+
+// NewFileServer serves the files of root. An fs.FS from os.DirFS can't escape its
+// directory, so paths like /../../etc/passwd are rejected.
+func NewFileServer(root fs.FS) *FileServer {
+	return &FileServer{root: root}
+}
+
+func (s *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// fs.FS paths have no leading slash, and "." is the root itself
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(s.root, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		// Relative links in the listing need the trailing slash
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		s.serveDir(w, r, name)
+		return
+	}
+	s.serveFile(w, r, name, info)
+}
+
+// serveFile sets the ETag and lets ServeContent deal with the conditional and range headers
+func (s *FileServer) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	file, err := s.root.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	// Files from os.DirFS are *os.File, which can seek
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file is not seekable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(info))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+}
+
+// etag identifies a version of a file by its size and modification time,
+// which is enough to detect changes without reading the whole content
+func etag(info fs.FileInfo) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s-%d-%d", info.Name(), info.Size(), info.ModTime().UnixNano())))
+	return fmt.Sprintf(`"%x"`, sum[:8])
+}
+
+// listingEntry is one row of the directory listing
+type listingEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>{{end}}
+{{range .Entries}}<tr>
+<td><a href="{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td>
+<td>{{if not .IsDir}}{{.Size}}{{end}}</td>
+<td>{{.ModTime.Format "2006-01-02 15:04"}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// serveDir renders the directory listing, directories first and then files, sorted by name
+func (s *FileServer) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(s.root, name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]listingEntry, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		rows = append(rows, listingEntry{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime(), IsDir: e.IsDir()})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].IsDir != rows[j].IsDir {
+			return rows[i].IsDir
+		}
+		return rows[i].Name < rows[j].Name
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	listingTemplate.Execute(w, struct {
+		Path    string
+		Entries []listingEntry
+	}{Path: r.URL.Path, Entries: rows})
+}
+
+// BasicAuth asks for a user and password with HTTP basic authentication.
+// Both values are hashed before the constant time comparison so their lengths don't leak.
+func BasicAuth(user, password string) middleware.Middleware {
+	wantUser, wantPassword := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(password))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+			gotUser, gotPassword := sha256.Sum256([]byte(u)), sha256.Sum256([]byte(p))
+			if ok &&
+				subtle.ConstantTimeCompare(gotUser[:], wantUser[:]) == 1 &&
+				subtle.ConstantTimeCompare(gotPassword[:], wantPassword[:]) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="files", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
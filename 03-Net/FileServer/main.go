@@ -0,0 +1,55 @@
+// This program serves a directory over HTTP
+// - Directory listings with links, directories first
+// - ETag and Last-Modified, so browsers revalidate with If-None-Match / If-Modified-Since
+// - Range requests, used by video players and download managers to resume
+// - Optional basic authentication, and access logging through the middleware chain
+// - Optional bandwidth limit per connection (see throttle.go and pkg/throttle)
+// RUN PROGRAM WITH FLAGS
+// go run . --dir=. --addr=localhost:8080
+// go run . --dir=/tmp --user=admin --password=secret
+// go run . --dir=. --rate=65536
+// curl -r 0-99 -i localhost:8080/main.go
+// go test .
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+)
+
+var (
+	addr     = flag.String("addr", "localhost:8080", "address to listen on")
+	dir      = flag.String("dir", ".", "directory to serve")
+	user     = flag.String("user", "", "basic auth user, empty disables authentication")
+	password = flag.String("password", "", "basic auth password")
+	rate     = flag.Int("rate", 0, "bandwidth limit per connection in bytes/second, 0 is unlimited")
+)
+
+// NewHandler wraps the file server with the logging layers and, when set, basic auth
+// and a bandwidth limit of rate bytes/second
+func NewHandler(root string, user, password string, rate int, logger *log.Logger) http.Handler {
+	chain := middleware.NewChain(middleware.RequestID, middleware.Logging(logger), middleware.Recovery(logger))
+	if user != "" {
+		chain = chain.Append(BasicAuth(user, password))
+	}
+	if rate > 0 {
+		chain = chain.Append(Throttle(rate))
+	}
+	return chain.Then(NewFileServer(os.DirFS(root)))
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	if *user != "" && *password == "" {
+		logger.Fatal("--password is required when --user is set")
+	}
+	logger.Printf("Serving %s on %s", *dir, *addr)
+	log.Fatal(http.ListenAndServe(*addr, NewHandler(*dir, *user, *password, *rate, logger)))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFileServer serves a temporary directory and checks listings, ranges,
+// conditional requests, authentication and path traversal
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0o755)
+	os.WriteFile(filepath.Join(root, "hello.txt"), []byte("0123456789abcdef"), 0o644)
+	os.WriteFile(filepath.Join(root, "docs", "readme.md"), []byte("# Readme"), 0o644)
+
+	server := httptest.NewServer(NewHandler(root, "admin", "secret", 0, log.New(io.Discard, "", 0)))
+	defer server.Close()
+
+	// The ETag of hello.txt, used by the conditional cases
+	resp, err := get(server.URL+"/hello.txt", map[string]string{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if tag == "" || lastModified == "" {
+		t.Fatal("missing ETag or Last-Modified header")
+	}
+
+	cases := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		noAuth     bool
+		wantStatus int
+		wantBody   string // Substring expected in the body
+	}{
+		{"file", "/hello.txt", nil, false, http.StatusOK, "0123456789abcdef"},
+		{"listing", "/", nil, false, http.StatusOK, `<a href="docs/">docs/</a>`},
+		{"nested listing", "/docs/", nil, false, http.StatusOK, "readme.md"},
+		{"directory without slash", "/docs", nil, false, http.StatusOK, "readme.md"},
+		{"missing file", "/nope.txt", nil, false, http.StatusNotFound, ""},
+		{"range", "/hello.txt", map[string]string{"Range": "bytes=2-5"}, false, http.StatusPartialContent, "2345"},
+		{"suffix range", "/hello.txt", map[string]string{"Range": "bytes=-3"}, false, http.StatusPartialContent, "def"},
+		{"range out of bounds", "/hello.txt", map[string]string{"Range": "bytes=100-"}, false, http.StatusRequestedRangeNotSatisfiable, ""},
+		{"if-none-match", "/hello.txt", map[string]string{"If-None-Match": tag}, false, http.StatusNotModified, ""},
+		{"if-none-match changed", "/hello.txt", map[string]string{"If-None-Match": `"old"`}, false, http.StatusOK, "0123"},
+		{"if-modified-since", "/hello.txt", map[string]string{"If-Modified-Since": lastModified}, false, http.StatusNotModified, ""},
+		{"if-range stale", "/hello.txt", map[string]string{"Range": "bytes=0-1", "If-Range": `"old"`}, false, http.StatusOK, "0123456789abcdef"},
+		{"no credentials", "/hello.txt", nil, true, http.StatusUnauthorized, ""},
+		{"wrong password", "/hello.txt", map[string]string{"Authorization": "Basic YWRtaW46bm9wZQ=="}, true, http.StatusUnauthorized, ""},
+		{"path traversal", "/../../etc/passwd", nil, false, http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := get(server.URL+c.path, c.headers, !c.noAuth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != c.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, c.wantStatus)
+			}
+			if !strings.Contains(string(body), c.wantBody) {
+				t.Errorf("body %q does not contain %q", body, c.wantBody)
+			}
+		})
+	}
+}
+
+// TestThrottle downloads 40KB at 100KB/s, which can't take less than 0.3s:
+// the first burst of 10KB is free, the other 30KB wait for the bucket to refill
+func TestThrottle(t *testing.T) {
+	root := t.TempDir()
+	content := strings.Repeat("0123456789", 4096)
+	os.WriteFile(filepath.Join(root, "big.txt"), []byte(content), 0o644)
+	server := httptest.NewServer(NewHandler(root, "", "", 100*1024, log.New(io.Discard, "", 0)))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := get(server.URL+"/big.txt", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+	if err != nil || string(body) != content {
+		t.Fatalf("got %d bytes, %v", len(body), err)
+	}
+	if elapsed < 250*time.Millisecond {
+		t.Errorf("throttled download took %s, want at least 300ms", elapsed)
+	}
+}
+
+// get sends a GET request; auth adds the credentials of the test server
+func get(url string, headers map[string]string, auth bool) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth {
+		req.SetBasicAuth("admin", "secret")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return http.DefaultClient.Do(req)
+}
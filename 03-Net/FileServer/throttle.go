@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+	"github.com/Arcanm/go_advanced_course/pkg/throttle"
+)
+
+// Throttle caps the bandwidth of every response at bytesPerSecond. HTTP/1.1 serves the
+// requests of a connection one after the other, so this is also the limit per connection.
+func Throttle(bytesPerSecond int) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writer := &throttle.Writer{W: w, Limiter: throttle.ForRate(bytesPerSecond), Ctx: r.Context()}
+			next.ServeHTTP(&throttledResponse{ResponseWriter: w, writer: writer}, r)
+		})
+	}
+}
+
+// throttledResponse sends the body through a throttle.Writer. It doesn't expose
+// io.ReaderFrom, so io.Copy can't bypass the limit with sendfile.
+type throttledResponse struct {
+	http.ResponseWriter
+	writer *throttle.Writer
+}
+
+func (t *throttledResponse) Write(p []byte) (int, error) {
+	return t.writer.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (t *throttledResponse) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// RunClient connects to the server and calls Compute, or Sequence when --from is set
+func RunClient(addr string) error {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(DefaultDeadlineClientInterceptor(*timeout)),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := NewFibonacciClient(conn)
+
+	if *from < 0 {
+		resp, err := client.Compute(context.Background(), &ComputeRequest{N: int32(*n)})
+		if err != nil {
+			return err
+		}
+		printResponse(resp)
+		return nil
+	}
+
+	// Streams are not covered by the unary interceptor, so the deadline is set here
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	stream, err := client.Sequence(ctx, &SequenceRequest{From: int32(*from), To: int32(*to)})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		printResponse(resp)
+	}
+}
+
+func printResponse(resp *FibResponse) {
+	fmt.Printf("F(%d) = %d (cached: %t)\n", resp.N, resp.Value, resp.Cached)
+}
@@ -0,0 +1,250 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: fib.proto
+
+package main
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ComputeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	N             int32                  `protobuf:"varint,1,opt,name=n,proto3" json:"n,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ComputeRequest) Reset() {
+	*x = ComputeRequest{}
+	mi := &file_fib_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ComputeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComputeRequest) ProtoMessage() {}
+
+func (x *ComputeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fib_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComputeRequest.ProtoReflect.Descriptor instead.
+func (*ComputeRequest) Descriptor() ([]byte, []int) {
+	return file_fib_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ComputeRequest) GetN() int32 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+type SequenceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int32                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            int32                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SequenceRequest) Reset() {
+	*x = SequenceRequest{}
+	mi := &file_fib_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SequenceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SequenceRequest) ProtoMessage() {}
+
+func (x *SequenceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fib_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SequenceRequest.ProtoReflect.Descriptor instead.
+func (*SequenceRequest) Descriptor() ([]byte, []int) {
+	return file_fib_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SequenceRequest) GetFrom() int32 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *SequenceRequest) GetTo() int32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type FibResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	N             int32                  `protobuf:"varint,1,opt,name=n,proto3" json:"n,omitempty"`
+	Value         uint64                 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	Cached        bool                   `protobuf:"varint,3,opt,name=cached,proto3" json:"cached,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FibResponse) Reset() {
+	*x = FibResponse{}
+	mi := &file_fib_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FibResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FibResponse) ProtoMessage() {}
+
+func (x *FibResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fib_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FibResponse.ProtoReflect.Descriptor instead.
+func (*FibResponse) Descriptor() ([]byte, []int) {
+	return file_fib_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FibResponse) GetN() int32 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+func (x *FibResponse) GetValue() uint64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *FibResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+var File_fib_proto protoreflect.FileDescriptor
+
+const file_fib_proto_rawDesc = "" +
+	"\n" +
+	"\tfib.proto\x12\x03fib\"\x1e\n" +
+	"\x0eComputeRequest\x12\f\n" +
+	"\x01n\x18\x01 \x01(\x05R\x01n\"5\n" +
+	"\x0fSequenceRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x05R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x05R\x02to\"I\n" +
+	"\vFibResponse\x12\f\n" +
+	"\x01n\x18\x01 \x01(\x05R\x01n\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value\x12\x16\n" +
+	"\x06cached\x18\x03 \x01(\bR\x06cached2s\n" +
+	"\tFibonacci\x120\n" +
+	"\aCompute\x12\x13.fib.ComputeRequest\x1a\x10.fib.FibResponse\x124\n" +
+	"\bSequence\x12\x14.fib.SequenceRequest\x1a\x10.fib.FibResponse0\x01B\tZ\a./;mainb\x06proto3"
+
+var (
+	file_fib_proto_rawDescOnce sync.Once
+	file_fib_proto_rawDescData []byte
+)
+
+func file_fib_proto_rawDescGZIP() []byte {
+	file_fib_proto_rawDescOnce.Do(func() {
+		file_fib_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fib_proto_rawDesc), len(file_fib_proto_rawDesc)))
+	})
+	return file_fib_proto_rawDescData
+}
+
+var file_fib_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_fib_proto_goTypes = []any{
+	(*ComputeRequest)(nil),  // 0: fib.ComputeRequest
+	(*SequenceRequest)(nil), // 1: fib.SequenceRequest
+	(*FibResponse)(nil),     // 2: fib.FibResponse
+}
+var file_fib_proto_depIdxs = []int32{
+	0, // 0: fib.Fibonacci.Compute:input_type -> fib.ComputeRequest
+	1, // 1: fib.Fibonacci.Sequence:input_type -> fib.SequenceRequest
+	2, // 2: fib.Fibonacci.Compute:output_type -> fib.FibResponse
+	2, // 3: fib.Fibonacci.Sequence:output_type -> fib.FibResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_fib_proto_init() }
+func file_fib_proto_init() {
+	if File_fib_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fib_proto_rawDesc), len(file_fib_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fib_proto_goTypes,
+		DependencyIndexes: file_fib_proto_depIdxs,
+		MessageInfos:      file_fib_proto_msgTypes,
+	}.Build()
+	File_fib_proto = out.File
+	file_fib_proto_goTypes = nil
+	file_fib_proto_depIdxs = nil
+}
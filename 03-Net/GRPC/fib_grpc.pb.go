@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: fib.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Fibonacci_Compute_FullMethodName  = "/fib.Fibonacci/Compute"
+	Fibonacci_Sequence_FullMethodName = "/fib.Fibonacci/Sequence"
+)
+
+// FibonacciClient is the client API for Fibonacci service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Fibonacci computes Fibonacci numbers backed by a server side cache
+type FibonacciClient interface {
+	// Compute returns a single Fibonacci number
+	Compute(ctx context.Context, in *ComputeRequest, opts ...grpc.CallOption) (*FibResponse, error)
+	// Sequence streams the Fibonacci numbers between from and to (inclusive)
+	Sequence(ctx context.Context, in *SequenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FibResponse], error)
+}
+
+type fibonacciClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFibonacciClient(cc grpc.ClientConnInterface) FibonacciClient {
+	return &fibonacciClient{cc}
+}
+
+func (c *fibonacciClient) Compute(ctx context.Context, in *ComputeRequest, opts ...grpc.CallOption) (*FibResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FibResponse)
+	err := c.cc.Invoke(ctx, Fibonacci_Compute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fibonacciClient) Sequence(ctx context.Context, in *SequenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FibResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Fibonacci_ServiceDesc.Streams[0], Fibonacci_Sequence_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SequenceRequest, FibResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fibonacci_SequenceClient = grpc.ServerStreamingClient[FibResponse]
+
+// FibonacciServer is the server API for Fibonacci service.
+// All implementations must embed UnimplementedFibonacciServer
+// for forward compatibility.
+//
+// Fibonacci computes Fibonacci numbers backed by a server side cache
+type FibonacciServer interface {
+	// Compute returns a single Fibonacci number
+	Compute(context.Context, *ComputeRequest) (*FibResponse, error)
+	// Sequence streams the Fibonacci numbers between from and to (inclusive)
+	Sequence(*SequenceRequest, grpc.ServerStreamingServer[FibResponse]) error
+	mustEmbedUnimplementedFibonacciServer()
+}
+
+// UnimplementedFibonacciServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFibonacciServer struct{}
+
+func (UnimplementedFibonacciServer) Compute(context.Context, *ComputeRequest) (*FibResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compute not implemented")
+}
+func (UnimplementedFibonacciServer) Sequence(*SequenceRequest, grpc.ServerStreamingServer[FibResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Sequence not implemented")
+}
+func (UnimplementedFibonacciServer) mustEmbedUnimplementedFibonacciServer() {}
+func (UnimplementedFibonacciServer) testEmbeddedByValue()                   {}
+
+// UnsafeFibonacciServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FibonacciServer will
+// result in compilation errors.
+type UnsafeFibonacciServer interface {
+	mustEmbedUnimplementedFibonacciServer()
+}
+
+func RegisterFibonacciServer(s grpc.ServiceRegistrar, srv FibonacciServer) {
+	// If the following call pancis, it indicates UnimplementedFibonacciServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Fibonacci_ServiceDesc, srv)
+}
+
+func _Fibonacci_Compute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComputeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FibonacciServer).Compute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Fibonacci_Compute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FibonacciServer).Compute(ctx, req.(*ComputeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fibonacci_Sequence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SequenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FibonacciServer).Sequence(m, &grpc.GenericServerStream[SequenceRequest, FibResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fibonacci_SequenceServer = grpc.ServerStreamingServer[FibResponse]
+
+// Fibonacci_ServiceDesc is the grpc.ServiceDesc for Fibonacci service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Fibonacci_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fib.Fibonacci",
+	HandlerType: (*FibonacciServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Compute",
+			Handler:    _Fibonacci_Compute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sequence",
+			Handler:       _Fibonacci_Sequence_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fib.proto",
+}
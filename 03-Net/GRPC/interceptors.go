@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptors are the gRPC version of HTTP middlewares (see 02-DesignPatterns/Middleware):
+// they wrap every call and are chained with grpc.ChainUnaryInterceptor / ChainStreamInterceptor.
+
+// LoggingUnaryInterceptor logs the method, status code and duration of every unary call
+func LoggingUnaryInterceptor(logger *log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.Printf("%s %s %s", info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor logs the method, status code and duration of every stream
+func LoggingStreamInterceptor(logger *log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logger.Printf("%s %s %s", info.FullMethod, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// DeadlineUnaryInterceptor caps the time a unary call may run. Calls without a deadline,
+// or with a longer one, get max; shorter deadlines set by the client are kept.
+func DeadlineUnaryInterceptor(max time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, max)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// DeadlineStreamInterceptor does the same for streams, replacing the stream context
+func DeadlineStreamInterceptor(max time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := context.WithTimeout(ss.Context(), max)
+		defer cancel()
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextStream overrides the context of a ServerStream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// DefaultDeadlineClientInterceptor gives a deadline to the client calls that don't have one
+func DefaultDeadlineClientInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,149 @@
+// gRPC service exposing the cached Fibonacci of 01-Concurrency/Cache, on pkg/cache.
+// The service is defined in fib.proto and has two RPCs:
+// - Compute is a unary call returning one number
+// - Sequence is a server-streaming call sending one message per number
+// The server chains logging and deadline interceptors; the client CLI adds a default deadline.
+// The messages (fib.pb.go) and the stubs (fib_grpc.pb.go) are generated from fib.proto with
+// protoc-gen-go and protoc-gen-go-grpc, see the go:generate line below.
+// RUN PROGRAM WITH FLAGS
+// go run . --mode=server --addr=localhost:50051
+// go run . --mode=client --addr=localhost:50051 --n=50
+// go run . --mode=client --addr=localhost:50051 --from=1 --to=20
+// go test .
+
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fib.proto
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxN is the largest Fibonacci number that fits in an uint64
+const maxN = 93
+
+// fibonacciServer implements FibonacciServer with a cache shared by every call
+type fibonacciServer struct {
+	UnimplementedFibonacciServer
+	cache *cache.Memory[int32, uint64]
+}
+
+func newFibonacciServer() *fibonacciServer {
+	return &fibonacciServer{cache: cache.NewCache(fibonacci)}
+}
+
+// fibonacci is the FibonacciCached of 01-Concurrency/Cache on uint64: it gets the previous
+// numbers from the cache, so every number up to n ends up cached
+func fibonacci(n int32, m *cache.Memory[int32, uint64]) (uint64, error) {
+	if n <= 1 {
+		return uint64(n), nil
+	}
+	previous, err := m.Get(n - 1)
+	if err != nil {
+		return 0, err
+	}
+	beforePrevious, err := m.Get(n - 2)
+	if err != nil {
+		return 0, err
+	}
+	return previous + beforePrevious, nil
+}
+
+// fibonacci returns F(n), n being checked by the caller, and whether it was already cached.
+// Two first calls for the same n at once may both report it as not cached.
+func (s *fibonacciServer) fibonacci(n int32) (uint64, bool) {
+	_, cached := s.cache.Expiry(n)
+	value, _ := s.cache.Get(n) // fibonacci only fails for the errors of Get, which has none here
+	return value, cached
+}
+
+func (s *fibonacciServer) Compute(ctx context.Context, req *ComputeRequest) (*FibResponse, error) {
+	if req.N < 0 || req.N > maxN {
+		return nil, status.Errorf(codes.InvalidArgument, "n must be between 0 and %d, got %d", maxN, req.N)
+	}
+	value, cached := s.fibonacci(req.N)
+	return &FibResponse{N: req.N, Value: value, Cached: cached}, nil
+}
+
+// Sequence sends one message per number. It waits for the context between messages,
+// so a cancelled client or an expired deadline stops the stream, even during the pause.
+func (s *fibonacciServer) Sequence(req *SequenceRequest, stream Fibonacci_SequenceServer) error {
+	if req.From < 0 || req.To > maxN || req.From > req.To {
+		return status.Errorf(codes.InvalidArgument, "invalid range [%d, %d], the limits are 0 and %d", req.From, req.To, maxN)
+	}
+	ctx := stream.Context()
+	for n := req.From; n <= req.To; n++ {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		value, cached := s.fibonacci(n)
+		if err := stream.Send(&FibResponse{N: n, Value: value, Cached: cached}); err != nil {
+			return err
+		}
+		if n == req.To || *delay <= 0 {
+			continue
+		}
+		// Slow down the stream so the messages can be seen arriving one by one
+		timer := time.NewTimer(*delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
+
+var (
+	mode    = flag.String("mode", "server", "server or client")
+	addr    = flag.String("addr", "localhost:50051", "address of the server")
+	maxTime = flag.Duration("max-time", 5*time.Second, "maximum duration of a call on the server")
+	delay   = flag.Duration("delay", 50*time.Millisecond, "pause between streamed messages")
+	n       = flag.Int("n", 40, "client: number to compute")
+	from    = flag.Int("from", -1, "client: first number of the sequence, -1 computes only --n")
+	to      = flag.Int("to", 10, "client: last number of the sequence")
+	timeout = flag.Duration("timeout", 2*time.Second, "client: default deadline of the calls")
+)
+
+// StartServer listens on addr and serves the Fibonacci service until it fails
+func StartServer(addr string, logger *log.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor(logger), DeadlineUnaryInterceptor(*maxTime)),
+		grpc.ChainStreamInterceptor(LoggingStreamInterceptor(logger), DeadlineStreamInterceptor(*maxTime)),
+	)
+	RegisterFibonacciServer(server, newFibonacciServer())
+	logger.Printf("gRPC server listening on %s", listener.Addr())
+	return server.Serve(listener)
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	var err error
+	switch *mode {
+	case "server":
+		err = StartServer(*addr, logger)
+	case "client":
+		err = RunClient(*addr)
+	default:
+		logger.Fatalf("unknown mode %q, use server or client", *mode)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
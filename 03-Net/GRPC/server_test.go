@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// syncBuffer is a bytes.Buffer the server goroutines can log to
+type syncBuffer struct {
+	buf bytes.Buffer
+	mux sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.String()
+}
+
+// startServer serves the Fibonacci service over an in-memory connection, with the
+// interceptors of StartServer, and returns a client with the interceptor of RunClient
+func startServer(t *testing.T, maxTime time.Duration) (FibonacciClient, *syncBuffer) {
+	t.Helper()
+	logs := &syncBuffer{}
+	logger := log.New(logs, "", 0)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor(logger), DeadlineUnaryInterceptor(maxTime)),
+		grpc.ChainStreamInterceptor(LoggingStreamInterceptor(logger), DeadlineStreamInterceptor(maxTime)),
+	)
+	RegisterFibonacciServer(server, newFibonacciServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(DefaultDeadlineClientInterceptor(time.Second)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewFibonacciClient(conn), logs
+}
+
+func TestCompute(t *testing.T) {
+	client, logs := startServer(t, time.Second)
+	ctx := context.Background()
+
+	cases := []struct {
+		n      int32
+		want   uint64
+		cached bool
+	}{
+		{10, 55, false},
+		{10, 55, true},
+		{5, 5, true}, // Computing F(10) filled every number below it
+		{93, 12200160415121876738, false},
+	}
+	for _, c := range cases {
+		resp, err := client.Compute(ctx, &ComputeRequest{N: c.n})
+		if err != nil {
+			t.Fatalf("Compute(%d): %v", c.n, err)
+		}
+		if resp.GetN() != c.n || resp.GetValue() != c.want || resp.GetCached() != c.cached {
+			t.Errorf("Compute(%d) = %v, want value %d, cached %t", c.n, resp, c.want, c.cached)
+		}
+	}
+
+	for _, n := range []int32{-1, maxN + 1} {
+		if _, err := client.Compute(ctx, &ComputeRequest{N: n}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Compute(%d): got %v, want InvalidArgument", n, err)
+		}
+	}
+	if got := logs.String(); !strings.Contains(got, "/fib.Fibonacci/Compute OK") ||
+		!strings.Contains(got, "/fib.Fibonacci/Compute InvalidArgument") {
+		t.Errorf("the logging interceptor wrote %q", got)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	*delay = 0
+	client, logs := startServer(t, time.Second)
+
+	stream, err := client.Sequence(context.Background(), &SequenceRequest{From: 5, To: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []uint64
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.GetValue())
+	}
+	if want := []uint64{5, 8, 13, 21, 34, 55}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	stream, err = client.Sequence(context.Background(), &SequenceRequest{From: 10, To: 5})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v for a reversed range, want InvalidArgument", err)
+	}
+	if got := logs.String(); !strings.Contains(got, "/fib.Fibonacci/Sequence OK") {
+		t.Errorf("the logging interceptor wrote %q", got)
+	}
+}
+
+// TestDeadlineInterceptor streams slower than the maximum duration of a call on the server:
+// the stream must stop with DeadlineExceeded even though the client set no deadline
+func TestDeadlineInterceptor(t *testing.T) {
+	*delay = 5 * time.Millisecond
+	t.Cleanup(func() { *delay = 0 })
+	client, _ := startServer(t, 20*time.Millisecond)
+
+	stream, err := client.Sequence(context.Background(), &SequenceRequest{From: 0, To: maxN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		received++
+	}
+	if status.Code(err) != codes.DeadlineExceeded || received >= maxN {
+		t.Errorf("got %v after %d messages, want DeadlineExceeded before the end", err, received)
+	}
+}
+
+// TestSequenceStopsDuringThePause sets a pause of a minute between messages: the stream
+// must end at the deadline of the server, not after the pause
+func TestSequenceStopsDuringThePause(t *testing.T) {
+	*delay = time.Minute
+	t.Cleanup(func() { *delay = 0 })
+	client, logs := startServer(t, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	stream, err := client.Sequence(ctx, &SequenceRequest{From: 0, To: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if elapsed := time.Since(start); status.Code(err) != codes.DeadlineExceeded || elapsed > time.Second {
+		t.Fatalf("got %v after %s, want DeadlineExceeded after about 50ms", err, elapsed)
+	}
+	// The handler returned too, it doesn't sleep until the end of the pause
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "/fib.Fibonacci/Sequence DeadlineExceeded") {
+		if time.Now().After(deadline) {
+			t.Fatalf("the handler is still running, the logging interceptor wrote %q", logs.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDefaultDeadlineClientInterceptor(t *testing.T) {
+	interceptor := DefaultDeadlineClientInterceptor(time.Minute)
+	var deadline time.Time
+	var ok bool
+	invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		deadline, ok = ctx.Deadline()
+		return nil
+	}
+
+	interceptor(context.Background(), "/fib.Fibonacci/Compute", nil, nil, nil, invoker)
+	if !ok || time.Until(deadline) < 59*time.Second {
+		t.Errorf("got deadline %v, %t without one, want a minute from now", deadline, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	interceptor(ctx, "/fib.Fibonacci/Compute", nil, nil, nil, invoker)
+	if deadline != want {
+		t.Errorf("got deadline %v, want the one of the caller %v", deadline, want)
+	}
+}
@@ -0,0 +1,180 @@
+// REST API for products built with net/http only.
+// Routes use the method and wildcard patterns of http.ServeMux (Go 1.22+):
+//   GET    /products       list every product
+//   POST   /products       create a product, returns 201 and a Location header
+//   GET    /products/{id}  get one product
+//   PUT    /products/{id}  replace a product
+//   DELETE /products/{id}  delete a product, returns 204
+// Reads are public, writes require "Authorization: Bearer <token>".
+// The products are stored in a repository of pkg/repository, the ones of 02-DesignPatterns/Repository.
+// RUN PROGRAM WITH FLAGS
+// go run . --addr=localhost:8080 --token=secret-token
+// curl -X POST -H "Authorization: Bearer secret-token" -d '{"name":"Laptop","price":1200,"stock":3}' localhost:8080/products
+// go test .
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Arcanm/go_advanced_course/pkg/httpjson"
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// maxBodySize limits the size of the JSON bodies accepted by the API
+const maxBodySize = 1 << 20
+
+// ProductHandler implements the HTTP endpoints on top of a ProductRepository
+type ProductHandler struct {
+	repo repository.ProductRepository
+}
+
+// NewServer returns the API with its routes and middlewares wired
+func NewServer(repo repository.ProductRepository, logger *log.Logger, tokens ...string) http.Handler {
+	h := &ProductHandler{repo: repo}
+
+	// Writes need authentication; the common layers wrap the whole mux so the
+	// 404 and 405 answers generated by the mux are also logged and get a request ID
+	protected := middleware.NewChain(middleware.Auth(tokens...))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /products", h.list)
+	mux.Handle("POST /products", protected.ThenFunc(h.create))
+	mux.HandleFunc("GET /products/{id}", h.get)
+	mux.Handle("PUT /products/{id}", protected.ThenFunc(h.update))
+	mux.Handle("DELETE /products/{id}", protected.ThenFunc(h.delete))
+	return middleware.NewChain(middleware.RequestID, middleware.Logging(logger), middleware.Recovery(logger)).Then(mux)
+}
+
+func (h *ProductHandler) list(w http.ResponseWriter, r *http.Request) {
+	products, err := h.repo.List()
+	if err != nil {
+		httpjson.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, products)
+}
+
+func (h *ProductHandler) create(w http.ResponseWriter, r *http.Request) {
+	var p repository.Product
+	if err := decodeProduct(w, r, &p); err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.repo.Create(&p); err != nil {
+		httpjson.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/products/%d", p.ID))
+	httpjson.Write(w, http.StatusCreated, p)
+}
+
+func (h *ProductHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	p, err := h.repo.GetByID(id)
+	if err != nil {
+		httpjson.Error(w, errorStatuses.Of(err), err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, p)
+}
+
+func (h *ProductHandler) update(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	var p repository.Product
+	if err := decodeProduct(w, r, &p); err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// The ID in the URL wins over the one in the body
+	p.ID = id
+	if err := h.repo.Update(p); err != nil {
+		httpjson.Error(w, errorStatuses.Of(err), err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, p)
+}
+
+func (h *ProductHandler) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.repo.Delete(id); err != nil {
+		httpjson.Error(w, errorStatuses.Of(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathID parses the {id} wildcard of the route
+func pathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid product id %q", r.PathValue("id"))
+	}
+	return id, nil
+}
+
+// decodeProduct reads and validates a product from the request body.
+// Unknown fields are rejected so typos in the client don't go unnoticed.
+func decodeProduct(w http.ResponseWriter, r *http.Request, p *repository.Product) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("request body is empty")
+		}
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	switch {
+	case p.Name == "":
+		return errors.New("name is required")
+	case p.Price < 0:
+		return errors.New("price can't be negative")
+	case p.Stock < 0:
+		return errors.New("stock can't be negative")
+	}
+	return nil
+}
+
+// errorStatuses maps the repository errors to HTTP status codes
+var errorStatuses = httpjson.Statuses{
+	{Err: repository.ErrNotFound, Status: http.StatusNotFound},
+}
+
+var (
+	// Address the API listens on
+	addr = flag.String("addr", "localhost:8080", "address to listen on")
+	// Token accepted for write operations
+	token = flag.String("token", "secret-token", "bearer token required to modify products")
+)
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	repo := repository.NewInMemoryProductRepository()
+	repo.Create(&repository.Product{Name: "Laptop", Price: 1200, Stock: 3})
+	repo.Create(&repository.Product{Name: "Mouse", Price: 25, Stock: 40})
+
+	logger.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, NewServer(repo, logger, *token)))
+}
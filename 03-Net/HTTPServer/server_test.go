@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// TestAPI runs a table of requests against the API served by httptest.
+// The cases share one repository and run in order, so later cases see the earlier writes.
+func TestAPI(t *testing.T) {
+	server := httptest.NewServer(NewServer(repository.NewInMemoryProductRepository(), log.New(io.Discard, "", 0), "test-token"))
+	defer server.Close()
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantBody   string // Substring expected in the response body
+	}{
+		{"empty list", "GET", "/products", "", "", http.StatusOK, "[]"},
+		{"create without token", "POST", "/products", `{"name":"Laptop","price":1200,"stock":3}`, "", http.StatusUnauthorized, ""},
+		{"create with wrong token", "POST", "/products", `{"name":"Laptop","price":1200,"stock":3}`, "nope", http.StatusUnauthorized, ""},
+		{"create", "POST", "/products", `{"name":"Laptop","price":1200,"stock":3}`, "test-token", http.StatusCreated, `"id":1`},
+		{"create second", "POST", "/products", `{"name":"Mouse","price":25,"stock":40}`, "test-token", http.StatusCreated, `"id":2`},
+		{"create invalid JSON", "POST", "/products", `{"name":`, "test-token", http.StatusBadRequest, "invalid JSON"},
+		{"create unknown field", "POST", "/products", `{"name":"X","colour":"red"}`, "test-token", http.StatusBadRequest, "colour"},
+		{"create without name", "POST", "/products", `{"price":10}`, "test-token", http.StatusBadRequest, "name is required"},
+		{"create negative price", "POST", "/products", `{"name":"X","price":-1}`, "test-token", http.StatusBadRequest, "price"},
+		{"get", "GET", "/products/1", "", "", http.StatusOK, `"name":"Laptop"`},
+		{"get missing", "GET", "/products/99", "", "", http.StatusNotFound, "product not found"},
+		{"get invalid id", "GET", "/products/abc", "", "", http.StatusBadRequest, "invalid product id"},
+		{"list", "GET", "/products", "", "", http.StatusOK, `"name":"Mouse"`},
+		{"update", "PUT", "/products/1", `{"name":"Laptop Pro","price":1500,"stock":2}`, "test-token", http.StatusOK, `"price":1500`},
+		{"update is stored", "GET", "/products/1", "", "", http.StatusOK, `"name":"Laptop Pro"`},
+		{"update missing", "PUT", "/products/99", `{"name":"X"}`, "test-token", http.StatusNotFound, ""},
+		{"update without token", "PUT", "/products/1", `{"name":"X"}`, "", http.StatusUnauthorized, ""},
+		{"delete", "DELETE", "/products/2", "", "test-token", http.StatusNoContent, ""},
+		{"delete again", "DELETE", "/products/2", "", "test-token", http.StatusNotFound, ""},
+		{"method not allowed", "PATCH", "/products/1", "", "test-token", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(c.method, server.URL+c.path, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Errorf("got status %d, want %d (body %s)", resp.StatusCode, c.wantStatus, body)
+			}
+			if !strings.Contains(string(body), c.wantBody) {
+				t.Errorf("body %s does not contain %s", body, c.wantBody)
+			}
+			if resp.Header.Get(middleware.RequestIDHeader) == "" {
+				t.Errorf("missing %s header", middleware.RequestIDHeader)
+			}
+		})
+	}
+
+	// The Location header of a new product points to the product
+	t.Run("location", func(t *testing.T) {
+		resp, err := postJSON(server.URL+"/products", `{"name":"Tablet","price":300,"stock":5}`, "test-token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var created repository.Product
+		json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+		if want := fmt.Sprintf("/products/%d", created.ID); resp.Header.Get("Location") != want {
+			t.Errorf("got %q, want %q", resp.Header.Get("Location"), want)
+		}
+	})
+}
+
+func postJSON(url, body, token string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
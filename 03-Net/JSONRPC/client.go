@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClientClosed is returned for calls pending when the connection closes
+var ErrClientClosed = errors.New("jsonrpc: client closed")
+
+// transport sends an encoded request or batch and returns the responses for ids,
+// in the same order. Notifications have no id, so a batch of them waits for nothing.
+type transport interface {
+	roundTrip(ctx context.Context, payload []byte, ids []string) ([]*Response, error)
+	Close() error
+}
+
+// Client calls a JSON-RPC server over TCP (DialTCP) or HTTP (NewHTTPClient)
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+}
+
+// BatchCall is one element of a batch; Result receives the decoded result
+// and Err the error of this call only
+type BatchCall struct {
+	Method string
+	Params any
+	Result any
+	Notify bool
+	Err    error
+}
+
+// Call invokes method and decodes its result into result (which may be nil)
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	calls := []BatchCall{{Method: method, Params: params, Result: result}}
+	if err := c.send(ctx, calls, false); err != nil {
+		return err
+	}
+	return calls[0].Err
+}
+
+// Notify invokes method without waiting for a response
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	return c.send(ctx, []BatchCall{{Method: method, Params: params, Notify: true}}, false)
+}
+
+// Batch sends every call in one message; the error of each call is stored in its Err
+func (c *Client) Batch(ctx context.Context, calls []BatchCall) error {
+	if len(calls) == 0 {
+		return errors.New("jsonrpc: empty batch")
+	}
+	return c.send(ctx, calls, true)
+}
+
+// Close releases the connection
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+func (c *Client) send(ctx context.Context, calls []BatchCall, batch bool) error {
+	requests := make([]Request, len(calls))
+	var ids []string
+	for i, call := range calls {
+		requests[i] = Request{JSONRPC: Version, Method: call.Method}
+		if call.Params != nil {
+			params, err := json.Marshal(call.Params)
+			if err != nil {
+				return fmt.Errorf("jsonrpc: encoding params of %s: %w", call.Method, err)
+			}
+			requests[i].Params = params
+		}
+		if !call.Notify {
+			id := strconv.FormatInt(c.nextID.Add(1), 10)
+			requests[i].ID = json.RawMessage(id)
+			ids = append(ids, id)
+		}
+	}
+
+	var payload []byte
+	if batch {
+		payload = encode(requests)
+	} else {
+		payload = encode(requests[0])
+	}
+	responses, err := c.transport.roundTrip(ctx, payload, ids)
+	if err != nil {
+		return err
+	}
+
+	// Responses come back in the order of ids, which skips the notifications
+	for i, call := range calls {
+		if call.Notify {
+			continue
+		}
+		resp := responses[0]
+		responses = responses[1:]
+		switch {
+		case resp.Error != nil:
+			calls[i].Err = resp.Error
+		case call.Result != nil:
+			calls[i].Err = json.Unmarshal(resp.Result, call.Result)
+		}
+	}
+	return nil
+}
+
+// tcpTransport multiplexes the calls over one connection; a reader goroutine
+// hands every response to the call waiting for its id
+type tcpTransport struct {
+	conn     net.Conn
+	pending  map[string]chan *Response
+	closed   bool
+	writeMux sync.Mutex
+	mux      sync.Mutex
+}
+
+// DialTCP connects to a server speaking newline-delimited JSON-RPC
+func DialTCP(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &tcpTransport{conn: conn, pending: make(map[string]chan *Response)}
+	go t.readLoop()
+	return &Client{transport: t}, nil
+}
+
+func (t *tcpTransport) roundTrip(ctx context.Context, payload []byte, ids []string) ([]*Response, error) {
+	channels := make([]chan *Response, len(ids))
+	t.mux.Lock()
+	if t.closed {
+		t.mux.Unlock()
+		return nil, ErrClientClosed
+	}
+	for i, id := range ids {
+		channels[i] = make(chan *Response, 1)
+		t.pending[id] = channels[i]
+	}
+	t.mux.Unlock()
+	defer t.forget(ids)
+
+	t.writeMux.Lock()
+	_, err := t.conn.Write(append(payload, '\n'))
+	t.writeMux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*Response, len(ids))
+	for i, ch := range channels {
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				return nil, ErrClientClosed
+			}
+			responses[i] = resp
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return responses, nil
+}
+
+// forget removes the ids of a finished or abandoned call
+func (t *tcpTransport) forget(ids []string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, id := range ids {
+		delete(t.pending, id)
+	}
+}
+
+// readLoop dispatches the responses until the connection fails, then fails every pending call
+func (t *tcpTransport) readLoop() {
+	reader := bufio.NewReader(t.conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			for _, resp := range decodeResponses(line) {
+				t.mux.Lock()
+				if ch, waiting := t.pending[string(resp.ID)]; waiting {
+					ch <- resp
+					delete(t.pending, string(resp.ID))
+				}
+				t.mux.Unlock()
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	t.mux.Lock()
+	t.closed = true
+	for id, ch := range t.pending {
+		close(ch)
+		delete(t.pending, id)
+	}
+	t.mux.Unlock()
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// decodeResponses reads a single response or a batch; invalid messages are ignored
+func decodeResponses(data []byte) []*Response {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []*Response
+		json.Unmarshal(data, &batch)
+		return batch
+	}
+	var resp Response
+	if json.Unmarshal(data, &resp) != nil {
+		return nil
+	}
+	return []*Response{&resp}
+}
+
+// httpTransport posts every message to the endpoint
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPClient creates a client posting to url
+func NewHTTPClient(url string) *Client {
+	return &Client{transport: &httpTransport{url: url, client: http.DefaultClient}}
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, payload []byte, ids []string) ([]*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return nil, fmt.Errorf("jsonrpc: unexpected HTTP status %s", resp.Status)
+	}
+
+	byID := make(map[string]*Response)
+	for _, r := range decodeResponses(body) {
+		byID[string(r.ID)] = r
+	}
+	responses := make([]*Response, len(ids))
+	for i, id := range ids {
+		if responses[i] = byID[id]; responses[i] == nil {
+			// Errors about the whole message (parse errors, invalid batch) come with a null id
+			if whole := byID["null"]; whole != nil && whole.Error != nil {
+				return nil, whole.Error
+			}
+			return nil, fmt.Errorf("jsonrpc: no response for id %s", id)
+		}
+	}
+	return responses, nil
+}
+
+func (t *httpTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,99 @@
+// JSON-RPC 2.0 server and client, over TCP (one JSON message per line) and HTTP (one per POST)
+// - Methods are registered explicitly (Register) or by reflection (RegisterService)
+// - Batches are answered with an array, notifications get no response
+// - Errors use the codes of the specification (-32700, -32600, -32601, -32602, -32603)
+// RUN PROGRAM WITH FLAGS
+// go run . --tcp=localhost:4000 --http=localhost:4001
+// echo '{"jsonrpc":"2.0","method":"Arith.Add","params":{"a":2,"b":3},"id":1}' | nc localhost 4000
+// curl -d '[{"jsonrpc":"2.0","method":"Arith.Divide","params":{"a":1,"b":0},"id":1}]' localhost:4001
+// go test .
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ArithArgs are the named params of the Arith methods
+type ArithArgs struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+// Arith is registered by reflection: each method becomes "Arith.<Method>"
+type Arith struct{}
+
+// CodeDivisionByZero is an application error code, outside the reserved range
+const CodeDivisionByZero = -32000
+
+func (Arith) Add(ctx context.Context, args ArithArgs) (float64, error) {
+	return args.A + args.B, nil
+}
+
+func (Arith) Divide(ctx context.Context, args ArithArgs) (float64, error) {
+	if args.B == 0 {
+		return 0, NewError(CodeDivisionByZero, "division by zero")
+	}
+	return args.A / args.B, nil
+}
+
+// Sum takes positional params: [1, 2, 3]
+func (Arith) Sum(ctx context.Context, numbers []float64) (float64, error) {
+	var total float64
+	for _, n := range numbers {
+		total += n
+	}
+	return total, nil
+}
+
+// NewDemoServer registers the Arith service and a few explicit handlers
+func NewDemoServer(logger *log.Logger) *Server {
+	server := NewServer()
+	server.RegisterService("Arith", Arith{})
+	server.Register("upper", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var words []string
+		if err := json.Unmarshal(params, &words); err != nil || len(words) != 1 {
+			return nil, &Error{Code: CodeInvalidParams, Message: "expected one string"}
+		}
+		return strings.ToUpper(words[0]), nil
+	})
+	server.Register("log", func(ctx context.Context, params json.RawMessage) (any, error) {
+		logger.Printf("log notification: %s", params)
+		return nil, nil
+	})
+	server.Register("fail", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("something went wrong")
+	})
+	return server
+}
+
+var (
+	tcpAddr  = flag.String("tcp", "localhost:4000", "TCP address")
+	httpAddr = flag.String("http", "localhost:4001", "HTTP address")
+)
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	server := NewDemoServer(logger)
+	listener, err := net.Listen("tcp", *tcpAddr)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go func() {
+		logger.Printf("JSON-RPC over TCP on %s", *tcpAddr)
+		if err := server.ServeTCP(context.Background(), listener); err != nil {
+			logger.Fatal(err)
+		}
+	}()
+	logger.Printf("JSON-RPC over HTTP on %s", *httpAddr)
+	logger.Fatal(http.ListenAndServe(*httpAddr, server))
+}
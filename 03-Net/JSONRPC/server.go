@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Handler processes the raw params of a request and returns the result
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Server dispatches requests to the registered handlers
+type Server struct {
+	handlers map[string]Handler
+	mux      sync.RWMutex
+}
+
+// NewServer creates a server without methods
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]Handler)}
+}
+
+// Register adds an explicit handler for a method name
+func (s *Server) Register(method string, handler Handler) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, exists := s.handlers[method]; exists {
+		return fmt.Errorf("method %q already registered", method)
+	}
+	s.handlers[method] = handler
+	return nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterService registers every exported method of receiver with the shape
+//
+//	func (ctx context.Context, params P) (R, error)
+//
+// as "name.Method". P is decoded from the request params (a struct or pointer for
+// named params, a slice for positional ones). Methods with other shapes are skipped.
+func (s *Server) RegisterService(name string, receiver any) error {
+	value := reflect.ValueOf(receiver)
+	registered := 0
+	for i := range value.NumMethod() {
+		method := value.Type().Method(i)
+		fn := value.Method(i)
+		t := fn.Type()
+		if t.NumIn() != 2 || t.In(0) != contextType || t.NumOut() != 2 || t.Out(1) != errorType {
+			continue
+		}
+		paramsType := t.In(1)
+		handler := func(ctx context.Context, raw json.RawMessage) (any, error) {
+			params := reflect.New(paramsType)
+			if len(raw) > 0 {
+				if err := json.Unmarshal(raw, params.Interface()); err != nil {
+					return nil, &Error{Code: CodeInvalidParams, Message: "invalid params", Data: err.Error()}
+				}
+			}
+			out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), params.Elem()})
+			if err, _ := out[1].Interface().(error); err != nil {
+				return nil, err
+			}
+			return out[0].Interface(), nil
+		}
+		if err := s.Register(name+"."+method.Name, handler); err != nil {
+			return err
+		}
+		registered++
+	}
+	if registered == 0 {
+		return fmt.Errorf("type %T has no methods with the shape func(context.Context, P) (R, error)", receiver)
+	}
+	return nil
+}
+
+// Handle processes a single request or a batch and returns the encoded response.
+// It returns nil when nothing must be answered (notifications only).
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return s.handleBatch(ctx, data)
+	}
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return encode(errorResponse(nullID, CodeParseError, "parse error"))
+	}
+	resp := s.call(ctx, &req)
+	if resp == nil {
+		return nil
+	}
+	return encode(resp)
+}
+
+// handleBatch runs the requests of a batch concurrently, as the specification allows,
+// and answers with an array without the notifications
+func (s *Server) handleBatch(ctx context.Context, data []byte) []byte {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return encode(errorResponse(nullID, CodeParseError, "parse error"))
+	}
+	if len(batch) == 0 {
+		return encode(errorResponse(nullID, CodeInvalidRequest, "empty batch"))
+	}
+
+	responses := make([]*Response, len(batch))
+	var wg sync.WaitGroup
+	for i, raw := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var req Request
+			if err := json.Unmarshal(raw, &req); err != nil {
+				// A valid array with an invalid element is an invalid request, not a parse error
+				responses[i] = errorResponse(nullID, CodeInvalidRequest, "invalid request")
+				return
+			}
+			responses[i] = s.call(ctx, &req)
+		}()
+	}
+	wg.Wait()
+
+	answered := make([]*Response, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			answered = append(answered, resp)
+		}
+	}
+	if len(answered) == 0 {
+		return nil
+	}
+	return encode(answered)
+}
+
+// call validates and runs one request; it returns nil for notifications
+func (s *Server) call(ctx context.Context, req *Request) *Response {
+	id := nullID
+	if !req.IsNotification() {
+		id = req.ID
+	}
+	if req.JSONRPC != Version || req.Method == "" || !validID(id) {
+		return errorResponse(nullIDIfInvalid(id), CodeInvalidRequest, "invalid request")
+	}
+
+	s.mux.RLock()
+	handler, exists := s.handlers[req.Method]
+	s.mux.RUnlock()
+
+	var result any
+	var err error
+	if !exists {
+		err = &Error{Code: CodeMethodNotFound, Message: "method not found", Data: req.Method}
+	} else {
+		result, err = safeCall(ctx, handler, req.Params)
+	}
+
+	if req.IsNotification() {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return &Response{JSONRPC: Version, Error: rpcErr, ID: id}
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(id, CodeInternalError, "result can't be encoded")
+	}
+	return &Response{JSONRPC: Version, Result: encoded, ID: id}
+}
+
+// safeCall turns a panic in a handler into an internal error
+func safeCall(ctx context.Context, handler Handler, params json.RawMessage) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{Code: CodeInternalError, Message: "internal error", Data: fmt.Sprint(r)}
+		}
+	}()
+	return handler(ctx, params)
+}
+
+// validID accepts the id types of the specification: string, number or null
+func validID(id json.RawMessage) bool {
+	var v any
+	if json.Unmarshal(id, &v) != nil {
+		return false
+	}
+	switch v.(type) {
+	case string, float64, nil:
+		return true
+	}
+	return false
+}
+
+func nullIDIfInvalid(id json.RawMessage) json.RawMessage {
+	if validID(id) {
+		return id
+	}
+	return nullID
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	return &Response{JSONRPC: Version, Error: &Error{Code: code, Message: message}, ID: id}
+}
+
+func encode(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// ServeConn reads newline-delimited messages from a TCP connection and writes the
+// responses in the same format. Each message is handled in its own goroutine, so
+// responses may arrive out of order and the client matches them by id.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var writeMux sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.Handle(ctx, line); resp != nil {
+					writeMux.Lock()
+					conn.Write(append(resp, '\n'))
+					writeMux.Unlock()
+				}
+			}()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ServeTCP accepts connections until the listener is closed
+func (s *Server) ServeTCP(ctx context.Context, listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(ctx, conn)
+	}
+}
+
+// ServeHTTP implements the HTTP transport: one request or batch per POST body.
+// Notifications are answered with 204 No Content.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	resp := s.Handle(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
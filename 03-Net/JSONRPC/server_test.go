@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHandle checks the error codes with raw messages
+func TestHandle(t *testing.T) {
+	server := NewDemoServer(log.New(io.Discard, "", 0))
+	ctx := context.Background()
+
+	// Raw messages and the exact response expected, "" means no response at all
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"call", `{"jsonrpc":"2.0","method":"Arith.Add","params":{"a":2,"b":3},"id":1}`,
+			`{"jsonrpc":"2.0","result":5,"id":1}`},
+		{"string id", `{"jsonrpc":"2.0","method":"Arith.Sum","params":[1,2,3],"id":"abc"}`,
+			`{"jsonrpc":"2.0","result":6,"id":"abc"}`},
+		{"null id is a call", `{"jsonrpc":"2.0","method":"upper","params":["go"],"id":null}`,
+			`{"jsonrpc":"2.0","result":"GO","id":null}`},
+		{"notification", `{"jsonrpc":"2.0","method":"log","params":["hi"]}`, ``},
+		{"parse error", `{"jsonrpc":"2.0","method"`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`},
+		{"wrong version", `{"jsonrpc":"1.0","method":"Arith.Add","id":1}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":1}`},
+		{"invalid id", `{"jsonrpc":"2.0","method":"Arith.Add","id":{}}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"nope","id":2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found","data":"nope"},"id":2}`},
+		{"invalid params", `{"jsonrpc":"2.0","method":"Arith.Add","params":[1,2],"id":3}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid params","data":"json: cannot unmarshal array into Go value of type main.ArithArgs"},"id":3}`},
+		{"internal error", `{"jsonrpc":"2.0","method":"fail","id":4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"something went wrong"},"id":4}`},
+		{"application error", `{"jsonrpc":"2.0","method":"Arith.Divide","params":{"a":1,"b":0},"id":5}`,
+			`{"jsonrpc":"2.0","error":{"code":-32000,"message":"division by zero"},"id":5}`},
+		{"empty batch", `[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+		{"invalid batch element", `[1]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}]`},
+		{"batch", `[{"jsonrpc":"2.0","method":"Arith.Add","params":{"a":1,"b":1},"id":1},{"jsonrpc":"2.0","method":"log"},{"jsonrpc":"2.0","method":"nope","id":2}]`,
+			`[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found","data":"nope"},"id":2}]`},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"log"},{"jsonrpc":"2.0","method":"log"}]`, ``},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := string(server.Handle(ctx, []byte(c.in)))
+			if got != c.want {
+				t.Errorf("\n  got  %s\n  want %s", got, c.want)
+			}
+		})
+	}
+}
+
+// TestClient checks the same client API over both transports
+func TestClient(t *testing.T) {
+	server := NewDemoServer(log.New(io.Discard, "", 0))
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go server.ServeTCP(ctx, listener)
+	tcpClient, err := DialTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpClient.Close()
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	clients := map[string]*Client{"tcp": tcpClient, "http": NewHTTPClient(httpServer.URL)}
+	for _, name := range []string{"tcp", "http"} {
+		client := clients[name]
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			var sum float64
+			if err := client.Call(ctx, "Arith.Add", ArithArgs{A: 20, B: 22}, &sum); err != nil || sum != 42 {
+				t.Fatalf("Add: got %v (err: %v)", sum, err)
+			}
+
+			var rpcErr *Error
+			err := client.Call(ctx, "Arith.Divide", ArithArgs{A: 1}, nil)
+			if !errors.As(err, &rpcErr) || rpcErr.Code != CodeDivisionByZero {
+				t.Fatalf("Divide: got %v, want code %d", err, CodeDivisionByZero)
+			}
+
+			if err := client.Notify(ctx, "log", []string{"from client"}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+
+			var upper string
+			var total float64
+			calls := []BatchCall{
+				{Method: "upper", Params: []string{"batch"}, Result: &upper},
+				{Method: "log", Params: []string{"in batch"}, Notify: true},
+				{Method: "nope"},
+				{Method: "Arith.Sum", Params: []float64{1, 2, 3, 4}, Result: &total},
+			}
+			if err := client.Batch(ctx, calls); err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+			if upper != "BATCH" || total != 10 || calls[0].Err != nil || calls[3].Err != nil {
+				t.Fatalf("Batch: got %q and %v (errors %v, %v)", upper, total, calls[0].Err, calls[3].Err)
+			}
+			if !errors.As(calls[2].Err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+				t.Fatalf("Batch: got %v for an unknown method", calls[2].Err)
+			}
+
+			// Concurrent calls on the same client are matched by id
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var words string
+					if err := client.Call(ctx, "upper", []string{strings.Repeat("a", i)}, &words); err != nil || words != strings.Repeat("A", i) {
+						t.Errorf("call %d got %q (err: %v)", i, words, err)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// Fsync policies of the append-only file, like appendfsync in Redis
+const (
+	FsyncAlways   = "always"   // Every write is on disk before the reply: safest, slowest
+	FsyncEverySec = "everysec" // At most one second of writes is lost on a crash
+	FsyncNo       = "no"       // The OS decides when to flush
+)
+
+// AOF is the append-only file: every command that changes the data is appended in the
+// protocol format, and replaying the file on startup rebuilds the keyspace.
+// Relative expirations are logged as absolute deadlines (PEXPIREAT), so a replay
+// doesn't extend the life of the keys.
+type AOF struct {
+	file   *os.File
+	writer *bufio.Writer
+	policy string
+	done   chan struct{}
+	mux    sync.Mutex
+}
+
+// OpenAOF opens or creates the file at path and replays it through apply before
+// returning. A command cut in half by a crash is dropped and the file truncated
+// after the last complete one, so new commands are not appended to garbage.
+func OpenAOF(path, policy string, apply func(args []string) error) (*AOF, int, error) {
+	switch policy {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+	default:
+		return nil, 0, fmt.Errorf("unknown fsync policy %q", policy)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	replayed, valid, err := replay(file, apply)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	aof := &AOF{file: file, writer: bufio.NewWriter(file), policy: policy, done: make(chan struct{})}
+	if policy == FsyncEverySec {
+		go aof.syncEverySecond()
+	}
+	return aof, replayed, nil
+}
+
+// replay applies every complete command and returns how many, and the offset after the last one
+func replay(file *os.File, apply func(args []string) error) (int, int64, error) {
+	counter := &countingReader{r: file}
+	reader := bufio.NewReader(counter)
+	var valid int64
+	replayed := 0
+	for {
+		args, err := resp.ReadCommand(reader)
+		if err == io.EOF {
+			return replayed, valid, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// The tail was being written when the process died
+			return replayed, valid, nil
+		}
+		if err != nil {
+			return replayed, valid, fmt.Errorf("aof corrupted after %d bytes: %w", valid, err)
+		}
+		if err := apply(args); err != nil {
+			return replayed, valid, fmt.Errorf("aof replay of %q: %w", args, err)
+		}
+		replayed++
+		// What was read from the file minus what is still buffered
+		valid = counter.n - int64(reader.Buffered())
+	}
+}
+
+// countingReader counts the bytes read from the file
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Append writes a command. It always reaches the OS, so it survives a crash of the
+// process; with FsyncAlways it also is on disk, surviving a crash of the machine.
+func (a *AOF) Append(args []string) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	resp.WriteCommand(a.writer, args)
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+	if a.policy == FsyncAlways {
+		return a.file.Sync()
+	}
+	return nil
+}
+
+// syncEverySecond flushes the file to disk every second until Close
+func (a *AOF) syncEverySecond() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.mux.Lock()
+			a.file.Sync()
+			a.mux.Unlock()
+		}
+	}
+}
+
+// Close flushes and closes the file
+func (a *AOF) Close() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	close(a.done)
+	if err := a.writer.Flush(); err != nil {
+		a.file.Close()
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,134 @@
+// Package client is the Go client of MiniRedis. It speaks RESP (see pkg/resp), so it
+// also works against a real Redis for the commands MiniRedis has.
+//
+//	c := client.New("localhost:6380", 8)
+//	defer c.Close()
+//	c.Set(ctx, "greeting", "hello", time.Minute)
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/connpool"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// Client talks to the server over pooled connections (see pkg/connpool), so concurrent
+// callers don't open a connection per command nor share one and wait for each other
+type Client struct {
+	addr string
+	pool *connpool.Pool
+}
+
+// New creates a client for the server at addr with up to maxConns connections
+func New(addr string, maxConns int) *Client {
+	return &Client{addr: addr, pool: connpool.New(connpool.Options{MaxActive: maxConns, MaxIdle: maxConns})}
+}
+
+// Do sends a command and returns the reply: nil, string, int64, []any, or a RedisError
+// as error. A connection that failed in the middle of a command is discarded, since
+// the next reply read from it would belong to this command.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := c.pool.Get(ctx, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	writer := bufio.NewWriter(conn)
+	resp.WriteCommand(writer, args)
+	if err := writer.Flush(); err != nil {
+		conn.Discard()
+		return nil, err
+	}
+	reply, err := resp.ReadReply(bufio.NewReader(conn))
+	if err != nil {
+		conn.Discard()
+		return nil, err
+	}
+	conn.Release()
+	if redisErr, isErr := reply.(resp.RedisError); isErr {
+		return nil, redisErr
+	}
+	return reply, nil
+}
+
+// Get returns the value of key and whether it exists
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value; a ttl of 0 means no expiration
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Del removes the keys and returns how many existed
+func (c *Client) Del(ctx context.Context, keys ...string) (int, error) {
+	reply, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return toInt(reply, err)
+}
+
+// Expire sets the time to live of key, it reports whether the key exists
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	n, err := toInt(c.Do(ctx, "EXPIRE", key, strconv.Itoa(int(ttl.Seconds()))))
+	return n == 1, err
+}
+
+// TTL returns the seconds left of key, -1 without expiration and -2 when missing
+func (c *Client) TTL(ctx context.Context, key string) (int, error) {
+	return toInt(c.Do(ctx, "TTL", key))
+}
+
+// Keys returns the keys matching a glob pattern
+func (c *Client) Keys(ctx context.Context, pattern string) ([]string, error) {
+	reply, err := c.Do(ctx, "KEYS", pattern)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply %T", reply)
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i], _ = item.(string)
+	}
+	return keys, nil
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	return c.pool.Close()
+}
+
+func toInt(reply any, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errors.New("unexpected reply, want an integer")
+	}
+	return int(n), nil
+}
@@ -0,0 +1,114 @@
+// This program is a small Redis-like key/value server, the capstone of the course: it combines
+// the cache of 01-Concurrency/Cache, persistence to a file, and the TCP servers of 03-Net
+// - A subset of RESP, the protocol of Redis: PING, GET, SET (EX/PX/PXAT), DEL, EXPIRE, TTL, KEYS
+//   and QUIT, in array form or inline, so redis-cli, nc and telnet all work (see pkg/resp)
+// - Keys expire lazily on access and are swept in the background (see store.go, built on pkg/cache)
+// - Every write is appended to an append-only file, replayed on startup (see aof.go)
+// - A Go client package with a connection pool (see client/ and pkg/connpool), which
+//   --connect uses to send one command
+// RUN PROGRAM WITH FLAGS
+// go run . --listen=localhost:6380 --aof=data.aof --fsync=everysec
+// go run . --connect=localhost:6380 SET greeting hello EX 60
+// redis-cli -p 6380 GET greeting
+// printf 'GET greeting\r\n' | nc localhost 6380
+// go test .
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/03-Net/MiniRedis/client"
+)
+
+var (
+	listen  = flag.String("listen", "localhost:6380", "address to listen on")
+	aofPath = flag.String("aof", "miniredis.aof", "append-only file, empty disables persistence")
+	fsync   = flag.String("fsync", FsyncEverySec, "fsync policy of the AOF: always, everysec or no")
+	sweep   = flag.Duration("sweep", time.Second, "interval between removals of the expired keys")
+	connect = flag.String("connect", "", "address of a server: send the arguments as one command and print the reply")
+)
+
+// Open creates the store and the server, replaying the AOF at path if it is not empty
+func Open(path, policy string, logger *log.Logger) (*Server, error) {
+	server := NewServer(NewStore(), nil, logger)
+	if path == "" {
+		return server, nil
+	}
+	aof, replayed, err := OpenAOF(path, policy, server.Replay)
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("Replayed %d commands from %s", replayed, path)
+	server.aof = aof
+	return server, nil
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+	if *connect != "" {
+		if err := send(*connect, flag.Args()); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	server, err := Open(*aofPath, *fsync, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go server.SweepEvery(ctx, *sweep)
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	context.AfterFunc(ctx, func() { listener.Close() })
+	logger.Printf("Listening on %s", listener.Addr())
+	if err := server.Serve(listener); err != nil {
+		logger.Println("Serve error:", err)
+	}
+
+	// Flush the AOF before exiting, so the last writes are on disk
+	if server.aof != nil {
+		if err := server.aof.Close(); err != nil {
+			logger.Println("Closing the AOF:", err)
+		}
+	}
+}
+
+// send runs one command against the server at addr with the client package
+func send(addr string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("--connect needs a command, like: GET greeting")
+	}
+	c := client.New(addr, 1)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return err
+	}
+	switch reply := reply.(type) {
+	case nil:
+		fmt.Println("(nil)")
+	case []any:
+		for i, item := range reply {
+			fmt.Printf("%d) %v\n", i+1, item)
+		}
+	default:
+		fmt.Println(reply)
+	}
+	return nil
+}
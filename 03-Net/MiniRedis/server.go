@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// Error replies, with the same texts as Redis so existing clients understand them
+var (
+	errSyntax     = errors.New("ERR syntax error")
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+)
+
+// Server executes the commands on a Store and logs the writes to an AOF
+type Server struct {
+	store  *Store
+	aof    *AOF // Nil disables persistence
+	logger *log.Logger
+	// writeMux orders the writes: the AOF must hold them in the order they were applied
+	writeMux sync.Mutex
+}
+
+// NewServer creates a server; aof may be nil
+func NewServer(store *Store, aof *AOF, logger *log.Logger) *Server {
+	return &Server{store: store, aof: aof, logger: logger}
+}
+
+// Serve accepts connections until the listener is closed
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle executes the commands of one connection. Replies are buffered and flushed
+// when no more commands are waiting, so pipelined commands share one write.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := resp.ReadCommand(reader)
+		if err != nil {
+			if errors.Is(err, resp.ErrProtocol) {
+				resp.WriteReply(writer, fmt.Errorf("ERR %v", err))
+				writer.Flush()
+			} else if err != io.EOF {
+				s.logger.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.EqualFold(args[0], "QUIT")
+		if quit {
+			resp.WriteReply(writer, resp.Status("OK"))
+		} else {
+			resp.WriteReply(writer, s.Execute(args))
+		}
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// Execute runs a command and returns its reply, logging it to the AOF if it changed the data
+func (s *Server) Execute(args []string) any {
+	name := strings.ToUpper(args[0])
+	command, exists := commands[name]
+	if !exists {
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+	if len(args) < command.minArgs || (command.maxArgs > 0 && len(args) > command.maxArgs) {
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+	if command.write == nil {
+		return command.read(s.store, args)
+	}
+
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	reply, logged := command.write(s.store, args)
+	if logged != nil && s.aof != nil {
+		if err := s.aof.Append(logged); err != nil {
+			s.logger.Println("AOF write failed:", err)
+			return fmt.Errorf("ERR persistence failed: %v", err)
+		}
+	}
+	return reply
+}
+
+// Replay applies a command read from the AOF, without logging it again
+func (s *Server) Replay(args []string) error {
+	command, exists := commands[strings.ToUpper(args[0])]
+	if !exists || command.write == nil {
+		return fmt.Errorf("unexpected command %q", args[0])
+	}
+	reply, _ := command.write(s.store, args)
+	if err, failed := reply.(error); failed {
+		return err
+	}
+	return nil
+}
+
+// SweepEvery removes the expired keys every interval until ctx is done
+func (s *Server) SweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.store.Sweep()
+		}
+	}
+}
+
+// command describes one command: read commands only return a reply, write commands
+// also return what to append to the AOF (nil when nothing changed)
+type command struct {
+	minArgs, maxArgs int // Including the name, maxArgs 0 means no limit
+	read             func(store *Store, args []string) any
+	write            func(store *Store, args []string) (reply any, logged []string)
+}
+
+var commands = map[string]command{
+	"PING": {minArgs: 1, maxArgs: 2, read: func(store *Store, args []string) any {
+		if len(args) == 2 {
+			return args[1]
+		}
+		return resp.Status("PONG")
+	}},
+	"GET": {minArgs: 2, maxArgs: 2, read: func(store *Store, args []string) any {
+		if value, found := store.Get(args[1]); found {
+			return value
+		}
+		return nil
+	}},
+	"TTL": {minArgs: 2, maxArgs: 2, read: func(store *Store, args []string) any {
+		ttl := store.TTL(args[1])
+		if ttl < 0 {
+			return int(ttl)
+		}
+		// Round up, so a key with 300ms left doesn't report 0 seconds
+		return int((ttl + time.Second - 1) / time.Second)
+	}},
+	"KEYS": {minArgs: 2, maxArgs: 2, read: func(store *Store, args []string) any {
+		return store.Keys(args[1])
+	}},
+	// SET key value [EX seconds | PX milliseconds | PXAT unix-milliseconds]
+	"SET": {minArgs: 3, maxArgs: 5, write: func(store *Store, args []string) (any, []string) {
+		var expiresAt time.Time
+		if len(args) == 5 {
+			n, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || n <= 0 {
+				return errNotInteger, nil
+			}
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				expiresAt = store.now().Add(time.Duration(n) * time.Second)
+			case "PX":
+				expiresAt = store.now().Add(time.Duration(n) * time.Millisecond)
+			case "PXAT":
+				expiresAt = time.UnixMilli(n)
+			default:
+				return errSyntax, nil
+			}
+		} else if len(args) == 4 {
+			return errSyntax, nil
+		}
+		store.Set(args[1], args[2], expiresAt)
+		logged := []string{"SET", args[1], args[2]}
+		if !expiresAt.IsZero() {
+			logged = append(logged, "PXAT", strconv.FormatInt(expiresAt.UnixMilli(), 10))
+		}
+		return resp.Status("OK"), logged
+	}},
+	"DEL": {minArgs: 2, write: func(store *Store, args []string) (any, []string) {
+		removed := store.Del(args[1:]...)
+		if removed == 0 {
+			return 0, nil
+		}
+		return removed, args
+	}},
+	// EXPIRE key seconds, logged as PEXPIREAT with the absolute deadline
+	"EXPIRE": {minArgs: 3, maxArgs: 3, write: func(store *Store, args []string) (any, []string) {
+		seconds, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInteger, nil
+		}
+		return expireAt(store, args[1], store.now().Add(time.Duration(seconds)*time.Second))
+	}},
+	"PEXPIREAT": {minArgs: 3, maxArgs: 3, write: func(store *Store, args []string) (any, []string) {
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInteger, nil
+		}
+		return expireAt(store, args[1], time.UnixMilli(ms))
+	}},
+}
+
+// expireAt sets the deadline of key; a deadline in the past deletes it, as in Redis
+func expireAt(store *Store, key string, deadline time.Time) (any, []string) {
+	if !deadline.After(store.now()) {
+		if store.Del(key) == 0 {
+			return 0, nil
+		}
+		return 1, []string{"DEL", key}
+	}
+	if !store.ExpireAt(key, deadline) {
+		return 0, nil
+	}
+	return 1, []string{"PEXPIREAT", key, strconv.FormatInt(deadline.UnixMilli(), 10)}
+}
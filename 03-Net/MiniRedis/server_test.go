@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/03-Net/MiniRedis/client"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// TestServer runs a server on a temporary AOF, checks the commands with the client
+// and a raw connection, then restarts from the AOF and checks that the data survived.
+// The subtests run in order on the same data, each one needs the previous
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.aof")
+	logger := log.New(io.Discard, "", 0)
+	ctx := context.Background()
+
+	server, client, stop := start(t, path, logger)
+
+	// Basic commands through the client
+	ok := t.Run("commands", func(t *testing.T) {
+		client.Set(ctx, "user:1", "ada", 0)
+		client.Set(ctx, "user:2", "linus", 0)
+		client.Set(ctx, "session", "token", time.Hour)
+		client.Set(ctx, "short", "lived", 50*time.Millisecond)
+		if value, found, err := client.Get(ctx, "user:1"); err != nil || !found || value != "ada" {
+			t.Fatalf("GET user:1 = %q %t %v", value, found, err)
+		}
+		if _, found, _ := client.Get(ctx, "missing"); found {
+			t.Fatal("GET of a missing key found a value")
+		}
+		if keys, _ := client.Keys(ctx, "user:*"); !slices.Equal(keys, []string{"user:1", "user:2"}) {
+			t.Fatalf("KEYS user:* = %q", keys)
+		}
+		if ttl, _ := client.TTL(ctx, "session"); ttl != 3600 {
+			t.Fatalf("TTL session = %d, want 3600", ttl)
+		}
+		if ok, _ := client.Expire(ctx, "user:2", time.Hour); !ok {
+			t.Fatal("EXPIRE user:2 failed")
+		}
+		if n, _ := client.Del(ctx, "user:2", "missing"); n != 1 {
+			t.Fatalf("DEL removed %d keys, want 1", n)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, found, _ := client.Get(ctx, "short"); found {
+			t.Fatal("an expired key was returned")
+		}
+		if _, err := client.Do(ctx, "GET"); err == nil || err.Error() != "ERR wrong number of arguments for 'get' command" {
+			t.Fatalf("GET without key: %v", err)
+		}
+		if _, err := client.Do(ctx, "SET", "k", "v", "EX", "abc"); err == nil {
+			t.Fatal("SET with an invalid EX was accepted")
+		}
+
+		// Concurrent clients share the pool
+		var wg sync.WaitGroup
+		for i := range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				key := fmt.Sprintf("counter:%d", i)
+				client.Set(ctx, key, fmt.Sprint(i), 0)
+			}()
+		}
+		wg.Wait()
+		if keys, _ := client.Keys(ctx, "counter:*"); len(keys) != 20 {
+			t.Fatalf("got %d counters, want 20", len(keys))
+		}
+	})
+
+	// Inline commands and pipelining over a raw connection, like nc
+	ok = ok && t.Run("raw", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprint(conn, "PING\r\nGET user:1\r\nFLY away\r\nQUIT\r\n")
+
+		reader := bufio.NewReader(conn)
+		for _, want := range []string{"+PONG", "$3", "ada", "-ERR unknown command 'FLY'", "+OK"} {
+			if got, err := resp.ReadLine(reader); err != nil || got != want {
+				t.Fatalf("raw reply %q (%v), want %q", got, err, want)
+			}
+		}
+		if _, err := reader.ReadByte(); err != io.EOF {
+			t.Fatal("QUIT didn't close the connection")
+		}
+	})
+	stop()
+	if !ok {
+		return
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		// A crash in the middle of a write leaves half a command at the end of the file
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString("*3\r\n$3\r\nSET\r\n$4\r\nhalf")
+		file.Close()
+
+		// The data survives a restart; the expired and deleted keys stay gone
+		_, client, stop := start(t, path, logger)
+		defer stop()
+		if value, found, _ := client.Get(ctx, "user:1"); !found || value != "ada" {
+			t.Fatalf("after restart GET user:1 = %q %t", value, found)
+		}
+		if ttl, _ := client.TTL(ctx, "session"); ttl < 3590 || ttl > 3600 {
+			t.Fatalf("after restart TTL session = %d, want the original deadline", ttl)
+		}
+		keys, _ := client.Keys(ctx, "*")
+		if len(keys) != 22 || slices.Contains(keys, "user:2") || slices.Contains(keys, "short") || slices.Contains(keys, "half") {
+			t.Fatalf("after restart got %d keys: %q", len(keys), keys)
+		}
+		// New writes are appended after the truncated tail
+		if err := client.Set(ctx, "after", "crash", 0); err != nil {
+			t.Fatalf("SET after the truncated tail: %v", err)
+		}
+	})
+}
+
+// runningServer is a server listening on a random port
+type runningServer struct {
+	*Server
+	addr string
+}
+
+// start opens the AOF, serves on a random port and returns a client; stop shuts everything down
+func start(t *testing.T, path string, logger *log.Logger) (*runningServer, *client.Client, func()) {
+	t.Helper()
+	server, err := Open(path, FsyncAlways, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	c := client.New(listener.Addr().String(), 4)
+	stop := func() {
+		c.Close()
+		listener.Close()
+		server.aof.Close()
+	}
+	return &runningServer{Server: server, addr: listener.Addr().String()}, c, stop
+}
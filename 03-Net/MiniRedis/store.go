@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+// errMissing is the answer of the loader of the store: keys are only added by Set
+var errMissing = errors.New("miniredis: missing key")
+
+// Store is the in-memory keyspace: pkg/cache used as a map, where every key has its own
+// deadline (cache.SetUntil); expired keys are hidden on access and removed by Sweep
+type Store struct {
+	data  *cache.Memory[string, string]
+	clock clock.Clock // The clock of the cache, replaced by the checks to control the time
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return newStore(clock.Real{})
+}
+
+// newStore creates an empty store on the time of source
+func newStore(source clock.Clock) *Store {
+	data := cache.NewCache(func(key string, m *cache.Memory[string, string]) (string, error) {
+		return "", errMissing
+	}, cache.WithClock(source))
+	return &Store{data: data, clock: source}
+}
+
+// now is the time the deadlines of the commands are computed from
+func (s *Store) now() time.Time {
+	return s.clock.Now()
+}
+
+// Get returns the value of key
+func (s *Store) Get(key string) (string, bool) {
+	value, err := s.data.Get(key)
+	return value, err == nil
+}
+
+// Set stores value; expiresAt is absolute so replaying the AOF restores the same deadline,
+// the zero time means no expiration (and removes a previous one, like Redis)
+func (s *Store) Set(key, value string, expiresAt time.Time) {
+	s.data.SetUntil(key, value, expiresAt)
+}
+
+// Del removes the keys and returns how many existed. The server serialises the writes,
+// so a key can't be set again between the check and the removal.
+func (s *Store) Del(keys ...string) int {
+	removed := 0
+	for _, key := range keys {
+		if _, live := s.data.Expiry(key); live {
+			removed++
+		}
+		s.data.Delete(key)
+	}
+	return removed
+}
+
+// ExpireAt sets the expiration of an existing key, it reports whether the key exists.
+// A deadline in the past deletes the key.
+func (s *Store) ExpireAt(key string, expiresAt time.Time) bool {
+	return s.data.ExpireAt(key, expiresAt)
+}
+
+// TTL returns the time left of key: -1 when it has no expiration, -2 when it doesn't exist
+func (s *Store) TTL(key string) time.Duration {
+	expiresAt, live := s.data.Expiry(key)
+	switch {
+	case !live:
+		return -2
+	case expiresAt.IsZero():
+		return -1
+	}
+	return expiresAt.Sub(s.now())
+}
+
+// Keys returns the live keys matching a glob pattern, sorted, see globMatch
+func (s *Store) Keys(pattern string) []string {
+	keys := []string{}
+	for _, key := range s.data.Keys() {
+		if globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// globMatch is the glob of the Redis KEYS command: * matches any run of bytes, ? one
+// byte, [abc], [a-z] and [^abc] a byte of a class, and \ escapes the next byte.
+// Unlike path.Match, / and : are ordinary bytes, so "user:*" also matches "user:1/2",
+// and a malformed pattern matches what it can instead of failing.
+func globMatch(pattern, key string) bool {
+	p, k := 0, 0
+	// Position of the last * and of the key byte it is matched up to, to backtrack
+	star, starKey := -1, 0
+	for p < len(pattern) || k < len(key) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, starKey = p, k
+				p++
+				continue
+			case '?':
+				if k < len(key) {
+					p, k = p+1, k+1
+					continue
+				}
+			case '[':
+				if k < len(key) {
+					if matched, next := matchClass(pattern, p+1, key[k]); matched {
+						p, k = next, k+1
+						continue
+					}
+				}
+			case '\\':
+				literal := p
+				if p+1 < len(pattern) {
+					literal = p + 1
+				}
+				if k < len(key) && key[k] == pattern[literal] {
+					p, k = literal+1, k+1
+					continue
+				}
+			default:
+				if k < len(key) && key[k] == pattern[p] {
+					p, k = p+1, k+1
+					continue
+				}
+			}
+		}
+		// Mismatch: let the last * swallow one more byte and try again
+		if star < 0 || starKey >= len(key) {
+			return false
+		}
+		starKey++
+		p, k = star+1, starKey
+	}
+	return true
+}
+
+// matchClass matches b against the class starting at pattern[p], just after the '[',
+// and returns the position after its ']'; an unterminated class ends with the pattern
+func matchClass(pattern string, p int, b byte) (matched bool, next int) {
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+	for ; p < len(pattern) && pattern[p] != ']'; p++ {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			matched = matched || pattern[p] == b
+		case p+2 < len(pattern) && pattern[p+1] == '-':
+			low, high := pattern[p], pattern[p+2]
+			if low > high {
+				low, high = high, low
+			}
+			matched = matched || low <= b && b <= high
+			p += 2
+		default:
+			matched = matched || pattern[p] == b
+		}
+	}
+	return matched != negate, min(p+1, len(pattern))
+}
+
+// Sweep removes the expired keys and returns how many; expired keys are already
+// invisible, this only gives their memory back
+func (s *Store) Sweep() int {
+	return s.data.DeleteExpired()
+}
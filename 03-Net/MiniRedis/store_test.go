@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"*", "user:1/2", true},
+		{"user:*", "user:1", true},
+		{"user:*", "user:1/2", true},
+		{"user:*", "users", false},
+		{"*:1", "a/b:1", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"*a*b", "xaxxbab", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h[\]]llo`, "h]llo", true},
+		{`a\`, `a\`, true},
+		{"a[bc", "ab", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestStoreKeys(t *testing.T) {
+	store := NewStore()
+	for _, key := range []string{"user:1", "user:1/posts", "user:2", "session:1"} {
+		store.Set(key, "x", time.Time{})
+	}
+	if keys := store.Keys("user:*"); !slices.Equal(keys, []string{"user:1", "user:1/posts", "user:2"}) {
+		t.Errorf("Keys(user:*) = %q", keys)
+	}
+	if keys := store.Keys("*:1"); !slices.Equal(keys, []string{"session:1", "user:1"}) {
+		t.Errorf("Keys(*:1) = %q", keys)
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	store := newStore(fake)
+	store.Set("session", "x", fake.Now().Add(10*time.Second))
+	store.Set("user", "y", time.Time{})
+	if ttl := store.TTL("session"); ttl != 10*time.Second {
+		t.Errorf("TTL(session) = %s, want 10s", ttl)
+	}
+	if ttl := store.TTL("user"); ttl != -1 {
+		t.Errorf("TTL(user) = %s, want -1", ttl)
+	}
+
+	fake.Advance(10 * time.Second)
+	if _, found := store.Get("session"); found {
+		t.Error("Get(session) found the key after its deadline")
+	}
+	if ttl := store.TTL("session"); ttl != -2 {
+		t.Errorf("TTL(session) = %s, want -2", ttl)
+	}
+	if removed := store.Del("session", "user"); removed != 1 {
+		t.Errorf("Del removed %d keys, want 1: an expired key doesn't count", removed)
+	}
+	store.Set("cart", "z", fake.Now().Add(time.Second))
+	fake.Advance(time.Second)
+	if removed := store.Sweep(); removed != 1 {
+		t.Errorf("Sweep removed %d keys, want 1", removed)
+	}
+}
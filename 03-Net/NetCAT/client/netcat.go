@@ -1,20 +1,59 @@
 package main
 
 import (
+	"bufio"
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"os"
+	"strings"
+
+	"github.com/Arcanm/go_advanced_course/pkg/frame"
+	"github.com/Arcanm/go_advanced_course/pkg/mtls"
 )
 
 // Command line flags for client configuration
 var (
 	port = flag.Int("port", 3090, "port to connect to")
 	host = flag.String("host", "localhost", "host to connect to")
+	// Mutual TLS material, required when the server runs with --cert
+	caFile     = flag.String("ca", "", "CA bundle used to verify the server")
+	certFile   = flag.String("cert", "", "client certificate, empty connects over plain TCP")
+	keyFile    = flag.String("key", "", "client private key")
+	serverName = flag.String("server-name", "", "name expected in the server certificate, defaults to --host")
+	allowed    = flag.String("allow", "", "comma separated SANs accepted from the server, empty accepts any certificate of the CA")
 )
 
+// maxMessageSize matches the limit of the server
+const maxMessageSize = 64 * 1024
+
+// message is the JSON payload of every frame of the chat, as in the server
+type message struct {
+	From string `json:"from,omitempty"`
+	Text string `json:"text"`
+}
+
+// dial connects over plain TCP, or with mutual TLS when a certificate is given (see pkg/mtls)
+func dial(addr string) (net.Conn, error) {
+	if *certFile == "" {
+		return net.Dial("tcp", addr)
+	}
+	var sans []string
+	for _, san := range strings.Split(*allowed, ",") {
+		if san = strings.TrimSpace(san); san != "" {
+			sans = append(sans, san)
+		}
+	}
+	reloader, err := mtls.Load(mtls.Config{CAFile: *caFile, CertFile: *certFile, KeyFile: *keyFile, ServerName: *serverName, AllowedSANs: sans})
+	if err != nil {
+		return nil, err
+	}
+	// The certificate of the server must be for --host when --server-name is empty
+	return tls.Dial("tcp", addr, reloader.ClientConfig(*host))
+}
+
 // main is the entry point of the chat client application
 // It establishes a connection to the chat server and handles bidirectional communication
 func main() {
@@ -22,7 +61,7 @@ func main() {
 	flag.Parse()
 
 	// Connect to the chat server
-	conn, err := net.Dial("tcp", net.JoinHostPort(*host, fmt.Sprintf("%d", *port)))
+	conn, err := dial(net.JoinHostPort(*host, fmt.Sprintf("%d", *port)))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -34,8 +73,19 @@ func main() {
 	// Goroutine to read from the server and write to stdout
 	// This handles incoming messages from other clients
 	go func() {
-		// Copy all data from the connection to stdout
-		io.Copy(os.Stdout, conn)
+		// Print every frame until the connection fails; the notices of the server have no sender
+		decoder := frame.NewDecoder(conn, maxMessageSize, frame.JSON)
+		for {
+			var msg message
+			if err := decoder.Decode(&msg); err != nil {
+				break
+			}
+			if msg.From == "" {
+				fmt.Println(msg.Text)
+			} else {
+				fmt.Printf("%s: %s\n", msg.From, msg.Text)
+			}
+		}
 		// Log when the connection is closed
 		log.Println("Connection closed by remote host")
 		// Signal that this goroutine is done
@@ -45,8 +95,14 @@ func main() {
 	// Goroutine to read from stdin and write to the server
 	// This handles outgoing messages from this client
 	go func() {
-		// Copy all data from stdin to the connection
-		io.Copy(conn, os.Stdin)
+		// Send every line of stdin as one frame
+		encoder := frame.NewEncoder(conn, maxMessageSize, frame.JSON)
+		lines := bufio.NewScanner(os.Stdin)
+		for lines.Scan() {
+			if err := encoder.Encode(message{Text: lines.Text()}); err != nil {
+				break
+			}
+		}
 		// Signal that this goroutine is done
 		done <- struct{}{}
 	}()
@@ -1,16 +1,33 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/frame"
+	"github.com/Arcanm/go_advanced_course/pkg/mtls"
+	"github.com/Arcanm/go_advanced_course/pkg/throttle"
 )
 
+// MaxMessageSize is the largest frame accepted from a client or sent to one
+const MaxMessageSize = 64 * 1024
+
+// Message is the JSON payload of every frame of the chat (see pkg/frame).
+// From is empty for the notices of the server itself.
+type Message struct {
+	From string `json:"from,omitempty"`
+	Text string `json:"text"`
+}
+
 // Client represents a connected user in the chat system.
-// It's a channel that can only send strings (chan<- string)
-type Client chan<- string
+// It's a channel that can only send messages (chan<- Message)
+type Client chan<- Message
 
 // Global variables for managing the chat system
 var (
@@ -19,10 +36,17 @@ var (
 	// LeavingClients channel receives clients when they disconnect
 	LeavingClients = make(chan Client)
 	// ChatMessages channel receives all messages to be broadcasted
-	ChatMessages = make(chan string)
+	ChatMessages = make(chan Message)
 	// Host and Port for the server configuration
 	Host = flag.String("host", "localhost", "host to connect to")
 	Port = flag.Int("port", 3090, "port to connect to")
+	// Rate limits the bandwidth of every connection in bytes/second, 0 is unlimited
+	Rate = flag.Int("rate", 0, "bandwidth limit per connection in bytes/second, 0 is unlimited")
+	// Mutual TLS material; when CertFile is set every client must present a certificate of the CA
+	CAFile       = flag.String("ca", "", "CA bundle used to verify the clients")
+	CertFile     = flag.String("cert", "", "server certificate, empty serves plain TCP")
+	KeyFile      = flag.String("key", "", "server private key")
+	AllowedPeers = flag.String("allow", "", "comma separated SANs accepted from the clients, empty accepts any certificate of the CA")
 )
 
 // HandleConn manages a single client connection
@@ -32,7 +56,7 @@ func HandleConn(conn net.Conn) {
 	defer conn.Close()
 
 	// Create a channel for this client's messages
-	clientMessages := make(chan string)
+	clientMessages := make(chan Message)
 	// Start a goroutine to write messages to this client
 	go MessageWriter(conn, clientMessages)
 
@@ -40,33 +64,39 @@ func HandleConn(conn net.Conn) {
 	clientName := conn.RemoteAddr().String()
 
 	// Send welcome message to the new client
-	clientMessages <- fmt.Sprintf("Welcome to the chat, %s!", clientName)
+	clientMessages <- Message{Text: fmt.Sprintf("Welcome to the chat, %s!", clientName)}
 	// Broadcast that a new client has joined
-	ChatMessages <- fmt.Sprintf("New client %s has joined", clientName)
+	ChatMessages <- Message{Text: fmt.Sprintf("New client %s has joined", clientName)}
 	// Register this client in the system
 	IncomingClients <- clientMessages
 
-	// Create a scanner to read messages from the client
-	inputMessage := bufio.NewScanner(conn)
-	// Read messages until the client disconnects
-	for inputMessage.Scan() {
+	// Read framed messages until the client disconnects or sends a broken or oversized frame
+	decoder := frame.NewDecoder(conn, MaxMessageSize, frame.JSON)
+	for {
+		var msg Message
+		if err := decoder.Decode(&msg); err != nil {
+			break
+		}
+		// The sender is always the connection, whatever the client put in From
+		msg.From = clientName
 		// Broadcast the message to all clients
-		ChatMessages <- clientName + ": " + inputMessage.Text()
+		ChatMessages <- msg
 	}
 
 	// Client has disconnected
 	LeavingClients <- clientMessages
 	// Broadcast that the client has left
-	ChatMessages <- fmt.Sprintf("Client %s has left", clientName)
+	ChatMessages <- Message{Text: fmt.Sprintf("Client %s has left", clientName)}
 }
 
 // MessageWriter continuously reads from the client's message channel
 // and writes the messages to the client's connection
-func MessageWriter(conn net.Conn, clientMessages <-chan string) {
+func MessageWriter(conn net.Conn, clientMessages <-chan Message) {
+	encoder := frame.NewEncoder(conn, MaxMessageSize, frame.JSON)
 	// Range over the channel until it's closed
 	for msg := range clientMessages {
-		// Write each message to the client's connection
-		fmt.Fprintln(conn, msg)
+		// Write each message to the client's connection as one frame
+		encoder.Encode(msg)
 	}
 }
 
@@ -112,6 +142,17 @@ func StartServer() {
 	}
 	defer listener.Close()
 
+	// With TLS material, only clients with a certificate of the CA can join (see pkg/mtls).
+	// The files are checked for changes, so rotated certificates apply without a restart.
+	if *CertFile != "" {
+		reloader, err := mtls.Load(mtls.Config{CAFile: *CAFile, CertFile: *CertFile, KeyFile: *KeyFile, AllowedSANs: splitList(*AllowedPeers)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		go reloader.Watch(context.Background(), 10*time.Second, func(err error) { log.Print(err) })
+		listener = tls.NewListener(listener, reloader.ServerConfig())
+	}
+
 	// Start the broadcast goroutine
 	go Broadcast()
 
@@ -123,11 +164,25 @@ func StartServer() {
 			log.Print(err)
 			continue
 		}
+		// Every connection gets its own limiters, so a client flooding the chat
+		// can't use the bandwidth of the others (see pkg/throttle)
+		conn = throttle.NewConn(conn, throttle.ForRate(*Rate), throttle.ForRate(*Rate))
 		// Handle the connection in a new goroutine
 		go HandleConn(conn)
 	}
 }
 
+// splitList splits a comma separated flag, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // main is the entry point of the chat server application
 // It parses command line flags and starts the chat server
 func main() {
@@ -0,0 +1,91 @@
+// This program sends ICMP echo requests to a host and prints the round trip times,
+// followed by a summary like the one of the ping command
+// Without root it uses unprivileged ICMP sockets (see ping.NewPinger)
+// RUN PROGRAM WITH FLAGS
+// go run . --count=5 --interval=1s --timeout=2s golang.org
+// sudo go run . scanme.nmap.org
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/ping"
+)
+
+var (
+	count    = flag.Int("count", 4, "number of requests, 0 pings until interrupted")
+	interval = flag.Duration("interval", time.Second, "time between requests")
+	timeout  = flag.Duration("timeout", 2*time.Second, "time to wait for each reply")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go run . [flags] host")
+		os.Exit(2)
+	}
+	host := flag.Arg(0)
+
+	addr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Resolve error:", err)
+		os.Exit(1)
+	}
+
+	pinger, err := ping.NewPinger()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer pinger.Close()
+
+	mode := "raw socket"
+	if !pinger.Privileged() {
+		mode = "unprivileged datagram socket"
+	}
+	fmt.Printf("PING %s (%s) using %s\n", host, addr.IP, mode)
+
+	// Ctrl+C stops the loop but still prints the summary
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var stats ping.Statistics
+	for seq := 0; *count == 0 || seq < *count; seq++ {
+		if seq > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(*interval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		reply, err := pinger.Ping(addr.IP, seq, *timeout)
+		stats.Add(reply.RTT, err == nil)
+		switch {
+		case errors.Is(err, ping.ErrTimeout):
+			fmt.Printf("Request timeout for seq=%d\n", seq)
+		case err != nil:
+			fmt.Printf("seq=%d error: %v\n", seq, err)
+		default:
+			fmt.Printf("%d bytes from %s: seq=%d time=%s\n", reply.Size, reply.From, reply.Seq, reply.RTT.Round(time.Microsecond))
+		}
+	}
+
+	minRTT, avg, maxRTT, stddev := stats.Summary()
+	fmt.Printf("\n--- %s ping statistics ---\n", host)
+	fmt.Printf("%d packets transmitted, %d received, %.1f%% packet loss\n", stats.Sent, len(stats.RTTs), stats.Loss())
+	if len(stats.RTTs) > 0 {
+		fmt.Printf("rtt min/avg/max/stddev = %s/%s/%s/%s\n",
+			minRTT.Round(time.Microsecond), avg.Round(time.Microsecond), maxRTT.Round(time.Microsecond), stddev.Round(time.Microsecond))
+	}
+}
@@ -1,23 +1,60 @@
 // This program scans ports on a given website to check which ones are open
+// With --discover the host is pinged first (see pkg/ping) and the scan is skipped if it
+// doesn't answer. Many hosts drop ICMP, so discovery is disabled by default.
 // RUN PROGRAM WITH FLAGS
-// go run port.go --webSite=scanme.webscantest.com
+// go run . --site=scanme.webscantest.com
+// go run . --site=scanme.nmap.org --discover
 package main
 
 import (
 	"flag"
 	"fmt"
 	"net"
+	"os"
 	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/ping"
 )
 
 // Define command line flag for the website to scan
 // Default value is scanme.nmap.org which is a site specifically for testing port scanning
 var webSite = flag.String("site", "scanme.nmap.org", "url to scan ports")
 
+// Host discovery flag, checks with ICMP echo that the host is up before scanning
+var discover = flag.Bool("discover", false, "ping the host before scanning and skip it if it is down")
+
+// hostUp pings the site with ICMP echo requests
+func hostUp(site string) (bool, error) {
+	addr, err := net.ResolveIPAddr("ip4", site)
+	if err != nil {
+		return false, err
+	}
+	pinger, err := ping.NewPinger()
+	if err != nil {
+		return false, err
+	}
+	defer pinger.Close()
+	return pinger.HostUp(addr.IP, 3, time.Second), nil
+}
+
 func main() {
 	// Parse command line flags
 	flag.Parse()
 
+	if *discover {
+		up, err := hostUp(*webSite)
+		if err != nil {
+			fmt.Println("Host discovery error:", err)
+			os.Exit(1)
+		}
+		if !up {
+			fmt.Printf("Host %s does not answer ping, skipping the scan\n", *webSite)
+			return
+		}
+		fmt.Printf("Host %s is up\n", *webSite)
+	}
+
 	// Create a WaitGroup to synchronize all goroutines
 	var wg sync.WaitGroup
 
@@ -0,0 +1,97 @@
+package main
+
+import "sync/atomic"
+
+// Backend is a server behind the proxy together with its metrics.
+// The counters are atomic because every proxied connection updates them.
+type Backend struct {
+	Addr     string
+	alive    atomic.Bool
+	active   atomic.Int64 // Connections currently open
+	total    atomic.Int64 // Connections handled since start
+	failures atomic.Int64 // Dials that failed
+	bytesIn  atomic.Int64 // Client to backend
+	bytesOut atomic.Int64 // Backend to client
+}
+
+// NewBackend creates a backend considered alive until the first health check says otherwise
+func NewBackend(addr string) *Backend {
+	b := &Backend{Addr: addr}
+	b.alive.Store(true)
+	return b
+}
+
+func (b *Backend) Alive() bool {
+	return b.alive.Load()
+}
+
+// BackendStats is a copy of the metrics of a backend at one moment
+type BackendStats struct {
+	Addr     string
+	Alive    bool
+	Active   int64
+	Total    int64
+	Failures int64
+	BytesIn  int64
+	BytesOut int64
+}
+
+func (b *Backend) Stats() BackendStats {
+	return BackendStats{
+		Addr:     b.Addr,
+		Alive:    b.Alive(),
+		Active:   b.active.Load(),
+		Total:    b.total.Load(),
+		Failures: b.failures.Load(),
+		BytesIn:  b.bytesIn.Load(),
+		BytesOut: b.bytesOut.Load(),
+	}
+}
+
+// Balancer chooses the backend for a new connection among the alive ones.
+// It is the Strategy of the proxy: the strategies can be swapped with the --strategy flag.
+type Balancer interface {
+	Next(backends []*Backend) *Backend
+}
+
+// RoundRobin hands the connections to the backends in turn
+type RoundRobin struct {
+	counter atomic.Uint64
+}
+
+func (r *RoundRobin) Next(backends []*Backend) *Backend {
+	// Start at the next position and skip the dead backends
+	start := r.counter.Add(1)
+	for i := range uint64(len(backends)) {
+		b := backends[(start+i)%uint64(len(backends))]
+		if b.Alive() {
+			return b
+		}
+	}
+	return nil
+}
+
+// LeastConnections picks the backend with the fewest open connections,
+// which adapts better than round-robin when connections have very different durations
+type LeastConnections struct{}
+
+func (LeastConnections) Next(backends []*Backend) *Backend {
+	var best *Backend
+	for _, b := range backends {
+		if b.Alive() && (best == nil || b.active.Load() < best.active.Load()) {
+			best = b
+		}
+	}
+	return best
+}
+
+// NewBalancer returns the balancer for the --strategy flag
+func NewBalancer(strategy string) (Balancer, bool) {
+	switch strategy {
+	case "round-robin":
+		return &RoundRobin{}, true
+	case "least-connections":
+		return LeastConnections{}, true
+	}
+	return nil, false
+}
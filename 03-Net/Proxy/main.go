@@ -0,0 +1,235 @@
+// This program is a TCP reverse proxy that load-balances connections across several backends
+// - Strategies: round-robin or least-connections (see balancer.go)
+// - Health checks mark the backends that refuse connections as down, and up again when they recover
+// - On Ctrl+C it stops accepting connections and drains the open ones before exiting
+// - Per-backend metrics: open and total connections, failures and bytes in each direction
+// - Optional mutual TLS on both sides: clients and backends must present a certificate of the CA
+// Without --backends it runs a demo with three local echo backends and a few clients
+// RUN PROGRAM WITH FLAGS
+// go run . --strategy=least-connections
+// go run . --listen=localhost:9000 --backends=localhost:9001,localhost:9002 --drain=10s
+// go run . --backends=node-1:9001,node-2:9001 --ca=ca.pem --cert=proxy.pem --key=proxy-key.pem --allow='*.cluster.local'
+// go test -race .
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/mtls"
+)
+
+var (
+	listen         = flag.String("listen", "localhost:9000", "address the proxy listens on")
+	backendList    = flag.String("backends", "", "comma separated backend addresses, empty runs the demo")
+	strategy       = flag.String("strategy", "round-robin", "round-robin or least-connections")
+	healthInterval = flag.Duration("health-interval", 2*time.Second, "time between health checks")
+	drain          = flag.Duration("drain", 10*time.Second, "maximum time to wait for open connections on shutdown")
+	caFile         = flag.String("ca", "", "CA bundle used to verify clients and backends")
+	certFile       = flag.String("cert", "", "certificate of the proxy, empty uses plain TCP on both sides")
+	keyFile        = flag.String("key", "", "private key of the proxy")
+	allowed        = flag.String("allow", "", "comma separated SANs accepted from clients and backends, empty accepts any certificate of the CA")
+)
+
+// printStats writes the metrics of every backend as a table
+func printStats(stats []BackendStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tALIVE\tACTIVE\tTOTAL\tFAILURES\tBYTES IN\tBYTES OUT")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%d\t%d\t%d\n", s.Addr, s.Alive, s.Active, s.Total, s.Failures, s.BytesIn, s.BytesOut)
+	}
+	w.Flush()
+}
+
+// startEchoBackend answers every line with "<name>: <line>"
+func startEchoBackend(name string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintf(conn, "%s: %s\n", name, scanner.Text())
+				}
+			}()
+		}
+	}()
+	return listener, nil
+}
+
+// sendLine opens a connection through the proxy, sends a line and keeps the connection
+// open for hold before reading the answer
+func sendLine(addr, line string, hold time.Duration) (string, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, line)
+	time.Sleep(hold)
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	return strings.TrimSpace(answer), err
+}
+
+func runDemo(balancer Balancer, logger *log.Logger) error {
+	var backends []*Backend
+	var listeners []net.Listener
+	for i := range 3 {
+		listener, err := startEchoBackend(fmt.Sprintf("backend-%d", i+1))
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, listener)
+		backends = append(backends, NewBackend(listener.Addr().String()))
+	}
+
+	proxyListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return err
+	}
+	proxy := NewProxy(backends, balancer, logger)
+	go proxy.Serve(proxyListener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.HealthCheck(ctx, 100*time.Millisecond)
+
+	// A round of concurrent clients; the first ones keep their connection open longer,
+	// which is where least-connections and round-robin differ
+	round := func(name string, clients int) {
+		var wg sync.WaitGroup
+		for i := range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				hold := 10 * time.Millisecond
+				if i < 2 {
+					hold = 300 * time.Millisecond
+				}
+				// Stagger the clients so the balancer sees the open connections
+				time.Sleep(time.Duration(i) * 20 * time.Millisecond)
+				answer, err := sendLine(proxyListener.Addr().String(), fmt.Sprintf("%s client %d", name, i), hold)
+				if err != nil {
+					fmt.Printf("%s client %d error: %v\n", name, i, err)
+					return
+				}
+				fmt.Println(answer)
+			}()
+		}
+		wg.Wait()
+		// The proxy closes its side shortly after the clients, let the counters settle
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	round("first", 9)
+	printStats(proxy.Stats())
+
+	// Stop a backend: the health check or the first failed dial marks it down
+	fmt.Println("\nStopping", backends[2].Addr)
+	listeners[2].Close()
+	time.Sleep(250 * time.Millisecond)
+	round("second", 6)
+	printStats(proxy.Stats())
+
+	// Draining: a slow client is still connected when the shutdown starts
+	go sendLine(proxyListener.Addr().String(), "slow client", 200*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	shutdownCtx, stop := context.WithTimeout(context.Background(), *drain)
+	defer stop()
+	err = proxy.Shutdown(shutdownCtx)
+	fmt.Printf("\nShutdown after draining for %s (err: %v)\n", time.Since(start).Round(10*time.Millisecond), err)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	balancer, ok := NewBalancer(*strategy)
+	if !ok {
+		logger.Fatalf("unknown strategy %q, use round-robin or least-connections", *strategy)
+	}
+
+	if *backendList == "" {
+		if err := runDemo(balancer, logger); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	var backends []*Backend
+	for _, addr := range strings.Split(*backendList, ",") {
+		backends = append(backends, NewBackend(strings.TrimSpace(addr)))
+	}
+	proxy := NewProxy(backends, balancer, logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go proxy.HealthCheck(ctx, *healthInterval)
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	// With TLS material the proxy is a node of the cluster: it only accepts clients with a
+	// certificate of the CA, and it authenticates itself to the backends with its own
+	if *certFile != "" {
+		var sans []string
+		for _, san := range strings.Split(*allowed, ",") {
+			if san = strings.TrimSpace(san); san != "" {
+				sans = append(sans, san)
+			}
+		}
+		reloader, err := mtls.Load(mtls.Config{CAFile: *caFile, CertFile: *certFile, KeyFile: *keyFile, AllowedSANs: sans})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		go reloader.Watch(ctx, 10*time.Second, func(err error) { logger.Println(err) })
+		listener = tls.NewListener(listener, reloader.ServerConfig())
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		// Every backend must present a certificate for its own host, not just any of the CA
+		proxy.Dial = func(addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			return tls.DialWithDialer(dialer, "tcp", addr, reloader.ClientConfig(host))
+		}
+	}
+	logger.Printf("Proxy listening on %s with %s", listener.Addr(), *strategy)
+	go func() {
+		if err := proxy.Serve(listener); err != nil {
+			logger.Println("Serve error:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Printf("Shutting down, draining connections for up to %s", *drain)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *drain)
+	defer cancel()
+	if err := proxy.Shutdown(shutdownCtx); err != nil {
+		logger.Println("Some connections were closed by force:", err)
+	}
+	printStats(proxy.Stats())
+}
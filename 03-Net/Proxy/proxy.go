@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNoBackend is returned when every backend is down
+var ErrNoBackend = errors.New("no backend available")
+
+// dialTimeout is how long the proxy and the health checks wait for a backend handshake
+const dialTimeout = time.Second
+
+// DialFunc opens a connection to a backend
+type DialFunc func(addr string) (net.Conn, error)
+
+// Proxy accepts TCP connections and pipes each one to a backend chosen by the balancer
+type Proxy struct {
+	backends []*Backend
+	balancer Balancer
+	logger   *log.Logger
+	// Dial connects to the backends and runs the health checks; plain TCP by default,
+	// mutual TLS when the backends are other nodes (see pkg/mtls)
+	Dial DialFunc
+
+	listener net.Listener
+	conns    map[net.Conn]struct{} // Open client connections, closed by force when draining times out
+	wg       sync.WaitGroup        // One per open client connection
+	closing  bool                  // Set by Shutdown, no connection is accepted after it
+	mux      sync.Mutex
+}
+
+// NewProxy creates a proxy for the given backends
+func NewProxy(backends []*Backend, balancer Balancer, logger *log.Logger) *Proxy {
+	return &Proxy{
+		backends: backends,
+		balancer: balancer,
+		logger:   logger,
+		conns:    make(map[net.Conn]struct{}),
+		Dial: func(addr string) (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, dialTimeout)
+		},
+	}
+}
+
+// Serve accepts connections on listener until Shutdown is called
+func (p *Proxy) Serve(listener net.Listener) error {
+	p.mux.Lock()
+	if p.closing {
+		p.mux.Unlock()
+		return listener.Close()
+	}
+	p.listener = listener
+	p.mux.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if !p.track(conn, true) {
+			conn.Close()
+			continue
+		}
+		go p.handle(conn)
+	}
+}
+
+// track adds or removes a client connection from the open set.
+// Adding fails once Shutdown started, so the WaitGroup never grows while it is waited on.
+func (p *Proxy) track(conn net.Conn, open bool) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if open {
+		if p.closing {
+			return false
+		}
+		p.conns[conn] = struct{}{}
+		p.wg.Add(1)
+		return true
+	}
+	delete(p.conns, conn)
+	p.wg.Done()
+	return true
+}
+
+// handle connects the client to a backend and copies data both ways.
+// If the chosen backend refuses the connection it is marked down and the next one is tried.
+func (p *Proxy) handle(client net.Conn) {
+	defer p.track(client, false)
+	defer client.Close()
+
+	for range len(p.backends) {
+		backend := p.balancer.Next(p.backends)
+		if backend == nil {
+			break
+		}
+		server, err := p.Dial(backend.Addr)
+		if err != nil {
+			backend.failures.Add(1)
+			backend.alive.Store(false)
+			p.logger.Printf("backend %s failed: %v", backend.Addr, err)
+			continue
+		}
+		p.pipe(client, server, backend)
+		return
+	}
+	p.logger.Printf("%s: %v", client.RemoteAddr(), ErrNoBackend)
+}
+
+// pipe copies in both directions until one side closes
+func (p *Proxy) pipe(client, server net.Conn, backend *Backend) {
+	defer server.Close()
+	backend.active.Add(1)
+	backend.total.Add(1)
+	defer backend.active.Add(-1)
+
+	done := make(chan struct{})
+	go func() {
+		n, _ := io.Copy(server, client)
+		backend.bytesIn.Add(n)
+		// Tell the backend the client finished writing, but keep reading its answer
+		if half, ok := server.(interface{ CloseWrite() error }); ok {
+			half.CloseWrite()
+		}
+		close(done)
+	}()
+	n, _ := io.Copy(client, server)
+	backend.bytesOut.Add(n)
+	// The backend is done: closing the client also ends the other copy
+	client.Close()
+	<-done
+}
+
+// HealthCheck dials every backend each interval and updates its alive flag, until ctx is done
+func (p *Proxy) HealthCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, b := range p.backends {
+			conn, err := p.Dial(b.Addr)
+			alive := err == nil
+			if alive {
+				conn.Close()
+			}
+			if b.alive.Swap(alive) != alive {
+				p.logger.Printf("backend %s is now %s", b.Addr, map[bool]string{true: "up", false: "down"}[alive])
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops accepting connections and waits for the open ones to finish (draining).
+// When ctx expires first, the remaining connections are closed by force.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mux.Lock()
+	p.closing = true
+	if p.listener != nil {
+		p.listener.Close()
+	}
+	p.mux.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		p.mux.Lock()
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.mux.Unlock()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+// Stats returns the metrics of every backend
+func (p *Proxy) Stats() []BackendStats {
+	stats := make([]BackendStats, len(p.backends))
+	for i, b := range p.backends {
+		stats[i] = b.Stats()
+	}
+	return stats
+}
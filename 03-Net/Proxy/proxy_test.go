@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startProxy serves a proxy for backends on a random port and shuts it down at the end of the test
+func startProxy(t *testing.T, balancer Balancer, backends ...*Backend) (*Proxy, string) {
+	t.Helper()
+	proxy := NewProxy(backends, balancer, log.New(io.Discard, "", 0))
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go proxy.Serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		proxy.Shutdown(ctx)
+	})
+	return proxy, listener.Addr().String()
+}
+
+// startBackends starts an echo backend per name, stopped at the end of the test
+func startBackends(t *testing.T, names ...string) ([]*Backend, []net.Listener) {
+	t.Helper()
+	var backends []*Backend
+	var listeners []net.Listener
+	for _, name := range names {
+		listener, err := startEchoBackend(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		backends = append(backends, NewBackend(listener.Addr().String()))
+		listeners = append(listeners, listener)
+	}
+	return backends, listeners
+}
+
+// openConn connects to the proxy and waits for the answer of the backend, so the
+// connection is piped when it returns; the answer is the name of the backend
+func openConn(t *testing.T, addr string) (net.Conn, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	fmt.Fprintln(conn, "hello")
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("no answer through the proxy: %v", err)
+	}
+	name, _, _ := strings.Cut(answer, ":")
+	return conn, name
+}
+
+// eventually polls cond until it holds or a second passed
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// TestRoundRobin sends connections one after the other: they go to the backends in
+// turn, and a dead backend is skipped
+func TestRoundRobin(t *testing.T) {
+	backends, _ := startBackends(t, "a", "b", "c")
+	_, addr := startProxy(t, &RoundRobin{}, backends...)
+
+	var order []string
+	for range 6 {
+		answer, err := sendLine(addr, "hello", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, _, _ := strings.Cut(answer, ":")
+		order = append(order, name)
+	}
+	for i := range 3 {
+		if order[i] == order[(i+1)%3] || order[i] != order[i+3] {
+			t.Fatalf("got backends %v, want the three in turn twice", order)
+		}
+	}
+	if !eventually(func() bool {
+		for _, b := range backends {
+			if b.Stats().Total != 2 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Errorf("got %+v, want 2 connections per backend", backends)
+	}
+
+	backends[1].alive.Store(false)
+	for range 4 {
+		if answer, _ := sendLine(addr, "hello", 0); strings.HasPrefix(answer, "b:") {
+			t.Fatal("a backend marked down got a connection")
+		}
+	}
+}
+
+// TestLeastConnections checks the choice of the balancer, then that a proxy with it
+// sends new connections to the backend with the fewest open ones
+func TestLeastConnections(t *testing.T) {
+	t.Run("next", func(t *testing.T) {
+		backends := []*Backend{NewBackend("a"), NewBackend("b"), NewBackend("c"), NewBackend("d")}
+		for i, active := range []int64{3, 1, 2, 0} {
+			backends[i].active.Store(active)
+		}
+		backends[3].alive.Store(false)
+		if got := (LeastConnections{}).Next(backends); got != backends[1] {
+			t.Errorf("got backend %s, want b: d has fewer connections but is down", got.Addr)
+		}
+		for _, b := range backends {
+			b.alive.Store(false)
+		}
+		if got := (LeastConnections{}).Next(backends); got != nil {
+			t.Errorf("got backend %s with every backend down, want nil", got.Addr)
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		backends, _ := startBackends(t, "a", "b")
+		_, addr := startProxy(t, LeastConnections{}, backends...)
+
+		// Both backends are idle: the first one gets the connection, the second one the next
+		_, first := openConn(t, addr)
+		held, second := openConn(t, addr)
+		if first != "a" || second != "b" {
+			t.Fatalf("got backends %s and %s, want a and b", first, second)
+		}
+
+		// Once the connection to b closes, b has fewer connections than a again
+		held.Close()
+		if !eventually(func() bool { return backends[1].Stats().Active == 0 }) {
+			t.Fatal("the closed connection is still counted")
+		}
+		if _, third := openConn(t, addr); third != "b" {
+			t.Errorf("got backend %s, want b with no open connection", third)
+		}
+	})
+}
+
+// TestHealthCheck stops a backend and checks that the health checks mark it down,
+// then up again once it listens on the same address
+func TestHealthCheck(t *testing.T) {
+	backends, listeners := startBackends(t, "a", "b")
+	proxy, addr := startProxy(t, &RoundRobin{}, backends...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.HealthCheck(ctx, 10*time.Millisecond)
+
+	listeners[1].Close()
+	if !eventually(func() bool { return !backends[1].Alive() }) {
+		t.Fatal("the stopped backend is still alive")
+	}
+	if !backends[0].Alive() {
+		t.Fatal("the running backend was marked down")
+	}
+	for range 4 {
+		if _, name := openConn(t, addr); name != "a" {
+			t.Fatalf("got backend %s, want a while b is down", name)
+		}
+	}
+
+	restarted, err := net.Listen("tcp", backends[1].Addr)
+	if err != nil {
+		t.Skipf("can't listen again on %s: %v", backends[1].Addr, err)
+	}
+	defer restarted.Close()
+	if !eventually(backends[1].Alive) {
+		t.Error("the restarted backend is still down")
+	}
+}
+
+// TestDialFailure checks that a backend refusing a connection is marked down at once,
+// and the connection goes to the next one
+func TestDialFailure(t *testing.T) {
+	backends, listeners := startBackends(t, "a", "b")
+	_, addr := startProxy(t, LeastConnections{}, backends...)
+
+	listeners[0].Close()
+	if _, name := openConn(t, addr); name != "b" {
+		t.Fatalf("got backend %s, want b", name)
+	}
+	if stats := backends[0].Stats(); stats.Alive || stats.Failures != 1 {
+		t.Errorf("got %+v for the stopped backend, want down after 1 failure", stats)
+	}
+}
+
+// TestShutdown checks that Shutdown refuses new connections and waits for the open
+// ones, and closes them by force when its context expires first
+func TestShutdown(t *testing.T) {
+	t.Run("drain", func(t *testing.T) {
+		backends, _ := startBackends(t, "a")
+		proxy, addr := startProxy(t, &RoundRobin{}, backends...)
+		conn, _ := openConn(t, addr)
+
+		done := make(chan error, 1)
+		go func() { done <- proxy.Shutdown(context.Background()) }()
+		select {
+		case err := <-done:
+			t.Fatalf("Shutdown returned %v with a connection open", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		// The listener is closed, but the open connection still works
+		if _, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+			t.Error("the proxy accepted a connection while draining")
+		}
+		fmt.Fprintln(conn, "still here")
+		if answer, err := bufio.NewReader(conn).ReadString('\n'); err != nil || answer != "a: still here\n" {
+			t.Errorf("got %q, %v while draining", answer, err)
+		}
+
+		conn.Close()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Shutdown returned %v after the drain", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Shutdown didn't return once the connection closed")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		backends, _ := startBackends(t, "a")
+		proxy, addr := startProxy(t, &RoundRobin{}, backends...)
+		conn, _ := openConn(t, addr)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := proxy.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want context.DeadlineExceeded", err)
+		}
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("read %v after the forced close, want EOF", err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+// This program forwards TCP connections, like ssh -L / ssh -R without SSH
+// - Forward: listens on --listen and forwards each connection to --remote, over TLS with --tls
+// - Reverse: --relay runs the public side and --reverse the agent next to the service (see tunnel.go)
+// - Every connection logs its byte counters and is closed after --idle without traffic
+// - Ctrl+C cancels the context, which stops the listeners and closes the open pipes
+// RUN PROGRAM WITH FLAGS
+// go run . --listen=localhost:8443 --remote=example.com:443
+// go run . --listen=localhost:8080 --remote=example.com:443 --tls
+// go run . --relay --listen=:9000 --agents=:9001
+// go run . --reverse --remote=relay.example.com:9001 --target=localhost:22 --pool=4
+// go test .
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+)
+
+var (
+	listen   = flag.String("listen", "localhost:9000", "local address accepting the clients (forward and relay)")
+	remote   = flag.String("remote", "", "forward: address to forward to; reverse: address of the relay agents port")
+	useTLS   = flag.Bool("tls", false, "use TLS towards --remote")
+	insecure = flag.Bool("insecure", false, "skip the verification of the --remote certificate")
+	idle     = flag.Duration("idle", 5*time.Minute, "close connections without traffic for this long, 0 disables it")
+	relay    = flag.Bool("relay", false, "run the public side of a reverse tunnel")
+	agents   = flag.String("agents", "localhost:9001", "relay: address accepting the agent connections")
+	reverse  = flag.Bool("reverse", false, "run the agent side of a reverse tunnel")
+	target   = flag.String("target", "", "reverse: address of the service to expose")
+	pool     = flag.Int("pool", 4, "reverse: idle connections kept open to the relay")
+)
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var tlsConfig *tls.Config
+	if *useTLS {
+		host, _, _ := net.SplitHostPort(*remote)
+		tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: *insecure}
+	}
+
+	switch {
+	case *reverse:
+		if *remote == "" || *target == "" {
+			logger.Fatal("--reverse needs --remote (the relay) and --target (the service)")
+		}
+		logger.Printf("Agent exposing %s through the relay %s", *target, *remote)
+		RunAgent(ctx, TCPDialer(*remote, tlsConfig), TCPDialer(*target, nil), *pool, *idle, logger)
+
+	case *relay:
+		r := NewRelay(logger)
+		agentListener, err := net.Listen("tcp", *agents)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		go r.AcceptAgents(ctx, agentListener)
+		serve(ctx, NewForwarder(r.Dial, *idle, logger), logger, "Relay accepting agents on "+*agents+" and clients on")
+
+	default:
+		if *remote == "" {
+			logger.Fatal("--remote is required")
+		}
+		serve(ctx, NewForwarder(TCPDialer(*remote, tlsConfig), *idle, logger), logger, "Forwarding to "+*remote+" from")
+	}
+}
+
+// serve runs the forwarder on --listen until ctx is cancelled
+func serve(ctx context.Context, forwarder *Forwarder, logger *log.Logger, message string) {
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Println(message, listener.Addr())
+	if err := forwarder.Serve(ctx, listener); err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdle is the reason of connections closed by the idle timeout
+var ErrIdle = errors.New("idle timeout")
+
+// Stats are the byte counters of one tunneled connection
+type Stats struct {
+	Sent     int64 // From the client to the remote side
+	Received int64 // From the remote side to the client
+	Duration time.Duration
+	Reason   error // Why the connection ended, nil for a clean close from both sides
+}
+
+// halfCloser is implemented by *net.TCPConn and *tls.Conn
+type halfCloser interface {
+	CloseWrite() error
+}
+
+// Pipe copies data in both directions until both sides are done, ctx is cancelled or
+// no byte moves in either direction for idle (0 disables the timeout).
+//
+// The usual mistakes with bidirectional copies are handled here:
+//   - When one side finishes writing, only the write half of the other side is closed,
+//     so answers still in flight keep arriving (e.g. a client that sends a request and
+//     closes its write side)
+//   - The idle timer is shared by both directions, so a long download with a silent
+//     client is not cut
+//   - Cancelling ctx closes both connections, which unblocks the pending reads
+func Pipe(ctx context.Context, client, remote net.Conn, idle time.Duration) Stats {
+	start := time.Now()
+	var lastActivity atomic.Int64
+	lastActivity.Store(start.UnixNano())
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var sent, received atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		copyCounting(ctx, remote, client, &sent, &lastActivity, cancel)
+	}()
+	go func() {
+		defer wg.Done()
+		copyCounting(ctx, client, remote, &received, &lastActivity, cancel)
+	}()
+
+	// Closing both connections is the only way to unblock a Read from another goroutine
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	var ticker <-chan time.Time
+	if idle > 0 {
+		t := time.NewTicker(idle / 4)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+loop:
+	for {
+		select {
+		case <-done:
+			break loop
+		case <-ctx.Done():
+			client.Close()
+			remote.Close()
+			<-done
+			break loop
+		case <-ticker:
+			if time.Since(time.Unix(0, lastActivity.Load())) >= idle {
+				cancel(ErrIdle)
+			}
+		}
+	}
+	client.Close()
+	remote.Close()
+
+	return Stats{
+		Sent:     sent.Load(),
+		Received: received.Load(),
+		Duration: time.Since(start),
+		Reason:   context.Cause(ctx),
+	}
+}
+
+// copyCounting copies src to dst, counting the bytes and updating the activity time.
+// On EOF it half-closes dst; on an error it cancels the whole pipe.
+func copyCounting(ctx context.Context, dst, src net.Conn, counter, lastActivity *atomic.Int64, cancel context.CancelCauseFunc) {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			lastActivity.Store(time.Now().UnixNano())
+			if _, werr := dst.Write(buffer[:n]); werr != nil {
+				cancel(werr)
+				return
+			}
+			counter.Add(int64(n))
+		}
+		if errors.Is(err, io.EOF) {
+			if hc, ok := dst.(halfCloser); ok {
+				hc.CloseWrite()
+			} else {
+				// Without half-close the only way to signal the end is closing everything
+				cancel(nil)
+			}
+			return
+		}
+		if err != nil {
+			// Reads fail with "use of closed connection" after a cancellation, which is expected
+			if ctx.Err() == nil {
+				cancel(err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DialFunc opens the remote side of a tunneled connection
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// TCPDialer dials address, over TLS when config is not nil
+func TCPDialer(address string, config *tls.Config) DialFunc {
+	return func(ctx context.Context) (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		if config != nil {
+			return (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", address)
+		}
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+}
+
+// Forwarder accepts local connections and pipes each one to a connection from dial
+type Forwarder struct {
+	dial   DialFunc
+	idle   time.Duration
+	logger *log.Logger
+	nextID atomic.Int64
+}
+
+// NewForwarder creates a forwarder closing connections idle for longer than idle
+func NewForwarder(dial DialFunc, idle time.Duration, logger *log.Logger) *Forwarder {
+	return &Forwarder{dial: dial, idle: idle, logger: logger}
+}
+
+// Serve accepts connections until ctx is cancelled, then waits for the open ones to end.
+// Cancelling ctx also cancels the pipes, so the wait is short.
+func (f *Forwarder) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.handle(ctx, conn)
+		}()
+	}
+}
+
+func (f *Forwarder) handle(ctx context.Context, client net.Conn) {
+	id := f.nextID.Add(1)
+	remote, err := f.dial(ctx)
+	if err != nil {
+		f.logger.Printf("#%d %s: dial failed: %v", id, client.RemoteAddr(), err)
+		client.Close()
+		return
+	}
+	f.logger.Printf("#%d %s -> %s opened", id, client.RemoteAddr(), remote.RemoteAddr())
+	stats := Pipe(ctx, client, remote, f.idle)
+	f.logger.Printf("#%d closed: sent %d B, received %d B in %s%s", id, stats.Sent, stats.Received,
+		stats.Duration.Round(time.Millisecond), reason(stats.Reason))
+}
+
+func reason(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%v)", err)
+}
+
+// Reverse tunnels
+//
+// A reverse tunnel exposes a service running behind a NAT or firewall: the agent, next to the
+// service, dials out to a public relay and keeps a pool of idle connections there. The relay
+// pairs each client it accepts with one of those connections, writes one byte to wake the
+// agent, and the agent then dials the service and pipes both connections.
+//
+//   client -> relay (--relay) <- agent (--reverse) -> service
+
+// wakeUp is the byte the relay sends to an agent connection when a client is paired with it
+const wakeUp = 0x01
+
+// Relay hands out the agent connections waiting in its pool
+type Relay struct {
+	agents chan net.Conn
+	logger *log.Logger
+}
+
+// NewRelay creates a relay; AcceptAgents must run to fill the pool
+func NewRelay(logger *log.Logger) *Relay {
+	return &Relay{agents: make(chan net.Conn, 64), logger: logger}
+}
+
+// AcceptAgents puts every connection accepted on listener into the pool
+func (r *Relay) AcceptAgents(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		select {
+		case r.agents <- conn:
+		default:
+			r.logger.Printf("agent pool full, rejecting %s", conn.RemoteAddr())
+			conn.Close()
+		}
+	}
+}
+
+// Dial is the DialFunc of the relay: it waits for an agent connection and wakes it up.
+// Agent connections that died while idle fail the write and the next one is tried.
+func (r *Relay) Dial(ctx context.Context) (net.Conn, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case conn := <-r.agents:
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if _, err := conn.Write([]byte{wakeUp}); err != nil {
+				conn.Close()
+				continue
+			}
+			conn.SetWriteDeadline(time.Time{})
+			return conn, nil
+		}
+	}
+}
+
+// RunAgent keeps pool connections open to the relay and pipes each woken one to target.
+// Failed connections are retried with a growing pause, up to 10 seconds.
+func RunAgent(ctx context.Context, relay DialFunc, target DialFunc, pool int, idle time.Duration, logger *log.Logger) {
+	var wg sync.WaitGroup
+	var nextID atomic.Int64
+	for range pool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backoff := 100 * time.Millisecond
+			for ctx.Err() == nil {
+				if err := agentConnection(ctx, relay, target, idle, logger, &nextID); err != nil {
+					logger.Printf("agent: %v, retrying in %s", err, backoff)
+					select {
+					case <-ctx.Done():
+					case <-time.After(backoff):
+					}
+					backoff = min(2*backoff, 10*time.Second)
+					continue
+				}
+				backoff = 100 * time.Millisecond
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// agentConnection waits on one relay connection for the wake up byte and serves one client
+func agentConnection(ctx context.Context, relay DialFunc, target DialFunc, idle time.Duration, logger *log.Logger, nextID *atomic.Int64) error {
+	conn, err := relay(ctx)
+	if err != nil {
+		return fmt.Errorf("dialing relay: %w", err)
+	}
+
+	// The read blocks until a client arrives; cancelling ctx closes the connection to unblock it
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	wake := make([]byte, 1)
+	_, err = conn.Read(wake)
+	stop()
+	if err != nil || wake[0] != wakeUp {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("waiting for a client: %v", err)
+	}
+
+	id := nextID.Add(1)
+	service, err := target(ctx)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("#%d dialing target: %w", id, err)
+	}
+	stats := Pipe(ctx, conn, service, idle)
+	logger.Printf("#%d closed: sent %d B, received %d B in %s%s", id, stats.Sent, stats.Received,
+		stats.Duration.Round(time.Millisecond), reason(stats.Reason))
+	return nil
+}
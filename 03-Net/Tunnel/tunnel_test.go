@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTunnel checks the forward and reverse tunnels against a local echo service,
+// plus half-close and idle timeout
+func TestTunnel(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service := startUpperService(t)
+
+	// Forward tunnel in front of the service
+	forwardListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewForwarder(TCPDialer(service.Addr().String(), nil), 300*time.Millisecond, logger).Serve(ctx, forwardListener)
+
+	// Reverse tunnel: relay with its agent exposing the same service
+	relay := NewRelay(logger)
+	agentListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go relay.AcceptAgents(ctx, agentListener)
+	go NewForwarder(relay.Dial, time.Second, logger).Serve(ctx, relayListener)
+	go RunAgent(ctx, TCPDialer(agentListener.Addr().String(), nil), TCPDialer(service.Addr().String(), nil), 2, time.Second, logger)
+
+	t.Run("forward", func(t *testing.T) {
+		roundTrip(t, forwardListener.Addr().String())
+	})
+	t.Run("reverse", func(t *testing.T) {
+		roundTrip(t, relayListener.Addr().String())
+	})
+	t.Run("reverse with more clients than pooled connections", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				roundTrip(t, relayListener.Addr().String())
+			}()
+		}
+		wg.Wait()
+	})
+
+	// Everything is sent and the write side closed; the whole answer must still arrive
+	t.Run("half-close keeps the answer", func(t *testing.T) {
+		conn := dialTunnel(t, forwardListener.Addr().String())
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprint(conn, "one\ntwo\nthree\n")
+		conn.(*net.TCPConn).CloseWrite()
+		answer, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(answer) != "ONE\nTWO\nTHREE\n" {
+			t.Fatalf("got %q", answer)
+		}
+	})
+
+	// The forward tunnel closes a silent connection after about 300ms
+	t.Run("idle connections are closed", func(t *testing.T) {
+		conn := dialTunnel(t, forwardListener.Addr().String())
+		start := time.Now()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Fatalf("got %v, want EOF", err)
+		}
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("closed after %s", elapsed)
+		}
+	})
+}
+
+// startUpperService answers every line in upper case
+func startUpperService(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintln(conn, strings.ToUpper(scanner.Text()))
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+// dialTunnel connects to addr and closes the connection at the end of the test
+func dialTunnel(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// roundTrip sends two lines through the tunnel and checks they come back in upper case.
+// It only uses t.Error, so it can run in the goroutines of a subtest.
+func roundTrip(t *testing.T, addr string) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	for _, word := range []string{"hello", "tunnel"} {
+		fmt.Fprintln(conn, word)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			t.Errorf("reading the answer to %q: %v", word, err)
+			return
+		}
+		if answer != strings.ToUpper(word)+"\n" {
+			t.Errorf("got %q for %q", answer, word)
+			return
+		}
+	}
+}
+
+// TestPipe pipes two in-process TCP connections and checks the counters and reasons
+func TestPipe(t *testing.T) {
+	pair := func() (net.Conn, net.Conn, error) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer listener.Close()
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return nil, nil, err
+		}
+		server, err := listener.Accept()
+		return client, server, err
+	}
+
+	// Two pipes: clientA <-> [a | b] <-> clientB
+	clientA, a, err := pair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, clientB, err := pair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statsCh := make(chan Stats)
+	go func() { statsCh <- Pipe(context.Background(), a, b, 0) }()
+
+	clientA.Write([]byte("12345"))
+	clientB.Write([]byte("abc"))
+	io.ReadFull(clientB, make([]byte, 5))
+	io.ReadFull(clientA, make([]byte, 3))
+	clientA.Close()
+	clientB.Close()
+	stats := <-statsCh
+	if stats.Sent != 5 || stats.Received != 3 || stats.Reason != nil {
+		t.Fatalf("got %+v", stats)
+	}
+
+	// Cancelling the context ends a pipe with open connections
+	clientA, a, _ = pair()
+	b, clientB, _ = pair()
+	defer clientA.Close()
+	defer clientB.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { statsCh <- Pipe(ctx, a, b, 0) }()
+	cancel()
+	select {
+	case stats = <-statsCh:
+	case <-time.After(time.Second):
+		t.Fatal("cancel did not stop the pipe")
+	}
+	if !errors.Is(stats.Reason, context.Canceled) {
+		t.Fatalf("got reason %v, want context.Canceled", stats.Reason)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// Storage of the links, following the Repository pattern of 02-DesignPatterns/Repository:
+// the service only knows the LinkRepository interface, and the in-memory implementation
+// is built on the repository.Memory of the product repositories.
+
+var (
+	// ErrNotFound is returned when no link has the requested code
+	ErrNotFound = errors.New("link not found")
+	// ErrDuplicateCode is returned by Create when the code is already taken
+	ErrDuplicateCode = errors.New("code already exists")
+)
+
+// Link is a short code pointing to a target URL
+type Link struct {
+	Code      string    `json:"code"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // Zero means the link never expires
+	Hits      int64     `json:"hits"`
+}
+
+// Expired reports whether the link can no longer be resolved at now
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// LinkRepository defines the storage operations of the shortener
+type LinkRepository interface {
+	// Create stores a new link or returns ErrDuplicateCode, never overwriting an existing one
+	Create(link Link) error
+	// GetByCode returns the link or ErrNotFound
+	GetByCode(code string) (Link, error)
+	// IncrementHits adds one to the hit counter of the link
+	IncrementHits(code string) error
+	// DeleteExpired removes the links expired at now and returns how many were removed
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// InMemoryLinkRepository stores links by code in a repository.Memory
+type InMemoryLinkRepository struct {
+	links *repository.Memory[string, Link]
+}
+
+// NewInMemoryLinkRepository creates an empty repository
+func NewInMemoryLinkRepository() *InMemoryLinkRepository {
+	return &InMemoryLinkRepository{links: repository.NewMemory[string, Link]()}
+}
+
+// Create checks and inserts under the same lock, so two links can never get the same code
+func (r *InMemoryLinkRepository) Create(link Link) error {
+	if !r.links.Insert(link.Code, link) {
+		return ErrDuplicateCode
+	}
+	return nil
+}
+
+func (r *InMemoryLinkRepository) GetByCode(code string) (Link, error) {
+	link, exists := r.links.Get(code)
+	if !exists {
+		return Link{}, ErrNotFound
+	}
+	return link, nil
+}
+
+func (r *InMemoryLinkRepository) IncrementHits(code string) error {
+	increment := func(link Link) Link {
+		link.Hits++
+		return link
+	}
+	if !r.links.Update(code, increment) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *InMemoryLinkRepository) DeleteExpired(now time.Time) (int, error) {
+	return r.links.DeleteFunc(func(link Link) bool { return link.Expired(now) }), nil
+}
@@ -0,0 +1,124 @@
+// URL shortener service, a small capstone combining several modules of the course:
+// - Repository pattern for the storage of the links (pkg/repository)
+// - Bounded cache with deduplicated loads for the redirects (pkg/cache)
+// - Middleware chain for request IDs, logging and recovery (pkg/middleware)
+// Endpoints:
+//   POST /links               {"url": "...", "ttl": "24h"} creates a link, ttl is optional
+//   GET  /{code}              redirects to the target, 404 if unknown, 410 if expired
+//   GET  /links/{code}/stats  returns the link with its hit counter
+// RUN PROGRAM WITH FLAGS
+// go run . --addr=localhost:8080 --cleanup=1m
+// curl -d '{"url":"https://go.dev","ttl":"1h"}' localhost:8080/links
+// go test .
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/httpjson"
+	"github.com/Arcanm/go_advanced_course/pkg/middleware"
+)
+
+// createRequest is the body of POST /links
+type createRequest struct {
+	URL string `json:"url"`
+	TTL string `json:"ttl"` // Go duration, e.g. "90m"; empty never expires
+}
+
+// createResponse adds the full short URL to the link
+type createResponse struct {
+	Link
+	ShortURL string `json:"short_url"`
+}
+
+// NewServer returns the API with its routes and middlewares wired
+func NewServer(shortener *Shortener, logger *log.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /links", func(w http.ResponseWriter, r *http.Request) {
+		var req createRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			httpjson.Error(w, http.StatusBadRequest, errors.New("invalid JSON body"))
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				httpjson.Error(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		link, err := shortener.Shorten(req.URL, ttl)
+		if err != nil {
+			httpjson.Error(w, errorStatuses.Of(err), err)
+			return
+		}
+		w.Header().Set("Location", "/"+link.Code)
+		httpjson.Write(w, http.StatusCreated, createResponse{Link: link, ShortURL: "http://" + r.Host + "/" + link.Code})
+	})
+	mux.HandleFunc("GET /{code}", func(w http.ResponseWriter, r *http.Request) {
+		link, err := shortener.Resolve(r.PathValue("code"))
+		if err != nil {
+			httpjson.Error(w, errorStatuses.Of(err), err)
+			return
+		}
+		http.Redirect(w, r, link.URL, http.StatusFound)
+	})
+	mux.HandleFunc("GET /links/{code}/stats", func(w http.ResponseWriter, r *http.Request) {
+		link, err := shortener.Stats(r.PathValue("code"))
+		if err != nil {
+			httpjson.Error(w, errorStatuses.Of(err), err)
+			return
+		}
+		httpjson.Write(w, http.StatusOK, link)
+	})
+	return middleware.NewChain(middleware.RequestID, middleware.Logging(logger), middleware.Recovery(logger)).Then(mux)
+}
+
+// errorStatuses maps the errors of the shortener to HTTP status codes
+var errorStatuses = httpjson.Statuses{
+	{Err: ErrNotFound, Status: http.StatusNotFound},
+	{Err: ErrExpired, Status: http.StatusGone},
+	{Err: ErrInvalidURL, Status: http.StatusBadRequest},
+}
+
+// RunCleanup removes the expired links every interval until ctx is done
+func RunCleanup(ctx context.Context, shortener *Shortener, interval time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed, err := shortener.Cleanup(); err != nil {
+				logger.Println("Cleanup error:", err)
+			} else if removed > 0 {
+				logger.Printf("Removed %d expired links", removed)
+			}
+		}
+	}
+}
+
+var (
+	addr    = flag.String("addr", "localhost:8080", "address to listen on")
+	cleanup = flag.Duration("cleanup", time.Minute, "interval between removals of expired links")
+)
+
+func main() {
+	flag.Parse()
+	logger := log.Default()
+
+	shortener := NewShortener(NewInMemoryLinkRepository())
+	go RunCleanup(context.Background(), shortener, *cleanup, logger)
+
+	logger.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, NewServer(shortener, logger)))
+}
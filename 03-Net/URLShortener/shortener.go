@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/cache"
+)
+
+var (
+	// ErrInvalidURL is returned for targets that are not absolute http(s) URLs
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrExpired is returned when resolving a link after its expiration
+	ErrExpired = errors.New("link expired")
+)
+
+const (
+	// alphabet of the codes; 62^7 is about 3.5 trillion codes
+	alphabet   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	codeLength = 7
+	// maxAttempts is how many random codes are tried before giving up
+	maxAttempts = 5
+	// The cache of the redirects keeps the most used links for a while, not every link
+	cacheEntries = 10_000
+	cacheTTL     = 10 * time.Minute
+)
+
+// Shortener creates and resolves short links
+type Shortener struct {
+	repo    LinkRepository
+	links   *cache.Memory[string, Link]
+	newCode func() (string, error) // Replaced by the verification to force collisions
+	now     func() time.Time
+}
+
+// NewShortener creates a shortener on top of a repository, with a cache for the lookups.
+// Concurrent misses for the same code wait for a single call to the repository.
+// A link never changes its URL or expiration, so cached links can't become stale;
+// only the hit counter changes, and it is always read from the repository.
+func NewShortener(repo LinkRepository) *Shortener {
+	load := func(code string, _ *cache.Memory[string, Link]) (Link, error) {
+		return repo.GetByCode(code)
+	}
+	return &Shortener{
+		repo:    repo,
+		links:   cache.NewCache(load, cache.WithMaxEntries(cacheEntries), cache.WithTTL(cacheTTL)),
+		newCode: randomCode,
+		now:     time.Now,
+	}
+}
+
+// Close stops the janitor of the cache
+func (s *Shortener) Close() error {
+	return s.links.Close()
+}
+
+// randomCode returns codeLength characters chosen with crypto/rand.
+// rand.Int has no modulo bias, so every character of the alphabet is equally likely.
+func randomCode() (string, error) {
+	code := make([]byte, codeLength)
+	limit := big.NewInt(int64(len(alphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// Shorten creates a link to rawURL; a ttl of 0 creates a link that never expires.
+// Random codes may collide with existing ones: the repository refuses duplicates
+// atomically and a new code is tried, so an existing link is never overwritten.
+func (s *Shortener) Shorten(rawURL string, ttl time.Duration) (Link, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Link{}, fmt.Errorf("%w: %q", ErrInvalidURL, rawURL)
+	}
+	if ttl < 0 {
+		return Link{}, fmt.Errorf("ttl can't be negative")
+	}
+
+	now := s.now()
+	link := Link{URL: target.String(), CreatedAt: now}
+	if ttl > 0 {
+		link.ExpiresAt = now.Add(ttl)
+	}
+
+	for range maxAttempts {
+		link.Code, err = s.newCode()
+		if err != nil {
+			return Link{}, err
+		}
+		err = s.repo.Create(link)
+		if !errors.Is(err, ErrDuplicateCode) {
+			return link, err
+		}
+	}
+	return Link{}, fmt.Errorf("no free code after %d attempts: %w", maxAttempts, err)
+}
+
+// Resolve returns the link for a code and counts the hit
+func (s *Shortener) Resolve(code string) (Link, error) {
+	link, err := s.links.Get(code)
+	if err != nil {
+		return Link{}, err
+	}
+	if link.Expired(s.now()) {
+		return Link{}, ErrExpired
+	}
+	if err := s.repo.IncrementHits(code); err != nil {
+		return Link{}, err
+	}
+	return link, nil
+}
+
+// Stats returns the link with its current hit counter, including expired links
+// that were not cleaned up yet
+func (s *Shortener) Stats(code string) (Link, error) {
+	return s.repo.GetByCode(code)
+}
+
+// Cleanup removes the expired links from the repository, then from the cache the codes
+// the repository no longer has
+func (s *Shortener) Cleanup() (int, error) {
+	removed, err := s.repo.DeleteExpired(s.now())
+	if err != nil {
+		return removed, err
+	}
+	for _, code := range s.links.Keys() {
+		if _, err := s.repo.GetByCode(code); errors.Is(err, ErrNotFound) {
+			s.links.Delete(code)
+		}
+	}
+	return removed, nil
+}
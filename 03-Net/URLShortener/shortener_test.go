@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestShortener checks the service through an httptest server. The steps run in
+// order, on the same links. The clock is fake, so expiration is tested without waiting.
+func TestShortener(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	shortener := NewShortener(NewInMemoryLinkRepository())
+	defer shortener.Close()
+	shortener.now = func() time.Time { return now }
+
+	server := httptest.NewServer(NewServer(shortener, log.New(io.Discard, "", 0)))
+	defer server.Close()
+	// Redirects are checked, not followed
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	var permanent, temporary createResponse
+	steps := []struct {
+		name  string
+		check func() error
+	}{
+		{"invalid URL is rejected", func() error {
+			return expectStatus(client, "POST", server.URL+"/links", `{"url":"ftp://files"}`, http.StatusBadRequest, nil)
+		}},
+		{"invalid ttl is rejected", func() error {
+			return expectStatus(client, "POST", server.URL+"/links", `{"url":"https://go.dev","ttl":"soon"}`, http.StatusBadRequest, nil)
+		}},
+		{"create permanent link", func() error {
+			return expectStatus(client, "POST", server.URL+"/links", `{"url":"https://go.dev/doc"}`, http.StatusCreated, &permanent)
+		}},
+		{"create link with ttl", func() error {
+			return expectStatus(client, "POST", server.URL+"/links", `{"url":"https://pkg.go.dev","ttl":"1h"}`, http.StatusCreated, &temporary)
+		}},
+		{"redirect", func() error {
+			resp, err := client.Get(server.URL + "/" + permanent.Code)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://go.dev/doc" {
+				return fmt.Errorf("got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+			}
+			return nil
+		}},
+		{"hits are counted", func() error {
+			client.Get(server.URL + "/" + permanent.Code)
+			var stats Link
+			if err := expectStatus(client, "GET", server.URL+"/links/"+permanent.Code+"/stats", "", http.StatusOK, &stats); err != nil {
+				return err
+			}
+			if stats.Hits != 2 {
+				return fmt.Errorf("got %d hits, want 2", stats.Hits)
+			}
+			return nil
+		}},
+		{"unknown code", func() error {
+			return expectStatus(client, "GET", server.URL+"/nope", "", http.StatusNotFound, nil)
+		}},
+		{"expired link", func() error {
+			now = now.Add(2 * time.Hour)
+			return expectStatus(client, "GET", server.URL+"/"+temporary.Code, "", http.StatusGone, nil)
+		}},
+		{"cleanup removes expired links only", func() error {
+			removed, err := shortener.Cleanup()
+			if err != nil || removed != 1 {
+				return fmt.Errorf("removed %d links (err: %v), want 1", removed, err)
+			}
+			if err := expectStatus(client, "GET", server.URL+"/"+temporary.Code, "", http.StatusNotFound, nil); err != nil {
+				return err
+			}
+			return expectStatus(client, "GET", server.URL+"/"+permanent.Code, "", http.StatusFound, nil)
+		}},
+	}
+
+	for _, step := range steps {
+		if !t.Run(step.name, func(t *testing.T) {
+			if err := step.check(); err != nil {
+				t.Fatal(err)
+			}
+		}) {
+			return
+		}
+	}
+}
+
+// expectStatus sends a request and decodes the JSON answer into out when it is not nil
+func expectStatus(client *http.Client, method, url, body string, want int, out any) error {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("got status %d, want %d (body %s)", resp.StatusCode, want, data)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// TestCollisions replaces the code generator to return taken codes on purpose
+func TestCollisions(t *testing.T) {
+	shortener := NewShortener(NewInMemoryLinkRepository())
+	defer shortener.Close()
+	codes := []string{"aaaaaaa", "aaaaaaa", "bbbbbbb"}
+	shortener.newCode = func() (string, error) {
+		code := codes[0]
+		if len(codes) > 1 {
+			codes = codes[1:]
+		}
+		return code, nil
+	}
+
+	first, err := shortener.Shorten("https://first.example", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := shortener.Shorten("https://second.example", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Code != "aaaaaaa" || second.Code != "bbbbbbb" {
+		t.Fatalf("got codes %s and %s", first.Code, second.Code)
+	}
+	// The generator is stuck on a taken code: give up instead of overwriting
+	if _, err := shortener.Shorten("https://third.example", 0); !errors.Is(err, ErrDuplicateCode) {
+		t.Fatalf("got %v, want ErrDuplicateCode", err)
+	}
+	if link, _ := shortener.Stats("bbbbbbb"); link.URL != "https://second.example" {
+		t.Fatalf("link bbbbbbb was overwritten with %s", link.URL)
+	}
+}
+
+// TestConcurrentCodes checks that concurrent creations get unique codes
+func TestConcurrentCodes(t *testing.T) {
+	shortener := NewShortener(NewInMemoryLinkRepository())
+	defer shortener.Close()
+	codes := make(chan string, 200)
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := shortener.Shorten(fmt.Sprintf("https://example.com/%d", i), 0)
+			if err == nil {
+				codes <- link.Code
+			}
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	seen := make(map[string]bool)
+	for code := range codes {
+		if seen[code] {
+			t.Fatalf("code %s was assigned twice", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != 200 {
+		t.Fatalf("got %d links, want 200", len(seen))
+	}
+}
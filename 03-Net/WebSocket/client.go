@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// RunClient sends every line read from stdin and prints every message received.
+// The default ping handler of gorilla/websocket answers the server pings with a pong.
+func RunClient(url string) error {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Println("Connected to", url)
+
+	// Print the incoming messages until the connection closes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				fmt.Println("Connection closed:", err)
+				return
+			}
+			fmt.Println("<", string(message))
+		}
+	}()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				// Stdin closed: say goodbye and wait for the server to close the connection
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+				}
+				return nil
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the timeouts of the keepalive; zero fields take the defaults
+type Config struct {
+	WriteWait  time.Duration // Time allowed to write a message to the peer, default 10s
+	PongWait   time.Duration // Time allowed to read the next pong from the peer, default 60s
+	PingPeriod time.Duration // Pings are sent with this period, default 9/10 of PongWait
+}
+
+// withDefaults fills the zero fields; PingPeriod must stay shorter than PongWait
+func (c Config) withDefaults() Config {
+	if c.WriteWait <= 0 {
+		c.WriteWait = 10 * time.Second
+	}
+	if c.PongWait <= 0 {
+		c.PongWait = 60 * time.Second
+	}
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		c.PingPeriod = c.PongWait * 9 / 10
+	}
+	return c
+}
+
+const (
+	// Maximum size of a message read from the peer
+	maxMessageSize = 4096
+	// Messages waiting to be written before the connection is considered too slow
+	sendBuffer = 16
+)
+
+// Connection is a WebSocket connection with its own outgoing queue.
+// gorilla/websocket allows one concurrent reader and one concurrent writer, so every
+// connection has a read pump and a write pump goroutine, and only the write pump writes.
+type Connection struct {
+	hub    *Hub // nil for echo connections
+	conn   *websocket.Conn
+	send   chan []byte
+	config Config
+}
+
+// NewConnection wraps an upgraded connection; hub is nil for echo connections
+func NewConnection(hub *Hub, conn *websocket.Conn, config Config) *Connection {
+	return &Connection{hub: hub, conn: conn, send: make(chan []byte, sendBuffer), config: config.withDefaults()}
+}
+
+// Serve registers the connection and starts both pumps
+func (c *Connection) Serve() {
+	if c.hub != nil {
+		c.hub.register <- c
+	}
+	go c.writePump()
+	go c.readPump()
+}
+
+// readPump reads messages until the peer leaves or stops answering the pings.
+// Every pong extends the read deadline; without pongs the read fails after PongWait.
+func (c *Connection) readPump() {
+	defer func() {
+		if c.hub != nil {
+			c.hub.unregister <- c
+		} else {
+			close(c.send)
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("%s: read error: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if c.hub != nil {
+			c.hub.broadcast <- message
+			continue
+		}
+		// Echo: a full queue means the peer doesn't read its answers
+		select {
+		case c.send <- message:
+		default:
+			log.Printf("%s: echo queue full, closing", c.conn.RemoteAddr())
+			return
+		}
+	}
+}
+
+// writePump sends the queued messages and a ping every PingPeriod.
+// It ends when the send channel is closed or a write fails.
+func (c *Connection) writePump() {
+	ticker := time.NewTicker(c.config.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+			if !ok {
+				// The hub or the read pump closed the channel
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
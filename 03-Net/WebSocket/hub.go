@@ -0,0 +1,46 @@
+package main
+
+// Hub keeps the set of connections joined to the broadcast endpoint and sends every
+// message to all of them. Like the chat server in NetCAT, a single goroutine owns the
+// state and the connections talk to it through channels, so no mutex is needed.
+type Hub struct {
+	clients    map[*Connection]bool
+	register   chan *Connection
+	unregister chan *Connection
+	broadcast  chan []byte
+}
+
+// NewHub creates a hub, Run must be started in its own goroutine
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[*Connection]bool),
+		register:   make(chan *Connection),
+		unregister: make(chan *Connection),
+		broadcast:  make(chan []byte),
+	}
+}
+
+// Run processes registrations and messages until the program ends
+func (h *Hub) Run() {
+	for {
+		select {
+		case c := <-h.register:
+			h.clients[c] = true
+		case c := <-h.unregister:
+			if h.clients[c] {
+				delete(h.clients, c)
+				close(c.send)
+			}
+		case message := <-h.broadcast:
+			for c := range h.clients {
+				select {
+				case c.send <- message:
+				default:
+					// The connection can't keep up: drop it instead of blocking everybody
+					delete(h.clients, c)
+					close(c.send)
+				}
+			}
+		}
+	}
+}
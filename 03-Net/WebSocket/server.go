@@ -0,0 +1,77 @@
+// WebSocket server with two endpoints:
+// - /echo sends every message back to its sender
+// - /broadcast sends every message to all the connected clients through a Hub
+// Connections are kept alive with ping/pong: the server pings every PingPeriod and closes
+// the connections that don't answer within PongWait (see Config). Unlike the NetCAT chat,
+// which speaks framed JSON over plain TCP, this server can be used from a browser.
+// RUN PROGRAM WITH FLAGS
+// go run . --mode=server --addr=localhost:8080
+// go run . --mode=client --url=ws://localhost:8080/broadcast
+// go test .
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgrader turns HTTP requests into WebSocket connections.
+// CheckOrigin accepts every origin to keep the example simple; a real service
+// should only accept its own pages.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// NewServer returns the handler with both endpoints; every broadcast client joins hub,
+// and every connection uses the keepalive timeouts of config
+func NewServer(hub *Hub, config Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		serveWebSocket(nil, config, w, r)
+	})
+	mux.HandleFunc("/broadcast", func(w http.ResponseWriter, r *http.Request) {
+		serveWebSocket(hub, config, w, r)
+	})
+	return mux
+}
+
+// serveWebSocket upgrades the request and starts the connection pumps
+func serveWebSocket(hub *Hub, config Config, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already answered the request with an HTTP error
+		log.Println("Upgrade error:", err)
+		return
+	}
+	NewConnection(hub, conn, config).Serve()
+}
+
+var (
+	mode = flag.String("mode", "server", "server or client")
+	addr = flag.String("addr", "localhost:8080", "address the server listens on")
+	url  = flag.String("url", "ws://localhost:8080/echo", "client: endpoint to connect to")
+)
+
+func main() {
+	flag.Parse()
+
+	switch *mode {
+	case "server":
+		hub := NewHub()
+		go hub.Run()
+		log.Printf("Listening on %s", *addr)
+		log.Fatal(http.ListenAndServe(*addr, NewServer(hub, Config{})))
+	case "client":
+		if err := RunClient(*url); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown mode %q, use server or client", *mode)
+	}
+}
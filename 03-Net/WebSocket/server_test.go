@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testConfig shortens the keepalive so the ping checks don't take a minute
+var testConfig = Config{PongWait: 300 * time.Millisecond, PingPeriod: 100 * time.Millisecond}
+
+// TestServer checks echo, broadcast and keepalive against an httptest server
+func TestServer(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(NewServer(hub, testConfig))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	t.Run("echo", func(t *testing.T) {
+		conn := dial(t, wsURL+"/echo")
+		for _, text := range []string{"hello", "world"} {
+			write(t, conn, text)
+			expect(t, conn, text)
+		}
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		clients := make([]*websocket.Conn, 3)
+		for i := range clients {
+			clients[i] = dial(t, wsURL+"/broadcast")
+		}
+		// Registration is asynchronous, give the hub a moment to see every client
+		time.Sleep(50 * time.Millisecond)
+
+		write(t, clients[0], "hi all")
+		// The sender receives its own message too
+		for _, conn := range clients {
+			expect(t, conn, "hi all")
+		}
+	})
+
+	t.Run("ping keeps the connection alive", func(t *testing.T) {
+		conn := dial(t, wsURL+"/echo")
+		var pings atomic.Int32
+		conn.SetPingHandler(func(data string) error {
+			pings.Add(1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+
+		// Control messages are only processed while reading, so send and read an echo
+		// periodically, for twice the time the server waits for a pong
+		for i := range 6 {
+			text := fmt.Sprintf("message %d", i)
+			write(t, conn, text)
+			expect(t, conn, text)
+			time.Sleep(testConfig.PingPeriod)
+		}
+		if got := pings.Load(); got < 3 {
+			t.Fatalf("got %d pings, want at least 3", got)
+		}
+	})
+
+	t.Run("missing pongs close the connection", func(t *testing.T) {
+		conn := dial(t, wsURL+"/echo")
+		conn.SetPingHandler(func(string) error { return nil })
+
+		conn.SetReadDeadline(time.Now().Add(3 * testConfig.PongWait))
+		for {
+			_, _, err := conn.ReadMessage()
+			if err == nil {
+				continue
+			}
+			// The server sends a close frame or drops the TCP connection, either is fine;
+			// only reaching our own deadline means the connection was kept open
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatalf("the server kept the connection open for %s", 3*testConfig.PongWait)
+			}
+			return
+		}
+	})
+}
+
+// TestConfigDefaults checks the timeouts filled for the zero fields
+func TestConfigDefaults(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		want   Config
+	}{
+		{name: "zero", want: Config{WriteWait: 10 * time.Second, PongWait: time.Minute, PingPeriod: 54 * time.Second}},
+		{name: "ping period follows pong wait", config: Config{PongWait: time.Second}, want: Config{WriteWait: 10 * time.Second, PongWait: time.Second, PingPeriod: 900 * time.Millisecond}},
+		{name: "ping period longer than pong wait", config: Config{PongWait: time.Second, PingPeriod: 2 * time.Second}, want: Config{WriteWait: 10 * time.Second, PongWait: time.Second, PingPeriod: 900 * time.Millisecond}},
+		{name: "explicit", config: testConfig, want: Config{WriteWait: 10 * time.Second, PongWait: 300 * time.Millisecond, PingPeriod: 100 * time.Millisecond}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.config.withDefaults(); got != c.want {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+// dial connects to url and closes the connection at the end of the test
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// write sends a text message
+func write(t *testing.T, conn *websocket.Conn, text string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(text)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect reads one message and compares it with want
+func expect(t *testing.T, conn *websocket.Conn, want string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message) != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
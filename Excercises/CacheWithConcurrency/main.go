@@ -6,40 +6,47 @@ import (
 	"time"
 )
 
-func ExpensiveFibonacci(n int) int {
+func ExpensiveFibonacci(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("fibonacci of negative position %d", n)
+	}
 	fmt.Printf("Calculating expensive fibonacci for %d\n", n)
 	time.Sleep(5 * time.Second)
-	return n
+	return n, nil
+}
+
+// result is what a calculation sends to the workers waiting for it
+type result struct {
+	value int
+	err   error
 }
 
 type Service struct {
 	InProgress map[int]bool
-	IsPending  map[int][]chan int
+	IsPending  map[int][]chan result
 	Cache      map[int]int
 	Lock       sync.Mutex
 }
 
-func (s *Service) Work(job int) {
+// Work returns the result of job, calculated once: the workers asking for a job in
+// progress wait for its result. Errors are not cached, the next call tries again
+func (s *Service) Work(job int) (int, error) {
 	s.Lock.Lock()
 
 	// Check cache first
-	if result, exists := s.Cache[job]; exists {
-		fmt.Printf("Found in cache! Job %d: %d\n", job, result)
-		fmt.Printf("Job %d completed with cached result %d\n", job, result)
+	if value, exists := s.Cache[job]; exists {
 		s.Lock.Unlock()
-		return
+		return value, nil
 	}
 
 	// If job is in progress, wait for result
 	if s.InProgress[job] {
-		response := make(chan int)
+		response := make(chan result)
 		s.IsPending[job] = append(s.IsPending[job], response)
 		s.Lock.Unlock()
 
-		fmt.Printf("Waiting for response for job %d\n", job)
 		resp := <-response
-		fmt.Printf("Job %d finished with result %d\n", job, resp)
-		return
+		return resp.value, resp.err
 	}
 
 	// Mark job as in progress
@@ -47,31 +54,29 @@ func (s *Service) Work(job int) {
 	s.Lock.Unlock()
 
 	// Calculate result
-	fmt.Printf("Calculating fibonacci for %d\n", job)
-	result := ExpensiveFibonacci(job)
+	value, err := ExpensiveFibonacci(job)
 
 	// Update cache and notify pending workers
 	s.Lock.Lock()
-	s.Cache[job] = result
+	if err == nil {
+		s.Cache[job] = value
+	}
 	s.InProgress[job] = false
 
 	// Notify pending workers
-	if pendingWorkers, exists := s.IsPending[job]; exists {
-		for _, response := range pendingWorkers {
-			response <- result
-		}
-		fmt.Printf("Result %d sent to %d workers\n", result, len(pendingWorkers))
-		delete(s.IsPending, job)
+	for _, response := range s.IsPending[job] {
+		response <- result{value, err}
 	}
+	delete(s.IsPending, job)
 	s.Lock.Unlock()
 
-	fmt.Printf("Job %d finished with result %d\n", job, result)
+	return value, err
 }
 
 func NewService() *Service {
 	return &Service{
 		InProgress: make(map[int]bool),
-		IsPending:  make(map[int][]chan int),
+		IsPending:  make(map[int][]chan result),
 		Cache:      make(map[int]int),
 	}
 }
@@ -82,27 +87,34 @@ func main() {
 		43, 40, 46, 43, 45, 49, 34, 47, 36, 43, 48, 41,
 		35, 46, 33, 42, 49, 47, 32, 30, 50, 50, 30, 40,
 		44, 30, 49, 34, 48, 43, 50, 42, 48, 31, 35, 30,
-		33, 40, 40, 50, 49, 47, 36, 43, 48, 41, 35, 46,
-	}
-	var wg sync.WaitGroup
-	wg.Add(len(jobs))
-
-	for _, job := range jobs {
-		go func(job int) {
-			defer wg.Done()
-			service.Work(job)
-		}(job)
+		33, 40, 40, 50, 49, 47, 36, 43, 48, 41, 35, 46, -1,
 	}
-
-	wg.Wait()
-	wg.Add(len(jobs))
-
-	for _, job := range jobs {
-		go func(job int) {
-			defer wg.Done()
-			service.Work(job)
-		}(job)
+	// The second round finds every result in the cache except the one of -1: errors are
+	// not cached, so that job runs and fails again
+	for round := 1; round <= 2; round++ {
+		start := time.Now()
+		results := make([]int, len(jobs))
+		errs := make([]error, len(jobs))
+		var wg sync.WaitGroup
+		wg.Add(len(jobs))
+		for i, job := range jobs {
+			go func(i, job int) {
+				defer wg.Done()
+				results[i], errs[i] = service.Work(job)
+			}(i, job)
+		}
+		wg.Wait()
+
+		sum, failed := 0, 0
+		for i, err := range errs {
+			if err != nil {
+				fmt.Printf("Job %d failed: %v\n", jobs[i], err)
+				failed++
+				continue
+			}
+			sum += results[i]
+		}
+		fmt.Printf("Round %d: %d jobs, %d failed, sum of the results %d, in %s\n",
+			round, len(jobs), failed, sum, time.Since(start).Round(time.Millisecond))
 	}
-
-	wg.Wait()
 }
@@ -0,0 +1,206 @@
+// Package account is the bank account of 01-Concurrency/RaceConditions-Mutex: an Account
+// keeps its balance and the mutex protecting it together, instead of a global balance, so
+// it can be used from many goroutines and tested on its own. Around it are the amounts
+// (Money), the history, transfers and transactions, the Bank holding many accounts, and
+// the atomic, actor and optimistic versions the benchmarks of the module compare.
+package account
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+var (
+	// ErrInsufficientFunds is returned by Withdraw and Transfer when the amount is above the balance
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrSameAccount is returned by Transfer when both sides are the same account
+	ErrSameAccount = errors.New("transfer to the same account")
+	// ErrInvalidAmount is returned for an amount of zero or less: a negative deposit
+	// would be a withdrawal without the check of the funds
+	ErrInvalidAmount = fmt.Errorf("%w: the amount must be positive", ErrInvalidMoney)
+)
+
+// lastID is the last account ID handed out, see Account.ID
+var lastID atomic.Uint64
+
+// Account is a bank account that is safe to use from several goroutines
+// The balance and the mutex protecting it live together in the struct, instead of a global
+// variable and a mutex passed to every function: nobody can touch the balance without the lock
+// The zero value is an empty account ready to use, like sync.Mutex itself: its first
+// deposit gives it its currency, see Money
+type Account struct {
+	id      atomic.Uint64 // Zero until the first call to ID
+	balance Money
+	initial Money       // The balance before the history, see BalanceAt
+	history []Operation // Every change of the balance, see history.go
+	log     io.Writer   // Optional copy of the history, see LogTo
+	logErr  error
+	funds   *sync.Cond  // Signaled when the balance grows, see WithdrawWait
+	clock   clock.Clock // The time of the history and the interest, the system time when nil
+
+	overdraft Money     // How far below zero the balance may go, see overdraft.go
+	fees      FeePolicy // The fee of an overdrawn withdrawal, none when nil
+
+	subscriptions []observer.Subscription[AccountEvent] // The observers, see events.go
+	dispatcher    *observer.Dispatcher[AccountEvent]
+	events        []AccountEvent // Produced by the operation holding the lock, for unlock
+	threshold     *Money         // The low balance threshold, none when nil
+	low           bool           // The balance is below the threshold
+	// RWMutex allows multiple readers but only one writer at a time
+	// It protects every field but id
+	mux sync.RWMutex
+}
+
+// NewAccount creates an account with an initial balance, in the currency of the account
+func NewAccount(initial Money) *Account {
+	return &Account{balance: initial, initial: initial}
+}
+
+// ID returns the unique ID of the account, given on the first call
+// CompareAndSwap makes sure two goroutines asking at once agree on the same ID
+func (a *Account) ID() uint64 {
+	if id := a.id.Load(); id != 0 {
+		return id
+	}
+	a.id.CompareAndSwap(0, lastID.Add(1))
+	return a.id.Load()
+}
+
+// UseClock replaces the source of time of the account, see pkg/clock. The tests use a
+// clock.Fake to pay a month of interest without sleeping.
+func (a *Account) UseClock(source clock.Clock) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.clock = source
+}
+
+// now returns the time of the clock of the account; the caller holds the lock
+func (a *Account) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+	return a.clock.Now()
+}
+
+// Deposit adds the given amount to the balance, or returns ErrInvalidAmount or
+// ErrCurrencyMismatch
+// Lock() is used for write operations, blocking all other read and write operations
+func (a *Account) Deposit(amount Money) error {
+	a.mux.Lock()
+	// defer releases the lock even if the function panics or returns early
+	// unlock also delivers the events of the operation to the observers, see events.go
+	defer a.unlock()
+	return a.deposit(amount)
+}
+
+// deposit is Deposit with the write lock held
+func (a *Account) deposit(amount Money) error {
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	balance, err := a.balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	a.balance = balance
+	a.record(DepositOperation, amount)
+	a.fundsAdded()
+	return nil
+}
+
+// Withdraw subtracts the given amount from the balance, or returns ErrInsufficientFunds,
+// ErrInvalidAmount or ErrCurrencyMismatch; with an overdraft, see overdraft.go, the balance can go below
+// zero and the withdrawal can be charged a fee
+// Similar to Deposit, it requires exclusive write access using Lock()
+// The check and the subtraction happen under the same lock: checking Balance() first and
+// withdrawing after would let two goroutines both see enough funds and both withdraw them
+func (a *Account) Withdraw(amount Money) error {
+	a.mux.Lock()
+	defer a.unlock()
+	return a.withdraw(amount)
+}
+
+// withdraw is Withdraw with the write lock held
+func (a *Account) withdraw(amount Money) error {
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	after, fee, err := a.debit(a.balance, amount)
+	if err != nil {
+		return err
+	}
+	a.applyDebit(WithdrawOperation, amount, after, fee)
+	return nil
+}
+
+// checkAmount returns ErrInvalidAmount for an amount of zero or less
+func checkAmount(amount Money) error {
+	if amount.IsZero() || amount.IsNegative() {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// Balance returns the current balance
+// It uses RLock() (Read Lock) since it only needs read access
+// Multiple goroutines can read the balance simultaneously using RLock()
+// However, if any goroutine has a write lock, read operations will be blocked
+func (a *Account) Balance() Money {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return a.balance
+}
+
+// Transfer moves amount from one account to the other, or returns ErrInsufficientFunds,
+// ErrInvalidAmount or ErrCurrencyMismatch
+// It holds both locks, so no goroutine can see the money in neither or in both accounts
+// Locking from then to would deadlock: a transfer from A to B holding A and waiting for B,
+// while a transfer from B to A holds B and waits for A. Every transfer locks the account
+// with the lowest ID first instead, so the waits can't form a cycle
+func Transfer(from, to *Account, amount Money) error {
+	return transfer(from, to, amount, nil)
+}
+
+// transfer is Transfer; applied, when not nil, is called after the transfer with both
+// locks still held and the overdraft fee charged to from, zero if none
+func transfer(from, to *Account, amount Money, applied func(fee Money)) error {
+	if from == to {
+		return ErrSameAccount
+	}
+	// A negative amount would move the money the other way without checking the funds
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	first, second := from, to
+	if second.ID() < first.ID() {
+		first, second = second, first
+	}
+	first.mux.Lock()
+	second.mux.Lock()
+	defer unlockAll(first, second)
+
+	// Both new balances are computed before changing any, so an error leaves both as they were
+	fromBalance, fee, err := from.debit(from.balance, amount)
+	if err != nil {
+		return err
+	}
+	toBalance, err := to.balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	from.applyDebit(TransferOutOperation, amount, fromBalance, fee)
+	to.balance = toBalance
+	to.record(TransferInOperation, amount)
+	to.fundsAdded()
+	if applied != nil {
+		applied(fee)
+	}
+	return nil
+}
@@ -0,0 +1,1029 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+	"github.com/Arcanm/go_advanced_course/pkg/notify"
+)
+
+// usd returns an amount of cents, for short checks
+func usd(cents int) Money {
+	return NewMoney(int64(cents), "USD")
+}
+
+// TestDepositWithdraw runs 1000 deposits and 1000 withdrawals of the same amount at
+// once on an account that can cover all the withdrawals: the balance must not move
+func TestDepositWithdraw(t *testing.T) {
+	account := NewAccount(usd(10_000))
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for range 1000 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.Deposit(usd(10))
+		}()
+		go func() {
+			defer wg.Done()
+			if account.Withdraw(usd(10)) != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if failed.Load() != 0 {
+		t.Fatalf("%d withdrawals failed", failed.Load())
+	}
+	if balance := account.Balance(); balance != usd(10_000) {
+		t.Errorf("balance %s, want 100.00 USD", balance)
+	}
+}
+
+// TestInvalidAmount checks that zero and negative amounts are refused with
+// ErrInvalidAmount and change nothing: Deposit(-50) would otherwise be a withdrawal
+// without the check of the funds
+func TestInvalidAmount(t *testing.T) {
+	account := NewAccount(usd(10_00))
+	for _, amount := range []Money{usd(0), usd(-50_00)} {
+		if err := account.Deposit(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("Deposit(%s) returned %v", amount, err)
+		}
+		if err := account.Withdraw(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("Withdraw(%s) returned %v", amount, err)
+		}
+		if err := account.Begin().Deposit(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("Tx.Deposit(%s) returned %v", amount, err)
+		}
+	}
+	if account.Balance() != usd(10_00) || len(account.History()) != 0 {
+		t.Errorf("balance %s with %d operations, want 10.00 USD and none",
+			account.Balance(), len(account.History()))
+	}
+}
+
+// TestOverdraft starts 1000 withdrawals of 1 from an account of 100: exactly 100 must
+// succeed, the others get ErrInsufficientFunds, and the balance never goes below 0
+func TestOverdraft(t *testing.T) {
+	account := NewAccount(usd(100))
+	var wg sync.WaitGroup
+	var succeeded, refused atomic.Int32
+	for range 1000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := account.Withdraw(usd(1))
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, ErrInsufficientFunds):
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded.Load() != 100 || refused.Load() != 900 {
+		t.Fatalf("%d withdrawals succeeded and %d refused, want 100 and 900",
+			succeeded.Load(), refused.Load())
+	}
+	if balance := account.Balance(); balance != usd(0) {
+		t.Errorf("balance %s, want 0", balance)
+	}
+}
+
+// TestTransfer refuses a transfer to the same account, above the balance or of a
+// negative amount, then moves money back and forth between two accounts from many goroutines
+// at once. With a lock ordering by arrival the transfers in opposite directions would
+// deadlock, so the check fails after a timeout instead of hanging. The total must be kept.
+func TestTransfer(t *testing.T) {
+	a, b := NewAccount(usd(1000)), NewAccount(usd(1000))
+	if err := Transfer(a, a, usd(1)); !errors.Is(err, ErrSameAccount) {
+		t.Fatalf("to the same account returned %v", err)
+	}
+	if err := Transfer(a, b, usd(5000)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("above the balance returned %v", err)
+	}
+	// A negative transfer would move the money from b to a without checking the funds of b
+	if err := Transfer(a, b, usd(-500)); !errors.Is(err, ErrInvalidAmount) || b.Balance() != usd(1000) {
+		t.Fatalf("of a negative amount returned %v, balance %s", err, b.Balance())
+	}
+
+	var wg sync.WaitGroup
+	for i := range 1000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				Transfer(a, b, usd(i%7))
+			} else {
+				Transfer(b, a, usd(i%5))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock, the transfers did not finish")
+	}
+	if total, _ := a.Balance().Add(b.Balance()); total != usd(2000) {
+		t.Errorf("total %s, want 20.00 USD", total)
+	}
+}
+
+// TestAtomicAccount runs the overdraft check on AtomicAccount: the CompareAndSwap loop
+// must give the same guarantee as the mutex
+func TestAtomicAccount(t *testing.T) {
+	account := NewAtomicAccount(usd(100))
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range 1000 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if account.Withdraw(usd(1)) == nil {
+				succeeded.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			account.Balance()
+		}()
+	}
+	wg.Wait()
+	if succeeded.Load() != 100 || account.Balance() != usd(0) {
+		t.Fatalf("%d withdrawals succeeded, balance %s, want 100 and 0",
+			succeeded.Load(), account.Balance())
+	}
+	account.Deposit(usd(50))
+	if account.Balance() != usd(50) {
+		t.Fatalf("balance %s after a deposit of 0.50 USD", account.Balance())
+	}
+	if err := account.Deposit(NewMoney(50, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("deposit of euros returned %v", err)
+	}
+
+	// Withdraw(-1) would credit the account without any check
+	for _, amount := range []Money{usd(0), usd(-100)} {
+		if err := account.Deposit(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Deposit(%s) returned %v", amount, err)
+		}
+		if err := account.Withdraw(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Withdraw(%s) returned %v", amount, err)
+		}
+	}
+	if account.Balance() != usd(50) {
+		t.Errorf("balance %s after the invalid amounts, want 0.50 USD", account.Balance())
+	}
+}
+
+// TestActorAccount runs deposits, withdrawals and reads from many goroutines, then
+// closes the account while more deposits are sent: every deposit that didn't get
+// ErrAccountClosed must be in the final balance
+func TestActorAccount(t *testing.T) {
+	account := NewActorAccount(usd(1000))
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for range 500 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			account.Deposit(usd(1))
+		}()
+		go func() {
+			defer wg.Done()
+			if errors.Is(account.Withdraw(usd(1)), ErrInsufficientFunds) {
+				refused.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			account.Balance()
+		}()
+	}
+	wg.Wait()
+	if balance, _ := account.Balance(); balance != usd(1000) || refused.Load() != 0 {
+		t.Fatalf("balance %s with %d refused withdrawals, want 10.00 USD and 0",
+			balance, refused.Load())
+	}
+
+	for _, amount := range []Money{usd(0), usd(-100)} {
+		if err := account.Deposit(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Deposit(%s) returned %v", amount, err)
+		}
+		if err := account.Withdraw(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Withdraw(%s) returned %v", amount, err)
+		}
+	}
+	if balance, _ := account.Balance(); balance != usd(1000) {
+		t.Fatalf("balance %s after the invalid amounts, want 10.00 USD", balance)
+	}
+
+	var accepted atomic.Int32
+	for range 500 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if account.Deposit(usd(1)) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	final, err := account.Close()
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	if want := usd(1000 + int(accepted.Load())); final != want {
+		t.Fatalf("final balance %s, want %s", final, want)
+	}
+	if err := account.Deposit(usd(1)); !errors.Is(err, ErrAccountClosed) {
+		t.Fatalf("Deposit after Close returned %v", err)
+	}
+	if _, err := account.Close(); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("second Close returned %v", err)
+	}
+}
+
+// TestHistory records concurrent deposits and withdrawals: the history must have one
+// entry per deposit and accepted withdrawal, each Balance the previous one plus or minus
+// the amount, and the log the same entries in the same order
+func TestHistory(t *testing.T) {
+	account := NewAccount(usd(0))
+	var log bytes.Buffer
+	account.LogTo(&log)
+	var wg sync.WaitGroup
+	var withdrawals atomic.Int32
+	for i := range 200 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.Deposit(usd(i + 1))
+		}()
+		go func() {
+			defer wg.Done()
+			if account.Withdraw(usd(i+1)) == nil {
+				withdrawals.Add(1)
+			}
+		}()
+		if i%10 == 0 {
+			// Readers while the history grows, for the race detector
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				account.History()
+			}()
+		}
+	}
+	wg.Wait()
+
+	history := account.History()
+	balance := usd(0)
+	for i, operation := range history {
+		switch operation.Kind {
+		case DepositOperation:
+			balance, _ = balance.Add(operation.Amount)
+		case WithdrawOperation:
+			balance, _ = balance.Sub(operation.Amount)
+		}
+		if operation.Balance != balance {
+			t.Fatalf("entry %d has balance %s, want %s", i, operation.Balance, balance)
+		}
+		if i > 0 && operation.Time.Before(history[i-1].Time) {
+			t.Fatalf("entry %d is older than the previous one", i)
+		}
+	}
+	if want := 200 + int(withdrawals.Load()); len(history) != want || balance != account.Balance() {
+		t.Fatalf("%d entries ending at %s, want %d ending at %s",
+			len(history), balance, want, account.Balance())
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(log.Bytes()), []byte("\n"))
+	if len(lines) != len(history) || string(lines[len(lines)-1]) != history[len(history)-1].String() {
+		t.Fatalf("the log has %d lines for %d entries", len(lines), len(history))
+	}
+	if err := account.LogErr(); err != nil {
+		t.Errorf("log error %v", err)
+	}
+}
+
+// TestBank runs random transfers between the accounts of a bank while other goroutines
+// check that the total never moves: a missed or doubled side of a transfer would show
+func TestBank(t *testing.T) {
+	bank := NewBank(4)
+	var ids []uint64
+	for range 20 {
+		ids = append(ids, bank.CreateAccount(usd(500)).ID())
+	}
+	if _, err := bank.Get(0); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("Get of an unknown ID returned %v", err)
+	}
+	if err := bank.Transfer(ids[0], 0, usd(1)); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("transfer to an unknown ID returned %v", err)
+	}
+	if err := bank.Transfer(ids[0], ids[1], usd(-1)); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("transfer of a negative amount returned %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var wrongTotal atomic.Pointer[Money]
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if totals, _ := bank.Totals(); totals["USD"] != usd(20*500) {
+					total := totals["USD"]
+					wrongTotal.Store(&total)
+				}
+			}
+		}()
+	}
+	var transfers sync.WaitGroup
+	for range 2000 {
+		transfers.Add(1)
+		go func() {
+			defer transfers.Done()
+			from, to := ids[rand.N(len(ids))], ids[rand.N(len(ids))]
+			bank.Transfer(from, to, usd(rand.N(100)))
+		}()
+	}
+	transfers.Wait()
+	close(stop)
+	wg.Wait()
+	if total := wrongTotal.Load(); total != nil {
+		t.Fatalf("Totals returned %s during the transfers, want %s", total, usd(20*500))
+	}
+	if totals, _ := bank.Totals(); totals["USD"] != usd(20*500) || len(totals) != 1 {
+		t.Fatalf("totals %v after the transfers, want %s", totals, usd(20*500))
+	}
+	if accounts := bank.Accounts(); len(accounts) != 20 {
+		t.Errorf("%d accounts, want 20", len(accounts))
+	}
+}
+
+// TestWithdrawWait starts waiters before the deposits: each one must withdraw once the
+// money is there, and a waiter asking more than will ever come must time out
+func TestWithdrawWait(t *testing.T) {
+	account := NewAccount(usd(0))
+	var wg sync.WaitGroup
+	var withdrawn, timedOut atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch err := account.WithdrawWait(usd(10), 5*time.Second); {
+			case err == nil:
+				withdrawn.Add(1)
+			case errors.Is(err, ErrWaitTimeout):
+				timedOut.Add(1)
+			}
+		}()
+	}
+	for range 20 {
+		time.Sleep(time.Millisecond)
+		account.Deposit(usd(5))
+	}
+	wg.Wait()
+	if withdrawn.Load() != 10 || timedOut.Load() != 0 || account.Balance() != usd(0) {
+		t.Fatalf("%d withdrawn, %d timed out, balance %s, want 10, 0 and 0",
+			withdrawn.Load(), timedOut.Load(), account.Balance())
+	}
+
+	start := time.Now()
+	err := account.WithdrawWait(usd(1000), 20*time.Millisecond)
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("returned %v without the funds, want ErrWaitTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Errorf("timed out after %s, want 20ms", elapsed)
+	}
+}
+
+// TestContext holds the lock of an account: DepositCtx and WithdrawCtx must give up with
+// the error of their context, and succeed once the lock is free
+func TestContext(t *testing.T) {
+	account := NewAccount(usd(100))
+	account.mux.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := account.DepositCtx(ctx, usd(10)); !errors.Is(err, context.DeadlineExceeded) {
+		account.mux.Unlock()
+		t.Fatalf("DepositCtx on a locked account returned %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := account.WithdrawCtx(canceled, usd(10)); !errors.Is(err, context.Canceled) {
+		account.mux.Unlock()
+		t.Fatalf("WithdrawCtx with a canceled context returned %v", err)
+	}
+	account.mux.Unlock()
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.DepositCtx(context.Background(), usd(1))
+		}()
+		go func() {
+			defer wg.Done()
+			account.WithdrawCtx(context.Background(), usd(1))
+		}()
+	}
+	wg.Wait()
+	if balance := account.Balance(); balance != usd(100) {
+		t.Errorf("balance %s, want 1.00 USD", balance)
+	}
+}
+
+// TestMoney parses and formats amounts, and checks that the accounts refuse to mix
+// currencies and that the arithmetic refuses to overflow
+func TestMoney(t *testing.T) {
+	for text, want := range map[string]Money{
+		"12.34 USD":  NewMoney(1234, "USD"),
+		"-0.05 EUR":  NewMoney(-5, "EUR"),
+		"7 USD":      NewMoney(700, "USD"),
+		"1.5 GBP":    NewMoney(150, "GBP"),
+		"1500 JPY":   NewMoney(1500, "JPY"),
+		"0.125 KWD":  NewMoney(125, "KWD"),
+		" 3.10 CHF ": NewMoney(310, "CHF"),
+	} {
+		money, err := ParseMoney(text)
+		if err != nil || money != want {
+			t.Fatalf("ParseMoney(%q) = %v, %v, want %v", text, money, err, want)
+		}
+		again, err := ParseMoney(money.String())
+		if err != nil || again != money {
+			t.Fatalf("%v formats as %q, which parses as %v, %v", want, money.String(), again, err)
+		}
+	}
+	for _, text := range []string{"12.34", "1.234 USD", "1.5 JPY", "12 XYZ", "abc USD", "--1 USD", "1.-5 USD", ". USD", ""} {
+		if money, err := ParseMoney(text); !errors.Is(err, ErrInvalidMoney) {
+			t.Fatalf("ParseMoney(%q) = %v, %v, want ErrInvalidMoney", text, money, err)
+		}
+	}
+	if _, err := ParseMoney("99999999999999999999 USD"); !errors.Is(err, ErrMoneyOverflow) {
+		t.Fatalf("ParseMoney of a huge amount returned %v", err)
+	}
+	if _, err := NewMoney(math.MaxInt64, "USD").Add(usd(1)); !errors.Is(err, ErrMoneyOverflow) {
+		t.Fatalf("Add beyond MaxInt64 returned %v", err)
+	}
+
+	account := NewAccount(usd(1000))
+	if err := account.Deposit(NewMoney(500, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("deposit of euros on a dollar account returned %v", err)
+	}
+	euros := NewAccount(NewMoney(500, "EUR"))
+	if err := Transfer(account, euros, usd(100)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("transfer of dollars to a euro account returned %v", err)
+	}
+	if account.Balance() != usd(1000) || euros.Balance() != NewMoney(500, "EUR") {
+		t.Fatalf("a refused transfer changed the balances to %s and %s", account.Balance(), euros.Balance())
+	}
+	var zero Account
+	if err := zero.Deposit(NewMoney(200, "EUR")); err != nil || zero.Balance() != NewMoney(200, "EUR") {
+		t.Errorf("the zero account after a deposit of euros has %s, %v", zero.Balance(), err)
+	}
+}
+
+// TestLedger runs thousands of transfers through a ledger while its watcher checks the
+// invariants, then reconciles every account: its balance is the initial one plus its
+// net movement in the ledger
+func TestLedger(t *testing.T) {
+	ledger := NewLedger()
+	accounts := make([]*Account, 10)
+	for i := range accounts {
+		accounts[i] = NewAccount(usd(10_000))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	violations := ledger.Watch(ctx, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 5000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			from, to := accounts[rand.N(len(accounts))], accounts[rand.N(len(accounts))]
+			ledger.Transfer(from, to, usd(rand.N(500)))
+		}()
+	}
+	wg.Wait()
+	cancel()
+	// The channel is closed once the watcher stopped, after a violation if it found one
+	if err, found := <-violations; found {
+		t.Fatal(err)
+	}
+	if err := ledger.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	balances := ledger.Balances()
+	for _, account := range accounts {
+		want, _ := usd(10_000).Add(balances[account.ID()]["USD"])
+		if account.Balance() != want {
+			t.Fatalf("account %d has %s, the ledger says %s", account.ID(), account.Balance(), want)
+		}
+	}
+
+	// A broken ledger must be noticed
+	ledger.mux.Lock()
+	ledger.entries = ledger.entries[:len(ledger.entries)-1]
+	ledger.mux.Unlock()
+	if ledger.Check() == nil {
+		t.Error("Check missed a transfer with a single half")
+	}
+}
+
+// TestLedgerAccounts checks that the ledger records the overdraft fees and notices the
+// movements that didn't go through it
+func TestLedgerAccounts(t *testing.T) {
+	ledger := NewLedger()
+	from, to := NewAccount(usd(10_00)), NewAccount(usd(0))
+	if err := from.SetOverdraft(usd(100_00), FlatFee{usd(5_00)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.00 USD of funds, the other 20.00 USD are an overdraft charged 5.00 USD
+	if err := ledger.Transfer(from, to, usd(30_00)); err != nil {
+		t.Fatal(err)
+	}
+	if from.Balance() != usd(-25_00) {
+		t.Fatalf("balance %s, want -25.00 USD", from.Balance())
+	}
+	if err := ledger.Check(); err != nil {
+		t.Fatal(err)
+	}
+	balances := ledger.Balances()
+	if balances[FeeAccount]["USD"] != usd(5_00) || balances[from.ID()]["USD"] != usd(-35_00) {
+		t.Fatalf("the ledger has %v, want the fee of 5.00 USD", balances)
+	}
+	if entries := ledger.Entries(); len(entries) != 4 {
+		t.Fatalf("%d entries, want a pair for the transfer and one for the fee", len(entries))
+	}
+
+	// The same transfer outside the ledger
+	if err := Transfer(to, from, usd(1_00)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Check(); err == nil {
+		t.Error("Check missed a transfer outside the ledger")
+	}
+}
+
+// TestOptimisticAccount checks that a stale version can't commit, and that concurrent
+// updates retried after their conflicts lose nothing
+func TestOptimisticAccount(t *testing.T) {
+	account := NewOptimisticAccount(usd(100))
+	balance, version := account.Read()
+	if !account.Commit(version, usd(200)) {
+		t.Fatal("the commit of the current version failed")
+	}
+	// Back to the same balance, but at a newer version
+	account.Commit(version+1, balance)
+	if account.Commit(version, usd(300)) {
+		t.Fatal("a commit of an old version succeeded")
+	}
+
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for range 1000 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.Deposit(usd(3))
+		}()
+		go func() {
+			defer wg.Done()
+			if account.Withdraw(usd(2)) != nil {
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	want := usd(100 + 1000*3 - (1000-int(refused.Load()))*2)
+	if balance, version := account.Read(); balance != want || version != 2+2000-uint64(refused.Load()) {
+		t.Errorf("%s at version %d, want %s at version %d",
+			balance, version, want, 2+2000-refused.Load())
+	}
+
+	// Invalid amounts are refused before Update: nothing is committed
+	_, before := account.Read()
+	for _, amount := range []Money{usd(0), usd(-100)} {
+		if err := account.Deposit(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Deposit(%s) returned %v", amount, err)
+		}
+		if err := account.Withdraw(amount); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Withdraw(%s) returned %v", amount, err)
+		}
+	}
+	if balance, version := account.Read(); balance != want || version != before {
+		t.Errorf("%s at version %d after the invalid amounts, want %s at version %d",
+			balance, version, want, before)
+	}
+}
+
+// TestTx checks that a failing step or a Rollback changes nothing, that a commit applies
+// every step, and that transactions in both directions between two accounts can't deadlock
+func TestTx(t *testing.T) {
+	checking, savings := NewAccount(usd(1000)), NewAccount(usd(0))
+
+	// The second half can't happen: the first must not happen either
+	tx := checking.Begin()
+	tx.Transfer(savings, usd(600))
+	tx.Withdraw(usd(600))
+	if err := tx.Commit(); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("commit of a failing step returned %v", err)
+	}
+	if checking.Balance() != usd(1000) || savings.Balance() != usd(0) || len(checking.History()) != 0 {
+		t.Fatalf("a failed commit left %s and %s", checking.Balance(), savings.Balance())
+	}
+	if err := tx.Deposit(usd(1)); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("Deposit after Commit returned %v", err)
+	}
+
+	tx = checking.Begin()
+	tx.Withdraw(usd(100))
+	if err := tx.Rollback(); err != nil || checking.Balance() != usd(1000) {
+		t.Fatalf("Rollback returned %v, balance %s", err, checking.Balance())
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("Commit after Rollback returned %v", err)
+	}
+
+	tx = checking.Begin()
+	tx.Transfer(savings, usd(600))
+	tx.Deposit(usd(50))
+	tx.Withdraw(usd(450))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit returned %v", err)
+	}
+	if checking.Balance() != usd(0) || savings.Balance() != usd(600) || len(checking.History()) != 3 {
+		t.Fatalf("after the commit %s and %s, want 0.00 USD and 6.00 USD",
+			checking.Balance(), savings.Balance())
+	}
+
+	var wg sync.WaitGroup
+	for i := range 500 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			from, to := checking, savings
+			if i%2 == 0 {
+				from, to = savings, checking
+			}
+			tx := from.Begin()
+			tx.Transfer(to, usd(1))
+			tx.Commit()
+		}()
+	}
+	wg.Wait()
+	if total, _ := checking.Balance().Add(savings.Balance()); total != usd(600) {
+		t.Errorf("total %s after the concurrent transactions, want 6.00 USD", total)
+	}
+}
+
+// TestInterest pays 1% a day for three days of a clock.Fake, with the fraction of a cent
+// dropped each time, then stops the worker with its context
+func TestInterest(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	account := NewAccount(usd(100_000))
+	account.UseClock(fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := account.StartInterest(ctx, 100, 24*time.Hour)
+
+	for _, want := range []int{101_000, 102_010, 103_030} {
+		// Advance only once the worker waits for the clock, or the tick would be missed
+		if !eventually(func() bool { return fake.Waiters() == 1 }) {
+			t.Fatal("the worker is not waiting for the clock")
+		}
+		fake.Advance(24 * time.Hour)
+		if !eventually(func() bool { return account.Balance() == usd(want) }) {
+			t.Fatalf("balance %s, want %s", account.Balance(), usd(want))
+		}
+	}
+	history := account.History()
+	if len(history) != 3 || history[2].Kind != InterestOperation || !history[2].Time.Equal(start.Add(72*time.Hour)) {
+		t.Fatalf("history %v, want 3 payments a day apart", history)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the worker did not stop with its context")
+	}
+}
+
+// eventually polls condition for up to a second, for the work of other goroutines
+func eventually(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
+
+// TestSeries makes an operation every hour of a clock.Fake and queries the balance
+// before, between and after them
+func TestSeries(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	account := NewAccount(usd(100))
+	account.UseClock(fake)
+	for _, amount := range []int{50, -30, 200} {
+		fake.Advance(time.Hour)
+		if amount > 0 {
+			account.Deposit(usd(amount))
+		} else {
+			account.Withdraw(usd(-amount))
+		}
+	}
+
+	for offset, want := range map[time.Duration]int{
+		-time.Hour:       100,
+		0:                100,
+		time.Hour:        150,
+		90 * time.Minute: 150,
+		2 * time.Hour:    120,
+		10 * time.Hour:   320,
+	} {
+		if balance := account.BalanceAt(start.Add(offset)); balance != usd(want) {
+			t.Fatalf("balance at +%s is %s, want %s", offset, balance, usd(want))
+		}
+	}
+
+	series := account.Series(start.Add(30*time.Minute), start.Add(2*time.Hour))
+	want := []BalancePoint{
+		{start.Add(30 * time.Minute), usd(100)},
+		{start.Add(time.Hour), usd(150)},
+		{start.Add(2 * time.Hour), usd(120)},
+	}
+	if !slices.EqualFunc(series, want, func(a, b BalancePoint) bool {
+		return a.Time.Equal(b.Time) && a.Balance == b.Balance
+	}) {
+		t.Fatalf("%v, want %v", series, want)
+	}
+
+	// Reversed bounds with operations between them
+	series = account.Series(start.Add(2*time.Hour), start.Add(30*time.Minute))
+	if len(series) != 1 || !series[0].Time.Equal(start.Add(2*time.Hour)) || series[0].Balance != usd(120) {
+		t.Fatalf("reversed bounds gave %v, want the balance at from alone", series)
+	}
+}
+
+// TestOverdraftPolicy lets 100 goroutines withdraw 10.00 USD from an empty account with
+// an overdraft of 100.00 USD and a flat fee of 5.00 USD: each withdrawal costs 15.00 USD,
+// so exactly 6 fit in the limit. Then it checks the percentage fee and its minimum
+func TestOverdraftPolicy(t *testing.T) {
+	account := NewAccount(usd(0))
+	if err := account.SetOverdraft(usd(-1), nil); !errors.Is(err, ErrInvalidMoney) {
+		t.Fatalf("a negative limit returned %v", err)
+	}
+	if err := account.SetOverdraft(usd(100_00), FlatFee{usd(5_00)}); err != nil {
+		t.Fatalf("SetOverdraft returned %v", err)
+	}
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if account.Withdraw(usd(10_00)) == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded.Load() != 6 || account.Balance() != usd(-90_00) || len(account.History()) != 12 {
+		t.Fatalf("%d withdrawals, balance %s, %d operations, want 6, -90.00 USD and 12",
+			succeeded.Load(), account.Balance(), len(account.History()))
+	}
+	account.Deposit(usd(90_00))
+
+	// 10% of the overdrawn part, at least 1.00 USD
+	account.SetOverdraft(usd(100_00), PercentFee{BasisPoints: 1000, Min: usd(1_00)})
+	savings := NewAccount(usd(0))
+	for _, c := range []struct{ amount, want int }{
+		{50_00, -55_00}, // 5.00 USD of fee
+		{1_00, -57_00},  // 0.10 USD, the minimum applies
+	} {
+		if err := Transfer(account, savings, usd(c.amount)); err != nil || account.Balance() != usd(c.want) {
+			t.Fatalf("transfer of %s returned %v, balance %s, want %s",
+				usd(c.amount), err, account.Balance(), usd(c.want))
+		}
+	}
+	if err := account.Withdraw(usd(40_00)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("a withdrawal beyond the limit returned %v", err)
+	}
+}
+
+// balanceReader is an observer calling an account back, which deadlocks if the events
+// are delivered with the lock of the account held
+type balanceReader struct {
+	account  *Account
+	balances []Money
+	mux      sync.Mutex
+}
+
+func (r *balanceReader) GetId() string {
+	return "balance reader"
+}
+
+func (r *balanceReader) UpdateValue(_ context.Context, event AccountEvent) error {
+	balance := r.account.Balance()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.balances = append(r.balances, balance)
+	return nil
+}
+
+// TestObserver registers an email client for every event and an SMS client for the low
+// balance only, then runs 100 deposits and 100 withdrawals at once: there must be one
+// email per operation and no SMS. Then it takes the balance below the threshold twice,
+// each crossing sends one SMS, and a transfer notifies an observer reading the other account
+func TestObserver(t *testing.T) {
+	account := NewAccount(usd(200_00))
+	account.SetLowBalance(usd(50_00))
+	mail := &notify.MockSender{}
+	sms := &notify.FakeSMSProvider{}
+	account.Register(NewEmailClient("owner@example.com", "bank@example.com", mail))
+	account.Register(NewSmsClient("+15550001111", "+15550000000", sms), ForAccountEvents(EventLowBalance))
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.Deposit(usd(1_00))
+		}()
+		go func() {
+			defer wg.Done()
+			account.Withdraw(usd(1_00))
+		}()
+	}
+	wg.Wait()
+	// The write methods deliver their events before returning
+	kinds := map[string]int{}
+	for _, message := range mail.Messages() {
+		kinds[message.Subject]++
+	}
+	if kinds["deposit"] != 100 || kinds["withdrawal"] != 100 || len(sms.Sent()) != 0 {
+		t.Fatalf("%v emails and %d SMS, want 100 of each kind and 0", kinds, len(sms.Sent()))
+	}
+
+	for _, step := range []struct {
+		deposit bool
+		amount  int
+		sms     int
+	}{
+		{false, 160_00, 1}, // 40.00 USD, below the threshold
+		{false, 10_00, 1},  // Still below, no new SMS
+		{true, 100_00, 1},  // 130.00 USD, above again
+		{false, 100_00, 2}, // 30.00 USD, a second crossing
+	} {
+		if step.deposit {
+			account.Deposit(usd(step.amount))
+		} else {
+			account.Withdraw(usd(step.amount))
+		}
+		if sent := sms.Sent(); len(sent) != step.sms {
+			t.Fatalf("%d SMS after the balance reached %s, want %d", len(sent), account.Balance(), step.sms)
+		}
+	}
+	if body := sms.Sent()[1].Body; !strings.Contains(body, "30.00 USD is below 50.00 USD") {
+		t.Fatalf("SMS %q, want the balance and the threshold", body)
+	}
+
+	savings := NewAccount(usd(0))
+	reader := &balanceReader{account: savings}
+	account.Register(reader)
+	if err := Transfer(account, savings, usd(10_00)); err != nil {
+		t.Fatalf("transfer returned %v", err)
+	}
+	if len(reader.balances) != 1 || reader.balances[0] != usd(10_00) || len(account.DeadLetters()) != 0 {
+		t.Errorf("the reader saw %v with %d dead letters, want [10.00 USD] and none",
+			reader.balances, len(account.DeadLetters()))
+	}
+}
+
+// TestSnapshot saves a bank 20 times while 1000 transfers run: every snapshot must
+// hold the total of the bank, since Save never catches a transfer halfway. The last one
+// can't be loaded while its IDs are in use; with new IDs, it is loaded into a new bank,
+// which must have the same balances and histories, and no temporary file may be left
+// next to it
+func TestSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bank.json")
+
+	bank := NewBank(4)
+	var ids []uint64
+	for range 10 {
+		ids = append(ids, bank.CreateAccount(usd(100_00)).ID())
+	}
+	// A fixed number of transfers: the histories, and the time of each Save, stay bounded
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 250 {
+				bank.Transfer(ids[(g+i)%len(ids)], ids[(g+i*3+1)%len(ids)], usd(1+i%500))
+			}
+		}()
+	}
+	for range 20 {
+		if err := bank.Save(path); err != nil {
+			t.Fatalf("Save returned %v", err)
+		}
+		var snapshot bankSnapshot
+		data, _ := os.ReadFile(path)
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatalf("reading the file: %v", err)
+		}
+		total := usd(0)
+		for _, saved := range snapshot.Accounts {
+			total, _ = total.Add(saved.Balance)
+		}
+		if total != usd(1000_00) || len(snapshot.Accounts) != len(ids) {
+			t.Fatalf("%d accounts with a total of %s, want 10 and 1000.00 USD", len(snapshot.Accounts), total)
+		}
+	}
+	wg.Wait()
+
+	if err := bank.Save(path); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+	// The IDs of the snapshot belong to the accounts of bank: loading it in this program
+	// would give two accounts the same ID
+	restored := NewBank(16)
+	if _, err := restored.Load(path); !errors.Is(err, ErrDuplicateAccount) || len(restored.Accounts()) != 0 {
+		t.Fatalf("loading IDs in use returned %v with %d accounts", err, len(restored.Accounts()))
+	}
+	// The snapshot of a previous run has IDs not handed out yet: moving them past lastID
+	// makes one
+	var snapshot bankSnapshot
+	data, _ := os.ReadFile(path)
+	json.Unmarshal(data, &snapshot)
+	offset := lastID.Load()
+	for i := range snapshot.Accounts {
+		snapshot.Accounts[i].ID += offset
+	}
+	data, _ = json.Marshal(snapshot)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := restored.Load(path); n != len(ids) || err != nil {
+		t.Fatalf("Load returned %d, %v, want 10 accounts", n, err)
+	}
+	for _, id := range ids {
+		original, _ := bank.Get(id)
+		account, err := restored.Get(id + offset)
+		if err != nil || account.Balance() != original.Balance() || len(account.History()) != len(original.History()) {
+			t.Fatalf("account %d was not restored as saved", id)
+		}
+	}
+	if _, err := restored.Load(path); !errors.Is(err, ErrDuplicateAccount) || len(restored.Accounts()) != len(ids) {
+		t.Fatalf("loading twice returned %v with %d accounts", err, len(restored.Accounts()))
+	}
+	if id := restored.CreateAccount(usd(0)).ID(); id <= offset+ids[len(ids)-1] {
+		t.Fatalf("a new account got the restored ID %d", id)
+	}
+	if _, err := restored.Load(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("loading a missing file returned %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("%d files in the directory, want only the snapshot", len(files))
+	}
+}
@@ -0,0 +1,135 @@
+package account
+
+import "errors"
+
+// ErrAccountClosed is returned by the operations of an ActorAccount after Close
+var ErrAccountClosed = errors.New("account closed")
+
+// ActorAccount is an account without any lock: "Do not communicate by sharing memory;
+// instead, share memory by communicating"
+// A single goroutine, the actor, owns the balance. The other goroutines never touch it,
+// they send commands over a channel and wait for the answer on a channel of their own
+// The actor handles one command at a time, so the commands can't race, like with a mutex,
+// but the exclusion comes from the channel instead of a lock that every method must
+// remember to take
+type ActorAccount struct {
+	commands chan command
+	done     chan struct{} // Closed by the actor when it stops
+}
+
+// commandKind is the operation asked to the actor
+type commandKind int
+
+const (
+	depositCommand commandKind = iota
+	withdrawCommand
+	balanceCommand
+	closeCommand
+)
+
+// command is a message to the actor, the reply channel gets the outcome
+type command struct {
+	kind   commandKind
+	amount Money
+	reply  chan reply
+}
+
+// reply is the outcome of a command: the balance after it, or an error
+type reply struct {
+	balance Money
+	err     error
+}
+
+// NewActorAccount creates an account with an initial balance and starts its actor
+func NewActorAccount(initial Money) *ActorAccount {
+	a := &ActorAccount{
+		// Unbuffered: a command is either received by the actor, and answered, or not
+		// sent at all. A buffer could hold commands the actor never reads after Close
+		commands: make(chan command),
+		done:     make(chan struct{}),
+	}
+	go a.run(initial)
+	return a
+}
+
+// run is the actor: the balance is a local variable, no other goroutine can reach it
+func (a *ActorAccount) run(balance Money) {
+	defer close(a.done)
+	for cmd := range a.commands {
+		if cmd.kind == depositCommand || cmd.kind == withdrawCommand {
+			// A negative deposit would be a withdrawal without the check of the funds
+			if err := checkAmount(cmd.amount); err != nil {
+				cmd.reply <- reply{balance: balance, err: err}
+				continue
+			}
+		}
+		switch cmd.kind {
+		case depositCommand:
+			next, err := balance.Add(cmd.amount)
+			if err == nil {
+				balance = next
+			}
+			cmd.reply <- reply{balance: balance, err: err}
+		case withdrawCommand:
+			next, err := balance.Sub(cmd.amount)
+			if err == nil && next.IsNegative() {
+				err = ErrInsufficientFunds
+			}
+			if err == nil {
+				balance = next
+			}
+			cmd.reply <- reply{balance: balance, err: err}
+		case balanceCommand:
+			cmd.reply <- reply{balance: balance}
+		case closeCommand:
+			cmd.reply <- reply{balance: balance}
+			return
+		}
+	}
+}
+
+// send gives a command to the actor and waits for its reply
+// Once the actor stopped nobody receives the commands anymore, done tells the callers
+func (a *ActorAccount) send(kind commandKind, amount Money) (Money, error) {
+	// Buffered so the actor never waits for a caller to read its reply
+	cmd := command{kind: kind, amount: amount, reply: make(chan reply, 1)}
+	select {
+	case a.commands <- cmd:
+	case <-a.done:
+		return Money{}, ErrAccountClosed
+	}
+	r := <-cmd.reply
+	return r.balance, r.err
+}
+
+// Deposit adds the given amount to the balance, or returns ErrInvalidAmount,
+// ErrCurrencyMismatch or ErrAccountClosed
+func (a *ActorAccount) Deposit(amount Money) error {
+	_, err := a.send(depositCommand, amount)
+	return err
+}
+
+// Withdraw subtracts the given amount from the balance, or returns ErrInsufficientFunds,
+// ErrInvalidAmount, ErrCurrencyMismatch or ErrAccountClosed
+func (a *ActorAccount) Withdraw(amount Money) error {
+	_, err := a.send(withdrawCommand, amount)
+	return err
+}
+
+// Balance returns the current balance, or ErrAccountClosed
+func (a *ActorAccount) Balance() (Money, error) {
+	return a.send(balanceCommand, Money{})
+}
+
+// Close stops the actor and returns the final balance
+// Every command the actor received before the close command is applied and answered:
+// the actor handles them in order, so nothing accepted is lost. The commands sent after
+// get ErrAccountClosed. Closing twice returns ErrAccountClosed
+func (a *ActorAccount) Close() (Money, error) {
+	balance, err := a.send(closeCommand, Money{})
+	if err != nil {
+		return Money{}, err
+	}
+	<-a.done
+	return balance, nil
+}
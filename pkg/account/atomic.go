@@ -0,0 +1,72 @@
+package account
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// AtomicAccount is Account without a mutex: the balance is an atomic.Int64 of minor units,
+// updated by single CPU instructions that no other goroutine can interrupt halfway
+// Balance is one atomic operation. Deposit and Withdraw have to check the new balance
+// (overflow, overdraft) and store it in one step, which no single instruction does: they
+// read the balance and store the new one only if nobody changed it meanwhile
+// (CompareAndSwap), retrying if someone did. This works for one value; two values changed
+// together, like the two sides of a Transfer, still need a lock
+// The currency is set by NewAtomicAccount and never changes, so reading it needs no
+// synchronization; the zero AtomicAccount has no currency and accepts no deposit
+type AtomicAccount struct {
+	units    atomic.Int64
+	currency string
+}
+
+// NewAtomicAccount creates an atomic account with an initial balance
+func NewAtomicAccount(initial Money) *AtomicAccount {
+	a := &AtomicAccount{currency: initial.Currency}
+	a.units.Store(initial.Units)
+	return a
+}
+
+// Deposit adds the given amount to the balance, or returns ErrInvalidAmount,
+// ErrCurrencyMismatch or ErrMoneyOverflow
+func (a *AtomicAccount) Deposit(amount Money) error {
+	return a.update(amount, Money.Add)
+}
+
+// Withdraw subtracts the given amount from the balance, or returns ErrInsufficientFunds,
+// ErrInvalidAmount or ErrCurrencyMismatch
+func (a *AtomicAccount) Withdraw(amount Money) error {
+	return a.update(amount, func(balance, amount Money) (Money, error) {
+		balance, err := balance.Sub(amount)
+		if err == nil && balance.IsNegative() {
+			err = ErrInsufficientFunds
+		}
+		return balance, err
+	})
+}
+
+// update replaces the balance with operation(balance, amount), retrying until no other
+// goroutine changed the balance between the read and the write
+func (a *AtomicAccount) update(amount Money, operation func(balance, amount Money) (Money, error)) error {
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	if amount.Currency != a.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.currency, amount.Currency)
+	}
+	for {
+		units := a.units.Load()
+		balance, err := operation(Money{Units: units, Currency: a.currency}, amount)
+		if err != nil {
+			return err
+		}
+		if a.units.CompareAndSwap(units, balance.Units) {
+			return nil
+		}
+		// Another goroutine changed the balance between Load and CompareAndSwap, try again
+	}
+}
+
+// Balance returns the current balance
+func (a *AtomicAccount) Balance() Money {
+	return Money{Units: a.units.Load(), Currency: a.currency}
+}
@@ -0,0 +1,124 @@
+package account
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownAccount is returned by the Bank for an ID it doesn't manage
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Bank manages many accounts, found by their ID
+// One mutex around the map of accounts would make every operation wait for every other
+// one, even on unrelated accounts. The map is split into stripes instead, each with its
+// own mutex, and an ID always falls in the same stripe: two operations only contend when
+// their accounts share a stripe. The stripe locks are only held to find an account, the
+// operations themselves take the lock of the accounts, see account.go
+type Bank struct {
+	stripes []stripe
+}
+
+// stripe is a part of the accounts of a Bank, with its lock
+type stripe struct {
+	accounts map[uint64]*Account
+	mux      sync.RWMutex
+}
+
+// NewBank creates a bank with the given number of stripes, 16 if stripes is not positive
+// More stripes means less contention on the map, for a little more memory
+func NewBank(stripes int) *Bank {
+	if stripes <= 0 {
+		stripes = 16
+	}
+	b := &Bank{stripes: make([]stripe, stripes)}
+	for i := range b.stripes {
+		b.stripes[i].accounts = make(map[uint64]*Account)
+	}
+	return b
+}
+
+// stripe returns the stripe of an account ID
+func (b *Bank) stripe(id uint64) *stripe {
+	return &b.stripes[id%uint64(len(b.stripes))]
+}
+
+// CreateAccount opens an account with an initial balance; its ID is the key of the bank
+func (b *Bank) CreateAccount(initial Money) *Account {
+	account := NewAccount(initial)
+	s := b.stripe(account.ID())
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.accounts[account.ID()] = account
+	return account
+}
+
+// Get returns the account of an ID, or ErrUnknownAccount
+func (b *Bank) Get(id uint64) (*Account, error) {
+	s := b.stripe(id)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	account, exists := s.accounts[id]
+	if !exists {
+		return nil, ErrUnknownAccount
+	}
+	return account, nil
+}
+
+// Transfer moves amount between two accounts of the bank, see Transfer
+func (b *Bank) Transfer(fromID, toID uint64, amount Money) error {
+	from, err := b.Get(fromID)
+	if err != nil {
+		return err
+	}
+	to, err := b.Get(toID)
+	if err != nil {
+		return err
+	}
+	return Transfer(from, to, amount)
+}
+
+// Accounts returns the accounts of the bank, ordered by ID
+func (b *Bank) Accounts() []*Account {
+	var accounts []*Account
+	for i := range b.stripes {
+		s := &b.stripes[i]
+		s.mux.RLock()
+		for _, account := range s.accounts {
+			accounts = append(accounts, account)
+		}
+		s.mux.RUnlock()
+	}
+	slices.SortFunc(accounts, func(a, b *Account) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+	return accounts
+}
+
+// Totals returns the sum of the balances of the bank in each currency, or
+// ErrMoneyOverflow
+// Adding the balances one at a time would be wrong while transfers run: a transfer from
+// an account not added yet to one already added would be missed on both sides. Total
+// holds the read locks of every account at once, taken in ID order like Transfer does,
+// so it sees all the balances at one moment and can't deadlock with the transfers
+func (b *Bank) Totals() (map[string]Money, error) {
+	accounts := b.Accounts()
+	for _, account := range accounts {
+		account.mux.RLock()
+		defer account.mux.RUnlock()
+	}
+	totals := make(map[string]Money)
+	for _, account := range accounts {
+		if account.balance.Currency == "" {
+			// A zero account that never had a deposit
+			continue
+		}
+		total, err := totals[account.balance.Currency].Add(account.balance)
+		if err != nil {
+			return nil, err
+		}
+		totals[account.balance.Currency] = total
+	}
+	return totals, nil
+}
@@ -0,0 +1,52 @@
+package account
+
+import (
+	"context"
+	"time"
+)
+
+// DepositCtx is Deposit that gives up waiting for the lock when ctx is done,
+// returning ctx.Err()
+func (a *Account) DepositCtx(ctx context.Context, amount Money) error {
+	if err := a.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer a.unlock()
+	return a.deposit(amount)
+}
+
+// WithdrawCtx is Withdraw that gives up waiting for the lock when ctx is done,
+// returning ctx.Err()
+func (a *Account) WithdrawCtx(ctx context.Context, amount Money) error {
+	if err := a.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer a.unlock()
+	return a.withdraw(amount)
+}
+
+// lockCtx takes the write lock like Lock, or returns ctx.Err() if ctx is done first
+// Lock can't be interrupted, so it tries TryLock again and again, sleeping a little longer
+// each time up to a millisecond: a free lock is taken at once, a busy one costs a few
+// wake ups instead of a spinning CPU. The sync documentation warns that TryLock is rarely
+// the right tool; here it is the only way to bound the wait of a plain mutex. The price is
+// fairness: a goroutine in Lock may get the lock before one that has been trying longer
+func (a *Account) lockCtx(ctx context.Context) error {
+	backoff := time.Microsecond
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if a.mux.TryLock() {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, time.Millisecond)
+	}
+}
@@ -0,0 +1,159 @@
+package account
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// An Account is a Topic (see pkg/observer): observers such as EmailClient and SmsClient
+// register to be told about deposits, withdrawals and a balance falling below a threshold
+// The events are produced with the lock held, in the order of the history, but delivered
+// after it is released: an observer sending an email must not block the account, nor be
+// able to deadlock by calling it. The write methods deliver the events of their operation
+// before returning, through a Dispatcher that bounds the time of each observer; the events
+// of concurrent operations may reach the observers in any order, their Balance tells
+// which came first
+
+// AccountEventKind identifies what happened to an account
+type AccountEventKind string
+
+const (
+	EventDeposit    AccountEventKind = "deposit"     // Money came in: deposit, transfer, interest
+	EventWithdrawal AccountEventKind = "withdrawal"  // Money went out: withdrawal, transfer, fee
+	EventLowBalance AccountEventKind = "low-balance" // The balance fell below the threshold
+)
+
+// AccountEvent is the payload sent to the observers of an account
+type AccountEvent struct {
+	Kind      AccountEventKind
+	Account   uint64
+	Operation Operation // The operation that caused the event
+	Threshold Money     // For EventLowBalance
+}
+
+// String describes the event for the notification messages
+func (e AccountEvent) String() string {
+	switch e.Kind {
+	case EventLowBalance:
+		return fmt.Sprintf("Account %d: the balance of %s is below %s", e.Account, e.Operation.Balance, e.Threshold)
+	default:
+		return fmt.Sprintf("Account %d: %s of %s, balance %s", e.Account, e.Operation.Kind, e.Operation.Amount, e.Operation.Balance)
+	}
+}
+
+// ForAccountEvents subscribes an observer only to the given kinds of account events
+func ForAccountEvents(kinds ...AccountEventKind) observer.SubscribeOption[AccountEvent] {
+	return observer.WithFilter(func(event AccountEvent) bool {
+		for _, kind := range kinds {
+			if event.Kind == kind {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Register adds an observer of the account
+// The first observer creates the dispatcher: 4 workers, a 500ms timeout and 2 retries
+func (a *Account) Register(o observer.Observer[AccountEvent], options ...observer.SubscribeOption[AccountEvent]) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.dispatcher == nil {
+		a.dispatcher = observer.NewDispatcher[AccountEvent](4, 500*time.Millisecond, 2)
+	}
+	// A new slice, so the deliveries in progress keep the list they started with
+	a.subscriptions = append(a.subscriptions[:len(a.subscriptions):len(a.subscriptions)],
+		observer.NewSubscription(o, options))
+}
+
+// Broadcast notifies the observers whose filters accept the event
+func (a *Account) Broadcast(event AccountEvent) error {
+	a.mux.RLock()
+	subscriptions, dispatcher := a.subscriptions, a.dispatcher
+	a.mux.RUnlock()
+	if dispatcher == nil {
+		return nil
+	}
+	return dispatcher.Dispatch(observer.Matching(subscriptions, event), event)
+}
+
+// DeadLetters returns the notifications that failed after every retry
+func (a *Account) DeadLetters() []observer.DeadLetter[AccountEvent] {
+	a.mux.RLock()
+	dispatcher := a.dispatcher
+	a.mux.RUnlock()
+	if dispatcher == nil {
+		return nil
+	}
+	return dispatcher.DeadLetters()
+}
+
+// SetLowBalance sends an EventLowBalance each time an operation takes the balance from
+// threshold or more to below it; a balance staying low doesn't repeat the event
+func (a *Account) SetLowBalance(threshold Money) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.threshold = &threshold
+	a.low = a.below(threshold)
+}
+
+// below reports whether the balance is under threshold; the caller holds the lock
+func (a *Account) below(threshold Money) bool {
+	difference, err := a.balance.Sub(threshold)
+	return err == nil && difference.IsNegative()
+}
+
+// emit queues the events of an operation just recorded, for unlock; the caller holds the
+// write lock
+func (a *Account) emit(operation Operation) {
+	crossed := false
+	if a.threshold != nil {
+		low := a.below(*a.threshold)
+		crossed = low && !a.low
+		a.low = low
+	}
+	if len(a.subscriptions) == 0 {
+		return
+	}
+
+	kind := EventDeposit
+	switch operation.Kind {
+	case WithdrawOperation, TransferOutOperation, FeeOperation:
+		kind = EventWithdrawal
+	}
+	a.events = append(a.events, AccountEvent{Kind: kind, Account: a.ID(), Operation: operation})
+	if crossed {
+		a.events = append(a.events, AccountEvent{Kind: EventLowBalance, Account: a.ID(), Operation: operation, Threshold: *a.threshold})
+	}
+}
+
+// unlock releases the write lock, then delivers the events queued meanwhile
+func (a *Account) unlock() {
+	unlockAll(a)
+}
+
+// unlockAll releases the write locks of the accounts, then delivers their events: an
+// observer is never called with the lock of any account of the operation held
+func unlockAll(accounts ...*Account) {
+	type delivery struct {
+		events        []AccountEvent
+		subscriptions []observer.Subscription[AccountEvent]
+		dispatcher    *observer.Dispatcher[AccountEvent]
+	}
+	deliveries := make([]delivery, 0, len(accounts))
+	for _, a := range accounts {
+		if len(a.events) > 0 {
+			deliveries = append(deliveries, delivery{a.events, a.subscriptions, a.dispatcher})
+			a.events = nil
+		}
+		a.mux.Unlock()
+	}
+	for _, d := range deliveries {
+		for _, event := range d.events {
+			// The errors stay in the dead letters, the operation itself succeeded
+			d.dispatcher.Dispatch(observer.Matching(d.subscriptions, event), event)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package account
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// OperationKind is the kind of an operation of the history
+type OperationKind string
+
+const (
+	DepositOperation     OperationKind = "deposit"
+	WithdrawOperation    OperationKind = "withdraw"
+	TransferInOperation  OperationKind = "transfer-in"
+	TransferOutOperation OperationKind = "transfer-out"
+	InterestOperation    OperationKind = "interest"
+	FeeOperation         OperationKind = "fee"
+)
+
+// Operation is an entry of the history of an account
+type Operation struct {
+	Time    time.Time
+	Kind    OperationKind
+	Amount  Money
+	Balance Money // The balance right after the operation
+}
+
+// String formats the operation as a line of the log, see LogTo
+func (o Operation) String() string {
+	return fmt.Sprintf("%s %-12s %s balance %s", o.Time.Format("2006-01-02T15:04:05.000000Z07:00"), o.Kind, o.Amount, o.Balance)
+}
+
+// record appends an operation to the history and the log; the caller holds the write lock,
+// so the entries are in the order the operations happened and each Balance is exact
+// Only the operations that changed the balance are recorded: a refused withdrawal is not
+func (a *Account) record(kind OperationKind, amount Money) {
+	operation := Operation{Time: a.now(), Kind: kind, Amount: amount, Balance: a.balance}
+	a.history = append(a.history, operation)
+	a.emit(operation)
+	if a.log != nil && a.logErr == nil {
+		// The write happens with the lock held, so a slow writer slows the account down:
+		// that is the price of a log in the same order as the history
+		_, a.logErr = fmt.Fprintln(a.log, operation)
+	}
+}
+
+// History returns a copy of the operations of the account, oldest first
+// The history is append-only: the entries returned never change, and a copy keeps the
+// caller from reading the slice while an operation appends to it
+func (a *Account) History() []Operation {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return slices.Clone(a.history)
+}
+
+// LogTo also writes every next operation as a line to w, like a file opened for append
+// After a write error the log stops, LogErr returns the error; the history goes on
+func (a *Account) LogTo(w io.Writer) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.log, a.logErr = w, nil
+}
+
+// LogErr returns the error that stopped the log, if any
+func (a *Account) LogErr() error {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	return a.logErr
+}
@@ -0,0 +1,62 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+// StartInterest starts a goroutine paying interest on the account every interval, until
+// ctx is done; the returned channel is closed when the goroutine has stopped
+// rate is in basis points per interval: 1 basis point is 0.01%, so 25 pays 0.25% of the
+// balance each time. An integer rate keeps the computation exact, like Money itself, and
+// the fraction of a minor unit below the result is dropped
+// The worker is one more goroutine changing the balance: it takes the write lock like
+// Deposit, so an interest payment and a withdrawal can't both read the old balance
+// Only a positive balance earns interest
+func (a *Account) StartInterest(ctx context.Context, rate int64, interval time.Duration) <-chan struct{} {
+	a.mux.RLock()
+	source := a.clock
+	a.mux.RUnlock()
+	if source == nil {
+		source = clock.Real{}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-source.After(interval):
+				a.payInterest(rate)
+			}
+		}
+	}()
+	return done
+}
+
+// payInterest adds rate basis points of the balance to it
+func (a *Account) payInterest(rate int64) {
+	a.mux.Lock()
+	defer a.unlock()
+	if a.balance.Units <= 0 {
+		return
+	}
+	// units*rate/10000 in two parts, so the product can't overflow for a large balance
+	units := a.balance.Units
+	interest := Money{Units: units/10_000*rate + units%10_000*rate/10_000, Currency: a.balance.Currency}
+	if interest.Units <= 0 {
+		return
+	}
+	balance, err := a.balance.Add(interest)
+	if err != nil {
+		// The balance is at the limit of Money, it keeps it
+		return
+	}
+	a.balance = balance
+	a.record(InterestOperation, interest)
+	a.fundsAdded()
+}
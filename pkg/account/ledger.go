@@ -0,0 +1,189 @@
+package account
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Ledger is a double-entry book of the transfers between accounts
+// Every transfer is written twice: a debit (negative amount) on the account the money
+// leaves and a credit (positive amount) on the account it reaches. Money never appears
+// nor disappears, so the entries of a correct ledger always sum to zero; a missing or
+// doubled half of a transfer shows as a sum that isn't zero. An overdraft fee is a pair
+// of its own, from the account to FeeAccount
+// The entries are appended while the transfer still holds the locks of both accounts:
+// a reader can't see one half of a transfer without the other, nor a balance the ledger
+// doesn't explain yet
+type Ledger struct {
+	entries  []LedgerEntry
+	lastPair uint64
+	accounts map[uint64]*Account // Every account with entries
+	opening  map[uint64]Money    // Their balance before their first entry
+	mux      sync.RWMutex
+}
+
+// FeeAccount is the account credited with the overdraft fees in the ledger; the IDs of
+// the accounts start at 1
+const FeeAccount uint64 = 0
+
+// LedgerEntry is one half of a transfer
+type LedgerEntry struct {
+	Pair    uint64 // The transfer, shared by its debit and its credit
+	Account uint64 // The ID of the account
+	Amount  Money  // Negative for the debit, positive for the credit
+	Time    time.Time
+}
+
+// NewLedger creates an empty ledger
+func NewLedger() *Ledger {
+	return &Ledger{accounts: make(map[uint64]*Account), opening: make(map[uint64]Money)}
+}
+
+// Transfer moves amount between the accounts like Transfer, and records its two entries,
+// and those of the fee, if it succeeded
+func (l *Ledger) Transfer(from, to *Account, amount Money) error {
+	return transfer(from, to, amount, func(fee Money) {
+		now := time.Now()
+		l.mux.Lock()
+		defer l.mux.Unlock()
+		// The balances already include this transfer: the opening balance is before it
+		before, _ := from.balance.Add(amount)
+		before, _ = before.Add(fee)
+		l.join(from, before)
+		before, _ = to.balance.Sub(amount)
+		l.join(to, before)
+
+		l.pair(from.ID(), to.ID(), amount, now)
+		if !fee.IsZero() {
+			l.pair(from.ID(), FeeAccount, fee, now)
+		}
+	})
+}
+
+// join starts following account, whose balance before its first entry is opening;
+// l.mux must be held
+func (l *Ledger) join(account *Account, opening Money) {
+	id := account.ID()
+	if _, exists := l.accounts[id]; !exists {
+		l.accounts[id] = account
+		l.opening[id] = opening
+	}
+}
+
+// pair appends the debit and the credit of amount; l.mux must be held
+func (l *Ledger) pair(from, to uint64, amount Money, now time.Time) {
+	l.lastPair++
+	debit := Money{Units: -amount.Units, Currency: amount.Currency}
+	l.entries = append(l.entries,
+		LedgerEntry{Pair: l.lastPair, Account: from, Amount: debit, Time: now},
+		LedgerEntry{Pair: l.lastPair, Account: to, Amount: amount, Time: now},
+	)
+}
+
+// Entries returns a copy of the entries, oldest first
+func (l *Ledger) Entries() []LedgerEntry {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return slices.Clone(l.entries)
+}
+
+// Balances returns the net movement of every account in the ledger, per currency; the
+// fees collected are under FeeAccount
+func (l *Ledger) Balances() map[uint64]map[string]Money {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	balances := make(map[uint64]map[string]Money)
+	for _, entry := range l.entries {
+		if balances[entry.Account] == nil {
+			balances[entry.Account] = make(map[string]Money)
+		}
+		sum, _ := balances[entry.Account][entry.Amount.Currency].Add(entry.Amount)
+		balances[entry.Account][entry.Amount.Currency] = sum
+	}
+	return balances
+}
+
+// Check verifies the invariants of the ledger: the entries of every currency sum to
+// zero, every transfer has exactly a debit and a credit of the same amount, and every
+// account has its opening balance plus its entries. A deposit, withdrawal or transfer
+// that didn't go through the ledger breaks the last one.
+func (l *Ledger) Check() error {
+	// The accounts are locked before the ledger and in the order of Transfer, which takes
+	// the ledger with both of its accounts locked: no transfer is half applied meanwhile
+	l.mux.RLock()
+	accounts := make([]*Account, 0, len(l.accounts))
+	for _, account := range l.accounts {
+		accounts = append(accounts, account)
+	}
+	l.mux.RUnlock()
+	slices.SortFunc(accounts, func(a, b *Account) int { return cmp.Compare(a.ID(), b.ID()) })
+	for _, account := range accounts {
+		account.mux.RLock()
+		defer account.mux.RUnlock()
+	}
+
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	sums := make(map[string]Money)
+	net := make(map[uint64]Money)
+	for i, entry := range l.entries {
+		sum, err := sums[entry.Amount.Currency].Add(entry.Amount)
+		if err != nil {
+			return fmt.Errorf("ledger: entry %d: %w", i, err)
+		}
+		sums[entry.Amount.Currency] = sum
+		net[entry.Account], _ = net[entry.Account].Add(entry.Amount)
+		// The two halves of a pair are always appended together, debit first
+		if i%2 == 1 {
+			debit := l.entries[i-1]
+			if debit.Pair != entry.Pair || debit.Amount.Units != -entry.Amount.Units || debit.Amount.Currency != entry.Amount.Currency {
+				return fmt.Errorf("ledger: entries %d and %d are not the two halves of a transfer", i-1, i)
+			}
+		}
+	}
+	if len(l.entries)%2 != 0 {
+		return fmt.Errorf("ledger: %d entries, the last transfer has a single half", len(l.entries))
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("ledger: the %s entries sum to %s, not zero", currency, sum)
+		}
+	}
+	for _, account := range accounts {
+		want, _ := l.opening[account.ID()].Add(net[account.ID()])
+		if account.balance != want {
+			return fmt.Errorf("ledger: account %d has %s, the ledger says %s", account.ID(), account.balance, want)
+		}
+	}
+	return nil
+}
+
+// Watch starts a goroutine checking the ledger every interval until ctx is done
+// The violations are sent on the returned channel, closed when the goroutine stops;
+// the channel is buffered by one and a violation is dropped if the previous one wasn't read
+func (l *Ledger) Watch(ctx context.Context, interval time.Duration) <-chan error {
+	violations := make(chan error, 1)
+	go func() {
+		defer close(violations)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := l.Check(); err != nil {
+				select {
+				case violations <- err:
+				default:
+				}
+			}
+		}
+	}()
+	return violations
+}
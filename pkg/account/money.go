@@ -0,0 +1,168 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in a currency, counted in minor units: cents for USD, yen for JPY
+// Never use float64 for money: 0.1 + 0.2 is not 0.3 in binary floating point, and the
+// errors pile up over millions of operations. An integer number of cents is exact,
+// the decimal point only exists in ParseMoney and String
+// The zero Money has no currency and is zero in every currency, so the zero Account
+// still works: its first deposit gives it a currency
+type Money struct {
+	Units    int64  // Minor units, 1234 is 12.34 USD
+	Currency string // ISO 4217 code, like "USD"
+}
+
+var (
+	// ErrCurrencyMismatch is returned when two amounts of different currencies meet
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrInvalidMoney is returned by ParseMoney for a malformed amount or unknown currency
+	ErrInvalidMoney = errors.New("invalid money")
+	// ErrMoneyOverflow is returned when an amount doesn't fit in 64 bits
+	ErrMoneyOverflow = errors.New("money overflow")
+)
+
+// currencyDigits is the number of digits of the minor unit of the known currencies
+var currencyDigits = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"JPY": 0,
+	"KWD": 3,
+}
+
+// NewMoney returns units minor units of currency: NewMoney(1234, "USD") is 12.34 USD
+func NewMoney(units int64, currency string) Money {
+	return Money{Units: units, Currency: currency}
+}
+
+// ParseMoney reads an amount like "12.34 USD" or "-5 JPY"
+// The amount can't have more decimals than the minor unit of its currency: "0.001 USD"
+// is refused rather than rounded
+func ParseMoney(s string) (Money, error) {
+	amount, currency, found := strings.Cut(strings.TrimSpace(s), " ")
+	if !found {
+		return Money{}, fmt.Errorf("%w %q: want an amount and a currency", ErrInvalidMoney, s)
+	}
+	digits, known := currencyDigits[currency]
+	if !known {
+		return Money{}, fmt.Errorf("%w %q: unknown currency %q", ErrInvalidMoney, s, currency)
+	}
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if len(fraction) > digits {
+		return Money{}, fmt.Errorf("%w %q: %s has %d decimals", ErrInvalidMoney, s, currency, digits)
+	}
+	sign := ""
+	if rest, negative := strings.CutPrefix(whole, "-"); negative {
+		sign, whole = "-", rest
+	}
+	// Every character must be a digit, ParseInt alone would accept a second sign
+	number := whole + fraction + strings.Repeat("0", digits-len(fraction))
+	if whole == "" || strings.Trim(number, "0123456789") != "" {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidMoney, s)
+	}
+	units, err := strconv.ParseInt(sign+number, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return Money{}, fmt.Errorf("%w: %q", ErrMoneyOverflow, s)
+	}
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidMoney, s)
+	}
+	return Money{Units: units, Currency: currency}, nil
+}
+
+// String formats the amount like ParseMoney reads it, "12.34 USD"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return strconv.FormatInt(m.Units, 10)
+	}
+	digits, known := currencyDigits[m.Currency]
+	if !known {
+		digits = 2
+	}
+	sign := ""
+	// The absolute value as uint64, -math.MinInt64 doesn't fit in an int64
+	abs := uint64(m.Units)
+	if m.Units < 0 {
+		sign, abs = "-", -abs
+	}
+	number := strconv.FormatUint(abs, 10)
+	if digits == 0 {
+		return sign + number + " " + m.Currency
+	}
+	number = strings.Repeat("0", max(digits+1-len(number), 0)) + number
+	split := len(number) - digits
+	return sign + number[:split] + "." + number[split:] + " " + m.Currency
+}
+
+// MarshalText formats the amount with String, so JSON has "12.34 USD"
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText reads an amount with ParseMoney, or "0", the String of a zero Money
+// without currency
+func (m *Money) UnmarshalText(text []byte) error {
+	if string(text) == "0" {
+		*m = Money{}
+		return nil
+	}
+	money, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// IsZero reports whether the amount is zero, in any currency
+func (m Money) IsZero() bool {
+	return m.Units == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Units < 0
+}
+
+// currency returns the currency of an operation between m and other, or ErrCurrencyMismatch;
+// a zero Money without currency takes the currency of the other side
+func (m Money) currency(other Money) (string, error) {
+	switch {
+	case m.Currency == other.Currency:
+		return m.Currency, nil
+	case m.Currency == "" && m.Units == 0:
+		return other.Currency, nil
+	case other.Currency == "" && other.Units == 0:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// Add returns m + other, or ErrCurrencyMismatch or ErrMoneyOverflow
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currency(other)
+	if err != nil {
+		return Money{}, err
+	}
+	if (other.Units > 0 && m.Units > math.MaxInt64-other.Units) ||
+		(other.Units < 0 && m.Units < math.MinInt64-other.Units) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrMoneyOverflow, m, other)
+	}
+	return Money{Units: m.Units + other.Units, Currency: currency}, nil
+}
+
+// Sub returns m - other, or ErrCurrencyMismatch or ErrMoneyOverflow
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Units == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrMoneyOverflow, m, other)
+	}
+	return m.Add(Money{Units: -other.Units, Currency: other.Currency})
+}
@@ -0,0 +1,72 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Arcanm/go_advanced_course/pkg/notify"
+)
+
+// The email and SMS clients of 02-DesignPatterns/Observer, observing AccountEvent instead
+// of ItemEvent; they deliver through the Sender and SMSProvider of pkg/notify.
+
+// EmailClient represents a client that will receive email notifications
+// Implements the Observer[AccountEvent] interface
+type EmailClient struct {
+	id     string        // Client's email
+	sender notify.Sender // How the email is delivered, ConsoleSender when nil
+	from   string        // Sender address
+}
+
+// NewEmailClient creates a client whose notifications are delivered by sender
+func NewEmailClient(address, from string, sender notify.Sender) *EmailClient {
+	return &EmailClient{id: address, sender: sender, from: from}
+}
+
+// UpdateValue sends the event by email, the kind of event is the subject
+func (e *EmailClient) UpdateValue(ctx context.Context, event AccountEvent) error {
+	if !strings.Contains(e.id, "@") {
+		return fmt.Errorf("invalid email address %q", e.id)
+	}
+	var sender notify.Sender = notify.ConsoleSender{}
+	if e.sender != nil {
+		sender = e.sender
+	}
+	return sender.Send(ctx, notify.Message{From: e.from, To: []string{e.id}, Subject: string(event.Kind), Body: event.String()})
+}
+
+// GetId returns the client's email
+func (e EmailClient) GetId() string {
+	return e.id
+}
+
+// SmsClient represents a client that will receive SMS notifications
+// Implements the Observer[AccountEvent] interface
+type SmsClient struct {
+	id       string             // Client's phone number
+	from     string             // Sender number
+	provider notify.SMSProvider // How the SMS is delivered, ConsoleSMS when nil
+}
+
+// NewSmsClient creates a client whose notifications are delivered by provider
+func NewSmsClient(number, from string, provider notify.SMSProvider) *SmsClient {
+	return &SmsClient{id: number, from: from, provider: provider}
+}
+
+// UpdateValue sends the event by SMS
+func (s *SmsClient) UpdateValue(ctx context.Context, event AccountEvent) error {
+	if !strings.HasPrefix(s.id, "+") {
+		return fmt.Errorf("phone number %q must include the country code", s.id)
+	}
+	var provider notify.SMSProvider = notify.ConsoleSMS{}
+	if s.provider != nil {
+		provider = s.provider
+	}
+	return provider.SendSMS(ctx, s.from, s.id, event.String())
+}
+
+// GetId returns the client's phone number
+func (s SmsClient) GetId() string {
+	return s.id
+}
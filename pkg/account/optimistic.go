@@ -0,0 +1,104 @@
+package account
+
+import "sync/atomic"
+
+// OptimisticAccount never waits for a lock: it reads the balance and its version, computes
+// the new balance, and commits it only if the version is still the one it read. If another
+// goroutine committed meanwhile, the commit fails and the update starts again from the new
+// balance
+// Locking (pessimistic) assumes conflicts are likely and prevents them; the optimistic way
+// assumes they are rare and detects them. With little contention nobody waits nor retries;
+// with a lot, the updates keep redoing their work, see the benchmarks of
+// 01-Concurrency/RaceConditions-Mutex
+// The version, not the balance, tells whether something changed: a balance that went from
+// 100 to 50 and back to 100 between the read and the commit still fails the commit. It is
+// what databases do with a version column, and what lets the update depend on more than the
+// balance alone
+type OptimisticAccount struct {
+	// state is replaced as a whole, never modified: a reader always sees a balance with
+	// its own version
+	state     atomic.Pointer[accountState]
+	conflicts atomic.Int64
+}
+
+// accountState is a committed balance
+type accountState struct {
+	balance Money
+	version uint64
+}
+
+// NewOptimisticAccount creates an account with an initial balance, at version 0
+func NewOptimisticAccount(initial Money) *OptimisticAccount {
+	a := &OptimisticAccount{}
+	a.state.Store(&accountState{balance: initial})
+	return a
+}
+
+// Read returns the balance and its version
+func (a *OptimisticAccount) Read() (Money, uint64) {
+	state := a.state.Load()
+	return state.balance, state.version
+}
+
+// Commit replaces the balance if its version is still version, and reports whether it did
+func (a *OptimisticAccount) Commit(version uint64, balance Money) bool {
+	current := a.state.Load()
+	if current.version != version {
+		return false
+	}
+	return a.state.CompareAndSwap(current, &accountState{balance: balance, version: version + 1})
+}
+
+// Update commits update(balance) on the current balance, retrying after every conflict;
+// update must not have side effects, it may run several times. An error of update
+// leaves the balance as it is
+func (a *OptimisticAccount) Update(update func(balance Money) (Money, error)) error {
+	for {
+		balance, version := a.Read()
+		next, err := update(balance)
+		if err != nil {
+			return err
+		}
+		if a.Commit(version, next) {
+			return nil
+		}
+		a.conflicts.Add(1)
+	}
+}
+
+// Deposit adds the given amount to the balance, or returns ErrInvalidAmount or
+// ErrCurrencyMismatch
+func (a *OptimisticAccount) Deposit(amount Money) error {
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	return a.Update(func(balance Money) (Money, error) {
+		return balance.Add(amount)
+	})
+}
+
+// Withdraw subtracts the given amount from the balance, or returns ErrInsufficientFunds,
+// ErrInvalidAmount or ErrCurrencyMismatch
+func (a *OptimisticAccount) Withdraw(amount Money) error {
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	return a.Update(func(balance Money) (Money, error) {
+		next, err := balance.Sub(amount)
+		if err == nil && next.IsNegative() {
+			return next, ErrInsufficientFunds
+		}
+		return next, err
+	})
+}
+
+// Balance returns the current balance
+func (a *OptimisticAccount) Balance() Money {
+	balance, _ := a.Read()
+	return balance
+}
+
+// Conflicts returns the number of failed commits retried by Update
+func (a *OptimisticAccount) Conflicts() int64 {
+	return a.conflicts.Load()
+}
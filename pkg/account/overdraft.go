@@ -0,0 +1,91 @@
+package account
+
+import "fmt"
+
+// An account can be allowed to go below zero, down to its overdraft limit, and charged a
+// fee each time a withdrawal leaves it overdrawn. How the fee is computed is a strategy
+// (see 02-DesignPatterns/Strategy): the account only knows the FeePolicy interface, and a
+// bank can swap a flat fee for a percentage without touching the account
+// The withdrawal, the fee and the check of the limit happen under the same lock, so two
+// goroutines can't both see room in the overdraft and both use it
+
+// FeePolicy computes the fee of a withdrawal that leaves the account overdrawn
+type FeePolicy interface {
+	// Fee returns the fee for a withdrawal of amount that leaves the balance overdrawn
+	// below zero, overdrawn being positive; it must be in the currency of the amount
+	// It is called with the lock of the account held, so it must not use the account
+	Fee(overdrawn, amount Money) Money
+}
+
+// FlatFee charges the same fee for every overdrawn withdrawal
+type FlatFee struct {
+	Amount Money
+}
+
+func (f FlatFee) Fee(overdrawn, amount Money) Money {
+	return f.Amount
+}
+
+// PercentFee charges basis points (1 basis point is 0.01%) of the part of the withdrawal
+// below zero, and at least Min; the fraction of a minor unit is dropped, like the interest
+type PercentFee struct {
+	BasisPoints int64
+	Min         Money
+}
+
+func (f PercentFee) Fee(overdrawn, amount Money) Money {
+	// An account already overdrawn has all of the withdrawal below zero
+	below := min(overdrawn.Units, amount.Units)
+	units := below/10_000*f.BasisPoints + below%10_000*f.BasisPoints/10_000
+	return Money{Units: max(units, f.Min.Units), Currency: overdrawn.Currency}
+}
+
+// SetOverdraft allows the account to go down to -limit, charging the fees of policy when it
+// is below zero; a nil policy charges nothing and a zero limit forbids the overdraft again
+// It returns ErrInvalidMoney for a negative limit and ErrCurrencyMismatch for a limit in
+// another currency than the account
+func (a *Account) SetOverdraft(limit Money, policy FeePolicy) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if limit.IsNegative() {
+		return fmt.Errorf("%w: negative overdraft limit %s", ErrInvalidMoney, limit)
+	}
+	if _, err := a.balance.currency(limit); err != nil {
+		return err
+	}
+	a.overdraft, a.fees = limit, policy
+	return nil
+}
+
+// debit computes a withdrawal of amount from balance: the balance after it, and the fee to
+// take after that if it is overdrawn; ErrInsufficientFunds if the result, fee included,
+// is below the overdraft limit. The caller holds the lock; nothing changes
+func (a *Account) debit(balance, amount Money) (after, fee Money, err error) {
+	after, err = balance.Sub(amount)
+	if err != nil || !after.IsNegative() {
+		return after, Money{}, err
+	}
+	if a.fees != nil {
+		overdrawn := Money{Units: -after.Units, Currency: after.Currency}
+		fee = a.fees.Fee(overdrawn, amount)
+	}
+	final, err := after.Sub(fee)
+	if err != nil {
+		return after, fee, err
+	}
+	if final.Units < -a.overdraft.Units {
+		return after, fee, ErrInsufficientFunds
+	}
+	return after, fee, nil
+}
+
+// applyDebit applies a debit computed and checked by debit, recording the operation and
+// its fee; the caller holds the write lock
+func (a *Account) applyDebit(kind OperationKind, amount, after, fee Money) {
+	a.balance = after
+	a.record(kind, amount)
+	if !fee.IsZero() {
+		a.balance, _ = a.balance.Sub(fee)
+		a.record(FeeOperation, fee)
+	}
+}
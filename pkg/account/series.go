@@ -0,0 +1,59 @@
+package account
+
+import (
+	"slices"
+	"time"
+)
+
+// BalancePoint is the balance of an account at a time
+type BalancePoint struct {
+	Time    time.Time
+	Balance Money
+}
+
+// The history already is a snapshot of the balance after every operation, taken under the
+// write lock: the balance at any time is the one after the last operation before it.
+// The history is in time order, so a binary search finds that operation
+
+// BalanceAt returns the balance of the account at t; before the first operation it is the
+// balance the account was opened with
+func (a *Account) BalanceAt(t time.Time) Money {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	if i := a.after(t); i > 0 {
+		return a.history[i-1].Balance
+	}
+	return a.initial
+}
+
+// Series returns how the balance evolved between from and to, both included: the balance
+// at from, then a point for every operation after it up to to. A to before from gives
+// the balance at from alone
+func (a *Account) Series(from, to time.Time) []BalancePoint {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	start := a.initial
+	first := a.after(from)
+	if first > 0 {
+		start = a.history[first-1].Balance
+	}
+	series := []BalancePoint{{Time: from, Balance: start}}
+	if to.Before(from) {
+		return series
+	}
+	for _, operation := range a.history[first:a.after(to)] {
+		series = append(series, BalancePoint{Time: operation.Time, Balance: operation.Balance})
+	}
+	return series
+}
+
+// after returns the index of the first operation after t; the caller holds the lock
+func (a *Account) after(t time.Time) int {
+	i, _ := slices.BinarySearchFunc(a.history, t, func(operation Operation, t time.Time) int {
+		if operation.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	return i
+}
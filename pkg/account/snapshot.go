@@ -0,0 +1,150 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ErrDuplicateAccount is returned by Load for an ID already handed out to an account,
+// or found twice in the snapshot
+var ErrDuplicateAccount = errors.New("duplicate account")
+
+// A snapshot of a bank is a JSON file of its accounts with their balances and histories.
+// Like Totals, Save holds the read locks of every account at once, taken in ID order: a
+// transfer running meanwhile is either in the snapshot on both sides or not at all. The
+// locks are only held to copy the accounts, the file is written after.
+// The overdraft, the observers and the low-balance threshold are settings of the program,
+// not state: they are not saved, the program sets them again after Load.
+
+// savedAccount is the form of an account in a snapshot
+type savedAccount struct {
+	ID      uint64      `json:"id"`
+	Initial Money       `json:"initial"`
+	Balance Money       `json:"balance"`
+	History []Operation `json:"history"`
+}
+
+// bankSnapshot is the content of a snapshot file
+type bankSnapshot struct {
+	Accounts []savedAccount `json:"accounts"`
+}
+
+// snapshot copies the accounts of the bank at one moment
+func (b *Bank) snapshot() bankSnapshot {
+	accounts := b.Accounts()
+	for _, account := range accounts {
+		account.mux.RLock()
+		defer account.mux.RUnlock()
+	}
+	snapshot := bankSnapshot{Accounts: make([]savedAccount, 0, len(accounts))}
+	for _, account := range accounts {
+		snapshot.Accounts = append(snapshot.Accounts, savedAccount{
+			ID:      account.ID(),
+			Initial: account.initial,
+			Balance: account.balance,
+			History: slices.Clone(account.history),
+		})
+	}
+	return snapshot
+}
+
+// Save writes a snapshot of the bank to path atomically: it goes to a temporary file in
+// the same directory, renamed over path once complete, so a crash in the middle leaves
+// the previous snapshot instead of a torn one
+func (b *Bank) Save(path string) error {
+	data, err := json.MarshalIndent(b.snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("bank: encoding snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file was renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	// The data must be on disk before the rename makes it the snapshot
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load adds the accounts of a snapshot written by Save to the bank, with their IDs, and
+// returns how many were added. A missing file returns an error matching os.ErrNotExist,
+// which a first run can ignore.
+// Transfer orders its locks by ID, so two accounts with the same ID could deadlock: the IDs
+// of the snapshot must not have been handed out yet, to an account of any bank, or Load
+// returns ErrDuplicateAccount. Load is meant for the start of the program, before the
+// accounts it creates itself. The snapshot is added whole or not at all
+func (b *Bank) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var snapshot bankSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("bank: reading snapshot: %w", err)
+	}
+
+	accounts := make([]*Account, 0, len(snapshot.Accounts))
+	seen := make(map[uint64]bool, len(snapshot.Accounts))
+	var lowest, highest uint64
+	for _, saved := range snapshot.Accounts {
+		if saved.ID == 0 {
+			return 0, errors.New("bank: reading snapshot: account without ID")
+		}
+		if seen[saved.ID] {
+			return 0, fmt.Errorf("bank: account %d: %w", saved.ID, ErrDuplicateAccount)
+		}
+		seen[saved.ID] = true
+		if lowest == 0 || saved.ID < lowest {
+			lowest = saved.ID
+		}
+		highest = max(highest, saved.ID)
+
+		account := NewAccount(saved.Initial)
+		account.id.Store(saved.ID)
+		account.balance = saved.Balance
+		account.history = saved.History
+		accounts = append(accounts, account)
+	}
+	if len(accounts) == 0 {
+		return 0, nil
+	}
+
+	// Moving lastID past the snapshot reserves its IDs: the accounts created from now on get
+	// IDs after them. CompareAndSwap makes the check and the move one step, so an account
+	// can't get one of them in between
+	for {
+		last := lastID.Load()
+		if last >= lowest {
+			return 0, fmt.Errorf("bank: account %d: %w", lowest, ErrDuplicateAccount)
+		}
+		if lastID.CompareAndSwap(last, highest) {
+			break
+		}
+	}
+
+	// Every stripe is locked, in order, so the accounts appear in the bank all at once
+	for i := range b.stripes {
+		b.stripes[i].mux.Lock()
+		defer b.stripes[i].mux.Unlock()
+	}
+	for _, account := range accounts {
+		b.stripe(account.ID()).accounts[account.ID()] = account
+	}
+	return len(accounts), nil
+}
@@ -0,0 +1,144 @@
+package account
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrTxDone is returned by the methods of a Tx after Commit or Rollback
+var ErrTxDone = errors.New("transaction already committed or rolled back")
+
+// Tx stages operations on accounts and applies them all at once, or none of them
+// A multi-step flow done one call at a time can stop halfway: a transfer that withdrew
+// from one account and then failed to deposit in the other lost the money. Within a Tx,
+// the steps are only written down; Commit locks every account involved, checks that all
+// the steps succeed one after the other, and only then changes the balances. Any step
+// failing, or Rollback, leaves every account as it was
+// A Tx belongs to one goroutine, like the flow it models; the accounts stay safe to use
+// from other goroutines meanwhile
+type Tx struct {
+	account *Account
+	steps   []txStep
+	done    bool
+}
+
+// txStep is a staged change of the balance of an account
+type txStep struct {
+	account *Account
+	kind    OperationKind
+	amount  Money
+}
+
+// Begin starts a transaction on the account; nothing changes until Commit
+func (a *Account) Begin() *Tx {
+	return &Tx{account: a}
+}
+
+// Deposit stages a deposit on the account of the transaction
+func (tx *Tx) Deposit(amount Money) error {
+	return tx.stage(txStep{tx.account, DepositOperation, amount})
+}
+
+// Withdraw stages a withdrawal from the account of the transaction
+func (tx *Tx) Withdraw(amount Money) error {
+	return tx.stage(txStep{tx.account, WithdrawOperation, amount})
+}
+
+// Transfer stages the two halves of a transfer from the account of the transaction to to
+func (tx *Tx) Transfer(to *Account, amount Money) error {
+	if to == tx.account {
+		return ErrSameAccount
+	}
+	if err := tx.stage(txStep{tx.account, TransferOutOperation, amount}); err != nil {
+		return err
+	}
+	return tx.stage(txStep{to, TransferInOperation, amount})
+}
+
+// stage appends a step, unless the transaction is over or the amount is not positive
+func (tx *Tx) stage(step txStep) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if err := checkAmount(step.amount); err != nil {
+		return err
+	}
+	tx.steps = append(tx.steps, step)
+	return nil
+}
+
+// Commit applies the staged steps in order, all or none: it returns the error of the first
+// step that fails, ErrInsufficientFunds or ErrCurrencyMismatch, and then changes nothing.
+// The transaction is over either way
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	// Lock every account once, in ID order like Transfer, so two transactions on the same
+	// accounts can't deadlock
+	accounts := []*Account{tx.account}
+	for _, step := range tx.steps {
+		if !slices.Contains(accounts, step.account) {
+			accounts = append(accounts, step.account)
+		}
+	}
+	slices.SortFunc(accounts, func(a, b *Account) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+	for _, account := range accounts {
+		account.mux.Lock()
+	}
+	defer unlockAll(accounts...)
+
+	// Run the steps on copies of the balances first
+	balances := make(map[*Account]Money, len(accounts))
+	for _, account := range accounts {
+		balances[account] = account.balance
+	}
+	for i, step := range tx.steps {
+		var balance Money
+		var err error
+		switch step.kind {
+		case DepositOperation, TransferInOperation:
+			balance, err = balances[step.account].Add(step.amount)
+		default:
+			var fee Money
+			balance, fee, err = step.account.debit(balances[step.account], step.amount)
+			if err == nil {
+				balance, err = balance.Sub(fee)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("step %d, %s of %s: %w", i+1, step.kind, step.amount, err)
+		}
+		balances[step.account] = balance
+	}
+
+	// Every step succeeded: apply them for real, with their history
+	for _, step := range tx.steps {
+		account := step.account
+		if step.kind == DepositOperation || step.kind == TransferInOperation {
+			account.balance, _ = account.balance.Add(step.amount)
+			account.record(step.kind, step.amount)
+			account.fundsAdded()
+		} else {
+			after, fee, _ := account.debit(account.balance, step.amount)
+			account.applyDebit(step.kind, step.amount, after, fee)
+		}
+	}
+	return nil
+}
+
+// Rollback discards the staged steps; the transaction is over
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.steps = nil
+	return nil
+}
@@ -0,0 +1,58 @@
+package account
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrWaitTimeout is returned by WithdrawWait when the funds didn't come in time
+var ErrWaitTimeout = errors.New("timed out waiting for funds")
+
+// WithdrawWait is Withdraw that waits, up to timeout, for the balance to cover the amount
+// Checking the balance in a loop with time.Sleep would either waste the CPU or react late.
+// A condition variable (sync.Cond) lets the goroutine sleep until another one tells it
+// the state changed: Wait releases the lock and sleeps, and takes the lock again before
+// returning. The condition must be checked again after every wake up, in a loop: the
+// deposit may be too small, or another waiter may have taken the money first
+// sync.Cond has no timeout, so a timer wakes everybody up when it fires and the loop
+// notices that the deadline passed
+func (a *Account) WithdrawWait(amount Money, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	a.mux.Lock()
+	defer a.unlock()
+	if a.funds == nil {
+		// The condition uses the write side of the account lock, created on first use
+		// so the zero Account stays ready to use
+		a.funds = sync.NewCond(&a.mux)
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		a.mux.Lock()
+		defer a.mux.Unlock()
+		a.funds.Broadcast()
+	})
+	defer timer.Stop()
+
+	for {
+		err := a.withdraw(amount)
+		if !errors.Is(err, ErrInsufficientFunds) {
+			// Done, or an error no deposit can fix, like another currency
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return ErrWaitTimeout
+		}
+		a.funds.Wait()
+	}
+}
+
+// fundsAdded wakes up the goroutines in WithdrawWait after the balance grew; the caller
+// holds the write lock
+// Broadcast, not Signal: the waiters want different amounts, waking a single one could
+// wake one that still can't withdraw while another one could
+func (a *Account) fundsAdded() {
+	if a.funds != nil {
+		a.funds.Broadcast()
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// GetMulti returns the results of several keys: the cached ones right away, and the
+// missing ones calculated concurrently, at most GOMAXPROCS at a time. Calling Get in a
+// loop would calculate the misses one after the other.
+// Parameters:
+//   - keys: The inputs to look up, duplicates are calculated once
+//
+// Returns: The results of the keys that succeeded, and the errors of the others joined
+func (m *Memory[K, V]) GetMulti(keys []K) (map[K]V, error) {
+	results := make(map[K]V, len(keys))
+	var misses []K
+	missing := make(map[K]bool)
+
+	// One pass under the lock for the cached keys. The ones still being
+	// calculated by someone else go with the misses: Get waits for them
+	m.mux.Lock()
+	now := m.clock.Now()
+	for _, key := range keys {
+		if _, done := results[key]; done || missing[key] {
+			continue
+		}
+		e, exists := m.cache[key]
+		if exists && e.hasValue() && !e.expired(now) {
+			m.hit(key, e, now)
+			results[key] = e.value
+			continue
+		}
+		missing[key] = true
+		misses = append(misses, key)
+	}
+	m.unlock()
+
+	var errs []error
+	var resultsMux sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for _, key := range misses {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			value, err := m.Get(key)
+			resultsMux.Lock()
+			defer resultsMux.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("key %v: %w", key, err))
+				return
+			}
+			results[key] = value
+		}()
+	}
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,1594 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+	"github.com/Arcanm/go_advanced_course/pkg/connpool"
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// TestTTL checks that results are recalculated after the TTL and that the janitor
+// removes expired entries nobody asks for again. The TTL is an hour of a clock.Fake,
+// so it runs instantly.
+func TestTTL(t *testing.T) {
+	calls := 0
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		calls++
+		return len(key), nil
+	}, WithTTL(time.Hour), WithClock(fake))
+
+	m.Get("a")
+	fake.Advance(59 * time.Minute)
+	m.Get("a")
+	if calls != 1 {
+		t.Fatalf("%d calls before expiring, want 1", calls)
+	}
+	fake.Advance(time.Minute)
+	m.Get("a")
+	if calls != 2 {
+		t.Fatalf("%d calls after expiring, want 2", calls)
+	}
+
+	m.Get("forgotten")
+	for range 2 {
+		// The janitor runs every 30 minutes; wait until it is waiting for
+		// its next tick before moving the clock, or the tick is missed
+		if !eventually(func() bool { return fake.Waiters() > 0 }) {
+			t.Fatal("the janitor is not waiting for the clock")
+		}
+		fake.Advance(30 * time.Minute)
+	}
+	removed := eventually(func() bool {
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		return len(m.cache) == 0
+	})
+	if !removed {
+		t.Error("janitor left expired entries")
+	}
+}
+
+// TestTTLJitter warms many keys at the same instant of a clock.Fake and checks that
+// their expirations are spread over the band instead of all falling on the TTL
+func TestTTLJitter(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	start := fake.Now()
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		return key, nil
+	}, WithTTL(time.Hour), WithTTLJitter(0.5), WithClock(fake))
+	for key := range 100 {
+		m.Get(key)
+	}
+
+	m.mux.Lock()
+	expirations := make(map[time.Time]bool)
+	for _, e := range m.cache {
+		expiresIn := e.expiresAt.Sub(start)
+		if expiresIn < 30*time.Minute || expiresIn > time.Hour {
+			m.mux.Unlock()
+			t.Fatalf("a result expires in %s, want between 30m and 1h", expiresIn)
+		}
+		expirations[e.expiresAt] = true
+	}
+	m.mux.Unlock()
+	if len(expirations) < 50 {
+		t.Fatalf("%d distinct expirations for 100 keys, want them spread", len(expirations))
+	}
+
+	// Half way through the band, some results expired and some didn't
+	fake.Advance(45 * time.Minute)
+	if kept := len(m.Keys()); kept == 0 || kept == 100 {
+		t.Errorf("%d of 100 results valid after 45m, want some of them", kept)
+	}
+}
+
+// TestSweepAndClose checks WithSweepInterval on a clock.Fake, then that Close stops
+// the janitors: creating and closing many caches leaves no goroutine behind
+func TestSweepAndClose(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		return len(key), nil
+	}, WithTTL(time.Hour), WithSweepInterval(time.Minute), WithClock(fake))
+	m.Get("a")
+	// The default interval, 30 minutes, would not sweep at 60 minutes after a sweep at 59
+	for _, step := range []time.Duration{59 * time.Minute, time.Minute} {
+		if !eventually(func() bool { return fake.Waiters() > 0 }) {
+			t.Fatal("the janitor is not waiting for the clock")
+		}
+		fake.Advance(step)
+	}
+	swept := eventually(func() bool {
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		return len(m.cache) == 0
+	})
+	if !swept {
+		t.Fatal("the janitor didn't run every minute")
+	}
+	m.Close()
+	m.Close()
+
+	before := runtime.NumGoroutine()
+	for range 20 {
+		c := NewCacheWithTTL(func(key int, m *Memory[int, int]) (int, error) {
+			return key, nil
+		}, time.Millisecond)
+		c.Get(1)
+		c.Close()
+	}
+	if !eventually(func() bool { return runtime.NumGoroutine() <= before }) {
+		t.Errorf("%d goroutines after closing 20 caches, %d before", runtime.NumGoroutine(), before)
+	}
+}
+
+// TestRefreshAhead reads a key three times and another once, then moves a clock.Fake
+// close to their TTL: the hot key is refreshed before expiring, the cold one expires
+func TestRefreshAhead(t *testing.T) {
+	var mux sync.Mutex
+	calls := make(map[string]int)
+	count := func(key string) int {
+		mux.Lock()
+		defer mux.Unlock()
+		return calls[key]
+	}
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		calls[key]++
+		return calls[key], nil
+	}, WithTTL(time.Hour), WithRefreshAhead(10*time.Minute, 2), WithClock(fake))
+	defer m.Close()
+	for range 3 {
+		m.Get("hot")
+	}
+	m.Get("cold")
+
+	// The janitor runs every 5 minutes, half the window
+	for range 11 {
+		if !eventually(func() bool { return fake.Waiters() > 0 }) {
+			t.Fatal("the janitor is not waiting for the clock")
+		}
+		fake.Advance(5 * time.Minute)
+	}
+	if !eventually(func() bool { return count("hot") == 2 }) || count("cold") != 1 {
+		t.Fatalf("hot calculated %d times and cold %d before the TTL, want 2 and 1",
+			count("hot"), count("cold"))
+	}
+
+	fake.Advance(10 * time.Minute)
+	hot, _ := m.Get("hot")
+	cold, _ := m.Get("cold")
+	if hot != 2 || cold != 2 || count("hot") != 2 {
+		t.Errorf("after the TTL hot = %d and cold = %d, want 2 from the refresh and 2 from a miss", hot, cold)
+	}
+}
+
+// eventually polls condition for up to a second, for the work of other goroutines
+func eventually(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
+
+// TestLRU checks the eviction order, then hammers a small cache from many goroutines
+// and checks that it never holds more than its capacity and that the list matches the map
+func TestLRU(t *testing.T) {
+	calls := make(map[int]int)
+	var callsMux sync.Mutex
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		callsMux.Lock()
+		calls[key]++
+		callsMux.Unlock()
+		return key * key, nil
+	}, WithMaxEntries(3))
+
+	// 1 is used again after 2 and 3, so 2 is the least recently used when 4 arrives
+	for _, key := range []int{1, 2, 3, 1, 4} {
+		m.Get(key)
+	}
+	if got := m.policy.(*lruList[int]).Keys(); !slices.Equal(got, []int{4, 1, 3}) {
+		t.Fatalf("order %v, want [4 1 3]", got)
+	}
+	m.Get(2)
+	if calls[2] != 2 || calls[1] != 1 {
+		t.Fatalf("2 calculated %d times and 1 %d times, want 2 and 1", calls[2], calls[1])
+	}
+	if got := m.policy.(*lruList[int]).Keys(); !slices.Equal(got, []int{2, 4, 1}) {
+		t.Fatalf("order %v after reloading 2, want [2 4 1]", got)
+	}
+
+	m = NewCache(func(key int, m *Memory[int, int]) (int, error) { return key, nil }, WithMaxEntries(8))
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				key := (g*7 + i) % 32
+				if got, _ := m.Get(key); got != key {
+					t.Errorf("got %d for key %d under concurrent use", got, key)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.cache) > 8 || len(m.cache) != m.policy.Len() {
+		t.Fatalf("%d entries and %d keys in the list after concurrent use, want at most 8 of each", len(m.cache), m.policy.Len())
+	}
+	for _, key := range m.policy.(*lruList[int]).Keys() {
+		if _, exists := m.cache[key]; !exists {
+			t.Fatalf("key %d in the list but not in the cache", key)
+		}
+	}
+}
+
+// TestPanic makes the first call of the function panic while 10 callers wait for the
+// key: they must all get ErrPanic instead of waiting forever, and the next Get must call
+// the function again. SyncMemory and Memoize must release their callers the same way
+func TestPanic(t *testing.T) {
+	var calls atomic.Int32
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		if calls.Add(1) == 1 {
+			time.Sleep(20 * time.Millisecond)
+			panic("loader bug")
+		}
+		return key * 2, nil
+	})
+	errs := make(chan error, 10)
+	for range 10 {
+		go func() {
+			_, err := m.Get(21)
+			errs <- err
+		}()
+	}
+	for range 10 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrPanic) {
+				t.Fatalf("Get returned %v, want ErrPanic", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the callers of a panicking key are still waiting")
+		}
+	}
+	if value, err := m.Get(21); value != 42 || err != nil || calls.Load() != 2 {
+		t.Fatalf("Get after the panic returned %d, %v in %d calls, want 42 in 2", value, err, calls.Load())
+	}
+
+	syncCalls := 0
+	sm := NewSyncCache(func(key int, m *SyncMemory[int, int]) (int, error) {
+		if syncCalls++; syncCalls == 1 {
+			panic("loader bug")
+		}
+		return key * 2, nil
+	})
+	if _, err := sm.Get(21); !errors.Is(err, ErrPanic) {
+		t.Fatalf("SyncMemory.Get returned %v, want ErrPanic", err)
+	}
+	if value, err := sm.Get(21); value != 42 || err != nil {
+		t.Fatalf("SyncMemory.Get after the panic returned %d, %v, want 42", value, err)
+	}
+
+	half, _ := Memoize(func(n int) int {
+		if n%2 != 0 {
+			panic("odd input")
+		}
+		return n / 2
+	})
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		half(3)
+	}()
+	if err, ok := recovered.(error); !ok || !errors.Is(err, ErrPanic) {
+		t.Errorf("Memoize raised %v, want ErrPanic", recovered)
+	}
+}
+
+// TestErrors checks that errors reach the caller and are not cached
+func TestErrors(t *testing.T) {
+	calls := 0
+	failing := errors.New("backend down")
+	m := NewCache(func(key string, m *Memory[string, string]) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", failing
+		}
+		return "value of " + key, nil
+	})
+	if _, err := m.Get("k"); !errors.Is(err, failing) {
+		t.Fatalf("got %v, want the error of the function", err)
+	}
+	if value, err := m.Get("k"); err != nil || value != "value of k" || calls != 2 {
+		t.Fatalf("got %q, %v after %d calls, want the function called again", value, err, calls)
+	}
+	if _, err := m.Get("k"); err != nil || calls != 2 {
+		t.Fatalf("the successful result was not cached, %d calls", calls)
+	}
+
+	// An error deep in a recursive function reaches the first caller
+	fib := NewCache(fibonacci)
+	if _, err := fib.Get(-3); err == nil {
+		t.Error("fibonacci of a negative position succeeded")
+	}
+}
+
+// TestSingleflight starts many Gets of the same key at once and checks that the
+// slow function runs a single time and every caller receives its result
+func TestSingleflight(t *testing.T) {
+	var calls atomic.Int32
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return key * 2, nil
+	})
+
+	const callers = 20
+	results := make([]int, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = m.Get(21)
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d calls for concurrent Gets of the same key, want 1", n)
+	}
+	for i, result := range results {
+		if result != 42 {
+			t.Fatalf("caller %d got %d, want 42", i, result)
+		}
+	}
+}
+
+// TestRecursion runs the recursive fibonacci from many goroutines at once, so
+// they wait on each other's entries, and compares the results with a loop
+func TestRecursion(t *testing.T) {
+	m := NewCache(fibonacci)
+	const n = 90
+	results := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 7 and 90 are coprime, so the keys are a shuffled 0..89
+			key := i * 7 % n
+			results[key], errs[key] = m.Get(key)
+		}()
+	}
+	wg.Wait()
+
+	a, b := 0, 1
+	for i := range n {
+		if errs[i] != nil || results[i] != a {
+			t.Fatalf("fibonacci(%d) = %d, %v, want %d", i, results[i], errs[i], a)
+		}
+		a, b = b, a+b
+	}
+}
+
+// TestStats checks the counters after a known sequence of Gets
+func TestStats(t *testing.T) {
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		if key < 0 {
+			return 0, errors.New("negative")
+		}
+		return key, nil
+	}, WithMaxEntries(2))
+	for _, key := range []int{1, 1, 2, 3, 1, -1} {
+		m.Get(key)
+	}
+	// Misses: 1, 2, 3, 1 again (evicted by 3) and -1; the only hit is the second 1
+	stats := m.Stats()
+	if stats.Hits != 1 || stats.Misses != 5 || stats.Errors != 1 || stats.Evictions != 2 || stats.Entries != 2 {
+		t.Fatalf("got %+v, want 1 hit, 5 misses, 1 error, 2 evictions and 2 entries", stats)
+	}
+	if ratio := stats.HitRatio(); ratio < 0.16 || ratio > 0.17 {
+		t.Errorf("hit ratio %.3f, want 1/6", ratio)
+	}
+}
+
+// TestOnEvict checks that the callback receives the results removed by the LRU
+// and by the TTL, and that it can use the cache without deadlocking
+func TestOnEvict(t *testing.T) {
+	var evicted []string
+	var evictedMux sync.Mutex
+	record := func(key string, value int) {
+		evictedMux.Lock()
+		defer evictedMux.Unlock()
+		evicted = append(evicted, fmt.Sprintf("%s=%d", key, value))
+	}
+
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		return len(key), nil
+	}, WithMaxEntries(2))
+	m.OnEvict(func(key string, value int) {
+		record(key, value)
+		m.Stats() // Takes the lock, it would deadlock if the callback ran under it
+	})
+	for _, key := range []string{"a", "bb", "ccc", "dddd"} {
+		m.Get(key)
+	}
+	if !slices.Equal(evicted, []string{"a=1", "bb=2"}) {
+		t.Fatalf("lru evicted %v, want [a=1 bb=2]", evicted)
+	}
+
+	evicted = nil
+	ttl := NewCacheWithTTL(func(key string, m *Memory[string, int]) (int, error) {
+		return len(key), nil
+	}, 20*time.Millisecond)
+	ttl.OnEvict(record)
+	ttl.Get("expiring")
+	time.Sleep(60 * time.Millisecond)
+	evictedMux.Lock()
+	defer evictedMux.Unlock()
+	if !slices.Equal(evicted, []string{"expiring=8"}) {
+		t.Errorf("ttl evicted %v, want [expiring=8]", evicted)
+	}
+}
+
+// TestInvalidation checks Delete, Clear and Keys, and that deleting a key while it
+// is calculated keeps the stale result out of the cache
+func TestInvalidation(t *testing.T) {
+	calls := make(map[string]int)
+	var callsMux sync.Mutex
+	release := make(chan struct{})
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		callsMux.Lock()
+		calls[key]++
+		callsMux.Unlock()
+		if key == "slow" {
+			<-release
+		}
+		return len(key), nil
+	})
+	var evicted []string
+	m.OnEvict(func(key string, value int) { evicted = append(evicted, key) })
+
+	for _, key := range []string{"a", "b", "c"} {
+		m.Get(key)
+	}
+	keys := m.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("keys %v, want [a b c]", keys)
+	}
+	if !m.Delete("b") || m.Delete("b") {
+		t.Fatal("Delete must report true once, then false")
+	}
+	m.Get("b")
+	if calls["b"] != 2 {
+		t.Fatalf("b calculated %d times after Delete, want 2", calls["b"])
+	}
+	m.Clear()
+	slices.Sort(evicted)
+	if len(m.Keys()) != 0 || !slices.Equal(evicted, []string{"a", "b", "b", "c"}) {
+		t.Fatalf("%d keys and evicted %v after Clear, want none and [a b b c]", len(m.Keys()), evicted)
+	}
+
+	done := make(chan int)
+	go func() {
+		value, _ := m.Get("slow")
+		done <- value
+	}()
+	for {
+		callsMux.Lock()
+		started := calls["slow"] == 1
+		callsMux.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Delete("slow")
+	close(release)
+	if value := <-done; value != 4 {
+		t.Fatalf("caller of a deleted key got %d, want 4", value)
+	}
+	m.Get("slow")
+	callsMux.Lock()
+	defer callsMux.Unlock()
+	if calls["slow"] != 2 {
+		t.Errorf("result deleted while calculated was cached, %d calls", calls["slow"])
+	}
+}
+
+// TestStaleWhileRevalidate checks that a stale result is returned without waiting
+// for the slow function, and that a single background refresh replaces it
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		time.Sleep(30 * time.Millisecond)
+		return int(version.Add(1)), nil
+	}, WithStaleWhileRevalidate(20*time.Millisecond))
+
+	m.Get("k")
+	time.Sleep(30 * time.Millisecond)
+	for range 3 {
+		start := time.Now()
+		value, _ := m.Get("k")
+		if elapsed := time.Since(start); value != 1 || elapsed > 15*time.Millisecond {
+			t.Fatalf("stale Get returned %d after %s, want 1 right away", value, elapsed)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if value, _ := m.Get("k"); value != 2 {
+		t.Fatalf("got %d after the refresh, want 2", value)
+	}
+	if refreshes := m.Stats().Refreshes; refreshes != 1 {
+		t.Errorf("%d refreshes, want 1", refreshes)
+	}
+}
+
+// TestBig checks fibonacciBig past the overflow of int against known values
+// and against a loop, and that the cached values are not modified by later sums
+func TestBig(t *testing.T) {
+	m := NewCache(fibonacciBig)
+	known := map[int]string{
+		92:  "7540113804746346429", // The last one that fits in an int64
+		93:  "12200160415121876738",
+		100: "354224848179261915075",
+	}
+	for n, want := range known {
+		if got, err := m.Get(n); err != nil || got.String() != want {
+			t.Fatalf("fibonacci(%d) = %v, %v, want %s", n, got, err, want)
+		}
+	}
+
+	got, err := m.Get(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := big.NewInt(0), big.NewInt(1)
+	for range 1000 {
+		a.Add(a, b)
+		a, b = b, a
+	}
+	if got.Cmp(a) != 0 || len(got.String()) != 209 {
+		t.Fatalf("fibonacci(1000) has %d digits and differs from the loop", len(got.String()))
+	}
+	if again, _ := m.Get(100); again.String() != known[100] {
+		t.Errorf("cached fibonacci(100) changed to %s", again)
+	}
+}
+
+// TestGetMulti checks that the misses of a batch are calculated in parallel,
+// that the cached keys don't call the function and that errors are reported per key
+func TestGetMulti(t *testing.T) {
+	var calls atomic.Int32
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		calls.Add(1)
+		if key < 0 {
+			return 0, errors.New("negative")
+		}
+		time.Sleep(30 * time.Millisecond)
+		return key * 10, nil
+	})
+	m.Get(1)
+
+	start := time.Now()
+	results, err := m.GetMulti([]int{1, 2, 3, 2, -1})
+	elapsed := time.Since(start)
+	if err == nil || !strings.Contains(err.Error(), "key -1") {
+		t.Fatalf("error %v, want the error of key -1", err)
+	}
+	if len(results) != 3 || results[1] != 10 || results[2] != 20 || results[3] != 30 {
+		t.Fatalf("got %v, want 1, 2 and 3", results)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("%d calls, want 4 (1 before, then 2, 3 and -1)", n)
+	}
+	if runtime.GOMAXPROCS(0) > 1 && elapsed > 55*time.Millisecond {
+		t.Errorf("took %s, the misses were not calculated in parallel", elapsed)
+	}
+}
+
+// TestPersistence saves a warm big.Int Fibonacci cache to a file and restores it in a
+// new cache, which must answer without calling the function; expired results are skipped
+func TestPersistence(t *testing.T) {
+	dir, err := os.MkdirTemp("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fibonacci.gob")
+
+	warm := NewCache(fibonacciBig)
+	want, _ := warm.Get(200)
+	if err := warm.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if leftovers, _ := filepath.Glob(path + ".tmp*"); len(leftovers) > 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+
+	restored := NewCache(fibonacciBig)
+	n, err := restored.LoadFile(path)
+	if err != nil || n != 201 {
+		t.Fatalf("restored %d results, %v, want 201", n, err)
+	}
+	got, _ := restored.Get(200)
+	if stats := restored.Stats(); got.Cmp(want) != 0 || stats.Misses != 0 {
+		t.Fatalf("got %s with %d misses, want %s from the snapshot", got, stats.Misses, want)
+	}
+
+	// A result that expires before being restored is skipped
+	short := NewCacheWithTTL(func(key string, m *Memory[string, int]) (int, error) {
+		return len(key), nil
+	}, 20*time.Millisecond)
+	short.Get("soon")
+	var snapshot bytes.Buffer
+	if err := short.SaveTo(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if n, err := NewCache(short.f).LoadFrom(&snapshot); err != nil || n != 0 {
+		t.Errorf("restored %d expired results, %v, want 0", n, err)
+	}
+}
+
+// TestCodecs saves a snapshot of struct results in JSON and reads it back, stores the
+// same structs in a JSON FileStore, and checks that protobuf refuses plain Go values
+func TestCodecs(t *testing.T) {
+	words := NewCache(analyzeWord, WithCodec(JSON))
+	words.Get("concurrency")
+	words.Get("cache")
+	var snapshot bytes.Buffer
+	if err := words.SaveTo(&snapshot); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !json.Valid(snapshot.Bytes()) {
+		t.Fatalf("the JSON snapshot is not JSON: %q", snapshot.String())
+	}
+	restored := NewCache(analyzeWord, WithCodec(JSON))
+	if n, err := restored.LoadFrom(&snapshot); err != nil || n != 2 {
+		t.Fatalf("restored %d results, %v, want 2", n, err)
+	}
+	if stats, _ := restored.Get("cache"); stats != (wordStats{Length: 5, Vowels: 2}) || restored.Stats().Misses != 0 {
+		t.Fatalf("restored %+v, want the saved result", stats)
+	}
+
+	dir, err := os.MkdirTemp("", "codecs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := NewFileStore[string, wordStats](dir, JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Set("go", wordStats{Length: 2, Vowels: 1})
+	if stats, err := files.Get("go"); err != nil || stats.Vowels != 1 {
+		t.Fatalf("JSON FileStore returned %+v, %v", stats, err)
+	}
+
+	if _, err := Protobuf.Marshal(wordStats{}); err == nil {
+		t.Fatal("protobuf encoded a value that is not a proto.Message")
+	}
+	var value wordStats
+	if err := Protobuf.Unmarshal(nil, &value); err == nil {
+		t.Error("protobuf decoded into a value that is not a proto.Message")
+	}
+}
+
+// TestPolicies runs the same kind of sequence through a cache of 3 results with
+// each policy and checks which keys survive
+func TestPolicies(t *testing.T) {
+	cases := []struct {
+		policy Policy
+		gets   []int
+		want   []int
+	}{
+		// 1 is read again, but FIFO evicts it anyway as the first stored
+		{FIFO, []int{1, 2, 3, 1, 4}, []int{2, 3, 4}},
+		// LRU keeps 1 because it was read after 2
+		{LRU, []int{1, 2, 3, 1, 4}, []int{1, 3, 4}},
+		// LFU evicts 3, the only key read once before 4 arrives
+		{LFU, []int{1, 1, 2, 2, 3, 4}, []int{1, 2, 4}},
+		// ARC keeps the keys read twice while a scan reads 10 to 15 once
+		{ARC, []int{1, 1, 2, 2, 10, 11, 12, 13, 14, 15}, []int{1, 2, 15}},
+		// A miss on a ghost of the scan makes ARC give more room to the recent keys
+		{ARC, []int{1, 1, 2, 2, 10, 11, 12, 13, 14, 15, 14}, []int{2, 14, 15}},
+	}
+	for _, c := range cases {
+		m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+			return key, nil
+		}, WithMaxEntries(3), WithPolicy(c.policy))
+		for _, key := range c.gets {
+			m.Get(key)
+		}
+		keys := m.Keys()
+		slices.Sort(keys)
+		if !slices.Equal(keys, c.want) || m.policy.Len() != len(keys) {
+			t.Fatalf("%s kept %v and tracks %d keys after %v, want %v", c.policy, keys, m.policy.Len(), c.gets, c.want)
+		}
+	}
+
+	// Concurrent use, with deletes, must keep every policy in sync with the map
+	for _, policy := range []Policy{LRU, LFU, FIFO, ARC} {
+		m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+			return key, nil
+		}, WithMaxEntries(8), WithPolicy(policy))
+		var wg sync.WaitGroup
+		for g := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 500 {
+					key := (g*13 + i*i) % 32
+					if i%50 == 0 {
+						m.Delete(key)
+						continue
+					}
+					m.Get(key)
+				}
+			}()
+		}
+		wg.Wait()
+		m.mux.Lock()
+		entries, tracked := len(m.cache), m.policy.Len()
+		m.mux.Unlock()
+		if entries > 8 || entries != tracked {
+			t.Fatalf("%s has %d entries and tracks %d keys, want the same, at most 8", policy, entries, tracked)
+		}
+	}
+}
+
+// TestMaxCost bounds a cache of strings by their total length: the least recently used
+// strings leave to make room, and a string longer than the bound is never kept
+func TestMaxCost(t *testing.T) {
+	m := NewCache(func(n int, m *Memory[int, string]) (string, error) {
+		return strings.Repeat("x", n), nil
+	}, WithMaxCost(100))
+	m.SetCost(func(n int, value string) int64 {
+		return int64(len(value))
+	})
+	for _, n := range []int{40, 30, 20, 40, 25} {
+		m.Get(n)
+	}
+	// 40+30+20+25 is over 100: 30, the least recently used, left
+	keys := m.Keys()
+	slices.Sort(keys)
+	stats := m.Stats()
+	if !slices.Equal(keys, []int{20, 25, 40}) || stats.Cost != 85 || stats.Evictions != 1 {
+		t.Fatalf("kept %v costing %d after %d evictions, want [20 25 40] costing 85 after 1",
+			keys, stats.Cost, stats.Evictions)
+	}
+	if value, _ := m.Get(150); len(value) != 150 {
+		t.Fatal("a result too big to cache was not returned")
+	}
+	if keys := m.Keys(); len(keys) != 3 || m.Stats().Cost != 85 {
+		t.Fatalf("a result too big to cache changed the cache to %v", keys)
+	}
+	m.Delete(40)
+	if cost := m.Stats().Cost; cost != 45 {
+		t.Errorf("%d after a Delete, want 45", cost)
+	}
+}
+
+// TestSetCost changes the cost function of a full cache: the stored results are measured
+// again, the ones over the bound alone leave first, then the least recently used ones
+func TestSetCost(t *testing.T) {
+	m := NewCache(func(n int, m *Memory[int, string]) (string, error) {
+		return strings.Repeat("x", n), nil
+	}, WithMaxCost(100))
+	for _, n := range []int{10, 50, 20, 30, 110} {
+		m.Get(n)
+	}
+	if stats := m.Stats(); stats.Entries != 5 || stats.Cost != 5 {
+		t.Fatalf("%d results costing %d, want 5 costing 1 each", stats.Entries, stats.Cost)
+	}
+
+	m.SetCost(func(n int, value string) int64 {
+		return int64(len(value))
+	})
+	// 110 can't fit alone; 10+50+20+30 is over 100, so 10 leaves
+	keys := m.Keys()
+	slices.Sort(keys)
+	if stats := m.Stats(); !slices.Equal(keys, []int{20, 30, 50}) || stats.Cost != 100 || stats.Evictions != 2 {
+		t.Fatalf("kept %v costing %d after %d evictions, want [20 30 50] costing 100 after 2",
+			keys, stats.Cost, stats.Evictions)
+	}
+
+	m.SetCost(nil)
+	if stats := m.Stats(); stats.Entries != 3 || stats.Cost != 3 {
+		t.Errorf("%d results costing %d after SetCost(nil), want 3 costing 3", stats.Entries, stats.Cost)
+	}
+}
+
+// TestNegativeTTL checks that an error is returned from the cache during the
+// negative TTL and that the function is called again once it expires
+func TestNegativeTTL(t *testing.T) {
+	var calls atomic.Int32
+	failing := errors.New("backend down")
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		if calls.Add(1) < 3 {
+			return 0, failing
+		}
+		return len(key), nil
+	}, WithNegativeTTL(30*time.Second), WithMaxEntries(2), WithClock(fake))
+
+	for range 5 {
+		if _, err := m.Get("k"); !errors.Is(err, failing) {
+			t.Fatalf("got %v, want the cached error", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d calls during the negative TTL, want 1", n)
+	}
+	if len(m.Keys()) != 0 || m.policy.Len() != 0 {
+		t.Fatal("a cached error counts as a result")
+	}
+	fake.Advance(30 * time.Second)
+	m.Get("k") // Fails a second time, cached again
+	fake.Advance(30 * time.Second)
+	if value, err := m.Get("k"); err != nil || value != 1 || calls.Load() != 3 {
+		t.Errorf("got %d, %v after %d calls, want 1 after 3 calls", value, err, calls.Load())
+	}
+}
+
+// TestDirectAccess checks GetOrSet and CompareAndSwap, then builds a counter with
+// a CompareAndSwap loop from many goroutines, which must not lose any increment
+func TestDirectAccess(t *testing.T) {
+	var calls atomic.Int32
+	m := NewCache(func(key string, m *Memory[string, int]) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	if actual, loaded := m.GetOrSet("a", 1); actual != 1 || loaded {
+		t.Fatalf("first GetOrSet returned %d, %t, want 1, false", actual, loaded)
+	}
+	if actual, loaded := m.GetOrSet("a", 2); actual != 1 || !loaded {
+		t.Fatalf("second GetOrSet returned %d, %t, want 1, true", actual, loaded)
+	}
+	if m.CompareAndSwap("a", 5, 6) || m.CompareAndSwap("missing", 0, 1) {
+		t.Fatal("CompareAndSwap swapped a different or missing value")
+	}
+	if !m.CompareAndSwap("a", 1, 10) {
+		t.Fatal("CompareAndSwap didn't swap the current value")
+	}
+	if value, _ := m.Get("a"); value != 10 || calls.Load() != 0 {
+		t.Fatalf("Get returned %d after %d calls, want 10 without calling the function", value, calls.Load())
+	}
+
+	m.GetOrSet("counter", 0)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				current, _ := m.Get("counter")
+				if m.CompareAndSwap("counter", current, current+1) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _ := m.Get("counter"); value != 50 {
+		t.Errorf("counter is %d after 50 increments", value)
+	}
+}
+
+// TestExpiry gives keys their own deadlines with SetUntil and ExpireAt on a clock.Fake,
+// and removes them with DeleteExpired, in a cache without a TTL nor a janitor
+func TestExpiry(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, string]) (string, error) {
+		return "", errors.New("missing")
+	}, WithClock(fake))
+	start := fake.Now()
+
+	m.SetUntil("short", "a", start.Add(time.Second))
+	m.SetUntil("forever", "b", time.Time{})
+	m.Set("plain", "c")
+	if at, ok := m.Expiry("short"); !ok || !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("got %v, %t for short, want %v, true", at, ok, start.Add(time.Second))
+	}
+	if at, ok := m.Expiry("forever"); !ok || !at.IsZero() {
+		t.Fatalf("got %v, %t for forever, want the zero time, true", at, ok)
+	}
+	if _, ok := m.Expiry("missing"); ok {
+		t.Fatal("got a deadline for a missing key")
+	}
+	if m.ExpireAt("missing", start.Add(time.Hour)) {
+		t.Fatal("ExpireAt changed a missing key")
+	}
+	if !m.ExpireAt("plain", start.Add(2*time.Second)) {
+		t.Fatal("ExpireAt didn't change plain")
+	}
+
+	fake.Advance(time.Second)
+	if value, err := m.Get("short"); err == nil {
+		t.Fatalf("got %q after its deadline, want an error", value)
+	}
+	if got, want := len(m.Keys()), 2; got != want {
+		t.Fatalf("got %d keys, want %d", got, want)
+	}
+	fake.Advance(time.Second)
+	if removed := m.DeleteExpired(); removed != 1 {
+		t.Fatalf("DeleteExpired removed %d entries, want 1", removed)
+	}
+	if value, err := m.Get("forever"); err != nil || value != "b" {
+		t.Fatalf("got %q, %v, want %q", value, err, "b")
+	}
+}
+
+// TestMaxConcurrentLoads misses 12 keys at once with 3 slots, and checks that no
+// more than 3 calls ran together and that every Get got its result
+func TestMaxConcurrentLoads(t *testing.T) {
+	var running, peak atomic.Int32
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n) // Racy between goroutines, but it never records more than ran
+		}
+		time.Sleep(10 * time.Millisecond)
+		return -key, nil
+	}, WithMaxConcurrentLoads(3))
+
+	results, err := m.GetMulti([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+	if err != nil || len(results) != 12 {
+		t.Fatalf("got %d results, %v, want 12", len(results), err)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("%d calls ran at once, want at most 3", p)
+	}
+}
+
+// countingObserver counts the events it receives by kind
+type countingObserver struct {
+	id     string
+	counts map[EventKind]int
+	mux    sync.Mutex
+}
+
+func (o *countingObserver) GetId() string {
+	return o.id
+}
+
+func (o *countingObserver) UpdateValue(_ context.Context, event Event[int, int]) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.counts[event.Kind]++
+	return nil
+}
+
+// TestSubscribe checks the events received by an observer of everything and by one
+// filtering the evictions, and that an unsubscribed observer receives nothing more
+func TestSubscribe(t *testing.T) {
+	m := NewCache(func(key int, m *Memory[int, int]) (int, error) {
+		return key, nil
+	}, WithMaxEntries(2))
+	all := &countingObserver{id: "all", counts: make(map[EventKind]int)}
+	evictions := &countingObserver{id: "evictions", counts: make(map[EventKind]int)}
+	m.Subscribe(all)
+	m.Subscribe(evictions, observer.WithFilter(func(e Event[int, int]) bool { return e.Kind == EventEvict }))
+
+	// Misses 1, 2, 3 (evicts 1); hit 3; Delete 2 evicts it
+	for _, key := range []int{1, 2, 3, 3} {
+		m.Get(key)
+	}
+	m.Delete(2)
+	want := map[EventKind]int{EventMiss: 3, EventHit: 1, EventEvict: 2}
+	if !maps.Equal(all.counts, want) {
+		t.Fatalf("observer got %v, want %v", all.counts, want)
+	}
+	if !maps.Equal(evictions.counts, map[EventKind]int{EventEvict: 2}) {
+		t.Fatalf("filtered observer got %v, want only 2 evictions", evictions.counts)
+	}
+
+	m.Unsubscribe(all)
+	m.Get(3)
+	if all.counts[EventHit] != 1 {
+		t.Error("unsubscribed observer still receives events")
+	}
+}
+
+// TestLayered checks the read-through and write-through of a two-tier cache over a
+// MapStore, then a FileStore shared by two caches: the second one never calculates
+func TestLayered(t *testing.T) {
+	var calls atomic.Int32
+	square := func(key int, m *Memory[int, int]) (int, error) {
+		calls.Add(1)
+		return key * key, nil
+	}
+	store := NewMapStore[int, int]()
+	l := NewLayered(square, store)
+	if value, _ := l.Get(4); value != 16 || calls.Load() != 1 {
+		t.Fatalf("got %d after %d calls, want 16 after 1", value, calls.Load())
+	}
+	if value, err := store.Get(4); err != nil || value != 16 {
+		t.Fatalf("the store has %d, %v, want 16", value, err)
+	}
+	// A cold front finds the value in the store
+	if value, _ := NewLayered(square, store).Get(4); value != 16 || calls.Load() != 1 {
+		t.Fatalf("cold front got %d after %d calls, want 16 from the store", value, calls.Load())
+	}
+	l.Set(5, 99)
+	if value, _ := store.Get(5); value != 99 {
+		t.Fatalf("Set didn't write through, the store has %d", value)
+	}
+	l.Delete(4)
+	if _, err := store.Get(4); !errors.Is(err, ErrNotFound) || len(l.Front().Keys()) != 1 {
+		t.Fatal("Delete didn't remove the key from both layers")
+	}
+
+	dir, err := os.MkdirTemp("", "layered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := NewFileStore[int, *big.Int](dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewLayered(fibonacciBig, files).Get(150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewLayered(func(n int, m *Memory[int, *big.Int]) (*big.Int, error) {
+		return nil, errors.New("the function must not be called, the files have every value")
+	}, files)
+	if got, err := other.Get(150); err != nil || got.Cmp(want) != 0 {
+		t.Errorf("second process got %v, %v, want %s", got, err, want)
+	}
+}
+
+// flakyStore is a MapStore whose writes fail while failures is positive, counting them
+type flakyStore[K comparable, V any] struct {
+	*MapStore[K, V]
+	failures atomic.Int32
+	writes   atomic.Int32
+}
+
+func (s *flakyStore[K, V]) Set(key K, value V) error {
+	s.writes.Add(1)
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("store unavailable")
+	}
+	return s.MapStore.Set(key, value)
+}
+
+// TestWriteBehind checks that Set doesn't wait for the store, that the writes of a key
+// are coalesced, that failed writes are retried and that Close flushes the queue
+func TestWriteBehind(t *testing.T) {
+	store := &flakyStore[int, int]{MapStore: NewMapStore[int, int]()}
+	square := func(key int, m *Memory[int, int]) (int, error) { return key * key, nil }
+	l := NewLayeredWriteBehind(square, store, WriteBehindOptions{Interval: time.Hour, Backoff: time.Millisecond},
+		WithMaxEntries(1))
+
+	for value := range 10 {
+		l.Set(1, value)
+	}
+	if _, err := store.Get(1); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Set wrote to the store before the flush")
+	}
+	// 2 evicts 1 from the front, whose value must come from the queue, not the store
+	l.Get(2)
+	if value, _ := l.Get(1); value != 9 {
+		t.Fatalf("evicted key read %d, want the queued 9", value)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if value, _ := store.Get(1); value != 9 || store.writes.Load() != 2 {
+		t.Fatalf("store has %d after %d writes, want 9 after 2", value, store.writes.Load())
+	}
+
+	// Two failures are absorbed by the retries of the same flush
+	store.failures.Store(2)
+	l.Set(3, 30)
+	if err := l.Flush(); err != nil {
+		t.Fatalf("retried write failed: %v", err)
+	}
+	if value, _ := store.Get(3); value != 30 {
+		t.Fatal("the retried write is missing")
+	}
+
+	// More failures than retries keep the write queued for the next flush
+	store.failures.Store(4)
+	l.Set(4, 40)
+	if err := l.Flush(); err == nil {
+		t.Fatal("a flush failing every retry reported no error")
+	}
+	l.Delete(2)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if value, _ := store.Get(4); value != 40 {
+		t.Fatal("Close didn't flush the failed write")
+	}
+	if _, err := store.Get(2); !errors.Is(err, ErrNotFound) {
+		t.Fatal("Close didn't flush the queued Delete")
+	}
+	// After Close the writes go to the store directly
+	l.Set(5, 50)
+	if value, _ := store.Get(5); value != 50 {
+		t.Error("Set after Close didn't write through")
+	}
+}
+
+// TestRemoteStores runs a Layered big.Int Fibonacci over RedisStore and MemcachedStore,
+// against the fake servers of fakeservers_test.go: a second cache must read every value from
+// the server, and concurrent reads must stay within the connections of the pool
+func TestRemoteStores(t *testing.T) {
+	type remote struct {
+		name  string
+		serve func(r *bufio.Reader, w *bufio.Writer, data *fakeValues) error
+		store func(addr string) (Store[int, *big.Int], *connpool.Pool)
+	}
+	remotes := []remote{
+		{"redis", fakeRedis, func(addr string) (Store[int, *big.Int], *connpool.Pool) {
+			s := NewRedisStore[int, *big.Int](addr, "fib:", time.Minute, 4, nil)
+			return s, s.pool
+		}},
+		{"memcached", fakeMemcached, func(addr string) (Store[int, *big.Int], *connpool.Pool) {
+			s := NewMemcachedStore[int, *big.Int](addr, "fib:", time.Minute, 4, JSON)
+			return s, s.pool
+		}},
+	}
+	for _, r := range remotes {
+		t.Run(r.name, func(t *testing.T) {
+			addr, stop, err := serveFake(r.serve)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stop()
+			store, pool := r.store(addr)
+			defer pool.Close()
+
+			want, err := NewLayered(fibonacciBig, store).Get(120)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other := NewLayered(func(n int, m *Memory[int, *big.Int]) (*big.Int, error) {
+				return nil, errors.New("the function must not be called, the server has every value")
+			}, store)
+			var wg sync.WaitGroup
+			errs := make([]error, 16)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := other.Get(120 - i)
+					if err == nil && i == 0 && got.Cmp(want) != 0 {
+						err = fmt.Errorf("got %s, want %s", got, want)
+					}
+					errs[i] = err
+				}()
+			}
+			wg.Wait()
+			if err := errors.Join(errs...); err != nil {
+				t.Fatal(err)
+			}
+			if open := pool.Stats(addr).Open; open > 4 {
+				t.Errorf("%d connections open, want at most 4", open)
+			}
+			if err := store.Delete(120); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(120); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v after Delete, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// TestVariants runs the same concurrent Gets, Sets and Deletes on Sharded and SyncMemory,
+// checking that each keeps a single call per key like Memory
+func TestVariants(t *testing.T) {
+	var shardedCalls, syncCalls atomic.Int32
+	sharded := NewSharded(func(key int, m *Memory[int, int]) (int, error) {
+		shardedCalls.Add(1)
+		time.Sleep(time.Millisecond)
+		return key * 2, nil
+	}, 4)
+	syncMap := NewSyncCache(func(key int, m *SyncMemory[int, int]) (int, error) {
+		syncCalls.Add(1)
+		time.Sleep(time.Millisecond)
+		if key < 0 {
+			return 0, errors.New("negative key")
+		}
+		return key * 2, nil
+	})
+
+	const keys, callers = 50, 8
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				sharded.Get(key)
+				syncMap.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+	if shardedCalls.Load() != keys || syncCalls.Load() != keys {
+		t.Fatalf("%d sharded and %d sync.Map calls for %d keys, want one each",
+			shardedCalls.Load(), syncCalls.Load(), keys)
+	}
+	if stats := sharded.Stats(); stats.Entries != keys || stats.Misses != keys || stats.Hits != keys*(callers-1) {
+		t.Fatalf("sharded stats %+v, want %d entries and misses", stats, keys)
+	}
+
+	sharded.Set(7, 100)
+	syncMap.Set(7, 100)
+	if a, _ := sharded.Get(7); a != 100 {
+		t.Fatalf("sharded Get after Set = %d, want 100", a)
+	}
+	if b, _ := syncMap.Get(7); b != 100 {
+		t.Fatalf("sync.Map Get after Set = %d, want 100", b)
+	}
+	if !sharded.Delete(7) || !syncMap.Delete(7) || syncMap.Delete(7) {
+		t.Fatal("Delete didn't report the keys present")
+	}
+
+	// Errors are not cached by SyncMemory either
+	syncMap.Get(-1)
+	syncMap.Get(-1)
+	if n := syncCalls.Load(); n != keys+2 {
+		t.Errorf("%d sync.Map calls after two failures, want %d", n, keys+2)
+	}
+}
+
+// TestMemoize checks that a memoized function runs once per input, from concurrent
+// callers and through recursion
+func TestMemoize(t *testing.T) {
+	var calls atomic.Int32
+	var wrong atomic.Bool
+	square, _ := Memoize(func(n int) int {
+		calls.Add(1)
+		time.Sleep(time.Millisecond)
+		return n * n
+	})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 20 {
+				if square(n) != n*n {
+					wrong.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 20 || wrong.Load() {
+		t.Fatalf("%d calls for 20 inputs (wrong results: %t), want 20", n, wrong.Load())
+	}
+
+	var fibCalls int
+	var fib func(int) int
+	fib, _ = Memoize(func(n int) int {
+		fibCalls++
+		if n <= 1 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	})
+	if value := fib(90); value != 2880067194370816120 || fibCalls != 91 {
+		t.Errorf("fib(90) = %d in %d calls, want 2880067194370816120 in 91", value, fibCalls)
+	}
+}
+
+// TestMemoizeStop memoizes with a TTL, which starts a janitor: stop must end it
+func TestMemoizeStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for range 20 {
+		double, stop := Memoize(func(n int) int { return 2 * n }, WithTTL(time.Millisecond))
+		if double(21) != 42 {
+			t.Fatal("wrong result")
+		}
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+		// The function keeps working after stop, without the janitor
+		if double(21) != 42 {
+			t.Fatal("wrong result after stop")
+		}
+	}
+	if !eventually(func() bool { return runtime.NumGoroutine() <= before }) {
+		t.Errorf("%d goroutines after stopping 20 memoized functions, %d before", runtime.NumGoroutine(), before)
+	}
+}
+
+// TestHandler reads the JSON of Handler for a cache with a TTL on a clock.Fake; with the
+// JSON codec the size of a value is the length of its JSON
+func TestHandler(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	m := NewCache(func(key string, m *Memory[string, string]) (string, error) {
+		return strings.ToUpper(key), nil
+	}, WithTTL(time.Minute), WithClock(fake), WithCodec(JSON))
+	defer m.Close()
+	m.Get("old")
+	fake.Advance(20 * time.Second)
+	m.Get("new")
+	m.Get("new")
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+	var report debugReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []DebugEntry{
+		{Key: "new", Age: "0s", ExpiresIn: "1m0s", Size: len(`"NEW"`)},
+		{Key: "old", Age: "20s", ExpiresIn: "40s", Size: len(`"OLD"`)},
+	}
+	if !slices.Equal(report.Entries, want) || report.Stats.Hits != 1 || report.HitRatio != 1.0/3 {
+		t.Fatalf("got %+v, hit ratio %v", report.Entries, report.HitRatio)
+	}
+
+	recorder = httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/debug/cache", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST answered %d, want 405", recorder.Code)
+	}
+
+	// Without WithCodec nor WithMaxCost the size comes from Gob
+	plain := NewCache(fibonacci)
+	plain.Get(10)
+	if entries := plain.debugEntries(); len(entries) == 0 || entries[0].Size == 0 {
+		t.Errorf("got %+v, want a size for every entry", entries)
+	}
+}
+
+// TestRegistry registers caches of different types and shards, then adds up their
+// stats, finds them by name and clears them together
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	fib := NewCache(fibonacci)
+	words := NewCache(analyzeWord)
+	sharded := NewSharded(func(key int, m *Memory[int, int]) (int, error) { return key, nil }, 4)
+	for name, cache := range map[string]Cache{"fib": fib, "words": words, "sharded": sharded} {
+		if err := registry.Register(name, cache); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := registry.Register("fib", fib); !errors.Is(err, ErrDuplicateCache) {
+		t.Fatalf("registered a name twice, got %v", err)
+	}
+
+	fib.Get(10)
+	words.Get("cache")
+	words.Get("cache")
+	sharded.Get(1)
+	byName, total := registry.Stats()
+	// fib(10) caches the positions 0 to 10; the second Get of a word is the other hit
+	if byName["fib"].Entries != 11 || total.Entries != 13 || total.Hits != 9 || total.Misses != 13 {
+		t.Fatalf("fib %+v, total %+v, want 11 and 13 entries", byName["fib"], total)
+	}
+	if names := registry.Names(); !slices.Equal(names, []string{"fib", "sharded", "words"}) {
+		t.Fatalf("names %v", names)
+	}
+
+	cache, err := registry.Get("words")
+	if found, ok := cache.(*Memory[string, wordStats]); err != nil || !ok || found != words {
+		t.Fatalf("Get(words) = %T, %v", cache, err)
+	}
+	if _, err := registry.Get("missing"); !errors.Is(err, ErrUnknownCache) {
+		t.Fatalf("Get of a missing name returned %v", err)
+	}
+
+	registry.Clear()
+	if _, total := registry.Stats(); total.Entries != 0 {
+		t.Fatalf("%d results after Clear", total.Entries)
+	}
+	if !registry.Unregister("fib") || registry.Unregister("fib") || len(registry.Names()) != 2 {
+		t.Fatal("Unregister didn't remove fib once")
+	}
+	if err := registry.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestPrefetch predicts that n+1 and n+2 follow n: after a Get of 10, the Gets of 11
+// and 12 must be hits. Then a blocked function checks that one worker means one
+// prefetch at a time.
+func TestPrefetch(t *testing.T) {
+	m := NewCache(func(n int, m *Memory[int, int]) (int, error) {
+		time.Sleep(time.Millisecond)
+		return n * n, nil
+	})
+	next := func(lastKeys []int) []int {
+		n := lastKeys[len(lastKeys)-1]
+		return []int{n + 1, n + 2}
+	}
+	m.Prefetch(next, 2)
+	m.Get(10)
+	ready := eventually(func() bool {
+		keys := m.Keys()
+		return slices.Contains(keys, 11) && slices.Contains(keys, 12)
+	})
+	if !ready {
+		t.Fatalf("the predicted keys were not calculated, cache has %v", m.Keys())
+	}
+	m.Prefetch(nil, 0)
+	if value, _ := m.Get(12); value != 144 {
+		t.Fatalf("Get(12) = %d, want 144", value)
+	}
+	if stats := m.Stats(); stats.Misses != 1 || stats.Hits != 1 || stats.Prefetches != 2 {
+		t.Fatalf("%d misses, %d hits, %d prefetches, want 1, 1 and 2",
+			stats.Misses, stats.Hits, stats.Prefetches)
+	}
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	blocked := NewCache(func(n int, m *Memory[int, int]) (int, error) {
+		if n >= 100 {
+			peak.Store(max(peak.Load(), running.Add(1)))
+			<-release
+			running.Add(-1)
+		}
+		return n, nil
+	})
+	blocked.Prefetch(func(lastKeys []int) []int { return []int{lastKeys[len(lastKeys)-1] + 100} }, 1)
+	for n := range 10 {
+		blocked.Get(n)
+	}
+	eventually(func() bool { return running.Load() > 0 })
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if peak.Load() != 1 {
+		t.Errorf("%d prefetches at once with one worker", peak.Load())
+	}
+}
+
+// recordingTracer keeps the spans finished, as "op key hit err"
+type recordingTracer struct {
+	spans []string
+	mux   sync.Mutex
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	op     TraceOp
+	key    any
+}
+
+func (t *recordingTracer) Start(op TraceOp, key any) Span {
+	return recordingSpan{t, op, key}
+}
+
+func (s recordingSpan) Finish(info TraceInfo) {
+	s.tracer.mux.Lock()
+	defer s.tracer.mux.Unlock()
+	s.tracer.spans = append(s.tracer.spans, fmt.Sprint(s.op, " ", s.key, " ", info.Hit, " ", info.Err != nil))
+}
+
+func (t *recordingTracer) recorded() []string {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return slices.Clone(t.spans)
+}
+
+// TestTracer checks the spans of a miss, a hit, a failed load and an eviction
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	m := NewCache(func(n int, m *Memory[int, int]) (int, error) {
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * n, nil
+	}, WithMaxEntries(1), WithTracer(tracer))
+	m.Get(2)
+	m.Get(2)
+	m.Get(-1)
+	m.Get(3) // Evicts 2
+
+	want := []string{
+		"cache.load 2 false false", "cache.get 2 false false",
+		"cache.get 2 true false",
+		"cache.load -1 false true", "cache.get -1 false true",
+		"cache.load 3 false false", "cache.evict 2 false false", "cache.get 3 false false",
+	}
+	if !eventually(func() bool { return len(tracer.recorded()) >= len(want) }) {
+		t.Fatalf("spans %q, want %q", tracer.recorded(), want)
+	}
+	got := tracer.recorded()
+	// The eviction is delivered outside the lock, it may come after the Get of 3
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("spans %q, want %q", got, want)
+	}
+}
+
+// fibonacci is the recursive function of most checks: each result reads the two
+// previous positions from the cache it is memoized by
+func fibonacci(n int, m *Memory[int, int]) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("fibonacci of negative position %d", n)
+	}
+	if n <= 1 {
+		return n, nil
+	}
+	previous, err := m.Get(n - 1)
+	if err != nil {
+		return 0, err
+	}
+	beforePrevious, err := m.Get(n - 2)
+	if err != nil {
+		return 0, err
+	}
+	return previous + beforePrevious, nil
+}
+
+// fibonacciBig is fibonacci with *big.Int values, which never modifies a cached value
+func fibonacciBig(n int, m *Memory[int, *big.Int]) (*big.Int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("fibonacci of negative position %d", n)
+	}
+	if n <= 1 {
+		return big.NewInt(int64(n)), nil
+	}
+	previous, err := m.Get(n - 1)
+	if err != nil {
+		return nil, err
+	}
+	beforePrevious, err := m.Get(n - 2)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Add(previous, beforePrevious), nil
+}
+
+// wordStats is a struct result cached by a string key
+type wordStats struct {
+	Length int
+	Vowels int
+}
+
+// analyzeWord counts the letters and vowels of a word, an error for the empty one
+func analyzeWord(word string, m *Memory[string, wordStats]) (wordStats, error) {
+	if word == "" {
+		return wordStats{}, errors.New("empty word")
+	}
+	stats := wordStats{Length: len(word)}
+	for _, r := range strings.ToLower(word) {
+		if strings.ContainsRune("aeiou", r) {
+			stats.Vowels++
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+
+	"github.com/Arcanm/go_advanced_course/pkg/frame/protoframe"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec turns values into bytes and back for the layers that leave the process: the
+// snapshots of persist.go (WithCodec) and the stores of store.go, redis.go and
+// memcached.go (their constructors take one, nil means Gob). It has the method set of
+// the Codec of pkg/frame, so the same codecs fit both.
+//
+//   - Gob handles any Go type without annotations, *big.Int included, but only Go reads it
+//   - JSON is readable by any language and by people, but loses type details: a V of type
+//     any comes back as map[string]any, and unexported fields are skipped
+//   - Protobuf is compact and versioned; it only takes values that are proto.Message, so it
+//     fits stores of generated message types, not snapshots
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// The codecs available
+var (
+	Gob      Codec = gobCodec{}
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protoCodec{}
+)
+
+// orGob returns codec, or Gob when it is nil
+func orGob(codec Codec) Codec {
+	if codec == nil {
+		return Gob
+	}
+	return codec
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// protoCodec is the codec of pkg/frame/protoframe, extended for the stores: they decode
+// into a *V, and when V is a message pointer like *pb.Result the message must be allocated first
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	return protoframe.Codec.Marshal(v)
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	target := reflect.ValueOf(v)
+	if _, ok := v.(proto.Message); !ok && target.Kind() == reflect.Pointer && target.Elem().Kind() == reflect.Pointer {
+		message := reflect.New(target.Elem().Type().Elem())
+		if _, ok := message.Interface().(proto.Message); ok {
+			if err := protoframe.Codec.Unmarshal(data, message.Interface()); err != nil {
+				return err
+			}
+			target.Elem().Set(message)
+			return nil
+		}
+	}
+	return protoframe.Codec.Unmarshal(data, v)
+}
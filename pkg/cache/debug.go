@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DebugEntry describes one entry of the cache in the output of Handler
+type DebugEntry struct {
+	Key       string `json:"key"`                  // The key formatted with fmt.Sprint
+	Age       string `json:"age,omitempty"`        // Time since the result was stored
+	ExpiresIn string `json:"expires_in,omitempty"` // Time left before the TTL, none without one
+	Size      int    `json:"size"`                 // Bytes of the value encoded by the codec of WithCodec
+	Cost      int64  `json:"cost,omitempty"`       // Cost counted by WithMaxCost
+	Stale     bool   `json:"stale,omitempty"`      // Past WithStaleWhileRevalidate, a refresh is due
+	Loading   bool   `json:"loading,omitempty"`    // The function is still running
+	Error     string `json:"error,omitempty"`      // A failure kept by WithNegativeTTL
+}
+
+// debugReport is the JSON served by Handler
+type debugReport struct {
+	Stats    Stats        `json:"stats"`
+	HitRatio float64      `json:"hit_ratio"`
+	AvgLoad  string       `json:"avg_load"`
+	Entries  []DebugEntry `json:"entries"` // Sorted by key
+}
+
+// Handler returns an http.Handler serving the stats and the entries of the cache in JSON,
+// to inspect a running cache from a browser. The values are not included, they may be
+// large or private; only their size is, encoded with the codec of the snapshots (Gob
+// unless WithCodec), and their cost with WithMaxCost. Mount it on a debug address, not a
+// public one:
+//
+//	http.Handle("/debug/cache", cache.Handler())
+func (m *Memory[K, V]) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		stats := m.Stats()
+		report := debugReport{
+			Stats:    stats,
+			HitRatio: stats.HitRatio(),
+			AvgLoad:  stats.AvgLoad().String(),
+			Entries:  m.debugEntries(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	})
+}
+
+// debugEntries describes the entries that are not expired yet. The values are encoded for
+// their size after the mutex is released, so a large cache doesn't block its callers.
+func (m *Memory[K, V]) debugEntries() []DebugEntry {
+	m.mux.Lock()
+	now := m.clock.Now()
+	entries := make([]DebugEntry, 0, len(m.cache))
+	values := make(map[int]V) // Values of the entries with a result, by index in entries
+	for key, e := range m.cache {
+		if e.expired(now) {
+			continue
+		}
+		entry := DebugEntry{Key: fmt.Sprint(key), Cost: e.cost, Loading: !e.loaded()}
+		if !entry.Loading && e.err != nil {
+			entry.Error = e.err.Error()
+		}
+		if !e.storedAt.IsZero() {
+			entry.Age = now.Sub(e.storedAt).Round(time.Millisecond).String()
+		}
+		if !e.expiresAt.IsZero() {
+			entry.ExpiresIn = e.expiresAt.Sub(now).Round(time.Millisecond).String()
+		}
+		entry.Stale = !e.staleAt.IsZero() && !now.Before(e.staleAt)
+		if e.hasValue() {
+			values[len(entries)] = e.value
+		}
+		entries = append(entries, entry)
+	}
+	codec := orGob(m.codec)
+	m.mux.Unlock()
+	for i, value := range values {
+		// A value the codec can't encode, like a func, keeps a size of 0
+		if data, err := codec.Marshal(value); err == nil {
+			entries[i].Size = len(data)
+		}
+	}
+	slices.SortFunc(entries, func(a, b DebugEntry) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return entries
+}
@@ -0,0 +1,51 @@
+package cache
+
+// The loader function is not the only way in: Set, GetOrSet and CompareAndSwap use the
+// cache as a concurrent map, for values computed elsewhere. The stored values follow
+// the same rules as the calculated ones: TTL, eviction policy and OnEvict.
+
+// Set stores value as the result of key, replacing the current one
+func (m *Memory[K, V]) Set(key K, value V) {
+	m.mux.Lock()
+	defer m.unlock()
+	m.set(key, value)
+}
+
+// GetOrSet returns the result of key if the cache has one; otherwise it stores value
+// and returns it. loaded reports whether the result was already there. If the key is
+// being calculated, it waits for the calculation, and stores value only if it failed.
+func (m *Memory[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+	m.mux.Lock()
+	for {
+		e, exists := m.cache[key]
+		now := m.clock.Now()
+		if !exists || e.expired(now) || (e.loaded() && e.err != nil) {
+			m.set(key, value)
+			m.unlock()
+			return value, false
+		}
+		if e.loaded() {
+			m.hit(key, e, now)
+			m.unlock()
+			return e.value, true
+		}
+		// Calculating: wait without the lock and look again
+		m.mux.Unlock()
+		<-e.ready
+		m.mux.Lock()
+	}
+}
+
+// CompareAndSwap replaces the result of key with new if it is old, and reports whether
+// it did. Keys without a result, expired or still being calculated, are never swapped.
+// Like sync.Map.CompareAndSwap, it panics if the results are not comparable.
+func (m *Memory[K, V]) CompareAndSwap(key K, old, new V) bool {
+	m.mux.Lock()
+	defer m.unlock()
+	e, exists := m.cache[key]
+	if !exists || !e.hasValue() || e.expired(m.clock.Now()) || any(e.value) != any(old) {
+		return false
+	}
+	m.set(key, new)
+	return true
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "time"
+
+// The TTL of WithTTL is the same for every result. SetUntil and ExpireAt give a key its
+// own deadline instead, like the EX and EXPIREAT of Redis, for the caches used as a map;
+// DeleteExpired removes the expired keys of a cache without a janitor.
+
+// SetUntil stores value as the result of key until expiresAt, replacing the TTL of the
+// cache for it; the zero time means it never expires
+func (m *Memory[K, V]) SetUntil(key K, value V, expiresAt time.Time) {
+	m.mux.Lock()
+	defer m.unlock()
+	m.set(key, value)
+	if e, exists := m.cache[key]; exists {
+		// set may have evicted it right away, with WithMaxCost
+		e.expiresAt = expiresAt
+	}
+}
+
+// Expiry returns the deadline of the result of key, the zero time when it never expires.
+// ok is false when the key has no valid result.
+func (m *Memory[K, V]) Expiry(key K) (expiresAt time.Time, ok bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	e, exists := m.cache[key]
+	if !exists || !e.hasValue() || e.expired(m.clock.Now()) {
+		return time.Time{}, false
+	}
+	return e.expiresAt, true
+}
+
+// ExpireAt changes the deadline of the result of key, the zero time removes it, and
+// reports whether the key has a valid result. A deadline in the past expires it now.
+func (m *Memory[K, V]) ExpireAt(key K, expiresAt time.Time) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	e, exists := m.cache[key]
+	if !exists || !e.hasValue() || e.expired(m.clock.Now()) {
+		return false
+	}
+	e.expiresAt = expiresAt
+	return true
+}
+
+// DeleteExpired removes every expired entry and returns how many were removed; the janitor
+// does it on its own with WithTTL, this is for the caches that only use SetUntil and ExpireAt
+func (m *Memory[K, V]) DeleteExpired() int {
+	return m.deleteExpired()
+}
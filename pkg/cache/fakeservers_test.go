@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// Minimal Redis and memcached servers for the tests of RedisStore and
+// MemcachedStore in cache_test.go: a map behind the few commands the stores send
+
+// fakeValues is the data of a fake server, shared by its connections
+type fakeValues struct {
+	values map[string]string
+	sync.Mutex
+}
+
+// serveFake accepts connections on a local port and runs handle for each one;
+// it returns the address and a function stopping the server
+func serveFake(handle func(r *bufio.Reader, w *bufio.Writer, data *fakeValues) error) (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	data := &fakeValues{values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+				for {
+					if handle(r, w, data) != nil || w.Flush() != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }, nil
+}
+
+// fakeRedis answers GET, SET (ignoring PX) and DEL
+func fakeRedis(r *bufio.Reader, w *bufio.Writer, data *fakeValues) error {
+	args, err := resp.ReadCommand(r)
+	if err != nil {
+		return err
+	}
+	data.Lock()
+	defer data.Unlock()
+	values := data.values
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, exists := values[args[1]]
+		if !exists {
+			w.WriteString("$-1\r\n")
+			return nil
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		values[args[1]] = args[2]
+		w.WriteString("+OK\r\n")
+	case "DEL":
+		_, exists := values[args[1]]
+		delete(values, args[1])
+		if exists {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString(":0\r\n")
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+	return nil
+}
+
+// fakeMemcached answers get, set (ignoring the expiration) and delete
+func fakeMemcached(r *bufio.Reader, w *bufio.Writer, data *fakeValues) error {
+	line, err := resp.ReadLine(r)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	data.Lock()
+	defer data.Unlock()
+	values := data.values
+	switch {
+	case len(fields) == 2 && fields[0] == "get":
+		if value, exists := values[fields[1]]; exists {
+			fmt.Fprintf(w, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(value), value)
+		}
+		w.WriteString("END\r\n")
+	case len(fields) == 5 && fields[0] == "set":
+		size, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		values[fields[1]] = string(buf[:size])
+		w.WriteString("STORED\r\n")
+	case len(fields) == 2 && fields[0] == "delete":
+		if _, exists := values[fields[1]]; !exists {
+			w.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		delete(values, fields[1])
+		w.WriteString("DELETED\r\n")
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Layered is a two-tier cache: a Memory in front of a slower Store.
+// Get reads through both layers: the Memory first, then the store, and only when
+// both miss it calls the function, whose result populates both layers.
+// Set and Delete are write-through: they change the store, then the Memory,
+// so the store is never older than the front. NewLayeredWriteBehind creates
+// one that writes to the store later, in batches (see writebehind.go).
+type Layered[K comparable, V any] struct {
+	front       *Memory[K, V]
+	back        Store[K, V]
+	writeBehind *writeBehind[K, V] // The queue in write-behind mode, nil for write-through
+}
+
+// NewLayered creates a two-tier cache of f over back; the options configure the
+// front Memory (WithTTL, WithMaxEntries...), the store keeps its own rules
+func NewLayered[K comparable, V any](f Function[K, V], back Store[K, V], options ...Option) *Layered[K, V] {
+	l := &Layered[K, V]{back: back}
+	l.front = NewCache(func(key K, m *Memory[K, V]) (V, error) {
+		value, err := back.Get(key)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return value, fmt.Errorf("reading the store: %w", err)
+		}
+		if value, err = f(key, m); err != nil {
+			return value, err
+		}
+		if err := back.Set(key, value); err != nil {
+			return value, fmt.Errorf("writing the store: %w", err)
+		}
+		return value, nil
+	}, options...)
+	return l
+}
+
+// Get returns the result of key from the first layer that has it,
+// or calculates it and saves it in both
+func (l *Layered[K, V]) Get(key K) (V, error) {
+	return l.front.Get(key)
+}
+
+// Set saves value in the store, then in the Memory; if the store fails,
+// the Memory is left unchanged. In write-behind mode the write is only queued.
+func (l *Layered[K, V]) Set(key K, value V) error {
+	if err := l.back.Set(key, value); err != nil {
+		return err
+	}
+	l.front.Set(key, value)
+	return nil
+}
+
+// Delete removes key from the store, then from the Memory; in write-behind mode
+// the removal from the store is queued
+func (l *Layered[K, V]) Delete(key K) error {
+	if err := l.back.Delete(key); err != nil {
+		return err
+	}
+	l.front.Delete(key)
+	return nil
+}
+
+// Front returns the in-memory layer, for its Stats, Subscribe...
+func (l *Layered[K, V]) Front() *Memory[K, V] {
+	return l.front
+}
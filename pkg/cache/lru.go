@@ -0,0 +1,106 @@
+package cache
+
+// lruNode is an element of the recency list
+type lruNode[K comparable] struct {
+	key        K
+	prev, next *lruNode[K]
+}
+
+// lruList orders the keys from the most to the least recently used.
+// It is a doubly linked list with a sentinel node, so inserting and unlinking never
+// check for nil, plus a map from key to node, so every operation is O(1).
+// It is not thread-safe: Memory calls it with its mutex held.
+type lruList[K comparable] struct {
+	root  lruNode[K] // Sentinel: root.next is the most recent, root.prev the least recent
+	nodes map[K]*lruNode[K]
+}
+
+// newLRUList creates an empty list
+func newLRUList[K comparable]() *lruList[K] {
+	l := &lruList[K]{nodes: make(map[K]*lruNode[K])}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	return l
+}
+
+// Len returns the number of keys in the list
+func (l *lruList[K]) Len() int {
+	return len(l.nodes)
+}
+
+// Contains reports whether key is in the list
+func (l *lruList[K]) Contains(key K) bool {
+	_, exists := l.nodes[key]
+	return exists
+}
+
+// Touch marks key as the most recently used, adding it if needed
+func (l *lruList[K]) Touch(key K) {
+	node, exists := l.nodes[key]
+	if exists {
+		l.unlink(node)
+	} else {
+		node = &lruNode[K]{key: key}
+		l.nodes[key] = node
+	}
+	// Insert right after the sentinel
+	node.prev = &l.root
+	node.next = l.root.next
+	l.root.next.prev = node
+	l.root.next = node
+}
+
+// Remove deletes key from the list
+func (l *lruList[K]) Remove(key K) {
+	if node, exists := l.nodes[key]; exists {
+		l.unlink(node)
+		delete(l.nodes, key)
+	}
+}
+
+// Oldest returns the least recently used key
+func (l *lruList[K]) Oldest() (K, bool) {
+	if l.root.prev == &l.root {
+		var zero K
+		return zero, false
+	}
+	return l.root.prev.key, true
+}
+
+// Add, Access and Evict make lruList the LRU EvictionPolicy, see policy.go
+
+// Add marks key as the most recently used
+func (l *lruList[K]) Add(key K) {
+	l.Touch(key)
+}
+
+// Access marks key as the most recently used if it is in the list
+func (l *lruList[K]) Access(key K) {
+	if l.Contains(key) {
+		l.Touch(key)
+	}
+}
+
+// Evict removes and returns the least recently used key
+func (l *lruList[K]) Evict() (K, bool) {
+	key, exists := l.Oldest()
+	if exists {
+		l.Remove(key)
+	}
+	return key, exists
+}
+
+// Keys returns the keys from the most to the least recently used
+func (l *lruList[K]) Keys() []K {
+	keys := make([]K, 0, len(l.nodes))
+	for node := l.root.next; node != &l.root; node = node.next {
+		keys = append(keys, node.key)
+	}
+	return keys
+}
+
+func (l *lruList[K]) unlink(node *lruNode[K]) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.prev, node.next = nil, nil
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/connpool"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// MemcachedStore is a Store on a memcached server, speaking its text protocol:
+//
+//	set <key> 0 <seconds> <bytes>\r\n<data>\r\n   ->  STORED
+//	get <key>\r\n                                 ->  VALUE <key> 0 <bytes>\r\n<data>\r\nEND, or END
+//	delete <key>\r\n                              ->  DELETED or NOT_FOUND
+//
+// memcached keys can't contain spaces nor be longer than 250 bytes, so the keys are
+// hashed (see hashKey). Values are encoded with a Codec like in RedisStore.
+type MemcachedStore[K comparable, V any] struct {
+	addr    string
+	prefix  string
+	ttl     time.Duration // Expiration of the keys, whole seconds, 0 means none
+	timeout time.Duration
+	codec   Codec
+	pool    *connpool.Pool
+}
+
+// Limit protecting the client from a broken server, the one of the bulk strings of RESP
+const maxValueSize = 512 * 1024 * 1024
+
+// NewMemcachedStore creates a store on the server at addr with up to maxConns connections;
+// the values are encoded with codec, nil means Gob
+func NewMemcachedStore[K comparable, V any](addr, prefix string, ttl time.Duration, maxConns int, codec Codec) *MemcachedStore[K, V] {
+	return &MemcachedStore[K, V]{
+		addr:    addr,
+		prefix:  prefix,
+		ttl:     ttl,
+		timeout: 2 * time.Second,
+		codec:   orGob(codec),
+		pool:    connpool.New(connpool.Options{MaxActive: maxConns, MaxIdle: maxConns}),
+	}
+}
+
+func (s *MemcachedStore[K, V]) Get(key K) (V, error) {
+	var value V
+	var data string
+	found := false
+	err := s.do("get "+s.key(key)+"\r\n", func(r *bufio.Reader) error {
+		line, err := resp.ReadLine(r)
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return memcachedError(line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil || size < 0 || size > maxValueSize {
+			return fmt.Errorf("%w: invalid value length %q", resp.ErrProtocol, line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		if end, err := resp.ReadLine(r); err != nil || end != "END" {
+			return fmt.Errorf("%w: value not followed by END", resp.ErrProtocol)
+		}
+		data, found = string(buf[:size]), true
+		return nil
+	})
+	if err != nil {
+		return value, err
+	}
+	if !found {
+		return value, ErrNotFound
+	}
+	err = decodeValue(s.codec, data, &value)
+	return value, err
+}
+
+func (s *MemcachedStore[K, V]) Set(key K, value V) error {
+	data, err := encodeValue(s.codec, value)
+	if err != nil {
+		return err
+	}
+	seconds := int((s.ttl + time.Second - 1) / time.Second)
+	command := fmt.Sprintf("set %s 0 %d %d\r\n%s\r\n", s.key(key), seconds, len(data), data)
+	return s.do(command, expectLine("STORED"))
+}
+
+func (s *MemcachedStore[K, V]) Delete(key K) error {
+	return s.do("delete "+s.key(key)+"\r\n", expectLine("DELETED", "NOT_FOUND"))
+}
+
+// Close closes the idle connections
+func (s *MemcachedStore[K, V]) Close() error {
+	return s.pool.Close()
+}
+
+func (s *MemcachedStore[K, V]) key(key K) string {
+	return s.prefix + hashKey(key)
+}
+
+// do sends a command on a pooled connection and reads the response with read.
+// Like RedisStore.do, the connection is discarded when anything fails.
+func (s *MemcachedStore[K, V]) do(command string, read func(r *bufio.Reader) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	conn, err := s.pool.Get(ctx, s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if _, err := io.WriteString(conn, command); err != nil {
+		conn.Discard()
+		return err
+	}
+	if err := read(bufio.NewReader(conn)); err != nil {
+		conn.Discard()
+		return err
+	}
+	conn.Release()
+	return nil
+}
+
+// expectLine reads a one-line response that must be one of the accepted ones
+func expectLine(accepted ...string) func(r *bufio.Reader) error {
+	return func(r *bufio.Reader) error {
+		line, err := resp.ReadLine(r)
+		if err != nil {
+			return err
+		}
+		for _, ok := range accepted {
+			if line == ok {
+				return nil
+			}
+		}
+		return memcachedError(line)
+	}
+}
+
+// memcachedError is an unexpected response, like ERROR or SERVER_ERROR out of memory
+type memcachedError string
+
+func (e memcachedError) Error() string {
+	return "memcached: " + string(e)
+}
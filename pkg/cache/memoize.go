@@ -0,0 +1,36 @@
+package cache
+
+// Memoize wraps a pure function with a Memory cache: the returned function calls f once
+// per input, concurrent calls for the same input included, and returns the stored output
+// afterwards. It is the Decorator pattern over a plain function, for the many functions
+// that don't need the *Memory parameter nor an error, unlike the FibonacciCached of 01-Concurrency/Cache.
+// The options are the ones of NewCache, e.g. WithMaxEntries to bound the memory used.
+// The second function returned is the Close of the cache: with WithTTL it stops the
+// janitor, which would otherwise run as long as the program.
+//
+// A recursive function uses the memoized version for its sub-results by declaring it first:
+//
+//	var fib func(int) int
+//	fib, stop := Memoize(func(n int) int {
+//		if n <= 1 {
+//			return n
+//		}
+//		return fib(n-1) + fib(n-2)
+//	})
+//	defer stop()
+//
+// A panic of f is raised again in every call waiting for the same input, as an error
+// wrapping ErrPanic, and the next call for the input calls f again.
+func Memoize[I comparable, O any](f func(I) O, options ...Option) (memoized func(I) O, stop func() error) {
+	m := NewCache(func(input I, m *Memory[I, O]) (O, error) {
+		return f(input), nil
+	}, options...)
+	return func(input I) O {
+		output, err := m.Get(input)
+		if err != nil {
+			// f returns no error, this is the panic of f
+			panic(err)
+		}
+		return output
+	}, m.Close
+}
@@ -0,0 +1,577 @@
+// Package cache memoizes functions in memory: Memory calls the function once per key,
+// the concurrent callers of a key being calculated wait for that result, and the results
+// stay until their TTL, an eviction or a Delete.
+//
+// It is the cache of 01-Concurrency/Cache, served over TCP by 03-Net/CacheServer, put in
+// front of a slow service by 02-DesignPatterns/CachingProxy and of the links of
+// 03-Net/URLShortener, and the keyspace of 03-Net/MiniRedis. On top of Memory: eviction
+// policies (policy.go), per-key deadlines (expiry.go), snapshots (persist.go), observers
+// and tracing (observer.go, tracer.go), and layers over remote stores like Redis (layered.go).
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// Function is a type that defines the signature of functions that can be cached
+// It takes a key and a pointer to the cache memory system, so recursive functions
+// like the Fibonacci of 01-Concurrency/Cache can get their sub-results from the same cache.
+// A function that can fail (a network fetch, a database lookup) returns an error,
+// and failed results are not cached (see WithNegativeTTL to keep them a while).
+type Function[K comparable, V any] func(key K, m *Memory[K, V]) (V, error)
+
+// ErrPanic is returned by Get when the function panicked: the callers waiting for the same
+// key get it too, instead of waiting forever for a result that never comes
+var ErrPanic = errors.New("cache: the function panicked")
+
+// entry is the promise of a result: it is stored in the map before the function runs,
+// so the callers asking for the same key wait on ready instead of calculating it again
+type entry[V any] struct {
+	ready     chan struct{} // Closed when value and err are set
+	value     V
+	err       error
+	cost      int64     // Counted in the total with WithMaxCost, zero while calculating
+	storedAt  time.Time // When the result was stored, zero while calculating
+	expiresAt time.Time // Zero while calculating and when the entry never expires
+	staleAt   time.Time // With WithStaleWhileRevalidate, when a refresh starts; zero otherwise
+	refresh   bool      // A background refresh is running
+	hits      int       // Reads since the result was stored, for WithRefreshAhead
+}
+
+// expired reports whether the entry is no longer valid at now
+func (e *entry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// loaded reports whether the calculation of the entry finished
+func (e *entry[V]) loaded() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasValue reports whether the entry holds a result, not a calculation in
+// progress or an error kept by WithNegativeTTL
+func (e *entry[V]) hasValue() bool {
+	return e.loaded() && e.err == nil
+}
+
+// Memory implements a thread-safe caching system
+// This structure ensures safe concurrent access to cached values
+// K is the type of the keys and V the type of the cached results
+type Memory[K comparable, V any] struct {
+	f      Function[K, V]    // The function to be cached
+	cache  map[K]*entry[V]   // Cached results and results being calculated
+	config                   // Settings given to NewCache
+	policy EvictionPolicy[K] // Chooses the result to evict, only with WithMaxEntries or WithMaxCost, see policy.go
+	stats  Stats             // Counters returned by Stats, see stats.go
+	loads  chan struct{}     // One slot per running call with WithMaxConcurrentLoads, nil otherwise
+
+	costOf    func(key K, value V) int64 // Set by SetCost, every result costs 1 otherwise
+	totalCost int64                      // Sum of the costs of the results, with WithMaxCost
+
+	onEvict func(key K, value V) // Set by OnEvict
+	evicted []eviction[K, V]     // Removed entries waiting for onEvict, see unlock
+
+	stop    chan struct{} // Closed by Close to stop the janitor, nil without a TTL
+	stopped chan struct{} // Closed by the janitor when it returns
+	closed  bool          // Close was called
+
+	prefetcher atomic.Pointer[prefetcher[K, V]] // Set by Prefetch, see prefetch.go
+
+	subscriptions []observer.Subscription[Event[K, V]] // Observers added by Subscribe, see observer.go
+	events        []Event[K, V]                        // Events waiting for the observers, see unlock
+	mux           sync.Mutex                           // Protects the map and the list, never held while the function runs
+}
+
+// NewCache creates a new instance of the caching system
+// Parameters:
+//   - f: The function to be cached
+//   - options: Optional settings, like WithTTL or WithMaxEntries (see options.go)
+//
+// Returns: A pointer to a new Memory instance
+func NewCache[K comparable, V any](f Function[K, V], options ...Option) *Memory[K, V] {
+	m := &Memory[K, V]{
+		f:     f,
+		cache: make(map[K]*entry[V]),
+	}
+	for _, option := range options {
+		option(&m.config)
+	}
+	if m.clock == nil {
+		m.clock = clock.Real{}
+	}
+	if m.maxEntries > 0 || m.maxCost > 0 {
+		m.policy = newPolicy[K](m.policyKind, m.maxEntries)
+	}
+	m.costOf = unitCost[K, V]
+	if m.maxLoads > 0 {
+		m.loads = make(chan struct{}, m.maxLoads)
+	}
+	if m.tracer != nil {
+		m.Subscribe(evictionTracer[K, V]{m.tracer}, observer.WithFilter(func(event Event[K, V]) bool {
+			return event.Kind == EventEvict
+		}))
+	}
+	// The janitor removes the expired entries, so they don't use memory until
+	// the next Get of the same key; it runs until Close
+	if m.ttl > 0 || m.negativeTTL > 0 {
+		interval := m.sweep
+		if interval <= 0 {
+			shortest := m.ttl
+			if shortest == 0 || (m.negativeTTL > 0 && m.negativeTTL < shortest) {
+				shortest = m.negativeTTL
+			}
+			interval = max(shortest/2, time.Millisecond)
+			if m.ttl > 0 && m.aheadWindow > 0 {
+				interval = max(min(interval, m.aheadWindow/2), time.Millisecond)
+			}
+		}
+		m.stop, m.stopped = make(chan struct{}), make(chan struct{})
+		go m.janitor(interval)
+	}
+	return m
+}
+
+// NewCacheWithTTL creates a cache whose results expire ttl after being calculated
+// It is a shortcut for NewCache(f, WithTTL(ttl))
+func NewCacheWithTTL[K comparable, V any](f Function[K, V], ttl time.Duration) *Memory[K, V] {
+	return NewCache(f, WithTTL(ttl))
+}
+
+// Get retrieves a value from the cache. If it doesn't exist or expired, calculates and stores it
+// Concurrent calls for the same key share a single call to the function.
+// The lock only protects the map for a moment and is never held while the function runs,
+// so recursive functions like a cached Fibonacci can call Get for other keys. A function must
+// not Get its own key, directly or through a cycle of keys: it would wait for itself.
+// Parameters:
+//   - key: The input value for which we want to cache the result
+//
+// Returns: The cached or newly calculated result, or the error of the function
+func (m *Memory[K, V]) Get(key K) (V, error) {
+	var span Span
+	var start time.Time
+	if m.tracer != nil {
+		span, start = m.tracer.Start(TraceGet, key), time.Now()
+	}
+	value, hit, err := m.get(key, false)
+	if span != nil {
+		span.Finish(TraceInfo{Duration: time.Since(start), Hit: hit, Err: err})
+	}
+	if p := m.prefetcher.Load(); p != nil {
+		p.after(m, key)
+	}
+	return value, err
+}
+
+// get is Get without the prefetching and the tracing, it also reports whether the key
+// was found. prefetch is true for the loads started by the prefetcher, which don't count
+// as Gets and don't wait for a key in progress.
+func (m *Memory[K, V]) get(key K, prefetch bool) (V, bool, error) {
+	m.mux.Lock()
+	e, exists := m.cache[key]
+	now := m.clock.Now()
+	if exists && !e.expired(now) && prefetch {
+		m.unlock()
+		var zero V
+		return zero, true, nil
+	}
+	if exists && !e.expired(now) {
+		m.hit(key, e, now)
+		m.unlock()
+		// Wait for the result when another goroutine is calculating it,
+		// the error included: every caller sees the outcome of the same call
+		<-e.ready
+		return e.value, true, e.err
+	}
+
+	// Missing or expired: store the promise first, so the next callers wait for it.
+	// It leaves the recency list until it has a result, so it can't be evicted unfinished
+	if exists {
+		m.remove(key)
+		m.stats.Expired++
+	}
+	e = &entry[V]{ready: make(chan struct{})}
+	m.cache[key] = e
+	if prefetch {
+		m.stats.Prefetches++
+	} else {
+		m.stats.Misses++
+		m.emit(EventMiss, key, e.value, nil)
+	}
+	m.unlock()
+
+	value, elapsed, err := m.call(key)
+
+	m.mux.Lock()
+	e.value, e.err = value, err
+	m.record(elapsed, err)
+	switch {
+	case m.cache[key] != e:
+		// Delete or Clear removed the key while it was calculated: the callers
+		// waiting get the result, but it is not cached, it may be stale already
+	case errors.Is(err, ErrPanic):
+		// Not even with WithNegativeTTL: a panic is a bug, not a failure of a backend
+		delete(m.cache, key)
+	case err != nil && m.negativeTTL > 0:
+		// The error is kept for a short while, so a failing key doesn't call
+		// the function on every Get. The eviction policy doesn't track it:
+		// it doesn't take the room of a result, and leaves on its own
+		e.expiresAt = m.clock.Now().Add(m.negativeTTL)
+	case err != nil:
+		// Errors are not cached, the next Get calls the function again
+		delete(m.cache, key)
+	default:
+		m.store(key, e)
+	}
+	close(e.ready)
+	m.unlock()
+	return value, false, err
+}
+
+// hit records a Get answered by the entry e of key; the mutex must be held
+func (m *Memory[K, V]) hit(key K, e *entry[V], now time.Time) {
+	// The policy learns about the use (a key still being calculated
+	// isn't tracked yet, it is added when its result arrives)
+	if m.policy != nil {
+		m.policy.Access(key)
+	}
+	m.stats.Hits++
+	e.hits++
+	m.emit(EventHit, key, e.value, nil)
+	// A stale result is still returned right away, and refreshed in the
+	// background for the next callers
+	if !e.staleAt.IsZero() && !now.Before(e.staleAt) && !e.refresh {
+		e.refresh = true
+		go m.refresh(key, e)
+	}
+}
+
+// call runs the function for key and measures it; with WithMaxConcurrentLoads
+// it first waits for a free slot, the wait is not part of the measure
+func (m *Memory[K, V]) call(key K) (V, time.Duration, error) {
+	if m.loads != nil {
+		m.loads <- struct{}{}
+		defer func() { <-m.loads }()
+	}
+	var span Span
+	if m.tracer != nil {
+		span = m.tracer.Start(TraceLoad, key)
+	}
+	start := time.Now()
+	value, err := m.load(key)
+	elapsed := time.Since(start)
+	if span != nil {
+		span.Finish(TraceInfo{Duration: elapsed, Err: err})
+	}
+	return value, elapsed, err
+}
+
+// load calls the function for key, turning a panic into an error wrapping ErrPanic: the
+// entry of the key is then finished and removed like for any error, and the callers
+// waiting for it are released
+func (m *Memory[K, V]) load(key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w for key %v: %v", ErrPanic, key, r)
+		}
+	}()
+	return m.f(key, m)
+}
+
+// record adds a call to the function to the stats; the mutex must be held
+func (m *Memory[K, V]) record(elapsed time.Duration, err error) {
+	m.stats.LoadTime += elapsed
+	m.stats.MaxLoad = max(m.stats.MaxLoad, elapsed)
+	if err != nil {
+		m.stats.Errors++
+	}
+}
+
+// refresh calculates again the stale entry e of key, while Get keeps returning
+// the stale result. A failed refresh keeps it, and the next Get tries again.
+func (m *Memory[K, V]) refresh(key K, e *entry[V]) {
+	value, elapsed, err := m.call(key)
+
+	m.mux.Lock()
+	defer m.unlock()
+	m.record(elapsed, err)
+	m.stats.Refreshes++
+	m.emit(EventRefresh, key, value, err)
+	if m.cache[key] != e {
+		// Deleted, or expired and calculated again, while refreshing
+		return
+	}
+	if err != nil {
+		e.refresh = false
+		return
+	}
+	m.set(key, value)
+}
+
+// set replaces the entry of key with value; the mutex must be held.
+// The callers holding the old entry read its value without the lock,
+// so the new value goes into a new entry instead of changing the old one.
+func (m *Memory[K, V]) set(key K, value V) {
+	if _, exists := m.cache[key]; exists {
+		m.remove(key)
+	}
+	e := &entry[V]{ready: make(chan struct{}), value: value}
+	close(e.ready)
+	m.cache[key] = e
+	m.store(key, e)
+}
+
+// store makes a calculated entry valid, evicting the least recently used ones while the cache
+// is over WithMaxEntries or WithMaxCost. The mutex must be held
+func (m *Memory[K, V]) store(key K, e *entry[V]) {
+	now := m.clock.Now()
+	e.storedAt = now
+	if m.ttl > 0 {
+		e.expiresAt = now.Add(m.lifetime())
+	}
+	if m.softTTL > 0 {
+		e.staleAt = now.Add(m.softTTL)
+	}
+	if m.policy == nil {
+		return
+	}
+	if m.maxCost > 0 {
+		cost := m.costOf(key, e.value)
+		if cost > m.maxCost {
+			// It would evict every other result, and then itself
+			m.forget(key)
+			m.stats.Evictions++
+			return
+		}
+		e.cost = cost
+		m.totalCost += cost
+	}
+	m.policy.Add(key)
+	for m.full() {
+		victim, _ := m.policy.Evict()
+		m.forget(victim)
+		m.stats.Evictions++
+	}
+}
+
+// full reports whether the results go over WithMaxEntries or WithMaxCost; the mutex must be held
+func (m *Memory[K, V]) full() bool {
+	return (m.maxEntries > 0 && m.policy.Len() > m.maxEntries) ||
+		(m.maxCost > 0 && m.totalCost > m.maxCost && m.policy.Len() > 0)
+}
+
+// lifetime returns the TTL of a new result, minus a random share of it with WithTTLJitter
+func (m *Memory[K, V]) lifetime() time.Duration {
+	band := time.Duration(float64(m.ttl) * m.ttlJitter)
+	if band <= 0 {
+		return m.ttl
+	}
+	return m.ttl - rand.N(band+1)
+}
+
+// remove deletes key from the cache and the eviction policy; the mutex must be held
+func (m *Memory[K, V]) remove(key K) {
+	m.forget(key)
+	if m.policy != nil {
+		m.policy.Remove(key)
+	}
+}
+
+// forget deletes key from the cache and queues the OnEvict callback and the event
+// that unlock delivers; the mutex must be held
+func (m *Memory[K, V]) forget(key K) {
+	e := m.cache[key]
+	m.totalCost -= e.cost
+	if e.hasValue() {
+		if m.onEvict != nil {
+			m.evicted = append(m.evicted, eviction[K, V]{key: key, value: e.value})
+		}
+		m.emit(EventEvict, key, e.value, nil)
+	}
+	delete(m.cache, key)
+}
+
+// Delete removes the result of key, so the next Get calculates it again.
+// If it is being calculated, the callers already waiting still receive it.
+// It reports whether the key was in the cache.
+func (m *Memory[K, V]) Delete(key K) bool {
+	m.mux.Lock()
+	defer m.unlock()
+	if _, exists := m.cache[key]; !exists {
+		return false
+	}
+	m.remove(key)
+	return true
+}
+
+// Clear removes every result; OnEvict is called for each of them
+func (m *Memory[K, V]) Clear() {
+	m.mux.Lock()
+	defer m.unlock()
+	for key := range m.cache {
+		m.remove(key)
+	}
+}
+
+// Keys returns the keys with a valid result, in no particular order.
+// It is a snapshot: the cache may change as soon as it returns.
+func (m *Memory[K, V]) Keys() []K {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	now := m.clock.Now()
+	keys := make([]K, 0, len(m.cache))
+	for key, e := range m.cache {
+		if e.hasValue() && !e.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// janitor removes the expired entries every interval until Close
+func (m *Memory[K, V]) janitor(interval time.Duration) {
+	defer close(m.stopped)
+	for {
+		select {
+		case <-m.clock.After(interval):
+			m.deleteExpired()
+			if m.ttl > 0 && m.aheadWindow > 0 {
+				m.refreshAhead()
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Close stops the janitor and waits for it to return, so a cache created for a while,
+// like the one of a request or a test, doesn't leave a goroutine behind. The cache keeps
+// working after Close, its expired entries are only removed when their key is read again.
+// Calling Close again does nothing. It always returns nil, the error is for io.Closer.
+func (m *Memory[K, V]) Close() error {
+	m.mux.Lock()
+	if m.closed || m.stop == nil {
+		m.closed = true
+		m.mux.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stop)
+	m.mux.Unlock()
+	<-m.stopped
+	return nil
+}
+
+// deleteExpired removes every expired entry and returns how many were removed
+func (m *Memory[K, V]) deleteExpired() int {
+	m.mux.Lock()
+	defer m.unlock()
+	now := m.clock.Now()
+	removed := 0
+	for key, cached := range m.cache {
+		if cached.expired(now) {
+			m.remove(key)
+			removed++
+			m.stats.Expired++
+		}
+	}
+	return removed
+}
+
+// refreshAhead starts a background refresh of the hot results that expire within
+// the window of WithRefreshAhead
+func (m *Memory[K, V]) refreshAhead() {
+	m.mux.Lock()
+	defer m.unlock()
+	due := m.clock.Now().Add(m.aheadWindow)
+	for key, e := range m.cache {
+		if e.hasValue() && !e.refresh && e.hits >= m.aheadHits && !e.expiresAt.IsZero() && !due.Before(e.expiresAt) {
+			e.refresh = true
+			go m.refresh(key, e)
+		}
+	}
+}
+
+// eviction is a removed entry to pass to the OnEvict callback
+type eviction[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// OnEvict registers a callback called with every result removed from the cache:
+// expired after the TTL, evicted by WithMaxEntries or deleted. It runs without the lock,
+// so it may use the cache, and is the place to release resources tied to the values.
+// Failed calculations were never cached and don't reach it.
+func (m *Memory[K, V]) OnEvict(f func(key K, value V)) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.onEvict = f
+}
+
+// SetCost sets the function measuring the cost of a result for WithMaxCost, usually its
+// size in bytes; nil goes back to the default, where every result costs 1. It is a method
+// rather than an option so its key and value types are checked against the cache.
+// It is called once, when a result is stored, with the mutex held, so it must be quick
+// and must not use the cache. The results already stored are measured again, and the
+// ones that no longer fit are evicted.
+func (m *Memory[K, V]) SetCost(cost func(key K, value V) int64) {
+	if cost == nil {
+		cost = unitCost[K, V]
+	}
+	m.mux.Lock()
+	defer m.unlock()
+	m.costOf = cost
+	if m.maxCost <= 0 {
+		return
+	}
+	m.totalCost = 0
+	for key, e := range m.cache {
+		if !e.hasValue() {
+			continue
+		}
+		e.cost = 0
+		if c := cost(key, e.value); c > m.maxCost {
+			m.remove(key)
+			m.stats.Evictions++
+		} else {
+			e.cost = c
+			m.totalCost += c
+		}
+	}
+	for m.full() {
+		victim, _ := m.policy.Evict()
+		m.forget(victim)
+		m.stats.Evictions++
+	}
+}
+
+// unitCost is the cost of every result without SetCost
+func unitCost[K comparable, V any](K, V) int64 {
+	return 1
+}
+
+// unlock releases the mutex, then runs the OnEvict callback for the entries removed
+// while it was held and notifies the observers of the queued events; running them
+// under the lock would deadlock if they call the cache
+func (m *Memory[K, V]) unlock() {
+	evicted, onEvict := m.evicted, m.onEvict
+	events, subscriptions := m.events, m.subscriptions
+	m.evicted, m.events = nil, nil
+	m.mux.Unlock()
+	for _, e := range evicted {
+		onEvict(e.key, e.value)
+	}
+	notify(subscriptions, events)
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/observer"
+)
+
+// The cache reports what happens inside it with the Observer pattern of pkg/observer:
+// Memory is the topic, and anything implementing observer.Observer[Event[K, V]]
+// (a logger, a metrics exporter) can Subscribe to it without the cache knowing about it.
+
+// EventKind identifies what happened in the cache
+type EventKind string
+
+const (
+	EventHit     EventKind = "hit"     // Get found the key, calculated or being calculated
+	EventMiss    EventKind = "miss"    // Get calls the function
+	EventEvict   EventKind = "evict"   // A result left: expired, evicted or deleted
+	EventRefresh EventKind = "refresh" // A background refresh finished, see WithStaleWhileRevalidate
+)
+
+// Event is sent to the observers of a Memory. Value is set for evictions and refreshes,
+// Err for the refreshes that failed.
+type Event[K comparable, V any] struct {
+	Kind  EventKind
+	Key   K
+	Value V
+	Err   error
+	Time  time.Time
+}
+
+// Subscribe registers an observer for the events of the cache; options such as
+// observer.WithFilter limit the events it receives. The observers are called in the goroutine
+// that used the cache, after its lock is released, so they must be quick; their
+// errors are ignored, the cache has nobody to report them to.
+func (m *Memory[K, V]) Subscribe(o observer.Observer[Event[K, V]], options ...observer.SubscribeOption[Event[K, V]]) {
+	s := observer.NewSubscription(o, options)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	// A new slice, so unlock can range over the old one without the lock
+	m.subscriptions = append(m.subscriptions[:len(m.subscriptions):len(m.subscriptions)], s)
+}
+
+// Unsubscribe removes the observer with the id of o
+func (m *Memory[K, V]) Unsubscribe(o observer.Observer[Event[K, V]]) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	kept := make([]observer.Subscription[Event[K, V]], 0, len(m.subscriptions))
+	for _, s := range m.subscriptions {
+		if s.Observer().GetId() != o.GetId() {
+			kept = append(kept, s)
+		}
+	}
+	m.subscriptions = kept
+}
+
+// emit queues an event for the observers, delivered by unlock; the mutex must be held
+func (m *Memory[K, V]) emit(kind EventKind, key K, value V, err error) {
+	if len(m.subscriptions) == 0 {
+		return
+	}
+	m.events = append(m.events, Event[K, V]{Kind: kind, Key: key, Value: value, Err: err, Time: m.clock.Now()})
+}
+
+// notify delivers the events to the observers whose filters accept them
+func notify[K comparable, V any](subscriptions []observer.Subscription[Event[K, V]], events []Event[K, V]) {
+	for _, event := range events {
+		for _, o := range observer.Matching(subscriptions, event) {
+			o.UpdateValue(context.Background(), event)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+// config holds the settings of a Memory, filled by the options of NewCache
+type config struct {
+	ttl         time.Duration // How long a result stays valid, 0 means forever
+	ttlJitter   float64       // Share of the TTL randomly taken off each result, between 0 and 1
+	maxEntries  int           // Maximum number of cached results, 0 means unbounded
+	maxCost     int64         // Maximum total cost of the cached results, 0 means unbounded
+	policyKind  Policy        // Which result WithMaxEntries or WithMaxCost evicts, LRU by default
+	negativeTTL time.Duration // How long an error is kept, 0 means errors are not cached
+	maxLoads    int           // Calls to the function running at once, 0 means unbounded
+	clock       clock.Clock   // Source of time for expiration, the system time by default
+	sweep       time.Duration // Interval of the janitor, half the shortest TTL by default
+	codec       Codec         // Encoding of the snapshots, Gob when nil
+	tracer      Tracer        // Receives the spans of the operations, see tracer.go
+	softTTL     time.Duration // Age after which a result is refreshed in the background, 0 means never
+	aheadWindow time.Duration // With WithRefreshAhead, how long before the TTL hot results are refreshed
+	aheadHits   int           // With WithRefreshAhead, reads that make a result hot
+}
+
+// Option configures a Memory created with NewCache
+type Option func(*config)
+
+// WithTTL makes the results expire ttl after being calculated; a janitor goroutine
+// removes the expired entries every ttl/2, or as set by WithSweepInterval
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithTTLJitter shortens the TTL of each result by a random share of up to fraction of it:
+// with WithTTL(time.Minute) and a fraction of 0.2, results expire between 48s and 1m after
+// being calculated. Results warmed at the same moment, like a list of keys loaded at startup,
+// then expire at different times instead of all calling the function again together.
+// The TTL stays the upper bound, so no result lives longer than without jitter.
+// fraction is clamped between 0 and 1.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *config) {
+		c.ttlJitter = min(max(fraction, 0), 1)
+	}
+}
+
+// WithSweepInterval sets how often the janitor removes the expired entries, instead of
+// half the shortest of WithTTL and WithNegativeTTL. A short interval frees the memory
+// sooner, a long one locks the cache less often on a large cache. Without a TTL there
+// is no janitor and the interval is ignored.
+func WithSweepInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.sweep = interval
+	}
+}
+
+// WithMaxEntries bounds the cache to n results: when it is full, storing a new
+// result evicts the least recently used one, or the one chosen by WithPolicy
+func WithMaxEntries(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
+}
+
+// WithMaxCost bounds the total cost of the results to max, as measured by SetCost: when
+// a new result goes over it, the results chosen by the policy are evicted until the total
+// fits again. It is meant for values of very different sizes, where a number of entries
+// says little about the memory used. A result costing more than max alone is not kept.
+// It can be combined with WithMaxEntries; ARC only keeps its history of evicted keys
+// when WithMaxEntries gives it a capacity.
+func WithMaxCost(max int64) Option {
+	return func(c *config) {
+		c.maxCost = max
+	}
+}
+
+// WithStaleWhileRevalidate refreshes the results older than softTTL in the background:
+// the Get that finds a stale result returns it right away and starts a single refresh,
+// so the hot keys never make a caller wait for the function. Combined with WithTTL,
+// softTTL should be shorter than the TTL: results past the TTL are calculated again
+// in the foreground as usual.
+func WithStaleWhileRevalidate(softTTL time.Duration) Option {
+	return func(c *config) {
+		c.softTTL = softTTL
+	}
+}
+
+// WithRefreshAhead refreshes the hot results before they expire: the janitor looks for
+// the results read at least minHits times since they were stored whose TTL ends within
+// window, and calculates them again in the background. A key read often never makes a
+// caller wait for the function after its first calculation, while the keys read rarely
+// expire as usual. Unlike WithStaleWhileRevalidate, the refresh doesn't wait for a read.
+// It needs WithTTL; the janitor then runs at least every window/2, unless
+// WithSweepInterval says otherwise.
+func WithRefreshAhead(window time.Duration, minHits int) Option {
+	return func(c *config) {
+		c.aheadWindow = window
+		c.aheadHits = max(minHits, 1)
+	}
+}
+
+// WithPolicy chooses the eviction policy of a cache bounded by WithMaxEntries or WithMaxCost:
+// LRU (the default), LFU, FIFO or ARC, see policy.go
+func WithPolicy(kind Policy) Option {
+	return func(c *config) {
+		c.policyKind = kind
+	}
+}
+
+// WithNegativeTTL keeps the errors of the function for ttl: during that time Get returns
+// the same error without calling the function again, so a failing backend isn't hit by
+// every caller. It is usually much shorter than WithTTL, the failure may be transient.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithMaxConcurrentLoads runs at most n calls to the function at once: under a burst of
+// misses for different keys, the extra Gets queue for a free slot instead of starting
+// n expensive calculations together. A recursive function like the FibonacciCached of 01-Concurrency/Cache keeps its
+// slot while it waits for its sub-results, so it needs more slots than its depth;
+// the limit is meant for flat functions, like the jobs of its massive operations.
+func WithMaxConcurrentLoads(n int) Option {
+	return func(c *config) {
+		c.maxLoads = n
+	}
+}
+
+// WithClock replaces the system time used for the TTLs and the janitor, see pkg/clock.
+// The duration of the calls to the function in Stats is always measured for real.
+func WithClock(source clock.Clock) Option {
+	return func(c *config) {
+		c.clock = source
+	}
+}
+
+// WithCodec sets the encoding of the snapshots written by SaveTo and SaveFile, Gob by
+// default; JSON makes them readable by other programs. See codec.go.
+func WithCodec(codec Codec) Option {
+	return func(c *config) {
+		c.codec = codec
+	}
+}
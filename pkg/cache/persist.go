@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedEntry is the form of a result in a snapshot; the fields are exported for the codecs
+type savedEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	ExpiresAt time.Time // Zero when the result never expires
+}
+
+// SaveTo writes the valid results to w with the Codec of WithCodec, Gob by default,
+// to be read back by LoadFrom. K and V must be types the codec can encode: with Gob,
+// *big.Int is, a func or a channel is not.
+func (m *Memory[K, V]) SaveTo(w io.Writer) error {
+	m.mux.Lock()
+	now := m.clock.Now()
+	entries := make([]savedEntry[K, V], 0, len(m.cache))
+	for key, e := range m.cache {
+		if e.hasValue() && !e.expired(now) {
+			entries = append(entries, savedEntry[K, V]{Key: key, Value: e.value, ExpiresAt: e.expiresAt})
+		}
+	}
+	m.mux.Unlock()
+	// Encoding may be slow, it runs without the lock on the copied entries
+	data, err := orGob(m.codec).Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("cache: encoding snapshot: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// LoadFrom adds the results written by SaveTo to the cache and returns how many were
+// added. The results that expired since they were saved are skipped, the others keep
+// their expiration; keys already in the cache keep their current result.
+func (m *Memory[K, V]) LoadFrom(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("cache: reading snapshot: %w", err)
+	}
+	var entries []savedEntry[K, V]
+	if err := orGob(m.codec).Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("cache: reading snapshot: %w", err)
+	}
+
+	m.mux.Lock()
+	defer m.unlock()
+	now := m.clock.Now()
+	loaded := 0
+	for _, saved := range entries {
+		if _, exists := m.cache[saved.Key]; exists {
+			continue
+		}
+		e := &entry[V]{ready: make(chan struct{}), value: saved.Value}
+		close(e.ready)
+		if e.expiresAt = saved.ExpiresAt; e.expired(now) {
+			continue
+		}
+		m.cache[saved.Key] = e
+		m.store(saved.Key, e)
+		// store gives a new TTL, the saved expiration is kept
+		if !saved.ExpiresAt.IsZero() {
+			e.expiresAt = saved.ExpiresAt
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// SaveFile writes a snapshot to path atomically: it goes to a temporary file in the
+// same directory, renamed over path once complete, so a crash in the middle never
+// leaves a truncated snapshot behind
+func (m *Memory[K, V]) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file was renamed
+	defer os.Remove(tmp.Name())
+
+	if err := m.SaveTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	// The data must be on disk before the rename makes it the snapshot
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile reads a snapshot written by SaveFile; a missing file returns an error
+// matching os.ErrNotExist, which a first run can ignore
+func (m *Memory[K, V]) LoadFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return m.LoadFrom(f)
+}
@@ -0,0 +1,260 @@
+package cache
+
+// The eviction policy decides which result leaves a full cache (WithMaxEntries).
+// It is an example of the Strategy pattern: Memory only talks to the EvictionPolicy
+// interface, and the algorithm is chosen when the cache is created with WithPolicy.
+//
+// The policies ship with the cache:
+// - LRU evicts the least recently used result, good for most workloads
+// - LFU evicts the least frequently used result, good when a few keys are always hot
+// - FIFO evicts the oldest result, whatever its use; the cheapest one
+// - ARC balances recency and frequency by itself, and resists scans that read many keys once
+
+// EvictionPolicy tracks the cached keys and chooses the one to evict.
+// It is not thread-safe: Memory calls it with its mutex held.
+type EvictionPolicy[K comparable] interface {
+	Add(key K)        // A new result was stored for key
+	Access(key K)     // The result of key was read; keys not tracked are ignored
+	Remove(key K)     // The result of key was deleted or expired
+	Evict() (K, bool) // Chooses a key, stops tracking it and returns it; false when empty
+	Len() int         // Number of keys tracked
+}
+
+// Policy names an eviction policy for WithPolicy
+type Policy int
+
+// The eviction policies available, see the top of this file
+const (
+	LRU Policy = iota
+	LFU
+	FIFO
+	ARC
+)
+
+// String returns the name of the policy, as in the flags of the demos
+func (p Policy) String() string {
+	switch p {
+	case LRU:
+		return "LRU"
+	case LFU:
+		return "LFU"
+	case FIFO:
+		return "FIFO"
+	case ARC:
+		return "ARC"
+	}
+	return "unknown"
+}
+
+// newPolicy creates the implementation of kind for a cache of capacity results
+func newPolicy[K comparable](kind Policy, capacity int) EvictionPolicy[K] {
+	switch kind {
+	case LFU:
+		return newLFUPolicy[K]()
+	case FIFO:
+		return fifoPolicy[K]{newLRUList[K]()}
+	case ARC:
+		return newARCPolicy[K](capacity)
+	}
+	return newLRUList[K]()
+}
+
+// fifoPolicy is an lruList where reading a key doesn't move it: the oldest key
+// in the list is the first one stored
+type fifoPolicy[K comparable] struct {
+	*lruList[K]
+}
+
+// Access does nothing, only the order of insertion counts
+func (fifoPolicy[K]) Access(key K) {}
+
+// lfuPolicy groups the keys by number of uses. Each group is an lruList, so ties
+// are broken by recency, and min is the smallest count, so every operation is O(1)
+// except Remove, which may look for the new minimum.
+type lfuPolicy[K comparable] struct {
+	counts map[K]int
+	groups map[int]*lruList[K] // Keys by number of uses, only non-empty groups
+	min    int
+}
+
+func newLFUPolicy[K comparable]() *lfuPolicy[K] {
+	return &lfuPolicy[K]{counts: make(map[K]int), groups: make(map[int]*lruList[K])}
+}
+
+func (p *lfuPolicy[K]) Add(key K) {
+	if _, exists := p.counts[key]; exists {
+		p.Access(key)
+		return
+	}
+	p.move(key, 0, 1)
+	p.min = 1
+}
+
+func (p *lfuPolicy[K]) Access(key K) {
+	count, exists := p.counts[key]
+	if !exists {
+		return
+	}
+	p.move(key, count, count+1)
+	if _, left := p.groups[p.min]; !left && p.min == count {
+		p.min = count + 1
+	}
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	count, exists := p.counts[key]
+	if !exists {
+		return
+	}
+	p.move(key, count, 0)
+	if _, left := p.groups[count]; !left && count == p.min {
+		p.min = 0
+		for c := range p.groups {
+			if p.min == 0 || c < p.min {
+				p.min = c
+			}
+		}
+	}
+}
+
+func (p *lfuPolicy[K]) Evict() (K, bool) {
+	group, exists := p.groups[p.min]
+	if !exists {
+		var zero K
+		return zero, false
+	}
+	key, _ := group.Oldest()
+	p.Remove(key)
+	return key, true
+}
+
+func (p *lfuPolicy[K]) Len() int {
+	return len(p.counts)
+}
+
+// move takes key from the group of count from to the group of count to; 0 means none
+func (p *lfuPolicy[K]) move(key K, from, to int) {
+	if from > 0 {
+		group := p.groups[from]
+		group.Remove(key)
+		if group.Len() == 0 {
+			delete(p.groups, from)
+		}
+		delete(p.counts, key)
+	}
+	if to > 0 {
+		group, exists := p.groups[to]
+		if !exists {
+			group = newLRUList[K]()
+			p.groups[to] = group
+		}
+		group.Touch(key)
+		p.counts[key] = to
+	}
+}
+
+// arcPolicy is the Adaptive Replacement Cache of Megiddo and Modha.
+// recent holds the keys used once and frequent the keys used again; the ghost lists
+// remember the keys recently evicted from each one, without their values. A miss on
+// a ghost means that list was too short, so target, the size wanted for recent,
+// moves towards it: the policy adapts to the workload.
+type arcPolicy[K comparable] struct {
+	capacity       int
+	target         int // Wanted length of recent, between 0 and capacity
+	recent         *lruList[K]
+	frequent       *lruList[K]
+	recentGhosts   *lruList[K]
+	frequentGhosts *lruList[K]
+	added          K    // Last key added, it is not the one to evict
+	addedRecent    bool // added went to recent
+	addedGhost     bool // added was a ghost of frequent
+}
+
+func newARCPolicy[K comparable](capacity int) *arcPolicy[K] {
+	return &arcPolicy[K]{
+		capacity:       capacity,
+		recent:         newLRUList[K](),
+		frequent:       newLRUList[K](),
+		recentGhosts:   newLRUList[K](),
+		frequentGhosts: newLRUList[K](),
+	}
+}
+
+func (p *arcPolicy[K]) Add(key K) {
+	p.added, p.addedRecent, p.addedGhost = key, false, false
+	switch {
+	case p.recent.Contains(key) || p.frequent.Contains(key):
+		p.Access(key)
+	case p.recentGhosts.Contains(key):
+		// recent was too short to keep it: make it longer
+		p.target = min(p.capacity, p.target+max(p.frequentGhosts.Len()/p.recentGhosts.Len(), 1))
+		p.recentGhosts.Remove(key)
+		p.frequent.Touch(key)
+	case p.frequentGhosts.Contains(key):
+		// frequent was too short to keep it: make recent shorter
+		p.target = max(0, p.target-max(p.recentGhosts.Len()/p.frequentGhosts.Len(), 1))
+		p.frequentGhosts.Remove(key)
+		p.frequent.Touch(key)
+		p.addedGhost = true
+	default:
+		p.recent.Touch(key)
+		p.addedRecent = true
+	}
+}
+
+func (p *arcPolicy[K]) Access(key K) {
+	switch {
+	case p.recent.Contains(key):
+		// Used a second time: it is frequent now
+		p.recent.Remove(key)
+		p.frequent.Touch(key)
+	case p.frequent.Contains(key):
+		p.frequent.Touch(key)
+	}
+}
+
+func (p *arcPolicy[K]) Remove(key K) {
+	p.recent.Remove(key)
+	p.frequent.Remove(key)
+}
+
+// Evict takes the least recent key of recent when it is longer than the target,
+// of frequent otherwise, and remembers it in the matching ghost list
+func (p *arcPolicy[K]) Evict() (K, bool) {
+	recentLen := p.recent.Len()
+	if p.addedRecent && p.recent.Contains(p.added) {
+		// The target is about the keys before the one that caused the eviction
+		recentLen--
+	}
+	fromRecent := recentLen > 0 && (recentLen > p.target || (p.addedGhost && recentLen == p.target))
+	if p.frequent.Len() == 0 {
+		fromRecent = true
+	}
+
+	list, ghosts := p.frequent, p.frequentGhosts
+	if fromRecent {
+		list, ghosts = p.recent, p.recentGhosts
+	}
+	key, exists := list.Oldest()
+	if !exists {
+		return key, false
+	}
+	list.Remove(key)
+	ghosts.Touch(key)
+
+	// The ghosts are bounded too: recent plus its ghosts at most capacity,
+	// everything at most twice the capacity
+	for p.recent.Len()+p.recentGhosts.Len() > p.capacity && p.recentGhosts.Len() > 0 {
+		oldest, _ := p.recentGhosts.Oldest()
+		p.recentGhosts.Remove(oldest)
+	}
+	for p.Len()+p.recentGhosts.Len()+p.frequentGhosts.Len() > 2*p.capacity && p.frequentGhosts.Len() > 0 {
+		oldest, _ := p.frequentGhosts.Oldest()
+		p.frequentGhosts.Remove(oldest)
+	}
+	return key, true
+}
+
+func (p *arcPolicy[K]) Len() int {
+	return p.recent.Len() + p.frequent.Len()
+}
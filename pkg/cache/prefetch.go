@@ -0,0 +1,52 @@
+package cache
+
+import "sync"
+
+// prefetchHistory is the number of recent keys given to the predictor
+const prefetchHistory = 8
+
+// prefetcher calculates the keys a predictor expects to be read next, so their Get
+// finds them ready: for a cached Fibonacci, a read of n is often followed by n+1 and n+2
+type prefetcher[K comparable, V any] struct {
+	predict func(lastKeys []K) []K
+	workers chan struct{} // One slot per running prediction
+	recent  []K           // The last keys read, oldest first
+	mux     sync.Mutex
+}
+
+// Prefetch makes every Get call predict with the last keys read, oldest first, and
+// calculate the keys it returns in the background, so the next Gets are hits. At most
+// workers predictions run at once; when they are all busy a new one is skipped rather
+// than queued, prefetching is only a bet. The prefetched calls count in Stats.Prefetches,
+// not as misses. predict runs on every Get, the nested Gets of a recursive function
+// included, so it must be quick; nil stops prefetching.
+func (m *Memory[K, V]) Prefetch(predict func(lastKeys []K) []K, workers int) {
+	if predict == nil {
+		m.prefetcher.Store(nil)
+		return
+	}
+	m.prefetcher.Store(&prefetcher[K, V]{predict: predict, workers: make(chan struct{}, max(workers, 1))})
+}
+
+// after records key as read and starts a prediction, if a worker is free
+func (p *prefetcher[K, V]) after(m *Memory[K, V], key K) {
+	p.mux.Lock()
+	if len(p.recent) == prefetchHistory {
+		p.recent = p.recent[1:]
+	}
+	p.recent = append(p.recent, key)
+	lastKeys := append([]K(nil), p.recent...)
+	p.mux.Unlock()
+
+	select {
+	case p.workers <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-p.workers }()
+		for _, next := range p.predict(lastKeys) {
+			m.get(next, true)
+		}
+	}()
+}
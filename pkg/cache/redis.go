@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/connpool"
+	"github.com/Arcanm/go_advanced_course/pkg/resp"
+)
+
+// RedisStore is a Store on a Redis server (or 03-Net/MiniRedis), so several processes
+// share the second layer of a Layered cache: what one of them calculates, the others read.
+// The values are encoded with a Codec and the commands go over pooled connections
+// (see pkg/connpool), so concurrent misses don't open a connection each.
+type RedisStore[K comparable, V any] struct {
+	addr    string
+	prefix  string        // Namespace of the keys, e.g. "fib:"
+	ttl     time.Duration // Expiration of the keys in Redis, 0 means none
+	timeout time.Duration // Maximum time of a command
+	codec   Codec
+	pool    *connpool.Pool
+}
+
+// NewRedisStore creates a store on the server at addr with up to maxConns connections.
+// The keys are prefix followed by the key written with fmt, and expire after ttl.
+// The values are encoded with codec, nil means Gob.
+func NewRedisStore[K comparable, V any](addr, prefix string, ttl time.Duration, maxConns int, codec Codec) *RedisStore[K, V] {
+	return &RedisStore[K, V]{
+		addr:    addr,
+		prefix:  prefix,
+		ttl:     ttl,
+		timeout: 2 * time.Second,
+		codec:   orGob(codec),
+		pool:    connpool.New(connpool.Options{MaxActive: maxConns, MaxIdle: maxConns}),
+	}
+}
+
+func (s *RedisStore[K, V]) Get(key K) (V, error) {
+	var value V
+	reply, err := s.do("GET", s.key(key))
+	if err != nil {
+		return value, err
+	}
+	if reply == nil {
+		return value, ErrNotFound
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return value, fmt.Errorf("redis: unexpected reply %T to GET", reply)
+	}
+	err = decodeValue(s.codec, data, &value)
+	return value, err
+}
+
+func (s *RedisStore[K, V]) Set(key K, value V) error {
+	data, err := encodeValue(s.codec, value)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", s.key(key), data}
+	if s.ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(s.ttl.Milliseconds(), 10))
+	}
+	_, err = s.do(args...)
+	return err
+}
+
+func (s *RedisStore[K, V]) Delete(key K) error {
+	_, err := s.do("DEL", s.key(key))
+	return err
+}
+
+// Close closes the idle connections
+func (s *RedisStore[K, V]) Close() error {
+	return s.pool.Close()
+}
+
+func (s *RedisStore[K, V]) key(key K) string {
+	return s.prefix + fmt.Sprint(key)
+}
+
+// do sends a command and returns the reply, a RedisError reply as error.
+// A connection that failed in the middle of a command is discarded: the next
+// reply read from it would belong to this command.
+func (s *RedisStore[K, V]) do(args ...string) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	conn, err := s.pool.Get(ctx, s.addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	writer := bufio.NewWriter(conn)
+	resp.WriteCommand(writer, args)
+	if err := writer.Flush(); err != nil {
+		conn.Discard()
+		return nil, err
+	}
+	reply, err := resp.ReadReply(bufio.NewReader(conn))
+	if err != nil {
+		conn.Discard()
+		return nil, err
+	}
+	conn.Release()
+	if redisErr, isErr := reply.(resp.RedisError); isErr {
+		return nil, redisErr
+	}
+	return reply, nil
+}
+
+// encodeValue serializes a value for a remote store
+func encodeValue[V any](codec Codec, value V) (string, error) {
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("encoding value: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeValue reads a value written by encodeValue
+func decodeValue[V any](codec Codec, data string, value *V) error {
+	if err := codec.Unmarshal([]byte(data), value); err != nil {
+		return fmt.Errorf("decoding value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A program with several memoized functions has several caches of different types.
+// The Registry pattern of 02-DesignPatterns/Registry gives them a well known place:
+// each cache is registered under a name, and the registry enumerates them, adds up
+// their stats and flushes them all at once, without knowing their key and value types.
+
+// Errors returned by the registry
+var (
+	ErrDuplicateCache = errors.New("cache already registered")
+	ErrUnknownCache   = errors.New("unknown cache")
+)
+
+// Cache is what the registry needs from a cache: Memory and Sharded implement it
+type Cache interface {
+	Stats() Stats
+	Clear()
+	Close() error
+}
+
+// Registry holds caches by name; it is safe for concurrent use
+type Registry struct {
+	caches map[string]Cache
+	mux    sync.RWMutex
+}
+
+// NewRegistry creates an empty registry; a program usually keeps one in a package-level
+// variable, like http.DefaultServeMux is its default mux
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]Cache)}
+}
+
+// Register adds cache under name
+func (r *Registry) Register(name string, cache Cache) error {
+	if cache == nil {
+		return fmt.Errorf("cache %q: cache is nil", name)
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, exists := r.caches[name]; exists {
+		return fmt.Errorf("%w: %q", ErrDuplicateCache, name)
+	}
+	r.caches[name] = cache
+	return nil
+}
+
+// Unregister removes the cache registered under name and reports whether there was one.
+// The cache is not closed, it still belongs to whoever created it.
+func (r *Registry) Unregister(name string) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	_, exists := r.caches[name]
+	delete(r.caches, name)
+	return exists
+}
+
+// Get returns the cache registered under name; assert its type to use it:
+//
+//	fib, ok := cache.(*Memory[int, *big.Int])
+func (r *Registry) Get(name string) (Cache, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	cache, exists := r.caches[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCache, name)
+	}
+	return cache, nil
+}
+
+// Names returns the sorted names of the registered caches
+func (r *Registry) Names() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the stats of every cache by name, and their total
+func (r *Registry) Stats() (byName map[string]Stats, total Stats) {
+	byName = make(map[string]Stats)
+	for name, cache := range r.snapshot() {
+		stats := cache.Stats()
+		byName[name] = stats
+		total = total.add(stats)
+	}
+	return byName, total
+}
+
+// Clear empties every registered cache
+func (r *Registry) Clear() {
+	for _, cache := range r.snapshot() {
+		cache.Clear()
+	}
+}
+
+// Close closes every registered cache and returns their errors joined
+func (r *Registry) Close() error {
+	var errs []error
+	for name, cache := range r.snapshot() {
+		if err := cache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("cache %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshot copies the map, so the caches are called without the lock of the registry:
+// a cache calling the registry from an OnEvict callback must not deadlock
+func (r *Registry) snapshot() map[string]Cache {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	snapshot := make(map[string]Cache, len(r.caches))
+	for name, cache := range r.caches {
+		snapshot[name] = cache
+	}
+	return snapshot
+}
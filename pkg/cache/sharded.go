@@ -0,0 +1,75 @@
+package cache
+
+import "hash/maphash"
+
+// Sharded splits the keys over several Memory instances, each with its own mutex, so
+// goroutines working on different keys rarely wait for the same lock. It keeps every
+// feature of Memory, but each shard applies the options alone: WithMaxEntries(n)
+// bounds every shard to n results, and eviction chooses among the keys of one shard.
+type Sharded[K comparable, V any] struct {
+	shards []*Memory[K, V]
+	seed   maphash.Seed
+}
+
+// NewSharded creates a cache of count shards, each one a NewCache(f, options...).
+// The function receives the Memory of the shard of its key: a recursive function
+// calling m.Get would cache its sub-results in that shard, so it should call the
+// Get of the Sharded cache instead.
+func NewSharded[K comparable, V any](f Function[K, V], count int, options ...Option) *Sharded[K, V] {
+	s := &Sharded[K, V]{shards: make([]*Memory[K, V], max(count, 1)), seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i] = NewCache(f, options...)
+	}
+	return s
+}
+
+// shard returns the Memory holding key
+func (s *Sharded[K, V]) shard(key K) *Memory[K, V] {
+	return s.shards[maphash.Comparable(s.seed, key)%uint64(len(s.shards))]
+}
+
+// Get returns the result of key from its shard, see Memory.Get
+func (s *Sharded[K, V]) Get(key K) (V, error) {
+	return s.shard(key).Get(key)
+}
+
+// Set stores value as the result of key in its shard
+func (s *Sharded[K, V]) Set(key K, value V) {
+	s.shard(key).Set(key, value)
+}
+
+// Delete removes the result of key and reports whether the key was in the cache
+func (s *Sharded[K, V]) Delete(key K) bool {
+	return s.shard(key).Delete(key)
+}
+
+// Close stops the janitors of the shards, see Memory.Close
+func (s *Sharded[K, V]) Close() error {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+	return nil
+}
+
+// Clear removes every result of every shard
+func (s *Sharded[K, V]) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// SetCost sets the cost function of every shard, see Memory.SetCost
+func (s *Sharded[K, V]) SetCost(cost func(key K, value V) int64) {
+	for _, shard := range s.shards {
+		shard.SetCost(cost)
+	}
+}
+
+// Stats adds up the counters of the shards; MaxLoad is the slowest of them
+func (s *Sharded[K, V]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		total = total.add(shard.Stats())
+	}
+	return total
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"expvar"
+	"time"
+)
+
+// Stats are the counters of a Memory since it was created
+type Stats struct {
+	Hits       int64         // Gets answered from the cache, waiting for a calculation in progress included
+	Misses     int64         // Gets that called the function
+	Errors     int64         // Calls to the function that failed
+	Refreshes  int64         // Background refreshes of stale results, see WithStaleWhileRevalidate
+	Prefetches int64         // Results calculated ahead of their Get, see Prefetch
+	Evictions  int64         // Results removed to respect WithMaxEntries or WithMaxCost
+	Expired    int64         // Results removed by the janitor after their TTL
+	Entries    int           // Results in the cache right now
+	Cost       int64         // Total cost of the results right now, with WithMaxCost
+	LoadTime   time.Duration // Total time spent in the function, nested Gets of recursive functions included
+	MaxLoad    time.Duration // Slowest call to the function
+}
+
+// HitRatio returns the share of Gets answered without calling the function, between 0 and 1
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AvgLoad returns the average duration of a call to the function
+func (s Stats) AvgLoad() time.Duration {
+	calls := s.Misses + s.Refreshes + s.Prefetches
+	if calls == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(calls)
+}
+
+// Saved estimates the time the cache saved: every hit would have cost an average call
+func (s Stats) Saved() time.Duration {
+	return time.Duration(s.Hits) * s.AvgLoad()
+}
+
+// add returns the sum of two sets of counters, for the caches made of several Memory;
+// MaxLoad is the slowest of the two
+func (s Stats) add(other Stats) Stats {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Errors += other.Errors
+	s.Refreshes += other.Refreshes
+	s.Prefetches += other.Prefetches
+	s.Evictions += other.Evictions
+	s.Expired += other.Expired
+	s.Entries += other.Entries
+	s.Cost += other.Cost
+	s.LoadTime += other.LoadTime
+	s.MaxLoad = max(s.MaxLoad, other.MaxLoad)
+	return s
+}
+
+// Stats returns a snapshot of the counters
+func (m *Memory[K, V]) Stats() Stats {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	stats := m.stats
+	stats.Entries = len(m.cache)
+	stats.Cost = m.totalCost
+	return stats
+}
+
+// Publish exposes the counters as the expvar variable name, served in JSON by
+// the /debug/vars handler of expvar. Like expvar.Publish, it panics if the name is used.
+func (m *Memory[K, V]) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.Stats()
+	}))
+}
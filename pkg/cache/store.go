@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned by Store.Get for keys without a value
+var ErrNotFound = errors.New("cache: key not found")
+
+// Store is a slower backing layer behind a Memory (a directory, Redis, another process),
+// see Layered. The implementations must be safe for concurrent use.
+type Store[K comparable, V any] interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(key K) (V, error)
+	// Set saves the value of key, replacing the previous one
+	Set(key K, value V) error
+	// Delete removes key; deleting a missing key is not an error
+	Delete(key K) error
+}
+
+// MapStore is a Store in a map, for the checks and as the simplest example
+type MapStore[K comparable, V any] struct {
+	values map[K]V
+	mux    sync.Mutex
+}
+
+// NewMapStore creates an empty MapStore
+func NewMapStore[K comparable, V any]() *MapStore[K, V] {
+	return &MapStore[K, V]{values: make(map[K]V)}
+}
+
+func (s *MapStore[K, V]) Get(key K) (V, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	value, exists := s.values[key]
+	if !exists {
+		return value, ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *MapStore[K, V]) Set(key K, value V) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *MapStore[K, V]) Delete(key K) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// FileStore keeps every value in its own file of a directory, encoded with a Codec, so
+// the values survive the process and can be shared by the programs on the same machine.
+// The file name is a hash of the key, written with fmt, so any comparable key works.
+type FileStore[K comparable, V any] struct {
+	dir   string
+	codec Codec
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed;
+// the values are encoded with codec, nil means Gob
+func NewFileStore[K comparable, V any](dir string, codec Codec) (*FileStore[K, V], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore[K, V]{dir: dir, codec: orGob(codec)}, nil
+}
+
+// path returns the file of key
+func (s *FileStore[K, V]) path(key K) string {
+	return filepath.Join(s.dir, hashKey(key))
+}
+
+// hashKey turns any comparable key into 32 hex characters, a safe file name or
+// memcached key whatever the key contains. The key is written with %#v, so 1 and "1"
+// are different keys.
+func hashKey[K comparable](key K) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", key)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *FileStore[K, V]) Get(key K) (V, error) {
+	var value V
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return value, ErrNotFound
+	}
+	if err != nil {
+		return value, err
+	}
+	err = s.codec.Unmarshal(data, &value)
+	return value, err
+}
+
+// Set writes the value to a temporary file renamed over the previous one,
+// so a concurrent Get never reads half a value
+func (s *FileStore[K, V]) Set(key K, value V) error {
+	tmp, err := os.CreateTemp(s.dir, "set-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	data, err := s.codec.Marshal(value)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore[K, V]) Delete(key K) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SyncMemory is Memory built on sync.Map instead of a map and a mutex. sync.Map is
+// optimized for the two cases named in its documentation: keys written once and read
+// many times, and goroutines working on disjoint sets of keys. Reads of an existing
+// key then take no lock at all, so they don't contend however many cores read.
+// The price is everything that needs to see the whole cache at once: sync.Map can't
+// order its keys nor count them cheaply, so SyncMemory has no TTL, no eviction and no
+// stats. The benchmarks of 01-Concurrency/Cache show when it wins.
+type SyncMemory[K comparable, V any] struct {
+	f     SyncFunction[K, V]
+	cache sync.Map // K to *entry[V], the same promises as Memory
+}
+
+// SyncFunction is Function for a SyncMemory
+type SyncFunction[K comparable, V any] func(key K, m *SyncMemory[K, V]) (V, error)
+
+// NewSyncCache creates a SyncMemory caching the results of f
+func NewSyncCache[K comparable, V any](f SyncFunction[K, V]) *SyncMemory[K, V] {
+	return &SyncMemory[K, V]{f: f}
+}
+
+// Get returns the result of key, calling the function once on a miss like Memory.Get:
+// LoadOrStore lets a single caller store the promise, the others wait on it
+func (m *SyncMemory[K, V]) Get(key K) (V, error) {
+	if cached, exists := m.cache.Load(key); exists {
+		e := cached.(*entry[V])
+		<-e.ready
+		return e.value, e.err
+	}
+
+	e := &entry[V]{ready: make(chan struct{})}
+	if cached, loaded := m.cache.LoadOrStore(key, e); loaded {
+		// Another goroutine stored its promise first
+		e = cached.(*entry[V])
+		<-e.ready
+		return e.value, e.err
+	}
+	e.value, e.err = m.load(key)
+	if e.err != nil {
+		// Errors are not cached; CompareAndDelete leaves a value set meanwhile
+		m.cache.CompareAndDelete(key, e)
+	}
+	close(e.ready)
+	return e.value, e.err
+}
+
+// load calls the function for key, turning a panic into an error wrapping ErrPanic like
+// Memory.load does
+func (m *SyncMemory[K, V]) load(key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w for key %v: %v", ErrPanic, key, r)
+		}
+	}()
+	return m.f(key, m)
+}
+
+// Set stores value as the result of key, replacing the current one
+func (m *SyncMemory[K, V]) Set(key K, value V) {
+	e := &entry[V]{ready: make(chan struct{}), value: value}
+	close(e.ready)
+	m.cache.Store(key, e)
+}
+
+// Delete removes the result of key and reports whether the key was in the cache
+func (m *SyncMemory[K, V]) Delete(key K) bool {
+	_, existed := m.cache.LoadAndDelete(key)
+	return existed
+}
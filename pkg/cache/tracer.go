@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// A Tracer follows the operations of a cache: every Get, every call to the function
+// and every eviction opens a span, finished with its duration and outcome. The shape
+// is the one of OpenTelemetry, so an adapter is a few lines in a program that has it:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) Start(op TraceOp, key any) Span {
+//		_, span := t.tracer.Start(context.Background(), string(op),
+//			trace.WithAttributes(attribute.String("cache.key", fmt.Sprint(key))))
+//		return otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ span trace.Span }
+//
+//	func (s otelSpan) Finish(info TraceInfo) {
+//		s.span.SetAttributes(attribute.Bool("cache.hit", info.Hit))
+//		if info.Err != nil {
+//			s.span.RecordError(info.Err)
+//		}
+//		s.span.End()
+//	}
+
+// TraceOp names a traced operation
+type TraceOp string
+
+const (
+	TraceGet   TraceOp = "cache.get"   // A Get, from the call to the result
+	TraceLoad  TraceOp = "cache.load"  // A call to the function, for a miss, a refresh or a prefetch
+	TraceEvict TraceOp = "cache.evict" // A result left the cache: expired, evicted or deleted
+)
+
+// Tracer starts the spans; it is called concurrently, never with the lock of the cache
+// held, and must be safe for concurrent use
+type Tracer interface {
+	Start(op TraceOp, key any) Span
+}
+
+// Span is an operation in progress
+type Span interface {
+	Finish(info TraceInfo)
+}
+
+// TraceInfo is the outcome of an operation
+type TraceInfo struct {
+	Duration time.Duration // Measured with the system time, like Stats; zero for evictions
+	Hit      bool          // For TraceGet: the result was in the cache, or being calculated
+	Err      error         // The error of the Get or the function
+}
+
+// WithTracer sends the spans of the cache to tracer, see tracer.go
+func WithTracer(tracer Tracer) Option {
+	return func(c *config) {
+		c.tracer = tracer
+	}
+}
+
+// evictionTracer turns the eviction events into spans; the evictions happen with the
+// lock held, the events are the way out of it that the observers already use
+type evictionTracer[K comparable, V any] struct {
+	tracer Tracer
+}
+
+func (t evictionTracer[K, V]) GetId() string {
+	return "tracer"
+}
+
+func (t evictionTracer[K, V]) UpdateValue(_ context.Context, event Event[K, V]) error {
+	t.tracer.Start(TraceEvict, event.Key).Finish(TraceInfo{})
+	return nil
+}
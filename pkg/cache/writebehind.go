@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// In write-behind mode, Layered.Set and Delete only change the Memory and queue the
+// write; a background worker applies the queued writes to the store in batches. The
+// callers don't wait for a slow store, and several writes of the same key between two
+// flushes cost a single write. The price is durability: writes still queued are lost if
+// the program crashes, so Close must be called to flush them on exit.
+
+// WriteBehindOptions configures the write-behind mode; zero fields take the defaults
+type WriteBehindOptions struct {
+	BatchSize int           // Queued keys that trigger a flush before Interval, default 100
+	Interval  time.Duration // Longest time a write stays queued, default 1s
+	Retries   int           // Attempts after a failed write of a batch, default 3
+	Backoff   time.Duration // Wait before the first retry, doubled for each next one, default 50ms
+}
+
+// pendingWrite is the last write queued for a key
+type pendingWrite[V any] struct {
+	value  V
+	delete bool
+}
+
+// writeBehind queues the writes of a Layered and flushes them from one goroutine
+type writeBehind[K comparable, V any] struct {
+	opts     WriteBehindOptions
+	back     Store[K, V]
+	pending  map[K]pendingWrite[V] // The last write of every queued key
+	inflight map[K]pendingWrite[V] // The batch being flushed, never modified
+	closed   bool
+	mux      sync.Mutex // Protects the maps and closed, never held while writing to the store
+
+	wake     chan struct{}   // Buffered: the queue reached BatchSize
+	flushes  chan chan error // Flush requests, answered with the errors of the flush
+	stop     chan struct{}   // Closed by Close
+	stopped  chan struct{}   // Closed by the worker after its last flush
+	closeErr error           // Errors of the last flush, read after stopped
+}
+
+// NewLayeredWriteBehind is NewLayered in write-behind mode, see the top of this file
+func NewLayeredWriteBehind[K comparable, V any](f Function[K, V], back Store[K, V], opts WriteBehindOptions, options ...Option) *Layered[K, V] {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 50 * time.Millisecond
+	}
+	w := &writeBehind[K, V]{
+		opts:    opts,
+		back:    back,
+		pending: make(map[K]pendingWrite[V]),
+		wake:    make(chan struct{}, 1),
+		flushes: make(chan chan error),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	l := NewLayered(f, w, options...)
+	l.writeBehind = w
+	go w.run()
+	return l
+}
+
+// The writeBehind is the Store seen by the front of the Layered: reads look at the queue
+// and the batch being flushed before the store, so a key evicted from the Memory with
+// a write still queued doesn't come back with the older value of the store
+
+func (w *writeBehind[K, V]) Get(key K) (V, error) {
+	w.mux.Lock()
+	write, queued := w.pending[key]
+	if !queued {
+		write, queued = w.inflight[key]
+	}
+	w.mux.Unlock()
+	if queued {
+		if write.delete {
+			var zero V
+			return zero, ErrNotFound
+		}
+		return write.value, nil
+	}
+	return w.back.Get(key)
+}
+
+func (w *writeBehind[K, V]) Set(key K, value V) error {
+	return w.queue(key, pendingWrite[V]{value: value})
+}
+
+func (w *writeBehind[K, V]) Delete(key K) error {
+	return w.queue(key, pendingWrite[V]{delete: true})
+}
+
+// queue replaces the write queued for key. After Close the write goes to the store
+// directly, once the last flush is done so it can't overwrite the new value.
+func (w *writeBehind[K, V]) queue(key K, write pendingWrite[V]) error {
+	w.mux.Lock()
+	if w.closed {
+		w.mux.Unlock()
+		<-w.stopped
+		return w.apply(key, write)
+	}
+	w.pending[key] = write
+	full := len(w.pending) >= w.opts.BatchSize
+	w.mux.Unlock()
+	if full {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+			// A flush is already due
+		}
+	}
+	return nil
+}
+
+// run flushes the queue every Interval, when it is full and when asked, until Close
+func (w *writeBehind[K, V]) run() {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.wake:
+			w.flush()
+		case done := <-w.flushes:
+			done <- w.flush()
+		case <-w.stop:
+			w.closeErr = w.flush()
+			close(w.stopped)
+			return
+		}
+	}
+}
+
+// flush writes the queued writes to the store, retrying the failed ones with backoff.
+// Writes that still fail are queued again, unless a newer write of the key replaced
+// them meanwhile, and their errors are returned joined.
+func (w *writeBehind[K, V]) flush() error {
+	w.mux.Lock()
+	batch := maps.Clone(w.pending)
+	w.inflight, w.pending = w.pending, make(map[K]pendingWrite[V])
+	w.mux.Unlock()
+
+	backoff := w.opts.Backoff
+	failed := make(map[K]error)
+	for attempt := 0; len(batch) > 0 && attempt <= w.opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		for key, write := range batch {
+			if err := w.apply(key, write); err != nil {
+				failed[key] = err
+				continue
+			}
+			delete(batch, key)
+		}
+	}
+
+	var errs []error
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.inflight = nil
+	for key, write := range batch {
+		errs = append(errs, fmt.Errorf("key %v: %w", key, failed[key]))
+		if _, newer := w.pending[key]; !newer && !w.closed {
+			w.pending[key] = write
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// apply writes one queued write to the store
+func (w *writeBehind[K, V]) apply(key K, write pendingWrite[V]) error {
+	if write.delete {
+		return w.back.Delete(key)
+	}
+	return w.back.Set(key, write.value)
+}
+
+// Flush writes the queued writes now and waits for them; it returns the errors of
+// the writes that failed after the retries, which stay queued for the next flush.
+// In write-through mode there is nothing to flush.
+func (l *Layered[K, V]) Flush() error {
+	w := l.writeBehind
+	if w == nil {
+		return nil
+	}
+	done := make(chan error)
+	select {
+	case w.flushes <- done:
+		return <-done
+	case <-w.stopped:
+		return nil
+	}
+}
+
+// Close stops the janitor of the Memory and, in write-behind mode, flushes the queue and
+// stops the worker; the writes after Close go to the store directly. It returns the
+// errors of the writes that could not be flushed, those writes are lost.
+func (l *Layered[K, V]) Close() error {
+	l.front.Close()
+	w := l.writeBehind
+	if w == nil {
+		return nil
+	}
+	w.mux.Lock()
+	if w.closed {
+		w.mux.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mux.Unlock()
+	close(w.stop)
+	<-w.stopped
+	return w.closeErr
+}
@@ -0,0 +1,19 @@
+// Package clock is the source of time of the code that waits or dates things, so its
+// tests can replace the time of the system with a Fake that only moves when told to.
+// The caches of pkg/cache expire with it, and the accounts of pkg/account pay their
+// interest with it; the limiters of pkg/throttle refill with it.
+package clock
+
+import "time"
+
+// Clock tells the time and waits for it
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the time of the system, the default Clock
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
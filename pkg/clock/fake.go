@@ -0,0 +1,68 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when Advance is called: in a test, a TTL of an hour
+// expires instantly and always at the same point
+type Fake struct {
+	now     time.Time
+	waiters []fakeWaiter
+	mux     sync.Mutex
+}
+
+// fakeWaiter is a channel returned by After and the time it fires
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake creates a clock stopped at start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once Advance reaches now+d
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the After channels that are due
+func (c *Fake) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// Waiters returns how many After channels have not fired yet; a test waits for the
+// code under test to be waiting before advancing, so the tick isn't missed
+func (c *Fake) Waiters() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.waiters)
+}
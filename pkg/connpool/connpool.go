@@ -0,0 +1,298 @@
+// Package connpool keeps a bounded set of reusable TCP connections per address.
+//
+// Opening a connection costs a round trip (and a TLS handshake on top), so clients that send
+// many small requests to the same servers borrow a connection with Get, use it, and give it
+// back with Release for the next caller. A connection left in a bad state (an error in the
+// middle of a message) is closed with Discard instead.
+//
+// Every address has at most MaxActive connections in use plus idle; Get waits for a free slot.
+// Idle connections are closed after IdleTimeout and checked before being handed out, so a
+// server that closed its side is noticed before the caller writes a request into a dead socket.
+package connpool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Get after Close
+var ErrClosed = errors.New("connpool: pool closed")
+
+// DialFunc opens a new connection to addr
+type DialFunc func(ctx context.Context, addr string) (net.Conn, error)
+
+// Options configures a Pool; zero fields take the defaults
+type Options struct {
+	Dial        DialFunc      // Default: a net.Dialer with a 5s timeout over TCP
+	MaxActive   int           // Connections per address, borrowed plus idle, default 16
+	MaxIdle     int           // Idle connections kept per address, default 4
+	IdleTimeout time.Duration // Idle connections older than this are closed, default 1m
+	// HealthCheck runs on an idle connection before handing it out, default Alive
+	HealthCheck func(net.Conn) error
+}
+
+// Pool is safe for concurrent use
+type Pool struct {
+	opts   Options
+	hosts  map[string]*host
+	closed bool
+	mux    sync.Mutex
+}
+
+// host holds the connections of one address
+type host struct {
+	open int           // Borrowed plus idle, at most MaxActive
+	idle []idleConn    // Most recently used last
+	wait chan struct{} // Closed and replaced when a connection comes back
+}
+
+type idleConn struct {
+	conn  net.Conn
+	since time.Time
+}
+
+// New creates an empty pool
+func New(opts Options) *Pool {
+	if opts.Dial == nil {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		opts.Dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}
+	}
+	if opts.MaxActive <= 0 {
+		opts.MaxActive = 16
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 4
+	}
+	opts.MaxIdle = min(opts.MaxIdle, opts.MaxActive)
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = time.Minute
+	}
+	if opts.HealthCheck == nil {
+		opts.HealthCheck = Alive
+	}
+	return &Pool{opts: opts, hosts: make(map[string]*host)}
+}
+
+// Conn is a borrowed connection; it must be given back with Release or Discard
+type Conn struct {
+	net.Conn
+	pool *Pool
+	addr string
+	done bool
+	mux  sync.Mutex
+}
+
+// Get borrows a connection to addr: an idle healthy one if any, otherwise a new one.
+// It waits while the address has MaxActive connections, until ctx is done.
+func (p *Pool) Get(ctx context.Context, addr string) (*Conn, error) {
+	p.mux.Lock()
+	for {
+		if p.closed {
+			p.mux.Unlock()
+			return nil, ErrClosed
+		}
+		h := p.host(addr)
+
+		// Reuse the most recent idle connection that is still healthy
+		if conn := p.popIdle(h); conn != nil {
+			p.mux.Unlock()
+			if p.opts.HealthCheck(conn) == nil {
+				return &Conn{Conn: conn, pool: p, addr: addr}, nil
+			}
+			conn.Close()
+			p.mux.Lock()
+			p.release(h)
+			continue
+		}
+
+		if h.open < p.opts.MaxActive {
+			h.open++
+			p.mux.Unlock()
+			conn, err := p.opts.Dial(ctx, addr)
+			if err != nil {
+				p.mux.Lock()
+				p.release(h)
+				p.mux.Unlock()
+				return nil, err
+			}
+			return &Conn{Conn: conn, pool: p, addr: addr}, nil
+		}
+
+		// Every connection is borrowed, wait for one to come back
+		wait := h.wait
+		p.mux.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mux.Lock()
+	}
+}
+
+// host returns the connections of addr, p.mux must be held
+func (p *Pool) host(addr string) *host {
+	h, exists := p.hosts[addr]
+	if !exists {
+		h = &host{wait: make(chan struct{})}
+		p.hosts[addr] = h
+	}
+	return h
+}
+
+// popIdle takes the most recent idle connection, closing the expired ones; p.mux must be held
+func (p *Pool) popIdle(h *host) net.Conn {
+	for len(h.idle) > 0 {
+		last := h.idle[len(h.idle)-1]
+		h.idle = h.idle[:len(h.idle)-1]
+		if time.Since(last.since) < p.opts.IdleTimeout {
+			return last.conn
+		}
+		last.conn.Close()
+		p.release(h)
+	}
+	return nil
+}
+
+// release forgets a closed connection; p.mux must be held
+func (p *Pool) release(h *host) {
+	h.open--
+	p.notify(h)
+}
+
+// notify wakes up the callers waiting for a connection of h; p.mux must be held
+func (p *Pool) notify(h *host) {
+	close(h.wait)
+	h.wait = make(chan struct{})
+}
+
+// Release gives the connection back to the pool for reuse. Calling Release or
+// Discard again does nothing.
+func (c *Conn) Release() {
+	if !c.finish() {
+		return
+	}
+	p := c.pool
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	h := p.hosts[c.addr]
+	if p.closed || len(h.idle) >= p.opts.MaxIdle {
+		c.Conn.Close()
+		p.release(h)
+		return
+	}
+	// Clear the deadlines set by the borrower so they don't hit the next one
+	c.Conn.SetDeadline(time.Time{})
+	h.idle = append(h.idle, idleConn{conn: c.Conn, since: time.Now()})
+	p.notify(h)
+}
+
+// Discard closes the connection instead of reusing it, e.g. after an I/O error
+func (c *Conn) Discard() error {
+	if !c.finish() {
+		return nil
+	}
+	err := c.Conn.Close()
+	c.pool.mux.Lock()
+	c.pool.release(c.pool.hosts[c.addr])
+	c.pool.mux.Unlock()
+	return err
+}
+
+// Close is Discard, so a borrowed connection can be used as an io.Closer
+func (c *Conn) Close() error {
+	return c.Discard()
+}
+
+// finish marks the connection as given back, it reports false if it already was
+func (c *Conn) finish() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.done {
+		return false
+	}
+	c.done = true
+	return true
+}
+
+// Stats describes the connections of one address
+type Stats struct {
+	Open int // Borrowed plus idle
+	Idle int
+}
+
+// Stats returns the counters of addr
+func (p *Pool) Stats(addr string) Stats {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	h, exists := p.hosts[addr]
+	if !exists {
+		return Stats{}
+	}
+	return Stats{Open: h.open, Idle: len(h.idle)}
+}
+
+// Prune closes the idle connections older than IdleTimeout; call it
+// periodically to release sockets of addresses that are no longer used
+func (p *Pool) Prune() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, h := range p.hosts {
+		kept := h.idle[:0]
+		for _, idle := range h.idle {
+			if time.Since(idle.since) < p.opts.IdleTimeout {
+				kept = append(kept, idle)
+				continue
+			}
+			idle.conn.Close()
+			p.release(h)
+		}
+		h.idle = kept
+	}
+}
+
+// Close closes the idle connections and makes Get fail; borrowed
+// connections are closed when they are released
+func (p *Pool) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.closed = true
+	for _, h := range p.hosts {
+		for _, idle := range h.idle {
+			idle.conn.Close()
+			p.release(h)
+		}
+		h.idle = nil
+	}
+	return nil
+}
+
+// Alive checks an idle connection: an idle connection has nothing to read, so data or
+// EOF means the server closed it or broke the protocol. TCP sockets are peeked without
+// waiting; other connections (TLS, net.Pipe) get a read that must time out after 1ms.
+func Alive(conn net.Conn) error {
+	if checked, err := peek(conn); checked {
+		return err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil
+	}
+	if err == nil {
+		return errUnexpectedData
+	}
+	return err
+}
+
+var errUnexpectedData = errors.New("connpool: unexpected data on an idle connection")
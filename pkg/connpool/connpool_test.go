@@ -0,0 +1,286 @@
+package connpool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server accepts TCP connections and keeps them until the end of the test
+type server struct {
+	addr  string
+	conns chan net.Conn
+}
+
+func startServer(t *testing.T) *server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{addr: listener.Addr().String(), conns: make(chan net.Conn, 64)}
+	var accepted []net.Conn
+	var mux sync.Mutex
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mux.Lock()
+			accepted = append(accepted, conn)
+			mux.Unlock()
+			s.conns <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		mux.Lock()
+		defer mux.Unlock()
+		for _, conn := range accepted {
+			conn.Close()
+		}
+	})
+	return s
+}
+
+// countingDial dials TCP and counts the new connections
+func countingDial(dials *int, mux *sync.Mutex) DialFunc {
+	var dialer net.Dialer
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		mux.Lock()
+		*dials++
+		mux.Unlock()
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// TestReuse checks that released connections are handed out again instead of dialing
+func TestReuse(t *testing.T) {
+	s := startServer(t)
+	var dials int
+	var mux sync.Mutex
+	pool := New(Options{Dial: countingDial(&dials, &mux)})
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background(), s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := first.LocalAddr().String()
+	first.Release()
+	if got, want := pool.Stats(s.addr), (Stats{Open: 1, Idle: 1}); got != want {
+		t.Fatalf("after Release got %+v, want %+v", got, want)
+	}
+
+	second, err := pool.Get(context.Background(), s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.LocalAddr().String() != local || dials != 1 {
+		t.Fatalf("got a new connection after Release, %d dials", dials)
+	}
+
+	// Discard closes the connection, the next Get dials again
+	second.Discard()
+	second.Release() // Giving it back twice does nothing
+	if got, want := pool.Stats(s.addr), (Stats{}); got != want {
+		t.Fatalf("after Discard got %+v, want %+v", got, want)
+	}
+	third, err := pool.Get(context.Background(), s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Release()
+	if dials != 2 {
+		t.Fatalf("got %d dials, want 2", dials)
+	}
+}
+
+// TestMaxIdle checks that the connections given back beyond MaxIdle are closed
+func TestMaxIdle(t *testing.T) {
+	s := startServer(t)
+	pool := New(Options{MaxActive: 5, MaxIdle: 2})
+	defer pool.Close()
+
+	conns := make([]*Conn, 5)
+	for i := range conns {
+		conn, err := pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn
+	}
+	for _, conn := range conns {
+		conn.Release()
+	}
+	if got, want := pool.Stats(s.addr), (Stats{Open: 2, Idle: 2}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestMaxActive checks that Get waits while the address has MaxActive connections
+func TestMaxActive(t *testing.T) {
+	s := startServer(t)
+	pool := New(Options{MaxActive: 2})
+	defer pool.Close()
+
+	var borrowed []*Conn
+	for range 2 {
+		conn, err := pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		borrowed = append(borrowed, conn)
+	}
+
+	t.Run("ctx done while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := pool.Get(ctx, s.addr); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("released connection wakes the waiter", func(t *testing.T) {
+		got := make(chan *Conn, 1)
+		go func() {
+			conn, err := pool.Get(context.Background(), s.addr)
+			if err != nil {
+				t.Error(err)
+			}
+			got <- conn
+		}()
+		select {
+		case <-got:
+			t.Fatal("Get returned with MaxActive connections borrowed")
+		case <-time.After(50 * time.Millisecond):
+		}
+		borrowed[0].Release()
+		select {
+		case conn := <-got:
+			if conn != nil {
+				conn.Release()
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Get still waits after a Release")
+		}
+	})
+
+	borrowed[1].Release()
+	if got := pool.Stats(s.addr); got.Open > 2 {
+		t.Fatalf("got %d open connections, want at most 2", got.Open)
+	}
+}
+
+// TestIdleChecks checks that dead and expired idle connections are not handed out
+func TestIdleChecks(t *testing.T) {
+	t.Run("server closed its side", func(t *testing.T) {
+		s := startServer(t)
+		pool := New(Options{})
+		defer pool.Close()
+		conn, err := pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local := conn.LocalAddr().String()
+		conn.Release()
+		(<-s.conns).Close()
+		time.Sleep(20 * time.Millisecond) // Let the FIN arrive
+
+		conn, err = pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Release()
+		if conn.LocalAddr().String() == local {
+			t.Fatal("got the connection closed by the server")
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		s := startServer(t)
+		pool := New(Options{IdleTimeout: 20 * time.Millisecond})
+		defer pool.Close()
+		conn, err := pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Release()
+		time.Sleep(40 * time.Millisecond)
+		pool.Prune()
+		if got, want := pool.Stats(s.addr), (Stats{}); got != want {
+			t.Fatalf("after Prune got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("closed pool", func(t *testing.T) {
+		s := startServer(t)
+		pool := New(Options{})
+		conn, err := pool.Get(context.Background(), s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Close()
+		conn.Release()
+		if got, want := pool.Stats(s.addr), (Stats{}); got != want {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+		if _, err := pool.Get(context.Background(), s.addr); !errors.Is(err, ErrClosed) {
+			t.Fatalf("got %v, want %v", err, ErrClosed)
+		}
+	})
+}
+
+// TestAlive checks the health check on TCP sockets, peeked, and on net.Pipe, read with a deadline
+func TestAlive(t *testing.T) {
+	connect := map[string]func(t *testing.T) (client, server net.Conn){
+		"tcp": func(t *testing.T) (net.Conn, net.Conn) {
+			s := startServer(t)
+			client, err := net.Dial("tcp", s.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { client.Close() })
+			return client, <-s.conns
+		},
+		"pipe": func(t *testing.T) (net.Conn, net.Conn) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { client.Close(); server.Close() })
+			return client, server
+		},
+	}
+	for name, connect := range connect {
+		t.Run(name, func(t *testing.T) {
+			t.Run("idle", func(t *testing.T) {
+				client, _ := connect(t)
+				start := time.Now()
+				if err := Alive(client); err != nil {
+					t.Fatalf("got %v, want nil", err)
+				}
+				if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+					t.Fatalf("the check took %s", elapsed)
+				}
+			})
+			t.Run("closed by the server", func(t *testing.T) {
+				client, server := connect(t)
+				server.Close()
+				time.Sleep(20 * time.Millisecond)
+				if err := Alive(client); err == nil {
+					t.Fatal("got nil, want an error")
+				}
+			})
+			t.Run("unexpected data", func(t *testing.T) {
+				client, server := connect(t)
+				go server.Write([]byte("x"))
+				time.Sleep(20 * time.Millisecond)
+				if err := Alive(client); !errors.Is(err, errUnexpectedData) {
+					t.Fatalf("got %v, want %v", err, errUnexpectedData)
+				}
+			})
+		})
+	}
+}
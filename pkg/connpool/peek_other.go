@@ -0,0 +1,10 @@
+//go:build !unix
+
+package connpool
+
+import "net"
+
+// peek has no non-blocking check outside unix, Alive falls back to a short read
+func peek(net.Conn) (checked bool, err error) {
+	return false, nil
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package connpool
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// peek looks at the socket of conn with MSG_PEEK, which leaves the data in place. The
+// runtime keeps its sockets non-blocking, so an empty socket fails with EAGAIN right away
+// instead of waiting. checked is false when conn is not a socket.
+func peek(conn net.Conn) (checked bool, err error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false, nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, nil
+	}
+	var n int
+	var peekErr error
+	err = raw.Read(func(fd uintptr) bool {
+		var b [1]byte
+		n, _, peekErr = syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK)
+		return true // Don't park until the socket is readable
+	})
+	switch {
+	case err != nil:
+		return true, err
+	case errors.Is(peekErr, syscall.EAGAIN), errors.Is(peekErr, syscall.EWOULDBLOCK):
+		return true, nil
+	case peekErr != nil:
+		return true, peekErr
+	case n == 0:
+		return true, io.EOF
+	}
+	return true, errUnexpectedData
+}
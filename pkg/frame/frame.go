@@ -0,0 +1,163 @@
+// Package frame splits a byte stream into length-prefixed messages.
+//
+// Newline-delimited protocols break as soon as a payload contains a newline, and a peer
+// that never sends one makes the reader buffer forever. Here every frame starts with its
+// length as a 4-byte big-endian integer, followed by exactly that many bytes:
+//
+//	+--------+--------+--------+--------+----------------------+
+//	|         length (uint32, BE)       |  payload (length B)  |
+//	+--------+--------+--------+--------+----------------------+
+//
+// The reader checks the length against a maximum before allocating anything, so a corrupt
+// or hostile header can't make it allocate gigabytes.
+//
+// Encoder and Decoder add a Codec on top, JSON by default; pkg/frame/protoframe provides
+// one for protobuf messages. The NetCAT chat sends its messages as JSON frames, and
+// pkg/cache encodes its values with protoframe.
+package frame
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// HeaderSize is the size of the length prefix
+const HeaderSize = 4
+
+// DefaultMaxSize is the largest payload accepted when the maximum is 0
+const DefaultMaxSize = 1 << 20
+
+// ErrTooLarge is returned for frames bigger than the maximum, in both directions
+var ErrTooLarge = errors.New("frame: payload too large")
+
+// Writer writes frames; it is safe for concurrent use and never interleaves two frames
+type Writer struct {
+	w       io.Writer
+	maxSize int
+	mux     sync.Mutex
+}
+
+// NewWriter creates a Writer; maxSize 0 means DefaultMaxSize
+func NewWriter(w io.Writer, maxSize int) *Writer {
+	return &Writer{w: w, maxSize: orDefault(maxSize)}
+}
+
+// WriteFrame writes the header and the payload with a single Write
+func (w *Writer) WriteFrame(payload []byte) error {
+	if len(payload) > w.maxSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrTooLarge, len(payload), w.maxSize)
+	}
+	buf := make([]byte, HeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[HeaderSize:], payload)
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Reader reads frames; it is not safe for concurrent use
+type Reader struct {
+	r       io.Reader
+	maxSize int
+	header  [HeaderSize]byte
+}
+
+// NewReader creates a Reader; maxSize 0 means DefaultMaxSize
+func NewReader(r io.Reader, maxSize int) *Reader {
+	return &Reader{r: r, maxSize: orDefault(maxSize)}
+}
+
+// ReadFrame returns the next payload. It returns io.EOF when the stream ends between
+// frames and io.ErrUnexpectedEOF when it ends in the middle of one. After ErrTooLarge
+// the stream is out of sync and the connection should be closed.
+func (r *Reader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(r.header[:])
+	if uint64(size) > uint64(r.maxSize) {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrTooLarge, size, r.maxSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+func orDefault(maxSize int) int {
+	if maxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return maxSize
+}
+
+// Codec turns values into payloads and back
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSON is the default codec
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// Encoder writes one value per frame
+type Encoder struct {
+	w     *Writer
+	codec Codec
+}
+
+// NewEncoder creates an Encoder; a nil codec means JSON
+func NewEncoder(w io.Writer, maxSize int, codec Codec) *Encoder {
+	if codec == nil {
+		codec = JSON
+	}
+	return &Encoder{w: NewWriter(w, maxSize), codec: codec}
+}
+
+// Encode marshals v and writes it as a frame
+func (e *Encoder) Encode(v any) error {
+	payload, err := e.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return e.w.WriteFrame(payload)
+}
+
+// Decoder reads one value per frame
+type Decoder struct {
+	r     *Reader
+	codec Codec
+}
+
+// NewDecoder creates a Decoder; a nil codec means JSON
+func NewDecoder(r io.Reader, maxSize int, codec Codec) *Decoder {
+	if codec == nil {
+		codec = JSON
+	}
+	return &Decoder{r: NewReader(r, maxSize), codec: codec}
+}
+
+// Decode reads the next frame and unmarshals it into v. A payload that doesn't
+// unmarshal doesn't break the stream, the next Decode reads the next frame.
+func (d *Decoder) Decode(v any) error {
+	payload, err := d.r.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return d.codec.Unmarshal(payload, v)
+}
@@ -0,0 +1,145 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// header builds the length prefix of a frame of size bytes
+func header(size uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, size)
+}
+
+// TestReadFrame checks the reader against well-formed, truncated and oversized input
+func TestReadFrame(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   []byte
+		maxSize int
+		want    [][]byte
+		wantErr error // Returned after the frames of want
+	}{
+		{name: "empty stream", input: nil, wantErr: io.EOF},
+		{name: "one frame", input: append(header(5), "hello"...), want: [][]byte{[]byte("hello")}, wantErr: io.EOF},
+		{name: "zero-length frame", input: append(header(0), append(header(1), 'x')...), want: [][]byte{{}, []byte("x")}, wantErr: io.EOF},
+		{name: "payload with newlines", input: append(header(4), "a\nb\n"...), want: [][]byte{[]byte("a\nb\n")}, wantErr: io.EOF},
+		{name: "short header", input: []byte{0, 0}, wantErr: io.ErrUnexpectedEOF},
+		{name: "header without payload", input: header(3), wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated payload", input: append(header(10), "short"...), wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated after a frame", input: append(append(header(1), 'a'), 0), want: [][]byte{[]byte("a")}, wantErr: io.ErrUnexpectedEOF},
+		{name: "at the maximum", input: append(header(4), "abcd"...), maxSize: 4, want: [][]byte{[]byte("abcd")}, wantErr: io.EOF},
+		{name: "over the maximum", input: append(header(5), "abcde"...), maxSize: 4, wantErr: ErrTooLarge},
+		{name: "hostile header", input: header(0xFFFFFFFF), wantErr: ErrTooLarge},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewReader(bytes.NewReader(c.input), c.maxSize)
+			for i, want := range c.want {
+				got, err := r.ReadFrame()
+				if err != nil || !bytes.Equal(got, want) {
+					t.Fatalf("frame %d: got %q, %v, want %q", i, got, err, want)
+				}
+			}
+			if _, err := r.ReadFrame(); !errors.Is(err, c.wantErr) {
+				t.Fatalf("got %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+// TestWriteFrame checks the bytes on the wire and the maximum on the writing side
+func TestWriteFrame(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload []byte
+		maxSize int
+		want    []byte
+		wantErr error
+	}{
+		{name: "payload", payload: []byte("hi"), want: []byte{0, 0, 0, 2, 'h', 'i'}},
+		{name: "zero-length", payload: nil, want: []byte{0, 0, 0, 0}},
+		{name: "at the maximum", payload: []byte("abc"), maxSize: 3, want: []byte{0, 0, 0, 3, 'a', 'b', 'c'}},
+		{name: "over the maximum", payload: []byte("abcd"), maxSize: 3, wantErr: ErrTooLarge},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewWriter(&buf, c.maxSize).WriteFrame(c.payload)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("got %v, want %v", err, c.wantErr)
+			}
+			// Nothing reaches the stream when the frame is refused
+			if !bytes.Equal(buf.Bytes(), c.want) {
+				t.Fatalf("got %v, want %v", buf.Bytes(), c.want)
+			}
+		})
+	}
+}
+
+// TestConcurrentWrites checks that frames written from several goroutines don't interleave
+func TestConcurrentWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 0)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.WriteFrame(bytes.Repeat([]byte{byte('a' + i)}, 1000))
+		}()
+	}
+	wg.Wait()
+
+	r := NewReader(&buf, 0)
+	for range 10 {
+		payload, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payload) != 1000 || strings.Count(string(payload), string(payload[0])) != 1000 {
+			t.Fatalf("got an interleaved frame of %d bytes", len(payload))
+		}
+	}
+}
+
+// TestCodec round-trips values with the JSON codec and checks that a bad payload
+// doesn't break the stream
+func TestCodec(t *testing.T) {
+	type message struct {
+		From string
+		Text string
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, 0, nil)
+	sent := []message{{From: "ana", Text: "hello\nworld"}, {From: "bob"}}
+	if err := enc.Encode(sent[0]); err != nil {
+		t.Fatal(err)
+	}
+	NewWriter(&buf, 0).WriteFrame([]byte("{not json"))
+	if err := enc.Encode(sent[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(&buf, 0, nil)
+	var got message
+	if err := dec.Decode(&got); err != nil || got != sent[0] {
+		t.Fatalf("got %+v, %v, want %+v", got, err, sent[0])
+	}
+	if err := dec.Decode(&got); err == nil {
+		t.Fatal("a bad payload decoded without error")
+	}
+	got = message{}
+	if err := dec.Decode(&got); err != nil || got != sent[1] {
+		t.Fatalf("got %+v, %v, want %+v", got, err, sent[1])
+	}
+	if err := dec.Decode(&got); err != io.EOF {
+		t.Fatalf("got %v, want EOF", err)
+	}
+}
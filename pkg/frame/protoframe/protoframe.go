@@ -0,0 +1,33 @@
+// Package protoframe is the protobuf Codec of pkg/frame. It lives in its own package so
+// the programs sending JSON frames don't depend on google.golang.org/protobuf.
+//
+//	enc := frame.NewEncoder(conn, 0, protoframe.Codec)
+//	enc.Encode(&pb.Request{Key: "k"})
+package protoframe
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals proto.Message values; any other value is an error
+var Codec = codec{}
+
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protoframe: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protoframe: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,72 @@
+package protoframe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/Arcanm/go_advanced_course/pkg/frame"
+)
+
+// TestRoundTrip writes protobuf messages as frames and reads them back
+func TestRoundTrip(t *testing.T) {
+	record, err := structpb.NewStruct(map[string]any{"key": "k", "ttl": 30.0, "tags": []any{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		sent proto.Message
+		into proto.Message
+	}{
+		{name: "string", sent: wrapperspb.String("line\nbreak"), into: &wrapperspb.StringValue{}},
+		{name: "empty message", sent: &wrapperspb.StringValue{}, into: &wrapperspb.StringValue{}},
+		{name: "bytes", sent: wrapperspb.Bytes([]byte{0, 1, 2, 0xFF}), into: &wrapperspb.BytesValue{}},
+		{name: "nested", sent: record, into: &structpb.Struct{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := frame.NewEncoder(&buf, 0, Codec).Encode(c.sent); err != nil {
+				t.Fatal(err)
+			}
+			dec := frame.NewDecoder(&buf, 0, Codec)
+			if err := dec.Decode(c.into); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(c.into, c.sent) {
+				t.Fatalf("got %v, want %v", c.into, c.sent)
+			}
+			if err := dec.Decode(c.into); err != io.EOF {
+				t.Fatalf("got %v, want EOF after the only frame", err)
+			}
+		})
+	}
+}
+
+// TestErrors checks the values that are not messages and the payloads that are not protobuf
+func TestErrors(t *testing.T) {
+	if _, err := Codec.Marshal("not a message"); err == nil {
+		t.Error("Marshal accepted a string")
+	}
+	if err := Codec.Unmarshal(nil, new(string)); err == nil {
+		t.Error("Unmarshal accepted a *string")
+	}
+	// Field 1 declared as a length of 10 bytes with only 1 after it
+	if err := Codec.Unmarshal([]byte{0x0A, 10, 'x'}, &wrapperspb.StringValue{}); err == nil {
+		t.Error("Unmarshal accepted a truncated message")
+	}
+
+	// A frame over the maximum is refused before the message is sent
+	var buf bytes.Buffer
+	big := wrapperspb.Bytes(make([]byte, 100))
+	if err := frame.NewEncoder(&buf, 50, Codec).Encode(big); !errors.Is(err, frame.ErrTooLarge) || buf.Len() != 0 {
+		t.Errorf("got %v with %d bytes written, want %v and nothing written", err, buf.Len(), frame.ErrTooLarge)
+	}
+}
@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of the host is open
+var ErrCircuitOpen = errors.New("httpclient: circuit open")
+
+// State of a circuit breaker
+type State int
+
+const (
+	Closed   State = iota // Requests go through, failures are counted
+	Open                  // Requests fail fast until the cooldown ends
+	HalfOpen              // One trial request decides whether to close or open again
+)
+
+func (s State) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+// BreakerOptions configures a Breaker
+type BreakerOptions struct {
+	Threshold int           // Consecutive failures that open the circuit, default 5
+	Cooldown  time.Duration // Time open before a trial request, default 10s
+}
+
+// Breaker is a consecutive-failures circuit breaker, safe for concurrent use
+type Breaker struct {
+	opts     BreakerOptions
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // A trial request is in flight while half-open
+	mux      sync.Mutex
+}
+
+// NewBreaker creates a closed breaker
+func NewBreaker(opts BreakerOptions) *Breaker {
+	setDefault(&opts.Threshold, 5)
+	setDefault(&opts.Cooldown, 10*time.Second)
+	return &Breaker{opts: opts}
+}
+
+// Allow reports whether a request may be sent now
+func (b *Breaker) Allow() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.opts.Cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return true
+	case HalfOpen:
+		// Only the trial request goes through until it finishes
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// Record stores the outcome of a request allowed by Allow
+func (b *Breaker) Record(success bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if success {
+		b.state, b.failures, b.trial = Closed, 0, false
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.opts.Threshold {
+		b.state, b.openedAt, b.trial = Open, time.Now(), false
+	}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.opts.Cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,301 @@
+// Package httpclient wraps http.Client with the defaults a program talking to remote
+// services needs and that the zero http.Client doesn't have:
+//   - Timeouts for the whole request and for every phase (dial, TLS, response headers)
+//   - A limit of connections per host, so one slow host can't take every socket
+//   - Retries with exponential backoff and jitter for network errors, 429 and 5xx answers,
+//     honoring Retry-After, and only for requests that are safe to repeat
+//   - A circuit breaker per host (see breaker.go) that fails fast while a host is down
+//   - Hooks to log every attempt and every response
+//
+// The webhooks of 02-DesignPatterns/Observer post through it; like them, the callers can
+// depend on Doer, satisfied by both *http.Client and *Client.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Doer is implemented by *http.Client and *Client
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Options configures a Client; zero fields take the defaults of DefaultOptions
+type Options struct {
+	Timeout               time.Duration // Whole request, including retries
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxConnsPerHost       int
+
+	Retries    int           // Extra attempts after the first one, -1 disables retries
+	Backoff    time.Duration // Pause before the first retry, doubled after each one
+	MaxBackoff time.Duration
+
+	// Breaker creates the circuit breaker of each host, nil disables them
+	Breaker *BreakerOptions
+
+	// OnRequest is called before every attempt, attempt starts at 1
+	OnRequest func(req *http.Request, attempt int)
+	// OnResponse is called after every attempt with the response or the error
+	OnResponse func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+}
+
+// DefaultOptions returns the defaults used for the zero fields
+func DefaultOptions() Options {
+	return Options{
+		Timeout:               30 * time.Second,
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxConnsPerHost:       10,
+		Retries:               2,
+		Backoff:               100 * time.Millisecond,
+		MaxBackoff:            2 * time.Second,
+	}
+}
+
+// Client is a hardened HTTP client, safe for concurrent use
+type Client struct {
+	http     *http.Client
+	opts     Options
+	breakers map[string]*Breaker // One per host
+	mux      sync.Mutex
+}
+
+// New creates a client with its own transport
+func New(opts Options) *Client {
+	defaults := DefaultOptions()
+	setDefault(&opts.Timeout, defaults.Timeout)
+	setDefault(&opts.DialTimeout, defaults.DialTimeout)
+	setDefault(&opts.TLSHandshakeTimeout, defaults.TLSHandshakeTimeout)
+	setDefault(&opts.ResponseHeaderTimeout, defaults.ResponseHeaderTimeout)
+	setDefault(&opts.IdleConnTimeout, defaults.IdleConnTimeout)
+	setDefault(&opts.MaxConnsPerHost, defaults.MaxConnsPerHost)
+	setDefault(&opts.Retries, defaults.Retries)
+	setDefault(&opts.Backoff, defaults.Backoff)
+	setDefault(&opts.MaxBackoff, defaults.MaxBackoff)
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}
+	return &Client{
+		// The timeout of the whole call is applied by Do with a context, so it covers the retries
+		http:     &http.Client{Transport: transport},
+		opts:     opts,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
+
+// Get is a shortcut for a GET request
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post is a shortcut for a POST request. POST is only retried when the request
+// has an Idempotency-Key header, see Retryable.
+func (c *Client) Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// Do sends the request, retrying it while the error is temporary. The response of the
+// last attempt is returned as is, so a 503 after every retry is a response, not an error.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.opts.Timeout)
+	breaker := c.breaker(req.URL.Host)
+
+	attempts := 1
+	if Retryable(req) && c.opts.Retries > 0 {
+		attempts += c.opts.Retries
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			delay := c.delay(attempt-1, resp)
+			// The failed response is dropped whatever the sleep returns: release its
+			// connection before waiting
+			if resp != nil {
+				discard(resp.Body)
+			}
+			if err := sleep(ctx, delay); err != nil {
+				cancel()
+				return nil, err
+			}
+		}
+		if breaker != nil && !breaker.Allow() {
+			cancel()
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, req.URL.Host)
+		}
+
+		attemptReq, rewindErr := rewind(req.WithContext(ctx), attempt)
+		if rewindErr != nil {
+			cancel()
+			return nil, rewindErr
+		}
+		if c.opts.OnRequest != nil {
+			c.opts.OnRequest(attemptReq, attempt)
+		}
+		start := time.Now()
+		resp, err = c.http.Do(attemptReq)
+		if c.opts.OnResponse != nil {
+			c.opts.OnResponse(attemptReq, resp, err, time.Since(start))
+		}
+
+		failed := err != nil || resp.StatusCode >= 500
+		if breaker != nil {
+			breaker.Record(!failed)
+		}
+		if !temporary(resp, err) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The context must live until the body is read, the body cancels it when closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// breaker returns the circuit breaker of host, nil when breakers are disabled
+func (c *Client) breaker(host string) *Breaker {
+	if c.opts.Breaker == nil {
+		return nil
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	b, exists := c.breakers[host]
+	if !exists {
+		b = NewBreaker(*c.opts.Breaker)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// BreakerState returns the state of the breaker of host
+func (c *Client) BreakerState(host string) State {
+	if b := c.breaker(host); b != nil {
+		return b.State()
+	}
+	return Closed
+}
+
+// Retryable reports whether req may be sent again: idempotent methods, or any
+// method with an Idempotency-Key header, and only if the body can be rewound
+func Retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// temporary reports whether the attempt failed in a way the next one may fix
+func temporary(resp *http.Response, err error) bool {
+	if err != nil {
+		// A cancelled or expired context won't get better
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// delay returns the pause before retry n: the Retry-After of the last response if any,
+// otherwise the exponential backoff with up to 50% of jitter, so clients don't retry in sync
+func (c *Client) delay(n int, last *http.Response) time.Duration {
+	if last != nil {
+		if seconds, err := strconv.Atoi(last.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, c.opts.MaxBackoff)
+		}
+	}
+	backoff := min(c.opts.Backoff<<(n-1), c.opts.MaxBackoff)
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// rewind returns the request for an attempt with a fresh copy of the body
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+// discard reads what is left of a small body, so the transport can reuse the
+// connection, and closes it
+func discard(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelBody releases the context of the request when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,226 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusServer answers the statuses in order, then 200 once they run out
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// TestRetries checks which answers are retried and which are returned as they are
+func TestRetries(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		header     http.Header
+		statuses   []int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "success", method: http.MethodGet, wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "temporary failures", method: http.MethodGet, statuses: []int{503, 502}, wantStatus: http.StatusOK, wantCalls: 3},
+		{name: "too many requests", method: http.MethodGet, statuses: []int{429}, wantStatus: http.StatusOK, wantCalls: 2},
+		{name: "last failure is a response", method: http.MethodGet, statuses: []int{503, 503, 503}, wantStatus: http.StatusServiceUnavailable, wantCalls: 3},
+		{name: "client error", method: http.MethodGet, statuses: []int{404}, wantStatus: http.StatusNotFound, wantCalls: 1},
+		{name: "internal error", method: http.MethodGet, statuses: []int{500}, wantStatus: http.StatusInternalServerError, wantCalls: 1},
+		{name: "POST is not repeated", method: http.MethodPost, statuses: []int{503}, wantStatus: http.StatusServiceUnavailable, wantCalls: 1},
+		{name: "POST with an idempotency key", method: http.MethodPost, header: http.Header{"Idempotency-Key": {"k1"}}, statuses: []int{503}, wantStatus: http.StatusOK, wantCalls: 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, calls := statusServer(t, c.statuses...)
+			client := New(Options{Retries: 2, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+			req, err := http.NewRequest(c.method, server.URL, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, values := range c.header {
+				req.Header[key] = values
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != c.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, c.wantStatus)
+			}
+			if got := calls.Load(); got != c.wantCalls {
+				t.Errorf("got %d calls, want %d", got, c.wantCalls)
+			}
+			// Every attempt sends the whole body again
+			if resp.StatusCode == http.StatusOK && string(body) != "payload" {
+				t.Errorf("got body %q, want %q", body, "payload")
+			}
+		})
+	}
+}
+
+// TestDelay checks the backoff and the Retry-After header
+func TestDelay(t *testing.T) {
+	client := New(Options{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {value}}}
+	}
+
+	cases := []struct {
+		name     string
+		n        int
+		last     *http.Response
+		min, max time.Duration
+	}{
+		{name: "first retry", n: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "doubled", n: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped", n: 10, min: 500 * time.Millisecond, max: time.Second},
+		{name: "retry after", n: 1, last: retryAfter("0"), min: 0, max: 0},
+		{name: "retry after capped", n: 1, last: retryAfter("120"), min: time.Second, max: time.Second},
+		{name: "retry after as a date is ignored", n: 1, last: retryAfter("Wed, 21 Oct 2015 07:28:00 GMT"), min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for range 20 {
+				if got := client.delay(c.n, c.last); got < c.min || got > c.max {
+					t.Fatalf("got %s, want between %s and %s", got, c.min, c.max)
+				}
+			}
+		})
+	}
+}
+
+// closeTracker is a body that records whether it was closed
+type closeTracker struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+// TestBodyClosedBeforeBackoff checks that the failed response is closed even when the
+// context ends during the pause before the retry
+func TestBodyClosedBeforeBackoff(t *testing.T) {
+	client := New(Options{Backoff: time.Minute, MaxBackoff: time.Minute})
+	body := &closeTracker{Reader: strings.NewReader("try later")}
+	client.http.Transport = roundTripper(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: body}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	resp, err := client.Get(ctx, "http://example.test/")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, %v, want %v", resp, err, context.DeadlineExceeded)
+	}
+	if !body.closed.Load() {
+		t.Fatal("the body of the failed attempt was not closed")
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestBreaker checks that the breaker of a failing host opens and fails fast
+func TestBreaker(t *testing.T) {
+	server, calls := statusServer(t, 500, 500, 500, 500)
+	client := New(Options{Retries: -1, Breaker: &BreakerOptions{Threshold: 2, Cooldown: 50 * time.Millisecond}})
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for range 2 {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if state := client.BreakerState(host); state != Open {
+		t.Fatalf("got state %s after 2 failures, want %s", state, Open)
+	}
+	if _, err := client.Get(context.Background(), server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("got %d calls, want 2: the open breaker let a request through", got)
+	}
+
+	// After the cooldown one trial request goes through, a failure opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if state := client.BreakerState(host); state != Open {
+		t.Fatalf("got state %s after a failed trial, want %s", state, Open)
+	}
+	if state := client.BreakerState("other.test"); state != Closed {
+		t.Fatalf("got state %s for another host, want %s", state, Closed)
+	}
+}
+
+// TestMaxConnsPerHost checks that concurrent requests to one host share MaxConnsPerHost connections
+func TestMaxConnsPerHost(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+	client := New(Options{MaxConnsPerHost: 2})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("got %d requests at once, want at most 2", got)
+	}
+}
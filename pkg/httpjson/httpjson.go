@@ -0,0 +1,47 @@
+// Package httpjson writes the JSON answers of the HTTP services: a value with its status,
+// or an error as {"error": "..."} with the status its Statuses table gives it. The
+// products API of 03-Net/HTTPServer, the URL shortener and the bank of
+// 01-Concurrency/RaceConditions-Mutex answer with it.
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Write writes v as the JSON response body with the given status
+func Write(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Error writes {"error": "..."}; internal errors are not shown to the client
+func Error(w http.ResponseWriter, status int, err error) {
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = http.StatusText(status)
+	}
+	Write(w, status, map[string]string{"error": message})
+}
+
+// ErrorStatus is the status answered for an error and the errors wrapping it
+type ErrorStatus struct {
+	Err    error
+	Status int
+}
+
+// Statuses maps the errors of a service to HTTP status codes
+type Statuses []ErrorStatus
+
+// Of returns the status of the first entry that err matches with errors.Is,
+// 500 when none does
+func (s Statuses) Of(err error) int {
+	for _, entry := range s {
+		if errors.Is(err, entry.Err) {
+			return entry.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
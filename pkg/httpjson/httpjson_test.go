@@ -0,0 +1,65 @@
+package httpjson
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var (
+	errMissing = errors.New("missing")
+	errTaken   = errors.New("taken")
+)
+
+// TestStatuses checks the lookup of wrapped errors and the default
+func TestStatuses(t *testing.T) {
+	statuses := Statuses{
+		{errMissing, http.StatusNotFound},
+		{errTaken, http.StatusConflict},
+	}
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "sentinel", err: errMissing, want: http.StatusNotFound},
+		{name: "wrapped", err: fmt.Errorf("code abc: %w", errTaken), want: http.StatusConflict},
+		{name: "first match wins", err: errors.Join(errTaken, errMissing), want: http.StatusNotFound},
+		{name: "unknown", err: errors.New("disk full"), want: http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := statuses.Of(c.err); got != c.want {
+				t.Errorf("got %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+// TestWrite checks the bodies, and that internal errors don't reach the client
+func TestWrite(t *testing.T) {
+	cases := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		code  int
+		body  string
+	}{
+		{name: "value", write: func(w http.ResponseWriter) { Write(w, http.StatusCreated, map[string]int{"id": 1}) }, code: http.StatusCreated, body: `{"id":1}` + "\n"},
+		{name: "client error", write: func(w http.ResponseWriter) { Error(w, http.StatusNotFound, errMissing) }, code: http.StatusNotFound, body: `{"error":"missing"}` + "\n"},
+		{name: "internal error", write: func(w http.ResponseWriter) { Error(w, http.StatusInternalServerError, errors.New("db password wrong")) }, code: http.StatusInternalServerError, body: `{"error":"Internal Server Error"}` + "\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.write(rec)
+			if rec.Code != c.code || rec.Body.String() != c.body {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), c.code, c.body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("got Content-Type %q", got)
+			}
+		})
+	}
+}
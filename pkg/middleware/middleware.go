@@ -0,0 +1,161 @@
+// Package middleware is the middleware chain of 02-DesignPatterns/Middleware, shared by
+// the HTTP services of 03-Net: a Middleware wraps an http.Handler, and a Chain applies
+// several of them so the first one receives the request first. RequestID, Logging,
+// Recovery and Auth are the usual layers of a service.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware wraps a handler with extra behavior
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middlewares
+type Chain []Middleware
+
+// NewChain creates a chain; the first middleware is the outermost layer
+func NewChain(middlewares ...Middleware) Chain {
+	return append(Chain(nil), middlewares...)
+}
+
+// Append returns a new chain with extra middlewares added at the end
+func (c Chain) Append(middlewares ...Middleware) Chain {
+	return append(append(Chain(nil), c...), middlewares...)
+}
+
+// Then wraps the handler with every middleware of the chain.
+// Middlewares are applied in reverse so the first one receives the request first.
+func (c Chain) Then(handler http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		handler = c[i](handler)
+	}
+	return handler
+}
+
+// ThenFunc is a shortcut for Then(http.HandlerFunc(fn))
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// RequestIDHeader is the header used to propagate the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID reuses the incoming X-Request-ID header or generates a new ID,
+// stores it in the request context and returns it in the response headers
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the request ID stored by the RequestID middleware
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns 8 random bytes encoded in hex
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder captures the status code and size written by the next handlers
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Logging logs one line per request with its status, size and duration
+func Logging(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			logger.Printf("%s %s %s %d %dB %s", GetRequestID(r.Context()), r.Method, r.URL.Path,
+				recorder.status, recorder.bytes, time.Since(start))
+		})
+	}
+}
+
+// Recovery turns a panic in the next handlers into a 500 response instead of
+// killing the connection, and logs the panic with the request ID
+func Recovery(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					// http.ErrAbortHandler is used on purpose to abort a response
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					logger.Printf("%s panic: %v", GetRequestID(r.Context()), err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Auth only lets through requests with "Authorization: Bearer <token>" for one of the tokens
+func Auth(tokens ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if found {
+				for _, token := range tokens {
+					// Constant time comparison avoids leaking the token through timing
+					if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
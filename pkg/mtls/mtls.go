@@ -0,0 +1,231 @@
+// Package mtls builds tls.Configs for mutual TLS between the nodes of a system.
+//
+// With mutual TLS both sides present a certificate signed by a private CA, so a server
+// knows which node is calling and a node knows it reached a real server. On top of the
+// chain verification, the peer must have one of the allowed SANs (DNS names, IPs, URIs
+// like spiffe://cluster/node-1, or emails): a valid certificate of the CA is not enough
+// to impersonate another node.
+//
+// Certificates are short-lived in practice, so a Reloader re-reads the files when they
+// change (Watch) and every new handshake uses the new material without a restart.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSANNotAllowed is returned when the peer certificate has none of the allowed SANs
+	ErrSANNotAllowed = errors.New("mtls: peer certificate SAN not allowed")
+	// ErrNoServerName is returned by a client that has no name to check the server against
+	ErrNoServerName = errors.New("mtls: no server name to verify")
+)
+
+// Config names the PEM files and the peers accepted
+type Config struct {
+	CAFile   string // CA bundle used to verify the peer
+	CertFile string // Certificate chain of this node
+	KeyFile  string // Private key of this node
+
+	// AllowedSANs are the identities accepted from the peer; empty accepts any
+	// certificate of the CA. An entry may start with "*." to match one DNS label.
+	AllowedSANs []string
+	// ServerName is the name checked in the server certificate by ClientConfig,
+	// empty uses the host given to ClientConfig
+	ServerName string
+}
+
+// Reloader holds the current certificate and CA pool and swaps them on Reload
+type Reloader struct {
+	cfg      Config
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes [3]time.Time // Of the CA, the certificate and the key
+	mux      sync.RWMutex
+}
+
+// Load reads the files of cfg
+func Load(cfg Config) (*Reloader, error) {
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous material stays in use,
+// so a half-written file during a rotation doesn't take the node down.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: loading key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(r.cfg.CAFile)
+	if err != nil {
+		return fmt.Errorf("mtls: reading CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("mtls: no certificate found in %s", r.cfg.CAFile)
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.cert, r.pool, r.modTimes = &cert, pool, modTimes
+	return nil
+}
+
+func (r *Reloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, name := range []string{r.cfg.CAFile, r.cfg.CertFile, r.cfg.KeyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return modTimes, fmt.Errorf("mtls: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// Watch checks the files every interval and reloads them when one changed,
+// until ctx is done. Reload errors are passed to onError, which may be nil.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modTimes, err := r.stat()
+		r.mux.RLock()
+		changed := modTimes != r.modTimes
+		r.mux.RUnlock()
+		if err == nil && changed {
+			err = r.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig requires and verifies a client certificate. The config is built for
+// every handshake, so a reload applies to the next connection.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS13,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				VerifyConnection: func(cs tls.ConnectionState) error {
+					return r.checkSAN(cs.PeerCertificates[0])
+				},
+			}, nil
+		},
+	}
+}
+
+// ClientConfig presents the certificate of this node and verifies the server for host,
+// the host being dialed (a DNS name or an IP), unless Config.ServerName overrides it.
+// The standard verification can't use a CA pool that changes, so it is turned off
+// and VerifyConnection does the same checks with the current pool. The name is checked
+// here rather than taken from the connection state, which has no name for an IP: SNI
+// is never sent for one.
+func (r *Reloader) ClientConfig(host string) *tls.Config {
+	serverName := r.cfg.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("mtls: server sent no certificate")
+			}
+			if serverName == "" {
+				return ErrNoServerName
+			}
+			_, pool := r.current()
+			// Verify matches the IP SANs when DNSName is an IP
+			opts := x509.VerifyOptions{
+				Roots:         pool,
+				DNSName:       serverName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+				return fmt.Errorf("mtls: %w", err)
+			}
+			return r.checkSAN(cs.PeerCertificates[0])
+		},
+	}
+}
+
+// checkSAN accepts the certificate if one of its SANs is allowed
+func (r *Reloader) checkSAN(cert *x509.Certificate) error {
+	if len(r.cfg.AllowedSANs) == 0 {
+		return nil
+	}
+	for _, san := range SANs(cert) {
+		for _, allowed := range r.cfg.AllowedSANs {
+			if matchSAN(allowed, san) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrSANNotAllowed, SANs(cert))
+}
+
+// SANs returns every subject alternative name of cert as a string
+func SANs(cert *x509.Certificate) []string {
+	sans := append([]string(nil), cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// matchSAN compares exactly, except "*.example.com" which matches a single label
+func matchSAN(pattern, san string) bool {
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		label, found := strings.CutSuffix(san, "."+domain)
+		return found && label != "" && !strings.Contains(label, ".")
+	}
+	return pattern == san
+}
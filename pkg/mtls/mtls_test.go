@@ -0,0 +1,274 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authority is a CA of the tests, able to issue node certificates
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a node with the given SANs, which may be
+// DNS names, IPs or URIs
+func (a *authority) issue(t *testing.T, sans ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: sans[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if uri, err := url.Parse(san); err == nil && uri.Scheme != "" {
+			template.URIs = append(template.URIs, uri)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeNode writes the CA, certificate and key of a node to dir and returns their Config
+func writeNode(t *testing.T, dir, name string, ca *authority, sans ...string) Config {
+	t.Helper()
+	cfg := Config{
+		CAFile:   filepath.Join(dir, name+"-ca.pem"),
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+"-key.pem"),
+	}
+	certPEM, keyPEM := ca.issue(t, sans...)
+	for file, data := range map[string][]byte{cfg.CAFile: ca.pem, cfg.CertFile: certPEM, cfg.KeyFile: keyPEM} {
+		if err := os.WriteFile(file, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func load(t *testing.T, cfg Config) *Reloader {
+	t.Helper()
+	reloader, err := Load(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reloader
+}
+
+// handshake connects a client and a server over loopback and returns the errors of both
+// sides. With TLS 1.3 the client finishes first, so it reads once to learn whether the
+// server accepted its certificate.
+func handshake(server, client *tls.Config) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	serverConn, err := listener.Accept()
+	if err != nil {
+		clientConn.Close()
+		return err
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		conn := tls.Server(serverConn, server)
+		err := conn.Handshake()
+		if err == nil {
+			_, err = conn.Write([]byte{1})
+		}
+		conn.Close()
+		serverErr <- err
+	}()
+	conn := tls.Client(clientConn, client)
+	err = conn.Handshake()
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+	}
+	conn.Close()
+	return errors.Join(err, <-serverErr)
+}
+
+func TestMatchSAN(t *testing.T) {
+	cases := []struct {
+		pattern, san string
+		want         bool
+	}{
+		{"node-1.cluster", "node-1.cluster", true},
+		{"node-1.cluster", "node-2.cluster", false},
+		{"*.cluster", "node-1.cluster", true},
+		{"*.cluster", "a.node-1.cluster", false},
+		{"*.cluster", ".cluster", false},
+		{"*.cluster", "cluster", false},
+		{"spiffe://cluster/node-1", "spiffe://cluster/node-1", true},
+		{"10.0.0.1", "10.0.0.10", false},
+	}
+	for _, c := range cases {
+		if got := matchSAN(c.pattern, c.san); got != c.want {
+			t.Errorf("matchSAN(%q, %q) = %v, want %v", c.pattern, c.san, got, c.want)
+		}
+	}
+}
+
+func TestClientVerifiesHost(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	server := load(t, writeNode(t, dir, "server", ca, "node-1.cluster", "127.0.0.1"))
+	client := load(t, writeNode(t, dir, "client", ca, "client.cluster"))
+
+	for _, host := range []string{"127.0.0.1", "node-1.cluster"} {
+		if err := handshake(server.ServerConfig(), client.ClientConfig(host)); err != nil {
+			t.Errorf("dialing %s: %v", host, err)
+		}
+	}
+	// A certificate of the CA for another host must not be accepted, IPs included
+	for _, host := range []string{"10.0.0.1", "node-2.cluster"} {
+		if err := handshake(server.ServerConfig(), client.ClientConfig(host)); err == nil {
+			t.Errorf("dialing %s: the certificate of 127.0.0.1 was accepted", host)
+		}
+	}
+	if err := handshake(server.ServerConfig(), client.ClientConfig("")); !errors.Is(err, ErrNoServerName) {
+		t.Errorf("dialing without a host: got %v, want ErrNoServerName", err)
+	}
+
+	// Config.ServerName overrides the host being dialed
+	cfg := writeNode(t, dir, "named", ca, "client.cluster")
+	cfg.ServerName = "node-1.cluster"
+	if err := handshake(server.ServerConfig(), load(t, cfg).ClientConfig("10.0.0.1")); err != nil {
+		t.Errorf("dialing with ServerName: %v", err)
+	}
+}
+
+func TestOtherCARejected(t *testing.T) {
+	dir := t.TempDir()
+	server := load(t, writeNode(t, dir, "server", newAuthority(t, "ca"), "127.0.0.1"))
+	client := load(t, writeNode(t, dir, "client", newAuthority(t, "other"), "127.0.0.1"))
+	if err := handshake(server.ServerConfig(), client.ClientConfig("127.0.0.1")); err == nil {
+		t.Error("nodes of different CAs connected")
+	}
+}
+
+func TestAllowedSANs(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	serverCfg := writeNode(t, dir, "server", ca, "127.0.0.1", "spiffe://cluster/node-1")
+	clientCfg := writeNode(t, dir, "client", ca, "client.cluster", "spiffe://cluster/client")
+
+	cases := []struct {
+		name           string
+		server, client []string
+		wantErr        bool
+	}{
+		{"Any", nil, nil, false},
+		{"Allowed", []string{"spiffe://cluster/client"}, []string{"spiffe://cluster/node-1"}, false},
+		{"Wildcard", []string{"*.cluster"}, []string{"127.0.0.1"}, false},
+		{"ServerRejectsClient", []string{"spiffe://cluster/admin"}, nil, true},
+		{"ClientRejectsServer", nil, []string{"spiffe://cluster/node-2"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			serverCfg.AllowedSANs, clientCfg.AllowedSANs = c.server, c.client
+			err := handshake(load(t, serverCfg).ServerConfig(), load(t, clientCfg).ClientConfig("127.0.0.1"))
+			if c.wantErr && err == nil {
+				t.Error("got no error")
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("got %v", err)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newAuthority(t, "old"), newAuthority(t, "new")
+	serverCfg := writeNode(t, dir, "server", oldCA, "127.0.0.1")
+	server := load(t, serverCfg)
+	client := load(t, writeNode(t, dir, "client", newCA, "client.cluster"))
+	if err := handshake(server.ServerConfig(), client.ClientConfig("127.0.0.1")); err == nil {
+		t.Fatal("a client of the new CA reached a server of the old one")
+	}
+
+	// The rotation of the files applies to the next handshake of the same config
+	config := server.ServerConfig()
+	writeNode(t, dir, "server", newCA, "127.0.0.1")
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(config, client.ClientConfig("127.0.0.1")); err != nil {
+		t.Errorf("after the reload: %v", err)
+	}
+
+	// A half-written file keeps the previous material
+	if err := os.WriteFile(serverCfg.CertFile, []byte("-----BEGIN CERT"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(); err == nil {
+		t.Error("Reload accepted a broken certificate")
+	}
+	if err := handshake(config, client.ClientConfig("127.0.0.1")); err != nil {
+		t.Errorf("after a failed reload: %v", err)
+	}
+}
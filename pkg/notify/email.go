@@ -0,0 +1,195 @@
+// Package notify delivers the notifications of the observers of 02-DesignPatterns/Observer
+// and of pkg/account: emails through a Sender, an SMTP server or the
+// console, and text messages through an SMSProvider, a Twilio-style HTTP gateway or the
+// console. MockSender and FakeSMSProvider record what they were given, for the tests.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email ready to be sent
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string // Plain text, "\n" line endings
+}
+
+// Sender delivers emails. The email observers depend on this interface, so the notifications
+// can go through a real SMTP server, be printed, or be recorded by a mock. The send must
+// give up when ctx is done, so the timeout of the dispatcher bounds it.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ConsoleSender prints the emails instead of sending them, it is the default of the observers
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(ctx context.Context, msg Message) error {
+	fmt.Printf("Sending email - %s for client %s\n", msg.Subject, strings.Join(msg.To, ", "))
+	return nil
+}
+
+// MockSender records the emails; Err makes every Send fail
+type MockSender struct {
+	Err      error
+	messages []Message
+	mux      sync.Mutex
+}
+
+func (m *MockSender) Send(ctx context.Context, msg Message) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.messages = append(m.messages, msg)
+	return m.Err
+}
+
+// Messages returns a copy of the recorded emails
+func (m *MockSender) Messages() []Message {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// ErrTLSRequired is returned when RequireTLS is set and the server doesn't offer STARTTLS
+var ErrTLSRequired = errors.New("smtp server does not support STARTTLS")
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	Host       string
+	Port       int // 587 (submission with STARTTLS) or 25
+	Username   string
+	Password   string
+	RequireTLS bool          // Fail instead of sending in clear text when STARTTLS is missing
+	Timeout    time.Duration // Limit of the whole conversation when ctx has no deadline, 10 seconds when zero
+	TLSConfig  *tls.Config   // Optional, ServerName defaults to Host
+}
+
+// Send opens a connection per message, upgrades it with STARTTLS when offered and
+// authenticates when Username is set. smtp.PlainAuth refuses to send the password over
+// an unencrypted connection unless the server is localhost.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := s.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return err
+	}
+	// The deadline of ctx bounds the whole conversation, so a stuck server doesn't block the
+	// observer; a cancel before it is honoured by closing the connection
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := s.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: s.Host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	} else if s.RequireTLS {
+		return ErrTLSRequired
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(msg.From); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Bytes encodes the message in RFC 5322 format. The subject is Q-encoded and the body
+// quoted-printable, so non-ASCII text survives any server.
+func (m Message) Bytes() ([]byte, error) {
+	// A newline in a header would let the value add headers of its own
+	for _, value := range append([]string{m.From, m.Subject}, m.To...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header value %q", value)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", rand.Text(), domain(m.From))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(m.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// domain returns the part of an address after the @
+func domain(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return strings.Trim(address[at+1:], "> ")
+	}
+	return "localhost"
+}
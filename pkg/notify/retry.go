@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTemporary marks failures worth retrying (timeouts, 5xx and 429 answers)
+var ErrTemporary = errors.New("temporary failure")
+
+// RetryPolicy retries an operation with exponential backoff
+type RetryPolicy struct {
+	Attempts int           // Total attempts, including the first one
+	Backoff  time.Duration // Pause before the second attempt, doubled after each failure
+}
+
+// Do runs fn until it succeeds, fails with an error that is not ErrTemporary,
+// or the attempts run out; the last error is returned. When ctx is done during a
+// backoff the retries stop and the error of ctx is returned.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	backoff := p.Backoff
+	var err error
+	for attempt := range max(1, p.Attempts) {
+		if attempt > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+		if err = fn(); err == nil || !errors.Is(err, ErrTemporary) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSProvider sends text messages. The SMS observers depend on this interface, so the
+// notifications can go through an HTTP gateway, be printed, or be recorded by a fake. The
+// send must give up when ctx is done, so the timeout of the dispatcher bounds it.
+type SMSProvider interface {
+	SendSMS(ctx context.Context, from, to, body string) error
+}
+
+// ConsoleSMS prints the messages instead of sending them, it is the default of the observers
+type ConsoleSMS struct{}
+
+func (ConsoleSMS) SendSMS(ctx context.Context, from, to, body string) error {
+	fmt.Printf("Sending SMS - %s for client %s\n", body, to)
+	return nil
+}
+
+// SMS is a message recorded by FakeSMSProvider
+type SMS struct {
+	From, To, Body string
+}
+
+// FakeSMSProvider records the messages; Err makes every send fail
+type FakeSMSProvider struct {
+	Err  error
+	sent []SMS
+	mux  sync.Mutex
+}
+
+func (f *FakeSMSProvider) SendSMS(ctx context.Context, from, to, body string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.sent = append(f.sent, SMS{From: from, To: to, Body: body})
+	return f.Err
+}
+
+// Sent returns a copy of the recorded messages
+func (f *FakeSMSProvider) Sent() []SMS {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return append([]SMS(nil), f.sent...)
+}
+
+// GatewayError is the error answered by the gateway
+type GatewayError struct {
+	Status  int    `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("sms gateway: %d %s (code %d)", e.Status, e.Message, e.Code)
+}
+
+// Unwrap marks the errors worth retrying as ErrTemporary
+func (e *GatewayError) Unwrap() error {
+	if e.Status == http.StatusTooManyRequests || e.Status >= 500 {
+		return ErrTemporary
+	}
+	return nil
+}
+
+// HTTPSMSProvider sends messages through a Twilio-style REST gateway:
+//
+//	POST {BaseURL}/2010-04-01/Accounts/{AccountSID}/Messages.json
+//	form fields To, From and Body, basic auth with the account SID and token
+//
+// Every request is also signed (see Sign), so the gateway can reject tampered or replayed requests.
+type HTTPSMSProvider struct {
+	BaseURL    string
+	AccountSID string
+	AuthToken  string
+	Client     *http.Client // http.DefaultClient when nil
+	Retry      RetryPolicy
+}
+
+// NewHTTPSMSProvider creates a provider with retries short enough to fit in the
+// timeout of the dispatcher; the dispatcher retries the whole notification on top of them
+func NewHTTPSMSProvider(baseURL, accountSID, authToken string) *HTTPSMSProvider {
+	return &HTTPSMSProvider{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		Client:     &http.Client{Timeout: 2 * time.Second},
+		Retry:      RetryPolicy{Attempts: 3, Backoff: 20 * time.Millisecond},
+	}
+}
+
+func (p *HTTPSMSProvider) SendSMS(ctx context.Context, from, to, body string) error {
+	return p.Retry.Do(ctx, func() error {
+		return p.send(ctx, from, to, body)
+	})
+}
+
+func (p *HTTPSMSProvider) send(ctx context.Context, from, to, body string) error {
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.BaseURL, url.PathEscape(p.AccountSID))
+	form := url.Values{"From": {from}, "To": {to}, "Body": {body}}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", Sign(p.AuthToken, endpoint, timestamp, form))
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// Network errors and timeouts may succeed on the next attempt
+		return fmt.Errorf("%w: %v", ErrTemporary, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	gatewayErr := &GatewayError{Status: resp.StatusCode}
+	if json.NewDecoder(resp.Body).Decode(gatewayErr) != nil || gatewayErr.Message == "" {
+		gatewayErr.Message = http.StatusText(resp.StatusCode)
+	}
+	gatewayErr.Status = resp.StatusCode
+	return gatewayErr
+}
+
+// Sign computes base64(HMAC-SHA256(token, url + timestamp + sorted form fields)),
+// in the style of the webhook signatures of Twilio. The fields are sorted by name so
+// both sides build the same string no matter how the form was encoded.
+func Sign(token, endpoint, timestamp string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var payload strings.Builder
+	payload.WriteString(endpoint)
+	payload.WriteString(timestamp)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			payload.WriteString(k)
+			payload.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(payload.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature is the check done by the gateway: the signature must match and the
+// timestamp must be recent, so a captured request can't be replayed later
+func VerifySignature(token, endpoint, timestamp, signature string, form url.Values, maxAge time.Duration) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(seconds, 0)).Abs() > maxAge {
+		return false
+	}
+	expected := Sign(token, endpoint, timestamp, form)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,144 @@
+// Package objectmother holds the Test Data Builders and the Object Mother of
+// 02-DesignPatterns/ObjectMother: fluent builders that start from valid defaults, and named
+// fixtures on top of them. The tests of pkg/repository build their products with it.
+package objectmother
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// ProductBuilder builds the products of pkg/repository starting from valid defaults
+type ProductBuilder struct {
+	product repository.Product
+}
+
+// NewProductBuilder returns a builder for an in-stock product
+func NewProductBuilder() *ProductBuilder {
+	return &ProductBuilder{product: repository.Product{ID: 1, Name: "Laptop", Price: 1200, Stock: 10}}
+}
+
+func (b *ProductBuilder) WithID(id int64) *ProductBuilder {
+	b.product.ID = id
+	return b
+}
+
+func (b *ProductBuilder) WithName(name string) *ProductBuilder {
+	b.product.Name = name
+	return b
+}
+
+func (b *ProductBuilder) WithPrice(price int) *ProductBuilder {
+	b.product.Price = price
+	return b
+}
+
+func (b *ProductBuilder) WithStock(stock int) *ProductBuilder {
+	b.product.Stock = stock
+	return b
+}
+
+// Build returns a copy, so the builder can be reused for several products
+func (b *ProductBuilder) Build() repository.Product {
+	return b.product
+}
+
+// Payment mirrors the charge request of the Adapter example
+type Payment struct {
+	OrderID  string
+	Amount   int64 // In cents
+	Currency string
+	Card     string // Tokenized card
+}
+
+// PaymentBuilder builds payments starting from valid defaults
+type PaymentBuilder struct {
+	payment Payment
+}
+
+// NewPaymentBuilder returns a builder for a payment the fake provider accepts
+func NewPaymentBuilder() *PaymentBuilder {
+	return &PaymentBuilder{payment: Payment{OrderID: "order-1", Amount: 2500, Currency: "USD", Card: "tok_visa"}}
+}
+
+func (b *PaymentBuilder) WithOrderID(orderID string) *PaymentBuilder {
+	b.payment.OrderID = orderID
+	return b
+}
+
+func (b *PaymentBuilder) WithAmount(amount int64) *PaymentBuilder {
+	b.payment.Amount = amount
+	return b
+}
+
+func (b *PaymentBuilder) WithCurrency(currency string) *PaymentBuilder {
+	b.payment.Currency = currency
+	return b
+}
+
+func (b *PaymentBuilder) WithCard(card string) *PaymentBuilder {
+	b.payment.Card = card
+	return b
+}
+
+func (b *PaymentBuilder) Build() Payment {
+	return b.payment
+}
+
+// RecordingObserver stores every event it receives, optionally failing each delivery
+type RecordingObserver struct {
+	id     string
+	fail   bool
+	events []string
+	mux    sync.Mutex
+}
+
+func (o *RecordingObserver) Update(event string) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.events = append(o.events, event)
+	if o.fail {
+		return errors.New("delivery failed")
+	}
+	return nil
+}
+
+func (o *RecordingObserver) ID() string {
+	return o.id
+}
+
+// Events returns a copy of the received events
+func (o *RecordingObserver) Events() []string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+// ObserverBuilder builds recording observers
+type ObserverBuilder struct {
+	id   string
+	fail bool
+}
+
+// NewObserverBuilder returns a builder for an observer that accepts every event
+func NewObserverBuilder() *ObserverBuilder {
+	return &ObserverBuilder{id: "observer@test.com"}
+}
+
+func (b *ObserverBuilder) WithID(id string) *ObserverBuilder {
+	b.id = id
+	return b
+}
+
+// Failing makes every delivery to the observer return an error
+func (b *ObserverBuilder) Failing() *ObserverBuilder {
+	b.fail = true
+	return b
+}
+
+// Build returns a new observer on every call, observers are not shared
+func (b *ObserverBuilder) Build() *RecordingObserver {
+	return &RecordingObserver{id: b.id, fail: b.fail}
+}
@@ -0,0 +1,64 @@
+package objectmother
+
+import (
+	"fmt"
+
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// The Object Mother gives a name to the fixtures that appear again and again.
+// Each function returns a builder, so a case can still tweak one field:
+// AnOutOfStockProduct().WithName("Tablet").Build()
+
+// AProduct is a regular product with stock
+func AProduct() *ProductBuilder {
+	return NewProductBuilder()
+}
+
+// AnOutOfStockProduct can't be sold
+func AnOutOfStockProduct() *ProductBuilder {
+	return NewProductBuilder().WithName("Desktop").WithStock(0)
+}
+
+// ACheapProduct falls under the price filters of the Specification example
+func ACheapProduct() *ProductBuilder {
+	return NewProductBuilder().WithName("Mouse").WithPrice(25)
+}
+
+// AValidPayment is accepted by the fake SDK client
+func AValidPayment() *PaymentBuilder {
+	return NewPaymentBuilder()
+}
+
+// ADeclinedPayment uses the card the fake SDK client always declines
+func ADeclinedPayment() *PaymentBuilder {
+	return NewPaymentBuilder().WithCard("tok_declined")
+}
+
+// AnInvalidPayment has an amount no provider accepts
+func AnInvalidPayment() *PaymentBuilder {
+	return NewPaymentBuilder().WithAmount(0)
+}
+
+// AnEmailObserver accepts every notification
+func AnEmailObserver() *ObserverBuilder {
+	return NewObserverBuilder()
+}
+
+// ABrokenObserver fails every notification
+func ABrokenObserver() *ObserverBuilder {
+	return NewObserverBuilder().WithID("broken@test.com").Failing()
+}
+
+// Catalog returns n products with consecutive IDs and increasing prices
+func Catalog(n int) []repository.Product {
+	products := make([]repository.Product, n)
+	for i := range products {
+		products[i] = AProduct().
+			WithID(int64(i + 1)).
+			WithName(fmt.Sprintf("Product %d", i+1)).
+			WithPrice(100 * (i + 1)).
+			Build()
+	}
+	return products
+}
@@ -0,0 +1,159 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrObserverTimeout is returned when an observer takes longer than the configured timeout
+var ErrObserverTimeout = errors.New("observer timed out")
+
+// MaxDeadLetters is the number of dead letters a Dispatcher keeps; past it the oldest
+// are dropped, so an observer failing for days doesn't fill the memory
+const MaxDeadLetters = 1000
+
+// DeadLetter records a notification that failed after every retry
+type DeadLetter[E any] struct {
+	ObserverID string
+	Event      E
+	Err        error
+}
+
+// Dispatcher notifies observers concurrently using a fixed pool of workers.
+// Every notification has a timeout and is retried before ending in the dead letters.
+// The pool is shared by every Dispatch: its workers are started when notifications are
+// waiting, at most workers of them, and return once the queue is empty, so an idle
+// dispatcher holds no goroutine and needs no Close.
+type Dispatcher[E any] struct {
+	workers     int           // Maximum number of goroutines notifying observers
+	timeout     time.Duration // Maximum time for a single UpdateValue call
+	retries     int           // Extra attempts after the first failure
+	backoff     time.Duration // Pause between attempts
+	queue       []job[E]      // Notifications waiting for a worker
+	running     int           // Workers started and not returned yet
+	deadLetters []DeadLetter[E]
+	mux         sync.Mutex
+}
+
+// job is the notification of one observer, done receives its result
+type job[E any] struct {
+	observer Observer[E]
+	event    E
+	done     func(err error)
+}
+
+// NewDispatcher creates a dispatcher with the given pool size, timeout and retries
+func NewDispatcher[E any](workers int, timeout time.Duration, retries int) *Dispatcher[E] {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher[E]{
+		workers: workers,
+		timeout: timeout,
+		retries: retries,
+		backoff: 10 * time.Millisecond,
+	}
+}
+
+// Dispatch sends the event to every observer and waits until all of them finish.
+// It returns the errors of the observers that failed after all retries, joined together.
+func (d *Dispatcher[E]) Dispatch(observers []Observer[E], event E) error {
+	var wg sync.WaitGroup
+	var failures []error
+	var failuresMux sync.Mutex
+	done := func(err error) {
+		if err != nil {
+			failuresMux.Lock()
+			failures = append(failures, err)
+			failuresMux.Unlock()
+		}
+		wg.Done()
+	}
+
+	wg.Add(len(observers))
+	d.mux.Lock()
+	for _, observer := range observers {
+		d.queue = append(d.queue, job[E]{observer: observer, event: event, done: done})
+	}
+	for d.running < min(d.workers, len(d.queue)) {
+		d.running++
+		go d.work()
+	}
+	d.mux.Unlock()
+
+	wg.Wait()
+	return errors.Join(failures...)
+}
+
+// work notifies the queued observers until the queue is empty
+func (d *Dispatcher[E]) work() {
+	for {
+		d.mux.Lock()
+		if len(d.queue) == 0 {
+			d.running--
+			d.mux.Unlock()
+			return
+		}
+		next := d.queue[0]
+		d.queue[0] = job[E]{}
+		d.queue = d.queue[1:]
+		d.mux.Unlock()
+
+		next.done(d.notify(next.observer, next.event))
+	}
+}
+
+// notify calls the observer with retries; the last error goes to the dead letters
+func (d *Dispatcher[E]) notify(observer Observer[E], event E) error {
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(d.backoff * time.Duration(attempt))
+		}
+		if err = d.call(observer, event); err == nil {
+			return nil
+		}
+	}
+
+	err = fmt.Errorf("observer %s: %w", observer.GetId(), err)
+	d.mux.Lock()
+	if len(d.deadLetters) == MaxDeadLetters {
+		d.deadLetters = append(d.deadLetters[:0], d.deadLetters[1:]...)
+	}
+	d.deadLetters = append(d.deadLetters, DeadLetter[E]{ObserverID: observer.GetId(), Event: event, Err: err})
+	d.mux.Unlock()
+	return err
+}
+
+// call runs a single UpdateValue with a timeout. The call runs in its own goroutine so
+// the timeout holds even for an observer that ignores its context: the worker stops
+// waiting and moves on, and the abandoned call ends whenever the observer returns. A
+// retry may therefore start while an abandoned attempt is still running.
+func (d *Dispatcher[E]) call(observer Observer[E], event E) error {
+	if d.timeout <= 0 {
+		return observer.UpdateValue(context.Background(), event)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	// Buffered so an abandoned call can still send its result and return
+	result := make(chan error, 1)
+	go func() { result <- observer.UpdateValue(ctx, event) }()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ErrObserverTimeout
+	}
+}
+
+// DeadLetters returns a copy of the notifications that could not be delivered, at most
+// the last MaxDeadLetters
+func (d *Dispatcher[E]) DeadLetters() []DeadLetter[E] {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return append([]DeadLetter[E](nil), d.deadLetters...)
+}
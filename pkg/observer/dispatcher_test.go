@@ -0,0 +1,172 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcObserver runs update on every notification
+type funcObserver struct {
+	id     string
+	update func(ctx context.Context, event int) error
+}
+
+func (o funcObserver) GetId() string {
+	return o.id
+}
+
+func (o funcObserver) UpdateValue(ctx context.Context, event int) error {
+	return o.update(ctx, event)
+}
+
+func TestDispatch(t *testing.T) {
+	failure := errors.New("unreachable")
+	var delivered atomic.Int32
+	ok := funcObserver{id: "ok", update: func(context.Context, int) error {
+		delivered.Add(1)
+		return nil
+	}}
+	failing := funcObserver{id: "failing", update: func(context.Context, int) error {
+		return failure
+	}}
+
+	d := NewDispatcher[int](2, time.Second, 1)
+	err := d.Dispatch([]Observer[int]{ok, failing, ok}, 7)
+	if !errors.Is(err, failure) {
+		t.Errorf("Dispatch returned %v, want %v", err, failure)
+	}
+	if got := delivered.Load(); got != 2 {
+		t.Errorf("%d deliveries, want 2", got)
+	}
+	letters := d.DeadLetters()
+	if len(letters) != 1 || letters[0].ObserverID != "failing" || letters[0].Event != 7 {
+		t.Errorf("dead letters %+v, want the event 7 of failing", letters)
+	}
+}
+
+func TestDispatchTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	slow := funcObserver{id: "slow", update: func(ctx context.Context, _ int) error {
+		attempts.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	d := NewDispatcher[int](4, 10*time.Millisecond, 2)
+	if err := d.Dispatch([]Observer[int]{slow}, 1); !errors.Is(err, ErrObserverTimeout) {
+		t.Errorf("Dispatch returned %v, want %v", err, ErrObserverTimeout)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("%d attempts, want 3", got)
+	}
+	letters := d.DeadLetters()
+	if len(letters) != 1 || !errors.Is(letters[0].Err, ErrObserverTimeout) {
+		t.Errorf("dead letters %+v, want one timeout", letters)
+	}
+}
+
+// TestDispatchIgnoredContext checks that the timeout holds for observers that never look
+// at their context: a late success is still a timeout, and a blocked observer doesn't
+// hold the worker
+func TestDispatchIgnoredContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blocked := funcObserver{id: "blocked", update: func(context.Context, int) error {
+		<-release
+		return nil
+	}}
+	late := funcObserver{id: "late", update: func(context.Context, int) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}}
+
+	d := NewDispatcher[int](1, 10*time.Millisecond, 0)
+	for _, observer := range []Observer[int]{blocked, late} {
+		start := time.Now()
+		if err := d.Dispatch([]Observer[int]{observer}, 1); !errors.Is(err, ErrObserverTimeout) {
+			t.Errorf("%s: Dispatch returned %v, want %v", observer.GetId(), err, ErrObserverTimeout)
+		}
+		if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+			t.Errorf("%s: Dispatch took %s with a timeout of 10ms", observer.GetId(), elapsed)
+		}
+	}
+
+	// The only worker is free again
+	var delivered atomic.Bool
+	ok := funcObserver{id: "ok", update: func(context.Context, int) error {
+		delivered.Store(true)
+		return nil
+	}}
+	if err := d.Dispatch([]Observer[int]{ok}, 2); err != nil || !delivered.Load() {
+		t.Errorf("Dispatch after the timeouts returned %v, delivered %t", err, delivered.Load())
+	}
+}
+
+func TestDispatchWorkers(t *testing.T) {
+	const workers = 3
+	var running, peak atomic.Int32
+	busy := funcObserver{id: "busy", update: func(context.Context, int) error {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return nil
+	}}
+	observers := make([]Observer[int], 20)
+	for i := range observers {
+		observers[i] = busy
+	}
+
+	before := runtime.NumGoroutine()
+	d := NewDispatcher[int](workers, time.Second, 0)
+	// Two dispatches at once share the pool of the dispatcher
+	var wg sync.WaitGroup
+	for event := range 2 {
+		wg.Go(func() {
+			if err := d.Dispatch(observers, event); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if got := peak.Load(); got > workers {
+		t.Errorf("%d observers called at once, want at most %d", got, workers)
+	}
+
+	// The workers return once the queue is empty
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after dispatching, %d before", after, before)
+	}
+}
+
+func TestDeadLettersCap(t *testing.T) {
+	failing := funcObserver{id: "failing", update: func(context.Context, int) error {
+		return errors.New("down")
+	}}
+	d := NewDispatcher[int](1, 0, 0)
+	for event := range MaxDeadLetters + 10 {
+		d.Dispatch([]Observer[int]{failing}, event)
+	}
+
+	letters := d.DeadLetters()
+	if len(letters) != MaxDeadLetters {
+		t.Fatalf("%d dead letters, want %d", len(letters), MaxDeadLetters)
+	}
+	if first, last := letters[0].Event, letters[len(letters)-1].Event; first != 10 || last != MaxDeadLetters+9 {
+		t.Errorf("dead letters from event %d to %d, want the newest from 10 to %d", first, last, MaxDeadLetters+9)
+	}
+}
@@ -0,0 +1,87 @@
+// Package observer is the Observer pattern of 02-DesignPatterns/Observer, generic over the
+// type of the events: a Topic keeps the Subscriptions of its Observers, with the Filters
+// they chose, and a Dispatcher delivers each event to the matching observers from a pool
+// of workers, with a timeout per observer, retries and dead letters.
+//
+// The items of 02-DesignPatterns/Observer, the accounts of pkg/account
+// and the caches of pkg/cache are topics built on it.
+package observer
+
+import "context"
+
+// Topic defines the interface for objects that can be observed
+// E is the type of the events sent to the observers
+type Topic[E any] interface {
+	// Register adds a new observer to receive updates
+	// Options such as WithFilter limit the events delivered to the observer
+	Register(observer Observer[E], options ...SubscribeOption[E])
+	// Broadcast notifies all registered observers with the given event
+	// and returns the errors of the observers that could not be notified
+	Broadcast(event E) error
+}
+
+// Observer defines the interface for objects that want to receive updates
+type Observer[E any] interface {
+	// GetId returns the unique identifier of the observer
+	GetId() string
+	// UpdateValue receives updates from the Topic and reports delivery errors.
+	// ctx is canceled when the Dispatcher stops waiting for the call, a slow delivery
+	// should give up then.
+	UpdateValue(ctx context.Context, event E) error
+}
+
+// Filter decides whether an event must be delivered to an observer
+type Filter[E any] func(event E) bool
+
+// Subscription links an observer with the filters chosen when it registered; a Topic
+// keeps one per observer
+type Subscription[E any] struct {
+	observer Observer[E]
+	filters  []Filter[E]
+}
+
+// SubscribeOption customizes a subscription when an observer registers
+type SubscribeOption[E any] func(s *Subscription[E])
+
+// WithFilter only delivers the events accepted by the predicate.
+// Several filters can be combined; all of them must accept the event.
+func WithFilter[E any](filter Filter[E]) SubscribeOption[E] {
+	return func(s *Subscription[E]) {
+		s.filters = append(s.filters, filter)
+	}
+}
+
+// NewSubscription applies the options to a new subscription
+func NewSubscription[E any](observer Observer[E], options []SubscribeOption[E]) Subscription[E] {
+	s := Subscription[E]{observer: observer}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+// Observer returns the observer of the subscription
+func (s Subscription[E]) Observer() Observer[E] {
+	return s.observer
+}
+
+// Accepts evaluates the filters before the event is dispatched
+func (s Subscription[E]) Accepts(event E) bool {
+	for _, filter := range s.filters {
+		if !filter(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matching returns the observers whose subscriptions accept the event
+func Matching[E any](subscriptions []Subscription[E], event E) []Observer[E] {
+	observers := make([]Observer[E], 0, len(subscriptions))
+	for _, s := range subscriptions {
+		if s.Accepts(event) {
+			observers = append(observers, s.observer)
+		}
+	}
+	return observers
+}
@@ -0,0 +1,183 @@
+// Package ping sends ICMP echo requests over IPv4 and keeps the statistics of a session.
+//
+// It is the pinger of 03-Net/Ping, also used by 03-Net/PortsScanner to check that a host
+// is up before scanning it.
+package ping
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// protocolICMP is the IP protocol number of ICMPv4, needed to parse the replies
+const protocolICMP = 1
+
+// ErrTimeout is returned when no reply arrives before the timeout
+var ErrTimeout = errors.New("request timed out")
+
+// Pinger sends ICMP echo requests over IPv4.
+// Raw ICMP sockets ("ip4:icmp") need root or CAP_NET_RAW. Without them the pinger falls back
+// to unprivileged ICMP datagram sockets ("udp4"), available on Linux (when the group is
+// allowed by net.ipv4.ping_group_range) and macOS. With those the kernel chooses the echo
+// ID, so replies are matched by sequence number only.
+type Pinger struct {
+	conn       *icmp.PacketConn
+	privileged bool
+	id         int
+}
+
+// NewPinger opens the ICMP socket, trying the raw one first
+func NewPinger() (*Pinger, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err == nil {
+		return &Pinger{conn: conn, privileged: true, id: os.Getpid() & 0xffff}, nil
+	}
+	conn, udpErr := icmp.ListenPacket("udp4", "0.0.0.0")
+	if udpErr != nil {
+		return nil, fmt.Errorf("opening ICMP socket: %w (unprivileged fallback: %v)", err, udpErr)
+	}
+	return &Pinger{conn: conn, privileged: false}, nil
+}
+
+// Close releases the socket
+func (p *Pinger) Close() error {
+	return p.conn.Close()
+}
+
+// Privileged reports whether the pinger uses a raw socket
+func (p *Pinger) Privileged() bool {
+	return p.privileged
+}
+
+// Reply is the answer to one echo request
+type Reply struct {
+	From net.IP
+	Seq  int
+	Size int
+	RTT  time.Duration
+}
+
+// Ping sends one echo request to ip and waits for the matching reply
+func (p *Pinger) Ping(ip net.IP, seq int, timeout time.Duration) (Reply, error) {
+	message := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: []byte("go_advanced_course ping")},
+	}
+	packet, err := message.Marshal(nil)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	// Raw sockets take an IPAddr, datagram sockets a UDPAddr
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !p.privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	start := time.Now()
+	if _, err := p.conn.WriteTo(packet, dst); err != nil {
+		return Reply{}, err
+	}
+
+	// Other replies (late ones, or for other processes on a raw socket) are skipped
+	// until the matching one arrives or the deadline expires
+	p.conn.SetReadDeadline(start.Add(timeout))
+	buffer := make([]byte, 1500)
+	for {
+		n, peer, err := p.conn.ReadFrom(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return Reply{}, ErrTimeout
+			}
+			return Reply{}, err
+		}
+		rtt := time.Since(start)
+
+		reply, err := icmp.ParseMessage(protocolICMP, buffer[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (p.privileged && echo.ID != p.id) {
+			continue
+		}
+		if !peerIP(peer).Equal(ip) {
+			continue
+		}
+		return Reply{From: ip, Seq: seq, Size: n, RTT: rtt}, nil
+	}
+}
+
+// peerIP extracts the IP of the sender for both kinds of socket
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// HostUp reports whether ip answers any of attempts echo requests
+func (p *Pinger) HostUp(ip net.IP, attempts int, timeout time.Duration) bool {
+	for seq := range attempts {
+		if _, err := p.Ping(ip, seq, timeout); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Statistics accumulates the results of a ping session
+type Statistics struct {
+	Sent int
+	RTTs []time.Duration
+}
+
+// Add records one request and, if it was answered, its round trip time
+func (s *Statistics) Add(rtt time.Duration, answered bool) {
+	s.Sent++
+	if answered {
+		s.RTTs = append(s.RTTs, rtt)
+	}
+}
+
+// Loss returns the percentage of requests without reply
+func (s *Statistics) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return 100 * float64(s.Sent-len(s.RTTs)) / float64(s.Sent)
+}
+
+// Summary returns min, average, max and population standard deviation of the RTTs
+func (s *Statistics) Summary() (minRTT, avg, maxRTT, stddev time.Duration) {
+	if len(s.RTTs) == 0 {
+		return 0, 0, 0, 0
+	}
+	minRTT, maxRTT = s.RTTs[0], s.RTTs[0]
+	var sum float64
+	for _, rtt := range s.RTTs {
+		minRTT = min(minRTT, rtt)
+		maxRTT = max(maxRTT, rtt)
+		sum += float64(rtt)
+	}
+	mean := sum / float64(len(s.RTTs))
+
+	var variance float64
+	for _, rtt := range s.RTTs {
+		variance += (float64(rtt) - mean) * (float64(rtt) - mean)
+	}
+	variance /= float64(len(s.RTTs))
+	return minRTT, time.Duration(mean), maxRTT, time.Duration(math.Sqrt(variance))
+}
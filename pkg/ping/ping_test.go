@@ -0,0 +1,58 @@
+package ping
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestStatistics feeds sessions to Add and checks Loss and Summary, with the population
+// standard deviation; a session without replies has a zero summary
+func TestStatistics(t *testing.T) {
+	ms := time.Millisecond
+	cases := []struct {
+		name                      string
+		rtts                      []time.Duration // Round trip times of the answered requests
+		lost                      int             // Requests without reply
+		wantLoss                  float64
+		wantMin, wantAvg, wantMax time.Duration
+		wantStddev                time.Duration
+	}{
+		{name: "nothing sent"},
+		{name: "all lost", lost: 3, wantLoss: 100},
+		{name: "one reply", rtts: []time.Duration{10 * ms}, wantMin: 10 * ms, wantAvg: 10 * ms, wantMax: 10 * ms},
+		{
+			name:     "replies and losses",
+			rtts:     []time.Duration{2 * ms, 4 * ms, 4 * ms, 4 * ms, 5 * ms, 5 * ms, 7 * ms, 9 * ms},
+			lost:     2,
+			wantLoss: 20,
+			wantMin:  2 * ms, wantAvg: 5 * ms, wantMax: 9 * ms, wantStddev: 2 * ms,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s Statistics
+			for _, rtt := range c.rtts {
+				s.Add(rtt, true)
+			}
+			for range c.lost {
+				s.Add(time.Second, false) // The RTT of a lost request is ignored
+			}
+
+			if want := len(c.rtts) + c.lost; s.Sent != want {
+				t.Errorf("got %d sent, want %d", s.Sent, want)
+			}
+			if !slices.Equal(s.RTTs, c.rtts) {
+				t.Errorf("got RTTs %v, want %v", s.RTTs, c.rtts)
+			}
+			if loss := s.Loss(); loss != c.wantLoss {
+				t.Errorf("got loss %v%%, want %v%%", loss, c.wantLoss)
+			}
+			minRTT, avg, maxRTT, stddev := s.Summary()
+			if minRTT != c.wantMin || avg != c.wantAvg || maxRTT != c.wantMax || stddev != c.wantStddev {
+				t.Errorf("got min/avg/max/stddev %s/%s/%s/%s, want %s/%s/%s/%s",
+					minRTT, avg, maxRTT, stddev, c.wantMin, c.wantAvg, c.wantMax, c.wantStddev)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Memory is a map protected by a RWMutex, the storage of the in-memory repositories.
+// Every method takes the lock once, so a check and the change that depends on it, like
+// refusing a duplicate key, can't be split by another goroutine.
+// The repositories turn the booleans it returns into their own errors.
+type Memory[K comparable, V any] struct {
+	items map[K]V
+	mux   sync.RWMutex
+}
+
+// NewMemory creates an empty Memory
+func NewMemory[K comparable, V any]() *Memory[K, V] {
+	return &Memory[K, V]{items: make(map[K]V)}
+}
+
+// Insert stores value under key, unless the key is already taken
+func (m *Memory[K, V]) Insert(key K, value V) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if _, exists := m.items[key]; exists {
+		return false
+	}
+	m.items[key] = value
+	return true
+}
+
+// Get returns the value of key and whether it exists
+func (m *Memory[K, V]) Get(key K) (V, bool) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	value, exists := m.items[key]
+	return value, exists
+}
+
+// Update replaces the value of an existing key with update(value)
+func (m *Memory[K, V]) Update(key K, update func(V) V) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	value, exists := m.items[key]
+	if exists {
+		m.items[key] = update(value)
+	}
+	return exists
+}
+
+// Delete removes key and reports whether it existed
+func (m *Memory[K, V]) Delete(key K) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	_, exists := m.items[key]
+	delete(m.items, key)
+	return exists
+}
+
+// DeleteFunc removes the values matching the predicate and returns how many were removed
+func (m *Memory[K, V]) DeleteFunc(match func(V) bool) int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	removed := 0
+	for key, value := range m.items {
+		if match(value) {
+			delete(m.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Filter returns the values matching the predicate, in no particular order
+func (m *Memory[K, V]) Filter(match func(V) bool) []V {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	result := make([]V, 0)
+	for _, value := range m.items {
+		if match(value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// InMemoryProductRepository stores products in a Memory
+type InMemoryProductRepository struct {
+	products *Memory[int64, Product]
+	nextID   int64
+	mux      sync.Mutex // Protects nextID
+}
+
+// NewInMemoryProductRepository creates an empty in-memory repository
+func NewInMemoryProductRepository() *InMemoryProductRepository {
+	return &InMemoryProductRepository{products: NewMemory[int64, Product](), nextID: 1}
+}
+
+func (r *InMemoryProductRepository) Create(p *Product) error {
+	r.mux.Lock()
+	p.ID = r.nextID
+	r.nextID++
+	r.mux.Unlock()
+
+	r.products.Insert(p.ID, *p)
+	return nil
+}
+
+func (r *InMemoryProductRepository) GetByID(id int64) (Product, error) {
+	p, exists := r.products.Get(id)
+	if !exists {
+		return Product{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (r *InMemoryProductRepository) Update(p Product) error {
+	if !r.products.Update(p.ID, func(Product) Product { return p }) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *InMemoryProductRepository) Delete(id int64) error {
+	if !r.products.Delete(id) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *InMemoryProductRepository) List() ([]Product, error) {
+	return r.filter(func(Product) bool { return true }), nil
+}
+
+func (r *InMemoryProductRepository) FindByName(text string) ([]Product, error) {
+	text = strings.ToLower(text)
+	return r.filter(func(p Product) bool {
+		return strings.Contains(strings.ToLower(p.Name), text)
+	}), nil
+}
+
+func (r *InMemoryProductRepository) FindByPriceRange(min, max int) ([]Product, error) {
+	return r.filter(func(p Product) bool {
+		return p.Price >= min && p.Price <= max
+	}), nil
+}
+
+func (r *InMemoryProductRepository) FindInStock() ([]Product, error) {
+	return r.filter(func(p Product) bool { return p.Stock > 0 }), nil
+}
+
+// filter returns the products matching the predicate, ordered by ID
+// so the results are the same as the ones returned by the SQL backend
+func (r *InMemoryProductRepository) filter(match func(Product) bool) []Product {
+	result := r.products.Filter(match)
+	slices.SortFunc(result, func(a, b Product) int { return cmp.Compare(a.ID, b.ID) })
+	return result
+}
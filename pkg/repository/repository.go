@@ -0,0 +1,40 @@
+// Package repository is the Repository pattern of 02-DesignPatterns/Repository: the client
+// code talks to the ProductRepository interface and never sees the map or the SQL behind it.
+// InMemoryProductRepository and SQLiteProductRepository pass the same conformance suite.
+//
+// Memory is the map and lock every in-memory repository is built on: the products here and
+// in the API of 03-Net/HTTPServer, and the links of 03-Net/URLShortener.
+package repository
+
+import "errors"
+
+// ErrNotFound is returned when a product with the requested ID does not exist
+var ErrNotFound = errors.New("product not found")
+
+// Product is the domain object stored by the repositories
+type Product struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+	Stock int    `json:"stock"`
+}
+
+// ProductRepository defines the operations every storage backend must provide
+type ProductRepository interface {
+	// Create stores a new product and assigns its ID
+	Create(p *Product) error
+	// GetByID returns the product with the given ID or ErrNotFound
+	GetByID(id int64) (Product, error)
+	// Update replaces an existing product or returns ErrNotFound
+	Update(p Product) error
+	// Delete removes a product or returns ErrNotFound
+	Delete(id int64) error
+	// List returns all products ordered by ID
+	List() ([]Product, error)
+	// FindByName returns the products whose name contains the given text (case insensitive)
+	FindByName(text string) ([]Product, error)
+	// FindByPriceRange returns the products with min <= price <= max ordered by ID
+	FindByPriceRange(min, max int) ([]Product, error)
+	// FindInStock returns the products with a positive stock ordered by ID
+	FindInStock() ([]Product, error)
+}
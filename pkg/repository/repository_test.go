@@ -0,0 +1,238 @@
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Arcanm/go_advanced_course/pkg/objectmother"
+	"github.com/Arcanm/go_advanced_course/pkg/repository"
+)
+
+// backends builds a fresh, empty repository of every implementation; the SQLite
+// ones are closed when the test ends
+var backends = []struct {
+	name    string
+	newRepo func(t *testing.T) repository.ProductRepository
+}{
+	{"Memory", func(t *testing.T) repository.ProductRepository {
+		return repository.NewInMemoryProductRepository()
+	}},
+	{"SQLite", func(t *testing.T) repository.ProductRepository {
+		repo, err := repository.NewSQLiteProductRepository(":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	}},
+}
+
+// conformanceCases are the expectations every ProductRepository must satisfy.
+// Each case receives a fresh, empty repository.
+var conformanceCases = []struct {
+	name string
+	run  func(t *testing.T, repo repository.ProductRepository)
+}{
+	{"CreateAssignsDistinctIDs", func(t *testing.T, repo repository.ProductRepository) {
+		created := create(t, repo, objectmother.AProduct().Build(), objectmother.AProduct().Build())
+		a, b := created[0], created[1]
+		if a.ID == 0 || a.ID == b.ID {
+			t.Errorf("got ids %d and %d", a.ID, b.ID)
+		}
+	}},
+	{"GetReturnsTheStoredProduct", func(t *testing.T, repo repository.ProductRepository) {
+		want := create(t, repo, objectmother.AProduct().Build())[0]
+		got, err := repo.GetByID(want.ID)
+		if err != nil || got != want {
+			t.Errorf("got %+v, %v, want %+v", got, err, want)
+		}
+	}},
+	{"GetUnknownID", func(t *testing.T, repo repository.ProductRepository) {
+		if _, err := repo.GetByID(42); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("got error %v, want ErrNotFound", err)
+		}
+	}},
+	{"UpdateReplacesTheProduct", func(t *testing.T, repo repository.ProductRepository) {
+		p := create(t, repo, objectmother.AProduct().Build())[0]
+		p.Price = 999
+		if err := repo.Update(p); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.GetByID(p.ID); err != nil || got.Price != 999 {
+			t.Errorf("got %+v, %v, want price 999", got, err)
+		}
+	}},
+	{"UpdateUnknownID", func(t *testing.T, repo repository.ProductRepository) {
+		if err := repo.Update(objectmother.AProduct().WithID(42).Build()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("got error %v, want ErrNotFound", err)
+		}
+	}},
+	{"DeleteRemovesTheProduct", func(t *testing.T, repo repository.ProductRepository) {
+		p := create(t, repo, objectmother.AProduct().Build())[0]
+		if err := repo.Delete(p.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByID(p.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("got error %v after delete, want ErrNotFound", err)
+		}
+		if err := repo.Delete(p.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("got error %v on second delete, want ErrNotFound", err)
+		}
+	}},
+	{"QueriesFilterAndOrderByID", func(t *testing.T, repo repository.ProductRepository) {
+		create(t, repo,
+			objectmother.AProduct().Build(),
+			objectmother.AnOutOfStockProduct().WithPrice(900).Build(),
+			objectmother.AProduct().WithName("Gaming laptop").WithPrice(2500).Build(),
+		)
+		queries := []struct {
+			name  string
+			query func() ([]repository.Product, error)
+			want  []string
+		}{
+			{"List", repo.List, []string{"Laptop", "Desktop", "Gaming laptop"}},
+			{"FindByName", func() ([]repository.Product, error) { return repo.FindByName("LAPTOP") }, []string{"Laptop", "Gaming laptop"}},
+			{"FindByPriceRange", func() ([]repository.Product, error) { return repo.FindByPriceRange(900, 1200) }, []string{"Laptop", "Desktop"}},
+			{"FindInStock", repo.FindInStock, []string{"Laptop", "Gaming laptop"}},
+		}
+		for _, q := range queries {
+			checkNames(t, q.name, q.want)(q.query())
+		}
+	}},
+	{"FindByNameMatchesWildcardsLiterally", func(t *testing.T, repo repository.ProductRepository) {
+		create(t, repo,
+			objectmother.AProduct().WithName("100% cotton").Build(),
+			objectmother.AProduct().WithName("1000 cotton").Build(),
+			objectmother.AProduct().WithName("usb_c cable").Build(),
+			objectmother.AProduct().WithName("usbXc cable").Build(),
+			objectmother.AProduct().WithName(`C:\drivers`).Build(),
+		)
+		for text, want := range map[string][]string{
+			"%":      {"100% cotton"},
+			"0% c":   {"100% cotton"},
+			"_":      {"usb_c cable"},
+			"b_c":    {"usb_c cable"},
+			`\`:      {`C:\drivers`},
+			"cotton": {"100% cotton", "1000 cotton"},
+		} {
+			checkNames(t, "FindByName("+text+")", want)(repo.FindByName(text))
+		}
+	}},
+	{"FindByNameIgnoresTheCaseOfAnyLetter", func(t *testing.T, repo repository.ProductRepository) {
+		create(t, repo,
+			objectmother.AProduct().WithName("Éclair").Build(),
+			objectmother.AProduct().WithName("ÑANDÚ plush").Build(),
+			objectmother.AProduct().WithName("Eclair").Build(),
+		)
+		for text, want := range map[string][]string{
+			"éclair": {"Éclair"},
+			"ÉCLAIR": {"Éclair"},
+			"ñandú":  {"ÑANDÚ plush"},
+			"eclair": {"Eclair"},
+		} {
+			checkNames(t, "FindByName("+text+")", want)(repo.FindByName(text))
+		}
+	}},
+}
+
+func TestConformance(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, c := range conformanceCases {
+				t.Run(c.name, func(t *testing.T) {
+					c.run(t, backend.newRepo(t))
+				})
+			}
+		})
+	}
+}
+
+// TestSQLiteOlderTable opens a database whose table predates name_lower: the column is
+// added and filled from the names, so FindByName finds the products stored before
+func TestSQLiteOlderTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE products (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		name  TEXT    NOT NULL,
+		price INTEGER NOT NULL,
+		stock INTEGER NOT NULL
+	);
+	INSERT INTO products (name, price, stock) VALUES ('Éclair', 300, 5)`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := repository.NewSQLiteProductRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	checkNames(t, "FindByName(éclair)", []string{"Éclair"})(repo.FindByName("éclair"))
+}
+
+// create stores the products, failing the test on the first error, and returns them
+// with the IDs assigned by the repository
+func create(t *testing.T, repo repository.ProductRepository, products ...repository.Product) []repository.Product {
+	t.Helper()
+	for i := range products {
+		if err := repo.Create(&products[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return products
+}
+
+// checkNames returns a function that compares the names of a query result with want
+func checkNames(t *testing.T, query string, want []string) func([]repository.Product, error) {
+	t.Helper()
+	return func(products []repository.Product, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			return
+		}
+		got := make([]string, len(products))
+		for i, p := range products {
+			got[i] = p.Name
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := repository.NewMemory[string, int]()
+	if !m.Insert("a", 1) || !m.Insert("b", 2) || !m.Insert("c", 3) {
+		t.Fatal("Insert refused a new key")
+	}
+	if m.Insert("a", 10) {
+		t.Error("Insert overwrote an existing key")
+	}
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Errorf("got %d, %v for a, want 1", v, ok)
+	}
+	if !m.Update("a", func(v int) int { return v + 10 }) || m.Update("z", func(v int) int { return v }) {
+		t.Error("Update reported a wrong result")
+	}
+	if v, _ := m.Get("a"); v != 11 {
+		t.Errorf("got %d after Update, want 11", v)
+	}
+	if !m.Delete("b") || m.Delete("b") {
+		t.Error("Delete reported a wrong result")
+	}
+	if removed := m.DeleteFunc(func(v int) bool { return v > 5 }); removed != 1 {
+		t.Errorf("DeleteFunc removed %d values, want 1", removed)
+	}
+	if got := m.Filter(func(int) bool { return true }); !slices.Equal(got, []int{3}) {
+		t.Errorf("got %v left, want [3]", got)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	// Pure Go SQLite driver registered under the name "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteProductRepository stores products in a SQLite table through database/sql
+type SQLiteProductRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteProductRepository opens the database at path and creates the table if needed
+func NewSQLiteProductRepository(path string) (*SQLiteProductRepository, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to ":memory:" gets its own database, so keep a single one
+	db.SetMaxOpenConns(1)
+
+	// name_lower is the name lowered by Go for FindByName: the LIKE and lower() of SQLite
+	// only fold ASCII, so "éclair" wouldn't find "Éclair"
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS products (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT    NOT NULL,
+		name_lower TEXT    NOT NULL DEFAULT '',
+		price      INTEGER NOT NULL,
+		stock      INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating products table: %w", err)
+	}
+	if err := addNameLower(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("adding name_lower to products table: %w", err)
+	}
+	return &SQLiteProductRepository{db: db}, nil
+}
+
+// addNameLower adds the name_lower column to a table created before it, and fills it
+func addNameLower(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('products') WHERE name = 'name_lower'`).
+		Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := db.Exec(`ALTER TABLE products ADD COLUMN name_lower TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	products, err := (&SQLiteProductRepository{db: db}).List()
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		if _, err := db.Exec(`UPDATE products SET name_lower = ? WHERE id = ?`, strings.ToLower(p.Name), p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the underlying database
+func (r *SQLiteProductRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteProductRepository) Create(p *Product) error {
+	result, err := r.db.Exec(`INSERT INTO products (name, name_lower, price, stock) VALUES (?, ?, ?, ?)`,
+		p.Name, strings.ToLower(p.Name), p.Price, p.Stock)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.ID = id
+	return nil
+}
+
+func (r *SQLiteProductRepository) GetByID(id int64) (Product, error) {
+	var p Product
+	err := r.db.QueryRow(`SELECT id, name, price, stock FROM products WHERE id = ?`, id).
+		Scan(&p.ID, &p.Name, &p.Price, &p.Stock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Product{}, ErrNotFound
+	}
+	return p, err
+}
+
+func (r *SQLiteProductRepository) Update(p Product) error {
+	result, err := r.db.Exec(`UPDATE products SET name = ?, name_lower = ?, price = ?, stock = ? WHERE id = ?`,
+		p.Name, strings.ToLower(p.Name), p.Price, p.Stock, p.ID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+func (r *SQLiteProductRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM products WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+func (r *SQLiteProductRepository) List() ([]Product, error) {
+	return r.query(`SELECT id, name, price, stock FROM products ORDER BY id`)
+}
+
+func (r *SQLiteProductRepository) FindByName(text string) ([]Product, error) {
+	// Both sides are lowered by strings.ToLower, like in the in-memory backend, so the
+	// case of any letter is ignored, not only ASCII. The wildcards of LIKE are escaped so
+	// "%" and "_" in the text match literally, like strings.Contains
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(text))
+	return r.query(`SELECT id, name, price, stock FROM products WHERE name_lower LIKE ? ESCAPE '\' ORDER BY id`,
+		"%"+escaped+"%")
+}
+
+func (r *SQLiteProductRepository) FindByPriceRange(min, max int) ([]Product, error) {
+	return r.query(`SELECT id, name, price, stock FROM products WHERE price BETWEEN ? AND ? ORDER BY id`,
+		min, max)
+}
+
+func (r *SQLiteProductRepository) FindInStock() ([]Product, error) {
+	return r.query(`SELECT id, name, price, stock FROM products WHERE stock > 0 ORDER BY id`)
+}
+
+// query runs a SELECT and scans every row into a Product
+func (r *SQLiteProductRepository) query(query string, args ...any) ([]Product, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Product, 0)
+	for rows.Next() {
+		var p Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// expectOneRow turns "no rows affected" into ErrNotFound
+func expectOneRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
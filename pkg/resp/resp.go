@@ -0,0 +1,186 @@
+// Package resp is the subset of RESP, the protocol of Redis, spoken by 03-Net/MiniRedis,
+// 03-Net/CacheServer and the Redis store of pkg/cache: ReadCommand and WriteReply for the
+// server side, WriteCommand and ReadReply for the client side.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The messages, in both directions:
+//
+//	request:  *2\r\n$3\r\nGET\r\n$3\r\nkey\r\n   (an array of bulk strings)
+//	          GET key\r\n                        (inline form, handy with nc or telnet)
+//	replies:  +OK\r\n            simple string
+//	          -ERR message\r\n   error
+//	          :42\r\n            integer
+//	          $5\r\nhello\r\n    bulk string, $-1\r\n is null
+//	          *2\r\n...          array of replies
+//
+// Bulk strings carry their length, so keys and values can contain any byte.
+
+// Limits protecting a server from hostile or broken clients, and a client from a
+// broken server
+const (
+	maxArgs     = 1024
+	maxBulkSize = 512 * 1024 * 1024
+)
+
+// ErrProtocol is returned for malformed messages; the connection can't continue after it
+var ErrProtocol = errors.New("protocol error")
+
+// RedisError is an error reply sent by the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// ReadLine reads a line ending in \r\n, or \n for the inline commands. The text
+// protocol of memcached is made of the same lines.
+func ReadLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// ReadCommand reads a request in array or inline form
+func ReadCommand(r *bufio.Reader) ([]string, error) {
+	line, err := ReadLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxArgs {
+		return nil, fmt.Errorf("%w: invalid array length %q", ErrProtocol, line)
+	}
+	args := make([]string, count)
+	for i := range args {
+		if args[i], err = readBulk(r); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// readBulk reads a $<length>\r\n<bytes>\r\n bulk string
+func readBulk(r *bufio.Reader) (string, error) {
+	line, err := ReadLine(r)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "$") {
+		return "", fmt.Errorf("%w: expected a bulk string, got %q", ErrProtocol, line)
+	}
+	return bulkBody(r, line)
+}
+
+// bulkBody reads the bytes of a bulk string whose header line was already read
+func bulkBody(r *bufio.Reader, line string) (string, error) {
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > maxBulkSize {
+		return "", fmt.Errorf("%w: invalid bulk length %q", ErrProtocol, line)
+	}
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+	return string(buf[:size]), nil
+}
+
+// WriteCommand encodes a request as an array of bulk strings; it is also the AOF format
+// of 03-Net/MiniRedis
+func WriteCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// WriteReply encodes a reply: nil is null, string a bulk string, int an integer,
+// Status a simple string, error an error and []string an array of bulk strings
+func WriteReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case error:
+		// Errors are one line, a newline in the message would break the framing
+		fmt.Fprintf(w, "-%s\r\n", strings.ReplaceAll(v.Error(), "\n", " "))
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, s := range v {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported reply %T", reply))
+	}
+}
+
+// Status is a simple string reply, like OK or PONG
+type Status string
+
+// ReadReply decodes a reply: nil, string (simple and bulk), int64, []any or RedisError
+func ReadReply(r *bufio.Reader) (any, error) {
+	line, err := ReadLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty reply", ErrProtocol)
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid integer %q", ErrProtocol, line)
+		}
+		return n, nil
+	case '$':
+		if line == "$-1" {
+			return nil, nil
+		}
+		return bulkBody(r, line)
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%w: invalid array length %q", ErrProtocol, line)
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%w: unknown reply %q", ErrProtocol, line)
+}
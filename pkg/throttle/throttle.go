@@ -0,0 +1,176 @@
+// Package throttle caps the bandwidth of readers, writers and connections.
+//
+// A Limiter is a token bucket measured in bytes: it refills at a fixed rate up to a burst,
+// and every read or write takes as many tokens as bytes it moved, waiting when the bucket is
+// empty. Wrapping each connection with its own Limiter caps the bandwidth per connection;
+// sharing one Limiter between connections caps them all together.
+package throttle
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+// Limiter is a token bucket of bytes, safe for concurrent use
+type Limiter struct {
+	rate   float64 // Bytes per second
+	burst  int     // Bucket size, and the largest chunk moved at once
+	tokens float64 // Can go negative: the debt is paid by waiting
+	last   time.Time
+	clock  clock.Clock
+	mux    sync.Mutex
+}
+
+// NewLimiter allows bytesPerSecond on average and bursts of up to burst bytes.
+// A burst of about a tenth of the rate keeps the transfer smooth.
+func NewLimiter(bytesPerSecond, burst int) *Limiter {
+	burst = max(burst, 1)
+	return &Limiter{rate: float64(bytesPerSecond), burst: burst, tokens: float64(burst), last: time.Now(), clock: clock.Real{}}
+}
+
+// UseClock replaces the source of time of the limiter, see pkg/clock. The tests use a
+// clock.Fake to check the rate without sleeping.
+func (l *Limiter) UseClock(source clock.Clock) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.clock = source
+	l.last = source.Now()
+}
+
+// ForRate returns a limiter for bytesPerSecond with a burst of a tenth of a second
+// (at least 512 bytes), or nil for a rate of 0, which means unlimited
+func ForRate(bytesPerSecond int) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return NewLimiter(bytesPerSecond, max(bytesPerSecond/10, 512))
+}
+
+// Burst returns the bucket size
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// WaitN takes n tokens, waiting until they are available or ctx is done.
+// The tokens are reserved before waiting, so concurrent callers queue in order.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mux.Lock()
+	source := l.clock
+	now := source.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mux.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-source.After(wait):
+		return nil
+	case <-ctx.Done():
+		// Give back the tokens that won't be used
+		l.mux.Lock()
+		l.tokens += float64(n)
+		l.mux.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Reader reads from R no faster than its limiter allows
+type Reader struct {
+	R       io.Reader
+	Limiter *Limiter
+	Ctx     context.Context // Cancels the waits, context.Background() when nil
+}
+
+// NewReader throttles r; a nil limiter returns r unchanged
+func NewReader(r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &Reader{R: r, Limiter: l}
+}
+
+// Read reads at most a burst, then waits for the bytes actually read
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) > r.Limiter.Burst() {
+		p = p[:r.Limiter.Burst()]
+	}
+	n, err := r.R.Read(p)
+	if n > 0 {
+		if waitErr := r.Limiter.WaitN(contextOrBackground(r.Ctx), n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Writer writes to W no faster than its limiter allows
+type Writer struct {
+	W       io.Writer
+	Limiter *Limiter
+	Ctx     context.Context // Cancels the waits, context.Background() when nil
+}
+
+// NewWriter throttles w; a nil limiter returns w unchanged
+func NewWriter(w io.Writer, l *Limiter) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &Writer{W: w, Limiter: l}
+}
+
+// Write sends p in chunks of at most a burst, waiting before each one
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), w.Limiter.Burst())]
+		if err := w.Limiter.WaitN(contextOrBackground(w.Ctx), len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.W.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// Conn throttles the reads and writes of a connection with separate limiters
+type Conn struct {
+	net.Conn
+	reader io.Reader
+	writer io.Writer
+}
+
+// NewConn wraps conn; a nil limiter leaves that direction unlimited
+func NewConn(conn net.Conn, read, write *Limiter) net.Conn {
+	if read == nil && write == nil {
+		return conn
+	}
+	return &Conn{Conn: conn, reader: NewReader(conn, read), writer: NewWriter(conn, write)}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.writer.Write(p)
+}
@@ -0,0 +1,186 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Arcanm/go_advanced_course/pkg/clock"
+)
+
+const (
+	rate  = 1000 // Bytes per second
+	burst = 100
+	total = 10000
+	step  = 100 * time.Millisecond // Time for one burst at rate
+)
+
+// countingWriter counts the bytes that reached it
+type countingWriter struct {
+	n atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// fakeLimiter returns a limiter of rate with a stopped clock
+func fakeLimiter() (*Limiter, *clock.Fake) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLimiter(rate, burst)
+	l.UseClock(fake)
+	return l, fake
+}
+
+// drive advances the clock by step each time the limiter waits, until done is closed.
+// After every step it checks that moved is within what the rate allows, and it returns
+// the time it took.
+func drive(t *testing.T, fake *clock.Fake, done <-chan struct{}, moved func() int64) time.Duration {
+	t.Helper()
+	var elapsed time.Duration
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			return elapsed
+		case <-deadline:
+			t.Fatalf("still running after %s of fake time", elapsed)
+		default:
+		}
+		if fake.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		fake.Advance(step)
+		elapsed += step
+		if limit := burst + int64(elapsed.Seconds()*rate); moved() > limit {
+			t.Fatalf("%d bytes after %s, want at most %d", moved(), elapsed, limit)
+		}
+	}
+}
+
+// TestRate checks that the writer and the reader move total bytes in total/rate seconds
+// of the fake clock, and never more than a burst ahead of the rate
+func TestRate(t *testing.T) {
+	// The first burst is free, every other one waits for step
+	want := (total - burst) / burst * step
+
+	t.Run("writer", func(t *testing.T) {
+		l, fake := fakeLimiter()
+		dst := &countingWriter{}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if n, err := NewWriter(dst, l).Write(make([]byte, total)); n != total || err != nil {
+				t.Errorf("got %d, %v, want %d bytes", n, err, total)
+			}
+		}()
+		if elapsed := drive(t, fake, done, dst.n.Load); elapsed != want {
+			t.Fatalf("got %s, want %s", elapsed, want)
+		}
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		l, fake := fakeLimiter()
+		var read atomic.Int64
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r := NewReader(strings.NewReader(strings.Repeat("x", total)), l)
+			buf := make([]byte, 4096)
+			for {
+				n, err := r.Read(buf)
+				if n > burst {
+					t.Errorf("read %d bytes at once, want at most %d", n, burst)
+				}
+				read.Add(int64(n))
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		// The reader waits after reading, so it is one burst ahead of the writer
+		moved := func() int64 { return read.Load() - burst }
+		if elapsed := drive(t, fake, done, moved); elapsed != want {
+			t.Fatalf("got %s, want %s", elapsed, want)
+		}
+		if got := read.Load(); got != total {
+			t.Fatalf("read %d bytes, want %d", got, total)
+		}
+	})
+
+	// A real clock, with a tolerance for the scheduler
+	t.Run("system time", func(t *testing.T) {
+		var buf bytes.Buffer
+		start := time.Now()
+		NewWriter(&buf, ForRate(20000)).Write(make([]byte, 6000))
+		// 2000 bytes of burst, then 4000 at 20000 B/s
+		if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("got %s, want about 200ms", elapsed)
+		}
+	})
+}
+
+// TestCancel checks that a cancelled context stops a waiting write and gives its tokens back
+func TestCancel(t *testing.T) {
+	l, fake := fakeLimiter()
+	ctx, cancel := context.WithCancel(context.Background())
+	dst := &countingWriter{}
+	result := make(chan error, 1)
+	go func() {
+		_, err := (&Writer{W: dst, Limiter: l, Ctx: ctx}).Write(make([]byte, total))
+		result <- err
+	}()
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the write still waits after cancel")
+	}
+	if got := dst.n.Load(); got != burst {
+		t.Fatalf("wrote %d bytes, want the first burst of %d", got, burst)
+	}
+
+	// The cancelled chunk didn't keep its tokens: the next one waits a single step
+	waited := make(chan error, 1)
+	go func() { waited <- l.WaitN(context.Background(), burst) }()
+	for fake.Waiters() < 2 { // The abandoned wait of the cancelled write is still counted
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(step)
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitN waited more than one step: the cancelled tokens were not given back")
+	}
+}
+
+// TestUnlimited checks that a nil limiter leaves the stream unwrapped
+func TestUnlimited(t *testing.T) {
+	var buf bytes.Buffer
+	if w := NewWriter(&buf, ForRate(0)); w != io.Writer(&buf) {
+		t.Errorf("got %T, want the writer itself", w)
+	}
+	if r := NewReader(&buf, nil); r != io.Reader(&buf) {
+		t.Errorf("got %T, want the reader itself", r)
+	}
+}